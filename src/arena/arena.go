@@ -0,0 +1,64 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package arena provides memory arenas: regions in which many values
+// are allocated and which are then freed all at once, explicitly,
+// rather than value by value by the garbage collector.
+//
+// An arena suits values sharing a bounded lifetime, such as the data
+// built while serving a request: they are allocated in an arena created
+// for the request, which is freed when the request completes. This
+// saves the garbage collector the work of tracing and sweeping each of
+// them.
+//
+// This package is experimental. It is only available if the toolchain
+// was built with GOEXPERIMENT=arenas; otherwise NewArena panics.
+//
+// Using the memory of a freed arena is an error that the runtime
+// detects by making the memory inaccessible: such a use crashes the
+// program with the error "use of freed arena memory". Memory freed while
+// the garbage collector is marking stays accessible until the marking
+// is done.
+package arena
+
+import "unsafe"
+
+// An Arena is a region of memory for values freed together by Free.
+// An Arena is not safe for concurrent use by multiple goroutines.
+type Arena struct {
+	a unsafe.Pointer
+}
+
+// NewArena returns a new, empty arena.
+func NewArena() *Arena {
+	return &Arena{a: runtime_newArena()}
+}
+
+// Free frees the arena and the values allocated in it, which must not
+// be used afterwards.
+func (a *Arena) Free() {
+	runtime_freeArena(a.a)
+}
+
+// New allocates a zero value of type T in the arena and stores a
+// pointer to it in *ptr, where ptr is a non-nil pointer of type **T.
+func (a *Arena) New(ptr interface{}) {
+	runtime_new(a.a, ptr)
+}
+
+// MakeSlice allocates an array of cap values of type T in the arena
+// and stores a slice of it of length len in *slicePtr, where slicePtr
+// is a non-nil pointer of type *[]T.
+//
+// Appending to the slice beyond its capacity allocates a new array in
+// the heap, not in the arena.
+func (a *Arena) MakeSlice(slicePtr interface{}, len, cap int) {
+	runtime_makeSlice(a.a, slicePtr, len, cap)
+}
+
+// Implemented in runtime.
+func runtime_newArena() unsafe.Pointer
+func runtime_freeArena(a unsafe.Pointer)
+func runtime_new(a unsafe.Pointer, ptr interface{})
+func runtime_makeSlice(a unsafe.Pointer, slicePtr interface{}, len, cap int)
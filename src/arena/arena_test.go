@@ -0,0 +1,76 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package arena_test
+
+import (
+	"arena"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newArena returns a new arena, or skips the test if the toolchain was
+// not built with GOEXPERIMENT=arenas.
+func newArena(t *testing.T) (a *arena.Arena) {
+	defer func() {
+		if recover() != nil {
+			t.Skip("toolchain not built with GOEXPERIMENT=arenas")
+		}
+	}()
+	return arena.NewArena()
+}
+
+func TestArena(t *testing.T) {
+	a := newArena(t)
+	defer a.Free()
+
+	var root *node
+	a.New(&root)
+	if root == nil || root.name != "" || root.children != nil {
+		t.Fatalf("New returned %v, want a pointer to a zero node", root)
+	}
+
+	a.MakeSlice(&root.children, 3, 10)
+	if len(root.children) != 3 || cap(root.children) != 10 {
+		t.Fatalf("MakeSlice returned len %d cap %d, want len 3 cap 10", len(root.children), cap(root.children))
+	}
+	for i, c := range root.children {
+		if c != nil {
+			t.Fatalf("children[%d] = %v, want nil", i, c)
+		}
+	}
+
+	// Values in the arena keep the heap values they point to alive.
+	for i := range root.children {
+		root.children[i] = &node{name: strings.Repeat("x", i+1)}
+	}
+	runtime.GC()
+	for i, c := range root.children {
+		if want := strings.Repeat("x", i+1); c.name != want {
+			t.Errorf("children[%d].name = %q after GC, want %q", i, c.name, want)
+		}
+	}
+}
+
+func TestArenaBadArgs(t *testing.T) {
+	a := newArena(t)
+	defer a.Free()
+
+	mustPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s did not panic", name)
+			}
+		}()
+		f()
+	}
+	var p *node
+	var s []int
+	mustPanic("New(nil)", func() { a.New(nil) })
+	mustPanic("New(p)", func() { a.New(p) })
+	mustPanic("MakeSlice(s)", func() { a.MakeSlice(s, 1, 1) })
+	mustPanic("MakeSlice with len > cap", func() { a.MakeSlice(&s, 2, 1) })
+}
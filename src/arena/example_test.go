@@ -0,0 +1,33 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package arena_test
+
+import (
+	"arena"
+	"fmt"
+)
+
+type node struct {
+	name     string
+	children []*node
+}
+
+// This example builds a tree in an arena, uses it and frees it at once.
+func Example() {
+	a := arena.NewArena()
+	defer a.Free()
+
+	var root *node
+	a.New(&root)
+	root.name = "root"
+	a.MakeSlice(&root.children, 3, 3)
+	for i := range root.children {
+		a.New(&root.children[i])
+		root.children[i].name = fmt.Sprint("child ", i)
+	}
+	for _, c := range root.children {
+		fmt.Println(c.name)
+	}
+}
@@ -600,9 +600,10 @@ func (w *Walker) writeType(buf *bytes.Buffer, typ types.Type) {
 
 	case *types.Interface:
 		buf.WriteString("interface{")
-		if typ.NumMethods() > 0 {
+		elems := append(sortedMethodNames(typ), w.typeSetElems(typ)...)
+		if len(elems) > 0 {
 			buf.WriteByte(' ')
-			buf.WriteString(strings.Join(sortedMethodNames(typ), ", "))
+			buf.WriteString(strings.Join(elems, ", "))
 			buf.WriteByte(' ')
 		}
 		buf.WriteString("}")
@@ -636,12 +637,79 @@ func (w *Walker) writeType(buf *bytes.Buffer, typ types.Type) {
 			buf.WriteByte('.')
 		}
 		buf.WriteString(typ.Obj().Name())
+		targs := typ.TypeArgs()
+		if len(targs) == 0 {
+			// The receiver of a method of a generic type.
+			for _, tpar := range typ.TypeParams() {
+				targs = append(targs, tpar)
+			}
+		}
+		if len(targs) > 0 {
+			buf.WriteByte('[')
+			for i, t := range targs {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				w.writeType(buf, t)
+			}
+			buf.WriteByte(']')
+		}
+
+	case *types.TypeParam:
+		// Type parameters are written by index, so that renaming
+		// them does not change the API.
+		fmt.Fprintf(buf, "$%d", typ.Index())
 
 	default:
 		panic(fmt.Sprintf("unknown type %T", typ))
 	}
 }
 
+// writeTypeParams writes the type parameter list tparams, if any.
+func (w *Walker) writeTypeParams(buf *bytes.Buffer, tparams []*types.TypeParam) {
+	if len(tparams) == 0 {
+		return
+	}
+	buf.WriteByte('[')
+	for i, tpar := range tparams {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		w.writeType(buf, tpar)
+		buf.WriteByte(' ')
+		w.writeType(buf, tpar.Constraint())
+	}
+	buf.WriteByte(']')
+}
+
+// typeSetElems returns the elements of the constraint interface typ
+// other than methods: embedded constraints and unions.
+func (w *Walker) typeSetElems(typ *types.Interface) []string {
+	var elems []string
+	for i := 0; i < typ.NumEmbeddeds(); i++ {
+		if e := typ.Embedded(i); !e.Underlying().(*types.Interface).IsMethodSet() {
+			elems = append(elems, w.typeString(e))
+		}
+	}
+	for i := 0; i < typ.NumUnions(); i++ {
+		elems = append(elems, w.unionString(typ.Union(i)))
+	}
+	return elems
+}
+
+func (w *Walker) unionString(u *types.Union) string {
+	var terms []string
+	for i := 0; i < u.Len(); i++ {
+		t := u.Term(i)
+		s := w.typeString(t.Type())
+		if t.Tilde() {
+			s = "~" + s
+		}
+		terms = append(terms, s)
+	}
+	return strings.Join(terms, " | ")
+}
+
 func (w *Walker) writeSignature(buf *bytes.Buffer, sig *types.Signature) {
 	w.writeParams(buf, sig.Params(), sig.Variadic())
 	switch res := sig.Results(); res.Len() {
@@ -710,6 +778,12 @@ func (w *Walker) emitObj(obj types.Object) {
 func (w *Walker) emitType(obj *types.TypeName) {
 	name := obj.Name()
 	typ := obj.Type()
+	if named, _ := typ.(*types.Named); named != nil && len(named.TypeParams()) > 0 {
+		var buf bytes.Buffer
+		buf.WriteString(name)
+		w.writeTypeParams(&buf, named.TypeParams())
+		name = buf.String()
+	}
 	switch typ := typ.Underlying().(type) {
 	case *types.Struct:
 		w.emitStructType(name, typ)
@@ -798,12 +872,14 @@ func (w *Walker) emitIfaceType(name string, typ *types.Interface) {
 		return
 	}
 
+	sort.Strings(methodNames)
+	methodNames = append(methodNames, w.typeSetElems(typ)...)
+
 	if len(methodNames) == 0 {
 		w.emitf("type %s interface {}", name)
 		return
 	}
 
-	sort.Strings(methodNames)
 	w.emitf("type %s interface { %s }", name, strings.Join(methodNames, ", "))
 }
 
@@ -812,7 +888,9 @@ func (w *Walker) emitFunc(f *types.Func) {
 	if sig.Recv() != nil {
 		panic("method considered a regular function: " + f.String())
 	}
-	w.emitf("func %s%s", f.Name(), w.signatureString(sig))
+	var buf bytes.Buffer
+	w.writeTypeParams(&buf, sig.TypeParams())
+	w.emitf("func %s%s%s", f.Name(), buf.String(), w.signatureString(sig))
 }
 
 func (w *Walker) emitMethod(m *types.Selection) {
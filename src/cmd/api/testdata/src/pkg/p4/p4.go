@@ -0,0 +1,26 @@
+package p4
+
+type Pair[T1 interface{ M() }, T2 ~int] struct {
+	A T1
+	B T2
+}
+
+func NewPair[T1 interface{ M() }, T2 ~int](a T1, b T2) Pair[T1, T2] {
+	return Pair[T1, T2]{a, b}
+}
+
+func (p Pair[X1, X2]) First() X1 {
+	return p.A
+}
+
+type Number interface {
+	~int | ~float64
+}
+
+func Sum[T Number](list []T) T {
+	var s T
+	for _, x := range list {
+		s += x
+	}
+	return s
+}
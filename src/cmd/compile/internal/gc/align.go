@@ -35,18 +35,26 @@ func expandiface(t *types.Type) {
 			continue
 		}
 
+		i := t.Extra.(*types.Interface)
 		if !m.Type.IsInterface() {
-			yyerrorl(asNode(m.Nname).Pos, "interface contains embedded non-interface %v", m.Type)
-			m.SetBroke(true)
-			t.SetBroke(true)
-			// Add to fields so that error messages
-			// include the broken embedded type when
-			// printing t.
-			// TODO(mdempsky): Revisit this.
-			fields = append(fields, m)
+			// Embedded non-interface type T: a union of
+			// the single term T, which makes t a
+			// constraint interface.
+			if m.Type.Broke() {
+				t.SetBroke(true)
+				continue
+			}
+			i.Unions = append(i.Unions, []types.Term{{Type: m.Type}})
 			continue
 		}
 
+		mi := m.Type.Extra.(*types.Interface)
+		m.Type.Fields() // expand m.Type's embedded interfaces
+		i.Unions = append(i.Unions, mi.Unions...)
+		if mi.Comparable {
+			i.Comparable = true
+		}
+
 		// Embedded interface: duplicate all methods
 		// (including broken ones, if any) and add to t's
 		// method set.
@@ -341,7 +349,11 @@ func dowidth(t *types.Type) {
 		if t.IsFuncArgStruct() {
 			Fatalf("dowidth fn struct %v", t)
 		}
-		w = widstruct(t, t, 0, 1)
+		align := 1
+		if isAtomicAlign64(t) {
+			align = 8
+		}
+		w = widstruct(t, t, 0, align)
 
 	// make fake type to check later to
 	// trigger function argument computation.
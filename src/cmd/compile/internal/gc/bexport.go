@@ -135,13 +135,16 @@ import (
 const debugFormat = false // default: false
 
 // Current export format version. Increase with each format change.
+// 6: generic functions and types (genericTag), constraint interfaces
+//    (constraintTag), type arguments of instance types; packages that
+//    use none of these are exported as version 5, with the same layout
 // 5: improved position encoding efficiency (issue 20080, CL 41619)
 // 4: type name objects support type aliases, uses aliasTag
 // 3: Go1.8 encoding (same as version 2, aliasTag defined but never used)
 // 2: removed unused bool in ODCL export (compiler only)
 // 1: header format change (more regular), export package for _ struct fields
 // 0: Go1.7 encoding
-const exportVersion = 5
+const exportVersion = 6
 
 // exportInlined enables the export of inlined function bodies and related
 // dependencies. The compiler should work w/o any loss of functionality with
@@ -199,11 +202,34 @@ func export(out *bufio.Writer, trace bool) int {
 		trace:         trace,
 	}
 
+	// Packages without type parameters, constraints or instances are
+	// exported in the version 5 layout so that older importers can
+	// still read them. Export the unexported objects that generic
+	// declarations refer to with the package-level objects: importers
+	// need them to set up the declarations.
+	version := 5
+	if len(instances) > 0 {
+		version = exportVersion
+	}
+	seen := make(map[*Node]bool)
+	for i := 0; i < len(exportlist); i++ {
+		n := exportlist[i]
+		if tm := templates[n]; tm != nil {
+			version = exportVersion
+			if !seen[n] {
+				seen[n] = true
+				tm.reexportdeps()
+			}
+		} else if n.Op == OTYPE && n.Type != nil && n.Type.IsConstraint() {
+			version = exportVersion
+		}
+	}
+
 	// write version info
 	// The version string must start with "version %d" where %d is the version
 	// number. Additional debugging information may follow after a blank; that
 	// text is ignored by the importer.
-	p.rawStringln(fmt.Sprintf("version %d", exportVersion))
+	p.rawStringln(fmt.Sprintf("version %d", version))
 	var debug string
 	if debugFormat {
 		debug = "debug"
@@ -245,6 +271,9 @@ func export(out *bufio.Writer, trace bool) int {
 			// TODO(mdempsky): Investigate why. This seems suspicious.
 			continue
 		}
+		if templates[asNode(sym.Def)] != nil {
+			continue
+		}
 		p.markType(asNode(sym.Def).Type)
 	}
 	p.marked = nil
@@ -281,7 +310,8 @@ func export(out *bufio.Writer, trace bool) int {
 
 		// TODO(gri) Closures have dots in their names;
 		// e.g., TestFloatZeroValue.func1 in math/big tests.
-		if strings.Contains(sym.Name, ".") {
+		// Instances may have qualified type arguments.
+		if strings.Contains(sym.Name, ".") && !strings.HasSuffix(sym.Name, "]") {
 			Fatalf("exporter: unexpected symbol: %v", sym)
 		}
 
@@ -343,7 +373,8 @@ func export(out *bufio.Writer, trace bool) int {
 
 		// TODO(gri) Closures have dots in their names;
 		// e.g., TestFloatZeroValue.func1 in math/big tests.
-		if strings.Contains(sym.Name, ".") {
+		// Instances may have qualified type arguments.
+		if strings.Contains(sym.Name, ".") && !strings.HasSuffix(sym.Name, "]") {
 			Fatalf("exporter: unexpected symbol: %v", sym)
 		}
 
@@ -535,6 +566,25 @@ func (p *exporter) obj(sym *types.Sym) {
 	// qualifier is not needed. Possible space optimization.)
 
 	n := asNode(sym.Def)
+	if tm := templates[n]; tm != nil {
+		// generic function or type
+		p.tag(genericTag)
+		p.pos(n)
+		p.qualifiedName(sym)
+		p.int(len(tm.methods) + 1)
+		for i := 0; i <= len(tm.methods); i++ {
+			decl, env := tm.unit(i)
+			p.int(len(env.imports))
+			for _, pack := range env.imports {
+				p.pkg(pack.Name.Pkg)
+				p.string(pack.Sym.Name)
+			}
+			p.string(source(decl))
+		}
+		tm.reexportdeps()
+		return
+	}
+
 	switch n.Op {
 	case OLITERAL:
 		// constant
@@ -755,6 +805,15 @@ func (p *exporter) typ(t *types.Type) {
 		p.pos(n)
 		p.qualifiedName(tsym)
 
+		// write type arguments of instance types
+		if isInstance(t) {
+			inst := instances[t]
+			p.int(len(inst.targs))
+			for _, targ := range inst.targs {
+				p.typ(targ)
+			}
+		}
+
 		// write underlying type
 		p.typ(t.Orig)
 
@@ -843,8 +902,23 @@ func (p *exporter) typ(t *types.Type) {
 		p.paramList(t.Results(), false)
 
 	case TINTER:
-		p.tag(interfaceTag)
+		if !t.IsConstraint() {
+			p.tag(interfaceTag)
+			p.methodList(t)
+			break
+		}
+		p.tag(constraintTag)
 		p.methodList(t)
+		iface := t.Extra.(*types.Interface)
+		p.bool(iface.Comparable)
+		p.int(len(iface.Unions))
+		for _, u := range iface.Unions {
+			p.int(len(u))
+			for _, term := range u {
+				p.bool(term.Tilde)
+				p.typ(term.Type)
+			}
+		}
 
 	case TMAP:
 		p.tag(mapTag)
@@ -1890,6 +1964,10 @@ const (
 
 	// Type aliases
 	aliasTag
+
+	// Generics
+	genericTag
+	constraintTag
 )
 
 // Debugging support.
@@ -1928,6 +2006,10 @@ var tagString = [...]string{
 
 	// Type aliases
 	-aliasTag: "alias",
+
+	// Generics
+	-genericTag:    "generic",
+	-constraintTag: "constraint",
 }
 
 // untype returns the "pseudo" untyped type for a Ctype (import/export use only).
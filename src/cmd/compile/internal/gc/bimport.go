@@ -96,10 +96,10 @@ func Import(imp *types.Pkg, in *bufio.Reader) {
 
 	// read version specific flags - extend as necessary
 	switch p.version {
-	// case 6:
+	// case 7:
 	// 	...
 	//	fallthrough
-	case 5, 4, 3, 2, 1:
+	case 6, 5, 4, 3, 2, 1:
 		p.debugFormat = p.rawStringln(p.rawByte()) == "debug"
 		p.trackAllTypes = p.bool()
 		p.posInfoFormat = p.bool()
@@ -390,6 +390,24 @@ func (p *importer) obj(tag int) {
 			fmt.Printf("import [%q] func %v \n", p.imp.Path, n)
 		}
 
+	case genericTag:
+		pos := p.pos()
+		sym := p.qualifiedName()
+		envs := make([]*templateEnv, p.int())
+		sources := make([]string, len(envs))
+		for i := range envs {
+			env := &templateEnv{pkg: sym.Pkg}
+			for j := p.int(); j > 0; j-- {
+				pack := nod(OPACK, nil, nil)
+				pack.Name.Pkg = p.pkg()
+				pack.Sym = sym.Pkg.Lookup(p.string())
+				env.imports = append(env.imports, pack)
+			}
+			envs[i] = env
+			sources[i] = p.string()
+		}
+		importgeneric(pos, p.imp, sym, envs, sources)
+
 	default:
 		p.formatErrorf("unexpected object (tag = %d)", tag)
 	}
@@ -499,6 +517,18 @@ func (p *importer) typ() *types.Type {
 		p.typList = append(p.typList, t)
 		dup := !t.IsKind(types.TFORW) // type already imported
 
+		// read type arguments of instance types
+		if p.version >= 6 && isInstance(t) {
+			targs := make([]*types.Type, p.int())
+			for i := range targs {
+				targs[i] = p.typ()
+			}
+			if instances[t] == nil {
+				base := tsym.Pkg.Lookup(tsym.Name[:strings.Index(tsym.Name, "[")])
+				instances[t] = &instance{base, targs}
+			}
+		}
+
 		// read underlying type
 		t0 := p.typ()
 		// TODO(mdempsky): Stop clobbering n.Pos in declare.
@@ -602,6 +632,21 @@ func (p *importer) typ() *types.Type {
 			t.SetInterface(ml)
 		}
 
+	case constraintTag:
+		t = p.newtyp(TINTER)
+		t.SetInterface(p.methodList())
+		iface := t.Extra.(*types.Interface)
+		iface.Comparable = p.bool()
+		iface.Unions = make([][]types.Term, p.int())
+		for i := range iface.Unions {
+			u := make([]types.Term, p.int())
+			for j := range u {
+				u[j].Tilde = p.bool()
+				u[j].Type = p.typ()
+			}
+			iface.Unions[i] = u
+		}
+
 	case mapTag:
 		t = p.newtyp(TMAP)
 		mt := t.MapType()
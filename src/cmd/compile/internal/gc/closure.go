@@ -491,7 +491,9 @@ func walkclosure(func_ *Node, init *Nodes) *Node {
 		if !v.Name.Byval() {
 			typ = types.NewPtr(typ)
 		}
-		fields = append(fields, symfield(v.Sym, typ))
+		// Use local names, as variables of instances of
+		// generic functions of other packages are not.
+		fields = append(fields, namedfield(v.Sym.Name, typ))
 	}
 	typ := tostruct(fields)
 	typ.SetNoalg(true)
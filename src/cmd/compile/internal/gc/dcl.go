@@ -571,6 +571,14 @@ func checkembeddedtype(t *types.Type) {
 	}
 }
 
+// checkconstraint reports an error if t, used as the type of a
+// value, is a constraint interface.
+func checkconstraint(t *types.Type) {
+	if t != nil && t.IsConstraint() {
+		yyerror("cannot use %v as a type: interface contains type constraints", t)
+	}
+}
+
 func structfield(n *Node) *types.Field {
 	lno := lineno
 	lineno = n.Pos
@@ -591,6 +599,7 @@ func structfield(n *Node) *types.Field {
 		if n.Embedded() {
 			checkembeddedtype(n.Type)
 		}
+		checkconstraint(n.Type)
 	}
 
 	n.Right = nil
@@ -751,6 +760,45 @@ func interfacefield(n *Node) *types.Field {
 	return f
 }
 
+// interfaceunion returns the terms of the union n of an interface:
+// the types in n.List, and those in n.Rlist, written ~T. It returns
+// nil if a term is invalid.
+func interfaceunion(n *Node) []types.Term {
+	lno := lineno
+	lineno = n.Pos
+	defer func() { lineno = lno }()
+
+	var terms []types.Term
+	broke := false
+	add := func(l Nodes, tilde bool) {
+		for i, x := range l.Slice() {
+			x = typecheck(x, Etype)
+			l.SetIndex(i, x)
+			t := x.Type
+			switch {
+			case t == nil:
+				broke = true
+				continue
+			case t.IsInterface():
+				yyerror("cannot use interface %v in union", t)
+				broke = true
+				continue
+			case tilde && t != t.Orig && t.Sym != nil:
+				yyerror("invalid use of ~ (underlying type of %v is %v)", t, t.Orig)
+				broke = true
+				continue
+			}
+			terms = append(terms, types.Term{Tilde: tilde, Type: t})
+		}
+	}
+	add(n.List, false)
+	add(n.Rlist, true)
+	if broke {
+		return nil
+	}
+	return terms
+}
+
 func tointerface(l []*Node) *types.Type {
 	if len(l) == 0 {
 		return types.Types[TINTER]
@@ -767,6 +815,17 @@ func tointerface0(t *types.Type, l []*Node) {
 
 	var fields []*types.Field
 	for _, n := range l {
+		if n.Op == ODCLFIELD && n.Left == nil && n.Right == nil && n.Type == nil {
+			// union of terms
+			u := interfaceunion(n)
+			if u == nil {
+				t.SetBroke(true)
+				continue
+			}
+			i := t.Extra.(*types.Interface)
+			i.Unions = append(i.Unions, u)
+			continue
+		}
 		f := interfacefield(n)
 		if f.Broke() {
 			t.SetBroke(true)
@@ -980,7 +1039,7 @@ func addmethod(msym *types.Sym, t *types.Type, local, nointerface bool) *types.F
 		return nil
 	}
 
-	if local && mt.Sym.Pkg != localpkg {
+	if local && mt.Sym.Pkg != localpkg && !isInstance(mt) {
 		yyerror("cannot define new methods on non-local type %v", mt)
 		return nil
 	}
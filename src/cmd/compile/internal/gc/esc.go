@@ -679,6 +679,19 @@ func (e *EscState) esc(n *Node, parent *Node) {
 		e.escassignSinkWhy(n, n, "too large for stack") // TODO category: tooLarge
 	}
 
+	// Stack frames are only register-aligned, so variables that need
+	// more alignment than that (see sync/atomic.Int64) must live on the heap.
+	if n.Esc != EscHeap && n.Type != nil &&
+		(n.Op == ONAME && n.Class() == PAUTO && int(n.Type.Align) > Widthreg ||
+			(n.Op == ONEW || n.Op == OPTRLIT) && int(n.Type.Elem().Align) > Widthreg) {
+		if Debug['m'] > 2 {
+			Warnl(n.Pos, "%v is too aligned for stack", n)
+		}
+		n.Esc = EscHeap
+		addrescapes(n)
+		e.escassignSinkWhy(n, n, "too aligned for stack")
+	}
+
 	e.esc(n.Left, n)
 
 	if n.Op == ORANGE {
@@ -811,6 +824,7 @@ func (e *EscState) esc(n *Node, parent *Node) {
 
 	case ODEFER:
 		if e.loopdepth == 1 { // top level
+			n.Esc = EscNever // force stack allocation of defer record, unless open-coded
 			break
 		}
 		// arguments leak out of scope
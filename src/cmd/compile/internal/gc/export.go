@@ -11,6 +11,7 @@ import (
 	"cmd/internal/bio"
 	"cmd/internal/src"
 	"fmt"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -86,6 +87,10 @@ func autoexport(n *Node, ctxt Class) {
 	if n.Type != nil && n.Type.IsKind(TFUNC) && n.IsMethod() {
 		return
 	}
+	if strings.HasSuffix(n.Sym.Name, "]") {
+		// instances are exported only as dependencies
+		return
+	}
 
 	if exportname(n.Sym.Name) || initname(n.Sym.Name) {
 		exportsym(n)
@@ -384,6 +389,9 @@ func dumpasmhdr() {
 
 		case OTYPE:
 			t := n.Type
+			if t == nil { // generic type
+				break
+			}
 			if !t.IsStruct() || t.StructType().Map != nil || t.IsFuncArgStruct() {
 				break
 			}
@@ -749,15 +749,38 @@ func typefmt(t *types.Type, flag FmtFlag, mode fmtMode, depth int) string {
 		return "map[" + tmodeString(t.Key(), mode, depth) + "]" + tmodeString(t.Val(), mode, depth)
 
 	case TINTER:
-		if t.IsEmptyInterface() {
+		iface := t.Extra.(*types.Interface)
+		if t.IsEmptyInterface() && !t.IsConstraint() {
 			return "interface {}"
 		}
 		buf := make([]byte, 0, 64)
 		buf = append(buf, "interface {"...)
-		for i, f := range t.Fields().Slice() {
-			if i != 0 {
+		n := 0
+		if iface.Comparable {
+			buf = append(buf, " comparable"...)
+			n++
+		}
+		for _, u := range iface.Unions {
+			if n != 0 {
+				buf = append(buf, ';')
+			}
+			n++
+			for i, term := range u {
+				if i != 0 {
+					buf = append(buf, " |"...)
+				}
+				buf = append(buf, ' ')
+				if term.Tilde {
+					buf = append(buf, '~')
+				}
+				buf = append(buf, tconv(term.Type, 0, mode, depth)...)
+			}
+		}
+		for _, f := range t.Fields().Slice() {
+			if n != 0 {
 				buf = append(buf, ';')
 			}
+			n++
 			buf = append(buf, ' ')
 			switch {
 			case f.Sym == nil:
@@ -771,7 +794,7 @@ func typefmt(t *types.Type, flag FmtFlag, mode fmtMode, depth int) string {
 			}
 			buf = append(buf, tconv(f.Type, FmtShort, mode, depth)...)
 		}
-		if t.NumFields() != 0 {
+		if n != 0 {
 			buf = append(buf, ' ')
 		}
 		buf = append(buf, '}')
@@ -0,0 +1,1018 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gc
+
+import (
+	"bytes"
+	"cmd/compile/internal/syntax"
+	"cmd/compile/internal/types"
+	"cmd/internal/objabi"
+	"cmd/internal/src"
+	"fmt"
+	"strings"
+)
+
+// Generic functions and types are compiled by stenciling. Their
+// declarations are kept as syntax trees, called templates, and each
+// instance is noded anew from its template, with the type parameters
+// bound to the type arguments, and then type checked and compiled
+// like a non-generic declaration. Instances are named after their
+// template and type arguments, as in Map[int,string], and marked
+// DUPOK, so that packages instantiating a template the same way
+// share the code.
+//
+// The bodies of generic functions are type checked for each instance
+// only; go/types checks them against the type constraints.
+
+// A templateEnv is the environment of the generic declarations of a
+// source file: the package they belong to and the file's imports.
+type templateEnv struct {
+	pkg     *types.Pkg
+	imports []*Node       // OPACK nodes, including those of dot imports
+	decls   []syntax.Decl // generic declarations, while noding the file
+}
+
+// A template is a generic function or type declaration.
+type template struct {
+	env     *templateEnv
+	name    *Node            // declared ONAME (function) or OTYPE (type)
+	fun     *syntax.FuncDecl // generic function, or
+	typ     *syntax.TypeDecl // generic type
+	methods []genericMethod  // methods of the generic type
+	insts   map[string]*Node // instances, by type arguments
+}
+
+// A genericMethod is a method of a generic type. It may be declared
+// in another file than the type.
+type genericMethod struct {
+	decl *syntax.FuncDecl
+	env  *templateEnv
+}
+
+// An instance records the generic type and the type arguments of
+// an instance type.
+type instance struct {
+	base  *types.Sym
+	targs []*types.Type
+}
+
+var (
+	templates      = make(map[*Node]*template)
+	instances      = make(map[*types.Type]*instance)
+	genericMethods []genericMethod // not yet attached to their type
+
+	instDepth map[*Node]int // nesting of function instances
+
+	// instPkg records the package of function instances of
+	// generic declarations of other packages.
+	instPkg  = make(map[*Node]*types.Pkg)
+	instNest int // nesting of type instantiations
+)
+
+// maxInstDepth limits the nesting of instantiations, to detect
+// instantiation cycles such as a function F[T] calling F[[]T].
+const maxInstDepth = 50
+
+func (p *noder) templateEnv() *templateEnv {
+	if p.env == nil {
+		// Imports precede all other declarations, so
+		// p.imports is complete.
+		p.env = &templateEnv{pkg: localpkg, imports: p.imports}
+	}
+	return p.env
+}
+
+// genericDecl records the declaration of the generic type n.
+func (p *noder) genericDecl(n *Node, decl *syntax.TypeDecl) {
+	if dclcontext != PEXTERN {
+		yyerrorl(n.Pos, "generic type cannot be declared inside a function")
+		return
+	}
+	env := p.templateEnv()
+	env.decls = append(env.decls, decl)
+	templates[n] = &template{env: env, name: n, typ: decl, insts: make(map[string]*Node)}
+}
+
+// genericFuncDecl records the declaration of a generic function or
+// of a method of a generic type.
+func (p *noder) genericFuncDecl(fun *syntax.FuncDecl) {
+	env := p.templateEnv()
+	env.decls = append(env.decls, fun)
+
+	if fun.Recv != nil {
+		// The type may be declared in a file not yet noded:
+		// attachGenericMethods attaches the method later.
+		genericMethods = append(genericMethods, genericMethod{fun, env})
+		return
+	}
+
+	name := p.name(fun.Name)
+	if name.Name == "init" || localpkg.Name == "main" && name.Name == "main" {
+		yyerrorpos(fun.Pos(), "func %s must have no type parameters", name.Name)
+		return
+	}
+	n := newfuncname(name)
+	declare(n, PFUNC)
+	templates[n] = &template{env: env, name: n, fun: fun, insts: make(map[string]*Node)}
+}
+
+// isGenericRecv reports whether recv is the receiver of a method of a
+// generic type, as in func (l *List[T]) Len() int.
+func isGenericRecv(recv *syntax.Field) bool {
+	if recv == nil {
+		return false
+	}
+	_, ok := unparenRecv(recv.Type).(*syntax.IndexExpr)
+	return ok
+}
+
+// unparenRecv returns the receiver type x, without parentheses and
+// pointer indirection.
+func unparenRecv(x syntax.Expr) syntax.Expr {
+	for {
+		switch y := x.(type) {
+		case *syntax.ParenExpr:
+			x = y.X
+			continue
+		case *syntax.Operation:
+			if y.Op == syntax.Mul && y.Y == nil {
+				x = y.X
+				continue
+			}
+		}
+		return x
+	}
+}
+
+// recvTypeParams returns the base type name and the type parameter
+// names of the receiver of a method of a generic type.
+func recvTypeParams(recv *syntax.Field) (base *syntax.Name, names []*syntax.Name) {
+	x := unparenRecv(recv.Type).(*syntax.IndexExpr)
+	base, _ = x.X.(*syntax.Name)
+	list := []syntax.Expr{x.Index}
+	if l, ok := x.Index.(*syntax.ListExpr); ok {
+		list = l.ElemList
+	}
+	for _, x := range list {
+		name, ok := x.(*syntax.Name)
+		if !ok {
+			yyerrorpos(x.Pos(), "receiver type parameter %s must be an identifier", syntax.String(x))
+			return nil, nil
+		}
+		names = append(names, name)
+	}
+	return base, names
+}
+
+// attachGenericMethods attaches the methods of generic types to
+// their templates, once all files are noded.
+func attachGenericMethods() {
+	for _, m := range genericMethods {
+		m.attach()
+	}
+	genericMethods = nil
+}
+
+func (m genericMethod) attach() {
+	base, names := recvTypeParams(m.decl.Recv)
+	if base == nil {
+		return
+	}
+	tm := templates[asNode(m.env.pkg.Lookup(base.Value).Def)]
+	if tm == nil || tm.typ == nil {
+		yyerrorpos(m.decl.Pos(), "cannot define method %s with type parameters on non-generic type %s", m.decl.Name.Value, base.Value)
+		return
+	}
+	if len(names) != len(tm.typ.TParamList) {
+		yyerrorpos(m.decl.Pos(), "got %d type parameters, but receiver base type %s declares %d", len(names), base.Value, len(tm.typ.TParamList))
+		return
+	}
+	tm.methods = append(tm.methods, m)
+}
+
+// markImportsUsed marks the imports used by the generic declarations
+// of the file as used: the declarations are noded only when
+// instantiated, after the file's imports are cleared.
+func (env *templateEnv) markImportsUsed() {
+	for _, decl := range env.decls {
+		syntax.Inspect(decl, func(n syntax.Node) bool {
+			if n, ok := n.(*syntax.Name); ok {
+				def := asNode(lookup(n.Value).Def)
+				switch {
+				case def == nil:
+				case def.Op == OPACK:
+					def.Name.SetUsed(true)
+				case def.Name != nil && def.Name.Pack != nil:
+					def.Name.Pack.Name.SetUsed(true)
+				}
+			}
+			return true
+		})
+	}
+	env.decls = nil
+}
+
+// resolve returns the symbol that the identifier s denotes in a
+// generic declaration of env if s, in the declaration's package, is
+// not defined: a name imported with import . or a predeclared name.
+func (env *templateEnv) resolve(s *types.Sym) *types.Sym {
+	if exportname(s.Name) {
+		for _, pack := range env.imports {
+			if pack.Sym.Name == "." {
+				if s1 := pack.Name.Pkg.Lookup(s.Name); s1.Def != nil {
+					return s1
+				}
+			}
+		}
+	}
+	if s1 := builtinpkg.Lookup(s.Name); s1.Def != nil {
+		return s1
+	}
+	return s
+}
+
+// bindings temporarily binds symbols to definitions, to node an
+// instance of a generic declaration.
+type bindings []struct {
+	sym *types.Sym
+	def *types.Node
+}
+
+func (b *bindings) bind(s *types.Sym, n *Node) {
+	*b = append(*b, struct {
+		sym *types.Sym
+		def *types.Node
+	}{s, s.Def})
+	s.Def = asTypesNode(n)
+}
+
+func (b *bindings) bindImports(env *templateEnv) {
+	for _, pack := range env.imports {
+		if pack.Sym.Name != "." {
+			b.bind(pack.Sym, pack)
+		}
+	}
+}
+
+func (b bindings) unbind() {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i].sym.Def = b[i].def
+	}
+}
+
+// templateOf returns the template of the generic function or type
+// that n denotes, or nil.
+func templateOf(n *Node) *template {
+	if len(templates) == 0 || n == nil {
+		return nil
+	}
+	return templates[resolve(unparen(n))]
+}
+
+func (tm *template) tparams() []*syntax.Field {
+	if tm.fun != nil {
+		return tm.fun.TParamList
+	}
+	return tm.typ.TParamList
+}
+
+func (tm *template) kind() string {
+	if tm.fun != nil {
+		return "function"
+	}
+	return "type"
+}
+
+// typeArgsKey returns the type arguments targs as they appear in the
+// names of instances. Types of the local package are qualified by
+// its import path, as in other packages.
+func typeArgsKey(targs []*types.Type) string {
+	var buf []byte
+	for i, t := range targs {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, t.ShortString()...)
+	}
+	key := string(buf)
+	if myimportpath != "" {
+		key = strings.Replace(key, `"".`, objabi.PathToPrefix(myimportpath)+".", -1)
+	}
+	return key
+}
+
+// instantiate returns the instance of the template of n for the type
+// arguments targs: the ONAME of a function or the OTYPE of a type.
+// It returns nil, after reporting an error, if targs are not valid.
+func instantiate(n *Node, targs []*types.Type) *Node {
+	tm := templates[n]
+	tparams := tm.tparams()
+	if len(targs) != len(tparams) {
+		yyerror("got %d type arguments but %v has %d type parameters", len(targs), n.Sym, len(tparams))
+		return nil
+	}
+	for _, t := range targs {
+		if t == nil || t.Broke() {
+			return nil
+		}
+	}
+
+	key := typeArgsKey(targs)
+	if inst := tm.insts[key]; inst != nil {
+		return inst
+	}
+	sym := tm.env.pkg.Lookup(n.Sym.Name + "[" + key + "]")
+	if def := asNode(sym.Def); def != nil {
+		// imported instance
+		tm.insts[key] = def
+		return def
+	}
+
+	depth := instDepth[Curfn] + instNest
+	if depth >= maxInstDepth {
+		yyerror("instantiation cycle instantiating %v", sym)
+		return nil
+	}
+	if !tm.satisfied(targs) {
+		return nil
+	}
+
+	lno := lineno
+	saveCurfn, saveDecldepth := Curfn, decldepth
+	Curfn = nil
+	// Defer width calculations for recursive instance types,
+	// as for the package's type declarations.
+	deferwidth := defercalc == 0
+	if deferwidth {
+		defercheckwidth()
+	}
+	var inst *Node
+	if tm.fun != nil {
+		inst = tm.instantiateFunc(sym, key, targs, depth+1)
+	} else {
+		instNest++
+		inst = tm.instantiateType(sym, key, targs)
+		instNest--
+	}
+	if deferwidth {
+		resumecheckwidth()
+	}
+	Curfn, decldepth = saveCurfn, saveDecldepth
+	lineno = lno
+	return inst
+}
+
+// isInstance reports whether t is an instance of a generic type,
+// instantiated by this or another package.
+func isInstance(t *types.Type) bool {
+	return t.Sym != nil && strings.HasSuffix(t.Sym.Name, "]")
+}
+
+// bindTypeParams binds the type parameter names to targs.
+func (b *bindings) bindTypeParams(q *noder, names []*syntax.Name, targs []*types.Type) {
+	for i, name := range names {
+		b.bind(q.name(name), typenod(targs[i]))
+	}
+}
+
+func tparamNames(tparams []*syntax.Field) []*syntax.Name {
+	var names []*syntax.Name
+	for _, f := range tparams {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func (tm *template) instantiateFunc(sym *types.Sym, key string, targs []*types.Type, depth int) *Node {
+	q := &noder{env: tm.env, pkg: tm.env.pkg, inst: sym}
+	var b bindings
+	b.bindImports(tm.env)
+	b.bindTypeParams(q, tparamNames(tm.fun.TParamList), targs)
+	q.lineno(tm.fun)
+	fn := q.funcDecl(tm.fun)
+	b.unbind()
+
+	fn.Func.SetDupok(true)
+	if tm.env.pkg != localpkg {
+		instPkg[fn] = tm.env.pkg
+	}
+	tm.insts[key] = fn.Func.Nname
+	if instDepth == nil {
+		instDepth = make(map[*Node]int)
+	}
+	instDepth[fn] = depth
+
+	// The body is type checked with those of the other functions
+	// in xtop.
+	fn = typecheck(fn, Etop)
+	xtop = append(xtop, fn)
+	return fn.Func.Nname
+}
+
+func (tm *template) instantiateType(sym *types.Sym, key string, targs []*types.Type) *Node {
+	q := &noder{env: tm.env, pkg: tm.env.pkg}
+	n := newname(sym)
+	n.Op = OTYPE
+	n.Pos = tm.name.Pos
+	sym.Def = asTypesNode(n)
+	sym.Block = 1
+	sym.Lastlineno = n.Pos
+
+	var b bindings
+	b.bindImports(tm.env)
+	b.bindTypeParams(q, tparamNames(tm.typ.TParamList), targs)
+	q.lineno(tm.typ)
+	n.Name.Param.Ntype = q.typeExpr(tm.typ.Type)
+	n.Name.Param.Pragma = tm.typ.Pragma
+	b.unbind()
+
+	// Register the instance before type checking it,
+	// for recursive types.
+	tm.insts[key] = n
+	n = typecheck(n, Etype)
+	if n.Type == nil {
+		return n
+	}
+	instances[n.Type] = &instance{tm.name.Sym, targs}
+
+	for _, m := range tm.methods {
+		q := &noder{env: m.env, pkg: m.env.pkg}
+		_, names := recvTypeParams(m.decl.Recv)
+		var b bindings
+		b.bindImports(m.env)
+		b.bindTypeParams(q, names, targs)
+		q.lineno(m.decl)
+		fn := q.funcDecl(m.decl)
+		b.unbind()
+
+		fn.Func.SetDupok(true)
+		if m.env.pkg != localpkg {
+			instPkg[fn] = m.env.pkg
+		}
+		fn = typecheck(fn, Etop)
+		xtop = append(xtop, fn)
+	}
+	return n
+}
+
+// curpkg returns the package of the function being type checked: the
+// local package, or that of the template of a function instance.
+func curpkg() *types.Pkg {
+	fn := Curfn
+	for fn != nil && fn.Func.Outerfunc != nil {
+		fn = fn.Func.Outerfunc
+	}
+	if pkg := instPkg[fn]; pkg != nil {
+		return pkg
+	}
+	return localpkg
+}
+
+// satisfied reports whether the type arguments targs satisfy the
+// constraints of the type parameters of tm, and reports an error
+// if not.
+func (tm *template) satisfied(targs []*types.Type) bool {
+	lno := lineno
+	defer func() { lineno = lno }()
+
+	tparams := tm.tparams()
+	q := &noder{env: tm.env, pkg: tm.env.pkg}
+	var b bindings
+	b.bindImports(tm.env)
+	b.bindTypeParams(q, tparamNames(tparams), targs)
+	constraints := make([]*Node, len(tparams))
+	for i, f := range tparams {
+		if i > 0 && f.Type == tparams[i-1].Type {
+			constraints[i] = constraints[i-1]
+			continue
+		}
+		c := f.Type
+		if _, ok := c.(*syntax.Operation); ok {
+			// ~T or a union: implicit interface
+			c = &syntax.InterfaceType{MethodList: []*syntax.Field{{Type: c}}}
+		}
+		constraints[i] = q.typeExpr(c)
+	}
+	b.unbind()
+
+	for i, c := range constraints {
+		c = typecheck(c, Etype)
+		constraints[i] = c
+		if c.Type == nil {
+			return false
+		}
+		lineno = lno
+		if ok, why := satisfies(targs[i], c.Type); !ok {
+			if why != "" {
+				why = " (" + why + ")"
+			}
+			yyerror("%v does not satisfy %v%s", targs[i], c.Type, why)
+			return false
+		}
+	}
+	return true
+}
+
+// satisfies reports whether t satisfies the constraint c. If not,
+// it also returns the reason.
+func satisfies(t, c *types.Type) (bool, string) {
+	if !c.IsInterface() {
+		// a type constraint T, which stands for interface{ T }
+		return eqtype(t, c), ""
+	}
+
+	c.Fields() // expand embedded interfaces
+	iface := c.Extra.(*types.Interface)
+	for _, union := range iface.Unions {
+		if !inUnion(t, union) {
+			return false, fmt.Sprintf("%v missing in %s", t, unionString(union))
+		}
+	}
+	if iface.Comparable && !IsComparable(t) {
+		return false, fmt.Sprintf("%v is not comparable", t)
+	}
+	if c.NumFields() > 0 {
+		var missing, have *types.Field
+		var ptr int
+		if !implements(t, c, &missing, &have, &ptr) {
+			return false, fmt.Sprintf("missing method %v", missing.Sym)
+		}
+	}
+	return true, ""
+}
+
+func inUnion(t *types.Type, union []types.Term) bool {
+	for _, term := range union {
+		if term.Tilde && eqtype(t.Orig, term.Type.Orig) || eqtype(t, term.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func unionString(union []types.Term) string {
+	var buf []byte
+	for i, term := range union {
+		if i > 0 {
+			buf = append(buf, " | "...)
+		}
+		if term.Tilde {
+			buf = append(buf, '~')
+		}
+		buf = append(buf, term.Type.String()...)
+	}
+	return string(buf)
+}
+
+// typecheckinstance type checks the instantiation n of a generic
+// function or type, tm[targs], and returns the instance.
+func typecheckinstance(n *Node, tm *template, top int) *Node {
+	targs := typeargs(n)
+	if targs == nil {
+		n.Type = nil
+		return n
+	}
+	inst := instantiate(tm.name, targs)
+	if inst == nil {
+		n.Type = nil
+		return n
+	}
+	return typecheck(inst, top)
+}
+
+// typeargs type checks and returns the type arguments of the
+// instantiation n, or nil if one is not a valid type.
+func typeargs(n *Node) []*types.Type {
+	list := n.List.Slice()
+	if n.Right != nil {
+		list = []*Node{n.Right}
+	}
+	targs := make([]*types.Type, len(list))
+	for i, x := range list {
+		x = typecheck(x, Etype)
+		list[i] = x
+		if x.Type == nil {
+			return nil
+		}
+		if x.Op != OTYPE {
+			yyerror("%v is not a type", x)
+			return nil
+		}
+		targs[i] = x.Type
+	}
+	if n.Right != nil {
+		n.Right = list[0]
+	}
+	return targs
+}
+
+// instantiatecall infers the missing type arguments of the call n of
+// a generic function from the types of the arguments, and replaces
+// the callee by the instance. It reports whether the call is valid
+// so far.
+func instantiatecall(n *Node) bool {
+	l := n.Left
+	tm := templateOf(l)
+	var targs []*types.Type
+	if tm == nil && l.Op == OINDEX {
+		tm = templateOf(l.Left)
+		if tm == nil || tm.fun == nil {
+			return true
+		}
+		if l.List.Len() >= len(tm.fun.TParamList) {
+			// not partial: typecheck instantiates it
+			return true
+		}
+		if targs = typeargs(l); targs == nil {
+			return false
+		}
+	}
+	if tm == nil || tm.fun == nil {
+		return true
+	}
+
+	typecheckslice(n.List.Slice(), Erv)
+	var args []*Node
+	var argtypes []*types.Type
+	for _, arg := range n.List.Slice() {
+		if arg.Type == nil {
+			return false
+		}
+		if arg.Type.IsFuncArgStruct() && n.List.Len() == 1 {
+			for _, f := range arg.Type.FieldSlice() {
+				args = append(args, arg)
+				argtypes = append(argtypes, f.Type)
+			}
+			break
+		}
+		args = append(args, arg)
+		argtypes = append(argtypes, arg.Type)
+	}
+
+	u := unifier{tm: tm, targs: make(map[string]*types.Type)}
+	for i, f := range tm.fun.TParamList {
+		if i < len(targs) {
+			u.targs[f.Name.Value] = targs[i]
+		} else {
+			u.tparams = append(u.tparams, f.Name.Value)
+		}
+	}
+
+	// Match the parameters with the typed arguments, then the
+	// parameters of type parameter type with the untyped ones.
+	params := tm.fun.Type.ParamList
+	var untyped []int
+	for i, t := range argtypes {
+		var x syntax.Expr
+		switch {
+		case i < len(params):
+			x = params[i].Type
+		case len(params) > 0:
+			x = params[len(params)-1].Type
+		}
+		if d, ok := x.(*syntax.DotsType); ok {
+			if n.Isddd() {
+				x = &syntax.SliceType{Elem: d.Elem}
+			} else {
+				x = d.Elem
+			}
+		}
+		if x == nil {
+			break
+		}
+		if t.IsUntyped() {
+			untyped = append(untyped, i)
+			continue
+		}
+		if !u.unify(x, t) {
+			yyerror("type %v of %v does not match %s", t, args[i], u.mismatch)
+			return false
+		}
+	}
+	kinds := make(map[string]Ctype)
+	for _, i := range untyped {
+		x := params[len(params)-1].Type
+		if i < len(params) {
+			x = params[i].Type
+		}
+		if d, ok := x.(*syntax.DotsType); ok {
+			x = d.Elem
+		}
+		if name, ok := unparenExpr(x).(*syntax.Name); ok && u.isTypeParam(name.Value) && u.targs[name.Value] == nil {
+			k0, k := kinds[name.Value], idealkind(args[i])
+			if k == CTNIL {
+				continue
+			}
+			if k0 != CTxxx && k0 != k && (!isNumericKind(k0) || !isNumericKind(k)) {
+				yyerror("mismatched types untyped %v and untyped %v for %s", defaultType(k0), defaultType(k), name.Value)
+				return false
+			}
+			if k > k0 {
+				kinds[name.Value] = k
+			}
+		}
+	}
+	for name, k := range kinds {
+		if t := defaultType(k); t != nil {
+			u.targs[name] = t
+		}
+	}
+
+	targs = targs[:0:0]
+	for _, f := range tm.fun.TParamList {
+		t := u.targs[f.Name.Value]
+		if t == nil {
+			yyerror("cannot infer %s (declared at %v)", f.Name.Value, linestr(tm.name.Pos))
+			return false
+		}
+		targs = append(targs, t)
+	}
+	inst := instantiate(tm.name, targs)
+	if inst == nil {
+		return false
+	}
+	n.Left = inst
+	return true
+}
+
+// unparenExpr returns x without enclosing parentheses.
+func unparenExpr(x syntax.Expr) syntax.Expr {
+	for {
+		p, ok := x.(*syntax.ParenExpr)
+		if !ok {
+			return x
+		}
+		x = p.X
+	}
+}
+
+func isNumericKind(k Ctype) bool {
+	return CTINT <= k && k <= CTCPLX
+}
+
+// defaultType returns the default type of untyped constants of kind k.
+func defaultType(k Ctype) *types.Type {
+	switch k {
+	case CTBOOL:
+		return types.Types[TBOOL]
+	case CTSTR:
+		return types.Types[TSTRING]
+	case CTINT:
+		return types.Types[TINT]
+	case CTRUNE:
+		return types.Runetype
+	case CTFLT:
+		return types.Types[TFLOAT64]
+	case CTCPLX:
+		return types.Types[TCOMPLEX128]
+	}
+	return nil
+}
+
+// A unifier infers type arguments by matching the parameter types of
+// a generic function, as written in its declaration, with the types
+// of the arguments.
+type unifier struct {
+	tm       *template
+	tparams  []string               // type parameters to infer
+	targs    map[string]*types.Type // inferred and explicit type arguments
+	mismatch string                 // reason of the last failure
+}
+
+func (u *unifier) isTypeParam(name string) bool {
+	for _, f := range u.tm.fun.TParamList {
+		if f.Name.Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+// unify matches the type expression x with t. It reports whether
+// they match, binding type parameters in x as needed. Parts of x not
+// involving type parameters are checked when the instance is called.
+func (u *unifier) unify(x syntax.Expr, t *types.Type) bool {
+	switch x := unparenExpr(x).(type) {
+	case *syntax.Name:
+		if !u.isTypeParam(x.Value) {
+			return true
+		}
+		if t0 := u.targs[x.Value]; t0 != nil {
+			if !eqtype(t0, t) {
+				u.mismatch = fmt.Sprintf("inferred type %v for %s", t0, x.Value)
+				return false
+			}
+			return true
+		}
+		u.targs[x.Value] = t
+		return true
+
+	case *syntax.Operation:
+		if x.Op == syntax.Mul && x.Y == nil && t.IsPtr() {
+			return u.unify(x.X, t.Elem())
+		}
+
+	case *syntax.SliceType:
+		if t.IsSlice() {
+			return u.unify(x.Elem, t.Elem())
+		}
+
+	case *syntax.ArrayType:
+		if t.IsArray() {
+			return u.unify(x.Elem, t.Elem())
+		}
+
+	case *syntax.MapType:
+		if t.IsMap() {
+			return u.unify(x.Key, t.Key()) && u.unify(x.Value, t.Val())
+		}
+
+	case *syntax.ChanType:
+		if t.IsChan() {
+			return u.unify(x.Elem, t.Elem())
+		}
+
+	case *syntax.FuncType:
+		if t.Etype == TFUNC && len(x.ParamList) == t.NumParams() && len(x.ResultList) == t.NumResults() {
+			for i, f := range x.ParamList {
+				if !u.unify(f.Type, t.Params().Field(i).Type) {
+					return false
+				}
+			}
+			for i, f := range x.ResultList {
+				if !u.unify(f.Type, t.Results().Field(i).Type) {
+					return false
+				}
+			}
+			return true
+		}
+
+	case *syntax.IndexExpr:
+		inst := instances[t]
+		name, ok := unparenExpr(x.X).(*syntax.Name)
+		if inst == nil || !ok || u.tm.env.pkg.Lookup(name.Value) != inst.base {
+			break
+		}
+		list := []syntax.Expr{x.Index}
+		if l, ok := x.Index.(*syntax.ListExpr); ok {
+			list = l.ElemList
+		}
+		if len(list) != len(inst.targs) {
+			break
+		}
+		for i, x := range list {
+			if !u.unify(x, inst.targs[i]) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return true
+	}
+
+	// t does not have the structure of x, which can only match
+	// if x does not involve type parameters.
+	mentions := false
+	syntax.Inspect(x, func(n syntax.Node) bool {
+		if n, ok := n.(*syntax.Name); ok && u.isTypeParam(n.Value) {
+			mentions = true
+		}
+		return !mentions
+	})
+	if mentions {
+		u.mismatch = syntax.String(x)
+		return false
+	}
+	return true
+}
+
+// unit returns the declaration of tm, for i == 0, or of its i'th
+// method, with its environment.
+func (tm *template) unit(i int) (syntax.Decl, *templateEnv) {
+	switch {
+	case i > 0:
+		return tm.methods[i-1].decl, tm.methods[i-1].env
+	case tm.fun != nil:
+		return tm.fun, tm.env
+	}
+	return tm.typ, tm.env
+}
+
+// source returns the source text of the declaration decl of a
+// template, for export. A line directive preserves its position.
+func source(decl syntax.Decl) string {
+	var buf bytes.Buffer
+	pos := decl.Pos()
+	fmt.Fprintf(&buf, "//line %s:%d\n", pos.RelFilename(), pos.RelLine())
+	if _, err := syntax.Fprint(&buf, decl, true); err != nil {
+		Fatalf("exporter: cannot print generic declaration: %v", err)
+	}
+	return buf.String()
+}
+
+// reexportdeps adds the package-level objects that the declarations
+// of tm refer to, and that importers instantiating tm need, to the
+// export list.
+func (tm *template) reexportdeps() {
+	add := func(s *types.Sym) {
+		n := asNode(s.Def)
+		if n == nil || n == tm.name || n.Sym == nil || n.Sym.Pkg == builtinpkg || exportedsym(s) {
+			return
+		}
+		switch n.Op {
+		case ONAME:
+			if n.Class() != PEXTERN && n.Class() != PFUNC {
+				return
+			}
+		case OTYPE, OLITERAL:
+		default:
+			return
+		}
+		if n.Type == nil && templates[n] == nil {
+			return
+		}
+		exportlist = append(exportlist, n)
+	}
+
+	for i := 0; i <= len(tm.methods); i++ {
+		decl, env := tm.unit(i)
+		syntax.Inspect(decl, func(n syntax.Node) bool {
+			switch n := n.(type) {
+			case *syntax.SelectorExpr:
+				if x, ok := n.X.(*syntax.Name); ok {
+					for _, pack := range env.imports {
+						if pack.Sym.Name == x.Value {
+							add(pack.Name.Pkg.Lookup(n.Sel.Value))
+							return false
+						}
+					}
+				}
+				syntax.Inspect(n.X, func(n syntax.Node) bool {
+					if n, ok := n.(*syntax.Name); ok {
+						add(env.pkg.Lookup(n.Value))
+					}
+					return true
+				})
+				return false
+			case *syntax.Name:
+				s := env.pkg.Lookup(n.Value)
+				if s.Def == nil && exportname(n.Value) {
+					// imported with import .
+					for _, pack := range env.imports {
+						if pack.Sym.Name == "." {
+							add(pack.Name.Pkg.Lookup(n.Value))
+						}
+					}
+				}
+				add(s)
+			}
+			return true
+		})
+	}
+}
+
+// importgeneric declares the imported generic function or type sym,
+// given the source text of its declaration and of its methods, and
+// the imports of their files.
+func importgeneric(pos src.XPos, pkg *types.Pkg, sym *types.Sym, envs []*templateEnv, sources []string) {
+	if asNode(sym.Def) != nil {
+		// imported before, via another import
+		return
+	}
+
+	var decls []syntax.Decl
+	for _, source := range sources {
+		filename := pkg.Path + ".go"
+		base := src.NewFileBase(filename, filename)
+		file, err := syntax.Parse(base, strings.NewReader("package "+pkg.Name+"\n"+source), nil, nil, nil, 0)
+		if err != nil || len(file.DeclList) != 1 {
+			Fatalf("importer: invalid generic declaration %v: %v", sym, err)
+		}
+		decls = append(decls, file.DeclList[0])
+	}
+
+	tm := &template{env: envs[0], insts: make(map[string]*Node)}
+	switch decl := decls[0].(type) {
+	case *syntax.TypeDecl:
+		importsym(pkg, sym, OTYPE)
+		tm.name = newnamel(pos, sym)
+		tm.name.Op = OTYPE
+		tm.typ = decl
+	case *syntax.FuncDecl:
+		importsym(pkg, sym, ONAME)
+		tm.name = newfuncnamel(pos, sym)
+		tm.name.SetClass(PFUNC)
+		tm.fun = decl
+	default:
+		Fatalf("importer: invalid generic declaration %v", sym)
+	}
+	sym.Def = asTypesNode(tm.name)
+	templates[tm.name] = tm
+
+	for i, decl := range decls[1:] {
+		genericMethod{decl.(*syntax.FuncDecl), envs[i+1]}.attach()
+	}
+}
@@ -25,6 +25,19 @@ func isRuntimePkg(p *types.Pkg) bool {
 	return p.Path == "runtime"
 }
 
+// isAtomicAlign64 reports whether t is sync/atomic.align64,
+// a zero-size type that forces 64-bit alignment of its containing struct.
+func isAtomicAlign64(t *types.Type) bool {
+	if t.Sym == nil || t.Sym.Name != "align64" {
+		return false
+	}
+	p := t.Sym.Pkg
+	if p == localpkg {
+		return myimportpath == "sync/atomic"
+	}
+	return p.Path == "sync/atomic"
+}
+
 // The Class of a variable/function describes the "storage class"
 // of a variable or function. During parsing, storage classes are
 // called declaration contexts.
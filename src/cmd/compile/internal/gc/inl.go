@@ -18,6 +18,10 @@
 //
 // At some point this may get another default and become switch-offable with -N.
 //
+// With a profile (see pgo.go), the functions called from hot call sites
+// get a larger budget, and are inlined over the normal one at the hot
+// call sites only.
+//
 // The -d typcheckinl flag enables early typechecking of all imported bodies,
 // which is useful to flush out bugs.
 //
@@ -169,14 +173,19 @@ func caninl(fn *Node) {
 	}
 	defer n.Func.SetInlinabilityChecked(true)
 
-	const maxBudget = 80
-	visitor := hairyVisitor{budget: maxBudget}
+	budget := int32(inlineMaxBudget)
+	if pgoProfile != nil && Debug_pgoinline != 0 && pgoProfile.IsHotCallee(pgoFuncName(n)) {
+		// mkinlcall inlines functions over the normal budget at
+		// hot call sites only.
+		budget = int32(Debug_pgoinlinebudget)
+	}
+	visitor := hairyVisitor{budget: budget}
 	if visitor.visitList(fn.Nbody) {
 		reason = visitor.reason
 		return
 	}
 	if visitor.budget < 0 {
-		reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", maxBudget-visitor.budget, maxBudget)
+		reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", budget-visitor.budget, budget)
 		return
 	}
 
@@ -187,7 +196,7 @@ func caninl(fn *Node) {
 	fn.Nbody.Set(inlcopylist(n.Func.Inl.Slice()))
 	inldcl := inlcopylist(n.Name.Defn.Func.Dcl)
 	n.Func.Inldcl.Set(inldcl)
-	n.Func.InlCost = maxBudget - visitor.budget
+	n.Func.InlCost = budget - visitor.budget
 
 	// hack, TODO, check for better way to link method nodes back to the thing with the ->inl
 	// this is so export can find the body of a method
@@ -569,7 +578,7 @@ func inlnode(n *Node) *Node {
 	// transmogrify this node itself unless inhibited by the
 	// switch at the top of this function.
 	switch n.Op {
-	case OCALLFUNC, OCALLMETH:
+	case OCALLFUNC, OCALLMETH, OCALLINTER:
 		if n.NoInline() {
 			return n
 		}
@@ -632,6 +641,11 @@ func inlnode(n *Node) *Node {
 		}
 
 		n = mkinlcall(n, asNode(n.Left.Type.FuncType().Nname), n.Isddd())
+
+	case OCALLINTER:
+		if pgoProfile != nil && Debug_pgodevirtualize != 0 {
+			n = pgoDevirtualize(n)
+		}
 	}
 
 	lineno = lno
@@ -779,6 +793,15 @@ func mkinlcall1(n, fn *Node, isddd bool) *Node {
 		return n
 	}
 
+	if fn.Func.InlCost > inlineMaxBudget && !pgoHotCallSite(n, fn) {
+		// Only hot functions are over the budget, and they are
+		// inlined at hot call sites only.
+		if Debug['m'] > 1 {
+			fmt.Printf("%v: cannot inline call to %v: cost %d exceeds budget %d at cold call site\n", n.Line(), fn, fn.Func.InlCost, inlineMaxBudget)
+		}
+		return n
+	}
+
 	if Debug_typecheckinl == 0 {
 		typecheckinl(fn)
 	}
@@ -44,7 +44,6 @@ func TestIntendedInlining(t *testing.T) {
 			"chanbuf",
 			"deferArgs",
 			"deferclass",
-			"evacuated",
 			"fastlog2",
 			"fastrand",
 			"float64bits",
@@ -52,6 +51,8 @@ func TestIntendedInlining(t *testing.T) {
 			"getm",
 			"isDirectIface",
 			"itabHashFunc",
+			"makeProbeSeq",
+			"maxLoad",
 			"maxSliceCap",
 			"noescape",
 			"readUnaligned32",
@@ -65,8 +66,16 @@ func TestIntendedInlining(t *testing.T) {
 			"subtractb",
 			"tophash",
 			"totaldefersize",
-			"(*bmap).keys",
-			"(*bmap).overflow",
+			"(*bmap).ctrlAt",
+			"(*bmap).match",
+			"(*bmap).matchEmpty",
+			"(*bmap).matchFree",
+			"(*bmap).matchFull",
+			"(*bmap).setCtrl",
+			"bitset.first",
+			"bitset.removeFirst",
+			"probeSeq.last",
+			"probeSeq.next",
 			"(*waitq).enqueue",
 
 			// GC-related ones
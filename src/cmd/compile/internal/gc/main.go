@@ -40,6 +40,7 @@ var (
 	Debug_closure      int
 	Debug_compilelater int
 	debug_dclstack     int
+	Debug_defer        int
 	Debug_panic        int
 	Debug_slice        int
 	Debug_vlog         bool
@@ -67,6 +68,7 @@ var debugtab = []struct {
 	{"compilelater", "compile functions as late as possible", &Debug_compilelater},
 	{"disablenil", "disable nil checks", &disable_checknil},
 	{"dclstack", "run internal dclstack check", &debug_dclstack},
+	{"defer", "print information about defer compilation", &Debug_defer},
 	{"gcprog", "print dump of GC programs", &Debug_gcprog},
 	{"nil", "print information about nil checks", &Debug_checknil},
 	{"panic", "do not hide any compiler panic", &Debug_panic},
@@ -80,6 +82,11 @@ var debugtab = []struct {
 	{"typecheckinl", "eager typechecking of inline function bodies", &Debug_typecheckinl},
 	{"dwarfinl", "print information about DWARF inlined function creation", &Debug_gendwarfinl},
 	{"softfloat", "force compiler to emit soft-float code", &Debug_softfloat},
+	{"pgoinline", "enable profile-guided inlining", &Debug_pgoinline},
+	{"pgoinlinebudget", "set inlining budget for hot functions", &Debug_pgoinlinebudget},
+	{"pgoinlinecdfthreshold", "set percentage of call weight making up the hot call sites", &Debug_pgoinlinecdfthreshold},
+	{"pgodevirtualize", "enable profile-guided devirtualization of interface calls", &Debug_pgodevirtualize},
+	{"pgolayout", "enable profile-guided branch layout", &Debug_pgolayout},
 }
 
 const debugHelpHeader = `usage: -d arg[,arg]* and arg is <key>[=<value>]
@@ -214,6 +221,7 @@ func Main(archInit func(*Arch)) {
 	flag.StringVar(&outfile, "o", "", "write output to `file`")
 	flag.StringVar(&myimportpath, "p", "", "set expected package import `path`")
 	flag.BoolVar(&writearchive, "pack", false, "write package file instead of object file")
+	flag.StringVar(&pgoprofile, "pgoprofile", "", "read CPU profile from `file` for profile-guided optimization")
 	objabi.Flagcount("r", "debug generated wrappers", &Debug['r'])
 	flag.BoolVar(&flag_race, "race", false, "enable race detector")
 	objabi.Flagcount("s", "warn about composite literals that can be simplified", &Debug['s'])
@@ -495,6 +503,14 @@ func Main(archInit func(*Arch)) {
 			xtop[i] = typecheck(n, Etop)
 		}
 	}
+	// Variables declared without value have no assignment; type check
+	// them now, so that the methods of the generic type instances they
+	// refer to are compiled with the package.
+	for i, n := range externdcl {
+		if n.Op == ONAME && n.Class() == PEXTERN {
+			externdcl[i] = typecheck(n, Erv)
+		}
+	}
 	resumecheckwidth()
 
 	// Phase 3: Type check function bodies.
@@ -541,6 +557,10 @@ func Main(archInit func(*Arch)) {
 	}
 
 	// Phase 5: Inlining
+	if pgoprofile != "" {
+		timings.Start("fe", "pgo")
+		pgoInit()
+	}
 	timings.Start("fe", "inlining")
 	if Debug_typecheckinl != 0 {
 		// Typecheck imported function bodies if debug['l'] > 1,
@@ -62,6 +62,8 @@ func parseFiles(filenames []string) uint {
 		testdclstack()
 	}
 
+	attachGenericMethods()
+
 	return lines
 }
 
@@ -86,6 +88,17 @@ type noder struct {
 	pragcgobuf string
 	err        chan syntax.Error
 	scope      ScopeID
+	imports    []*Node // OPACK nodes of the file's imports
+
+	// env is the environment of the generic declarations of
+	// the file, or of the template being instantiated.
+	env *templateEnv
+
+	// pkg is the package of the template being instantiated;
+	// its identifiers are looked up in pkg rather than localpkg.
+	// inst is the name of the function instance being noded.
+	pkg  *types.Pkg
+	inst *types.Sym
 }
 
 func (p *noder) funchdr(n *Node) ScopeID {
@@ -158,6 +171,9 @@ func (p *noder) node() {
 	mkpackage(p.file.PkgName.Value)
 
 	xtop = append(xtop, p.decls(p.file.DeclList)...)
+	if p.env != nil {
+		p.env.markImportsUsed()
+	}
 
 	for _, n := range p.linknames {
 		if imported_unsafe {
@@ -188,10 +204,14 @@ func (p *noder) decls(decls []syntax.Decl) (l []*Node) {
 			l = append(l, p.constDecl(decl, &cs)...)
 
 		case *syntax.TypeDecl:
-			l = append(l, p.typeDecl(decl))
+			if n := p.typeDecl(decl); n != nil {
+				l = append(l, n)
+			}
 
 		case *syntax.FuncDecl:
-			l = append(l, p.funcDecl(decl))
+			if n := p.funcDecl(decl); n != nil {
+				l = append(l, n)
+			}
 
 		default:
 			panic("unhandled Decl")
@@ -228,6 +248,7 @@ func (p *noder) importDecl(imp *syntax.ImportDecl) {
 	switch my.Name {
 	case ".":
 		importdot(ipkg, pack)
+		p.imports = append(p.imports, pack)
 		return
 	case "init":
 		yyerrorl(pack.Pos, "cannot import package as init - init must be a func")
@@ -242,6 +263,7 @@ func (p *noder) importDecl(imp *syntax.ImportDecl) {
 	my.Def = asTypesNode(pack)
 	my.Lastlineno = pack.Pos
 	my.Block = 1 // at top level
+	p.imports = append(p.imports, pack)
 }
 
 func (p *noder) varDecl(decl *syntax.VarDecl) []*Node {
@@ -323,6 +345,11 @@ func (p *noder) typeDecl(decl *syntax.TypeDecl) *Node {
 	n.Op = OTYPE
 	declare(n, dclcontext)
 
+	if decl.TParamList != nil {
+		p.genericDecl(n, decl)
+		return nil
+	}
+
 	// decl.Type may be nil but in that case we got a syntax error during parsing
 	typ := p.typeExprOrNil(decl.Type)
 
@@ -353,7 +380,15 @@ func (p *noder) declName(name *syntax.Name) *Node {
 }
 
 func (p *noder) funcDecl(fun *syntax.FuncDecl) *Node {
+	if p.pkg == nil && (fun.TParamList != nil || isGenericRecv(fun.Recv)) {
+		p.genericFuncDecl(fun)
+		return nil
+	}
+
 	name := p.name(fun.Name)
+	if p.inst != nil {
+		name = p.inst
+	}
 	t := p.signature(fun.Recv, fun.Type)
 	f := p.nod(fun, ODCLFUNC, nil, nil)
 
@@ -371,7 +406,7 @@ func (p *noder) funcDecl(fun *syntax.FuncDecl) *Node {
 			}
 		}
 	} else {
-		f.Func.Shortname = name
+		f.Func.Shortname = p.fieldSym(fun.Name.Value)
 		name = nblank.Sym // filled in by typecheckfunc
 	}
 
@@ -512,8 +547,15 @@ func (p *noder) expr(expr syntax.Expr) *Node {
 			obj.Name.SetUsed(true)
 			return oldname(restrictlookup(expr.Sel.Value, obj.Name.Pkg))
 		}
-		return p.setlineno(expr, nodSym(OXDOT, obj, p.name(expr.Sel)))
+		return p.setlineno(expr, nodSym(OXDOT, obj, p.fieldSym(expr.Sel.Value)))
 	case *syntax.IndexExpr:
+		// An index with several type arguments is represented
+		// as an OINDEX whose Right is nil and List holds them.
+		if list, ok := expr.Index.(*syntax.ListExpr); ok {
+			n := p.nod(expr, OINDEX, p.expr(expr.X), nil)
+			n.List.Set(p.exprs(list.ElemList))
+			return n
+		}
 		return p.nod(expr, OINDEX, p.expr(expr.X), p.expr(expr.Index))
 	case *syntax.SliceExpr:
 		op := OSLICE
@@ -709,7 +751,7 @@ func (p *noder) structType(expr *syntax.StructType) *Node {
 		if field.Name == nil {
 			n = p.embedded(field.Type)
 		} else {
-			n = p.nod(field, ODCLFIELD, p.newname(field.Name), p.typeExpr(field.Type))
+			n = p.nod(field, ODCLFIELD, newname(p.fieldSym(field.Name.Value)), p.typeExpr(field.Type))
 		}
 		if i < len(expr.TagList) && expr.TagList[i] != nil {
 			n.SetVal(p.basicLit(expr.TagList[i]))
@@ -729,9 +771,9 @@ func (p *noder) interfaceType(expr *syntax.InterfaceType) *Node {
 		p.lineno(method)
 		var n *Node
 		if method.Name == nil {
-			n = p.nod(method, ODCLFIELD, nil, oldname(p.packname(method.Type)))
+			n = p.embeddedElem(method)
 		} else {
-			mname := p.newname(method.Name)
+			mname := newname(p.fieldSym(method.Name.Value))
 			sig := p.typeExpr(method.Type)
 			sig.Left = fakeRecv()
 			n = p.nod(method, ODCLFIELD, mname, sig)
@@ -745,10 +787,44 @@ func (p *noder) interfaceType(expr *syntax.InterfaceType) *Node {
 	return n
 }
 
+// embeddedElem returns the ODCLFIELD for the embedded element of an
+// interface: an interface type name, or the terms of a union. The
+// terms of a union are held by List, those of the form ~T by Rlist.
+func (p *noder) embeddedElem(elem *syntax.Field) *Node {
+	switch typ := elem.Type.(type) {
+	case *syntax.Name, *syntax.SelectorExpr:
+		return p.nod(elem, ODCLFIELD, nil, oldname(p.packname(typ)))
+	case *syntax.IndexExpr:
+		return p.nod(elem, ODCLFIELD, nil, p.typeExpr(typ))
+	}
+
+	n := p.nod(elem, ODCLFIELD, nil, nil)
+	var terms func(x syntax.Expr)
+	terms = func(x syntax.Expr) {
+		if x, ok := x.(*syntax.Operation); ok {
+			switch {
+			case x.Op == syntax.Or && x.Y != nil:
+				terms(x.X)
+				terms(x.Y)
+				return
+			case x.Op == syntax.Tilde:
+				n.Rlist.Append(p.typeExpr(x.X))
+				return
+			}
+		}
+		n.List.Append(p.typeExpr(x))
+	}
+	terms(elem.Type)
+	return n
+}
+
 func (p *noder) packname(expr syntax.Expr) *types.Sym {
 	switch expr := expr.(type) {
 	case *syntax.Name:
 		name := p.name(expr)
+		if name.Def == nil && p.env != nil && p.pkg != nil {
+			name = p.env.resolve(name)
+		}
 		if n := oldname(name); n.Name != nil && n.Name.Pack != nil {
 			n.Name.Pack.Name.SetUsed(true)
 		}
@@ -777,8 +853,19 @@ func (p *noder) embedded(typ syntax.Expr) *Node {
 		typ = op.X
 	}
 
+	if x, ok := typ.(*syntax.IndexExpr); ok {
+		// embedded instance of a generic type
+		sym := p.packname(x.X)
+		n := nod(ODCLFIELD, newname(p.fieldSym(sym.Name)), p.typeExpr(typ))
+		n.SetEmbedded(true)
+		if isStar {
+			n.Right = p.nod(op, OIND, n.Right, nil)
+		}
+		return n
+	}
+
 	sym := p.packname(typ)
-	n := nod(ODCLFIELD, newname(lookup(sym.Name)), oldname(sym))
+	n := nod(ODCLFIELD, newname(p.fieldSym(sym.Name)), oldname(sym))
 	n.SetEmbedded(true)
 
 	if isStar {
@@ -1259,12 +1346,30 @@ func (p *noder) basicLit(lit *syntax.BasicLit) Val {
 }
 
 func (p *noder) name(name *syntax.Name) *types.Sym {
+	if p.pkg != nil {
+		return p.pkg.Lookup(name.Value)
+	}
 	return lookup(name.Value)
 }
 
+// fieldSym returns the symbol for the field or method name, or the
+// selector, name. Like imported ones, fields and methods of generic
+// declarations of other packages use local symbols for exported
+// names.
+func (p *noder) fieldSym(name string) *types.Sym {
+	if p.pkg != nil && !exportname(name) {
+		return p.pkg.Lookup(name)
+	}
+	return lookup(name)
+}
+
 func (p *noder) mkname(name *syntax.Name) *Node {
 	// TODO(mdempsky): Set line number?
-	return mkname(p.name(name))
+	sym := p.name(name)
+	if sym.Def == nil && p.env != nil && p.pkg != nil {
+		sym = p.env.resolve(sym)
+	}
+	return mkname(sym)
 }
 
 func (p *noder) newname(name *syntax.Name) *Node {
@@ -0,0 +1,310 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gc
+
+import (
+	"cmd/compile/internal/pgo"
+	"cmd/compile/internal/types"
+	"cmd/internal/objabi"
+	"cmd/internal/src"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Profile-guided optimization.
+//
+// Given a CPU profile of the program with -pgoprofile, the compiler
+//   - gives the functions called from hot call sites a larger inlining
+//     budget, and inlines them over the normal budget at the hot call
+//     sites only;
+//   - devirtualizes the hot interface method calls that mostly call
+//     the method of one concrete type, which can then be inlined;
+//   - marks the branches of hot functions that the profile shows are
+//     taken much more often than the other, for the block layout.
+
+var (
+	pgoprofile string       // -pgoprofile flag
+	pgoProfile *pgo.Profile // the profile read from pgoprofile, if any
+
+	Debug_pgoinline             = 1
+	Debug_pgoinlinebudget       = 2000
+	Debug_pgoinlinecdfthreshold = 99
+	Debug_pgodevirtualize       = 1
+	Debug_pgolayout             = 1
+)
+
+// inlineMaxBudget is the inlining budget of the functions that are
+// not hot.
+const inlineMaxBudget = 80
+
+// pgoInit reads the profile and marks the likely branches of the hot
+// functions in xtop. It must run before inlining, while the function
+// bodies hold only their own lines.
+func pgoInit() {
+	var err error
+	pgoProfile, err = pgo.New(pgoprofile, Debug_pgoinlinecdfthreshold)
+	if err != nil {
+		log.Fatalf("-pgoprofile: %v", err)
+	}
+	if Debug_pgolayout == 0 {
+		return
+	}
+	for _, n := range xtop {
+		if n.Op == ODCLFUNC {
+			pgoMarkBranches(n)
+		}
+	}
+}
+
+// pgoFuncName returns the symbol name of the function fn, an ONAME,
+// as the profile records it.
+func pgoFuncName(fn *Node) string {
+	if fn.Sym.Linkname != "" {
+		return fn.Sym.Linkname
+	}
+	prefix := objabi.PathToPrefix(myimportpath)
+	if pkg := fnpkg(fn); pkg != localpkg && pkg != nil {
+		prefix = pkg.Prefix
+	}
+	return prefix + "." + fn.Sym.Name
+}
+
+// pgoLine returns the line of pos as the profile records it.
+func pgoLine(pos src.XPos) int {
+	return int(Ctxt.PosTable.Pos(pos).RelLine())
+}
+
+// pgoHotCallSite reports whether the call n from Curfn to fn is hot,
+// so that fn may be inlined over the normal budget.
+func pgoHotCallSite(n, fn *Node) bool {
+	if pgoProfile == nil || Debug_pgoinline == 0 || Curfn == nil {
+		return false
+	}
+	// Large frames would overflow the stack of nosplit functions.
+	if Curfn.Func.Pragma&Nosplit != 0 {
+		return false
+	}
+	return pgoProfile.IsHotCallSite(pgoFuncName(Curfn.Func.Nname), pgoLine(n.Pos), pgoFuncName(fn))
+}
+
+// pgoDevirtualize rewrites the interface method call n, if the
+// profile shows that it is hot and mostly calls the method of one
+// concrete type T, into the equivalent of
+//
+//	if c, ok := x.(T); ok {
+//		c.M(args)
+//	} else {
+//		x.M(args)
+//	}
+//
+// in which the call to c.M can be inlined. It returns an OINLCALL, or
+// n if it leaves n alone.
+func pgoDevirtualize(n *Node) *Node {
+	sel := n.Left
+	if sel.Op != ODOTINTER || Curfn == nil {
+		return n
+	}
+	if n.List.Len() == 1 && n.List.First().Type.IsFuncArgStruct() {
+		// f(g()) with multiple results.
+		return n
+	}
+	var typ *types.Type
+	for _, callee := range pgoProfile.HotCallees(pgoFuncName(Curfn.Func.Nname), pgoLine(n.Pos)) {
+		if typ = pgoMethodRecv(callee, sel); typ != nil {
+			break
+		}
+	}
+	if typ == nil {
+		return n
+	}
+	if Debug['m'] != 0 {
+		Warnl(n.Pos, "PGO devirtualizing %v to %v", sel, typ)
+	}
+
+	// Evaluate the receiver and the arguments once.
+	init := n.Ninit.Slice()
+	n.Ninit.Set(nil)
+	recv := temp(sel.Left.Type)
+	init = append(init, typecheck(nod(OAS, recv, sel.Left), Etop))
+	sel.Left = recv
+	args := make([]*Node, n.List.Len())
+	for i, a := range n.List.Slice() {
+		args[i] = temp(a.Type)
+		init = append(init, typecheck(nod(OAS, args[i], a), Etop))
+	}
+	n.List.Set(args)
+
+	c := temp(typ)
+	ok := temp(types.Types[TBOOL])
+	dt := nod(ODOTTYPE, recv, nil)
+	dt.Type = typ
+	as := nod(OAS2, nil, nil)
+	as.List.Set2(c, ok)
+	as.Rlist.Set1(dt)
+	init = append(init, typecheck(as, Etop))
+
+	call := nod(OCALL, nodSym(OXDOT, c, sel.Sym), nil)
+	call.List.Set(append([]*Node(nil), args...))
+	call.SetIsddd(n.Isddd())
+
+	var results []*Node
+	for _, f := range sel.Type.Results().Fields().Slice() {
+		results = append(results, temp(f.Type))
+	}
+	nif := nod(OIF, ok, nil)
+	nif.Nbody.Set1(pgoAssignResults(results, call))
+	nif.Rlist.Set1(pgoAssignResults(results, n))
+	nif = typecheck(nif, Etop)
+
+	// Inline the direct call, but do not devirtualize the
+	// interface call again.
+	n.SetNoInline(true)
+	nif = inlnode(nif)
+
+	inl := nod(OINLCALL, nil, nil)
+	inl.Ninit.Set(init)
+	inl.Nbody.Set1(nif)
+	inl.Rlist.Set(append([]*Node(nil), results...))
+	inl.Type = n.Type
+	inl.SetTypecheck(1)
+	return inl
+}
+
+// pgoAssignResults returns a statement assigning the results of call
+// to results.
+func pgoAssignResults(results []*Node, call *Node) *Node {
+	switch len(results) {
+	case 0:
+		return call
+	case 1:
+		return nod(OAS, results[0], call)
+	}
+	as := nod(OAS2, nil, nil)
+	as.List.Set(append([]*Node(nil), results...))
+	as.Rlist.Set1(call)
+	return as
+}
+
+// pgoMethodRecv returns the receiver type of the method callee, given
+// by its symbol name, if it is the method called through the interface
+// by sel, an ODOTINTER, and the type is the local package's or one of
+// a directly imported package. Otherwise it returns nil.
+func pgoMethodRecv(callee string, sel *Node) *types.Type {
+	// callee is pkg.T.M or pkg.(*T).M, and pkg may contain dots
+	// before its last slash.
+	i := strings.LastIndex(callee, ".")
+	if i < 0 || callee[i+1:] != sel.Sym.Name {
+		return nil
+	}
+	recv := callee[:i]
+	i = strings.LastIndex(recv, "/") + 1
+	j := strings.Index(recv[i:], ".")
+	if j < 0 {
+		return nil
+	}
+	prefix, name := recv[:i+j], recv[i+j+1:]
+	ptr := strings.HasPrefix(name, "(*") && strings.HasSuffix(name, ")")
+	if ptr {
+		name = name[2 : len(name)-1]
+	}
+
+	var pkg *types.Pkg
+	if prefix == objabi.PathToPrefix(myimportpath) {
+		pkg = localpkg
+	} else {
+		for _, p := range types.ImportedPkgList() {
+			if p.Prefix == prefix {
+				pkg = p
+				break
+			}
+		}
+	}
+	if pkg == nil {
+		return nil
+	}
+	s, ok := pkg.LookupOK(name)
+	if !ok {
+		return nil
+	}
+	d := asNode(s.Def)
+	if d == nil || d.Op != OTYPE || d.Type == nil || d.Type.IsInterface() {
+		return nil
+	}
+	t := d.Type
+	if ptr {
+		t = types.NewPtr(t)
+	}
+	var missing, have *types.Field
+	var ptrRecv int
+	if !implements(t, sel.Left.Type, &missing, &have, &ptrRecv) {
+		return nil
+	}
+	return t
+}
+
+// pgoMarkBranches marks the if statements of fn, if it is hot, of
+// which the profile shows one branch to be much hotter than the other.
+// The branch that runs when the condition is false is the else branch
+// or, if there is none, the statements that follow, if any.
+func pgoMarkBranches(fn *Node) {
+	name := pgoFuncName(fn.Func.Nname)
+	if !pgoProfile.IsHotFunc(name) {
+		return
+	}
+	var markList func(l Nodes)
+	mark := func(n *Node) {
+		if n == nil || n.Op == OCLOSURE {
+			return
+		}
+		markList(n.Ninit)
+		markList(n.List)
+		markList(n.Rlist)
+		markList(n.Nbody)
+	}
+	markList = func(l Nodes) {
+		s := l.Slice()
+		for i, n := range s {
+			if n.Op == OIF && !n.Likely() && !n.Unlikely() && (n.Rlist.Len() != 0 || i+1 < len(s)) {
+				then := pgoLineWeight(name, n.Nbody.Slice())
+				var els int64
+				if n.Rlist.Len() != 0 {
+					els = pgoLineWeight(name, n.Rlist.Slice())
+				} else {
+					els = pgoLineWeight(name, s[i+1:])
+				}
+				switch {
+				case then > 2*els:
+					n.SetLikely(true)
+				case els > 2*then:
+					n.SetUnlikely(true)
+				}
+				if Debug['m'] > 1 && (n.Likely() || n.Unlikely()) {
+					fmt.Printf("%v: PGO branch weights %d, %d; likely %v\n", n.Line(), then, els, n.Likely())
+				}
+			}
+			mark(n)
+		}
+	}
+	markList(fn.Nbody)
+}
+
+// pgoLineWeight returns the largest weight of the lines of the
+// function name covered by the statements l.
+func pgoLineWeight(name string, l []*Node) int64 {
+	var w int64
+	for _, n := range l {
+		inspect(n, func(n *Node) bool {
+			if n.Op == OCLOSURE {
+				return false
+			}
+			if x := pgoProfile.LineWeight[pgo.Line{Func: name, Line: pgoLine(n.Pos)}]; x > w {
+				w = x
+			}
+			return true
+		})
+	}
+	return w
+}
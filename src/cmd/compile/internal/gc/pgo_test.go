@@ -0,0 +1,163 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gc_test
+
+import (
+	"fmt"
+	"internal/testenv"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+const pgoSrc = `package main
+
+type Shape interface {
+	Area() int
+}
+
+type Square struct{ s int }
+
+func (q *Square) Area() int { return q.s * q.s } // AREA
+
+type Circle struct{ r int }
+
+func (c *Circle) Area() int { return 3 * c.r * c.r }
+
+func sum(shapes []Shape) int {
+	t := 0
+	for _, s := range shapes {
+		if s == nil { // IF
+			continue
+		}
+		t += s.Area() // CALLAREA
+	}
+	return t
+}
+
+func big(x int) int {
+%s	return x
+}
+
+func main() {
+	n := big(1) // HOT
+	n += big(2) // COLD
+	println(n, sum([]Shape{&Square{2}, &Circle{1}}))
+}
+`
+
+// pgoLines returns the lines of src marked with comments.
+func pgoLines(src string) map[string]int {
+	lines := make(map[string]int)
+	for i, l := range strings.Split(src, "\n") {
+		if j := strings.Index(l, "// "); j >= 0 {
+			lines[l[j+3:]] = i + 1
+		}
+	}
+	return lines
+}
+
+// writePGOProfile writes a CPU profile of the samples, each a stack of
+// functions and lines, leaf first, to file.
+func writePGOProfile(t *testing.T, file string, samples map[int64][][2]interface{}) {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+	}
+	funcs := make(map[string]*profile.Function)
+	for count, stack := range samples {
+		s := &profile.Sample{Value: []int64{count}}
+		for _, f := range stack {
+			name, line := f[0].(string), f[1].(int)
+			fn := funcs[name]
+			if fn == nil {
+				fn = &profile.Function{ID: uint64(len(funcs) + 1), Name: name}
+				funcs[name] = fn
+				p.Function = append(p.Function, fn)
+			}
+			loc := &profile.Location{
+				ID:   uint64(len(p.Location) + 1),
+				Line: []profile.Line{{Function: fn, Line: int64(line)}},
+			}
+			p.Location = append(p.Location, loc)
+			s.Location = append(s.Location, loc)
+		}
+		p.Sample = append(p.Sample, s)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := p.Write(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPGO(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "pgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// big is over the normal inlining budget.
+	var body strings.Builder
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&body, "\tx = x*%d + %d\n", i+3, i)
+	}
+	src := fmt.Sprintf(pgoSrc, body.String())
+	lines := pgoLines(src)
+	file := filepath.Join(dir, "x.go")
+	if err := ioutil.WriteFile(file, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	prof := filepath.Join(dir, "cpu.pprof")
+	writePGOProfile(t, prof, map[int64][][2]interface{}{
+		100: {{"main.(*Square).Area", lines["AREA"]}, {"main.sum", lines["CALLAREA"]}, {"main.main", lines["HOT"] + 2}},
+		99:  {{"main.big", lines["HOT"] - 3}, {"main.main", lines["HOT"]}},
+		1:   {{"main.big", lines["HOT"] - 3}, {"main.main", lines["COLD"]}},
+	})
+
+	compile := func(args ...string) string {
+		args = append([]string{"tool", "compile", "-p", "main", "-o", filepath.Join(dir, "x.o"), "-m=2"}, args...)
+		out, err := exec.Command(testenv.GoToolPath(t), append(args, file)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("compile failed: %v\n%s", err, out)
+		}
+		return string(out)
+	}
+
+	out := compile("-pgoprofile", prof)
+	for _, want := range []string{
+		fmt.Sprintf(`x.go:%d[:0-9]*: PGO devirtualizing s.Area to \*Square`, lines["CALLAREA"]),
+		fmt.Sprintf(`x.go:%d[:0-9]*: inlining call to \(\*Square\).Area`, lines["CALLAREA"]),
+		fmt.Sprintf(`x.go:%d[:0-9]*: inlining call to big`, lines["HOT"]),
+		fmt.Sprintf(`x.go:%d[:0-9]*: cannot inline call to big: cost \d+ exceeds budget 80 at cold call site`, lines["COLD"]),
+		fmt.Sprintf(`x.go:%d[:0-9]*: PGO branch weights 0, 100; likely false`, lines["IF"]),
+	} {
+		if !regexp.MustCompile(`(?m)` + want).MatchString(out) {
+			t.Errorf("output does not match %q:\n%s", want, out)
+		}
+	}
+
+	// Without the profile, none of it happens.
+	out = compile()
+	if strings.Contains(out, "PGO") || regexp.MustCompile(`inlining call to (big|\(\*Square\).Area)`).MatchString(out) {
+		t.Errorf("profile-guided optimizations without a profile:\n%s", out)
+	}
+	if !strings.Contains(out, "cannot inline big: function too complex") {
+		t.Errorf("big is inlinable without a profile:\n%s", out)
+	}
+}
@@ -129,6 +129,14 @@ type Liveness struct {
 	// An array with a bit vector for each safe point tracking live variables.
 	livevars []bvec
 
+	// unsafePoints bit i is set if Value ID i is not a safe point
+	// for asynchronous preemption.
+	unsafePoints bvec
+
+	// deferreturnIndex is the stack map index for the deferreturn
+	// call of a function with open-coded defers.
+	deferreturnIndex int
+
 	cache progeffectscache
 }
 
@@ -452,6 +460,160 @@ func issafepoint(v *ssa.Value) bool {
 	return v.Op.IsCall()
 }
 
+// markUnsafePoints finds unsafe points and computes lv.unsafePoints.
+// An unsafe point is an instruction at which the goroutine must not
+// be asynchronously preempted, because the garbage collector could
+// not make sense of the goroutine's state there.
+func (lv *Liveness) markUnsafePoints() {
+	lv.unsafePoints = bvalloc(int32(lv.f.NumValues()))
+
+	if compiling_runtime || lv.f.NoSplit {
+		// The runtime assumes the only safe points are function
+		// prologues and calls, because that's how it has always
+		// been. Nosplit functions may not have room on the stack
+		// for the preemption frame. Mark everything unsafe.
+		for _, b := range lv.f.Blocks {
+			for _, v := range b.Values {
+				lv.unsafePoints.Set(int32(v.ID))
+			}
+		}
+		return
+	}
+
+	// Mark write barrier unsafe points.
+	for _, wbBlock := range lv.f.WBLoads {
+		if wbBlock.Kind == ssa.BlockPlain && len(wbBlock.Values) == 0 {
+			// The write barrier block was optimized away
+			// but we haven't done dead block elimination.
+			// (This can happen in -N mode.)
+			continue
+		}
+		// Check that we have the expected diamond shape.
+		if len(wbBlock.Succs) != 2 {
+			lv.f.Fatalf("expected branch at write barrier block %v", wbBlock)
+		}
+		s0, s1 := wbBlock.Succs[0].Block(), wbBlock.Succs[1].Block()
+		if s0 == s1 {
+			// There's no difference between write barrier
+			// on and off. Thus there are no unsafe points.
+			continue
+		}
+		if s0.Kind != ssa.BlockPlain || s1.Kind != ssa.BlockPlain {
+			lv.f.Fatalf("expected successors of write barrier block %v to be plain", wbBlock)
+		}
+		if s0.Succs[0].Block() != s1.Succs[0].Block() {
+			lv.f.Fatalf("expected successors of write barrier block %v to converge", wbBlock)
+		}
+
+		// Flow backwards from the control value to find the
+		// flag load. The lowered ops differ between
+		// architectures, but on all of them the flag is loaded
+		// (or its address computed) from the writeBarrier
+		// symbol through the first argument of each op. The
+		// earliest value of that chain in wbBlock is where the
+		// unsafe sequence begins.
+		var load *ssa.Value
+		for v := wbBlock.Control; v != nil; {
+			if v.Block == wbBlock {
+				load = v
+			}
+			if sym, ok := v.Aux.(*obj.LSym); ok && sym == writeBarrier {
+				break
+			}
+			if len(v.Args) == 0 {
+				v.Fatalf("write barrier control value does not load the write barrier flag: %s", wbBlock.Control.LongString())
+			}
+			v = v.Args[0]
+		}
+
+		// Mark everything after the load unsafe.
+		found := false
+		for _, v := range wbBlock.Values {
+			found = found || v == load
+			if found {
+				lv.unsafePoints.Set(int32(v.ID))
+			}
+		}
+
+		// Mark the two successor blocks unsafe. These come
+		// back together immediately after the direct write in
+		// one successor and the last write barrier call in
+		// the other, so there's no need to be more precise.
+		for _, succ := range wbBlock.Succs {
+			for _, v := range succ.Block().Values {
+				lv.unsafePoints.Set(int32(v.ID))
+			}
+		}
+	}
+
+	// Find uintptr -> unsafe.Pointer conversions and flood
+	// unsafeness back to a call (which is always a safe point).
+	//
+	// Looking for the uintptr -> unsafe.Pointer conversion rather
+	// than the unsafe.Pointer -> uintptr conversion avoids
+	// needlessly blocking safe points for conversions that never
+	// go back to a Pointer, and catches the implicit conversions
+	// done by reflect.Value.Pointer and friends.
+	var flooded bvec
+	var flood func(b *ssa.Block, vi int)
+	flood = func(b *ssa.Block, vi int) {
+		if flooded.n == 0 {
+			flooded = bvalloc(int32(lv.f.NumBlocks()))
+		}
+		if flooded.Get(int32(b.ID)) {
+			return
+		}
+		for i := vi - 1; i >= 0; i-- {
+			v := b.Values[i]
+			if v.Op.IsCall() {
+				// Uintptrs must not contain live
+				// pointers across calls, so stop
+				// flooding.
+				return
+			}
+			lv.unsafePoints.Set(int32(v.ID))
+		}
+		if vi == len(b.Values) {
+			// We marked all values in this block, so no
+			// need to flood this block again.
+			flooded.Set(int32(b.ID))
+		}
+		for _, pred := range b.Preds {
+			flood(pred.Block(), len(pred.Block().Values))
+		}
+	}
+	for _, b := range lv.f.Blocks {
+		for i, v := range b.Values {
+			if !(isConvert(v) && v.Type.IsPtrShaped()) {
+				continue
+			}
+			// Flood the unsafe-ness of this backwards
+			// until we hit a call.
+			flood(b, i+1)
+		}
+	}
+}
+
+// isConvert reports whether v is an unsafe.Pointer <-> uintptr
+// conversion. By the time liveness runs these have been lowered to
+// architecture-specific ops.
+func isConvert(v *ssa.Value) bool {
+	switch v.Op {
+	case ssa.OpConvert,
+		ssa.Op386MOVLconvert,
+		ssa.OpAMD64MOVQconvert,
+		ssa.OpAMD64MOVLconvert,
+		ssa.OpARMMOVWconvert,
+		ssa.OpARM64MOVDconvert,
+		ssa.OpMIPSMOVWconvert,
+		ssa.OpMIPS64MOVVconvert,
+		ssa.OpPPC64MOVDconvert,
+		ssa.OpS390XMOVDconvert:
+		return true
+	}
+	return false
+}
+
 // Initializes the sets for solving the live variables. Visits all the
 // instructions in each basic block to summarizes the information at each basic
 // block
@@ -622,6 +784,17 @@ func (lv *Liveness) epilogue() {
 				n.Name.SetNeedzero(true)
 				livedefer.Set(int32(i))
 			}
+			if n.Name.OpenDeferSlot() {
+				// Open-coded defer args slots must be live
+				// everywhere in a function, since a panic can
+				// occur (almost) anywhere. Because it is live
+				// everywhere, it must be zeroed on entry.
+				livedefer.Set(int32(i))
+				// It was already marked as Needzero when created.
+				if !n.Name.Needzero() {
+					Fatalf("all pointer-containing defer arg slots should have Needzero set")
+				}
+			}
 		}
 	}
 
@@ -735,6 +908,14 @@ func (lv *Liveness) epilogue() {
 		}
 	}
 
+	// If we have an open-coded deferreturn call, make a liveness map for it.
+	// Only the results and the defer slots are needed there.
+	if lv.hasOpenDefers() {
+		live := bvalloc(nvars)
+		live.Copy(livedefer)
+		lv.livevars = append(lv.livevars, live)
+	}
+
 	// Useful sanity check: on entry to the function,
 	// the only things that can possibly be live are the
 	// input parameters.
@@ -745,6 +926,13 @@ func (lv *Liveness) epilogue() {
 	}
 }
 
+// hasOpenDefers reports whether the function uses open-coded defers,
+// and so has a deferreturn landing pad that needs a stack map.
+func (lv *Liveness) hasOpenDefers() bool {
+	ls := lv.fn.Func.lsym
+	return ls != nil && ls.Func.OpenCodedDeferInfo != nil
+}
+
 func (lv *Liveness) clobber() {
 	// The clobberdead experiment inserts code to clobber all the dead variables (locals and args)
 	// before and after every safepoint. This experiment is useful for debugging the generation
@@ -1026,6 +1214,9 @@ Outer:
 			}
 		}
 	}
+	if lv.hasOpenDefers() {
+		lv.deferreturnIndex = remap[pos]
+	}
 }
 
 func (lv *Liveness) showlive(v *ssa.Value, live bvec) {
@@ -1247,8 +1438,10 @@ func (lv *Liveness) emit(argssym, livesym *obj.LSym) {
 // Entry pointer for liveness analysis. Solves for the liveness of
 // pointer variables in the function and emits a runtime data
 // structure read by the garbage collector.
-// Returns a map from GC safe points to their corresponding stack map index.
-func liveness(e *ssafn, f *ssa.Func) map[*ssa.Value]int {
+// Returns a map from GC safe points to their corresponding stack map index,
+// and a bit vector, indexed by Value ID, of the values at which the
+// goroutine must not be asynchronously preempted.
+func liveness(e *ssafn, f *ssa.Func) (map[*ssa.Value]int, bvec, int) {
 	// Construct the global liveness state.
 	vars, idx := getvariables(e.curfn)
 	lv := newliveness(e.curfn, f, vars, idx, e.stkptrsize)
@@ -1259,6 +1452,7 @@ func liveness(e *ssafn, f *ssa.Func) map[*ssa.Value]int {
 	lv.epilogue()
 	lv.compact()
 	lv.clobber()
+	lv.markUnsafePoints()
 	if debuglive >= 2 {
 		lv.printDebug()
 	}
@@ -1267,5 +1461,5 @@ func liveness(e *ssafn, f *ssa.Func) map[*ssa.Value]int {
 	if ls := e.curfn.Func.lsym; ls != nil {
 		lv.emit(&ls.Func.GCArgs, &ls.Func.GCLocals)
 	}
-	return lv.stackMapIndex
+	return lv.stackMapIndex, lv.unsafePoints, lv.deferreturnIndex
 }
@@ -114,13 +114,14 @@ func bmap(t *types.Type) *types.Type {
 		valtype = types.NewPtr(valtype)
 	}
 
-	field := make([]*types.Field, 0, 5)
+	field := make([]*types.Field, 0, 3)
 
-	// The first field is: uint8 topbits[BUCKETSIZE].
-	arr := types.NewArray(types.Types[TUINT8], BUCKETSIZE)
-	field = append(field, makefield("topbits", arr))
+	// The first field is: uint64 ctrl, holding one control byte
+	// per slot. The runtime only accesses it as a whole word, so
+	// the byte order does not matter.
+	field = append(field, makefield("ctrl", types.Types[TUINT64]))
 
-	arr = types.NewArray(keytype, BUCKETSIZE)
+	arr := types.NewArray(keytype, BUCKETSIZE)
 	arr.SetNoalg(true)
 	keys := makefield("keys", arr)
 	field = append(field, keys)
@@ -130,39 +131,12 @@ func bmap(t *types.Type) *types.Type {
 	values := makefield("values", arr)
 	field = append(field, values)
 
-	// Make sure the overflow pointer is the last memory in the struct,
-	// because the runtime assumes it can use size-ptrSize as the
-	// offset of the overflow pointer. We double-check that property
-	// below once the offsets and size are computed.
+	// There is no overflow pointer: maps use open addressing,
+	// so a bucket never links to another one.
 	//
-	// BUCKETSIZE is 8, so the struct is aligned to 64 bits to this point.
-	// On 32-bit systems, the max alignment is 32-bit, and the
-	// overflow pointer will add another 32-bit field, and the struct
-	// will end with no padding.
-	// On 64-bit systems, the max alignment is 64-bit, and the
-	// overflow pointer will add another 64-bit field, and the struct
-	// will end with no padding.
-	// On nacl/amd64p32, however, the max alignment is 64-bit,
-	// but the overflow pointer will add only a 32-bit field,
-	// so if the struct needs 64-bit padding (because a key or value does)
-	// then it would end with an extra 32-bit padding field.
-	// Preempt that by emitting the padding here.
-	if int(valtype.Align) > Widthptr || int(keytype.Align) > Widthptr {
-		field = append(field, makefield("pad", types.Types[TUINTPTR]))
-	}
-
-	// If keys and values have no pointers, the map implementation
-	// can keep a list of overflow pointers on the side so that
-	// buckets can be marked as having no pointers.
-	// Arrange for the bucket to have no pointers by changing
-	// the type of the overflow field to uintptr in this case.
-	// See comment on hmap.overflow in ../../../../runtime/hashmap.go.
-	otyp := types.NewPtr(bucket)
-	if !types.Haspointers(valtype) && !types.Haspointers(keytype) {
-		otyp = types.Types[TUINTPTR]
-	}
-	overflow := makefield("overflow", otyp)
-	field = append(field, overflow)
+	// BUCKETSIZE is 8 and key and value sizes are multiples of their
+	// alignment, which is at most 8, so the keys and values are
+	// aligned and need no padding.
 
 	// link up fields
 	bucket.SetNoalg(true)
@@ -213,10 +187,16 @@ func bmap(t *types.Type) *types.Type {
 		Fatalf("bad alignment of values in bmap for %v", t)
 	}
 
-	// Double-check that overflow field is final memory in struct,
-	// with no padding at end. See comment above.
-	if overflow.Offset != bucket.Width-int64(Widthptr) {
-		Fatalf("bad offset of overflow in bmap for %v", t)
+	// Double-check that there is no padding at the end of the struct,
+	// except when the values are zero-sized: then the struct is padded
+	// so that the address of the values cannot point past its end.
+	// See comment above.
+	size := 8 + BUCKETSIZE*(keytype.Width+valtype.Width)
+	if valtype.Width == 0 {
+		size += int64(bucket.Align)
+	}
+	if bucket.Width != size {
+		Fatalf("bad size of bmap for %v", t)
 	}
 
 	t.MapType().Bucket = bucket
@@ -239,24 +219,24 @@ func hmap(t *types.Type) *types.Type {
 	//    count      int
 	//    flags      uint8
 	//    B          uint8
-	//    noverflow  uint16
 	//    hash0      uint32
 	//    buckets    *bmap
+	//    ndeleted   uintptr
 	//    oldbuckets *bmap
 	//    nevacuate  uintptr
-	//    extra      unsafe.Pointer // *mapextra
+	//    evacuated  unsafe.Pointer
 	// }
 	// must match ../../../../runtime/hashmap.go:hmap.
 	fields := []*types.Field{
 		makefield("count", types.Types[TINT]),
 		makefield("flags", types.Types[TUINT8]),
 		makefield("B", types.Types[TUINT8]),
-		makefield("noverflow", types.Types[TUINT16]),
 		makefield("hash0", types.Types[TUINT32]), // Used in walk.go for OMAKEMAP.
 		makefield("buckets", types.NewPtr(bmap)), // Used in walk.go for OMAKEMAP.
+		makefield("ndeleted", types.Types[TUINTPTR]),
 		makefield("oldbuckets", types.NewPtr(bmap)),
 		makefield("nevacuate", types.Types[TUINTPTR]),
-		makefield("extra", types.Types[TUNSAFEPTR]),
+		makefield("evacuated", types.Types[TUNSAFEPTR]),
 	}
 
 	hmap := types.New(TSTRUCT)
@@ -264,9 +244,9 @@ func hmap(t *types.Type) *types.Type {
 	hmap.SetFields(fields)
 	dowidth(hmap)
 
-	// The size of hmap should be 48 bytes on 64 bit
-	// and 28 bytes on 32 bit platforms.
-	if size := int64(8 + 5*Widthptr); hmap.Width != size {
+	// The size of hmap should be 56 bytes on 64 bit
+	// and 32 bytes on 32 bit platforms.
+	if size := int64(8 + 6*Widthptr); hmap.Width != size {
 		Fatalf("hmap size not correct: got %d, want %d", hmap.Width, size)
 	}
 
@@ -292,16 +272,13 @@ func hiter(t *types.Type) *types.Type {
 	//    t           unsafe.Pointer // *MapType
 	//    h           *hmap
 	//    buckets     *bmap
-	//    bptr        *bmap
-	//    overflow    unsafe.Pointer // *[]*bmap
-	//    oldoverflow unsafe.Pointer // *[]*bmap
+	//    oldbuckets  *bmap
 	//    startBucket uintptr
+	//    bucket      uintptr
 	//    offset      uint8
-	//    wrapped     bool
 	//    B           uint8
+	//    oldB        uint8
 	//    i           uint8
-	//    bucket      uintptr
-	//    checkBucket uintptr
 	// }
 	// must match ../../../../runtime/hashmap.go:hiter.
 	fields := []*types.Field{
@@ -310,16 +287,13 @@ func hiter(t *types.Type) *types.Type {
 		makefield("t", types.Types[TUNSAFEPTR]),
 		makefield("h", types.NewPtr(hmap)),
 		makefield("buckets", types.NewPtr(bmap)),
-		makefield("bptr", types.NewPtr(bmap)),
-		makefield("overflow", types.Types[TUNSAFEPTR]),
-		makefield("oldoverflow", types.Types[TUNSAFEPTR]),
+		makefield("oldbuckets", types.NewPtr(bmap)),
 		makefield("startBucket", types.Types[TUINTPTR]),
+		makefield("bucket", types.Types[TUINTPTR]),
 		makefield("offset", types.Types[TUINT8]),
-		makefield("wrapped", types.Types[TBOOL]),
 		makefield("B", types.Types[TUINT8]),
+		makefield("oldB", types.Types[TUINT8]),
 		makefield("i", types.Types[TUINT8]),
-		makefield("bucket", types.Types[TUINTPTR]),
-		makefield("checkBucket", types.Types[TUINTPTR]),
 	}
 
 	// build iterator struct holding the above fields
@@ -327,8 +301,8 @@ func hiter(t *types.Type) *types.Type {
 	hiter.SetNoalg(true)
 	hiter.SetFields(fields)
 	dowidth(hiter)
-	if hiter.Width != int64(12*Widthptr) {
-		Fatalf("hash_iter size not correct %d %d", hiter.Width, 12*Widthptr)
+	if hiter.Width != int64(9*Widthptr) {
+		Fatalf("hash_iter size not correct %d %d", hiter.Width, 9*Widthptr)
 	}
 	t.MapType().Hiter = hiter
 	hiter.StructType().Map = t
@@ -1167,13 +1141,15 @@ func dtypesym(t *types.Type) *obj.LSym {
 		tbase = t.Elem()
 	}
 	dupok := 0
-	if tbase.Sym == nil {
+	if tbase.Sym == nil || isInstance(tbase) {
+		// Instances of generic types may be instantiated,
+		// identically, by several packages.
 		dupok = obj.DUPOK
 	}
 
 	if myimportpath != "runtime" || (tbase != types.Types[tbase.Etype] && tbase != types.Bytetype && tbase != types.Runetype && tbase != types.Errortype) { // int, float, etc
 		// named types from other files are defined only by those files
-		if tbase.Sym != nil && tbase.Sym.Pkg != localpkg {
+		if tbase.Sym != nil && tbase.Sym.Pkg != localpkg && !isInstance(tbase) {
 			return lsym
 		}
 		// TODO(mdempsky): Investigate whether this can happen.
@@ -1488,7 +1464,7 @@ func addsignat(t *types.Type) {
 func addsignats(dcls []*Node) {
 	// copy types from dcl list to signatset
 	for _, n := range dcls {
-		if n.Op == OTYPE {
+		if n.Op == OTYPE && templates[n] == nil {
 			addsignat(n.Type)
 		}
 	}
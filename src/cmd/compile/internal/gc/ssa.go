@@ -23,6 +23,11 @@ import (
 var ssaConfig *ssa.Config
 var ssaCaches []ssa.Cache
 
+// maxOpenDefers is the maximum number of defers in a function using
+// open-coded defers. We enforce this limit because the deferBits
+// bitmask is a single byte.
+const maxOpenDefers = 8
+
 func initssaconfig() {
 	types_ := ssa.Types{
 		Bool:       types.Types[TBOOL],
@@ -126,6 +131,28 @@ func buildssa(fn *Node, worker int) *ssa.Func {
 	if fn.Func.Pragma&CgoUnsafeArgs != 0 {
 		s.cgoUnsafeArgs = true
 	}
+	s.hasOpenDefers = Debug['N'] == 0 && s.hasdefer && !fn.Func.OpenCodedDeferDisallowed() && fn.Func.lsym != nil
+	if s.hasOpenDefers && (Ctxt.Flag_shared || Ctxt.Flag_dynlink) && thearch.LinkArch.Name == "386" {
+		// Don't support open-coded defers for 386 when using shared
+		// libraries, because rewriteToUseGot turns the deferreturn call
+		// into an indirect call that the linker can't locate.
+		s.hasOpenDefers = false
+	}
+	if s.hasOpenDefers && fn.Func.Exit.Len() > 0 {
+		// Skip doing open defers if there is any extra exit code (likely
+		// copying heap-allocated return values or race detection), since
+		// we will not generate that code in the case of the extra
+		// deferreturn/ret segment.
+		s.hasOpenDefers = false
+	}
+	if s.hasOpenDefers && fn.Func.numReturns*fn.Func.numDefers > 15 {
+		// Since we are generating defer calls at every exit for
+		// open-coded defers, skip doing open-coded defers if there are
+		// too many returns (especially if there are multiple defers).
+		// Open-coded defers are most important for improving performance
+		// for smaller functions (which don't have many returns).
+		s.hasOpenDefers = false
+	}
 
 	fe := ssafn{
 		curfn: fn,
@@ -165,6 +192,24 @@ func buildssa(fn *Node, worker int) *ssa.Func {
 
 	s.startBlock(s.f.Entry)
 	s.vars[&memVar] = s.startmem
+	if s.hasOpenDefers {
+		// Create the deferBits variable and stack slot. deferBits is a
+		// bitmask showing which of the open-coded defers in this function
+		// have been activated.
+		deferBitsTemp := tempAt(src.NoXPos, s.curfn, types.Types[TUINT8])
+		s.deferBitsTemp = deferBitsTemp
+		// For this value, AuxInt is initialized to zero by default
+		startDeferBits := s.entryNewValue0(ssa.OpConst8, types.Types[TUINT8])
+		s.vars[&deferBitsVar] = startDeferBits
+		s.deferBitsAddr = s.addr(deferBitsTemp, false)
+		s.storeType(types.Types[TUINT8], s.deferBitsAddr, startDeferBits, 0)
+		// Make sure that the deferBits stack slot is kept alive (for use
+		// by panics) and stores to deferBits are not eliminated, even if
+		// all checking code on deferBits in the function exit can be
+		// eliminated, because the defer statements were all
+		// unconditional.
+		s.vars[&memVar] = s.newValue1A(ssa.OpVarLive, types.TypeMem, deferBitsTemp, s.mem())
+	}
 
 	// Generate addresses of local declarations
 	s.decladdrs = map[*Node]*ssa.Value{}
@@ -221,9 +266,77 @@ func buildssa(fn *Node, worker int) *ssa.Func {
 
 	// Main call to ssa package to compile function
 	ssa.Compile(s.f)
+
+	if s.hasOpenDefers {
+		s.emitOpenDeferInfo()
+	}
+
 	return s.f
 }
 
+// emitOpenDeferInfo emits FUNCDATA information about the defers in a function
+// that is using open-coded defers. This funcdata is used to determine the active
+// defers in a function and execute those defers during panic processing.
+//
+// The funcdata is all encoded in varints (since values will almost always be
+// less than 128, but stack offsets could be larger). All "locations" (offsets)
+// for stack variables are specified as the number of bytes below varp (pointer
+// to the top of the local variables) for their starting address. The format is:
+//
+//  - Max total argument size among all the defers
+//  - Offset of the deferBits variable
+//  - Number of defers in the function
+//  - Information about each defer call, in reverse order of appearance in the function:
+//    - Total argument size of the call
+//    - Offset of the closure value to call
+//    - Number of arguments (including interface receiver or method receiver as first arg)
+//    - Information about each argument
+//      - Offset of the stored defer argument in this function's frame
+//      - Size of the argument
+//      - Offset of where argument should be placed in the args frame when making call
+func (s *state) emitOpenDeferInfo() {
+	x := Ctxt.Lookup(s.curfn.Func.lsym.Name + ".opendefer")
+	s.curfn.Func.lsym.Func.OpenCodedDeferInfo = x
+	off := 0
+
+	// Compute maxargsize (max size of arguments for all defers)
+	// first, so we can output it first to the funcdata
+	var maxargsize int64
+	for _, r := range s.openDefers {
+		if argsize := r.n.Left.Type.ArgWidth(); argsize > maxargsize {
+			maxargsize = argsize
+		}
+	}
+	off = dvarint(x, off, maxargsize)
+	off = dvarint(x, off, -s.deferBitsTemp.Xoffset)
+	off = dvarint(x, off, int64(len(s.openDefers)))
+
+	// Write in reverse-order, for ease of running in that order at runtime
+	for i := len(s.openDefers) - 1; i >= 0; i-- {
+		r := s.openDefers[i]
+		off = dvarint(x, off, r.n.Left.Type.ArgWidth())
+		off = dvarint(x, off, -r.closureNode.Xoffset)
+		numArgs := len(r.args)
+		if r.rcvrNode != nil {
+			// If there's an interface receiver, treat/place it as the first
+			// arg. (If there is a method receiver, it's already included as
+			// first arg in r.args.)
+			numArgs++
+		}
+		off = dvarint(x, off, int64(numArgs))
+		if r.rcvrNode != nil {
+			off = dvarint(x, off, -r.rcvrNode.Xoffset)
+			off = dvarint(x, off, int64(Widthptr))
+			off = dvarint(x, off, 0)
+		}
+		for _, a := range r.args {
+			off = dvarint(x, off, -a.n.Xoffset)
+			off = dvarint(x, off, a.t.Size())
+			off = dvarint(x, off, a.off)
+		}
+	}
+}
+
 // updateUnsetPredPos propagates the earliest-value position information for b
 // towards all of b's predecessors that need a position, and recurs on that
 // predecessor if its position is updated. B should have a non-empty position.
@@ -318,6 +431,47 @@ type state struct {
 	cgoUnsafeArgs bool
 	hasdefer      bool // whether the function contains a defer statement
 	softFloat     bool
+	hasOpenDefers bool // whether we are doing open-coded defers
+
+	// If doing open-coded defers, list of info about the defer calls in
+	// scanning order. Hence, at exit we should run these defers in reverse
+	// order of this list.
+	openDefers []*openDeferInfo
+	// Address and stack slot of the bitmask recording which open-coded
+	// defers have been activated.
+	deferBitsAddr *ssa.Value
+	deferBitsTemp *Node
+}
+
+// openDeferInfo records what is needed to run one open-coded defer
+// at function exit, or from the runtime during a panic.
+type openDeferInfo struct {
+	// The call node of the defer statement.
+	n *Node
+	// If the deferred call is through a closure or an interface, the
+	// address of the stack slot where the closure (or the itab's
+	// method entry) is stored. nil for static calls.
+	closure *ssa.Value
+	// The stack slot holding the function value to call. It is filled
+	// in for every defer, so that panic processing has a closure to
+	// call even when the exit code makes a static call.
+	closureNode *Node
+	// For interface calls, the address of the stack slot holding the
+	// receiver, and the slot itself.
+	rcvr     *ssa.Value
+	rcvrNode *Node
+	// The evaluated arguments (including any method receiver) of the
+	// deferred call, stored in stack slots when the defer statement
+	// executes.
+	args []openDeferArg
+}
+
+// openDeferArg describes one saved argument of an open-coded defer.
+type openDeferArg struct {
+	addr *ssa.Value  // address of the stack slot holding the argument
+	n    *Node       // the stack slot
+	t    *types.Type // type of the argument
+	off  int64       // offset of the argument in the callee's argument frame
 }
 
 type funcLine struct {
@@ -361,6 +515,9 @@ var (
 	capVar    = Node{Op: ONAME, Sym: &types.Sym{Name: "cap"}}
 	typVar    = Node{Op: ONAME, Sym: &types.Sym{Name: "typ"}}
 	okVar     = Node{Op: ONAME, Sym: &types.Sym{Name: "ok"}}
+
+	// dummy node for the bitmask of active open-coded defers
+	deferBitsVar = Node{Op: ONAME, Sym: &types.Sym{Name: "deferBits"}}
 )
 
 // startBlock sets the current block we're generating code in to b.
@@ -633,7 +790,20 @@ func (s *state) stmt(n *Node) {
 			}
 		}
 	case ODEFER:
-		s.call(n.Left, callDefer)
+		if Debug_defer > 0 {
+			var defertype string
+			if s.hasOpenDefers {
+				defertype = "open-coded"
+			} else {
+				defertype = "heap-allocated"
+			}
+			Warnl(n.Pos, "%s defer", defertype)
+		}
+		if s.hasOpenDefers {
+			s.openDeferRecord(n.Left)
+		} else {
+			s.call(n.Left, callDefer)
+		}
 	case OPROC:
 		s.call(n.Left, callGo)
 
@@ -828,6 +998,8 @@ func (s *state) stmt(n *Node) {
 		var likely int8
 		if n.Likely() {
 			likely = 1
+		} else if n.Unlikely() {
+			likely = -1
 		}
 		if n.Rlist.Len() != 0 {
 			bElse = s.f.NewBlock(ssa.BlockPlain)
@@ -1038,7 +1210,11 @@ func (s *state) stmt(n *Node) {
 // will be set to the final memory state.
 func (s *state) exit() *ssa.Block {
 	if s.hasdefer {
-		s.rtcall(Deferreturn, true, nil)
+		if s.hasOpenDefers {
+			s.openDeferExit()
+		} else {
+			s.rtcall(Deferreturn, true, nil)
+		}
 	}
 
 	// Run exit code. Typically, this code copies heap-allocated PPARAMOUT
@@ -3333,6 +3509,249 @@ func (s *state) intrinsicArgs(n *Node) []*ssa.Value {
 	return res
 }
 
+// openDeferRecord adds code to evaluate and store the function value and
+// arguments of an open-coded defer call n, and records info about the defer,
+// so we can generate the call on the exit paths. The stack slots used here,
+// along with the deferBits variable, are also described in funcdata so
+// that panics can run the defer.
+func (s *state) openDeferRecord(n *Node) {
+	index := len(s.openDefers)
+	opendefer := &openDeferInfo{n: n}
+	fn := n.Left
+	// We must always store a function value in a stack slot for the
+	// runtime panic code to use, even when the exit code calls the
+	// function directly. Static closures and itabs are not in the heap,
+	// so their slots are typed uintptr to keep them out of the stack maps.
+	switch n.Op {
+	case OCALLFUNC:
+		if fn.Op == ONAME && fn.Class() == PFUNC {
+			closure := s.entryNewValue1A(ssa.OpAddr, types.Types[TUINTPTR], funcsym(fn.Sym).Linksym(), s.sb)
+			opendefer.closureNode, _ = s.openDeferSave(closure.Type, closure, nil)
+			break
+		}
+		closure := s.expr(fn)
+		opendefer.closureNode, opendefer.closure = s.openDeferSave(fn.Type, closure, nil)
+	case OCALLMETH:
+		if fn.Op != ODOTMETH {
+			Fatalf("OCALLMETH: n.Left not an ODOTMETH: %v", fn)
+		}
+		// The receiver is assigned in n.List.
+		closure := s.entryNewValue1A(ssa.OpAddr, types.Types[TUINTPTR], funcsym(fn.Sym).Linksym(), s.sb)
+		opendefer.closureNode, _ = s.openDeferSave(closure.Type, closure, nil)
+	case OCALLINTER:
+		if fn.Op != ODOTINTER {
+			Fatalf("OCALLINTER: n.Left not an ODOTINTER: %v", fn.Op)
+		}
+		i := s.expr(fn.Left)
+		itab := s.newValue1(ssa.OpITab, types.Types[TUINTPTR], i)
+		s.nilCheck(itab)
+		itabidx := fn.Xoffset + 2*int64(Widthptr) + 8 // offset of fun field in runtime.itab
+		closure := s.newValue1I(ssa.OpOffPtr, s.f.Config.Types.UintptrPtr, itabidx, itab)
+		rcvr := s.newValue1(ssa.OpIData, types.Types[TUNSAFEPTR], i)
+		opendefer.closureNode, opendefer.closure = s.openDeferSave(types.Types[TUINTPTR], closure, nil)
+		opendefer.rcvrNode, opendefer.rcvr = s.openDeferSave(rcvr.Type, rcvr, nil)
+	default:
+		Fatalf("open-coded defer of %v", n.Op)
+	}
+	dowidth(fn.Type)
+
+	// The argument assignments in n.List store into the outgoing
+	// argument area, offset by 2*widthptr for deferproc's size and
+	// function arguments. Redirect them into stack slots instead. Any
+	// other statements (temporaries introduced to order calls among
+	// the arguments) are run as usual.
+	argBase := Ctxt.FixedFrameSize() + int64(2*Widthptr)
+	for _, a := range n.List.Slice() {
+		if a.Op != OAS || a.Left.Op != OINDREGSP {
+			s.stmt(a)
+			continue
+		}
+		t := a.Left.Type
+		rhs := a.Right
+		if rhs != nil {
+			switch rhs.Op {
+			case OSTRUCTLIT, OARRAYLIT, OSLICELIT:
+				// Only zero-valued literals survive walk.
+				if !iszero(rhs) {
+					Fatalf("literal with nonzero value in SSA: %v", rhs)
+				}
+				rhs = nil
+			}
+		}
+		var slot *Node
+		var addr *ssa.Value
+		if canSSAType(t) {
+			var v *ssa.Value
+			if rhs == nil {
+				v = s.zeroVal(t)
+			} else {
+				v = s.expr(rhs)
+			}
+			slot, addr = s.openDeferSave(t, v, nil)
+		} else {
+			var src *ssa.Value
+			if rhs != nil {
+				src = s.addr(rhs, false)
+			}
+			slot, addr = s.openDeferSave(t, nil, src)
+		}
+		opendefer.args = append(opendefer.args, openDeferArg{
+			addr: addr,
+			n:    slot,
+			t:    t,
+			off:  a.Left.Xoffset - argBase,
+		})
+	}
+	s.openDefers = append(s.openDefers, opendefer)
+
+	// Update deferBits only after evaluation and storage to stack of
+	// args/receiver/interface is successful.
+	bitvalue := s.constInt8(types.Types[TUINT8], 1<<uint(index))
+	newDeferBits := s.newValue2(ssa.OpOr8, types.Types[TUINT8], s.variable(&deferBitsVar, types.Types[TUINT8]), bitvalue)
+	s.vars[&deferBitsVar] = newDeferBits
+	s.storeType(types.Types[TUINT8], s.deferBitsAddr, newDeferBits, 0)
+	// Keep the store alive; the panic code may read it.
+	s.vars[&memVar] = s.newValue1A(ssa.OpVarLive, types.TypeMem, s.deferBitsTemp, s.mem())
+}
+
+// openDeferSave generates SSA nodes to store a value of type t into a new
+// stack slot for an open-coded defer, and returns the slot and its address.
+// If t is SSA-able, val is the value to store. Otherwise src is the address
+// of the value to copy, or nil to zero the slot.
+func (s *state) openDeferSave(t *types.Type, val, src *ssa.Value) (*Node, *ssa.Value) {
+	slot := tempAt(s.peekPos(), s.curfn, t)
+	slot.Name.SetOpenDeferSlot(true)
+	if types.Haspointers(t) {
+		// The exit code and the panic code only read the slot when the
+		// defer has been activated, but the slot is live everywhere in
+		// the function. It must be zeroed on entry so the GC doesn't
+		// follow a garbage pointer before then.
+		slot.Name.SetNeedzero(true)
+	}
+	ptrT := types.NewPtr(t)
+	var addr *ssa.Value
+	if s.curBlock == s.f.Entry {
+		s.vars[&memVar] = s.newValue1A(ssa.OpVarDef, types.TypeMem, slot, s.mem())
+		s.vars[&memVar] = s.newValue1A(ssa.OpVarLive, types.TypeMem, slot, s.mem())
+		addr = s.newValue1A(ssa.OpAddr, ptrT, slot, s.sp)
+	} else {
+		// Declare the slot in the entry block, so that it is live
+		// (and initialized) for the exit code in every path, which
+		// reads it only if the associated defer has been activated.
+		// The entry block has already ended, so its final memory
+		// state is in defvars.
+		entryMem := s.defvars[s.f.Entry.ID]
+		entryMem[&memVar] = s.entryNewValue1A(ssa.OpVarDef, types.TypeMem, slot, entryMem[&memVar])
+		entryMem[&memVar] = s.entryNewValue1A(ssa.OpVarLive, types.TypeMem, slot, entryMem[&memVar])
+		addr = s.entryNewValue1A(ssa.OpAddr, ptrT, slot, s.sp)
+	}
+	switch {
+	case val != nil:
+		// The slot is on the stack, so no write barrier is needed.
+		s.vars[&memVar] = s.newValue3A(ssa.OpStore, types.TypeMem, t, addr, val, s.mem())
+	case src != nil:
+		store := s.newValue3I(ssa.OpMove, types.TypeMem, t.Size(), addr, src, s.mem())
+		store.Aux = t
+		s.vars[&memVar] = store
+	default:
+		store := s.newValue2I(ssa.OpZero, types.TypeMem, t.Size(), addr, s.mem())
+		store.Aux = t
+		s.vars[&memVar] = store
+	}
+	return slot, addr
+}
+
+// openDeferExit generates SSA for processing all the open-coded defers at
+// an exit point. The defers are run in the reverse order of the defer
+// statements, each only if its bit is set in deferBits.
+func (s *state) openDeferExit() {
+	zeroval := s.constInt8(types.Types[TUINT8], 0)
+	for i := len(s.openDefers) - 1; i >= 0; i-- {
+		r := s.openDefers[i]
+		bCond := s.f.NewBlock(ssa.BlockPlain)
+		bEnd := s.f.NewBlock(ssa.BlockPlain)
+
+		// Generate code to check if the bit associated with the current
+		// defer is set.
+		deferBits := s.variable(&deferBitsVar, types.Types[TUINT8])
+		bitval := s.constInt8(types.Types[TUINT8], 1<<uint(i))
+		andval := s.newValue2(ssa.OpAnd8, types.Types[TUINT8], deferBits, bitval)
+		eqVal := s.newValue2(ssa.OpEq8, types.Types[TBOOL], andval, zeroval)
+		b := s.endBlock()
+		b.Kind = ssa.BlockIf
+		b.SetControl(eqVal)
+		b.AddEdgeTo(bEnd)
+		b.AddEdgeTo(bCond)
+		bCond.AddEdgeTo(bEnd)
+		s.startBlock(bCond)
+
+		// Clear this bit in deferBits and force store back to stack, so
+		// we will not try to re-run this defer call if this defer call panics.
+		nbitval := s.newValue1(ssa.OpCom8, types.Types[TUINT8], bitval)
+		maskedval := s.newValue2(ssa.OpAnd8, types.Types[TUINT8], deferBits, nbitval)
+		s.storeType(types.Types[TUINT8], s.deferBitsAddr, maskedval, 0)
+		// Use this value for following tests, so we keep previous
+		// bits cleared.
+		s.vars[&deferBitsVar] = maskedval
+
+		// Generate the call, using the closure, receiver and args that
+		// were saved in stack slots at the defer statement.
+		argStart := Ctxt.FixedFrameSize()
+		fn := r.n.Left
+		stksize := fn.Type.ArgWidth()
+		if r.rcvr != nil {
+			t := r.rcvrNode.Type
+			v := s.newValue2(ssa.OpLoad, t, r.rcvr, s.mem())
+			addr := s.constOffPtrSP(types.NewPtr(t), argStart)
+			s.vars[&memVar] = s.newValue3A(ssa.OpStore, types.TypeMem, t, addr, v, s.mem())
+		}
+		for _, a := range r.args {
+			addr := s.constOffPtrSP(types.NewPtr(a.t), argStart+a.off)
+			if canSSAType(a.t) {
+				v := s.newValue2(ssa.OpLoad, a.t, a.addr, s.mem())
+				s.vars[&memVar] = s.newValue3A(ssa.OpStore, types.TypeMem, a.t, addr, v, s.mem())
+			} else {
+				store := s.newValue3I(ssa.OpMove, types.TypeMem, a.t.Size(), addr, a.addr, s.mem())
+				store.Aux = a.t
+				s.vars[&memVar] = store
+			}
+		}
+		var call *ssa.Value
+		switch {
+		case r.rcvr != nil:
+			fnptr := s.newValue2(ssa.OpLoad, s.f.Config.Types.UintptrPtr, r.closure, s.mem())
+			codeptr := s.newValue2(ssa.OpLoad, types.Types[TUINTPTR], fnptr, s.mem())
+			call = s.newValue2(ssa.OpInterCall, types.TypeMem, codeptr, s.mem())
+		case r.closure != nil:
+			closure := s.newValue2(ssa.OpLoad, fn.Type, r.closure, s.mem())
+			codeptr := s.newValue2(ssa.OpLoad, types.Types[TUINTPTR], closure, s.mem())
+			call = s.newValue3(ssa.OpClosureCall, types.TypeMem, codeptr, closure, s.mem())
+		default:
+			// Static call of a function or method.
+			call = s.newValue1A(ssa.OpStaticCall, types.TypeMem, fn.Sym.Linksym(), s.mem())
+		}
+		call.AuxInt = stksize
+		s.vars[&memVar] = call
+
+		// Keep the slots with pointers live through the call, which is
+		// a safe point.
+		if types.Haspointers(r.closureNode.Type) {
+			s.vars[&memVar] = s.newValue1A(ssa.OpVarLive, types.TypeMem, r.closureNode, s.mem())
+		}
+		if r.rcvrNode != nil {
+			s.vars[&memVar] = s.newValue1A(ssa.OpVarLive, types.TypeMem, r.rcvrNode, s.mem())
+		}
+		for _, a := range r.args {
+			if types.Haspointers(a.t) {
+				s.vars[&memVar] = s.newValue1A(ssa.OpVarLive, types.TypeMem, a.n, s.mem())
+			}
+		}
+
+		s.endBlock()
+		s.startBlock(bEnd)
+	}
+}
+
 // Calls the function n using the specified call type.
 // Returns the address of the return value (or nil if none).
 func (s *state) call(n *Node, k callKind) *ssa.Value {
@@ -4585,6 +5004,19 @@ type SSAGenState struct {
 	// Map from GC safe points to stack map index, generated by
 	// liveness analysis.
 	stackMapIndex map[*ssa.Value]int
+
+	// unsafePoints has a bit set for each value (by ID) at which
+	// the goroutine must not be asynchronously preempted. It is
+	// generated by liveness analysis.
+	unsafePoints bvec
+
+	// unsafe reports whether the instructions being emitted are
+	// currently marked as unsafe for asynchronous preemption.
+	unsafe bool
+
+	// deferreturnIndex is the stack map index for the deferreturn
+	// call emitted for functions with open-coded defers.
+	deferreturnIndex int
 }
 
 // Prog appends a new Prog.
@@ -4592,6 +5024,23 @@ func (s *SSAGenState) Prog(as obj.As) *obj.Prog {
 	return s.pp.Prog(as)
 }
 
+// markUnsafePoint emits a PCDATA instruction if the instructions
+// that follow differ from the preceding ones in whether the goroutine
+// may be asynchronously preempted there.
+func (s *SSAGenState) markUnsafePoint(unsafe bool) {
+	if unsafe == s.unsafe {
+		return
+	}
+	s.unsafe = unsafe
+	p := s.Prog(obj.APCDATA)
+	Addrconst(&p.From, objabi.PCDATA_UnsafePoint)
+	if unsafe {
+		Addrconst(&p.To, objabi.PCDATA_UnsafePointUnsafe)
+	} else {
+		Addrconst(&p.To, objabi.PCDATA_UnsafePointSafe)
+	}
+}
+
 // Pc returns the current Prog.
 func (s *SSAGenState) Pc() *obj.Prog {
 	return s.pp.next
@@ -4631,7 +5080,7 @@ func genssa(f *ssa.Func, pp *Progs) {
 
 	e := f.Frontend().(*ssafn)
 
-	s.stackMapIndex = liveness(e, f)
+	s.stackMapIndex, s.unsafePoints, s.deferreturnIndex = liveness(e, f)
 
 	// Remember where each block starts.
 	s.bstart = make([]*obj.Prog, f.NumBlocks())
@@ -4653,6 +5102,20 @@ func genssa(f *ssa.Func, pp *Progs) {
 
 	s.ScratchFpMem = e.scratchFpMem
 
+	var openDeferInfo *obj.LSym
+	if ls := e.curfn.Func.lsym; ls != nil {
+		openDeferInfo = ls.Func.OpenCodedDeferInfo
+	}
+	if openDeferInfo != nil {
+		// This function uses open-coded defers -- write out the funcdata
+		// info that we computed at the end of buildssa.
+		p := pp.Prog(obj.AFUNCDATA)
+		Addrconst(&p.From, objabi.FUNCDATA_OpenCodedDeferInfo)
+		p.To.Type = obj.TYPE_MEM
+		p.To.Name = obj.NAME_EXTERN
+		p.To.Sym = openDeferInfo
+	}
+
 	logLocationLists := Debug_locationlist != 0
 	if Ctxt.Flag_locationlists {
 		e.curfn.Func.DebugInfo = ssa.BuildFuncDebug(f, logLocationLists)
@@ -4665,6 +5128,7 @@ func genssa(f *ssa.Func, pp *Progs) {
 		// Emit values in block
 		thearch.SSAMarkMoves(&s, b)
 		for _, v := range b.Values {
+			s.markUnsafePoint(s.unsafePoints.Get(int32(v.ID)))
 			x := s.pp.next
 			s.DebugFriendlySetPosFrom(v)
 			switch v.Op {
@@ -4733,6 +5197,28 @@ func genssa(f *ssa.Func, pp *Progs) {
 			}
 		}
 	}
+	if openDeferInfo != nil {
+		// Emit the landing pad that a recovered panic jumps to: a
+		// call to deferreturn, which runs any defers not yet run,
+		// followed by a return. The linker records the offset of the
+		// call in the function's metadata. The code is not safe for
+		// asynchronous preemption, since the runtime may be in the
+		// middle of running defers for this frame.
+		s.markUnsafePoint(true)
+		s.SetPos(e.curfn.Func.Endlineno)
+		p := s.Prog(obj.APCDATA)
+		Addrconst(&p.From, objabi.PCDATA_StackMapIndex)
+		Addrconst(&p.To, int64(s.deferreturnIndex))
+		p = s.Prog(obj.ACALL)
+		p.To.Type = obj.TYPE_MEM
+		p.To.Name = obj.NAME_EXTERN
+		p.To.Sym = Deferreturn
+		if s.maxarg < int64(Widthptr) {
+			// deferreturn takes a single pointer-sized argument.
+			s.maxarg = int64(Widthptr)
+		}
+		s.Prog(obj.ARET)
+	}
 
 	if Ctxt.Flag_locationlists {
 		for i := range f.Blocks {
@@ -613,6 +613,21 @@ func eqtype1(t1, t2 *types.Type, cmpTags bool, assumedEqual map[typePair]struct{
 				return false
 			}
 		}
+		i1, i2 := t1.Extra.(*types.Interface), t2.Extra.(*types.Interface)
+		if i1.Comparable != i2.Comparable || len(i1.Unions) != len(i2.Unions) {
+			return false
+		}
+		for i, u1 := range i1.Unions {
+			u2 := i2.Unions[i]
+			if len(u1) != len(u2) {
+				return false
+			}
+			for j, term := range u1 {
+				if term.Tilde != u2[j].Tilde || !eqtype1(term.Type, u2[j].Type, cmpTags, assumedEqual) {
+					return false
+				}
+			}
+		}
 		return true
 
 	case TSTRUCT:
@@ -263,6 +263,11 @@ func typecheck1(n *Node, top int) *Node {
 		// n.Sym is a field/method name, not a variable.
 	default:
 		if n.Sym != nil {
+			if tm := templates[n]; tm != nil {
+				yyerror("cannot use generic %s %v without instantiation", tm.kind(), n.Sym)
+				n.Type = nil
+				return n
+			}
 			if n.Op == ONAME && n.Etype != 0 && top&Ecall == 0 {
 				yyerror("use of builtin %v not in function call", n.Sym)
 				n.Type = nil
@@ -341,6 +346,7 @@ func typecheck1(n *Node, top int) *Node {
 			n.Type = nil
 			return n
 		}
+		checkconstraint(r.Type)
 
 		var t *types.Type
 		if n.Left == nil {
@@ -411,6 +417,8 @@ func typecheck1(n *Node, top int) *Node {
 		if r.Type.NotInHeap() {
 			yyerror("go:notinheap map value not allowed")
 		}
+		checkconstraint(l.Type)
+		checkconstraint(r.Type)
 		n.Op = OTYPE
 		n.Type = types.NewMap(l.Type, r.Type)
 		mapqueue = append(mapqueue, n) // check map keys when all types are settled
@@ -428,6 +436,7 @@ func typecheck1(n *Node, top int) *Node {
 		if l.Type.NotInHeap() {
 			yyerror("chan of go:notinheap type not allowed")
 		}
+		checkconstraint(l.Type)
 		t := types.NewChan(l.Type, types.ChanDir(n.Etype)) // TODO(marvin): Fix Node.EType type union.
 		n.Op = OTYPE
 		n.Type = t
@@ -952,6 +961,7 @@ func typecheck1(n *Node, top int) *Node {
 			if n.Type == nil {
 				return n
 			}
+			checkconstraint(n.Type)
 		}
 
 		if n.Type != nil && !n.Type.IsInterface() {
@@ -975,6 +985,14 @@ func typecheck1(n *Node, top int) *Node {
 		}
 
 	case OINDEX:
+		if tm := templateOf(n.Left); tm != nil {
+			return typecheckinstance(n, tm, top)
+		}
+		if n.Right == nil {
+			yyerror("invalid operation: %v (more than one index)", n)
+			n.Type = nil
+			return n
+		}
 		ok |= Erv
 		n.Left = typecheck(n.Left, Erv)
 		n.Left = defaultlit(n.Left, nil)
@@ -1170,6 +1188,10 @@ func typecheck1(n *Node, top int) *Node {
 
 	// call and call like
 	case OCALL:
+		if !instantiatecall(n) {
+			n.Type = nil
+			return n
+		}
 		n.Left = typecheck(n.Left, Erv|Etype|Ecall)
 		if n.Left.Diag() {
 			n.SetDiag(true)
@@ -1708,6 +1730,7 @@ func typecheck1(n *Node, top int) *Node {
 			n.Type = nil
 			return n
 		}
+		checkconstraint(n.Type)
 		var why string
 		n.Op = convertop(t, n.Type, &why)
 		if n.Op == 0 {
@@ -3038,7 +3061,9 @@ func typecheckcomplit(n *Node) *Node {
 
 				f := t.Field(i)
 				s := f.Sym
-				if s != nil && !exportname(s.Name) && s.Pkg != localpkg {
+				// Instances of imported generic code may use the
+				// unexported fields of the package defining them.
+				if s != nil && !exportname(s.Name) && s.Pkg != localpkg && s.Pkg != curpkg() {
 					yyerror("implicit assignment of unexported field '%s' in %v literal", s.Name, t)
 				}
 				// No pushtype allowed here. Must name fields for that.
@@ -3076,12 +3101,13 @@ func typecheckcomplit(n *Node) *Node {
 					}
 
 					// Sym might have resolved to name in other top-level
-					// package, because of import dot. Redirect to correct sym
-					// before we do the lookup.
+					// package, because of import dot, or be the name in
+					// the package of a generic function. Redirect to
+					// correct sym before we do the lookup.
 					s := key.Sym
 					if s.Pkg != localpkg && exportname(s.Name) {
 						s1 := lookup(s.Name)
-						if s1.Origpkg == s.Pkg {
+						if s1.Origpkg == s.Pkg || s.Pkg == curpkg() {
 							s = s1
 						}
 					}
@@ -3675,6 +3701,7 @@ func typecheckdef(n *Node) {
 				n.SetDiag(true)
 				goto ret
 			}
+			checkconstraint(n.Type)
 		}
 
 		if n.Type != nil {
@@ -388,6 +388,24 @@ func lexinit1() {
 	types.Errortype.Orig = makeErrorInterface()
 	s.Def = asTypesNode(typenod(types.Errortype))
 
+	// any, an alias for interface{}
+	s = builtinpkg.Lookup("any")
+	n := nod(OTYPE, nil, nil)
+	n.Sym = s
+	n.Type = types.Types[TINTER]
+	n.Name = new(Name)
+	n.SetTypecheck(1)
+	s.Def = asTypesNode(n)
+
+	// comparable, the constraint satisfied by comparable types
+	s = builtinpkg.Lookup("comparable")
+	comparabletype := types.New(TINTER)
+	comparabletype.Extra.(*types.Interface).Comparable = true
+	comparabletype.Sym = s
+	comparabletype.Orig = types.New(TINTER)
+	comparabletype.Orig.Extra.(*types.Interface).Comparable = true
+	s.Def = asTypesNode(typenod(comparabletype))
+
 	// We create separate byte and rune types for better error messages
 	// rather than just creating type alias *types.Sym's for the uint8 and
 	// int32 types. Hence, (bytetype|runtype).Sym.isAlias() is false.
@@ -242,6 +242,18 @@ func walkstmt(n *Node) *Node {
 
 	case ODEFER:
 		Curfn.Func.SetHasDefer(true)
+		Curfn.Func.numDefers++
+		if Curfn.Func.numDefers > maxOpenDefers {
+			// Don't allow open-coded defers if there are more than
+			// 8 defers in the function, since we use a single
+			// byte to record active defers.
+			Curfn.Func.SetOpenCodedDeferDisallowed(true)
+		}
+		if n.Esc != EscNever {
+			// If n.Esc is not EscNever, then this defer occurs in a loop,
+			// so open-coded defers cannot be used in this function.
+			Curfn.Func.SetOpenCodedDeferDisallowed(true)
+		}
 		switch n.Left.Op {
 		case OPRINT, OPRINTN:
 			n.Left = walkprintfunc(n.Left, &n.Ninit)
@@ -289,6 +301,7 @@ func walkstmt(n *Node) *Node {
 		adjustargs(n, 2*Widthptr)
 
 	case ORETURN:
+		Curfn.Func.numReturns++
 		walkexprlist(n.List.Slice(), &n.Ninit)
 		if n.List.Len() == 0 {
 			break
@@ -1474,7 +1487,7 @@ opswitch:
 				b := nod(OADDR, bv, nil)
 
 				// h.buckets = b
-				bsym := hmapType.Field(5).Sym // hmap.buckets see reflect.go:hmap
+				bsym := hmapType.Field(4).Sym // hmap.buckets see reflect.go:hmap
 				na := nod(OAS, nodSym(ODOT, h, bsym), b)
 				na = typecheck(na, Etop)
 				init.Append(na)
@@ -1486,7 +1499,7 @@ opswitch:
 			// make(map[any]any, hint) where hint <= BUCKETSIZE
 			// special allows for faster map initialization and
 			// improves binary size by using calls with fewer arguments.
-			// For hint <= BUCKETSIZE overLoadFactor(hint, 0) is false
+			// For hint <= BUCKETSIZE a single bucket holds all entries
 			// and no buckets will be allocated by makemap. Therefore,
 			// no buckets need to be allocated in this code path.
 			if n.Esc == EscNone {
@@ -1494,7 +1507,7 @@ opswitch:
 				// hmap h has been allocated on the stack already.
 				// h.hash0 = fastrand()
 				rand := mkcall("fastrand", types.Types[TUINT32], init)
-				hashsym := hmapType.Field(4).Sym // hmap.hash0 see reflect.go:hmap
+				hashsym := hmapType.Field(3).Sym // hmap.hash0 see reflect.go:hmap
 				a := nod(OAS, nodSym(ODOT, h, hashsym), rand)
 				a = typecheck(a, Etop)
 				a = walkexpr(a, init)
@@ -0,0 +1,250 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pgo reads the CPU profiles used for profile-guided
+// optimization.
+//
+// The profile identifies functions by their symbol names, such as
+// "main.(*T).M", and call sites by their line numbers, so it stays
+// useful only as long as the code it was collected from does not
+// change much. Call sites and functions the profile does not know
+// are simply cold.
+package pgo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// A CallSite is a call made from the function Caller, at line Line of
+// its source file, to the function Callee.
+type CallSite struct {
+	Caller string
+	Line   int
+	Callee string
+}
+
+// A Line is a line of the source of the function Func.
+type Line struct {
+	Func string
+	Line int
+}
+
+// A Profile holds the weights of the calls and lines found in the
+// samples of a CPU profile. The weight of a call or a line is the
+// total value of the samples with it in their stack; a sample
+// counts once even if the call or the line appears more than once
+// in its stack.
+type Profile struct {
+	// TotalWeight is the total value of the samples.
+	TotalWeight int64
+
+	// EdgeWeight holds the weight of each call.
+	EdgeWeight map[CallSite]int64
+
+	// LineWeight holds the weight of each line.
+	LineWeight map[Line]int64
+
+	// FuncWeight holds the weight of each function.
+	FuncWeight map[string]int64
+
+	// HotThreshold is the weight from which a call is hot: the
+	// hottest calls, with a weight of at least HotThreshold, make up
+	// the requested share of the total weight of the calls.
+	HotThreshold int64
+
+	hotCallees map[string]bool
+	callees    map[Line][]string // the hot callees of each call site, hottest first
+}
+
+// New reads the CPU profile in file. The calls making up
+// cdfThreshold percent of the total weight of the calls are hot.
+func New(file string, cdfThreshold int) (*Profile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := parseProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	p, err := newProfile(raw, cdfThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return p, nil
+}
+
+// frame is a function and line in the stack of a sample.
+type frame struct {
+	fn   string
+	line int
+}
+
+func newProfile(raw *rawProfile, cdfThreshold int) (*Profile, error) {
+	// Weigh samples by count, or else by CPU time.
+	index := -1
+	for i, vt := range raw.sampleTypes {
+		typ, unit := raw.str(vt.typ), raw.str(vt.unit)
+		if typ == "samples" && unit == "count" {
+			index = i
+			break
+		}
+		if typ == "cpu" && unit == "nanoseconds" {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("not a CPU profile")
+	}
+
+	p := &Profile{
+		EdgeWeight: make(map[CallSite]int64),
+		LineWeight: make(map[Line]int64),
+		FuncWeight: make(map[string]int64),
+		hotCallees: make(map[string]bool),
+		callees:    make(map[Line][]string),
+	}
+	var frames []frame
+	seenEdges := make(map[CallSite]bool)
+	seenLines := make(map[Line]bool)
+	seenFuncs := make(map[string]bool)
+	for _, s := range raw.samples {
+		if index >= len(s.values) {
+			return nil, errCorrupt
+		}
+		w := s.values[index]
+		if w <= 0 {
+			continue
+		}
+		p.TotalWeight += w
+
+		// Leaf first. The lines of a location are already
+		// ordered innermost first.
+		frames = frames[:0]
+		for _, id := range s.locations {
+			for _, l := range raw.locations[id] {
+				fn, ok := raw.functions[l.function]
+				if !ok {
+					return nil, errCorrupt
+				}
+				frames = append(frames, frame{fn, int(l.line)})
+			}
+		}
+		for k := range seenEdges {
+			delete(seenEdges, k)
+		}
+		for k := range seenLines {
+			delete(seenLines, k)
+		}
+		for k := range seenFuncs {
+			delete(seenFuncs, k)
+		}
+		for i, f := range frames {
+			if l := (Line{f.fn, f.line}); !seenLines[l] {
+				seenLines[l] = true
+				p.LineWeight[l] += w
+			}
+			if !seenFuncs[f.fn] {
+				seenFuncs[f.fn] = true
+				p.FuncWeight[f.fn] += w
+			}
+			if i == 0 {
+				continue
+			}
+			if e := (CallSite{f.fn, f.line, frames[i-1].fn}); !seenEdges[e] {
+				seenEdges[e] = true
+				p.EdgeWeight[e] += w
+			}
+		}
+	}
+
+	p.findHot(cdfThreshold)
+	return p, nil
+}
+
+// findHot sets the hot threshold of p and records the hot calls.
+func (p *Profile) findHot(cdfThreshold int) {
+	edges := make([]CallSite, 0, len(p.EdgeWeight))
+	var total int64
+	for e, w := range p.EdgeWeight {
+		edges = append(edges, e)
+		total += w
+	}
+	sort.Sort(byWeight{edges, p.EdgeWeight})
+	var cum int64
+	for _, e := range edges {
+		if cum*100 >= total*int64(cdfThreshold) {
+			break
+		}
+		w := p.EdgeWeight[e]
+		cum += w
+		p.HotThreshold = w
+	}
+	if p.HotThreshold == 0 {
+		return
+	}
+	for _, e := range edges {
+		if p.EdgeWeight[e] < p.HotThreshold {
+			break
+		}
+		p.hotCallees[e.Callee] = true
+		l := Line{e.Caller, e.Line}
+		p.callees[l] = append(p.callees[l], e.Callee)
+	}
+}
+
+// IsHotCallSite reports whether the call from caller to callee at
+// line is hot.
+func (p *Profile) IsHotCallSite(caller string, line int, callee string) bool {
+	return p.HotThreshold > 0 && p.EdgeWeight[CallSite{caller, line, callee}] >= p.HotThreshold
+}
+
+// IsHotCallee reports whether any hot call calls fn.
+func (p *Profile) IsHotCallee(fn string) bool {
+	return p.hotCallees[fn]
+}
+
+// IsHotFunc reports whether fn is at least as hot as a hot call.
+func (p *Profile) IsHotFunc(fn string) bool {
+	return p.HotThreshold > 0 && p.FuncWeight[fn] >= p.HotThreshold
+}
+
+// HotCallees returns the functions called by the hot calls made from
+// caller at line, hottest first. The result must not be modified.
+func (p *Profile) HotCallees(caller string, line int) []string {
+	return p.callees[Line{caller, line}]
+}
+
+// str returns the string with index i in the string table.
+func (raw *rawProfile) str(i int64) string {
+	if i < 0 || i >= int64(len(raw.strings)) {
+		return ""
+	}
+	return raw.strings[i]
+}
+
+// byWeight sorts calls by decreasing weight, and then by caller,
+// line and callee, to keep the compiler output deterministic.
+type byWeight struct {
+	edges  []CallSite
+	weight map[CallSite]int64
+}
+
+func (x byWeight) Len() int      { return len(x.edges) }
+func (x byWeight) Swap(i, j int) { x.edges[i], x.edges[j] = x.edges[j], x.edges[i] }
+func (x byWeight) Less(i, j int) bool {
+	a, b := x.edges[i], x.edges[j]
+	if wa, wb := x.weight[a], x.weight[b]; wa != wb {
+		return wa > wb
+	}
+	if a.Caller != b.Caller {
+		return a.Caller < b.Caller
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Callee < b.Callee
+}
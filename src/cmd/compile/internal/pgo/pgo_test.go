@@ -0,0 +1,174 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pgo
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// stack is a sample: its frames, leaf first, as function:line pairs,
+// and its count. The frames of one location are grouped in a slice,
+// innermost first.
+type stack struct {
+	locs  [][]frame
+	count int64
+}
+
+// makeProfile returns an encoded CPU profile of the stacks, gzipped
+// or not.
+func makeProfile(stacks []stack, gzipped bool) []byte {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     1e7,
+	}
+	funcs := make(map[string]*profile.Function)
+	for _, s := range stacks {
+		var locs []*profile.Location
+		for _, frames := range s.locs {
+			loc := &profile.Location{ID: uint64(len(p.Location) + 1)}
+			for _, f := range frames {
+				fn := funcs[f.fn]
+				if fn == nil {
+					fn = &profile.Function{ID: uint64(len(p.Function) + 1), Name: f.fn, SystemName: f.fn}
+					funcs[f.fn] = fn
+					p.Function = append(p.Function, fn)
+				}
+				loc.Line = append(loc.Line, profile.Line{Function: fn, Line: int64(f.line)})
+			}
+			p.Location = append(p.Location, loc)
+			locs = append(locs, loc)
+		}
+		p.Sample = append(p.Sample, &profile.Sample{
+			Location: locs,
+			Value:    []int64{s.count, s.count * 1e7},
+		})
+	}
+	var buf bytes.Buffer
+	if gzipped {
+		p.Write(&buf)
+	} else {
+		p.WriteUncompressed(&buf)
+	}
+	return buf.Bytes()
+}
+
+var testStacks = []stack{
+	// main.main:10 calls main.hot, which calls main.leaf at line 20,
+	// inlined at line 21.
+	{[][]frame{{{"main.leaf", 30}, {"main.hot", 21}}, {{"main.main", 10}}}, 90},
+	{[][]frame{{{"main.hot", 22}}, {{"main.main", 10}}}, 5},
+	// main.main:11 calls main.cold.
+	{[][]frame{{{"main.cold", 40}}, {{"main.main", 11}}}, 4},
+	// main.rec recursing.
+	{[][]frame{{{"main.rec", 50}}, {{"main.rec", 51}}, {{"main.rec", 51}}, {{"main.main", 12}}}, 1},
+}
+
+func testProfile(t *testing.T, gzipped bool, cdfThreshold int) *Profile {
+	raw, err := parseProfile(makeProfile(testStacks, gzipped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := newProfile(raw, cdfThreshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProfile(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		p := testProfile(t, gzipped, 90)
+		if p.TotalWeight != 100 {
+			t.Errorf("TotalWeight = %d, want 100", p.TotalWeight)
+		}
+		wantEdges := map[CallSite]int64{
+			{"main.hot", 21, "main.leaf"}:  90,
+			{"main.main", 10, "main.hot"}:  95,
+			{"main.main", 11, "main.cold"}: 4,
+			{"main.rec", 51, "main.rec"}:   1,
+			{"main.main", 12, "main.rec"}:  1,
+		}
+		if !reflect.DeepEqual(p.EdgeWeight, wantEdges) {
+			t.Errorf("EdgeWeight = %v, want %v", p.EdgeWeight, wantEdges)
+		}
+		for l, want := range map[Line]int64{
+			{"main.main", 10}: 95,
+			{"main.hot", 21}:  90,
+			{"main.hot", 22}:  5,
+			{"main.rec", 51}:  1,
+		} {
+			if got := p.LineWeight[l]; got != want {
+				t.Errorf("LineWeight[%v] = %d, want %d", l, got, want)
+			}
+		}
+		if got := p.FuncWeight["main.rec"]; got != 1 {
+			t.Errorf("FuncWeight[main.rec] = %d, want 1", got)
+		}
+
+		// The two hottest calls make up 185 of 191.
+		if p.HotThreshold != 90 {
+			t.Errorf("HotThreshold = %d, want 90", p.HotThreshold)
+		}
+		if !p.IsHotCallSite("main.main", 10, "main.hot") || !p.IsHotCallSite("main.hot", 21, "main.leaf") {
+			t.Errorf("hottest calls are not hot")
+		}
+		if p.IsHotCallSite("main.main", 11, "main.cold") || p.IsHotCallSite("main.main", 10, "main.cold") {
+			t.Errorf("cold call is hot")
+		}
+		if !p.IsHotCallee("main.leaf") || p.IsHotCallee("main.cold") {
+			t.Errorf("IsHotCallee(main.leaf), IsHotCallee(main.cold) = %v, %v, want true, false",
+				p.IsHotCallee("main.leaf"), p.IsHotCallee("main.cold"))
+		}
+		if !p.IsHotFunc("main.hot") || p.IsHotFunc("main.cold") {
+			t.Errorf("IsHotFunc(main.hot), IsHotFunc(main.cold) = %v, %v, want true, false",
+				p.IsHotFunc("main.hot"), p.IsHotFunc("main.cold"))
+		}
+		if got, want := p.HotCallees("main.main", 10), []string{"main.hot"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("HotCallees(main.main, 10) = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestProfileThreshold(t *testing.T) {
+	p := testProfile(t, true, 100)
+	if p.HotThreshold != 1 || !p.IsHotCallSite("main.main", 11, "main.cold") {
+		t.Errorf("with a 100%% threshold, HotThreshold = %d, want 1", p.HotThreshold)
+	}
+	p = testProfile(t, true, 0)
+	if p.HotThreshold != 0 || p.IsHotCallSite("main.main", 10, "main.hot") {
+		t.Errorf("with a 0%% threshold, HotThreshold = %d, want 0", p.HotThreshold)
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	if _, err := parseProfile([]byte("not a profile")); err == nil {
+		t.Errorf("parsing garbage succeeded")
+	}
+	good := makeProfile(testStacks, false)
+	if _, err := parseProfile(good[:len(good)-1]); err == nil {
+		t.Errorf("parsing truncated profile succeeded")
+	}
+
+	heap := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "alloc_space", Unit: "bytes"}},
+	}
+	var buf bytes.Buffer
+	heap.Write(&buf)
+	raw, err := parseProfile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newProfile(raw, 99); err == nil {
+		t.Errorf("heap profile accepted")
+	}
+}
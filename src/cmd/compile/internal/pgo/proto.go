@@ -0,0 +1,276 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// This file decodes the parts of a profile.proto message
+// (see github.com/google/pprof/proto/profile.proto) that the
+// compiler uses. It is written by hand, rather than using the pprof
+// profile package, because the compiler must build with Go 1.4.
+
+// A rawProfile is a decoded profile.proto message.
+type rawProfile struct {
+	sampleTypes []valueType
+	samples     []rawSample
+	locations   map[uint64][]rawLine
+	functions   map[uint64]string
+	strings     []string
+}
+
+type valueType struct {
+	typ, unit int64 // string table indexes
+}
+
+type rawSample struct {
+	locations []uint64
+	values    []int64
+}
+
+// A rawLine is one entry of a Location. Inlined calls expand a
+// Location to several lines, innermost first.
+type rawLine struct {
+	function uint64
+	line     int64
+}
+
+// Tags of the fields of the messages.
+const (
+	tagProfile_SampleType  = 1
+	tagProfile_Sample      = 2
+	tagProfile_Location    = 4
+	tagProfile_Function    = 5
+	tagProfile_StringTable = 6
+
+	tagValueType_Type = 1
+	tagValueType_Unit = 2
+
+	tagSample_Location = 1
+	tagSample_Value    = 2
+
+	tagLocation_ID   = 1
+	tagLocation_Line = 4
+
+	tagLine_FunctionID = 1
+	tagLine_Line       = 2
+
+	tagFunction_ID   = 1
+	tagFunction_Name = 2
+)
+
+// Wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errCorrupt = errors.New("malformed profile")
+
+// parseProfile decodes data, which holds a profile.proto message,
+// compressed with gzip or not.
+func parseProfile(data []byte) (*rawProfile, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = ioutil.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("decompressing profile: %v", err)
+		}
+	}
+	p := &rawProfile{
+		locations: make(map[uint64][]rawLine),
+		functions: make(map[uint64]string),
+	}
+	fnames := make(map[uint64]int64)
+	err := decodeMessage(data, func(tag int, b buffer) error {
+		switch tag {
+		case tagProfile_SampleType:
+			var vt valueType
+			err := decodeMessage(b.bytes, func(tag int, b buffer) error {
+				switch tag {
+				case tagValueType_Type:
+					vt.typ = int64(b.u64)
+				case tagValueType_Unit:
+					vt.unit = int64(b.u64)
+				}
+				return nil
+			})
+			p.sampleTypes = append(p.sampleTypes, vt)
+			return err
+		case tagProfile_Sample:
+			var s rawSample
+			err := decodeMessage(b.bytes, func(tag int, b buffer) error {
+				switch tag {
+				case tagSample_Location:
+					return b.uint64s(&s.locations)
+				case tagSample_Value:
+					var v []uint64
+					if err := b.uint64s(&v); err != nil {
+						return err
+					}
+					for _, x := range v {
+						s.values = append(s.values, int64(x))
+					}
+				}
+				return nil
+			})
+			p.samples = append(p.samples, s)
+			return err
+		case tagProfile_Location:
+			var id uint64
+			var lines []rawLine
+			err := decodeMessage(b.bytes, func(tag int, b buffer) error {
+				switch tag {
+				case tagLocation_ID:
+					id = b.u64
+				case tagLocation_Line:
+					var l rawLine
+					err := decodeMessage(b.bytes, func(tag int, b buffer) error {
+						switch tag {
+						case tagLine_FunctionID:
+							l.function = b.u64
+						case tagLine_Line:
+							l.line = int64(b.u64)
+						}
+						return nil
+					})
+					lines = append(lines, l)
+					return err
+				}
+				return nil
+			})
+			p.locations[id] = lines
+			return err
+		case tagProfile_Function:
+			var id uint64
+			var name int64
+			err := decodeMessage(b.bytes, func(tag int, b buffer) error {
+				switch tag {
+				case tagFunction_ID:
+					id = b.u64
+				case tagFunction_Name:
+					name = int64(b.u64)
+				}
+				return nil
+			})
+			fnames[id] = name
+			return err
+		case tagProfile_StringTable:
+			if b.wire != wireBytes {
+				return errCorrupt
+			}
+			p.strings = append(p.strings, string(b.bytes))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id, name := range fnames {
+		if name < 0 || name >= int64(len(p.strings)) {
+			return nil, errCorrupt
+		}
+		p.functions[id] = p.strings[name]
+	}
+	return p, nil
+}
+
+// A buffer is the value of one field: u64 for the numeric wire
+// types, bytes for the length-delimited one.
+type buffer struct {
+	wire  int
+	u64   uint64
+	bytes []byte
+}
+
+// uint64s appends the values of the repeated field b, packed or not,
+// to *x.
+func (b buffer) uint64s(x *[]uint64) error {
+	if b.wire != wireBytes {
+		*x = append(*x, b.u64)
+		return nil
+	}
+	data := b.bytes
+	for len(data) > 0 {
+		u, n := decodeVarint(data)
+		if n == 0 {
+			return errCorrupt
+		}
+		*x = append(*x, u)
+		data = data[n:]
+	}
+	return nil
+}
+
+// decodeMessage calls f for each field of the message in data.
+func decodeMessage(data []byte, f func(tag int, b buffer) error) error {
+	for len(data) > 0 {
+		key, n := decodeVarint(data)
+		if n == 0 {
+			return errCorrupt
+		}
+		data = data[n:]
+		b := buffer{wire: int(key & 7)}
+		switch b.wire {
+		case wireVarint:
+			if b.u64, n = decodeVarint(data); n == 0 {
+				return errCorrupt
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errCorrupt
+			}
+			for i := uint(0); i < 8; i++ {
+				b.u64 |= uint64(data[i]) << (8 * i)
+			}
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errCorrupt
+			}
+			for i := uint(0); i < 4; i++ {
+				b.u64 |= uint64(data[i]) << (8 * i)
+			}
+			data = data[4:]
+		case wireBytes:
+			l, n := decodeVarint(data)
+			if n == 0 || uint64(len(data)-n) < l {
+				return errCorrupt
+			}
+			b.bytes = data[n : n+int(l)]
+			data = data[n+int(l):]
+		default:
+			return errCorrupt
+		}
+		if err := f(int(key>>3), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeVarint decodes the varint at the start of data. It returns
+// the value and the number of bytes read, or 0 if data does not start
+// with a valid varint.
+func decodeVarint(data []byte) (uint64, int) {
+	var u uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		u |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i]&0x80 == 0 {
+			return u, i + 1
+		}
+	}
+	return 0, 0
+}
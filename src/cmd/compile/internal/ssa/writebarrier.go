@@ -173,6 +173,10 @@ func writebarrier(f *Func) {
 		b.Succs = b.Succs[:0]
 		b.AddEdgeTo(bThen)
 		b.AddEdgeTo(bElse)
+		// Record that this block branches on the write barrier
+		// flag, so liveness can keep the goroutine from being
+		// asynchronously preempted in the middle of the sequence.
+		f.WBLoads = append(f.WBLoads, b)
 		// TODO: For OpStoreWB and the buffered write barrier,
 		// we could move the write out of the write barrier,
 		// which would lead to fewer branches. We could do
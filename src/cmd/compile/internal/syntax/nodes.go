@@ -72,12 +72,14 @@ type (
 	}
 
 	// Name Type
+	// Name [TParamList] Type
 	TypeDecl struct {
-		Name   *Name
-		Alias  bool
-		Type   Expr
-		Group  *Group // nil means not part of a group
-		Pragma Pragma
+		Name       *Name
+		TParamList []*Field // nil means no type parameters
+		Alias      bool
+		Type       Expr
+		Group      *Group // nil means not part of a group
+		Pragma     Pragma
 		decl
 	}
 
@@ -92,17 +94,18 @@ type (
 		decl
 	}
 
-	// func          Name Type { Body }
-	// func          Name Type
+	// func          Name [TParamList] Type { Body }
+	// func          Name [TParamList] Type
 	// func Receiver Name Type { Body }
 	// func Receiver Name Type
 	FuncDecl struct {
-		Attr   map[string]bool // go:attr map
-		Recv   *Field          // nil means regular function
-		Name   *Name
-		Type   *FuncType
-		Body   *BlockStmt // nil means no body (forward declaration)
-		Pragma Pragma     // TODO(mdempsky): Cleaner solution.
+		Attr       map[string]bool // go:attr map
+		Recv       *Field          // nil means regular function
+		Name       *Name
+		TParamList []*Field // nil means no type parameters
+		Type       *FuncType
+		Body       *BlockStmt // nil means no body (forward declaration)
+		Pragma     Pragma     // TODO(mdempsky): Cleaner solution.
 		decl
 	}
 )
@@ -180,6 +183,7 @@ type (
 	}
 
 	// X[Index]
+	// X[T1, T2, ...] (with Ti = Index.(*ListExpr).ElemList[i])
 	IndexExpr struct {
 		X     Expr
 		Index Expr
@@ -256,12 +260,16 @@ type (
 	// Name Type
 	//      Type
 	Field struct {
-		Name *Name // nil means anonymous field/parameter (structs/parameters), or embedded interface (interfaces)
+		Name *Name // nil means anonymous field/parameter (structs/parameters), or embedded element (interfaces)
 		Type Expr  // field names declared in a list share the same Type (identical pointers)
 		node
 	}
 
 	// interface { MethodList[0]; MethodList[1]; ... }
+	//
+	// An embedded element of an interface is a type, or a union
+	// of terms T1 | T2 | ..., each a type or ~Type, represented by
+	// Operations with Op Or and Tilde.
 	InterfaceType struct {
 		MethodList []*Field
 		expr
@@ -457,7 +457,7 @@ func (p *parser) constDecl(group *Group) Decl {
 	return d
 }
 
-// TypeSpec = identifier [ "=" ] Type .
+// TypeSpec = identifier [ TypeParams ] [ "=" ] Type .
 func (p *parser) typeDecl(group *Group) Decl {
 	if trace {
 		defer p.trace("typeDecl")()
@@ -467,8 +467,36 @@ func (p *parser) typeDecl(group *Group) Decl {
 	d.pos = p.pos()
 
 	d.Name = p.name()
-	d.Alias = p.got(_Assign)
-	d.Type = p.typeOrNil()
+	if p.tok == _Lbrack {
+		// d.Name "[" ...
+		// array or slice type, or type parameter list
+		pos := p.pos()
+		p.next()
+		if p.tok == _Name {
+			// A name followed by a token that may start a type
+			// constraint begins a type parameter list. Otherwise
+			// the name begins the length of an array type.
+			name := p.name()
+			if p.startsConstraint() {
+				d.TParamList = p.typeParamList(name)
+				if p.tok == _Assign {
+					p.syntax_error("generic type cannot be alias")
+					p.next()
+				}
+				d.Type = p.typeOrNil()
+			} else {
+				p.xnest++
+				x := p.binaryExpr(p.pexpr(name, false), 0)
+				p.xnest--
+				d.Type = p.arrayType(pos, x)
+			}
+		} else {
+			d.Type = p.arrayType(pos, nil)
+		}
+	} else {
+		d.Alias = p.got(_Assign)
+		d.Type = p.typeOrNil()
+	}
 	if d.Type == nil {
 		d.Type = p.bad()
 		p.syntax_error("in type declaration")
@@ -536,6 +564,19 @@ func (p *parser) funcDeclOrNil() *FuncDecl {
 	}
 
 	f.Name = p.name()
+	if p.tok == _Lbrack {
+		pos := p.pos()
+		p.next()
+		if p.tok == _Rbrack {
+			p.syntax_error("empty type parameter list")
+			p.next()
+		} else {
+			f.TParamList = p.typeParamList(nil)
+			if f.Recv != nil {
+				p.error_at(pos, "method must have no type parameters")
+			}
+		}
+	}
 	f.Type = p.funcType()
 	if p.tok == _Lbrace {
 		f.Body = p.funcBody()
@@ -569,14 +610,18 @@ func (p *parser) expr() Expr {
 		defer p.trace("expr")()
 	}
 
-	return p.binaryExpr(0)
+	return p.binaryExpr(nil, 0)
 }
 
 // Expression = UnaryExpr | Expression binary_op Expression .
-func (p *parser) binaryExpr(prec int) Expr {
+//
+// If x is not nil, it is the already parsed first unary expression.
+func (p *parser) binaryExpr(x Expr, prec int) Expr {
 	// don't trace binaryExpr - only leads to overly nested trace output
 
-	x := p.unaryExpr()
+	if x == nil {
+		x = p.unaryExpr()
+	}
 	for (p.tok == _Operator || p.tok == _Star) && p.prec > prec {
 		t := new(Operation)
 		t.pos = p.pos()
@@ -584,7 +629,7 @@ func (p *parser) binaryExpr(prec int) Expr {
 		t.X = x
 		tprec := p.prec
 		p.next()
-		t.Y = p.binaryExpr(tprec)
+		t.Y = p.binaryExpr(nil, tprec)
 		x = t
 	}
 	return x
@@ -607,6 +652,15 @@ func (p *parser) unaryExpr() Expr {
 			x.X = p.unaryExpr()
 			return x
 
+		case Tilde:
+			p.error("cannot use ~ outside of interface or type constraint (use ^ for bitwise complement)")
+			x := new(Operation)
+			x.pos = p.pos()
+			x.Op = Xor
+			p.next()
+			x.X = p.unaryExpr()
+			return x
+
 		case And:
 			x := new(Operation)
 			x.pos = p.pos()
@@ -679,7 +733,7 @@ func (p *parser) unaryExpr() Expr {
 	// TODO(mdempsky): We need parens here so we can report an
 	// error for "(x) := true". It should be possible to detect
 	// and reject that more efficiently though.
-	return p.pexpr(true)
+	return p.pexpr(nil, true)
 }
 
 // callStmt parses call-like statements that can be preceded by 'defer' and 'go'.
@@ -693,7 +747,7 @@ func (p *parser) callStmt() *CallStmt {
 	s.Tok = p.tok // _Defer or _Go
 	p.next()
 
-	x := p.pexpr(p.tok == _Lparen) // keep_parens so we can report error below
+	x := p.pexpr(nil, p.tok == _Lparen) // keep_parens so we can report error below
 	if t := unparen(x); t != x {
 		p.error(fmt.Sprintf("expression in %s must not be parenthesized", s.Tok))
 		// already progressed, no need to advance
@@ -815,12 +869,16 @@ func (p *parser) operand(keep_parens bool) Expr {
 //                  "]" .
 // TypeAssertion  = "." "(" Type ")" .
 // Arguments      = "(" [ ( ExpressionList | Type [ "," ExpressionList ] ) [ "..." ] [ "," ] ] ")" .
-func (p *parser) pexpr(keep_parens bool) Expr {
+//
+// If x is not nil, it is the already parsed operand.
+func (p *parser) pexpr(x Expr, keep_parens bool) Expr {
 	if trace {
 		defer p.trace("pexpr")()
 	}
 
-	x := p.operand(keep_parens)
+	if x == nil {
+		x = p.operand(keep_parens)
+	}
 
 loop:
 	for {
@@ -865,6 +923,10 @@ loop:
 			var i Expr
 			if p.tok != _Colon {
 				i = p.expr()
+				if p.tok == _Comma {
+					// x[i, j, ...]: instantiation with several type arguments
+					i = p.typeArgs(i)
+				}
 				if p.got(_Rbrack) {
 					// x[i]
 					t := new(IndexExpr)
@@ -875,6 +937,13 @@ loop:
 					p.xnest--
 					break
 				}
+				if _, ok := i.(*ListExpr); ok {
+					p.syntax_error("expecting ]")
+					p.advance(_Rbrack)
+					p.got(_Rbrack)
+					p.xnest--
+					break
+				}
 			}
 
 			// x[i:...
@@ -918,12 +987,17 @@ loop:
 			t := unparen(x)
 			// determine if '{' belongs to a composite literal or a block statement
 			complit_ok := false
-			switch t.(type) {
+			switch t := t.(type) {
 			case *Name, *SelectorExpr:
 				if p.xnest >= 0 {
 					// x is considered a composite literal type
 					complit_ok = true
 				}
+			case *IndexExpr:
+				if p.xnest >= 0 && isTypeName(t.X) {
+					// x is possibly an instantiated generic type
+					complit_ok = true
+				}
 			case *ArrayType, *SliceType, *StructType, *MapType:
 				// x is a comptype
 				complit_ok = true
@@ -1056,26 +1130,7 @@ func (p *parser) typeOrNil() Expr {
 		// '[' oexpr ']' ntype
 		// '[' _DotDotDot ']' ntype
 		p.next()
-		p.xnest++
-		if p.got(_Rbrack) {
-			// []T
-			p.xnest--
-			t := new(SliceType)
-			t.pos = pos
-			t.Elem = p.type_()
-			return t
-		}
-
-		// [n]T
-		t := new(ArrayType)
-		t.pos = pos
-		if !p.got(_DotDotDot) {
-			t.Len = p.expr()
-		}
-		p.want(_Rbrack)
-		p.xnest--
-		t.Elem = p.type_()
-		return t
+		return p.arrayType(pos, nil)
 
 	case _Chan:
 		// _Chan non_recvchantype
@@ -1107,7 +1162,7 @@ func (p *parser) typeOrNil() Expr {
 		return p.interfaceType()
 
 	case _Name:
-		return p.dotname(p.name())
+		return p.typeInstance(p.dotname(p.name()))
 
 	case _Lparen:
 		p.next()
@@ -1119,6 +1174,248 @@ func (p *parser) typeOrNil() Expr {
 	return nil
 }
 
+// arrayType parses the rest of an array or slice type after the "[",
+// at pos. If len is not nil, it is the already parsed array length.
+//
+// ArrayType = "[" ArrayLength "]" ElementType .
+// SliceType = "[" "]" ElementType .
+func (p *parser) arrayType(pos src.Pos, len Expr) Expr {
+	if trace {
+		defer p.trace("arrayType")()
+	}
+
+	p.xnest++
+	if len == nil && p.got(_Rbrack) {
+		// []T
+		p.xnest--
+		t := new(SliceType)
+		t.pos = pos
+		t.Elem = p.type_()
+		return t
+	}
+
+	// [n]T
+	t := new(ArrayType)
+	t.pos = pos
+	if len != nil {
+		t.Len = len
+	} else if !p.got(_DotDotDot) {
+		t.Len = p.expr()
+	}
+	p.want(_Rbrack)
+	p.xnest--
+	t.Elem = p.type_()
+	return t
+}
+
+// typeInstance parses the type arguments of the generic type x, if
+// any, and returns x instantiated with them.
+//
+// TypeArgs = "[" TypeList [ "," ] "]" .
+func (p *parser) typeInstance(x Expr) Expr {
+	if p.tok != _Lbrack {
+		return x
+	}
+	if trace {
+		defer p.trace("typeInstance")()
+	}
+
+	t := new(IndexExpr)
+	t.pos = p.pos()
+	t.X = x
+	p.next()
+	p.xnest++
+	t.Index = p.typeArgs(p.type_())
+	p.xnest--
+	p.want(_Rbrack)
+	return t
+}
+
+// typeArgs parses the type arguments that follow x, the first one,
+// up to the closing "]". It returns x if it is the only one, or a
+// *ListExpr of all of them.
+func (p *parser) typeArgs(x Expr) Expr {
+	list := []Expr{x}
+	for p.got(_Comma) && p.tok != _Rbrack {
+		list = append(list, p.type_())
+	}
+	if len(list) == 1 {
+		return x
+	}
+	t := new(ListExpr)
+	t.pos = x.Pos()
+	t.ElemList = list
+	return t
+}
+
+// arrayOrTypeArgs parses what follows a name in a parameter or field
+// declaration if it starts with "[": either an array or slice type,
+// with the name as the parameter or field name, or the type
+// arguments of the name, a generic type. In the latter case it
+// returns a *ListExpr of the type arguments, possibly of just one.
+func (p *parser) arrayOrTypeArgs() Expr {
+	if trace {
+		defer p.trace("arrayOrTypeArgs")()
+	}
+
+	pos := p.pos()
+	p.want(_Lbrack)
+	if p.tok == _Rbrack || p.tok == _DotDotDot {
+		return p.arrayType(pos, nil)
+	}
+
+	p.xnest++
+	var list []Expr
+	for {
+		list = append(list, p.expr())
+		if !p.got(_Comma) || p.tok == _Rbrack {
+			break
+		}
+	}
+	p.xnest--
+	p.want(_Rbrack)
+
+	if len(list) == 1 && p.startsType() {
+		// [n]T
+		t := new(ArrayType)
+		t.pos = pos
+		t.Len = list[0]
+		t.Elem = p.type_()
+		return t
+	}
+
+	t := new(ListExpr)
+	t.pos = pos
+	t.ElemList = list
+	return t
+}
+
+// startsType reports whether the current token may start a type.
+func (p *parser) startsType() bool {
+	switch p.tok {
+	case _Name, _Star, _Arrow, _Func, _Lbrack, _Chan, _Map, _Struct, _Interface, _Lparen:
+		return true
+	}
+	return false
+}
+
+// startsConstraint reports whether the current token, following the
+// first name after "[" in a type declaration, may start the constraint
+// of a type parameter, or the rest of a type parameter list, rather
+// than continue an array length expression.
+//
+// A "*" continues the expression: type T[P *C] ... declares an array.
+func (p *parser) startsConstraint() bool {
+	switch p.tok {
+	case _Name, _Comma, _Arrow, _Func, _Lbrack, _Chan, _Map, _Struct, _Interface:
+		return true
+	case _Operator:
+		return p.op == Tilde
+	}
+	return false
+}
+
+// typeParamList parses a type parameter list after the "[" up to and
+// including the closing "]". If first is not nil, it is the already
+// parsed name of the first type parameter.
+//
+// TypeParams    = "[" TypeParamList [ "," ] "]" .
+// TypeParamList = TypeParamDecl { "," TypeParamDecl } .
+// TypeParamDecl = IdentifierList TypeConstraint .
+func (p *parser) typeParamList(first *Name) (list []*Field) {
+	if trace {
+		defer p.trace("typeParamList")()
+	}
+
+	var names []*Name // names without a constraint yet
+	for {
+		var name *Name
+		if first != nil {
+			name, first = first, nil
+		} else if p.tok == _Name {
+			name = p.name()
+		} else {
+			p.syntax_error("expecting type parameter name")
+			p.advance(_Rbrack)
+			break
+		}
+		names = append(names, name)
+		if p.tok != _Comma && p.tok != _Rbrack {
+			// names constraint
+			typ := p.constraint()
+			for _, name := range names {
+				f := new(Field)
+				f.pos = name.Pos()
+				f.Name = name
+				f.Type = typ // shared, as for other fields
+				list = append(list, f)
+			}
+			names = nil
+		}
+		if !p.got(_Comma) || p.tok == _Rbrack {
+			break
+		}
+	}
+	if len(names) > 0 {
+		p.syntax_error_at(names[len(names)-1].Pos(), "missing type constraint")
+		for _, name := range names {
+			f := new(Field)
+			f.pos = name.Pos()
+			f.Name = name
+			f.Type = p.bad()
+			list = append(list, f)
+		}
+	}
+	p.want(_Rbrack)
+	return
+}
+
+// constraint parses a type constraint: a type, or a union of terms
+// as in an interface.
+//
+// TypeConstraint = TypeElem .
+func (p *parser) constraint() Expr {
+	return p.typeElem(nil)
+}
+
+// typeElem parses a union of type terms. If x is not nil, it is the
+// already parsed first term.
+//
+// TypeElem = TypeTerm { "|" TypeTerm } .
+// TypeTerm = Type | UnderlyingType .
+// UnderlyingType = "~" Type .
+func (p *parser) typeElem(x Expr) Expr {
+	if trace {
+		defer p.trace("typeElem")()
+	}
+
+	if x == nil {
+		x = p.typeTerm()
+	}
+	for p.tok == _Operator && p.op == Or {
+		t := new(Operation)
+		t.pos = p.pos()
+		t.Op = Or
+		p.next()
+		t.X = x
+		t.Y = p.typeTerm()
+		x = t
+	}
+	return x
+}
+
+func (p *parser) typeTerm() Expr {
+	if p.tok == _Operator && p.op == Tilde {
+		t := new(Operation)
+		t.pos = p.pos()
+		t.Op = Tilde
+		p.next()
+		t.X = p.type_()
+		return t
+	}
+	return p.type_()
+}
+
 func (p *parser) funcType() *FuncType {
 	if trace {
 		defer p.trace("funcType")()
@@ -1242,7 +1539,7 @@ func (p *parser) addField(styp *StructType, pos src.Pos, name *Name, typ Expr, t
 }
 
 // FieldDecl      = (IdentifierList Type | AnonymousField) [ Tag ] .
-// AnonymousField = [ "*" ] TypeName .
+// AnonymousField = [ "*" ] TypeName [ TypeArgs ] .
 // Tag            = string_lit .
 func (p *parser) fieldDecl(styp *StructType) {
 	if trace {
@@ -1261,6 +1558,22 @@ func (p *parser) fieldDecl(styp *StructType) {
 			return
 		}
 
+		if p.tok == _Lbrack {
+			// name "[" ...
+			// field name with array or slice type, or embedded generic type
+			typ := p.arrayOrTypeArgs()
+			if list, ok := typ.(*ListExpr); ok {
+				// embed targs oliteral
+				typ = newInstance(name, list)
+				tag := p.oliteral()
+				p.addField(styp, pos, nil, typ, tag)
+				return
+			}
+			tag := p.oliteral()
+			p.addField(styp, pos, name, typ, tag)
+			return
+		}
+
 		// new_name_list ntype oliteral
 		names := p.nameList(name)
 		typ := p.type_()
@@ -1326,6 +1639,7 @@ func (p *parser) oliteral() *BasicLit {
 	return nil
 }
 
+// InterfaceElem     = MethodSpec | TypeElem .
 // MethodSpec        = MethodName Signature | InterfaceTypeName .
 // MethodName        = identifier .
 // InterfaceTypeName = TypeName .
@@ -1353,7 +1667,8 @@ func (p *parser) methodDecl() *Field {
 		f.pos = name.Pos()
 		if p.tok != _Lparen {
 			// packname
-			f.Type = p.qualifiedName(name)
+			// union of types
+			f.Type = p.typeElem(p.qualifiedName(name))
 			return f
 		}
 
@@ -1370,11 +1685,33 @@ func (p *parser) methodDecl() *Field {
 		p.want(_Rparen)
 		return f
 
-	default:
-		p.syntax_error("expecting method or interface name")
-		p.advance(_Semi, _Rbrace)
-		return nil
+	case _Operator, _Star, _Arrow, _Func, _Lbrack, _Chan, _Map, _Struct, _Interface:
+		if p.tok == _Operator && p.op != Tilde {
+			break
+		}
+		// union of types
+		f := new(Field)
+		f.pos = p.pos()
+		f.Type = p.typeElem(nil)
+		return f
+	}
+
+	p.syntax_error("expecting method or interface name")
+	p.advance(_Semi, _Rbrace)
+	return nil
+}
+
+// newInstance returns a generic type x instantiated with the type
+// arguments in list.
+func newInstance(x Expr, list *ListExpr) Expr {
+	t := new(IndexExpr)
+	t.pos = list.Pos()
+	t.X = x
+	t.Index = list
+	if len(list.ElemList) == 1 {
+		t.Index = list.ElemList[0]
 	}
+	return t
 }
 
 // ParameterDecl = [ IdentifierList ] [ "..." ] Type .
@@ -1390,7 +1727,18 @@ func (p *parser) paramDeclOrNil() *Field {
 	case _Name:
 		f.Name = p.name()
 		switch p.tok {
-		case _Name, _Star, _Arrow, _Func, _Lbrack, _Chan, _Map, _Struct, _Interface, _Lparen:
+		case _Lbrack:
+			// sym array_or_slice_type
+			// generic_type
+			typ := p.arrayOrTypeArgs()
+			if list, ok := typ.(*ListExpr); ok {
+				f.Type = newInstance(f.Name, list)
+				f.Name = nil
+			} else {
+				f.Type = typ
+			}
+
+		case _Name, _Star, _Arrow, _Func, _Chan, _Map, _Struct, _Interface, _Lparen:
 			// sym name_or_type
 			f.Type = p.type_()
 
@@ -1401,7 +1749,7 @@ func (p *parser) paramDeclOrNil() *Field {
 		case _Dot:
 			// name_or_type
 			// from dotname
-			f.Type = p.dotname(f.Name)
+			f.Type = p.typeInstance(p.dotname(f.Name))
 			f.Name = nil
 		}
 
@@ -2160,7 +2508,19 @@ func (p *parser) qualifiedName(name *Name) Expr {
 		p.advance(_Dot, _Semi, _Rbrace)
 	}
 
-	return p.dotname(name)
+	return p.typeInstance(p.dotname(name))
+}
+
+// isTypeName reports whether x is a (possibly qualified) type name.
+func isTypeName(x Expr) bool {
+	switch x := x.(type) {
+	case *Name:
+		return true
+	case *SelectorExpr:
+		_, ok := x.X.(*Name)
+		return ok
+	}
+	return false
 }
 
 // ExpressionList = Expression { "," Expression } .
@@ -27,6 +27,12 @@ func TestPrintString(t *testing.T) {
 	for _, want := range []string{
 		"package p",
 		"package p; type _ = int; type T1 = struct{}; type ( _ = *struct{}; T2 = float32 )",
+		"package p; type List[T any] struct{ next *List[T]; val T }",
+		"package p; type Pair[K comparable, V any] struct{ k K; v V }; var _ Pair[string, int]",
+		"package p; type Number interface{ ~int | ~int64 | float64 }",
+		"package p; func Map[S, T any](s []S, f func(S) T) []T",
+		"package p; func _() { _ = Map[int, string](nil, nil); _ = Sum[float64](nil) }",
+		"package p; type A [N]int; type B [N * M]int; type C [P * C]int",
 		// TODO(gri) expand
 	} {
 		ast, err := ParseBytes(nil, []byte(want), nil, nil, nil, 0)
@@ -228,8 +228,8 @@ redo:
 		goto assignop
 
 	case '~':
-		s.error("bitwise complement operator is ^")
-		fallthrough
+		s.op, s.prec = Tilde, 0
+		s.tok = _Operator
 
 	case '^':
 		s.op, s.prec = Xor, precAdd
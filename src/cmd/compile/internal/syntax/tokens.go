@@ -170,10 +170,11 @@ const (
 type Operator uint
 
 const (
-	_    Operator = iota
-	Def           // :=
-	Not           // !
-	Recv          // <-
+	_     Operator = iota
+	Def            // :=
+	Not            // !
+	Recv           // <-
+	Tilde          // ~
 
 	// precOrOr
 	OrOr // ||
@@ -207,9 +208,10 @@ const (
 
 var opstrings = [...]string{
 	// prec == 0
-	Def:  ":", // : in :=
-	Not:  "!",
-	Recv: "<-",
+	Def:   ":", // : in :=
+	Not:   "!",
+	Recv:  "<-",
+	Tilde: "~",
 
 	// precOrOr
 	OrOr: "||",
@@ -0,0 +1,56 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	const src = `package p
+
+type List[T any] struct {
+	next *List[T]
+	val  T
+}
+
+func (l *List[T]) Push(v T) *List[T] { return &List[T]{l, v} }
+
+func Map[S, T any](s []S, f func(S) T) (r []T) {
+	for _, x := range s {
+		r = append(r, f(x))
+	}
+	return
+}
+`
+	ast, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	depth := 0
+	Inspect(ast, func(n Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		depth++
+		if n, ok := n.(*Name); ok {
+			names = append(names, n.Value)
+		}
+		return true
+	})
+	if depth != 0 {
+		t.Errorf("unbalanced calls of f: depth %d", depth)
+	}
+
+	const want = "p List T any next List T val T l List T Push v T List T List T l v " +
+		"Map S any T any s S f S T r T _ x s r append r f x"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got names\n\t%s\nwant\n\t%s", got, want)
+	}
+}
@@ -28,7 +28,7 @@ func TestSizeof(t *testing.T) {
 		{Forward{}, 20, 32},
 		{Func{}, 28, 48},
 		{Struct{}, 12, 24},
-		{Interface{}, 4, 8},
+		{Interface{}, 20, 40},
 		{Chan{}, 8, 16},
 		{Array{}, 12, 16},
 		{DDDField{}, 4, 8},
@@ -263,6 +263,20 @@ func (t *Type) StructType() *Struct {
 // Interface contains Type fields specific to interface types.
 type Interface struct {
 	Fields Fields
+
+	// Unions and Comparable restrict the type set of constraint
+	// interfaces, which may only be used as type constraints.
+	// A type is in the type set if it is in each of the Unions
+	// and, if Comparable is set, comparable.
+	Unions     [][]Term
+	Comparable bool
+}
+
+// A Term is a term of a union in a constraint interface: a type T,
+// or ~T, which stands for all types with underlying type T.
+type Term struct {
+	Tilde bool
+	Type  *Type
 }
 
 // Ptr contains Type fields specific to pointer types.
@@ -1267,6 +1281,17 @@ func (t *Type) IsEmptyInterface() bool {
 	return t.IsInterface() && t.NumFields() == 0
 }
 
+// IsConstraint reports whether t is an interface that may only be
+// used as a type constraint.
+func (t *Type) IsConstraint() bool {
+	if !t.IsInterface() {
+		return false
+	}
+	Dowidth(t) // expand embedded interfaces
+	i := t.Extra.(*Interface)
+	return len(i.Unions) > 0 || i.Comparable
+}
+
 func (t *Type) ElemType() *Type {
 	// TODO(josharian): If Type ever moves to a shared
 	// internal package, remove this silly wrapper.
@@ -43,6 +43,7 @@ var bootstrapDirs = []string{
 	"cmd/compile/internal/gc",
 	"cmd/compile/internal/mips",
 	"cmd/compile/internal/mips64",
+	"cmd/compile/internal/pgo",
 	"cmd/compile/internal/ppc64",
 	"cmd/compile/internal/types",
 	"cmd/compile/internal/s390x",
@@ -127,6 +127,11 @@
 // 	-linkshared
 // 		link against shared libraries previously created with
 // 		-buildmode=shared.
+// 	-pgo file
+// 		build with profile-guided optimization, using the CPU profile in file,
+// 		as written by runtime/pprof or 'go test -cpuprofile', of a
+// 		representative run of the program. The profile applies to all
+// 		the packages of the build.
 // 	-pkgdir dir
 // 		install and load all packages from dir instead of the usual locations.
 // 		For example, when building with a non-standard configuration,
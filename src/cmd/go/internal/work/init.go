@@ -29,6 +29,19 @@ func BuildInit() {
 		}
 		cfg.BuildPkgdir = p
 	}
+
+	// Likewise for -pgo.
+	if cfg.BuildPGO != "" {
+		if cfg.BuildToolchainName == "gccgo" {
+			base.Fatalf("go %s: -pgo is not supported by gccgo", flag.Args()[0])
+		}
+		p, err := filepath.Abs(cfg.BuildPGO)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "go %s: evaluating -pgo: %v\n", flag.Args()[0], err)
+			os.Exit(2)
+		}
+		cfg.BuildPGO = p
+	}
 }
 
 func instrumentInit() {
@@ -0,0 +1,34 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Heapview examines a heap dump written by runtime/debug.WriteHeapDump.
+
+Usage:
+	go tool heapview [flags] dumpfile
+
+Heapview computes the dominator tree of the heap: an object dominates
+another if every path from the roots (globals, stacks, finalizers and
+runtime roots) to the second goes through the first. The retained size of
+an object is its size plus the sizes of all the objects it dominates, that
+is, the memory that would be freed if the object became unreachable.
+
+By default heapview prints a summary of the dump and the objects with the
+largest retained sizes. The flags are:
+
+	-top n
+		print the n objects with the largest retained sizes (default 20)
+	-types
+		print the number of objects and the total and retained sizes
+		of each type instead
+	-why addr
+		print why the object containing addr is alive: the shortest
+		path to it from a root, and the objects that dominate it
+
+Object types are inferred from the interface values and typed pointers
+that reach the objects, as described in the documentation of package
+internal/heapdump; objects whose type could not be inferred are shown
+with their size only.
+*/
+package main
@@ -0,0 +1,129 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// dominators computes the immediate dominators of the nodes of the graph
+// with successor lists succ that are reachable from root, using the
+// Lengauer-Tarjan algorithm with path compression.
+//
+// It returns the immediate dominator of each node, -1 for the root and
+// for unreachable nodes, and the reachable nodes in depth-first order,
+// starting with root. A node comes after its immediate dominator in
+// that order.
+//
+// Heaps can be deep linked lists, so the depth-first search and the
+// path compression are iterative.
+func dominators(succ [][]int, root int) (idom []int, order []int) {
+	n := len(succ)
+	dfnum := make([]int, n)    // depth-first number, -1 if unreachable
+	parent := make([]int, n)   // in the depth-first spanning tree
+	semi := make([]int, n)     // depth-first number of the semidominator
+	ancestor := make([]int, n) // in the forest built by link
+	label := make([]int, n)    // node with the least semi on the path to ancestor
+	idom = make([]int, n)
+	for i := range dfnum {
+		dfnum[i] = -1
+		ancestor[i] = -1
+		idom[i] = -1
+		label[i] = i
+	}
+
+	// Number the nodes in depth-first order.
+	type frame struct{ v, next int }
+	stack := []frame{{root, 0}}
+	dfnum[root] = 0
+	parent[root] = -1
+	order = append(order, root)
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		if f.next == len(succ[f.v]) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		w := succ[f.v][f.next]
+		f.next++
+		if dfnum[w] < 0 {
+			dfnum[w] = len(order)
+			parent[w] = f.v
+			order = append(order, w)
+			stack = append(stack, frame{w, 0})
+		}
+	}
+	for _, v := range order {
+		semi[v] = dfnum[v]
+	}
+
+	pred := make([][]int, n)
+	for _, v := range order {
+		for _, w := range succ[v] {
+			pred[w] = append(pred[w], v)
+		}
+	}
+
+	var path []int
+	eval := func(v int) int {
+		if ancestor[v] < 0 {
+			return v
+		}
+		// Compress the path from v to the root of its tree in the
+		// forest, updating the labels from the top down.
+		path = path[:0]
+		for u := v; ancestor[ancestor[u]] >= 0; u = ancestor[u] {
+			path = append(path, u)
+		}
+		for i := len(path) - 1; i >= 0; i-- {
+			u := path[i]
+			a := ancestor[u]
+			if semi[label[a]] < semi[label[u]] {
+				label[u] = label[a]
+			}
+			ancestor[u] = ancestor[a]
+		}
+		return label[v]
+	}
+
+	bucket := make([][]int, n)
+	for i := len(order) - 1; i > 0; i-- {
+		w := order[i]
+		for _, v := range pred[w] {
+			if u := eval(v); semi[u] < semi[w] {
+				semi[w] = semi[u]
+			}
+		}
+		s := order[semi[w]]
+		bucket[s] = append(bucket[s], w)
+		p := parent[w]
+		ancestor[w] = p
+		for _, v := range bucket[p] {
+			if u := eval(v); semi[u] < semi[v] {
+				idom[v] = u
+			} else {
+				idom[v] = p
+			}
+		}
+		bucket[p] = nil
+	}
+	for _, w := range order[1:] {
+		if idom[w] != order[semi[w]] {
+			idom[w] = idom[idom[w]]
+		}
+	}
+	return idom, order
+}
+
+// retainedSizes returns the retained size of each node given the
+// immediate dominators and the depth-first order computed by dominators:
+// the size of the node plus the sizes of the nodes it dominates.
+func retainedSizes(size []uint64, idom, order []int) []uint64 {
+	retained := make([]uint64, len(size))
+	for _, v := range order {
+		retained[v] = size[v]
+	}
+	for i := len(order) - 1; i > 0; i-- {
+		v := order[i]
+		retained[idom[v]] += retained[v]
+	}
+	return retained
+}
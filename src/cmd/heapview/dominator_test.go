@@ -0,0 +1,102 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDominators(t *testing.T) {
+	// The graph from figure 1 of Lengauer and Tarjan's paper, with
+	// R, A, B, ... numbered 0, 1, 2, ..., and an unreachable node 13.
+	const (
+		R = iota
+		A
+		B
+		C
+		D
+		E
+		F
+		G
+		H
+		I
+		J
+		K
+		L
+		X
+	)
+	succ := [][]int{
+		R: {A, B, C},
+		A: {D},
+		B: {A, D, E},
+		C: {F, G},
+		D: {L},
+		E: {H},
+		F: {I},
+		G: {I, J},
+		H: {E, K},
+		I: {K},
+		J: {I},
+		K: {I, R},
+		L: {H},
+		X: {R},
+	}
+	idom, order := dominators(succ, R)
+	want := []int{
+		R: -1,
+		A: R,
+		B: R,
+		C: R,
+		D: R,
+		E: R,
+		F: C,
+		G: C,
+		H: R,
+		I: R,
+		J: G,
+		K: R,
+		L: D,
+		X: -1,
+	}
+	if !reflect.DeepEqual(idom, want) {
+		t.Errorf("idom = %v, want %v", idom, want)
+	}
+	if len(order) != 13 || order[0] != R {
+		t.Errorf("order = %v, want the 13 reachable nodes starting with R", order)
+	}
+
+	size := make([]uint64, len(succ))
+	for i := range size {
+		size[i] = 1
+	}
+	retained := retainedSizes(size, idom, order)
+	for v, n := range map[int]uint64{R: 13, C: 4, G: 2, D: 2, L: 1, X: 0} {
+		if retained[v] != n {
+			t.Errorf("retained[%d] = %d, want %d", v, retained[v], n)
+		}
+	}
+}
+
+func TestDominatorsDeep(t *testing.T) {
+	// A long list must not overflow the stack.
+	const n = 1 << 20
+	succ := make([][]int, n)
+	for i := 0; i < n-1; i++ {
+		succ[i] = []int{i + 1}
+	}
+	succ[n-1] = []int{0}
+	idom, order := dominators(succ, 0)
+	if len(order) != n || idom[n-1] != n-2 {
+		t.Errorf("got %d reachable nodes, idom[n-1] = %d", len(order), idom[n-1])
+	}
+	size := make([]uint64, n)
+	for i := range size {
+		size[i] = 1
+	}
+	if r := retainedSizes(size, idom, order); r[0] != n || r[n/2] != n/2 {
+		t.Errorf("retained sizes %d and %d, want %d and %d", r[0], r[n/2], n, n/2)
+	}
+}
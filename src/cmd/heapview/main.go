@@ -0,0 +1,268 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"internal/heapdump"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+var (
+	flagTop   = flag.Int("top", 20, "print the `n` objects with the largest retained sizes")
+	flagTypes = flag.Bool("types", false, "print a summary of the objects by type")
+	flagWhy   = flag.String("why", "", "print why the object at `addr` is alive")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: go tool heapview [flags] dumpfile\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("heapview: ")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	d, err := heapdump.Read(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("reading %s: %v", flag.Arg(0), err)
+	}
+	h := newHeap(d)
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	switch {
+	case *flagWhy != "":
+		addr, err := strconv.ParseUint(*flagWhy, 0, 64)
+		if err != nil {
+			log.Fatalf("bad address %q", *flagWhy)
+		}
+		if err := h.why(w, addr); err != nil {
+			w.Flush()
+			log.Fatal(err)
+		}
+	case *flagTypes:
+		h.types(w)
+	default:
+		h.summary(w)
+		h.top(w, *flagTop)
+	}
+}
+
+// A heap is the graph of a heap dump. Node 0 is a super-root pointing to
+// the roots, followed by the roots and then by the objects in address
+// order.
+type heap struct {
+	d        *heapdump.Dump
+	roots    []*heapdump.Root
+	succ     [][]int
+	size     []uint64
+	idom     []int
+	order    []int
+	retained []uint64
+}
+
+func newHeap(d *heapdump.Dump) *heap {
+	h := &heap{d: d, roots: d.Roots()}
+	n := 1 + len(h.roots) + len(d.Objects)
+	h.succ = make([][]int, n)
+	h.size = make([]uint64, n)
+	index := make(map[*heapdump.Object]int, len(d.Objects))
+	for i, obj := range d.Objects {
+		v := h.objNode(i)
+		index[obj] = v
+		h.size[v] = obj.Size()
+	}
+	for i, r := range h.roots {
+		v := 1 + i
+		h.succ[0] = append(h.succ[0], v)
+		for _, ref := range r.Refs {
+			h.succ[v] = append(h.succ[v], index[ref.Obj])
+		}
+	}
+	for i, obj := range d.Objects {
+		v := h.objNode(i)
+		for _, ref := range d.Refs(obj.Data, obj.Fields) {
+			h.succ[v] = append(h.succ[v], index[ref.Obj])
+		}
+	}
+	h.idom, h.order = dominators(h.succ, 0)
+	h.retained = retainedSizes(h.size, h.idom, h.order)
+	return h
+}
+
+func (h *heap) objNode(i int) int {
+	return 1 + len(h.roots) + i
+}
+
+// object returns the object of node v, or nil if v is not an object.
+func (h *heap) object(v int) *heapdump.Object {
+	if i := v - 1 - len(h.roots); i >= 0 {
+		return h.d.Objects[i]
+	}
+	return nil
+}
+
+func (h *heap) name(v int) string {
+	if v == 0 {
+		return "<roots>"
+	}
+	if obj := h.object(v); obj != nil {
+		return fmt.Sprintf("%#x %v (%d bytes)", obj.Addr, obj.Type, obj.Size())
+	}
+	return h.roots[v-1].Name
+}
+
+func (h *heap) summary(w io.Writer) {
+	var live, reachable uint64
+	for _, obj := range h.d.Objects {
+		live += obj.Size()
+	}
+	for _, v := range h.order {
+		reachable += h.size[v]
+	}
+	typed := 0
+	for _, obj := range h.d.Objects {
+		if obj.Type != nil {
+			typed++
+		}
+	}
+	fmt.Fprintf(w, "objects: %d (%d bytes), %d reachable from %d roots (%d bytes), %d typed\n",
+		len(h.d.Objects), live, len(h.order)-1-len(h.roots), len(h.roots), reachable, typed)
+	fmt.Fprintf(w, "goroutines: %d\n", len(h.d.Goroutines))
+	if m := h.d.MemStats; m != nil {
+		fmt.Fprintf(w, "heap: %d bytes allocated, %d in use, next GC at %d\n", m.HeapAlloc, m.HeapInuse, m.NextGC)
+	}
+	fmt.Fprintln(w)
+}
+
+func (h *heap) top(w io.Writer, n int) {
+	var objs []int
+	for _, v := range h.order {
+		if h.object(v) != nil {
+			objs = append(objs, v)
+		}
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return h.retained[objs[i]] > h.retained[objs[j]]
+	})
+	if n < len(objs) {
+		objs = objs[:n]
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "retained\tsize\taddress\t type\n")
+	for _, v := range objs {
+		obj := h.object(v)
+		fmt.Fprintf(tw, "%d\t%d\t%#x\t %v\n", h.retained[v], obj.Size(), obj.Addr, obj.Type)
+	}
+	tw.Flush()
+}
+
+func (h *heap) types(w io.Writer) {
+	type stats struct {
+		name           string
+		count          int
+		size, retained uint64
+	}
+	byName := make(map[string]*stats)
+	for i, obj := range h.d.Objects {
+		name := obj.Type.String()
+		s := byName[name]
+		if s == nil {
+			s = &stats{name: name}
+			byName[name] = s
+		}
+		s.count++
+		s.size += obj.Size()
+		// Count the retained size of the outermost objects of the type
+		// only, so that nested objects are not counted twice.
+		v := h.objNode(i)
+		if h.idom[v] >= 0 {
+			if p := h.object(h.idom[v]); p == nil || p.Type.String() != name {
+				s.retained += h.retained[v]
+			}
+		}
+	}
+	var all []*stats
+	for _, s := range byName {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].size != all[j].size {
+			return all[i].size > all[j].size
+		}
+		return all[i].name < all[j].name
+	})
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "count\tsize\tretained\t type\n")
+	for _, s := range all {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t %s\n", s.count, s.size, s.retained, s.name)
+	}
+	tw.Flush()
+}
+
+func (h *heap) why(w io.Writer, addr uint64) error {
+	obj := h.d.FindObject(addr)
+	if obj == nil {
+		return fmt.Errorf("no object at %#x", addr)
+	}
+	target := h.objNode(sort.Search(len(h.d.Objects), func(i int) bool {
+		return h.d.Objects[i].Addr >= obj.Addr
+	}))
+	if h.idom[target] < 0 {
+		return fmt.Errorf("object %#x is not reachable", obj.Addr)
+	}
+
+	// Breadth-first search from the roots for the shortest path.
+	prev := make([]int, len(h.succ))
+	for i := range prev {
+		prev[i] = -1
+	}
+	queue := []int{0}
+	prev[0] = 0
+	for len(queue) > 0 && prev[target] < 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, s := range h.succ[v] {
+			if prev[s] < 0 {
+				prev[s] = v
+				queue = append(queue, s)
+			}
+		}
+	}
+	var path []int
+	for v := target; v != 0; v = prev[v] {
+		path = append(path, v)
+	}
+	fmt.Fprintf(w, "shortest path from a root:\n")
+	for i := len(path) - 1; i >= 0; i-- {
+		fmt.Fprintf(w, "\t%s\n", h.name(path[i]))
+	}
+
+	fmt.Fprintf(w, "dominated by:\n")
+	for v := h.idom[target]; v > 0; v = h.idom[v] {
+		fmt.Fprintf(w, "\t%s\n", h.name(v))
+	}
+	fmt.Fprintf(w, "retains %d bytes\n", h.retained[target])
+	return nil
+}
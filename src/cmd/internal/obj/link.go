@@ -394,8 +394,9 @@ type FuncInfo struct {
 	dwarfRangesSym *LSym
 	dwarfAbsFnSym  *LSym
 
-	GCArgs   LSym
-	GCLocals LSym
+	GCArgs             LSym
+	GCLocals           LSym
+	OpenCodedDeferInfo *LSym // info for func with open-coded defers
 }
 
 // Attribute is a set of symbol attributes.
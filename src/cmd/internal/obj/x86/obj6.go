@@ -908,6 +908,16 @@ func preprocess(ctxt *obj.Link, cursym *obj.LSym, newprog obj.ProgAlloc) {
 			p.Spadj = -2
 			continue
 
+		case AADJSP:
+			// An explicit ADJSP in the function body. The
+			// prologue's ADJSP already has Spadj set and is
+			// accounted for in deltasp.
+			if p.Spadj == 0 {
+				p.Spadj = int32(p.From.Offset)
+				deltasp += int32(p.From.Offset)
+			}
+			continue
+
 		case obj.ARET:
 			// do nothing
 		}
@@ -11,11 +11,13 @@ package objabi
 // ../../../runtime/symtab.go.
 
 const (
-	PCDATA_StackMapIndex       = 0
-	PCDATA_InlTreeIndex        = 1
-	FUNCDATA_ArgsPointerMaps   = 0
-	FUNCDATA_LocalsPointerMaps = 1
-	FUNCDATA_InlTree           = 2
+	PCDATA_StackMapIndex        = 0
+	PCDATA_InlTreeIndex         = 1
+	PCDATA_UnsafePoint          = 2
+	FUNCDATA_ArgsPointerMaps    = 0
+	FUNCDATA_LocalsPointerMaps  = 1
+	FUNCDATA_InlTree            = 2
+	FUNCDATA_OpenCodedDeferInfo = 3 // info for func with open-coded defers
 
 	// ArgsSizeUnknown is set in Func.argsize to mark all functions
 	// whose argument size is unknown (C vararg functions, and
@@ -23,3 +25,16 @@ const (
 	// This value is generated by the compiler, assembler, or linker.
 	ArgsSizeUnknown = -0x80000000
 )
+
+// Special PCDATA_UnsafePoint values.
+const (
+	// PCDATA_UnsafePointSafe indicates that the goroutine may be
+	// asynchronously preempted at this instruction. It is the
+	// value of the table for PCs the compiler did not annotate.
+	PCDATA_UnsafePointSafe = -1
+
+	// PCDATA_UnsafePointUnsafe indicates that asynchronous
+	// preemption is not allowed at this instruction, for example
+	// because it is in the middle of a write barrier sequence.
+	PCDATA_UnsafePointUnsafe = -2
+)
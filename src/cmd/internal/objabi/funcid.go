@@ -23,12 +23,15 @@ const (
 	FuncID_sigpanic
 	FuncID_runfinq
 	FuncID_bgsweep
+	FuncID_bgscavenge
 	FuncID_forcegchelper
-	FuncID_timerproc
+	FuncID_gomaxprocshelper
+	FuncID_uniquemapcleanup
 	FuncID_gcBgMarkWorker
 	FuncID_systemstack_switch
 	FuncID_systemstack
 	FuncID_cgocallback_gofunc
 	FuncID_gogo
 	FuncID_externalthreadhandler
+	FuncID_asyncPreempt
 )
@@ -95,6 +95,7 @@ var (
 	Fieldtrack_enabled       int
 	Preemptibleloops_enabled int
 	Clobberdead_enabled      int
+	Arenas_enabled           int
 )
 
 // Toolchain experiments.
@@ -109,6 +110,7 @@ var exper = []struct {
 	{"framepointer", &framepointer_enabled},
 	{"preemptibleloops", &Preemptibleloops_enabled},
 	{"clobberdead", &Clobberdead_enabled},
+	{"arenas", &Arenas_enabled},
 }
 
 var defaultExpstring = Expstring()
@@ -166,7 +166,7 @@ func decodetypePtrElem(arch *sys.Arch, s *sym.Symbol) *sym.Symbol {
 	return decodeRelocSym(s, int32(commonsize(arch))) // 0x1c / 0x30
 }
 
-// Type.MapType.key, elem
+// Type.MapType.key, elem, bucket
 func decodetypeMapKey(arch *sys.Arch, s *sym.Symbol) *sym.Symbol {
 	return decodeRelocSym(s, int32(commonsize(arch))) // 0x1c / 0x30
 }
@@ -175,6 +175,10 @@ func decodetypeMapValue(arch *sys.Arch, s *sym.Symbol) *sym.Symbol {
 	return decodeRelocSym(s, int32(commonsize(arch))+int32(arch.PtrSize)) // 0x20 / 0x38
 }
 
+func decodetypeMapBucket(arch *sys.Arch, s *sym.Symbol) *sym.Symbol {
+	return decodeRelocSym(s, int32(commonsize(arch))+2*int32(arch.PtrSize)) // 0x24 / 0x40
+}
+
 // Type.ChanType.elem
 func decodetypeChanElem(arch *sys.Arch, s *sym.Symbol) *sym.Symbol {
 	return decodeRelocSym(s, int32(commonsize(arch))) // 0x1c / 0x30
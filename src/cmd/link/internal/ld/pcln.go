@@ -7,7 +7,9 @@ package ld
 import (
 	"cmd/internal/objabi"
 	"cmd/internal/src"
+	"cmd/internal/sys"
 	"cmd/link/internal/sym"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -291,7 +293,7 @@ func (ctxt *Link) pclntab() {
 		// fixed size of struct, checked below
 		off := funcstart
 
-		end := funcstart + int32(ctxt.Arch.PtrSize) + 3*4 + 5*4 + int32(len(pcln.Pcdata))*4 + int32(len(pcln.Funcdata))*int32(ctxt.Arch.PtrSize)
+		end := funcstart + int32(ctxt.Arch.PtrSize) + 3*4 + 6*4 + int32(len(pcln.Pcdata))*4 + int32(len(pcln.Funcdata))*int32(ctxt.Arch.PtrSize)
 		if len(pcln.Funcdata) > 0 && (end&int32(ctxt.Arch.PtrSize-1) != 0) {
 			end += 4
 		}
@@ -335,10 +337,14 @@ func (ctxt *Link) pclntab() {
 			funcID = objabi.FuncID_runfinq
 		case "runtime.bgsweep":
 			funcID = objabi.FuncID_bgsweep
+		case "runtime.bgscavenge":
+			funcID = objabi.FuncID_bgscavenge
 		case "runtime.forcegchelper":
 			funcID = objabi.FuncID_forcegchelper
-		case "runtime.timerproc":
-			funcID = objabi.FuncID_timerproc
+		case "runtime.gomaxprocshelper":
+			funcID = objabi.FuncID_gomaxprocshelper
+		case "runtime.uniquemapcleanup":
+			funcID = objabi.FuncID_uniquemapcleanup
 		case "runtime.gcBgMarkWorker":
 			funcID = objabi.FuncID_gcBgMarkWorker
 		case "runtime.systemstack_switch":
@@ -351,6 +357,8 @@ func (ctxt *Link) pclntab() {
 			funcID = objabi.FuncID_gogo
 		case "runtime.externalthreadhandler":
 			funcID = objabi.FuncID_externalthreadhandler
+		case "runtime.asyncPreempt":
+			funcID = objabi.FuncID_asyncPreempt
 		}
 		off = int32(ftab.SetUint32(ctxt.Arch, int64(off), uint32(funcID)))
 
@@ -397,6 +405,33 @@ func (ctxt *Link) pclntab() {
 
 		off = addpctab(ctxt, ftab, off, &pcln.Pcfile)
 		off = addpctab(ctxt, ftab, off, &pcln.Pcline)
+
+		// deferreturn uint32
+		// The offset of the call to runtime.deferreturn, which functions
+		// with open-coded defers use as the landing pad for recovery.
+		deferreturn := uint32(0)
+		for _, r := range s.R {
+			if r.Type.IsDirectJump() && r.Sym != nil && r.Sym.Name == "runtime.deferreturn" {
+				// Note: the relocation target is in the call instruction, but
+				// is not necessarily the whole instruction (for instance, on
+				// x86 the relocation applies to bytes [1:5] of the 5 byte call
+				// instruction).
+				deferreturn = uint32(r.Off)
+				switch ctxt.Arch.Family {
+				case sys.AMD64, sys.I386:
+					deferreturn--
+				case sys.PPC64, sys.ARM, sys.ARM64, sys.MIPS, sys.MIPS64:
+					// no change
+				case sys.S390X:
+					deferreturn -= 2
+				default:
+					panic(fmt.Sprint("Unhandled architecture:", ctxt.Arch.Family))
+				}
+				break // only need one
+			}
+		}
+		off = int32(ftab.SetUint32(ctxt.Arch, int64(off), deferreturn))
+
 		off = int32(ftab.SetUint32(ctxt.Arch, int64(off), uint32(len(pcln.Pcdata))))
 		off = int32(ftab.SetUint32(ctxt.Arch, int64(off), uint32(len(pcln.Funcdata))))
 		for i := 0; i < len(pcln.Pcdata); i++ {
@@ -0,0 +1,1015 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Task- and region-oriented views of user annotations
+// (see runtime/trace.NewTask, WithRegion and Log).
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"internal/trace"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	http.HandleFunc("/usertasks", httpUserTasks)
+	http.HandleFunc("/usertask", httpUserTask)
+	http.HandleFunc("/userregions", httpUserRegions)
+	http.HandleFunc("/userregion", httpUserRegion)
+}
+
+// httpUserTasks reports all tasks found in the trace.
+func httpUserTasks(w http.ResponseWriter, r *http.Request) {
+	res, err := analyzeAnnotations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summary := make(map[string]taskStats)
+	for _, task := range res.tasks {
+		stats, ok := summary[task.name]
+		if !ok {
+			stats.Type = task.name
+		}
+		stats.add(task)
+		summary[task.name] = stats
+	}
+
+	// Sort tasks by type.
+	userTasks := make([]taskStats, 0, len(summary))
+	for _, stats := range summary {
+		userTasks = append(userTasks, stats)
+	}
+	sort.Slice(userTasks, func(i, j int) bool {
+		return userTasks[i].Type < userTasks[j].Type
+	})
+
+	// Emit table.
+	err = templUserTaskTypes.Execute(w, userTasks)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// httpUserRegions reports all regions found in the trace,
+// grouped by region type and the function that started them.
+func httpUserRegions(w http.ResponseWriter, r *http.Request) {
+	res, err := analyzeAnnotations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	allRegions := res.regions
+
+	summary := make(map[regionTypeID]regionStats)
+	for id, regions := range allRegions {
+		stats, ok := summary[id]
+		if !ok {
+			stats.regionTypeID = id
+		}
+		for _, s := range regions {
+			stats.add(s)
+		}
+		summary[id] = stats
+	}
+	// Sort regions by pc and name.
+	userRegions := make([]regionStats, 0, len(summary))
+	for _, stats := range summary {
+		userRegions = append(userRegions, stats)
+	}
+	sort.Slice(userRegions, func(i, j int) bool {
+		if userRegions[i].Type != userRegions[j].Type {
+			return userRegions[i].Type < userRegions[j].Type
+		}
+		return userRegions[i].Frame.PC < userRegions[j].Frame.PC
+	})
+	// Emit table.
+	err = templUserRegionTypes.Execute(w, userRegions)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// httpUserRegion reports the instances of a region type,
+// optionally limited to those within a latency range.
+func httpUserRegion(w http.ResponseWriter, r *http.Request) {
+	filter, err := newRegionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := analyzeAnnotations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	allRegions := res.regions
+
+	var data []*regionDesc
+	for id, regions := range allRegions {
+		for _, s := range regions {
+			if !filter.match(id, s) {
+				continue
+			}
+			data = append(data, s)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].duration() > data[j].duration()
+	})
+
+	err = templUserRegionType.Execute(w, struct {
+		Data  []*regionDesc
+		Title string
+	}{
+		Data:  data,
+		Title: filter.name,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// httpUserTask presents the details of the selected tasks.
+func httpUserTask(w http.ResponseWriter, r *http.Request) {
+	filter, err := newTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := analyzeAnnotations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tasks := res.tasks
+
+	type event struct {
+		WhenString string
+		Elapsed    time.Duration
+		Go         uint64
+		What       string
+		// TODO: include stack trace of creation time
+	}
+	type entry struct {
+		WhenString string
+		ID         uint64
+		Duration   time.Duration
+		Complete   bool
+		Events     []event
+	}
+
+	base := time.Duration(res.firstTs) * time.Nanosecond // trace start
+
+	var data []entry
+
+	for _, task := range tasks {
+		if !filter.match(task) {
+			continue
+		}
+		var events []event
+		var last time.Duration
+
+		for i, ev := range task.events {
+			when := time.Duration(ev.Ts)*time.Nanosecond - base
+			elapsed := time.Duration(ev.Ts)*time.Nanosecond - last
+			if i == 0 {
+				elapsed = 0
+			}
+
+			what := describeEvent(ev)
+			if what != "" {
+				events = append(events, event{
+					WhenString: fmt.Sprintf("%2.9f", when.Seconds()),
+					Elapsed:    elapsed,
+					What:       what,
+					Go:         ev.G,
+				})
+				last = time.Duration(ev.Ts) * time.Nanosecond
+			}
+		}
+
+		data = append(data, entry{
+			WhenString: fmt.Sprintf("%2.9fs", (time.Duration(task.firstTimestamp())*time.Nanosecond - base).Seconds()),
+			Duration:   task.duration(),
+			ID:         task.id,
+			Complete:   task.complete(),
+			Events:     events,
+		})
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Duration < data[j].Duration
+	})
+
+	// Emit table.
+	err = templUserTaskType.Execute(w, struct {
+		Name  string
+		Entry []entry
+	}{
+		Name:  filter.name,
+		Entry: data,
+	})
+	if err != nil {
+		log.Printf("failed to execute template: %v", err)
+		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+type annotationAnalysisResult struct {
+	tasks   map[uint64]*taskDesc           // tasks
+	regions map[regionTypeID][]*regionDesc // regions
+	firstTs int64                          // timestamp of the first event in the trace
+}
+
+type regionTypeID struct {
+	Frame trace.Frame // top frame
+	Type  string
+}
+
+var (
+	annotationsInit sync.Once
+	annotations     annotationAnalysisResult
+	annotationsErr  error
+)
+
+// analyzeAnnotations analyzes user annotation events and
+// returns the task descriptors keyed by internal task id
+// and the regions grouped by region type.
+func analyzeAnnotations() (annotationAnalysisResult, error) {
+	annotationsInit.Do(func() {
+		res, err := parseTrace()
+		if err != nil {
+			annotationsErr = fmt.Errorf("failed to parse trace: %v", err)
+			return
+		}
+		annotations, annotationsErr = analyzeAnnotationEvents(res.Events)
+	})
+	return annotations, annotationsErr
+}
+
+// analyzeAnnotationEvents does the work of analyzeAnnotations
+// for the given events.
+func analyzeAnnotationEvents(events []*trace.Event) (annotationAnalysisResult, error) {
+	if len(events) == 0 {
+		return annotationAnalysisResult{}, fmt.Errorf("empty trace")
+	}
+
+	tasks := allTasks{}
+	regions := map[regionTypeID][]*regionDesc{}
+	// matched records the region end events whose
+	// start event is in the trace.
+	matched := make(map[*trace.Event]bool)
+	firstTs, lastTs := events[0].Ts, events[len(events)-1].Ts
+
+	for _, ev := range events {
+		switch typ := ev.Type; typ {
+		case trace.EvUserTaskCreate, trace.EvUserTaskEnd, trace.EvUserLog:
+			taskid := ev.Args[0]
+			task := tasks.task(taskid)
+			task.addEvent(ev)
+
+			// retrieve parent task information
+			if typ == trace.EvUserTaskCreate {
+				if parentID := ev.Args[1]; parentID != 0 {
+					parentTask := tasks.task(parentID)
+					task.parent = parentTask
+					parentTask.children = append(parentTask.children, task)
+				}
+			}
+
+		case trace.EvUserRegion:
+			if ev.Args[1] == 1 && matched[ev] {
+				// The region was recorded at its start.
+				continue
+			}
+			s := &regionDesc{
+				Name:    ev.SArgs[0],
+				TaskID:  ev.Args[0],
+				G:       ev.G,
+				firstTs: firstTs,
+				lastTs:  lastTs,
+			}
+			if ev.Args[1] == 0 { // region start
+				s.Start = ev
+				s.End = ev.Link
+				if ev.Link != nil {
+					matched[ev.Link] = true
+				}
+			} else { // region end whose start is before the trace start
+				s.End = ev
+			}
+			id := regionTypeID{Type: s.Name}
+			if len(ev.Stk) > 0 {
+				id.Frame = *ev.Stk[0]
+			}
+			regions[id] = append(regions[id], s)
+
+			task := tasks.task(s.TaskID)
+			task.goroutines[s.G] = true
+			task.regions = append(task.regions, s)
+			task.addEvent(ev)
+			if s.End != nil && s.End != ev {
+				task.addEvent(s.End)
+			}
+		}
+	}
+	// sort events in each task by timestamp.
+	for _, task := range tasks {
+		task.firstTs, task.lastTs = firstTs, lastTs
+		sort.SliceStable(task.events, func(i, j int) bool {
+			return task.events[i].Ts < task.events[j].Ts
+		})
+	}
+	// The background task (id 0) only collects regions and logs
+	// that are not associated with any task.
+	delete(tasks, 0)
+
+	return annotationAnalysisResult{tasks: tasks, regions: regions, firstTs: firstTs}, nil
+}
+
+// taskDesc represents a task.
+type taskDesc struct {
+	name       string          // user-provided task name
+	id         uint64          // internal task id
+	events     []*trace.Event  // sorted based on timestamp.
+	regions    []*regionDesc   // associated regions, sorted based on the start timestamp and then the last timestamp.
+	goroutines map[uint64]bool // involved goroutines
+	create     *trace.Event    // Task create event
+	end        *trace.Event    // Task end event
+	parent     *taskDesc
+	children   []*taskDesc
+
+	firstTs, lastTs int64 // timestamps of the first and the last event in the trace
+}
+
+func newTaskDesc(id uint64) *taskDesc {
+	return &taskDesc{
+		id:         id,
+		goroutines: make(map[uint64]bool),
+	}
+}
+
+func (task *taskDesc) String() string {
+	wb := new(bytes.Buffer)
+	fmt.Fprintf(wb, "task %d:\t%s\n", task.id, task.name)
+	fmt.Fprintf(wb, "\tstart: %v end: %v complete: %t\n", task.firstTimestamp(), task.endTimestamp(), task.complete())
+	fmt.Fprintf(wb, "\t%d goroutines\n", len(task.goroutines))
+	fmt.Fprintf(wb, "\t%d regions:\n", len(task.regions))
+	for _, s := range task.regions {
+		fmt.Fprintf(wb, "\t\t%s(goid=%d)\n", s.Name, s.G)
+	}
+	if task.parent != nil {
+		fmt.Fprintf(wb, "\tparent: %s\n", task.parent.name)
+	}
+	fmt.Fprintf(wb, "\t%d children:\n", len(task.children))
+	for _, c := range task.children {
+		fmt.Fprintf(wb, "\t\t%s\n", c.name)
+	}
+
+	return wb.String()
+}
+
+// allTasks maps a task id to the task descriptor.
+type allTasks map[uint64]*taskDesc
+
+// task returns the task descriptor for id, creating it if necessary.
+func (tasks allTasks) task(taskID uint64) *taskDesc {
+	t, ok := tasks[taskID]
+	if ok {
+		return t
+	}
+
+	t = newTaskDesc(taskID)
+	tasks[taskID] = t
+	return t
+}
+
+// addEvent adds the event to the task.
+func (task *taskDesc) addEvent(ev *trace.Event) {
+	if task == nil {
+		return
+	}
+
+	task.events = append(task.events, ev)
+	task.goroutines[ev.G] = true
+
+	switch typ := ev.Type; typ {
+	case trace.EvUserTaskCreate:
+		task.name = ev.SArgs[0]
+		task.create = ev
+	case trace.EvUserTaskEnd:
+		task.end = ev
+	}
+}
+
+// complete is true only if both start and end events of this task
+// are present in the trace.
+func (task *taskDesc) complete() bool {
+	if task == nil {
+		return false
+	}
+	return task.create != nil && task.end != nil
+}
+
+// firstTimestamp returns the first timestamp of this task found in
+// this trace. If the trace does not contain the task creation event,
+// the first timestamp of the trace will be returned.
+func (task *taskDesc) firstTimestamp() int64 {
+	if task.create != nil {
+		return task.create.Ts
+	}
+	return task.firstTs
+}
+
+// endTimestamp returns the timestamp of the task end event, or the
+// last timestamp of the trace if the task did not end.
+func (task *taskDesc) endTimestamp() int64 {
+	if task.end != nil {
+		return task.end.Ts
+	}
+	return task.lastTs
+}
+
+func (task *taskDesc) duration() time.Duration {
+	return time.Duration(task.endTimestamp()-task.firstTimestamp()) * time.Nanosecond
+}
+
+// regionDesc represents a region.
+type regionDesc struct {
+	Name   string       // user-provided region name
+	TaskID uint64       // internal task id
+	G      uint64       // goroutine that ran the region
+	Start  *trace.Event // region start event; nil if the region started before the trace
+	End    *trace.Event // region end event; nil if the region did not end in the trace
+
+	firstTs, lastTs int64 // timestamps of the first and the last event in the trace
+}
+
+// firstTimestamp returns the timestamp of the region start event,
+// or the first timestamp of the trace if the region started earlier.
+func (s *regionDesc) firstTimestamp() int64 {
+	if s.Start != nil {
+		return s.Start.Ts
+	}
+	return s.firstTs
+}
+
+// lastTimestamp returns the timestamp of the region end event,
+// or the last timestamp of the trace if the region did not end.
+func (s *regionDesc) lastTimestamp() int64 {
+	if s.End != nil {
+		return s.End.Ts
+	}
+	return s.lastTs
+}
+
+func (s *regionDesc) duration() time.Duration {
+	return time.Duration(s.lastTimestamp()-s.firstTimestamp()) * time.Nanosecond
+}
+
+// complete reports whether both the start and the end of the
+// region are in the trace.
+func (s *regionDesc) complete() bool {
+	return s.Start != nil && s.End != nil
+}
+
+// StartString returns the start time of the region relative
+// to the start of the trace, in seconds.
+func (s *regionDesc) StartString() string {
+	return fmt.Sprintf("%2.9f", (time.Duration(s.firstTimestamp()-s.firstTs) * time.Nanosecond).Seconds())
+}
+
+// DurationString returns the duration of the region, marking
+// regions that are cut off by the start or the end of the trace.
+func (s *regionDesc) DurationString() string {
+	d := s.duration().String()
+	if !s.complete() {
+		d += " (incomplete)"
+	}
+	return d
+}
+
+// describeEvent returns a short description of an event
+// that belongs to a task.
+func describeEvent(ev *trace.Event) string {
+	switch ev.Type {
+	case trace.EvUserTaskCreate:
+		return fmt.Sprintf("task %q created", ev.SArgs[0])
+	case trace.EvUserTaskEnd:
+		return "task end"
+	case trace.EvUserRegion:
+		if ev.Args[1] == 0 {
+			return fmt.Sprintf("region %q started", ev.SArgs[0])
+		}
+		return fmt.Sprintf("region %q ended", ev.SArgs[0])
+	case trace.EvUserLog:
+		return formatUserLog(ev)
+	}
+	return ""
+}
+
+// formatUserLog returns the category and the message of a log event.
+func formatUserLog(ev *trace.Event) string {
+	k, v := ev.SArgs[0], ev.SArgs[1]
+	if k == "" {
+		return v
+	}
+	if v == "" {
+		return k
+	}
+	return fmt.Sprintf("%v=%v", k, v)
+}
+
+// taskFilter selects tasks by type and latency.
+type taskFilter struct {
+	name string
+	cond []func(*taskDesc) bool
+}
+
+func (f *taskFilter) match(t *taskDesc) bool {
+	if t == nil {
+		return false
+	}
+	for _, c := range f.cond {
+		if !c(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func newTaskFilter(r *http.Request) (*taskFilter, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	var name []string
+	var conditions []func(*taskDesc) bool
+
+	param := r.Form
+	if id, err := strconv.ParseUint(r.FormValue("taskid"), 10, 64); err == nil {
+		name = append(name, fmt.Sprintf("task %d", id))
+		conditions = append(conditions, func(t *taskDesc) bool {
+			return t.id == id
+		})
+	}
+	if typ, ok := param["type"]; ok && len(typ) > 0 {
+		name = append(name, "type="+typ[0])
+		conditions = append(conditions, func(t *taskDesc) bool {
+			return t.name == typ[0]
+		})
+	}
+	if complete := r.FormValue("complete"); complete == "1" {
+		name = append(name, "complete")
+		conditions = append(conditions, func(t *taskDesc) bool {
+			return t.complete()
+		})
+	} else if complete == "0" {
+		name = append(name, "incomplete")
+		conditions = append(conditions, func(t *taskDesc) bool {
+			return !t.complete()
+		})
+	}
+	if lat, err := time.ParseDuration(r.FormValue("latmin")); err == nil {
+		name = append(name, fmt.Sprintf("latency >= %s", lat))
+		conditions = append(conditions, func(t *taskDesc) bool {
+			return t.complete() && t.duration() >= lat
+		})
+	}
+	if lat, err := time.ParseDuration(r.FormValue("latmax")); err == nil {
+		name = append(name, fmt.Sprintf("latency <= %s", lat))
+		conditions = append(conditions, func(t *taskDesc) bool {
+			return t.complete() && t.duration() <= lat
+		})
+	}
+	if text := r.FormValue("logtext"); text != "" {
+		name = append(name, fmt.Sprintf("log contains %q", text))
+		conditions = append(conditions, func(t *taskDesc) bool {
+			return taskMatches(t, text)
+		})
+	}
+
+	return &taskFilter{name: fmt.Sprint(name), cond: conditions}, nil
+}
+
+// taskMatches reports whether one of the log messages
+// of task t contains text.
+func taskMatches(t *taskDesc, text string) bool {
+	for _, ev := range t.events {
+		switch ev.Type {
+		case trace.EvUserTaskCreate, trace.EvUserRegion, trace.EvUserLog:
+			for _, s := range ev.SArgs {
+				if strings.Contains(s, text) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// regionFilter selects regions by type, starting function and latency.
+type regionFilter struct {
+	name string
+	cond []func(regionTypeID, *regionDesc) bool
+}
+
+func (f *regionFilter) match(id regionTypeID, s *regionDesc) bool {
+	for _, c := range f.cond {
+		if !c(id, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRegionFilter(r *http.Request) (*regionFilter, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	var name []string
+	var conditions []func(regionTypeID, *regionDesc) bool
+
+	param := r.Form
+	if typ, ok := param["type"]; ok && len(typ) > 0 {
+		name = append(name, "type="+typ[0])
+		conditions = append(conditions, func(id regionTypeID, s *regionDesc) bool {
+			return id.Type == typ[0]
+		})
+	}
+	if pc, err := strconv.ParseUint(r.FormValue("pc"), 16, 64); err == nil {
+		name = append(name, fmt.Sprintf("pc=%x", pc))
+		conditions = append(conditions, func(id regionTypeID, s *regionDesc) bool {
+			return id.Frame.PC == pc
+		})
+	}
+
+	if lat, err := time.ParseDuration(r.FormValue("latmin")); err == nil {
+		name = append(name, fmt.Sprintf("latency >= %s", lat))
+		conditions = append(conditions, func(_ regionTypeID, s *regionDesc) bool {
+			return s.complete() && s.duration() >= lat
+		})
+	}
+	if lat, err := time.ParseDuration(r.FormValue("latmax")); err == nil {
+		name = append(name, fmt.Sprintf("latency <= %s", lat))
+		conditions = append(conditions, func(_ regionTypeID, s *regionDesc) bool {
+			return s.complete() && s.duration() <= lat
+		})
+	}
+
+	return &regionFilter{name: fmt.Sprint(name), cond: conditions}, nil
+}
+
+// durationHistogram is a histogram of durations with
+// logarithmically sized buckets.
+type durationHistogram struct {
+	Count                int
+	Buckets              []int
+	MinBucket, MaxBucket int
+}
+
+// Five buckets for every power of 10.
+var logDiv = math.Log(math.Pow(10, 1.0/5))
+
+func (h *durationHistogram) add(d time.Duration) {
+	var bucket int
+	if d > 0 {
+		bucket = int(math.Log(float64(d)) / logDiv)
+	}
+	if len(h.Buckets) <= bucket {
+		h.Buckets = append(h.Buckets, make([]int, bucket-len(h.Buckets)+1)...)
+		h.Buckets = h.Buckets[:cap(h.Buckets)]
+	}
+	h.Buckets[bucket]++
+	if bucket < h.MinBucket || h.Count == 0 {
+		h.MinBucket = bucket
+	}
+	if bucket > h.MaxBucket {
+		h.MaxBucket = bucket
+	}
+	h.Count++
+}
+
+// BucketMin returns the lower bound of the given bucket.
+func (h *durationHistogram) BucketMin(bucket int) time.Duration {
+	return time.Duration(math.Exp(float64(bucket) * logDiv))
+}
+
+// niceDuration formats d with a precision suitable for
+// histogram labels.
+func niceDuration(d time.Duration) string {
+	var rnd time.Duration
+	var unit string
+	switch {
+	case d < 10*time.Microsecond:
+		rnd, unit = time.Nanosecond, "ns"
+	case d < 10*time.Millisecond:
+		rnd, unit = time.Microsecond, "µs"
+	case d < 10*time.Second:
+		rnd, unit = time.Millisecond, "ms"
+	default:
+		rnd, unit = time.Second, "s "
+	}
+	return fmt.Sprintf("%d%s", d/rnd, unit)
+}
+
+// ToHTML renders the histogram as an HTML table. urlmaker
+// returns the link for the entries in the bucket [min, max).
+func (h *durationHistogram) ToHTML(urlmaker func(min, max time.Duration) string) template.HTML {
+	if h == nil || h.Count == 0 {
+		return template.HTML("")
+	}
+
+	const barWidth = 400
+
+	maxCount := 0
+	for _, count := range h.Buckets {
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+
+	w := new(bytes.Buffer)
+	fmt.Fprintf(w, `<table>`)
+	for i := h.MinBucket; i <= h.MaxBucket; i++ {
+		// Tick label.
+		if h.Buckets[i] > 0 {
+			fmt.Fprintf(w, `<tr><td class="histoTime" align="right"><a href="%s">%s</a></td>`,
+				template.HTMLEscapeString(urlmaker(h.BucketMin(i), h.BucketMin(i+1))), niceDuration(h.BucketMin(i)))
+		} else {
+			fmt.Fprintf(w, `<tr><td class="histoTime" align="right">%s</td>`, niceDuration(h.BucketMin(i)))
+		}
+		// Bucket bar.
+		width := h.Buckets[i] * barWidth / maxCount
+		fmt.Fprintf(w, `<td><div style="width:%dpx;background:blue;position:relative">&nbsp;</div></td>`, width)
+		// Bucket count.
+		fmt.Fprintf(w, `<td align="right"><div style="position:relative">%d</div></td>`, h.Buckets[i])
+		fmt.Fprintf(w, "</tr>\n")
+
+	}
+	// Final tick label.
+	fmt.Fprintf(w, `<tr><td align="right">%s</td></tr>`, niceDuration(h.BucketMin(h.MaxBucket+1)))
+	fmt.Fprintf(w, `</table>`)
+	return template.HTML(w.String())
+}
+
+func (h *durationHistogram) String() string {
+	const barWidth = 40
+
+	labels := []string{}
+	maxLabel := 0
+	maxCount := 0
+	for i := h.MinBucket; i <= h.MaxBucket; i++ {
+		// TODO: This formatting is pretty awful.
+		label := fmt.Sprintf("[%-12s%-11s)", h.BucketMin(i).String()+",", h.BucketMin(i+1))
+		labels = append(labels, label)
+		if len(label) > maxLabel {
+			maxLabel = len(label)
+		}
+		count := h.Buckets[i]
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+
+	w := new(bytes.Buffer)
+	for i := h.MinBucket; i <= h.MaxBucket; i++ {
+		count := h.Buckets[i]
+		bar := count * barWidth / maxCount
+		fmt.Fprintf(w, "%*s %-*s %d\n", maxLabel, labels[i-h.MinBucket], barWidth, bytes.Repeat([]byte("█"), bar), count)
+	}
+	return w.String()
+}
+
+// taskStats summarizes the tasks of one type.
+type taskStats struct {
+	Type      string
+	Count     int               // Complete + incomplete tasks
+	Histogram durationHistogram // Complete tasks only
+}
+
+func (s *taskStats) UserTaskURL(complete bool) func(min, max time.Duration) string {
+	return func(min, max time.Duration) string {
+		return fmt.Sprintf("/usertask?type=%s&complete=%v&latmin=%v&latmax=%v", url.QueryEscape(s.Type), template.URLQueryEscaper(complete), template.URLQueryEscaper(min), template.URLQueryEscaper(max))
+	}
+}
+
+func (s *taskStats) add(task *taskDesc) {
+	s.Count++
+	if task.complete() {
+		s.Histogram.add(task.duration())
+	}
+}
+
+var templUserTaskTypes = template.Must(template.New("").Parse(`
+<html>
+<style type="text/css">
+.histoTime {
+   width: 20%;
+   white-space:nowrap;
+}
+
+</style>
+<body>
+Search log text: <form action="/usertask"><input name="logtext" type="text"><input type="submit"></form><br>
+<table border="1" sortable="1">
+<tr>
+<th>Task type</th>
+<th>Count</th>
+<th>Duration distribution (complete tasks)</th>
+</tr>
+{{range $}}
+  <tr>
+    <td>{{.Type}}</td>
+    <td><a href="/usertask?type={{.Type}}">{{.Count}}</a></td>
+    <td>{{.Histogram.ToHTML (.UserTaskURL true)}}</td>
+  </tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+// regionStats summarizes the regions of one type.
+type regionStats struct {
+	regionTypeID
+	Histogram durationHistogram
+}
+
+func (s *regionStats) UserRegionURL() func(min, max time.Duration) string {
+	return func(min, max time.Duration) string {
+		return fmt.Sprintf("/userregion?type=%s&pc=%x&latmin=%v&latmax=%v", url.QueryEscape(s.Type), s.Frame.PC, template.URLQueryEscaper(min), template.URLQueryEscaper(max))
+	}
+}
+
+func (s *regionStats) add(region *regionDesc) {
+	if region.complete() {
+		s.Histogram.add(region.duration())
+	}
+}
+
+var templUserRegionTypes = template.Must(template.New("").Parse(`
+<html>
+<style type="text/css">
+.histoTime {
+   width: 20%;
+   white-space:nowrap;
+}
+
+</style>
+<body>
+<table border="1" sortable="1">
+<tr>
+<th>Region type</th>
+<th>Count</th>
+<th>Duration distribution (complete regions)</th>
+</tr>
+{{range $}}
+  <tr>
+    <td>{{.Type}}<br>{{.Frame.Fn}}<br>{{.Frame.File}}:{{.Frame.Line}}</td>
+    <td><a href="/userregion?type={{.Type}}&pc={{.Frame.PC | printf "%x"}}">{{.Histogram.Count}}</a></td>
+    <td>{{.Histogram.ToHTML (.UserRegionURL)}}</td>
+  </tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+var templUserRegionType = template.Must(template.New("").Parse(`
+<html>
+<body>
+<h2>{{.Title}}</h2>
+<table border="1" sortable="1">
+<tr>
+<th> Goroutine </th>
+<th> Task </th>
+<th> Start time, s </th>
+<th> Total time </th>
+</tr>
+{{range .Data}}
+  <tr>
+    <td> <a href="/trace?goid={{.G}}">{{.G}}</a> </td>
+    <td> {{if .TaskID}}<a href="/usertask?taskid={{.TaskID}}">{{.TaskID}}</a>{{end}} </td>
+    <td> {{.StartString}} </td>
+    <td> {{.DurationString}} </td>
+  </tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+var templUserTaskType = template.Must(template.New("userTask").Funcs(template.FuncMap{
+	"elapsed": elapsed,
+}).Parse(`
+<html>
+<head> <title>User Task: {{.Name}} </title> </head>
+        <style type="text/css">
+                body {
+                    font-family: sans-serif;
+                }
+                table#req-status td.family {
+                    padding-right: 2em;
+                }
+                table#req-status td.active {
+                    padding-right: 1em;
+                }
+                table#req-status td.empty {
+                    color: #aaa;
+                }
+                table#reqs {
+                    margin-top: 1em;
+                }
+                table#reqs tr.first {
+                    font-weight: bold;
+                }
+                table#reqs td {
+                    font-family: monospace;
+                }
+                table#reqs td.when {
+                    text-align: right;
+                    white-space: nowrap;
+                }
+                table#reqs td.elapsed {
+                    padding: 0 0.5em;
+                    text-align: right;
+                    white-space: pre;
+                    width: 10em;
+                }
+                address {
+                    font-size: smaller;
+                    margin-top: 5em;
+                }
+        </style>
+<body>
+
+<h2>User Task: {{.Name}}</h2>
+
+Search log text: <form onsubmit="window.location.search+='&logtext='+window.logtextinput.value; return false">
+<input name="logtext" id="logtextinput" type="text"><input type="submit">
+</form><br>
+
+<table id="reqs">
+<tr><th>When</th><th>Elapsed</th><th>Goroutine ID</th><th>Events</th></tr>
+     {{range $el := $.Entry}}
+        <tr class="first">
+                <td class="when">{{$el.WhenString}}</td>
+                <td class="elapsed">{{$el.Duration}}</td>
+		<td></td>
+                <td>Task {{$el.ID}}
+                    {{if not $el.Complete}}(incomplete){{end}}</td>
+        </tr>
+        {{range $el.Events}}
+        <tr>
+                <td class="when">{{.WhenString}}</td>
+                <td class="elapsed">{{elapsed .Elapsed}}</td>
+		<td class="goid"><a href="/trace?goid={{.Go}}">{{.Go}}</a></td>
+                <td>{{.What}}</td>
+        </tr>
+        {{end}}
+    {{end}}
+</body>
+</html>
+`))
+
+func elapsed(d time.Duration) string {
+	b := []byte(fmt.Sprintf("%.9f", d.Seconds()))
+
+	// For subsecond durations, blank all zeros before decimal point,
+	// and all zeros between the decimal point and the first non-zero digit.
+	if d < time.Second {
+		dot := bytes.IndexByte(b, '.')
+		for i := 0; i < dot; i++ {
+			b[i] = ' '
+		}
+		for i := dot + 1; i < len(b); i++ {
+			if b[i] == '0' {
+				b[i] = ' '
+			} else {
+				break
+			}
+		}
+	}
+
+	return string(b)
+}
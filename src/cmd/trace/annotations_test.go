@@ -0,0 +1,165 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"internal/trace"
+	"reflect"
+	rtrace "runtime/trace"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// traceProgram runs f while tracing and returns the parsed events.
+func traceProgram(t *testing.T, f func()) []*trace.Event {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := rtrace.Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	f()
+	rtrace.Stop()
+
+	res, err := trace.Parse(buf, "")
+	if err == trace.ErrTimeOrder {
+		t.Skipf("skipping due to golang.org/issue/16755 (timestamps are unreliable): %v", err)
+	}
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	return res.Events
+}
+
+func TestAnalyzeAnnotations(t *testing.T) {
+	if rtrace.IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	events := traceProgram(t, func() {
+		ctx, task0 := rtrace.NewTask(context.Background(), "task0")
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rtrace.WithRegion(ctx, "region0", func() {
+				rtrace.Log(ctx, "key0", "value0")
+			})
+		}()
+		wg.Wait()
+
+		ctx1, task1 := rtrace.NewTask(ctx, "task1")
+		rtrace.WithRegion(ctx1, "region1", func() {
+			time.Sleep(time.Millisecond)
+		})
+		task1.End()
+		task0.End()
+
+		_, task2 := rtrace.NewTask(context.Background(), "task2") // incomplete
+		_ = task2
+
+		rtrace.WithRegion(context.Background(), "region2", func() {})
+	})
+
+	res, err := analyzeAnnotationEvents(events)
+	if err != nil {
+		t.Fatalf("failed to analyze annotations: %v", err)
+	}
+
+	byName := make(map[string]*taskDesc)
+	for _, task := range res.tasks {
+		byName[task.name] = task
+	}
+	for name, complete := range map[string]bool{"task0": true, "task1": true, "task2": false} {
+		task := byName[name]
+		if task == nil {
+			t.Errorf("task %q not found; got %v", name, res.tasks)
+			continue
+		}
+		if task.complete() != complete {
+			t.Errorf("task %q: complete = %v, want %v\n%v", name, task.complete(), complete, task)
+		}
+	}
+
+	task0, task1 := byName["task0"], byName["task1"]
+	if task0 != nil && task1 != nil {
+		if task1.parent != task0 {
+			t.Errorf("task1's parent is %v, want task0", task1.parent)
+		}
+		if len(task0.children) != 1 || task0.children[0] != task1 {
+			t.Errorf("task0's children are %v, want [task1]", task0.children)
+		}
+		if got := regionNames(task0.regions); !reflect.DeepEqual(got, []string{"region0"}) {
+			t.Errorf("task0's regions are %v, want [region0]", got)
+		}
+		if len(task0.goroutines) < 2 {
+			t.Errorf("task0 has %d goroutines, want at least 2", len(task0.goroutines))
+		}
+		if d := task1.duration(); d < time.Millisecond {
+			t.Errorf("task1 took %v, want at least 1ms", d)
+		}
+		var logs []string
+		for _, ev := range task0.events {
+			if ev.Type == trace.EvUserLog {
+				logs = append(logs, formatUserLog(ev))
+			}
+		}
+		if !reflect.DeepEqual(logs, []string{"key0=value0"}) {
+			t.Errorf("task0's logs are %v, want [key0=value0]", logs)
+		}
+	}
+
+	var regions []string
+	for id, rs := range res.regions {
+		if id.Frame.Fn == "" {
+			t.Errorf("region %q has no frame", id.Type)
+		}
+		for _, r := range rs {
+			if !r.complete() {
+				t.Errorf("region %q is incomplete", r.Name)
+			}
+			regions = append(regions, r.Name)
+		}
+	}
+	sort.Strings(regions)
+	if want := []string{"region0", "region1", "region2"}; !reflect.DeepEqual(regions, want) {
+		t.Errorf("regions are %v, want %v", regions, want)
+	}
+}
+
+func regionNames(regions []*regionDesc) (ret []string) {
+	for _, s := range regions {
+		ret = append(ret, s.Name)
+	}
+	return ret
+}
+
+func TestDurationHistogram(t *testing.T) {
+	var h durationHistogram
+	for _, d := range []time.Duration{3 * time.Microsecond, 3 * time.Microsecond, 3 * time.Millisecond, 3 * time.Second} {
+		h.add(d)
+	}
+	if h.Count != 4 {
+		t.Errorf("Count = %d, want 4", h.Count)
+	}
+	total := 0
+	for i := h.MinBucket; i <= h.MaxBucket; i++ {
+		if h.BucketMin(i) > h.BucketMin(i+1) {
+			t.Errorf("bucket %d: min %v > next bucket's min %v", i, h.BucketMin(i), h.BucketMin(i+1))
+		}
+		total += h.Buckets[i]
+	}
+	if total != h.Count {
+		t.Errorf("buckets hold %d samples, want %d", total, h.Count)
+	}
+	if min := h.BucketMin(h.MinBucket); min > 3*time.Microsecond {
+		t.Errorf("smallest bucket starts at %v, want <= 3µs", min)
+	}
+	if max := h.BucketMin(h.MaxBucket + 1); max <= 3*time.Second {
+		t.Errorf("largest bucket ends at %v, want > 3s", max)
+	}
+}
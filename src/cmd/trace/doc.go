@@ -27,6 +27,13 @@ Supported profile types are:
 Then, you can use the pprof tool to analyze the profile:
 	go tool pprof TYPE.pprof
 
+User annotations recorded with runtime/trace.NewTask, WithRegion and Log
+are shown on the 'User-defined tasks' and 'User-defined regions' pages,
+which group the tasks and regions by type and show the distribution of
+their latencies. Each bucket of a distribution links to the list of
+matching tasks or regions, along with the log messages and regions of
+each task.
+
 Note that while the various profiles available when launching
 'go tool trace' work on every browser, the trace viewer itself
 (the 'view trace' page) comes from the Chrome/Chromium project
@@ -560,6 +560,12 @@ func generateTrace(params *traceParams) (ViewerData, error) {
 			ctx.emitInstant(ev, "syscall")
 		case trace.EvGoSysExit:
 			ctx.emitArrow(ev, "sysexit")
+		case trace.EvUserTaskCreate:
+			ctx.emitInstant(ev, "task start")
+		case trace.EvUserTaskEnd:
+			ctx.emitInstant(ev, "task end")
+		case trace.EvUserLog:
+			ctx.emitInstant(ev, formatUserLog(ev))
 		}
 		// Emit any counter updates.
 		ctx.emitThreadCounters(ev)
@@ -695,11 +701,30 @@ func (ctx *traceContext) emitThreadCounters(ev *trace.Event) {
 
 func (ctx *traceContext) emitInstant(ev *trace.Event, name string) {
 	var arg interface{}
-	if ev.Type == trace.EvProcStart {
+	switch ev.Type {
+	case trace.EvProcStart:
 		type Arg struct {
 			ThreadID uint64
 		}
 		arg = &Arg{ev.Args[0]}
+	case trace.EvUserTaskCreate:
+		type Arg struct {
+			TaskID   uint64
+			TaskName string
+		}
+		arg = &Arg{TaskID: ev.Args[0], TaskName: ev.SArgs[0]}
+	case trace.EvUserTaskEnd:
+		type Arg struct {
+			TaskID uint64
+		}
+		arg = &Arg{TaskID: ev.Args[0]}
+	case trace.EvUserLog:
+		type Arg struct {
+			TaskID   uint64
+			Category string
+			Message  string
+		}
+		arg = &Arg{TaskID: ev.Args[0], Category: ev.SArgs[0], Message: ev.SArgs[1]}
 	}
 	ctx.emit(&ViewerEvent{Name: name, Phase: "I", Scope: "t", Time: ctx.time(ev), Tid: ctx.proc(ev), Stack: ctx.stack(ev.Stk), Arg: arg})
 }
@@ -261,7 +261,7 @@ func checkFmtPrintfCall(f *File, node ast.Node) {
 	}
 	if ok {
 		if strings.HasSuffix(name, "f") {
-			f.checkPrintf(call, shortName)
+			f.checkPrintf(call, shortName, name == "fmt.Errorf")
 		} else {
 			f.checkPrint(call, shortName)
 		}
@@ -291,6 +291,7 @@ type formatState struct {
 	flags    []byte // the list of # + etc.
 	argNums  []int  // the successive argument numbers that are consumed, adjusted to refer to actual arg in call
 	firstArg int    // Index of first argument after the format in the Printf call.
+	wrapErrs bool   // Whether the call accepts %w, as fmt.Errorf does.
 	// Used only during parse.
 	file         *File
 	call         *ast.CallExpr
@@ -301,7 +302,8 @@ type formatState struct {
 }
 
 // checkPrintf checks a call to a formatted print routine such as Printf.
-func (f *File) checkPrintf(call *ast.CallExpr, name string) {
+// wrapErrs reports whether the routine accepts the %w verb.
+func (f *File) checkPrintf(call *ast.CallExpr, name string, wrapErrs bool) {
 	format, idx := formatString(f, call)
 	if idx < 0 {
 		if *verbose {
@@ -331,6 +333,7 @@ func (f *File) checkPrintf(call *ast.CallExpr, name string) {
 			return
 		}
 		w = len(state.format)
+		state.wrapErrs = wrapErrs
 		if !f.okPrintfArg(call, state) { // One error per format is enough.
 			return
 		}
@@ -507,6 +510,7 @@ const (
 	argFloat
 	argComplex
 	argPointer
+	argError
 	anyType printfArgType = ^0
 )
 
@@ -549,6 +553,7 @@ var printVerbs = []printVerb{
 	{'T', "-", anyType},
 	{'U', "-#", argRune | argInt},
 	{'v', allFlags, anyType},
+	{'w', allFlags, argError},
 	{'x', sharpNumFlag, argRune | argInt | argString},
 	{'X', sharpNumFlag, argRune | argInt | argString},
 }
@@ -567,6 +572,11 @@ func (f *File) okPrintfArg(call *ast.CallExpr, state *formatState) (ok bool) {
 		}
 	}
 
+	if state.verb == 'w' && !state.wrapErrs {
+		f.Badf(call.Pos(), "%s does not support error-wrapping directive %%w", state.name)
+		return false
+	}
+
 	// Does current arg implement fmt.Formatter?
 	formatter := false
 	if state.argNum < len(call.Args) {
@@ -575,7 +585,7 @@ func (f *File) okPrintfArg(call *ast.CallExpr, state *formatState) (ok bool) {
 		}
 	}
 
-	if !formatter {
+	if !formatter || state.verb == 'w' {
 		if !found {
 			f.Badf(call.Pos(), "%s format %s has unknown verb %c", state.name, state.format, state.verb)
 			return false
@@ -610,7 +620,7 @@ func (f *File) okPrintfArg(call *ast.CallExpr, state *formatState) (ok bool) {
 			return false
 		}
 	}
-	if state.verb == '%' || formatter {
+	if state.verb == '%' || formatter && state.verb != 'w' {
 		return true
 	}
 	argNum := state.argNums[len(state.argNums)-1]
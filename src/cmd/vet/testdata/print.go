@@ -280,6 +280,15 @@ func PrintfTests() {
 
 	Printf(someString(), "hello") // OK
 
+	// error wrapping
+	err := fmt.Errorf("boom")
+	var errIface interface{} = err
+	_ = fmt.Errorf("wrap: %w", err)             // OK
+	_ = fmt.Errorf("wrap: %w and %w", err, err) // OK
+	_ = fmt.Errorf("wrap: %w", stringer(1))     // ERROR "Errorf format %w has arg stringer\(1\) of wrong type testdata.stringer"
+	_ = fmt.Errorf("wrap: %w", errIface)        // ERROR "Errorf format %w has arg errIface of wrong type interface{}"
+	fmt.Printf("wrap: %w", err)                 // ERROR "Printf does not support error-wrapping directive %w"
+	_ = fmt.Sprintf("wrap: %w", err)            // ERROR "Sprintf does not support error-wrapping directive %w"
 }
 
 func someString() string { return "X" }
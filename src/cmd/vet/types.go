@@ -138,6 +138,10 @@ func (f *File) matchArgTypeInternal(t printfArgType, typ types.Type, arg ast.Exp
 			return true // probably a type check problem
 		}
 	}
+	// %w accepts only errors; fmt.Errorf does not consult Format for it.
+	if t == argError {
+		return types.ConvertibleTo(typ, errorType)
+	}
 	// If the type implements fmt.Formatter, we have nothing to check.
 	if f.isFormatter(typ) {
 		return true
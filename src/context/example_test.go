@@ -6,6 +6,7 @@ package context_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -114,3 +115,38 @@ func ExampleWithValue() {
 	// found value: Go
 	// key not found: color
 }
+
+// This example records why a context was canceled, so that code observing
+// the cancelation can report more than context.Canceled.
+func ExampleWithCancelCause() {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("client went away"))
+
+	<-ctx.Done()
+	fmt.Println(ctx.Err())
+	fmt.Println(context.Cause(ctx))
+
+	// Output:
+	// context canceled
+	// client went away
+}
+
+// This example uses AfterFunc to close a resource when a context is done,
+// without starting a goroutine that waits on ctx.Done.
+func ExampleAfterFunc() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	closed := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		fmt.Println("closing connection")
+		close(closed)
+	})
+
+	cancel()
+	<-closed
+	fmt.Println("stopped:", stop())
+
+	// Output:
+	// closing connection
+	// stopped: false
+}
@@ -31,6 +31,7 @@ type importer struct {
 	pkgList       []*types.Package   // in order of appearance
 	typList       []types.Type       // in order of appearance
 	interfaceList []*types.Interface // for delayed completion only
+	generics      []*genericDecl     // for delayed setup only
 	trackAllTypes bool
 
 	// position encoding
@@ -102,10 +103,10 @@ func BImportData(fset *token.FileSet, imports map[string]*types.Package, data []
 
 	// read version specific flags - extend as necessary
 	switch p.version {
-	// case 6:
+	// case 7:
 	// 	...
 	//	fallthrough
-	case 5, 4, 3, 2, 1:
+	case 6, 5, 4, 3, 2, 1:
 		p.debugFormat = p.rawStringln(p.rawByte()) == "debug"
 		p.trackAllTypes = p.int() != 0
 		p.posInfoFormat = p.int() != 0
@@ -141,6 +142,9 @@ func BImportData(fset *token.FileSet, imports map[string]*types.Package, data []
 
 	// ignore compiler-specific import data
 
+	// set up generic functions and types
+	p.declareGenerics()
+
 	// complete interfaces
 	// TODO(gri) re-investigate if we still need to do this in a delayed fashion
 	for _, typ := range p.interfaceList {
@@ -285,6 +289,9 @@ func (p *importer) obj(tag int) {
 		sig := types.NewSignature(nil, params, result, isddd)
 		p.declare(types.NewFunc(pos, pkg, name, sig))
 
+	case genericTag:
+		p.generic()
+
 	default:
 		errorf("unexpected object tag %d", tag)
 	}
@@ -392,6 +399,9 @@ func (p *importer) typ(parent *types.Package) types.Type {
 		// read type object
 		pos := p.pos()
 		parent, name := p.qualifiedName()
+		if p.version >= 6 && strings.HasSuffix(name, "]") {
+			return p.instance(parent, name)
+		}
 		scope := parent.Scope()
 		obj := scope.Lookup(name)
 
@@ -520,6 +530,37 @@ func (p *importer) typ(parent *types.Package) types.Type {
 		}
 		return t
 
+	case constraintTag:
+		// see comment for interfaceTag
+		n := len(p.typList)
+		if p.trackAllTypes {
+			p.record(nil)
+		}
+
+		var embeddeds []*types.Named
+		for n := p.int(); n > 0; n-- {
+			p.pos()
+			embeddeds = append(embeddeds, p.typ(parent).(*types.Named))
+		}
+		methods := p.methodList(parent)
+		comparable := p.bool()
+		var unions []*types.Union
+		for n := p.int(); n > 0; n-- {
+			var terms []*types.Term
+			for m := p.int(); m > 0; m-- {
+				tilde := p.bool()
+				terms = append(terms, types.NewTerm(tilde, p.typ(parent)))
+			}
+			unions = append(unions, types.NewUnion(terms))
+		}
+
+		t := types.NewConstraint(methods, embeddeds, unions, comparable)
+		p.interfaceList = append(p.interfaceList, t)
+		if p.trackAllTypes {
+			p.typList[n] = t
+		}
+		return t
+
 	case mapTag:
 		t := new(types.Map)
 		if p.trackAllTypes {
@@ -772,6 +813,10 @@ func (p *importer) float() constant.Value {
 // ----------------------------------------------------------------------------
 // Low-level decoders
 
+func (p *importer) bool() bool {
+	return p.int() != 0
+}
+
 func (p *importer) tagOrIndex() int {
 	if p.debugFormat {
 		p.marker('t')
@@ -937,6 +982,10 @@ const (
 
 	// Type aliases
 	aliasTag
+
+	// Generics
+	genericTag
+	constraintTag
 )
 
 var predeclared = []types.Type{
@@ -5,6 +5,7 @@
 package gcimporter
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"internal/testenv"
@@ -472,3 +473,82 @@ func lookupObj(t *testing.T, scope *types.Scope, name string) types.Object {
 	t.Fatalf("%s not found", name)
 	return nil
 }
+
+func TestImportGenerics(t *testing.T) {
+	skipSpecialPlatforms(t)
+
+	// This package only handles gc export data.
+	if runtime.Compiler != "gc" {
+		t.Skipf("gc-built packages not available (compiler = %s)", runtime.Compiler)
+	}
+
+	// On windows, we have to set the -D option for the compiler to avoid having a drive
+	// letter and an illegal ':' in the import path - just skip it (see also issue #3483).
+	if runtime.GOOS == "windows" {
+		t.Skip("avoid dealing with relative paths/drive letters on windows")
+	}
+
+	if f := compile(t, "testdata", "generics.go"); f != "" {
+		defer os.Remove(f)
+	}
+
+	// The generic type List is built on the unexported types node
+	// and inner, which must be imported too.
+	pkg := importPkg(t, "./testdata/generics")
+	obj := lookupObj(t, pkg.Scope(), "List")
+	list, ok := obj.Type().(*types.Named)
+	if !ok || len(list.TypeParams()) != 1 {
+		t.Fatalf("List = %v, want a generic type with one type parameter", obj.Type())
+	}
+	want := "struct{head *" + pkg.Path() + ".node[T]; n " + pkg.Path() + ".inner}"
+	if got := list.Underlying().String(); got != want {
+		t.Errorf("underlying type of List = %s, want %s", got, want)
+	}
+	for _, name := range []string{"Push", "Len"} {
+		if m, _, _ := types.LookupFieldOrMethod(types.NewPointer(list), false, pkg, name); m == nil {
+			t.Errorf("List.%s not found", name)
+		}
+	}
+	lookupObj(t, pkg.Scope(), "Map")
+}
+
+func TestExportVersion(t *testing.T) {
+	skipSpecialPlatforms(t)
+
+	// This package only handles gc export data.
+	if runtime.Compiler != "gc" {
+		t.Skipf("gc-built packages not available (compiler = %s)", runtime.Compiler)
+	}
+
+	// Packages that don't use type parameters keep the version 5 layout.
+	for _, test := range []struct {
+		filename, want string
+	}{
+		{"exports.go", "version 5"},
+		{"generics.go", "version 6"},
+	} {
+		f := compile(t, "testdata", test.filename)
+		if f == "" {
+			continue
+		}
+		defer os.Remove(f)
+
+		file, err := os.Open(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		buf := bufio.NewReader(file)
+		if _, err := FindExportData(buf); err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		line, err := buf.ReadString('\n')
+		if err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		if got := strings.TrimSpace(line); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.filename, got, test.want)
+		}
+	}
+}
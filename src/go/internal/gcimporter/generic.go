@@ -0,0 +1,649 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the import of generic functions and types.
+//
+// The export data contains the source text of generic declarations
+// rather than their types, since importing compilers instantiate
+// them from source. Here, the declarations are parsed and their
+// types set up from the (type parameter, type, and function) names
+// they refer to, which are resolved in the imported package, the
+// packages imported by the files the declarations come from, and
+// the universe. Function and method bodies are ignored.
+
+package gcimporter
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// A genericDecl is an imported generic function or type declaration,
+// together with the methods of a generic type.
+type genericDecl struct {
+	pkg   *types.Package
+	name  string
+	units []*genericUnit // declaration, followed by methods
+}
+
+// A genericUnit is the source text of a declaration and the imports
+// of the file it was declared in, by (local) package name.
+type genericUnit struct {
+	imports map[string]*types.Package
+	src     string
+	decl    ast.Decl // parsed declaration
+}
+
+// generic reads a generic function or type declaration and records
+// it; the declarations are set up by declareGenerics once all other
+// objects were imported.
+func (p *importer) generic() {
+	p.pos()
+	pkg, name := p.qualifiedName()
+	d := &genericDecl{pkg: pkg, name: name}
+	for n := p.int(); n > 0; n-- {
+		imports := make(map[string]*types.Package)
+		for m := p.int(); m > 0; m-- {
+			pkg := p.pkg()
+			if pkg.Path() == "unsafe" {
+				// The exporter writes a package reference;
+				// the objects are in types.Unsafe.
+				pkg = types.Unsafe
+			}
+			imports[p.string()] = pkg
+		}
+		d.units = append(d.units, &genericUnit{imports: imports, src: p.string()})
+	}
+	if len(d.units) == 0 {
+		errorf("generic %s has no declaration", name)
+	}
+	p.generics = append(p.generics, d)
+
+	// Make generic types known to instance types that refer to them.
+	p.parseGeneric(d)
+	if _, ok := d.units[0].decl.(*ast.GenDecl); ok {
+		p.genericType(pkg, name)
+	}
+}
+
+// parseGeneric parses the source texts of the declarations of d.
+func (p *importer) parseGeneric(d *genericDecl) {
+	for _, u := range d.units {
+		filename := d.pkg.Path() + ".go"
+		file, err := parser.ParseFile(p.fset, filename, "package "+d.pkg.Name()+"\n"+u.src, 0)
+		if err != nil || len(file.Decls) != 1 {
+			errorf("invalid generic declaration %s.%s: %v", d.pkg.Path(), d.name, err)
+		}
+		u.decl = file.Decls[0]
+	}
+}
+
+// genericType returns the generic named type with the given name,
+// declaring it if it doesn't exist yet. Its type parameters and
+// underlying type are set up by declareGenerics.
+func (p *importer) genericType(pkg *types.Package, name string) *types.Named {
+	scope := pkg.Scope()
+	obj := scope.Lookup(name)
+	if obj == nil {
+		obj = types.NewTypeName(token.NoPos, pkg, name, nil)
+		types.NewNamed(obj.(*types.TypeName), nil, nil)
+		scope.Insert(obj)
+	}
+	t, _ := obj.Type().(*types.Named)
+	if t == nil {
+		errorf("pkg = %s, name = %s => %s is not a generic type", pkg, name, obj)
+	}
+	return t
+}
+
+// declareGenerics sets up the generic functions and types read by
+// generic. All type parameters are declared before any constraint or
+// underlying type is resolved, since they may refer to each other.
+func (p *importer) declareGenerics() {
+	r := &genericResolver{p: p, specs: make(map[*types.TypeName]*ast.TypeSpec)}
+
+	// Phase 1: Declare the type parameters of generic types.
+	type typeDecl struct {
+		t       *types.Named
+		spec    *ast.TypeSpec
+		tparams []*types.TypeParam
+		unit    *genericUnit
+	}
+	var tdecls []typeDecl
+	for _, d := range p.generics {
+		u := d.units[0]
+		g, ok := u.decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		spec, _ := g.Specs[0].(*ast.TypeSpec)
+		if spec == nil || spec.TypeParams == nil {
+			errorf("generic %s.%s is not a generic type", d.pkg.Path(), d.name)
+		}
+		t := p.genericType(d.pkg, d.name)
+		tparams := r.declareTypeParams(d.pkg, spec.TypeParams)
+		t.SetTypeParams(tparams)
+		r.specs[t.Obj()] = spec
+		tdecls = append(tdecls, typeDecl{t, spec, tparams, u})
+	}
+
+	// Phase 2: Set up the underlying types of generic types.
+	for _, d := range tdecls {
+		r.enter(d.t.Obj().Pkg(), d.unit.imports, d.tparams)
+		u := r.typ(d.spec.Type).Underlying()
+		if u == nil {
+			u = types.Typ[types.Invalid]
+		}
+		d.t.SetUnderlying(u)
+	}
+
+	// Phase 3: Set up constraints, methods, and generic functions.
+	for _, d := range tdecls {
+		r.enter(d.t.Obj().Pkg(), d.unit.imports, d.tparams)
+		r.bounds(d.spec.TypeParams, d.tparams)
+	}
+	for _, d := range p.generics {
+		switch decl := d.units[0].decl.(type) {
+		case *ast.GenDecl:
+			for _, u := range d.units[1:] {
+				r.method(p.genericType(d.pkg, d.name), u)
+			}
+		case *ast.FuncDecl:
+			if decl.Recv != nil || decl.Type.TypeParams == nil {
+				errorf("generic %s.%s is not a generic function", d.pkg.Path(), d.name)
+			}
+			tparams := r.declareTypeParams(d.pkg, decl.Type.TypeParams)
+			r.enter(d.pkg, d.units[0].imports, tparams)
+			r.bounds(decl.Type.TypeParams, tparams)
+			params, results, variadic := r.signature(decl.Type)
+			sig := types.NewSignatureType(nil, nil, tparams, params, results, variadic)
+			p.declare(types.NewFunc(decl.Name.Pos(), d.pkg, d.name, sig))
+		}
+	}
+	p.generics = nil
+}
+
+// A genericResolver resolves the type expressions of generic
+// declarations.
+type genericResolver struct {
+	p       *importer
+	specs   map[*types.TypeName]*ast.TypeSpec // generic type declarations
+	pkg     *types.Package                    // package of the current declaration
+	imports map[string]*types.Package         // imports of the current declaration
+	tparams map[string]*types.TypeParam       // type parameters in scope
+}
+
+// enter sets up r to resolve names of a declaration in package pkg,
+// in the scope of the given imports and type parameters.
+func (r *genericResolver) enter(pkg *types.Package, imports map[string]*types.Package, tparams []*types.TypeParam) {
+	r.pkg = pkg
+	r.imports = imports
+	r.tparams = make(map[string]*types.TypeParam)
+	for _, tpar := range tparams {
+		r.tparams[tpar.Obj().Name()] = tpar
+	}
+}
+
+// declareTypeParams returns new type parameters for the names in list.
+// Their constraints are set by bounds.
+func (r *genericResolver) declareTypeParams(pkg *types.Package, list *ast.FieldList) []*types.TypeParam {
+	var tparams []*types.TypeParam
+	for _, f := range list.List {
+		for _, name := range f.Names {
+			obj := types.NewTypeName(name.Pos(), pkg, name.Name, nil)
+			tparams = append(tparams, types.NewTypeParam(obj, len(tparams), nil))
+		}
+	}
+	return tparams
+}
+
+// bounds sets the constraints of tparams, declared by list.
+func (r *genericResolver) bounds(list *ast.FieldList, tparams []*types.TypeParam) {
+	i := 0
+	for _, f := range list.List {
+		bound := r.bound(f.Type)
+		for range f.Names {
+			tparams[i].SetConstraint(bound)
+			i++
+		}
+	}
+}
+
+// bound resolves the constraint e. As for the type checker, a union
+// or a non-interface type is the type element of an implicit interface.
+func (r *genericResolver) bound(e ast.Expr) types.Type {
+	if isUnion(e) {
+		return r.newInterface(nil, nil, []*types.Union{r.union(e)}, false)
+	}
+	typ := r.typ(e)
+	if r.isInterface(typ) {
+		return typ
+	}
+	u := types.NewUnion([]*types.Term{types.NewTerm(false, typ)})
+	return r.newInterface(nil, nil, []*types.Union{u}, false)
+}
+
+// method sets up the method of the generic type t declared by u.
+func (r *genericResolver) method(t *types.Named, u *genericUnit) {
+	decl, _ := u.decl.(*ast.FuncDecl)
+	if decl == nil || decl.Recv == nil || len(decl.Recv.List) != 1 {
+		errorf("invalid method of generic type %s", t)
+	}
+	field := decl.Recv.List[0]
+	rtyp := field.Type
+	for {
+		if x, ok := rtyp.(*ast.ParenExpr); ok {
+			rtyp = x.X
+			continue
+		}
+		break
+	}
+	ptr := false
+	if x, ok := rtyp.(*ast.StarExpr); ok {
+		rtyp = x.X
+		ptr = true
+	}
+	var names []ast.Expr
+	switch x := rtyp.(type) {
+	case *ast.IndexExpr:
+		names = []ast.Expr{x.Index}
+	case *ast.IndexListExpr:
+		names = x.Indices
+	}
+	tparams := t.TypeParams()
+	if len(names) != len(tparams) {
+		errorf("invalid receiver type %s of method %s", t, decl.Name.Name)
+	}
+
+	// The receiver type parameters have the constraints of the type
+	// parameters of t, under their new names.
+	rparams := make([]*types.TypeParam, len(names))
+	for i, name := range names {
+		id, _ := name.(*ast.Ident)
+		if id == nil {
+			errorf("invalid receiver type parameter of method %s", decl.Name.Name)
+		}
+		obj := types.NewTypeName(id.Pos(), t.Obj().Pkg(), id.Name, nil)
+		rparams[i] = types.NewTypeParam(obj, i, nil)
+	}
+	// Resolve them in the scope of the receiver type parameters, under
+	// the names of the type parameters of t.
+	spec := r.specs[t.Obj()]
+	r.enter(t.Obj().Pkg(), u.imports, nil)
+	i := 0
+	for _, f := range spec.TypeParams.List {
+		for _, name := range f.Names {
+			r.tparams[name.Name] = rparams[i]
+			i++
+		}
+	}
+	r.bounds(spec.TypeParams, rparams)
+	r.enter(t.Obj().Pkg(), u.imports, rparams)
+
+	targs := make([]types.Type, len(rparams))
+	for i, tpar := range rparams {
+		targs[i] = tpar
+	}
+	inst, err := types.Instantiate(t, targs, false)
+	if err != nil {
+		errorf("%v", err)
+	}
+	if ptr {
+		inst = types.NewPointer(inst)
+	}
+	var rname string
+	if len(field.Names) > 0 {
+		rname = field.Names[0].Name
+	}
+	recv := types.NewVar(field.Pos(), t.Obj().Pkg(), rname, inst)
+	params, results, variadic := r.signature(decl.Type)
+	sig := types.NewSignatureType(recv, rparams, nil, params, results, variadic)
+	t.AddMethod(types.NewFunc(decl.Name.Pos(), t.Obj().Pkg(), decl.Name.Name, sig))
+}
+
+// typ resolves the type expression e.
+func (r *genericResolver) typ(e ast.Expr) types.Type {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return r.typeName(e)
+
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			if pkg := r.imports[x.Name]; pkg != nil {
+				if obj, ok := pkg.Scope().Lookup(e.Sel.Name).(*types.TypeName); ok {
+					return obj.Type()
+				}
+			}
+		}
+		errorf("cannot resolve type %s", types.ExprString(e))
+
+	case *ast.ParenExpr:
+		return r.typ(e.X)
+
+	case *ast.StarExpr:
+		return types.NewPointer(r.typ(e.X))
+
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return types.NewSlice(r.typ(e.Elt))
+		}
+		return types.NewArray(r.typ(e.Elt), r.arrayLength(e.Len))
+
+	case *ast.MapType:
+		return types.NewMap(r.typ(e.Key), r.typ(e.Value))
+
+	case *ast.ChanType:
+		dir := types.SendRecv
+		switch e.Dir {
+		case ast.SEND:
+			dir = types.SendOnly
+		case ast.RECV:
+			dir = types.RecvOnly
+		}
+		return types.NewChan(dir, r.typ(e.Value))
+
+	case *ast.FuncType:
+		params, results, variadic := r.signature(e)
+		return types.NewSignature(nil, params, results, variadic)
+
+	case *ast.StructType:
+		return r.structType(e)
+
+	case *ast.InterfaceType:
+		return r.interfaceType(e)
+
+	case *ast.IndexExpr:
+		return r.instance(e.X, []ast.Expr{e.Index})
+
+	case *ast.IndexListExpr:
+		return r.instance(e.X, e.Indices)
+	}
+
+	errorf("unexpected type expression %s (%T)", types.ExprString(e), e)
+	panic("unreachable")
+}
+
+// typeName resolves the type name id.
+func (r *genericResolver) typeName(id *ast.Ident) types.Type {
+	if tpar := r.tparams[id.Name]; tpar != nil {
+		return tpar
+	}
+	obj := r.pkg.Scope().Lookup(id.Name)
+	if obj == nil {
+		if pkg := r.imports["."]; pkg != nil {
+			obj = pkg.Scope().Lookup(id.Name)
+		}
+	}
+	if obj == nil {
+		obj = types.Universe.Lookup(id.Name)
+	}
+	tname, _ := obj.(*types.TypeName)
+	if tname == nil || tname.Type() == nil {
+		errorf("cannot resolve type %s", id.Name)
+	}
+	return tname.Type()
+}
+
+// instance resolves the instance of the generic type x with the type
+// arguments list.
+func (r *genericResolver) instance(x ast.Expr, list []ast.Expr) types.Type {
+	orig, _ := r.typ(x).(*types.Named)
+	if orig == nil {
+		errorf("%s is not a generic type", types.ExprString(x))
+	}
+	targs := make([]types.Type, len(list))
+	for i, e := range list {
+		targs[i] = r.typ(e)
+	}
+	t, err := types.Instantiate(orig, targs, false)
+	if err != nil {
+		errorf("%v", err)
+	}
+	return t
+}
+
+// arrayLength evaluates the array length e, a constant expression.
+func (r *genericResolver) arrayLength(e ast.Expr) int64 {
+	tv, err := types.Eval(r.p.fset, r.pkg, token.NoPos, types.ExprString(e))
+	if err == nil && tv.Value != nil {
+		if n, ok := constant.Int64Val(constant.ToInt(tv.Value)); ok {
+			return n
+		}
+	}
+	errorf("invalid array length %s", types.ExprString(e))
+	panic("unreachable")
+}
+
+// signature resolves the parameters and results of the function type f.
+func (r *genericResolver) signature(f *ast.FuncType) (params, results *types.Tuple, variadic bool) {
+	params, variadic = r.tuple(f.Params, true)
+	results, _ = r.tuple(f.Results, false)
+	return
+}
+
+// tuple resolves the parameter list list.
+func (r *genericResolver) tuple(list *ast.FieldList, variadicOk bool) (*types.Tuple, bool) {
+	if list == nil {
+		return nil, false
+	}
+	var vars []*types.Var
+	variadic := false
+	for i, f := range list.List {
+		ftype := f.Type
+		if t, ok := ftype.(*ast.Ellipsis); ok && variadicOk && i == len(list.List)-1 {
+			ftype = t.Elt
+			variadic = true
+		}
+		typ := r.typ(ftype)
+		if variadic {
+			typ = types.NewSlice(typ)
+		}
+		if len(f.Names) == 0 {
+			vars = append(vars, types.NewParam(f.Pos(), r.pkg, "", typ))
+			continue
+		}
+		for _, name := range f.Names {
+			vars = append(vars, types.NewParam(name.Pos(), r.pkg, name.Name, typ))
+		}
+	}
+	return types.NewTuple(vars...), variadic
+}
+
+// structType resolves the struct type e.
+func (r *genericResolver) structType(e *ast.StructType) types.Type {
+	var fields []*types.Var
+	var tags []string
+	for _, f := range e.Fields.List {
+		typ := r.typ(f.Type)
+		tag := ""
+		if f.Tag != nil {
+			tag, _ = strconv.Unquote(f.Tag.Value)
+		}
+		if len(f.Names) == 0 {
+			// anonymous field
+			name := embeddedName(f.Type)
+			pkg := r.pkg
+			if _, ok := deref(typ).(*types.Basic); ok {
+				pkg = nil // objects defined in Universe scope have no package
+			}
+			fields = append(fields, types.NewField(f.Pos(), pkg, name, typ, true))
+			tags = append(tags, tag)
+			continue
+		}
+		for _, name := range f.Names {
+			fields = append(fields, types.NewField(name.Pos(), r.pkg, name.Name, typ, false))
+			tags = append(tags, tag)
+		}
+	}
+	return types.NewStruct(fields, tags)
+}
+
+// embeddedName returns the field name of the embedded field type e.
+func embeddedName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.ParenExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(e.X)
+	case *ast.IndexListExpr:
+		return embeddedName(e.X)
+	}
+	errorf("invalid embedded field type %s", types.ExprString(e))
+	panic("unreachable")
+}
+
+// interfaceType resolves the interface type e.
+func (r *genericResolver) interfaceType(e *ast.InterfaceType) types.Type {
+	var (
+		methods    []*types.Func
+		embeddeds  []*types.Named
+		unions     []*types.Union
+		comparable bool
+	)
+	for _, f := range e.Methods.List {
+		if len(f.Names) > 0 {
+			ftype, _ := f.Type.(*ast.FuncType)
+			if ftype == nil {
+				errorf("invalid interface method %s", f.Names[0].Name)
+			}
+			params, results, variadic := r.signature(ftype)
+			sig := types.NewSignature(nil, params, results, variadic)
+			methods = append(methods, types.NewFunc(f.Names[0].Pos(), r.pkg, f.Names[0].Name, sig))
+			continue
+		}
+		if isUnion(f.Type) {
+			unions = append(unions, r.union(f.Type))
+			continue
+		}
+		typ := r.typ(f.Type)
+		if named, _ := typ.(*types.Named); named != nil && r.isInterface(named) {
+			if named == types.Universe.Lookup("comparable").Type() {
+				comparable = true
+				continue
+			}
+			embeddeds = append(embeddeds, named)
+			continue
+		}
+		unions = append(unions, types.NewUnion([]*types.Term{types.NewTerm(false, typ)}))
+	}
+	return r.newInterface(methods, embeddeds, unions, comparable)
+}
+
+// newInterface returns a new interface, to be completed with the other
+// imported interfaces.
+func (r *genericResolver) newInterface(methods []*types.Func, embeddeds []*types.Named, unions []*types.Union, comparable bool) *types.Interface {
+	var t *types.Interface
+	if len(unions) == 0 && !comparable {
+		t = types.NewInterface(methods, embeddeds)
+	} else {
+		t = types.NewConstraint(methods, embeddeds, unions, comparable)
+	}
+	r.p.interfaceList = append(r.p.interfaceList, t)
+	return t
+}
+
+// isInterface reports whether typ is an interface type. For instances,
+// it consults the generic type, whose underlying type may not be set up
+// yet, and which must not be expanded before.
+func (r *genericResolver) isInterface(typ types.Type) bool {
+	named, _ := typ.(*types.Named)
+	if named == nil {
+		return types.IsInterface(typ)
+	}
+	orig := named.Origin()
+	if u := orig.Underlying(); u != nil {
+		_, ok := u.(*types.Interface)
+		return ok
+	}
+	if spec := r.specs[orig.Obj()]; spec != nil {
+		_, ok := spec.Type.(*ast.InterfaceType)
+		return ok
+	}
+	return false
+}
+
+// isUnion reports whether e is a union of terms or a ~T term.
+func isUnion(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return isUnion(e.X)
+	case *ast.BinaryExpr:
+		return e.Op == token.OR
+	case *ast.UnaryExpr:
+		return e.Op == token.TILDE
+	}
+	return false
+}
+
+// union resolves the union of terms e.
+func (r *genericResolver) union(e ast.Expr) *types.Union {
+	var terms []*types.Term
+	var collect func(e ast.Expr)
+	collect = func(e ast.Expr) {
+		if p, ok := e.(*ast.ParenExpr); ok {
+			collect(p.X)
+			return
+		}
+		if b, ok := e.(*ast.BinaryExpr); ok && b.Op == token.OR {
+			collect(b.X)
+			collect(b.Y)
+			return
+		}
+		tilde := false
+		if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.TILDE {
+			tilde = true
+			e = u.X
+		}
+		terms = append(terms, types.NewTerm(tilde, r.typ(e)))
+	}
+	collect(e)
+	return types.NewUnion(terms)
+}
+
+// instance reads the instance type with the given name of a generic
+// type declared in package parent. The underlying type and methods
+// of the instance are derived from the generic type; the exported
+// ones are read and discarded.
+func (p *importer) instance(parent *types.Package, name string) types.Type {
+	// reserve the type's slot, as for named types
+	n := len(p.typList)
+	p.record(nil)
+
+	targs := make([]types.Type, p.int())
+	for i := range targs {
+		targs[i] = p.typ(parent)
+	}
+	orig := p.genericType(parent, name[:strings.Index(name, "[")])
+	t, err := types.Instantiate(orig, targs, false)
+	if err != nil {
+		errorf("%v", err)
+	}
+	p.typList[n] = t
+
+	// read and discard underlying type and methods
+	if !types.IsInterface(p.typ(parent)) {
+		for i := p.int(); i > 0; i-- {
+			p.pos()
+			if name := p.string(); !exported(name) {
+				p.pkg()
+			}
+			p.paramList()
+			p.paramList()
+			p.paramList()
+			p.int()
+		}
+	}
+	return t
+}
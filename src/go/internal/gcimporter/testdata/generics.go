@@ -0,0 +1,39 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file is used to generate an object file which
+// serves as test file for gcimporter_test.go.
+
+package generics
+
+type List[T any] struct {
+	head *node[T]
+	n    inner
+}
+
+type node[T any] struct {
+	v    T
+	next *node[T]
+}
+
+type inner struct {
+	len int
+}
+
+func (l *List[T]) Push(v T) {
+	l.head = &node[T]{v, l.head}
+	l.n.len++
+}
+
+func (l *List[T]) Len() int {
+	return l.n.len
+}
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	r := make([]U, len(s))
+	for i, v := range s {
+		r[i] = f(v)
+	}
+	return r
+}
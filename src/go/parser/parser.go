@@ -656,22 +656,134 @@ func (p *parser) parseArrayType() ast.Expr {
 	}
 
 	lbrack := p.expect(token.LBRACK)
-	p.exprLev++
-	var len ast.Expr
-	// always permit ellipsis for more fault-tolerant parsing
-	if p.tok == token.ELLIPSIS {
-		len = &ast.Ellipsis{Ellipsis: p.pos}
-		p.next()
-	} else if p.tok != token.RBRACK {
-		len = p.parseRhs()
+	return p.parseArrayTypeRest(lbrack, nil)
+}
+
+// parseArrayTypeRest parses the rest of an array or slice type after
+// the "[" at lbrack. If len is not nil, it is the already parsed array
+// length.
+func (p *parser) parseArrayTypeRest(lbrack token.Pos, len ast.Expr) ast.Expr {
+	if len == nil {
+		p.exprLev++
+		// always permit ellipsis for more fault-tolerant parsing
+		if p.tok == token.ELLIPSIS {
+			len = &ast.Ellipsis{Ellipsis: p.pos}
+			p.next()
+		} else if p.tok != token.RBRACK {
+			len = p.parseRhs()
+		}
+		p.exprLev--
 	}
-	p.exprLev--
 	p.expect(token.RBRACK)
 	elt := p.parseType()
 
 	return &ast.ArrayType{Lbrack: lbrack, Len: len, Elt: elt}
 }
 
+// parseTypeInstance parses the type arguments of the generic type x,
+// which must follow, and returns x instantiated with them.
+func (p *parser) parseTypeInstance(x ast.Expr) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "TypeInstance"))
+	}
+
+	p.resolve(x)
+	lbrack := p.expect(token.LBRACK)
+	p.exprLev++
+	var list []ast.Expr
+	for p.tok != token.RBRACK && p.tok != token.EOF {
+		list = append(list, p.parseType())
+		if !p.atComma("type argument list", token.RBRACK) {
+			break
+		}
+		p.next()
+	}
+	p.exprLev--
+	rbrack := p.expectClosing(token.RBRACK, "type argument list")
+	if len(list) == 0 {
+		p.errorExpected(rbrack, "type argument list")
+		return &ast.IndexExpr{X: x, Lbrack: lbrack, Index: &ast.BadExpr{From: lbrack + 1, To: rbrack}, Rbrack: rbrack}
+	}
+
+	return packIndexExpr(x, lbrack, list, rbrack)
+}
+
+// packIndexExpr returns an *ast.IndexExpr if list has exactly one
+// element, and an *ast.IndexListExpr otherwise.
+func packIndexExpr(x ast.Expr, lbrack token.Pos, list []ast.Expr, rbrack token.Pos) ast.Expr {
+	if len(list) == 1 {
+		return &ast.IndexExpr{X: x, Lbrack: lbrack, Index: list[0], Rbrack: rbrack}
+	}
+	return &ast.IndexListExpr{X: x, Lbrack: lbrack, Indices: list, Rbrack: rbrack}
+}
+
+// parseArrayFieldOrTypeInstance parses what follows the identifier x
+// in a parameter or field declaration if it starts with "[": either an
+// array or slice type, in which case x is the name of the parameter or
+// field and is returned with that type, or the type arguments of the
+// generic type x, in which case the name is nil and the type is the
+// instantiated x.
+func (p *parser) parseArrayFieldOrTypeInstance(x *ast.Ident) (*ast.Ident, ast.Expr) {
+	if p.trace {
+		defer un(trace(p, "ArrayFieldOrTypeInstance"))
+	}
+
+	lbrack := p.expect(token.LBRACK)
+	if p.tok == token.RBRACK || p.tok == token.ELLIPSIS {
+		// x []E or x [...]E
+		return x, p.parseArrayTypeRest(lbrack, nil)
+	}
+
+	p.exprLev++
+	var list []ast.Expr
+	for {
+		list = append(list, p.parseRhsOrType())
+		if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+		if p.tok == token.RBRACK {
+			break
+		}
+	}
+	p.exprLev--
+
+	if len(list) == 1 && p.tok == token.RBRACK {
+		rbrack := p.pos
+		p.next()
+		if elt := p.tryType(); elt != nil {
+			// x [N]E
+			return x, &ast.ArrayType{Lbrack: lbrack, Len: list[0], Elt: elt}
+		}
+		// x[P]
+		p.resolve(x)
+		return nil, &ast.IndexExpr{X: x, Lbrack: lbrack, Index: list[0], Rbrack: rbrack}
+	}
+
+	// x[P1, P2, ...]
+	p.resolve(x)
+	rbrack := p.expectClosing(token.RBRACK, "type argument list")
+	return nil, packIndexExpr(x, lbrack, list, rbrack)
+}
+
+// parseVarTypeOrField is like parseVarType but also accepts, for an
+// identifier followed by "[", the name of a parameter or field with an
+// array or slice type; in that case it returns the name and its type.
+// Otherwise the name is nil.
+func (p *parser) parseVarTypeOrField(isParam bool) (*ast.Ident, ast.Expr) {
+	if p.tok != token.IDENT {
+		return nil, p.parseVarType(isParam)
+	}
+	x := p.parseTypeName()
+	if p.tok != token.LBRACK {
+		return nil, x
+	}
+	if ident, isIdent := x.(*ast.Ident); isIdent {
+		return p.parseArrayFieldOrTypeInstance(ident)
+	}
+	return nil, p.parseTypeInstance(x)
+}
+
 func (p *parser) makeIdentList(list []ast.Expr) []*ast.Ident {
 	idents := make([]*ast.Ident, len(list))
 	for i, x := range list {
@@ -698,15 +810,25 @@ func (p *parser) parseFieldDecl(scope *ast.Scope) *ast.Field {
 	// 1st FieldDecl
 	// A type name used as an anonymous field looks like a field identifier.
 	var list []ast.Expr
+	var typ ast.Expr
 	for {
-		list = append(list, p.parseVarType(false))
+		name, x := p.parseVarTypeOrField(false)
+		if name != nil {
+			// IdentifierList ArrayType
+			list = append(list, name)
+			typ = x
+			break
+		}
+		list = append(list, x)
 		if p.tok != token.COMMA {
 			break
 		}
 		p.next()
 	}
 
-	typ := p.tryVarType(false)
+	if typ == nil {
+		typ = p.tryVarType(false)
+	}
 
 	// analyze case
 	var idents []*ast.Ident
@@ -719,7 +841,7 @@ func (p *parser) parseFieldDecl(scope *ast.Scope) *ast.Field {
 		if n := len(list); n > 1 {
 			p.errorExpected(p.pos, "type")
 			typ = &ast.BadExpr{From: p.pos, To: p.pos}
-		} else if !isTypeName(deref(typ)) {
+		} else if x := deref(typ); !isTypeName(x) && !isTypeInstance(x) {
 			p.errorExpected(typ.Pos(), "anonymous field")
 			typ = &ast.BadExpr{From: typ.Pos(), To: p.safePos(typ.End())}
 		}
@@ -816,8 +938,16 @@ func (p *parser) parseParameterList(scope *ast.Scope, ellipsisOk bool) (params [
 	// 1st ParameterDecl
 	// A list of identifiers looks like a list of type names.
 	var list []ast.Expr
+	var typ ast.Expr
 	for {
-		list = append(list, p.parseVarType(ellipsisOk))
+		name, x := p.parseVarTypeOrField(ellipsisOk)
+		if name != nil {
+			// IdentifierList ArrayType
+			list = append(list, name)
+			typ = x
+			break
+		}
+		list = append(list, x)
 		if p.tok != token.COMMA {
 			break
 		}
@@ -828,7 +958,10 @@ func (p *parser) parseParameterList(scope *ast.Scope, ellipsisOk bool) (params [
 	}
 
 	// analyze case
-	if typ := p.tryVarType(ellipsisOk); typ != nil {
+	if typ == nil {
+		typ = p.tryVarType(ellipsisOk)
+	}
+	if typ != nil {
 		// IdentifierList Type
 		idents := p.makeIdentList(list)
 		field := &ast.Field{Names: idents, Type: typ}
@@ -932,17 +1065,29 @@ func (p *parser) parseMethodSpec(scope *ast.Scope) *ast.Field {
 	doc := p.leadComment
 	var idents []*ast.Ident
 	var typ ast.Expr
-	x := p.parseTypeName()
-	if ident, isIdent := x.(*ast.Ident); isIdent && p.tok == token.LPAREN {
-		// method
-		idents = []*ast.Ident{ident}
-		scope := ast.NewScope(nil) // method scope
-		params, results := p.parseSignature(scope)
-		typ = &ast.FuncType{Func: token.NoPos, Params: params, Results: results}
+	if p.tok != token.IDENT {
+		// union of types
+		typ = p.parseTypeElem(nil)
+	} else if x := p.parseTypeName(); p.tok == token.LPAREN {
+		if ident, isIdent := x.(*ast.Ident); isIdent {
+			// method
+			idents = []*ast.Ident{ident}
+			scope := ast.NewScope(nil) // method scope
+			params, results := p.parseSignature(scope)
+			typ = &ast.FuncType{Func: token.NoPos, Params: params, Results: results}
+		} else {
+			p.errorExpected(x.Pos(), "method name")
+			typ = &ast.BadExpr{From: x.Pos(), To: p.safePos(x.End())}
+			p.resolve(x)
+		}
 	} else {
-		// embedded interface
-		typ = x
-		p.resolve(typ)
+		// embedded interface, or union of types
+		if p.tok == token.LBRACK {
+			x = p.parseTypeInstance(x)
+		} else {
+			p.resolve(x)
+		}
+		typ = p.parseTypeElem(x)
 	}
 	p.expectSemi() // call before accessing p.linecomment
 
@@ -961,7 +1106,7 @@ func (p *parser) parseInterfaceType() *ast.InterfaceType {
 	lbrace := p.expect(token.LBRACE)
 	scope := ast.NewScope(nil) // interface scope
 	var list []*ast.Field
-	for p.tok == token.IDENT {
+	for p.tok == token.IDENT || p.tok == token.TILDE || p.startsType() {
 		list = append(list, p.parseMethodSpec(scope))
 	}
 	rbrace := p.expect(token.RBRACE)
@@ -976,6 +1121,94 @@ func (p *parser) parseInterfaceType() *ast.InterfaceType {
 	}
 }
 
+// parseTypeElem parses a union of type terms, as in an interface or a
+// type constraint. If x is not nil, it is the already parsed first term.
+func (p *parser) parseTypeElem(x ast.Expr) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "TypeElem"))
+	}
+
+	if x == nil {
+		x = p.parseTypeTerm()
+	}
+	for p.tok == token.OR {
+		pos := p.pos
+		p.next()
+		y := p.parseTypeTerm()
+		x = &ast.BinaryExpr{X: x, OpPos: pos, Op: token.OR, Y: y}
+	}
+	return x
+}
+
+func (p *parser) parseTypeTerm() ast.Expr {
+	if p.tok == token.TILDE {
+		pos := p.pos
+		p.next()
+		typ := p.parseType()
+		return &ast.UnaryExpr{OpPos: pos, Op: token.TILDE, X: typ}
+	}
+	return p.parseType()
+}
+
+// startsType reports whether the current token may start a type
+// other than a type name.
+func (p *parser) startsType() bool {
+	switch p.tok {
+	case token.MUL, token.ARROW, token.FUNC, token.LBRACK, token.CHAN, token.MAP, token.STRUCT, token.INTERFACE, token.LPAREN:
+		return true
+	}
+	return false
+}
+
+// parseTypeParams parses a type parameter list after the "[" at
+// lbrack, up to and including the closing "]", and declares the type
+// parameters in scope. If first is not nil, it is the already parsed
+// name of the first type parameter.
+func (p *parser) parseTypeParams(scope *ast.Scope, lbrack token.Pos, first *ast.Ident) *ast.FieldList {
+	if p.trace {
+		defer un(trace(p, "TypeParams"))
+	}
+
+	var list []*ast.Field
+	var names []*ast.Ident // names without a constraint yet
+	for {
+		var name *ast.Ident
+		if first != nil {
+			name, first = first, nil
+		} else if p.tok == token.IDENT {
+			name = p.parseIdent()
+		} else {
+			p.errorExpected(p.pos, "type parameter name")
+			break
+		}
+		names = append(names, name)
+		if p.tok != token.COMMA && p.tok != token.RBRACK {
+			typ := p.parseTypeElem(nil)
+			field := &ast.Field{Names: names, Type: typ}
+			p.declare(field, nil, scope, ast.Typ, names...)
+			list = append(list, field)
+			names = nil
+		}
+		if !p.atComma("type parameter list", token.RBRACK) {
+			break
+		}
+		p.next()
+		if p.tok == token.RBRACK {
+			break
+		}
+	}
+	if len(names) > 0 {
+		p.error(names[len(names)-1].Pos(), "missing type constraint")
+		typ := &ast.BadExpr{From: p.pos, To: p.pos}
+		field := &ast.Field{Names: names, Type: typ}
+		p.declare(field, nil, scope, ast.Typ, names...)
+		list = append(list, field)
+	}
+	rbrack := p.expectClosing(token.RBRACK, "type parameter list")
+
+	return &ast.FieldList{Opening: lbrack, List: list, Closing: rbrack}
+}
+
 func (p *parser) parseMapType() *ast.MapType {
 	if p.trace {
 		defer un(trace(p, "MapType"))
@@ -1019,7 +1252,11 @@ func (p *parser) parseChanType() *ast.ChanType {
 func (p *parser) tryIdentOrType() ast.Expr {
 	switch p.tok {
 	case token.IDENT:
-		return p.parseTypeName()
+		typ := p.parseTypeName()
+		if p.tok == token.LBRACK {
+			typ = p.parseTypeInstance(typ)
+		}
+		return typ
 	case token.LBRACK:
 		return p.parseArrayType()
 	case token.STRUCT:
@@ -1055,6 +1292,20 @@ func (p *parser) tryType() ast.Expr {
 	return typ
 }
 
+// startsConstraint reports whether the current token, following the
+// first name after "[" in a type declaration, may start the constraint
+// of a type parameter, or the rest of a type parameter list, rather
+// than continue an array length expression.
+//
+// A "*" continues the expression: type T[P *C] ... declares an array.
+func (p *parser) startsConstraint() bool {
+	switch p.tok {
+	case token.IDENT, token.COMMA, token.ARROW, token.FUNC, token.LBRACK, token.CHAN, token.MAP, token.STRUCT, token.INTERFACE, token.TILDE:
+		return true
+	}
+	return false
+}
+
 // ----------------------------------------------------------------------------
 // Blocks
 
@@ -1209,7 +1460,23 @@ func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 	var index [N]ast.Expr
 	var colons [N - 1]token.Pos
 	if p.tok != token.COLON {
-		index[0] = p.parseRhs()
+		// The index may be a type argument of a generic
+		// function or type.
+		index[0] = p.parseRhsOrType()
+		if p.tok == token.COMMA {
+			// x[P1, P2, ...]: instantiation with several type arguments
+			list := []ast.Expr{index[0]}
+			for p.tok == token.COMMA {
+				p.next()
+				if p.tok == token.RBRACK {
+					break
+				}
+				list = append(list, p.parseType())
+			}
+			p.exprLev--
+			rbrack := p.expectClosing(token.RBRACK, "type argument list")
+			return &ast.IndexListExpr{X: x, Lbrack: lbrack, Indices: list, Rbrack: rbrack}
+		}
 	}
 	ncolons := 0
 	for p.tok == token.COLON && ncolons < len(colons) {
@@ -1225,6 +1492,9 @@ func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 
 	if ncolons > 0 {
 		// slice expression
+		if index[0] != nil {
+			index[0] = p.checkExpr(index[0])
+		}
 		slice3 := false
 		if ncolons == 2 {
 			slice3 = true
@@ -1372,6 +1642,7 @@ func (p *parser) checkExpr(x ast.Expr) ast.Expr {
 		panic("unreachable")
 	case *ast.SelectorExpr:
 	case *ast.IndexExpr:
+	case *ast.IndexListExpr:
 	case *ast.SliceExpr:
 	case *ast.TypeAssertExpr:
 		// If t.Type == nil we have a type assertion of the form
@@ -1405,6 +1676,18 @@ func isTypeName(x ast.Expr) bool {
 	return true
 }
 
+// isTypeInstance reports whether x is a (qualified) TypeName with
+// type arguments.
+func isTypeInstance(x ast.Expr) bool {
+	switch t := x.(type) {
+	case *ast.IndexExpr:
+		return isTypeName(t.X)
+	case *ast.IndexListExpr:
+		return isTypeName(t.X)
+	}
+	return false
+}
+
 // isLiteralType reports whether x is a legal composite literal type.
 func isLiteralType(x ast.Expr) bool {
 	switch t := x.(type) {
@@ -1413,6 +1696,8 @@ func isLiteralType(x ast.Expr) bool {
 	case *ast.SelectorExpr:
 		_, isIdent := t.X.(*ast.Ident)
 		return isIdent
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return isTypeInstance(t)
 	case *ast.ArrayType:
 	case *ast.StructType:
 	case *ast.MapType:
@@ -1458,12 +1743,15 @@ func (p *parser) checkExprOrType(x ast.Expr) ast.Expr {
 }
 
 // If lhs is set and the result is an identifier, it is not resolved.
-func (p *parser) parsePrimaryExpr(lhs bool) ast.Expr {
+// If x is not nil, it is the already parsed operand.
+func (p *parser) parsePrimaryExpr(x ast.Expr, lhs bool) ast.Expr {
 	if p.trace {
 		defer un(trace(p, "PrimaryExpr"))
 	}
 
-	x := p.parseOperand(lhs)
+	if x == nil {
+		x = p.parseOperand(lhs)
+	}
 L:
 	for {
 		switch p.tok {
@@ -1495,7 +1783,7 @@ L:
 			}
 			x = p.parseCallOrConversion(p.checkExprOrType(x))
 		case token.LBRACE:
-			if isLiteralType(x) && (p.exprLev >= 0 || !isTypeName(x)) {
+			if isLiteralType(x) && (p.exprLev >= 0 || !isTypeName(x) && !isTypeInstance(x)) {
 				if lhs {
 					p.resolve(x)
 				}
@@ -1519,7 +1807,7 @@ func (p *parser) parseUnaryExpr(lhs bool) ast.Expr {
 	}
 
 	switch p.tok {
-	case token.ADD, token.SUB, token.NOT, token.XOR, token.AND:
+	case token.ADD, token.SUB, token.NOT, token.XOR, token.AND, token.TILDE:
 		pos, op := p.pos, p.tok
 		p.next()
 		x := p.parseUnaryExpr(false)
@@ -1579,7 +1867,7 @@ func (p *parser) parseUnaryExpr(lhs bool) ast.Expr {
 		return &ast.StarExpr{Star: pos, X: p.checkExprOrType(x)}
 	}
 
-	return p.parsePrimaryExpr(lhs)
+	return p.parsePrimaryExpr(nil, lhs)
 }
 
 func (p *parser) tokPrec() (token.Token, int) {
@@ -1591,12 +1879,15 @@ func (p *parser) tokPrec() (token.Token, int) {
 }
 
 // If lhs is set and the result is an identifier, it is not resolved.
-func (p *parser) parseBinaryExpr(lhs bool, prec1 int) ast.Expr {
+// If x is not nil, it is the already parsed first unary expression.
+func (p *parser) parseBinaryExpr(lhs bool, x ast.Expr, prec1 int) ast.Expr {
 	if p.trace {
 		defer un(trace(p, "BinaryExpr"))
 	}
 
-	x := p.parseUnaryExpr(lhs)
+	if x == nil {
+		x = p.parseUnaryExpr(lhs)
+	}
 	for {
 		op, oprec := p.tokPrec()
 		if oprec < prec1 {
@@ -1607,7 +1898,7 @@ func (p *parser) parseBinaryExpr(lhs bool, prec1 int) ast.Expr {
 			p.resolve(x)
 			lhs = false
 		}
-		y := p.parseBinaryExpr(false, oprec+1)
+		y := p.parseBinaryExpr(false, nil, oprec+1)
 		x = &ast.BinaryExpr{X: p.checkExpr(x), OpPos: pos, Op: op, Y: p.checkExpr(y)}
 	}
 }
@@ -1621,7 +1912,7 @@ func (p *parser) parseExpr(lhs bool) ast.Expr {
 		defer un(trace(p, "Expression"))
 	}
 
-	return p.parseBinaryExpr(lhs, token.LowestPrec+1)
+	return p.parseBinaryExpr(lhs, nil, token.LowestPrec+1)
 }
 
 func (p *parser) parseRhs() ast.Expr {
@@ -2327,11 +2618,41 @@ func (p *parser) parseTypeSpec(doc *ast.CommentGroup, _ token.Token, _ int) ast.
 	// (Global identifiers are resolved in a separate phase after parsing.)
 	spec := &ast.TypeSpec{Doc: doc, Name: ident}
 	p.declare(spec, nil, p.topScope, ast.Typ, ident)
-	if p.tok == token.ASSIGN {
-		spec.Assign = p.pos
+	if p.tok == token.LBRACK {
+		// array or slice type, or type parameter list
+		lbrack := p.pos
 		p.next()
+		if p.tok == token.IDENT {
+			// A name followed by a token that may start a type
+			// constraint begins a type parameter list. Otherwise
+			// the name begins the length of an array type.
+			name := p.parseIdent()
+			if p.startsConstraint() {
+				p.openScope()
+				spec.TypeParams = p.parseTypeParams(p.topScope, lbrack, name)
+				if p.tok == token.ASSIGN {
+					p.error(p.pos, "generic type cannot be alias")
+					p.next()
+				}
+				spec.Type = p.parseType()
+				p.closeScope()
+			} else {
+				p.resolve(name)
+				p.exprLev++
+				x := p.parseBinaryExpr(false, p.parsePrimaryExpr(name, false), token.LowestPrec+1)
+				p.exprLev--
+				spec.Type = p.parseArrayTypeRest(lbrack, p.checkExpr(x))
+			}
+		} else {
+			spec.Type = p.parseArrayTypeRest(lbrack, nil)
+		}
+	} else {
+		if p.tok == token.ASSIGN {
+			spec.Assign = p.pos
+			p.next()
+		}
+		spec.Type = p.parseType()
 	}
-	spec.Type = p.parseType()
 	p.expectSemi() // call before accessing p.linecomment
 	spec.Comment = p.lineComment
 
@@ -2385,6 +2706,21 @@ func (p *parser) parseFuncDecl() *ast.FuncDecl {
 
 	ident := p.parseIdent()
 
+	var tparams *ast.FieldList
+	if p.tok == token.LBRACK {
+		lbrack := p.pos
+		p.next()
+		if p.tok == token.RBRACK {
+			p.error(lbrack, "empty type parameter list")
+			p.next()
+		} else {
+			tparams = p.parseTypeParams(scope, lbrack, nil)
+			if recv != nil {
+				p.error(lbrack, "method must have no type parameters")
+			}
+		}
+	}
+
 	params, results := p.parseSignature(scope)
 
 	var body *ast.BlockStmt
@@ -2398,9 +2734,10 @@ func (p *parser) parseFuncDecl() *ast.FuncDecl {
 		Recv: recv,
 		Name: ident,
 		Type: &ast.FuncType{
-			Func:    pos,
-			Params:  params,
-			Results: results,
+			Func:       pos,
+			TypeParams: tparams,
+			Params:     params,
+			Results:    results,
 		},
 		Body: body,
 	}
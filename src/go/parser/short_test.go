@@ -48,6 +48,25 @@ var valids = []string{
 	`package p; var _ = map[*P]int{&P{}:0, {}:1}`,
 	`package p; type T = int`,
 	`package p; type (T = p.T; _ = struct{}; x = *T)`,
+
+	// generics
+	`package p; type T[P any] struct{ x P }`,
+	`package p; type T[P, Q any, R interface{ ~int | string }] []P`,
+	`package p; type T[P ~int | ~uint] P`,
+	`package p; type A [N]int; type B [N + 1]int; type C [N * M]int`,
+	`package p; func f[P any](x P) P { return x }`,
+	`package p; func f[P, Q any, R comparable](P, Q) R`,
+	`package p; func (l *List[T]) Push(v T) {}`,
+	`package p; func (m Map[K, V]) Get(k K) V`,
+	`package p; type C interface{ int | ~string; m() }`,
+	`package p; type C interface{ ~[]byte | *T | func() | chan int | map[int]int | struct{} | interface{ m() } }`,
+	`package p; type S struct{ List[int]; *Map[int, string]; a [N]int; b []int; c, d [N]T "tag" }`,
+	`package p; func f(List[int], Map[int, string]) (a [N]int, b []int)`,
+	`package p; func f(l List[int], m p.Map[int, string])`,
+	`package p; var _ = f[int](0) + g[int, string](0, "")`,
+	`package p; var _ = List[int]{} ; var _ = Map[int, []string]{}`,
+	`package p; func f() { if x := (List[int]{}); x.Len() == 0 {} }`,
+	`package p; func f() { for x := range a[i] {} }`,
 }
 
 func TestValid(t *testing.T) {
@@ -127,6 +146,12 @@ var invalids = []string{
 	// issue 13475
 	`package p; func f() { if true {} else ; /* ERROR "expected if statement or block" */ }`,
 	`package p; func f() { if true {} else defer /* ERROR "expected if statement or block" */ f() }`,
+
+	// generics
+	`package p; type T[P any] = /* ERROR "generic type cannot be alias" */ int`,
+	`package p; type T[P, Q /* ERROR "missing type constraint" */ ] int`,
+	`package p; func f[ /* ERROR "empty type parameter list" */ ]()`,
+	`package p; func (T) m[ /* ERROR "method must have no type parameters" */ P any]()`,
 }
 
 func TestInvalid(t *testing.T) {
@@ -123,6 +123,7 @@ var tokens = [...]elt{
 	{token.ARROW, "<-", operator},
 	{token.INC, "++", operator},
 	{token.DEC, "--", operator},
+	{token.TILDE, "~", operator},
 
 	{token.EQL, "==", operator},
 	{token.LSS, "<", operator},
@@ -12,7 +12,21 @@ import (
 )
 
 func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
-	check.exprOrType(x, e.Fun)
+	var ix *indexedExpr // set if e.Fun is the instantiation of a generic function
+	switch e.Fun.(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		if fix := unpackIndexExpr(e.Fun); check.indexExpr(x, fix) {
+			// The instantiation is completed with the arguments
+			// below, which may be needed to infer the missing type
+			// arguments.
+			ix = fix
+		} else if x.mode != invalid {
+			check.recordTypeAndValue(e.Fun, x.mode, x.typ, x.val)
+		}
+		x.expr = e.Fun
+	default:
+		check.exprOrType(x, e.Fun, true)
+	}
 
 	switch x.mode {
 	case invalid:
@@ -23,6 +37,12 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 
 	case typexpr:
 		// conversion
+		check.nonGeneric(x)
+		if x.mode == invalid {
+			check.use(e.Args...)
+			x.expr = e
+			return statement
+		}
 		T := x.typ
 		x.mode = invalid
 		switch n := len(e.Args); n {
@@ -53,7 +73,7 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 
 	default:
 		// function/method call
-		sig, _ := x.typ.Underlying().(*Signature)
+		sig, _ := coreType(x.typ).(*Signature)
 		if sig == nil {
 			check.invalidOp(x.pos(), "cannot call non-function %s", x)
 			x.mode = invalid
@@ -62,6 +82,16 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 		}
 
 		arg, n, _ := unpack(func(x *operand, i int) { check.multiExpr(x, e.Args[i]) }, len(e.Args), false)
+		if sig.tparams != nil {
+			if arg != nil {
+				sig = check.instantiateCall(x, e, ix, sig, &arg, n)
+			}
+			if arg == nil || sig == nil {
+				x.mode = invalid
+				x.expr = e
+				return statement
+			}
+		}
 		if arg != nil {
 			check.arguments(x, e, sig, arg, n)
 		} else {
@@ -87,6 +117,43 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 	}
 }
 
+// instantiateCall instantiates the generic function x called by e
+// with the explicit type arguments of ix, if any, and the type
+// arguments inferred from the n call arguments provided by *arg.
+// The arguments are evaluated, and *arg is replaced by a getter for
+// the evaluated arguments. If successful, the result is the signature
+// of the instance; otherwise it is nil.
+func (check *Checker) instantiateCall(x *operand, e *ast.CallExpr, ix *indexedExpr, sig *Signature, arg *getter, n int) *Signature {
+	args := make([]*operand, n)
+	for i := range args {
+		args[i] = new(operand)
+		(*arg)(args[i], i)
+	}
+	*arg = func(x *operand, i int) { *x = *args[i] }
+
+	var targs []Type
+	var xlist []ast.Expr
+	if ix != nil {
+		xlist = ix.indices
+		targs = check.typeList(xlist)
+		if targs == nil {
+			return nil
+		}
+		if len(targs) > len(sig.tparams) {
+			check.errorf(xlist[len(sig.tparams)].Pos(), "got %d type arguments but %s has %d type parameters", len(targs), x.expr, len(sig.tparams))
+			return nil
+		}
+	}
+
+	targs = check.infer(e.Rparen, sig.tparams, targs, sig.params, sig.variadic && !e.Ellipsis.IsValid(), args)
+	if targs == nil {
+		return nil
+	}
+	inst := check.instantiateSignature(e.Pos(), sig, targs, xlist)
+	check.recordTypeAndValue(e.Fun, value, inst, nil)
+	return inst
+}
+
 // use type-checks each argument.
 // Useful to make sure expressions are evaluated
 // (and variables are "used") in the presence of other errors.
@@ -97,7 +164,7 @@ func (check *Checker) use(arg ...ast.Expr) {
 		// The nil check below is necessary since certain AST fields
 		// may legally be nil (e.g., the ast.SliceExpr.High field).
 		if e != nil {
-			check.rawExpr(&x, e, nil)
+			check.rawExpr(&x, e, nil, false)
 		}
 	}
 }
@@ -129,7 +196,7 @@ func (check *Checker) useLHS(arg ...ast.Expr) {
 				}
 			}
 		}
-		check.rawExpr(&x, e, nil)
+		check.rawExpr(&x, e, nil, false)
 		if v != nil {
 			v.used = v_used // restore v.used
 		}
@@ -288,7 +355,7 @@ func (check *Checker) argument(fun ast.Expr, sig *Signature, i int, x *operand,
 			check.errorf(ellipsis, "can only use ... with matching parameter")
 			return
 		}
-		if _, ok := x.typ.Underlying().(*Slice); !ok && x.typ != Typ[UntypedNil] { // see issue #18268
+		if _, ok := coreType(x.typ).(*Slice); !ok && x.typ != Typ[UntypedNil] { // see issue #18268
 			check.errorf(x.pos(), "cannot use %s as parameter of type %s", x, typ)
 			return
 		}
@@ -363,7 +430,7 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 		}
 	}
 
-	check.exprOrType(x, e.X)
+	check.exprOrType(x, e.X, false)
 	if x.mode == invalid {
 		goto Error
 	}
@@ -89,6 +89,7 @@ var tests = [][]string{
 	{"testdata/labels.src"},
 	{"testdata/issues.src"},
 	{"testdata/blank.src"},
+	{"testdata/generics.src"},
 }
 
 var fset = token.NewFileSet()
@@ -82,8 +82,23 @@ func (x *operand) convertibleTo(conf *Config, T Type) bool {
 		return true
 	}
 
-	// "x's type and T have identical underlying types if tags are ignored"
+	// "V or T is a type parameter and x is convertible to each type
+	// in its type set"
 	V := x.typ
+	if Vp, _ := V.(*TypeParam); Vp != nil {
+		return Vp.underIs(func(V Type) bool {
+			x := *x
+			x.typ = V
+			return x.convertibleTo(conf, T)
+		})
+	}
+	if Tp, _ := T.(*TypeParam); Tp != nil {
+		return Tp.underIs(func(T Type) bool {
+			return x.convertibleTo(conf, T)
+		})
+	}
+
+	// "x's type and T have identical underlying types if tags are ignored"
 	Vu := V.Underlying()
 	Tu := T.Underlying()
 	if IdenticalIgnoreTags(Vu, Tu) {
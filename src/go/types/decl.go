@@ -81,7 +81,7 @@ func (check *Checker) objDecl(obj Object, def *Named, path []*TypeName) {
 		check.varDecl(obj, d.lhs, d.typ, d.init)
 	case *TypeName:
 		// invalid recursive types are detected via path
-		check.typeDecl(obj, d.typ, def, path, d.alias)
+		check.typeDecl(obj, d.typ, d.tparams, def, path, d.alias)
 	case *Func:
 		// functions may be recursive - no need to track dependencies
 		check.funcDecl(obj, d)
@@ -145,6 +145,7 @@ func (check *Checker) varDecl(obj *Var, lhs []*Var, typ, init ast.Expr) {
 	// determine type, if any
 	if typ != nil {
 		obj.typ = check.typ(typ)
+		check.validVarType(typ, obj.typ)
 		// We cannot spread the type to all lhs variables if there
 		// are more than one since that would mark them as checked
 		// (see Checker.objDecl) and the assignment of init exprs,
@@ -201,13 +202,16 @@ func (check *Checker) varDecl(obj *Var, lhs []*Var, typ, init ast.Expr) {
 
 // underlying returns the underlying type of typ; possibly by following
 // forward chains of named types. Such chains only exist while named types
-// are incomplete.
+// are incomplete. Instances of generic types end a chain.
 func underlying(typ Type) Type {
 	for {
 		n, _ := typ.(*Named)
 		if n == nil {
 			break
 		}
+		if n.orig != nil {
+			return n.expandUnderlying()
+		}
 		typ = n.underlying
 	}
 	return typ
@@ -219,7 +223,7 @@ func (n *Named) setUnderlying(typ Type) {
 	}
 }
 
-func (check *Checker) typeDecl(obj *TypeName, typ ast.Expr, def *Named, path []*TypeName, alias bool) {
+func (check *Checker) typeDecl(obj *TypeName, typ ast.Expr, tparams *ast.FieldList, def *Named, path []*TypeName, alias bool) {
 	assert(obj.typ == nil)
 
 	// type declarations cannot use iota
@@ -227,6 +231,10 @@ func (check *Checker) typeDecl(obj *TypeName, typ ast.Expr, def *Named, path []*
 
 	if alias {
 
+		if tparams != nil {
+			check.errorf(tparams.Pos(), "generic type cannot be alias")
+		}
+
 		obj.typ = Typ[Invalid]
 		obj.typ = check.typExpr(typ, nil, append(path, obj))
 
@@ -236,6 +244,15 @@ func (check *Checker) typeDecl(obj *TypeName, typ ast.Expr, def *Named, path []*
 		def.setUnderlying(named)
 		obj.typ = named // make sure recursive type declarations terminate
 
+		if tparams != nil {
+			// The type parameters are in scope for the type expression
+			// but not outside of it.
+			scope := NewScope(check.scope, tparams.Pos(), typ.End(), "type parameters")
+			defer func(s *Scope) { check.scope = s }(check.scope)
+			named.tparams = check.declareTypeParams(scope, tparams)
+			check.scope = scope
+		}
+
 		// determine underlying type of named
 		check.typExpr(typ, named, append(path, obj))
 
@@ -454,7 +471,10 @@ func (check *Checker) declStmt(decl ast.Decl) {
 				// the innermost containing block."
 				scopePos := s.Name.Pos()
 				check.declare(check.scope, s.Name, obj, scopePos)
-				check.typeDecl(obj, s.Type, nil, nil, s.Assign.IsValid())
+				if s.TypeParams != nil {
+					check.errorf(s.TypeParams.Pos(), "generic type cannot be declared inside a function")
+				}
+				check.typeDecl(obj, s.Type, nil, nil, nil, s.Assign.IsValid())
 
 			default:
 				check.invalidAST(s.Pos(), "const, type, or var declaration expected")
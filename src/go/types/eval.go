@@ -78,6 +78,6 @@ func Eval(fset *token.FileSet, pkg *Package, pos token.Pos, expr string) (TypeAn
 
 	// evaluate node
 	var x operand
-	check.rawExpr(&x, node, nil)
+	check.rawExpr(&x, node, nil, false)
 	return TypeAndValue{x.mode, x.typ, x.val}, err
 }
@@ -94,7 +94,7 @@ func (check *Checker) unary(x *operand, e *ast.UnaryExpr, op token.Token) {
 		return
 
 	case token.ARROW:
-		typ, ok := x.typ.Underlying().(*Chan)
+		typ, ok := coreType(x.typ).(*Chan)
 		if !ok {
 			check.invalidOp(x.pos(), "cannot receive from non-channel %s", x)
 			x.mode = invalid
@@ -368,6 +368,7 @@ func (check *Checker) updateExprType(x ast.Expr, typ Type, final bool) {
 		*ast.FuncLit,
 		*ast.CompositeLit,
 		*ast.IndexExpr,
+		*ast.IndexListExpr,
 		*ast.SliceExpr,
 		*ast.TypeAssertExpr,
 		*ast.StarExpr,
@@ -462,6 +463,11 @@ func (check *Checker) updateExprType(x ast.Expr, typ Type, final bool) {
 	}
 
 	// Everything's fine, record final type and value for x.
+	// Values of type parameter type are never constant.
+	if old.mode == constant_ && isTypeParam(typ) {
+		check.recordTypeAndValue(x, value, typ, nil)
+		return
+	}
 	check.recordTypeAndValue(x, old.mode, typ, old.val)
 }
 
@@ -559,6 +565,22 @@ func (check *Checker) convertUntyped(x *operand, target Type) {
 		}
 		// keep nil untyped - see comment for interfaces, above
 		target = Typ[UntypedNil]
+	case *TypeParam:
+		// x must be convertible to each type in the type set of target
+		if !t.underIs(func(u Type) bool { return check.untypedConvertibleTo(x, u) }) {
+			goto Error
+		}
+		if x.isNil() {
+			// keep nil untyped - see comment for interfaces, above
+			target = Typ[UntypedNil]
+		} else if x.mode == constant_ {
+			// the value is not constant anymore
+			x.typ = target
+			check.updateExprType(x.expr, target, true)
+			x.mode = value
+			x.val = nil
+			return
+		}
 	default:
 		goto Error
 	}
@@ -572,6 +594,29 @@ Error:
 	x.mode = invalid
 }
 
+// untypedConvertibleTo reports whether the untyped value x can be
+// converted to the type u, which is the underlying type of a type in
+// the type set of a type parameter.
+func (check *Checker) untypedConvertibleTo(x *operand, u Type) bool {
+	switch u := u.(type) {
+	case *Basic:
+		if x.mode == constant_ {
+			return representableConst(x.val, check.conf, u, nil)
+		}
+		switch x.typ.(*Basic).kind {
+		case UntypedBool:
+			return isBoolean(u)
+		case UntypedInt, UntypedRune, UntypedFloat, UntypedComplex:
+			return isNumeric(u)
+		case UntypedNil:
+			return hasNil(u)
+		}
+	case *Pointer, *Signature, *Slice, *Map, *Chan, *Interface:
+		return x.isNil()
+	}
+	return false
+}
+
 func (check *Checker) comparison(x, y *operand, op token.Token) {
 	// spec: "In any comparison, the first operand must be assignable
 	// to the type of the second operand, or vice versa."
@@ -744,7 +789,7 @@ func (check *Checker) shift(x, y *operand, e *ast.BinaryExpr, op token.Token) {
 }
 
 var binaryOpPredicates = opPredicates{
-	token.ADD: func(typ Type) bool { return isNumeric(typ) || isString(typ) },
+	token.ADD: isNumericOrString,
 	token.SUB: isNumeric,
 	token.MUL: isNumeric,
 	token.QUO: isNumeric,
@@ -955,8 +1000,10 @@ const (
 // rawExpr typechecks expression e and initializes x with the expression
 // value or type. If an error occurred, x.mode is set to invalid.
 // If hint != nil, it is the type of a composite literal element.
+// If allowGeneric is set, the operand type may be an uninstantiated
+// generic function or type.
 //
-func (check *Checker) rawExpr(x *operand, e ast.Expr, hint Type) exprKind {
+func (check *Checker) rawExpr(x *operand, e ast.Expr, hint Type, allowGeneric bool) exprKind {
 	if trace {
 		check.trace(e.Pos(), "%s", e)
 		check.indent++
@@ -968,6 +1015,10 @@ func (check *Checker) rawExpr(x *operand, e ast.Expr, hint Type) exprKind {
 
 	kind := check.exprInternal(x, e, hint)
 
+	if !allowGeneric {
+		check.nonGeneric(x)
+	}
+
 	// convert x into a user-friendly set of values
 	// TODO(gri) this code can be simplified
 	var typ Type
@@ -996,6 +1047,186 @@ func (check *Checker) rawExpr(x *operand, e ast.Expr, hint Type) exprKind {
 	return kind
 }
 
+// indexExpr type-checks the index expression ix and initializes x with
+// its value or type. If ix denotes the instantiation of a generic
+// function, indexExpr only evaluates ix.x and reports true; the caller
+// must complete the instantiation (see check.funcInst).
+func (check *Checker) indexExpr(x *operand, ix *indexedExpr) (isFuncInst bool) {
+	check.exprOrType(x, ix.x, true)
+	switch x.mode {
+	case invalid:
+		check.use(ix.indices...)
+		return false
+
+	case typexpr:
+		// type instantiation
+		x.mode = invalid
+		x.typ = check.typ(ix.orig)
+		if x.typ != Typ[Invalid] {
+			x.mode = typexpr
+		}
+		return false
+
+	case builtin:
+		check.errorf(x.pos(), "%s must be called", x)
+		x.mode = invalid
+
+	case value:
+		if sig, _ := x.typ.(*Signature); sig != nil && sig.tparams != nil {
+			// function instantiation
+			return true
+		}
+	}
+
+	// x must be a single value now
+	check.nonGeneric(x)
+	check.singleValue(x)
+	if x.mode == invalid {
+		check.use(ix.indices...)
+		return false
+	}
+	if len(ix.indices) != 1 {
+		check.errorf(ix.indices[1].Pos(), "more than one index")
+		x.mode = invalid
+		return false
+	}
+	index := ix.indices[0]
+
+	valid := false
+	length := int64(-1) // valid if >= 0
+	switch typ := coreType(x.typ).(type) {
+	case *Basic:
+		if isString(typ) {
+			valid = true
+			if x.mode == constant_ {
+				length = int64(len(constant.StringVal(x.val)))
+			}
+			// an indexed string always yields a byte value
+			// (not a constant) even if the string and the
+			// index are constant
+			x.mode = value
+			x.typ = universeByte // use 'byte' name
+		}
+
+	case *Array:
+		valid = true
+		length = typ.len
+		if x.mode != variable {
+			x.mode = value
+		}
+		x.typ = typ.elem
+
+	case *Pointer:
+		if typ, _ := typ.base.Underlying().(*Array); typ != nil {
+			valid = true
+			length = typ.len
+			x.mode = variable
+			x.typ = typ.elem
+		}
+
+	case *Slice:
+		valid = true
+		x.mode = variable
+		x.typ = typ.elem
+
+	case *Map:
+		var key operand
+		check.expr(&key, index)
+		check.assignment(&key, typ.key, "map index")
+		if x.mode == invalid {
+			return false
+		}
+		x.mode = mapindex
+		x.typ = typ.elem
+		return false
+	}
+
+	if !valid {
+		check.invalidOp(x.pos(), "cannot index %s", x)
+		x.mode = invalid
+		return false
+	}
+
+	if index == nil {
+		check.invalidAST(ix.Pos(), "missing index for %s", x)
+		x.mode = invalid
+		return false
+	}
+
+	check.index(index, length)
+	// ok to continue
+	return false
+}
+
+// funcInst instantiates the generic function x with the type
+// arguments of ix. Missing type arguments must be inferrable from the
+// constraints.
+func (check *Checker) funcInst(x *operand, ix *indexedExpr) {
+	targs := check.typeList(ix.indices)
+	if targs == nil {
+		x.mode = invalid
+		x.expr = ix.orig
+		return
+	}
+	sig := x.typ.(*Signature)
+	if len(targs) > len(sig.tparams) {
+		check.errorf(ix.indices[len(sig.tparams)].Pos(), "got %d type arguments but %s has %d type parameters", len(targs), x.expr, len(sig.tparams))
+		x.mode = invalid
+		x.expr = ix.orig
+		return
+	}
+	if len(targs) < len(sig.tparams) {
+		targs = check.infer(ix.rbrack, sig.tparams, targs, nil, false, nil)
+		if targs == nil {
+			// error reported by infer
+			x.mode = invalid
+			x.expr = ix.orig
+			return
+		}
+	}
+	x.typ = check.instantiateSignature(ix.Pos(), sig, targs, ix.indices)
+	x.expr = ix.orig
+}
+
+// instantiateSignature instantiates the generic signature sig with the
+// type arguments targs, and verifies that they satisfy their
+// constraints. The type argument expressions, if any, are used for
+// error positions.
+func (check *Checker) instantiateSignature(pos token.Pos, sig *Signature, targs []Type, xlist []ast.Expr) *Signature {
+	inst := instantiate(sig, targs).(*Signature)
+	if i, err := verify(sig.tparams, targs); err != nil {
+		if i < len(xlist) {
+			pos = xlist[i].Pos()
+		}
+		check.errorf(pos, "%s", err)
+	}
+	return inst
+}
+
+// nonGeneric reports an error if the operand x is a generic function
+// or type that has not been instantiated, and invalidates x.
+func (check *Checker) nonGeneric(x *operand) {
+	if x.mode == invalid || x.mode == novalue {
+		return
+	}
+	var what string
+	switch t := x.typ.(type) {
+	case *Named:
+		if isGeneric(t) {
+			what = "type"
+		}
+	case *Signature:
+		if t.tparams != nil {
+			what = "function"
+		}
+	}
+	if what != "" {
+		check.errorf(x.pos(), "cannot use generic %s %s without instantiation", what, x.expr)
+		x.mode = invalid
+		x.typ = Typ[Invalid]
+	}
+}
+
 // exprInternal contains the core of type checking of expressions.
 // Must only be called by rawExpr.
 //
@@ -1079,7 +1310,7 @@ func (check *Checker) exprInternal(x *operand, e ast.Expr, hint Type) exprKind {
 			goto Error
 		}
 
-		switch utyp := base.Underlying().(type) {
+		switch utyp := coreType(base).(type) {
 		case *Struct:
 			if len(e.Elts) == 0 {
 				break
@@ -1239,83 +1470,27 @@ func (check *Checker) exprInternal(x *operand, e ast.Expr, hint Type) exprKind {
 		x.typ = typ
 
 	case *ast.ParenExpr:
-		kind := check.rawExpr(x, e.X, nil)
+		// type inference doesn't go past parentheses
+		kind := check.rawExpr(x, e.X, nil, false)
 		x.expr = e
 		return kind
 
 	case *ast.SelectorExpr:
 		check.selector(x, e)
 
-	case *ast.IndexExpr:
-		check.expr(x, e.X)
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		ix := unpackIndexExpr(e)
+		if check.indexExpr(x, ix) {
+			check.funcInst(x, ix)
+		}
 		if x.mode == invalid {
-			check.use(e.Index)
 			goto Error
 		}
-
-		valid := false
-		length := int64(-1) // valid if >= 0
-		switch typ := x.typ.Underlying().(type) {
-		case *Basic:
-			if isString(typ) {
-				valid = true
-				if x.mode == constant_ {
-					length = int64(len(constant.StringVal(x.val)))
-				}
-				// an indexed string always yields a byte value
-				// (not a constant) even if the string and the
-				// index are constant
-				x.mode = value
-				x.typ = universeByte // use 'byte' name
-			}
-
-		case *Array:
-			valid = true
-			length = typ.len
-			if x.mode != variable {
-				x.mode = value
-			}
-			x.typ = typ.elem
-
-		case *Pointer:
-			if typ, _ := typ.base.Underlying().(*Array); typ != nil {
-				valid = true
-				length = typ.len
-				x.mode = variable
-				x.typ = typ.elem
-			}
-
-		case *Slice:
-			valid = true
-			x.mode = variable
-			x.typ = typ.elem
-
-		case *Map:
-			var key operand
-			check.expr(&key, e.Index)
-			check.assignment(&key, typ.key, "map index")
-			if x.mode == invalid {
-				goto Error
-			}
-			x.mode = mapindex
-			x.typ = typ.elem
+		if x.mode == mapindex {
 			x.expr = e
 			return expression
 		}
 
-		if !valid {
-			check.invalidOp(x.pos(), "cannot index %s", x)
-			goto Error
-		}
-
-		if e.Index == nil {
-			check.invalidAST(e.Pos(), "missing index for %s", x)
-			goto Error
-		}
-
-		check.index(e.Index, length)
-		// ok to continue
-
 	case *ast.SliceExpr:
 		check.expr(x, e.X)
 		if x.mode == invalid {
@@ -1325,7 +1500,7 @@ func (check *Checker) exprInternal(x *operand, e ast.Expr, hint Type) exprKind {
 
 		valid := false
 		length := int64(-1) // valid if >= 0
-		switch typ := x.typ.Underlying().(type) {
+		switch typ := coreType(x.typ).(type) {
 		case *Basic:
 			if isString(typ) {
 				if e.Slice3 {
@@ -1444,14 +1619,14 @@ func (check *Checker) exprInternal(x *operand, e ast.Expr, hint Type) exprKind {
 		return check.call(x, e)
 
 	case *ast.StarExpr:
-		check.exprOrType(x, e.X)
+		check.exprOrType(x, e.X, false)
 		switch x.mode {
 		case invalid:
 			goto Error
 		case typexpr:
 			x.typ = &Pointer{base: x.typ}
 		default:
-			if typ, ok := x.typ.Underlying().(*Pointer); ok {
+			if typ, ok := coreType(x.typ).(*Pointer); ok {
 				x.mode = variable
 				x.typ = typ.base
 			} else {
@@ -1571,7 +1746,7 @@ func (check *Checker) expr(x *operand, e ast.Expr) {
 
 // multiExpr is like expr but the result may be a multi-value.
 func (check *Checker) multiExpr(x *operand, e ast.Expr) {
-	check.rawExpr(x, e, nil)
+	check.rawExpr(x, e, nil, false)
 	var msg string
 	switch x.mode {
 	default:
@@ -1593,7 +1768,7 @@ func (check *Checker) multiExpr(x *operand, e ast.Expr) {
 //
 func (check *Checker) exprWithHint(x *operand, e ast.Expr, hint Type) {
 	assert(hint != nil)
-	check.rawExpr(x, e, hint)
+	check.rawExpr(x, e, hint, false)
 	check.singleValue(x)
 	var msg string
 	switch x.mode {
@@ -1611,10 +1786,11 @@ func (check *Checker) exprWithHint(x *operand, e ast.Expr, hint Type) {
 }
 
 // exprOrType typechecks expression or type e and initializes x with the expression value or type.
+// If allowGeneric is set, the operand type may be an uninstantiated generic function or type.
 // If an error occurred, x.mode is set to invalid.
 //
-func (check *Checker) exprOrType(x *operand, e ast.Expr) {
-	check.rawExpr(x, e, nil)
+func (check *Checker) exprOrType(x *operand, e ast.Expr, allowGeneric bool) {
+	check.rawExpr(x, e, nil, allowGeneric)
 	check.singleValue(x)
 	if x.mode == novalue {
 		check.errorf(x.pos(), "%s used as value or type", x)
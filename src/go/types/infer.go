@@ -0,0 +1,298 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements type argument inference.
+
+package types
+
+import "go/token"
+
+// infer attempts to infer the complete list of type arguments for the
+// type parameters tparams, given the explicitly provided (possibly
+// empty) list of type arguments targs, and the value arguments args
+// passed to parameters params. If variadic is set, the last parameter
+// is a slice and each excess argument is passed to its element type.
+// If successful, infer returns the complete list of type arguments;
+// otherwise it reports an error at pos and returns nil.
+//
+// Inference proceeds in three steps: first, the types of typed
+// arguments are unified with the types of their parameters; then,
+// untyped constant arguments passed to parameters of (bare) type
+// parameter type provide their default type; finally, type parameters
+// whose constraint has a single type term are unified with it.
+func (check *Checker) infer(pos token.Pos, tparams []*TypeParam, targs []Type, params *Tuple, variadic bool, args []*operand) []Type {
+	for _, arg := range args {
+		if arg.mode == invalid {
+			// error reported before
+			return nil
+		}
+	}
+
+	u := newUnifier(tparams)
+	copy(u.targs, targs)
+	if len(targs) == len(tparams) {
+		return u.targs
+	}
+
+	// paramType returns the type of the parameter passed the i'th argument.
+	paramType := func(i int) Type {
+		n := params.Len()
+		if variadic && i >= n-1 {
+			if s, _ := params.vars[n-1].typ.(*Slice); s != nil {
+				return s.elem
+			}
+		}
+		if i < n {
+			return params.vars[i].typ
+		}
+		return nil
+	}
+
+	// Step 1: unify the parameter and argument types of typed arguments.
+	var untyped []int // indices of untyped arguments
+	for i, arg := range args {
+		par := paramType(i)
+		if par == nil {
+			break // argument count errors are reported later
+		}
+		if isUntyped(arg.typ) {
+			untyped = append(untyped, i)
+			continue
+		}
+		if !u.unify(par, arg.typ, false) {
+			check.errorf(arg.pos(), "type %s of %s does not match %s", arg.typ, arg.expr, u.apply(par))
+			return nil
+		}
+	}
+
+	// Step 2: use the default type of untyped constant arguments
+	// for type parameters that are still unknown.
+	for _, tpar := range tparams {
+		i := u.index(tpar)
+		if u.targs[i] != nil {
+			continue
+		}
+		var max Type
+		for _, j := range untyped {
+			if paramType(j) != tpar || args[j].isNil() {
+				continue
+			}
+			t := args[j].typ
+			if max == nil {
+				max = t
+				continue
+			}
+			if !isNumeric(max) || !isNumeric(t) {
+				if !Identical(max, t) {
+					check.errorf(args[j].pos(), "mismatched types %s and %s (cannot infer %s)", max, t, tpar)
+					return nil
+				}
+				continue
+			}
+			if t.(*Basic).kind > max.(*Basic).kind {
+				max = t
+			}
+		}
+		if max != nil {
+			u.targs[i] = Default(max)
+		}
+	}
+
+	// Step 3: unify type parameters with the single type term of their
+	// constraints, if any, until no more type arguments are inferred.
+	for changed := true; changed; {
+		changed = false
+		for i, tpar := range tparams {
+			terms := tpar.iface().terms()
+			if len(terms) != 1 {
+				continue
+			}
+			term := terms[0]
+			n := u.known()
+			if targ := u.targs[i]; targ != nil {
+				if term.tilde {
+					targ = targ.Underlying()
+				}
+				u.unify(term.typ, targ, true)
+			} else if !term.tilde {
+				u.targs[i] = term.typ
+			}
+			if u.known() > n {
+				changed = true
+			}
+		}
+	}
+
+	for i, targ := range u.targs {
+		if targ == nil {
+			check.errorf(pos, "cannot infer %s", tparams[i].obj.name)
+			return nil
+		}
+	}
+
+	// Inferred type arguments may refer to other type parameters
+	// (for instance, from a constraint []E): substitute them.
+	for range tparams {
+		changed := false
+		for i, targ := range u.targs {
+			if t := u.apply(targ); t != targ {
+				u.targs[i] = t
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return u.targs
+}
+
+// A unifier infers the type arguments for a list of type parameters by
+// unifying types containing the type parameters with other types.
+type unifier struct {
+	tparams []*TypeParam
+	targs   []Type // inferred type argument for each type parameter, or nil
+}
+
+func newUnifier(tparams []*TypeParam) *unifier {
+	return &unifier{tparams, make([]Type, len(tparams))}
+}
+
+// index returns the index of the type parameter typ in u.tparams, or -1.
+func (u *unifier) index(typ Type) int {
+	if tpar, _ := typ.(*TypeParam); tpar != nil {
+		if i := tpar.index; i < len(u.tparams) && u.tparams[i] == tpar {
+			return i
+		}
+	}
+	return -1
+}
+
+// known returns the number of inferred type arguments.
+func (u *unifier) known() int {
+	n := 0
+	for _, targ := range u.targs {
+		if targ != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// apply returns typ with the type arguments inferred so far
+// substituted for their type parameters.
+func (u *unifier) apply(typ Type) Type {
+	smap := make(substMap)
+	for i, targ := range u.targs {
+		if targ != nil {
+			smap[u.tparams[i]] = targ
+		}
+	}
+	return subst(typ, smap)
+}
+
+// unify unifies the type x, which may contain type parameters of u,
+// with the type y, and reports whether that was successful. If exact
+// is not set, a named type unifies with a type literal if its
+// underlying type does, as for assignability.
+func (u *unifier) unify(x, y Type, exact bool) bool {
+	if x == y {
+		return true
+	}
+	if i := u.index(x); i >= 0 {
+		if targ := u.targs[i]; targ != nil {
+			if targ == x {
+				return false
+			}
+			return u.unify(targ, y, exact)
+		}
+		u.targs[i] = y
+		return true
+	}
+
+	if !exact {
+		// A named type unifies with a type literal if their
+		// underlying types do.
+		xn, _ := x.(*Named)
+		yn, _ := y.(*Named)
+		switch {
+		case xn != nil && yn == nil && !isTypeParam(y):
+			return u.unify(xn.Underlying(), y, true)
+		case xn == nil && yn != nil && !isTypeParam(x):
+			return u.unify(x, yn.Underlying(), true)
+		}
+	}
+
+	switch x := x.(type) {
+	case *Array:
+		if y, ok := y.(*Array); ok {
+			return x.len == y.len && u.unify(x.elem, y.elem, true)
+		}
+
+	case *Slice:
+		if y, ok := y.(*Slice); ok {
+			return u.unify(x.elem, y.elem, true)
+		}
+
+	case *Struct:
+		if y, ok := y.(*Struct); ok && x.NumFields() == y.NumFields() {
+			for i, f := range x.fields {
+				g := y.fields[i]
+				if f.anonymous != g.anonymous || x.Tag(i) != y.Tag(i) ||
+					!f.sameId(g.pkg, g.name) || !u.unify(f.typ, g.typ, true) {
+					return false
+				}
+			}
+			return true
+		}
+
+	case *Pointer:
+		if y, ok := y.(*Pointer); ok {
+			return u.unify(x.base, y.base, true)
+		}
+
+	case *Tuple:
+		if y, ok := y.(*Tuple); ok && x.Len() == y.Len() {
+			for i := 0; i < x.Len(); i++ {
+				if !u.unify(x.vars[i].typ, y.vars[i].typ, true) {
+					return false
+				}
+			}
+			return true
+		}
+
+	case *Signature:
+		if y, ok := y.(*Signature); ok {
+			return x.variadic == y.variadic &&
+				u.unify(x.params, y.params, true) &&
+				u.unify(x.results, y.results, true)
+		}
+
+	case *Map:
+		if y, ok := y.(*Map); ok {
+			return u.unify(x.key, y.key, true) && u.unify(x.elem, y.elem, true)
+		}
+
+	case *Chan:
+		if y, ok := y.(*Chan); ok {
+			// A bidirectional channel may be passed for a
+			// directional one.
+			return (x.dir == y.dir || !exact && y.dir == SendRecv) && u.unify(x.elem, y.elem, true)
+		}
+
+	case *Named:
+		if y, ok := y.(*Named); ok {
+			if x.orig != nil && x.orig == y.orig {
+				for i, targ := range x.targs {
+					if !u.unify(targ, y.targs[i], true) {
+						return false
+					}
+				}
+				return true
+			}
+		}
+	}
+
+	return Identical(u.apply(x), y)
+}
@@ -117,10 +117,10 @@ func NewMethodSet(T Type) *MethodSet {
 				}
 				seen[named] = true
 
-				mset = mset.add(named.methods, e.index, e.indirect, e.multiples)
+				mset = mset.add(named.expandMethods(), e.index, e.indirect, e.multiples)
 
 				// continue with underlying type
-				typ = named.underlying
+				typ = named.Underlying()
 			}
 
 			switch t := typ.(type) {
@@ -143,6 +143,9 @@ func NewMethodSet(T Type) *MethodSet {
 
 			case *Interface:
 				mset = mset.add(t.allMethods, e.index, true, e.multiples)
+
+			case *TypeParam:
+				mset = mset.add(t.iface().allMethods, e.index, true, e.multiples)
 			}
 		}
 
@@ -16,40 +16,35 @@ func isNamed(typ Type) bool {
 	return ok
 }
 
-func isBoolean(typ Type) bool {
+// is reports whether typ is a basic type with any of the properties
+// in info. If typ is a type parameter, is reports whether this holds
+// for all types in its type set.
+func is(typ Type, info BasicInfo) bool {
+	if t, _ := typ.(*TypeParam); t != nil {
+		return t.underIs(func(u Type) bool {
+			t, ok := u.(*Basic)
+			return ok && t.info&info != 0
+		})
+	}
 	t, ok := typ.Underlying().(*Basic)
-	return ok && t.info&IsBoolean != 0
+	return ok && t.info&info != 0
 }
 
-func isInteger(typ Type) bool {
-	t, ok := typ.Underlying().(*Basic)
-	return ok && t.info&IsInteger != 0
-}
+func isBoolean(typ Type) bool { return is(typ, IsBoolean) }
 
-func isUnsigned(typ Type) bool {
-	t, ok := typ.Underlying().(*Basic)
-	return ok && t.info&IsUnsigned != 0
-}
+func isInteger(typ Type) bool { return is(typ, IsInteger) }
 
-func isFloat(typ Type) bool {
-	t, ok := typ.Underlying().(*Basic)
-	return ok && t.info&IsFloat != 0
-}
+func isUnsigned(typ Type) bool { return is(typ, IsUnsigned) }
 
-func isComplex(typ Type) bool {
-	t, ok := typ.Underlying().(*Basic)
-	return ok && t.info&IsComplex != 0
-}
+func isFloat(typ Type) bool { return is(typ, IsFloat) }
 
-func isNumeric(typ Type) bool {
-	t, ok := typ.Underlying().(*Basic)
-	return ok && t.info&IsNumeric != 0
-}
+func isComplex(typ Type) bool { return is(typ, IsComplex) }
 
-func isString(typ Type) bool {
-	t, ok := typ.Underlying().(*Basic)
-	return ok && t.info&IsString != 0
-}
+func isNumeric(typ Type) bool { return is(typ, IsNumeric) }
+
+func isString(typ Type) bool { return is(typ, IsString) }
+
+func isNumericOrString(typ Type) bool { return is(typ, IsNumeric|IsString) }
 
 func isTyped(typ Type) bool {
 	t, ok := typ.Underlying().(*Basic)
@@ -61,10 +56,7 @@ func isUntyped(typ Type) bool {
 	return ok && t.info&IsUntyped != 0
 }
 
-func isOrdered(typ Type) bool {
-	t, ok := typ.Underlying().(*Basic)
-	return ok && t.info&IsOrdered != 0
-}
+func isOrdered(typ Type) bool { return is(typ, IsOrdered) }
 
 func isConstType(typ Type) bool {
 	t, ok := typ.Underlying().(*Basic)
@@ -80,6 +72,8 @@ func IsInterface(typ Type) bool {
 // Comparable reports whether values of type T are comparable.
 func Comparable(T Type) bool {
 	switch t := T.Underlying().(type) {
+	case *TypeParam:
+		return t.iface().comparable || t.underIs(Comparable)
 	case *Basic:
 		// assume invalid types to be comparable
 		// to avoid follow-up errors
@@ -102,6 +96,8 @@ func Comparable(T Type) bool {
 // hasNil reports whether a type includes the nil value.
 func hasNil(typ Type) bool {
 	switch t := typ.Underlying().(type) {
+	case *TypeParam:
+		return t.underIs(hasNil)
 	case *Basic:
 		return t.kind == UnsafePointer
 	case *Slice, *Pointer, *Signature, *Interface, *Map, *Chan:
@@ -209,6 +205,7 @@ func identical(x, y Type, cmpTags bool, p *ifacePair) bool {
 		// names are not required to match.
 		if y, ok := y.(*Signature); ok {
 			return x.variadic == y.variadic &&
+				len(x.tparams) == len(y.tparams) &&
 				identical(x.params, y.params, cmpTags, p) &&
 				identical(x.results, y.results, cmpTags, p)
 		}
@@ -218,6 +215,9 @@ func identical(x, y Type, cmpTags bool, p *ifacePair) bool {
 		// the same names and identical function types. Lower-case method names from
 		// different packages are always different. The order of the methods is irrelevant.
 		if y, ok := y.(*Interface); ok {
+			if x.comparable != y.comparable || !identicalUnions(x.allUnions, y.allUnions) {
+				return false
+			}
 			a := x.allMethods
 			b := y.allMethods
 			if len(a) == len(b) {
@@ -279,11 +279,18 @@ func identical(x, y Type, cmpTags bool, p *ifacePair) bool {
 
 	case *Named:
 		// Two named types are identical if their type names originate
-		// in the same type declaration.
+		// in the same type declaration. Two instances of a generic type
+		// are identical if their type arguments are identical.
 		if y, ok := y.(*Named); ok {
+			if x.orig != nil || y.orig != nil {
+				return x.orig == y.orig && identicalTypes(x.targs, y.targs)
+			}
 			return x.obj == y.obj
 		}
 
+	case *TypeParam, *Union:
+		// Type parameters are only identical to themselves.
+
 	case nil:
 
 	default:
@@ -293,6 +300,26 @@ func identical(x, y Type, cmpTags bool, p *ifacePair) bool {
 	return false
 }
 
+// identicalUnions reports whether the type elements x and y describe
+// the same type sets, term by term.
+func identicalUnions(x, y []*Union) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, u := range x {
+		v := y[i]
+		if len(u.terms) != len(v.terms) {
+			return false
+		}
+		for j, t := range u.terms {
+			if w := v.terms[j]; t.tilde != w.tilde || !Identical(t.typ, w.typ) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // Default returns the default "typed" type for an "untyped" type;
 // it returns the incoming type for all other types. The default type
 // for untyped nil is untyped nil.
@@ -16,12 +16,13 @@ import (
 
 // A declInfo describes a package-level const, type, var, or func declaration.
 type declInfo struct {
-	file  *Scope        // scope of file containing this declaration
-	lhs   []*Var        // lhs of n:1 variable declarations, or nil
-	typ   ast.Expr      // type, or nil
-	init  ast.Expr      // init/orig expression, or nil
-	fdecl *ast.FuncDecl // func declaration, or nil
-	alias bool          // type alias declaration
+	file    *Scope         // scope of file containing this declaration
+	lhs     []*Var         // lhs of n:1 variable declarations, or nil
+	typ     ast.Expr       // type, or nil
+	tparams *ast.FieldList // type parameters of a type declaration, or nil
+	init    ast.Expr       // init/orig expression, or nil
+	fdecl   *ast.FuncDecl  // func declaration, or nil
+	alias   bool           // type alias declaration
 
 	// The deps field tracks initialization expression dependencies.
 	// As a special (overloaded) case, it also tracks dependencies of
@@ -386,7 +387,7 @@ func (check *Checker) collectObjects() {
 
 					case *ast.TypeSpec:
 						obj := NewTypeName(s.Name.Pos(), pkg, s.Name.Name, nil)
-						check.declarePkgObj(s.Name, obj, &declInfo{file: fileScope, typ: s.Type, alias: s.Assign.IsValid()})
+						check.declarePkgObj(s.Name, obj, &declInfo{file: fileScope, typ: s.Type, tparams: s.TypeParams, alias: s.Assign.IsValid()})
 
 					default:
 						check.invalidAST(s.Pos(), "unknown ast.Spec node %T", s)
@@ -421,6 +422,13 @@ func (check *Checker) collectObjects() {
 						if ptr, _ := typ.(*ast.StarExpr); ptr != nil {
 							typ = unparen(ptr.X)
 						}
+						// the base type of a generic receiver is instantiated
+						switch x := typ.(type) {
+						case *ast.IndexExpr:
+							typ = x.X
+						case *ast.IndexListExpr:
+							typ = x.X
+						}
 						if base, _ := typ.(*ast.Ident); base != nil && base.Name != "_" {
 							check.assocMethod(base.Name, obj)
 						}
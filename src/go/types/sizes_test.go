@@ -110,3 +110,39 @@ const _ = unsafe.Offsetof(struct{ x int64 }{}.x)
 		_ = conf.Sizes.Alignof(tv.Type)
 	}
 }
+
+// The alignment of structs containing sync/atomic.align64 is 8,
+// even on systems where 64-bit words are only 4-byte aligned.
+func TestAtomicAlign(t *testing.T) {
+	const src = `
+package atomic
+
+type align64 struct{}
+
+var s struct {
+	b byte
+	x struct {
+		_ align64
+		v int64
+	}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("sync/atomic", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := pkg.Scope().Lookup("s").Type().(*types.Struct)
+	fields := []*types.Var{ts.Field(0), ts.Field(1)}
+	sizes := &types.StdSizes{WordSize: 4, MaxAlign: 4}
+	if got := sizes.Offsetsof(fields); got[1] != 8 {
+		t.Errorf("Offsetsof(%v) = %v, want [0 8]", ts, got)
+	}
+	if got := sizes.Sizeof(ts); got != 16 {
+		t.Errorf("Sizeof(%v) = %d, want 16", ts, got)
+	}
+}
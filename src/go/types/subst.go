@@ -0,0 +1,443 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the instantiation of generic types and
+// functions by substitution of type arguments for type parameters.
+
+package types
+
+import "fmt"
+
+// A substMap maps type parameters to the types substituted for them.
+type substMap map[*TypeParam]Type
+
+// makeSubstMap returns the substitution map for the given type
+// parameters and type arguments. Excess entries of either list are
+// ignored.
+func makeSubstMap(tparams []*TypeParam, targs []Type) substMap {
+	smap := make(substMap, len(tparams))
+	for i, tpar := range tparams {
+		if i < len(targs) {
+			smap[tpar] = targs[i]
+		}
+	}
+	return smap
+}
+
+// asTypes returns the type parameters as a list of types.
+func asTypes(tparams []*TypeParam) []Type {
+	list := make([]Type, len(tparams))
+	for i, tpar := range tparams {
+		list[i] = tpar
+	}
+	return list
+}
+
+// subst returns the type typ with the types in smap substituted for
+// the respective type parameters. If no substitution took place, the
+// result is typ itself. Named types are not substituted except for
+// their type arguments, if they are instances.
+func subst(typ Type, smap substMap) Type {
+	if len(smap) == 0 {
+		return typ
+	}
+	s := subster{smap, make(map[*Func]*Func)}
+	return s.typ(typ)
+}
+
+type subster struct {
+	smap    substMap
+	methods map[*Func]*Func // substituted methods, for sharing among interfaces
+}
+
+func (s *subster) typ(typ Type) Type {
+	switch t := typ.(type) {
+	case *TypeParam:
+		if u := s.smap[t]; u != nil {
+			return u
+		}
+
+	case *Array:
+		if elem := s.typ(t.elem); elem != t.elem {
+			return &Array{len: t.len, elem: elem}
+		}
+
+	case *Slice:
+		if elem := s.typ(t.elem); elem != t.elem {
+			return &Slice{elem: elem}
+		}
+
+	case *Struct:
+		if fields, copied := s.vars(t.fields); copied {
+			return &Struct{fields: fields, tags: t.tags}
+		}
+
+	case *Pointer:
+		if base := s.typ(t.base); base != t.base {
+			return &Pointer{base: base}
+		}
+
+	case *Tuple:
+		return s.tuple(t)
+
+	case *Signature:
+		// The receiver is not substituted: for interface methods it
+		// refers back to the interface.
+		params := s.tuple(t.params)
+		results := s.tuple(t.results)
+		if params != t.params || results != t.results {
+			return &Signature{
+				scope:    t.scope,
+				tparams:  t.tparams,
+				rparams:  t.rparams,
+				recv:     t.recv,
+				params:   params,
+				results:  results,
+				variadic: t.variadic,
+			}
+		}
+
+	case *Interface:
+		methods, mcopied := s.funcs(t.methods)
+		embeddeds, ecopied := s.nameds(t.embeddeds)
+		unions, ucopied := s.unions(t.unions)
+		if mcopied || ecopied || ucopied {
+			iface := &Interface{methods: methods, embeddeds: embeddeds, unions: unions, comparable: t.comparable}
+			if t.allMethods != nil {
+				iface.Complete()
+			}
+			return iface
+		}
+
+	case *Map:
+		key := s.typ(t.key)
+		elem := s.typ(t.elem)
+		if key != t.key || elem != t.elem {
+			return &Map{key: key, elem: elem}
+		}
+
+	case *Chan:
+		if elem := s.typ(t.elem); elem != t.elem {
+			return &Chan{dir: t.dir, elem: elem}
+		}
+
+	case *Named:
+		if t.orig != nil {
+			if targs, copied := s.types(t.targs); copied {
+				return newInstance(t.orig, targs)
+			}
+		}
+	}
+
+	return typ
+}
+
+func (s *subster) tuple(t *Tuple) *Tuple {
+	if t != nil {
+		if vars, copied := s.vars(t.vars); copied {
+			return &Tuple{vars: vars}
+		}
+	}
+	return t
+}
+
+func (s *subster) vars(in []*Var) (out []*Var, copied bool) {
+	out = in
+	for i, v := range in {
+		if typ := s.typ(v.typ); typ != v.typ {
+			if !copied {
+				out = make([]*Var, len(in))
+				copy(out, in)
+				copied = true
+			}
+			nv := *v
+			nv.typ = typ
+			out[i] = &nv
+		}
+	}
+	return
+}
+
+func (s *subster) funcs(in []*Func) (out []*Func, copied bool) {
+	out = in
+	for i, f := range in {
+		nf := s.methods[f]
+		if nf == nil {
+			nf = f
+			if typ := s.typ(f.typ); typ != f.typ {
+				nf = NewFunc(f.pos, f.pkg, f.name, typ.(*Signature))
+			}
+			s.methods[f] = nf
+		}
+		if nf != f {
+			if !copied {
+				out = make([]*Func, len(in))
+				copy(out, in)
+				copied = true
+			}
+			out[i] = nf
+		}
+	}
+	return
+}
+
+func (s *subster) nameds(in []*Named) (out []*Named, copied bool) {
+	out = in
+	for i, t := range in {
+		if typ := s.typ(t); typ != Type(t) {
+			if !copied {
+				out = make([]*Named, len(in))
+				copy(out, in)
+				copied = true
+			}
+			out[i] = typ.(*Named)
+		}
+	}
+	return
+}
+
+func (s *subster) unions(in []*Union) (out []*Union, copied bool) {
+	out = in
+	for i, u := range in {
+		var terms []*Term
+		for j, t := range u.terms {
+			if typ := s.typ(t.typ); typ != t.typ {
+				if terms == nil {
+					terms = make([]*Term, len(u.terms))
+					copy(terms, u.terms)
+				}
+				terms[j] = &Term{t.tilde, typ}
+			}
+		}
+		if terms != nil {
+			if !copied {
+				out = make([]*Union, len(in))
+				copy(out, in)
+				copied = true
+			}
+			out[i] = &Union{terms}
+		}
+	}
+	return
+}
+
+func (s *subster) types(in []Type) (out []Type, copied bool) {
+	out = in
+	for i, t := range in {
+		if typ := s.typ(t); typ != t {
+			if !copied {
+				out = make([]Type, len(in))
+				copy(out, in)
+				copied = true
+			}
+			out[i] = typ
+		}
+	}
+	return
+}
+
+// newInstance returns the instance of the generic type orig for the
+// given type arguments. Instances with identical type arguments are
+// shared.
+func newInstance(orig *Named, targs []Type) *Named {
+	for _, t := range orig.insts {
+		if identicalTypes(t.targs, targs) {
+			return t
+		}
+	}
+	t := &Named{obj: orig.obj, orig: orig, targs: targs}
+	orig.insts = append(orig.insts, t)
+	return t
+}
+
+// identicalTypes reports whether the type lists x and y are identical.
+func identicalTypes(x, y []Type) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, t := range x {
+		if !Identical(t, y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// expandUnderlying returns the underlying type of t, computing it
+// first if t is an instance.
+func (t *Named) expandUnderlying() Type {
+	if t.orig == nil || t.underlying != nil {
+		return t.underlying
+	}
+	u := t.orig.underlying
+	if u == nil || len(t.targs) != len(t.orig.tparams) {
+		// generic type not yet set up, or invalid instance
+		return Typ[Invalid]
+	}
+	if _, ok := u.(*Named); ok {
+		// generic type not yet set up completely
+		return Typ[Invalid]
+	}
+	t.underlying = subst(u, makeSubstMap(t.orig.tparams, t.targs))
+	return t.underlying
+}
+
+// expandMethods returns the methods of t, computing them first if t
+// is an instance. Methods may be added to the generic type while it is
+// type-checked; they are instantiated as they appear.
+func (t *Named) expandMethods() []*Func {
+	if t.orig != nil {
+		for i := len(t.methods); i < len(t.orig.methods); i++ {
+			t.methods = append(t.methods, t.instanceMethod(t.orig.methods[i]))
+		}
+	}
+	return t.methods
+}
+
+// instanceMethod returns the method m of the generic type of t,
+// instantiated for the instance t.
+func (t *Named) instanceMethod(m *Func) *Func {
+	sig, _ := m.typ.(*Signature)
+	if sig == nil || sig.recv == nil {
+		return m
+	}
+	smap := makeSubstMap(sig.rparams, t.targs)
+	var recvTyp Type = t
+	if _, ptr := deref(sig.recv.typ); ptr {
+		recvTyp = NewPointer(t)
+	}
+	recv := *sig.recv
+	recv.typ = recvTyp
+	return NewFunc(m.pos, m.pkg, m.name, &Signature{
+		scope:    sig.scope,
+		recv:     &recv,
+		params:   subst(sig.params, smap).(*Tuple),
+		results:  subst(sig.results, smap).(*Tuple),
+		variadic: sig.variadic,
+	})
+}
+
+// Instantiate instantiates the generic named type or function
+// signature orig with the given type arguments. If validate is set,
+// Instantiate verifies that the number of type arguments matches the
+// number of type parameters and that each type argument satisfies
+// the constraint of its type parameter. Without validation, orig may
+// be a named type whose type parameters are not set yet, as happens
+// with importers; its instances are completed once they are.
+func Instantiate(orig Type, targs []Type, validate bool) (Type, error) {
+	var tparams []*TypeParam
+	switch orig := orig.(type) {
+	case *Named:
+		if !validate {
+			return newInstance(orig, targs), nil
+		}
+		tparams = orig.tparams
+	case *Signature:
+		tparams = orig.tparams
+	}
+	if len(tparams) == 0 {
+		return nil, fmt.Errorf("%s is not a generic type or function", orig)
+	}
+	if validate {
+		if len(targs) != len(tparams) {
+			return nil, fmt.Errorf("got %d type arguments but %s has %d type parameters", len(targs), orig, len(tparams))
+		}
+		if _, err := verify(tparams, targs); err != nil {
+			return nil, err
+		}
+	}
+	return instantiate(orig, targs), nil
+}
+
+// instantiate instantiates the generic type or signature orig with the
+// given type arguments, without validation.
+func instantiate(orig Type, targs []Type) Type {
+	switch orig := orig.(type) {
+	case *Named:
+		return newInstance(orig, targs)
+	case *Signature:
+		smap := makeSubstMap(orig.tparams, targs)
+		return &Signature{
+			scope:    orig.scope,
+			recv:     orig.recv,
+			params:   subst(orig.params, smap).(*Tuple),
+			results:  subst(orig.results, smap).(*Tuple),
+			variadic: orig.variadic,
+		}
+	}
+	unreachable()
+	return nil
+}
+
+// verify verifies that each type argument satisfies the constraint of
+// its type parameter. If not, it returns the index of the first type
+// argument that doesn't, and an error describing why.
+func verify(tparams []*TypeParam, targs []Type) (int, error) {
+	smap := makeSubstMap(tparams, targs)
+	for i, tpar := range tparams {
+		if i >= len(targs) {
+			break
+		}
+		if err := implements(targs[i], subst(tpar.bound, smap)); err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// implements returns an error if the type V doesn't satisfy the
+// constraint bound.
+func implements(V, bound Type) error {
+	if bound == nil || V == Typ[Invalid] {
+		return nil
+	}
+	iface, _ := bound.Underlying().(*Interface)
+	if iface == nil {
+		return fmt.Errorf("%s is not an interface", bound)
+	}
+	if iface.allMethods == nil {
+		iface.Complete()
+	}
+	if iface.Empty() {
+		return nil
+	}
+
+	if iface.comparable && !Comparable(V) {
+		return fmt.Errorf("%s does not satisfy comparable", V)
+	}
+
+	if m, wrong := MissingMethod(V, &Interface{allMethods: iface.allMethods}, true); m != nil {
+		if wrong {
+			return fmt.Errorf("%s does not satisfy %s (wrong type for method %s)", V, bound, m.name)
+		}
+		return fmt.Errorf("%s does not satisfy %s (missing method %s)", V, bound, m.name)
+	}
+
+	if len(iface.allUnions) == 0 {
+		return nil
+	}
+	if tpar, _ := V.(*TypeParam); tpar != nil {
+		// The type set of V must be a subset of the type set of bound.
+		vi := tpar.iface()
+		terms := vi.terms()
+		if terms == nil {
+			return fmt.Errorf("%s does not satisfy %s", V, bound)
+		}
+		for _, term := range terms {
+			if !vi.includesTerm(term) {
+				continue
+			}
+			if !iface.includesTerm(term) {
+				return fmt.Errorf("%s does not satisfy %s (%s not in type set)", V, bound, term)
+			}
+		}
+		return nil
+	}
+	for _, u := range iface.allUnions {
+		if !u.includes(V) {
+			return fmt.Errorf("%s does not satisfy %s (%s missing in %s)", V, bound, V, u)
+		}
+	}
+	return nil
+}
@@ -197,11 +197,13 @@ type Signature struct {
 	// and store it in the Func Object) because when type-checking a function
 	// literal we call the general type checker which returns a general Type.
 	// We then unpack the *Signature and use the scope for the literal body.
-	scope    *Scope // function scope, present for package-local signatures
-	recv     *Var   // nil if not a method
-	params   *Tuple // (incoming) parameters from left to right; or nil
-	results  *Tuple // (outgoing) results from left to right; or nil
-	variadic bool   // true if the last parameter's type is of the form ...T (or string, for append built-in only)
+	scope    *Scope       // function scope, present for package-local signatures
+	tparams  []*TypeParam // type parameters from left to right; or nil
+	rparams  []*TypeParam // receiver type parameters from left to right; or nil
+	recv     *Var         // nil if not a method
+	params   *Tuple       // (incoming) parameters from left to right; or nil
+	results  *Tuple       // (outgoing) results from left to right; or nil
+	variadic bool         // true if the last parameter's type is of the form ...T (or string, for append built-in only)
 }
 
 // NewSignature returns a new function type for the given receiver, parameters,
@@ -218,7 +220,24 @@ func NewSignature(recv *Var, params, results *Tuple, variadic bool) *Signature {
 			panic("types.NewSignature: variadic parameter must be of unnamed slice type")
 		}
 	}
-	return &Signature{nil, recv, params, results, variadic}
+	return &Signature{recv: recv, params: params, results: results, variadic: variadic}
+}
+
+// NewSignatureType is like NewSignature but also takes the type
+// parameters of a generic function, typeParams, or, for a method of a
+// generic type, the type parameters declared by its receiver,
+// recvTypeParams. At most one of them may be non-empty.
+func NewSignatureType(recv *Var, recvTypeParams, typeParams []*TypeParam, params, results *Tuple, variadic bool) *Signature {
+	if len(recvTypeParams) != 0 && len(typeParams) != 0 {
+		panic("types.NewSignatureType: function with receiver and type parameters")
+	}
+	if len(recvTypeParams) != 0 && recv == nil {
+		panic("types.NewSignatureType: receiver type parameters without receiver")
+	}
+	sig := NewSignature(recv, params, results, variadic)
+	sig.rparams = recvTypeParams
+	sig.tparams = typeParams
+	return sig
 }
 
 // Recv returns the receiver of signature s (if a method), or nil if a
@@ -229,6 +248,14 @@ func NewSignature(recv *Var, params, results *Tuple, variadic bool) *Signature {
 // contain methods whose receiver type is a different interface.
 func (s *Signature) Recv() *Var { return s.recv }
 
+// TypeParams returns the type parameters of the generic function
+// signature s, or nil.
+func (s *Signature) TypeParams() []*TypeParam { return s.tparams }
+
+// RecvTypeParams returns the type parameters declared by the receiver
+// of the method signature s, if its receiver base type is generic, or nil.
+func (s *Signature) RecvTypeParams() []*TypeParam { return s.rparams }
+
 // Params returns the parameters of signature s, or nil.
 func (s *Signature) Params() *Tuple { return s.params }
 
@@ -242,8 +269,11 @@ func (s *Signature) Variadic() bool { return s.variadic }
 type Interface struct {
 	methods   []*Func  // ordered list of explicitly declared methods
 	embeddeds []*Named // ordered list of explicitly embedded types
+	unions    []*Union // list of explicitly declared type elements, in source order
 
-	allMethods []*Func // ordered list of methods declared with or embedded in this interface (TODO(gri): replace with mset)
+	allMethods []*Func  // ordered list of methods declared with or embedded in this interface (TODO(gri): replace with mset)
+	allUnions  []*Union // list of type elements declared with or embedded in this interface
+	comparable bool     // set if the type set contains only comparable types
 }
 
 // emptyInterface represents the empty (completed) interface
@@ -290,6 +320,18 @@ func (t *Interface) NumExplicitMethods() int { return len(t.methods) }
 // The methods are ordered by their unique Id.
 func (t *Interface) ExplicitMethod(i int) *Func { return t.methods[i] }
 
+// NewConstraint returns a new (incomplete) interface for the given
+// methods, embedded types, and type elements, to be used as a type
+// constraint. If comparable is set, the interface is restricted to
+// comparable types as if it embedded the predeclared comparable.
+// To compute the method set of the interface, Complete must be called.
+func NewConstraint(methods []*Func, embeddeds []*Named, unions []*Union, comparable bool) *Interface {
+	typ := NewInterface(methods, embeddeds)
+	typ.unions = unions
+	typ.comparable = comparable
+	return typ
+}
+
 // NumEmbeddeds returns the number of embedded types in interface t.
 func (t *Interface) NumEmbeddeds() int { return len(t.embeddeds) }
 
@@ -304,8 +346,26 @@ func (t *Interface) NumMethods() int { return len(t.allMethods) }
 // The methods are ordered by their unique Id.
 func (t *Interface) Method(i int) *Func { return t.allMethods[i] }
 
+// NumUnions returns the number of explicitly declared type elements
+// of interface t.
+func (t *Interface) NumUnions() int { return len(t.unions) }
+
+// Union returns the i'th explicitly declared type element of interface t
+// for 0 <= i < t.NumUnions().
+func (t *Interface) Union(i int) *Union { return t.unions[i] }
+
+// IsComparable reports whether the type set of the (complete) interface t
+// is restricted to comparable types, either by embedding the predeclared
+// comparable or because of its type elements.
+func (t *Interface) IsComparable() bool { return t.comparable }
+
+// IsMethodSet reports whether the (complete) interface t is fully
+// described by its method set. Interfaces that are not may only be
+// used as type constraints.
+func (t *Interface) IsMethodSet() bool { return len(t.allUnions) == 0 && !t.comparable }
+
 // Empty returns true if t is the empty interface.
-func (t *Interface) Empty() bool { return len(t.allMethods) == 0 }
+func (t *Interface) Empty() bool { return len(t.allMethods) == 0 && t.IsMethodSet() }
 
 // Complete computes the interface's method set. It must be called by users of
 // NewInterface after the interface's embedded types are fully defined and
@@ -316,6 +376,7 @@ func (t *Interface) Complete() *Interface {
 		return t
 	}
 
+	t.allUnions = t.unions
 	var allMethods []*Func
 	if t.embeddeds == nil {
 		if t.methods == nil {
@@ -328,6 +389,7 @@ func (t *Interface) Complete() *Interface {
 		for _, et := range t.embeddeds {
 			it := et.Underlying().(*Interface)
 			it.Complete()
+			t.addTypeSet(it)
 			for _, tm := range it.allMethods {
 				// Make a copy of the method and adjust its receiver type.
 				newm := *tm
@@ -344,6 +406,17 @@ func (t *Interface) Complete() *Interface {
 	return t
 }
 
+// addTypeSet restricts the type set of t to that of the embedded
+// interface it.
+func (t *Interface) addTypeSet(it *Interface) {
+	if len(it.allUnions) > 0 {
+		t.allUnions = append(t.allUnions[:len(t.allUnions):len(t.allUnions)], it.allUnions...)
+	}
+	if it.comparable {
+		t.comparable = true
+	}
+}
+
 // A Map represents a map type.
 type Map struct {
 	key, elem Type
@@ -388,10 +461,19 @@ func (c *Chan) Dir() ChanDir { return c.dir }
 func (c *Chan) Elem() Type { return c.elem }
 
 // A Named represents a named type.
+//
+// A generic named type has type parameters; it must be instantiated
+// with type arguments before use. The instances share the object of
+// the generic type, and their underlying types and methods are derived
+// from it by substitution, on demand.
 type Named struct {
-	obj        *TypeName // corresponding declared object
-	underlying Type      // possibly a *Named during setup; never a *Named once set up completely
-	methods    []*Func   // methods declared for this type (not the method set of this type)
+	obj        *TypeName    // corresponding declared object
+	underlying Type         // possibly a *Named during setup; never a *Named once set up completely
+	methods    []*Func      // methods declared for this type (not the method set of this type)
+	tparams    []*TypeParam // type parameters of a generic type; or nil
+	orig       *Named       // generic type of an instance; or nil
+	targs      []Type       // type arguments of an instance; or nil
+	insts      []*Named     // instances of a generic type, for reuse
 }
 
 // NewNamed returns a new named type for the given type name, underlying type, and associated methods.
@@ -411,11 +493,30 @@ func NewNamed(obj *TypeName, underlying Type, methods []*Func) *Named {
 // Obj returns the type name for the named type t.
 func (t *Named) Obj() *TypeName { return t.obj }
 
+// TypeParams returns the type parameters of the generic named type t, or nil.
+func (t *Named) TypeParams() []*TypeParam { return t.tparams }
+
+// SetTypeParams sets the type parameters of the named type t, which
+// makes it generic.
+func (t *Named) SetTypeParams(tparams []*TypeParam) { t.tparams = tparams }
+
+// TypeArgs returns the type arguments of the instantiated named type t, or nil.
+func (t *Named) TypeArgs() []Type { return t.targs }
+
+// Origin returns the generic type from which the named type t was
+// instantiated. If t is not an instance, Origin returns t.
+func (t *Named) Origin() *Named {
+	if t.orig != nil {
+		return t.orig
+	}
+	return t
+}
+
 // NumMethods returns the number of explicit methods whose receiver is named type t.
-func (t *Named) NumMethods() int { return len(t.methods) }
+func (t *Named) NumMethods() int { return len(t.expandMethods()) }
 
 // Method returns the i'th method of named type t for 0 <= i < t.NumMethods().
-func (t *Named) Method(i int) *Func { return t.methods[i] }
+func (t *Named) Method(i int) *Func { return t.expandMethods()[i] }
 
 // SetUnderlying sets the underlying type and marks t as complete.
 func (t *Named) SetUnderlying(underlying Type) {
@@ -447,7 +548,7 @@ func (t *Signature) Underlying() Type { return t }
 func (t *Interface) Underlying() Type { return t }
 func (t *Map) Underlying() Type       { return t }
 func (t *Chan) Underlying() Type      { return t }
-func (t *Named) Underlying() Type     { return t.underlying }
+func (t *Named) Underlying() Type     { return t.expandUnderlying() }
 
 func (t *Basic) String() string     { return TypeString(t, nil) }
 func (t *Array) String() string     { return TypeString(t, nil) }
@@ -0,0 +1,398 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements type parameters and the type sets of
+// constraint interfaces.
+
+package types
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// A TypeParam represents a type parameter of a generic function or
+// type. Its underlying type is the type parameter itself; the
+// operations permitted on its values are those permitted for all
+// types in the type set of its constraint.
+type TypeParam struct {
+	obj   *TypeName // corresponding type name
+	index int       // index of the type parameter in its list
+	bound Type      // constraint; an *Interface, or a *Named interface
+}
+
+// NewTypeParam returns a new type parameter for the given type name,
+// at the given index in its type parameter list, and with the given
+// constraint, which must be an interface. If the type name obj doesn't
+// have a type yet, its type is set to the returned type parameter.
+func NewTypeParam(obj *TypeName, index int, constraint Type) *TypeParam {
+	typ := &TypeParam{obj: obj, index: index, bound: constraint}
+	if obj.typ == nil {
+		obj.typ = typ
+	}
+	return typ
+}
+
+// Obj returns the type name for the type parameter t.
+func (t *TypeParam) Obj() *TypeName { return t.obj }
+
+// Index returns the index of the type parameter t in its type
+// parameter list.
+func (t *TypeParam) Index() int { return t.index }
+
+// Constraint returns the constraint of the type parameter t.
+func (t *TypeParam) Constraint() Type { return t.bound }
+
+// SetConstraint sets the constraint of the type parameter t.
+func (t *TypeParam) SetConstraint(bound Type) { t.bound = bound }
+
+// iface returns the constraint interface of t.
+func (t *TypeParam) iface() *Interface {
+	if t.bound != nil {
+		if iface, _ := t.bound.Underlying().(*Interface); iface != nil {
+			return iface
+		}
+	}
+	return &emptyInterface
+}
+
+func (t *TypeParam) Underlying() Type { return t }
+func (t *TypeParam) String() string   { return TypeString(t, nil) }
+
+// A Term represents a term of a union: a type T, or ~T for all types
+// whose underlying type is T.
+type Term struct {
+	tilde bool
+	typ   Type
+}
+
+// NewTerm returns a new union term.
+func NewTerm(tilde bool, typ Type) *Term { return &Term{tilde, typ} }
+
+// Tilde reports whether the term t has the form ~T.
+func (t *Term) Tilde() bool { return t.tilde }
+
+// Type returns the type of the term t, without the ~.
+func (t *Term) Type() Type { return t.typ }
+
+func (t *Term) String() string {
+	if t.tilde {
+		return "~" + TypeString(t.typ, nil)
+	}
+	return TypeString(t.typ, nil)
+}
+
+// includes reports whether the type x is in the type set of term t.
+func (t *Term) includes(x Type) bool {
+	if t.tilde {
+		return Identical(x.Underlying(), t.typ)
+	}
+	return Identical(x, t.typ)
+}
+
+// subsetOf reports whether the type set of term t is a subset of the
+// type set of term u.
+func (t *Term) subsetOf(u *Term) bool {
+	if u.tilde {
+		return Identical(t.typ.Underlying(), u.typ)
+	}
+	return !t.tilde && Identical(t.typ, u.typ)
+}
+
+// A Union represents a union of terms, as in a type constraint.
+// Unions may only appear as the type elements of an interface.
+type Union struct {
+	terms []*Term
+}
+
+// NewUnion returns a new union of the given terms.
+func NewUnion(terms []*Term) *Union { return &Union{terms} }
+
+// Len returns the number of terms of the union u.
+func (u *Union) Len() int { return len(u.terms) }
+
+// Term returns the i'th term of the union u, for 0 <= i < u.Len().
+func (u *Union) Term(i int) *Term { return u.terms[i] }
+
+// includes reports whether the type x is in the type set of u.
+func (u *Union) includes(x Type) bool {
+	for _, t := range u.terms {
+		if t.includes(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// subsetOf reports whether the type set of u is a subset of the type
+// set of v.
+func (u *Union) subsetOf(v *Union) bool {
+L:
+	for _, t := range u.terms {
+		for _, w := range v.terms {
+			if t.subsetOf(w) {
+				continue L
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (u *Union) Underlying() Type { return u }
+func (u *Union) String() string   { return TypeString(u, nil) }
+
+// terms returns the terms of the smallest union among the type
+// elements of the (complete) interface t, whose type set includes the
+// type set of t, or nil if t has no type elements and thus an
+// unrestricted type set.
+func (t *Interface) terms() []*Term {
+	var terms []*Term
+	for _, u := range t.allUnions {
+		if terms == nil || len(u.terms) < len(terms) {
+			terms = u.terms
+		}
+	}
+	return terms
+}
+
+// underIs calls f with the underlying type of each type in the type
+// set of the type parameter t, and reports whether all calls returned
+// true. If the type set is not restricted by type elements, underIs
+// reports false.
+func (t *TypeParam) underIs(f func(Type) bool) bool {
+	iface := t.iface()
+	terms := iface.terms()
+	if terms == nil {
+		return false
+	}
+	for _, term := range terms {
+		// A term is in the type set if all other type elements
+		// include it; others don't matter for the operation.
+		if !iface.includesTerm(term) {
+			continue
+		}
+		if !f(term.typ.Underlying()) {
+			return false
+		}
+	}
+	return true
+}
+
+// includesTerm reports whether all type elements of t include the type
+// set of term.
+func (t *Interface) includesTerm(term *Term) bool {
+	for _, u := range t.allUnions {
+		if !(&Union{[]*Term{term}}).subsetOf(u) {
+			return false
+		}
+	}
+	return true
+}
+
+// coreType returns the underlying type of typ. If typ is a type
+// parameter, coreType returns the single underlying type of all types
+// in its type set, if there is one, and nil otherwise.
+func coreType(typ Type) Type {
+	t, _ := typ.(*TypeParam)
+	if t == nil {
+		return typ.Underlying()
+	}
+	var core Type
+	if !t.underIs(func(u Type) bool {
+		if core == nil {
+			core = u
+			return true
+		}
+		return Identical(core, u)
+	}) {
+		return nil
+	}
+	return core
+}
+
+// isTypeParam reports whether typ is a type parameter.
+func isTypeParam(typ Type) bool {
+	_, ok := typ.(*TypeParam)
+	return ok
+}
+
+// declareTypeParams declares the type parameters in list in scope,
+// and returns them. Their constraints are type-checked after all of
+// them were declared, so that they may refer to each other.
+func (check *Checker) declareTypeParams(scope *Scope, list *ast.FieldList) []*TypeParam {
+	var tparams []*TypeParam
+	for _, f := range list.List {
+		for _, name := range f.Names {
+			obj := NewTypeName(name.Pos(), check.pkg, name.Name, nil)
+			tpar := NewTypeParam(obj, len(tparams), nil)
+			check.declare(scope, name, obj, scope.pos)
+			tparams = append(tparams, tpar)
+		}
+	}
+
+	// Type-check the constraints in the scope of the type parameters.
+	defer func(s *Scope) { check.scope = s }(check.scope)
+	check.scope = scope
+	i := 0
+	for _, f := range list.List {
+		bound := check.bound(f.Type)
+		for range f.Names {
+			tparams[i].bound = bound
+			i++
+		}
+	}
+	return tparams
+}
+
+// bound type-checks the constraint e of a type parameter and returns
+// it as an interface. A constraint that is not an interface, such as
+// a union of terms, is the type element of an implicit interface.
+func (check *Checker) bound(e ast.Expr) Type {
+	if isUnionExpr(e) {
+		iface := new(Interface)
+		if u := check.union(e); u != nil {
+			iface.unions = []*Union{u}
+			iface.allUnions = iface.unions
+		}
+		iface.allMethods = markComplete
+		return iface
+	}
+
+	typ := check.typ(e)
+	if typ == Typ[Invalid] {
+		return &emptyInterface
+	}
+	if _, ok := typ.Underlying().(*Interface); !ok {
+		// implicit interface with the single term typ
+		if isTypeParam(typ) {
+			check.errorf(e.Pos(), "cannot use a type parameter as constraint")
+			return &emptyInterface
+		}
+		iface := &Interface{allMethods: markComplete}
+		iface.unions = []*Union{{[]*Term{{false, typ}}}}
+		iface.allUnions = iface.unions
+		return iface
+	}
+	return typ
+}
+
+// isUnionExpr reports whether e is a union of terms or a ~T term, as
+// opposed to a single type.
+func isUnionExpr(e ast.Expr) bool {
+	switch e := unparen(e).(type) {
+	case *ast.BinaryExpr:
+		return e.Op == token.OR
+	case *ast.UnaryExpr:
+		return e.Op == token.TILDE
+	}
+	return false
+}
+
+// union type-checks the union of terms e, as in an interface or a type
+// constraint, and returns it; or nil in case of an error.
+func (check *Checker) union(e ast.Expr) *Union {
+	var terms []*Term
+	var collect func(e ast.Expr) bool
+	collect = func(e ast.Expr) bool {
+		if b, _ := unparen(e).(*ast.BinaryExpr); b != nil && b.Op == token.OR {
+			return collect(b.X) && collect(b.Y)
+		}
+		term := check.term(e)
+		if term == nil {
+			return false
+		}
+		terms = append(terms, term)
+		return true
+	}
+	if !collect(e) {
+		return nil
+	}
+	return &Union{terms}
+}
+
+// term type-checks a single term of a union.
+func (check *Checker) term(e ast.Expr) *Term {
+	tilde := false
+	if u, _ := unparen(e).(*ast.UnaryExpr); u != nil && u.Op == token.TILDE {
+		tilde = true
+		e = u.X
+	}
+	typ := check.typ(e)
+	if typ == Typ[Invalid] {
+		return nil
+	}
+	if isTypeParam(typ) {
+		check.errorf(e.Pos(), "cannot use a type parameter as union term")
+		return nil
+	}
+	if _, ok := typ.Underlying().(*Interface); ok {
+		check.errorf(e.Pos(), "cannot use interface %s in union", typ)
+		return nil
+	}
+	if tilde && !Identical(typ, typ.Underlying()) {
+		check.errorf(e.Pos(), "invalid use of ~ (underlying type of %s is %s)", typ, typ.Underlying())
+		return nil
+	}
+	return &Term{tilde, typ}
+}
+
+// unpackRecv unpacks the receiver type expression rtyp. If it has the
+// form T[P1, P2, ...] or *T[P1, P2, ...], unpackRecv returns the name
+// T of the receiver base type and the list of type parameter names;
+// otherwise the list is nil.
+func unpackRecv(rtyp ast.Expr) (rname ast.Expr, list []ast.Expr) {
+	rtyp = unparen(rtyp)
+	if ptr, _ := rtyp.(*ast.StarExpr); ptr != nil {
+		rtyp = unparen(ptr.X)
+	}
+	switch x := rtyp.(type) {
+	case *ast.IndexExpr:
+		return x.X, []ast.Expr{x.Index}
+	case *ast.IndexListExpr:
+		return x.X, x.Indices
+	}
+	return rtyp, nil
+}
+
+// declareRecvTypeParams declares the type parameters named by list in
+// scope, for the receiver of a method of the generic type rname, and
+// returns them. Their constraints are those of the generic type.
+func (check *Checker) declareRecvTypeParams(scope *Scope, rname ast.Expr, list []ast.Expr) []*TypeParam {
+	var base *Named
+	if name, _ := rname.(*ast.Ident); name != nil {
+		if _, obj := check.scope.LookupParent(name.Name, check.pos); obj != nil {
+			if tname, _ := obj.(*TypeName); tname != nil {
+				check.objDecl(tname, nil, nil)
+				base, _ = tname.typ.(*Named)
+			}
+		}
+	}
+	if base == nil || base.tparams == nil {
+		// error reported when the receiver type is type-checked
+		return nil
+	}
+
+	rparams := make([]*TypeParam, len(list))
+	for i, e := range list {
+		name, _ := e.(*ast.Ident)
+		if name == nil {
+			check.errorf(e.Pos(), "receiver type parameter %s must be an identifier", e)
+			name = &ast.Ident{NamePos: e.Pos(), Name: "_"}
+		}
+		obj := NewTypeName(name.Pos(), check.pkg, name.Name, nil)
+		rparams[i] = NewTypeParam(obj, i, nil)
+		check.declare(scope, name, obj, scope.pos)
+	}
+
+	// The constraints of the generic type may refer to its type
+	// parameters: substitute the receiver type parameters for them.
+	smap := makeSubstMap(base.tparams, asTypes(rparams))
+	for i, tpar := range rparams {
+		if i < len(base.tparams) {
+			tpar.bound = subst(base.tparams[i].bound, smap)
+		}
+	}
+	return rparams
+}
@@ -186,6 +186,20 @@ func writeType(buf *bytes.Buffer, typ Type, qf Qualifier, visited []Type) {
 				empty = false
 			}
 		}
+		for _, u := range t.unions {
+			if !empty {
+				buf.WriteString("; ")
+			}
+			writeUnion(buf, u, qf, visited)
+			empty = false
+		}
+		if t.comparable && !embedsComparable(t) {
+			if !empty {
+				buf.WriteString("; ")
+			}
+			buf.WriteString("comparable")
+			empty = false
+		}
 		if t.allMethods == nil || len(t.methods) > len(t.allMethods) {
 			if !empty {
 				buf.WriteByte(' ')
@@ -238,6 +252,19 @@ func writeType(buf *bytes.Buffer, typ Type, qf Qualifier, visited []Type) {
 			s = obj.name
 		}
 		buf.WriteString(s)
+		if t.targs != nil {
+			writeTypeList(buf, t.targs, qf, visited)
+		}
+
+	case *TypeParam:
+		s := "<TypeParam w/o object>"
+		if t.obj != nil {
+			s = t.obj.name
+		}
+		buf.WriteString(s)
+
+	case *Union:
+		writeUnion(buf, t, qf, visited)
 
 	default:
 		// For externally defined implementations of Type.
@@ -245,6 +272,53 @@ func writeType(buf *bytes.Buffer, typ Type, qf Qualifier, visited []Type) {
 	}
 }
 
+func writeTypeList(buf *bytes.Buffer, list []Type, qf Qualifier, visited []Type) {
+	buf.WriteByte('[')
+	for i, typ := range list {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		writeType(buf, typ, qf, visited)
+	}
+	buf.WriteByte(']')
+}
+
+func writeTypeParams(buf *bytes.Buffer, list []*TypeParam, qf Qualifier, visited []Type) {
+	buf.WriteByte('[')
+	for i, tpar := range list {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		writeType(buf, tpar, qf, visited)
+		buf.WriteByte(' ')
+		writeType(buf, tpar.bound, qf, visited)
+	}
+	buf.WriteByte(']')
+}
+
+func writeUnion(buf *bytes.Buffer, u *Union, qf Qualifier, visited []Type) {
+	for i, t := range u.terms {
+		if i > 0 {
+			buf.WriteString(" | ")
+		}
+		if t.tilde {
+			buf.WriteByte('~')
+		}
+		writeType(buf, t.typ, qf, visited)
+	}
+}
+
+// embedsComparable reports whether the comparable restriction of the
+// interface t is due to one of its embedded interfaces.
+func embedsComparable(t *Interface) bool {
+	for _, e := range t.embeddeds {
+		if it, _ := e.Underlying().(*Interface); it != nil && it.comparable {
+			return true
+		}
+	}
+	return false
+}
+
 func writeTuple(buf *bytes.Buffer, tup *Tuple, variadic bool, qf Qualifier, visited []Type) {
 	buf.WriteByte('(')
 	if tup != nil {
@@ -287,6 +361,9 @@ func WriteSignature(buf *bytes.Buffer, sig *Signature, qf Qualifier) {
 }
 
 func writeSignature(buf *bytes.Buffer, sig *Signature, qf Qualifier, visited []Type) {
+	if sig.tparams != nil {
+		writeTypeParams(buf, sig.tparams, qf, visited)
+	}
 	writeTuple(buf, sig.params, sig.variadic, qf, visited)
 
 	n := sig.results.Len()
@@ -149,9 +149,46 @@ func (check *Checker) funcType(sig *Signature, recvPar *ast.FieldList, ftyp *ast
 	scope.isFunc = true
 	check.recordScope(ftyp, scope)
 
-	recvList, _ := check.collectParams(scope, recvPar, false)
-	params, variadic := check.collectParams(scope, ftyp.Params, true)
-	results, _ := check.collectParams(scope, ftyp.Results, false)
+	if recvPar != nil && len(recvPar.List) > 0 {
+		// A receiver of the form T[P] or *T[P] declares the type
+		// parameters P of the generic receiver base type T.
+		if rname, list := unpackRecv(recvPar.List[0].Type); list != nil {
+			sig.rparams = check.declareRecvTypeParams(scope, rname, list)
+		}
+	}
+	if ftyp.TypeParams != nil {
+		if recvPar != nil {
+			check.errorf(ftyp.TypeParams.Pos(), "methods cannot have type parameters")
+		}
+		sig.tparams = check.declareTypeParams(scope, ftyp.TypeParams)
+	}
+
+	// The parameters of a generic function may refer to its type
+	// parameters, declared in the function scope, but the scope of
+	// the value parameters starts with the function body: collect
+	// them in a separate scope and move them over afterwards.
+	pscope := scope
+	if sig.tparams != nil || sig.rparams != nil {
+		defer func(s *Scope) { check.scope = s }(check.scope)
+		check.scope = scope
+		pscope = NewScope(nil, token.NoPos, token.NoPos, "parameters")
+	}
+
+	recvList, _ := check.collectParams(pscope, recvPar, false)
+	params, variadic := check.collectParams(pscope, ftyp.Params, true)
+	results, _ := check.collectParams(pscope, ftyp.Results, false)
+
+	if pscope != scope {
+		for _, name := range pscope.Names() {
+			obj := pscope.elems[name]
+			if alt := scope.Insert(obj); alt != nil {
+				check.errorf(obj.Pos(), "%s redeclared in this block", name)
+				check.reportAltDecl(alt)
+				continue
+			}
+			obj.setParent(scope)
+		}
+	}
 
 	if recvPar != nil {
 		// recv parameter list present (may be empty)
@@ -181,7 +218,7 @@ func (check *Checker) funcType(sig *Signature, recvPar *ast.FieldList, ftyp *ast
 					err = "type not defined in this package"
 				} else {
 					// TODO(gri) This is not correct if the underlying type is unknown yet.
-					switch u := T.underlying.(type) {
+					switch u := T.Origin().underlying.(type) {
 					case *Basic:
 						// unsafe.Pointer is treated like a regular pointer
 						if u.kind == UnsafePointer {
@@ -223,6 +260,10 @@ func (check *Checker) typExprInternal(e ast.Expr, def *Named, path []*TypeName)
 		switch x.mode {
 		case typexpr:
 			typ := x.typ
+			if isGeneric(typ) {
+				check.errorf(x.pos(), "cannot use generic type %s without instantiation", typ)
+				break
+			}
 			def.setUnderlying(typ)
 			return typ
 		case invalid:
@@ -240,6 +281,10 @@ func (check *Checker) typExprInternal(e ast.Expr, def *Named, path []*TypeName)
 		switch x.mode {
 		case typexpr:
 			typ := x.typ
+			if isGeneric(typ) {
+				check.errorf(x.pos(), "cannot use generic type %s without instantiation", typ)
+				break
+			}
 			def.setUnderlying(typ)
 			return typ
 		case invalid:
@@ -250,6 +295,9 @@ func (check *Checker) typExprInternal(e ast.Expr, def *Named, path []*TypeName)
 			check.errorf(x.pos(), "%s is not a type", &x)
 		}
 
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return check.instantiatedType(unpackIndexExpr(e), def, path)
+
 	case *ast.ParenExpr:
 		return check.typExpr(e.X, def, path)
 
@@ -259,12 +307,14 @@ func (check *Checker) typExprInternal(e ast.Expr, def *Named, path []*TypeName)
 			def.setUnderlying(typ)
 			typ.len = check.arrayLength(e.Len)
 			typ.elem = check.typExpr(e.Elt, nil, path)
+			check.validVarType(e.Elt, typ.elem)
 			return typ
 
 		} else {
 			typ := new(Slice)
 			def.setUnderlying(typ)
 			typ.elem = check.typ(e.Elt)
+			check.validVarType(e.Elt, typ.elem)
 			return typ
 		}
 
@@ -298,6 +348,8 @@ func (check *Checker) typExprInternal(e ast.Expr, def *Named, path []*TypeName)
 
 		typ.key = check.typ(e.Key)
 		typ.elem = check.typ(e.Value)
+		check.validVarType(e.Key, typ.key)
+		check.validVarType(e.Value, typ.elem)
 
 		// spec: "The comparison operators == and != must be fully defined
 		// for operands of the key type; thus the key type must not be a
@@ -332,6 +384,7 @@ func (check *Checker) typExprInternal(e ast.Expr, def *Named, path []*TypeName)
 
 		typ.dir = dir
 		typ.elem = check.typ(e.Value)
+		check.validVarType(e.Value, typ.elem)
 		return typ
 
 	default:
@@ -343,13 +396,153 @@ func (check *Checker) typExprInternal(e ast.Expr, def *Named, path []*TypeName)
 	return typ
 }
 
+// isGeneric reports whether typ is a generic type that has not been
+// instantiated.
+func isGeneric(typ Type) bool {
+	t, _ := typ.(*Named)
+	return t != nil && t.tparams != nil && t.targs == nil
+}
+
+// An indexedExpr is an *ast.IndexExpr or *ast.IndexListExpr, unpacked.
+type indexedExpr struct {
+	orig    ast.Expr   // the original expression
+	x       ast.Expr   // indexed expression
+	lbrack  token.Pos  // position of "["
+	indices []ast.Expr // index expressions
+	rbrack  token.Pos  // position of "]"
+}
+
+func (ix *indexedExpr) Pos() token.Pos { return ix.orig.Pos() }
+
+// unpackIndexExpr unpacks e, which must be an *ast.IndexExpr or an
+// *ast.IndexListExpr.
+func unpackIndexExpr(e ast.Expr) *indexedExpr {
+	switch e := e.(type) {
+	case *ast.IndexExpr:
+		return &indexedExpr{e, e.X, e.Lbrack, []ast.Expr{e.Index}, e.Rbrack}
+	case *ast.IndexListExpr:
+		return &indexedExpr{e, e.X, e.Lbrack, e.Indices, e.Rbrack}
+	}
+	unreachable()
+	return nil
+}
+
+// instantiatedType type-checks the instantiation ix of a generic type
+// and returns the instance, or Typ[Invalid].
+// For the meaning of def and path, see check.typExpr.
+func (check *Checker) instantiatedType(ix *indexedExpr, def *Named, path []*TypeName) Type {
+	typ := check.genericType(ix.x, path)
+	if typ == Typ[Invalid] {
+		def.setUnderlying(typ)
+		return typ
+	}
+	orig, _ := typ.(*Named)
+	if orig == nil || orig.tparams == nil || orig.targs != nil {
+		check.errorf(ix.x.Pos(), "%s is not a generic type", typ)
+		def.setUnderlying(Typ[Invalid])
+		return Typ[Invalid]
+	}
+
+	targs := check.typeList(ix.indices)
+	if targs == nil {
+		def.setUnderlying(Typ[Invalid])
+		return Typ[Invalid]
+	}
+	if len(targs) != len(orig.tparams) {
+		pos := ix.rbrack
+		if len(targs) > len(orig.tparams) {
+			pos = ix.indices[len(orig.tparams)].Pos()
+		}
+		check.errorf(pos, "got %d type arguments but %s has %d type parameters", len(targs), orig, len(orig.tparams))
+		def.setUnderlying(Typ[Invalid])
+		return Typ[Invalid]
+	}
+
+	inst := newInstance(orig, targs)
+	def.setUnderlying(inst)
+
+	// The constraints may not be set up yet if the generic type is
+	// declared in a cycle: verify them later.
+	check.delay(func() {
+		if i, err := verify(orig.tparams, targs); err != nil {
+			check.errorf(ix.indices[i].Pos(), "%s", err)
+		}
+	})
+
+	return inst
+}
+
+// genericType type-checks the type expression e, which may denote a
+// generic type, and returns its type, or Typ[Invalid].
+func (check *Checker) genericType(e ast.Expr, path []*TypeName) Type {
+	var x operand
+	switch e := e.(type) {
+	case *ast.Ident:
+		check.ident(&x, e, nil, path)
+	case *ast.SelectorExpr:
+		check.selector(&x, e)
+	case *ast.ParenExpr:
+		return check.genericType(e.X, path)
+	default:
+		check.errorf(e.Pos(), "%s is not a generic type", e)
+		return Typ[Invalid]
+	}
+
+	switch x.mode {
+	case typexpr:
+		check.recordTypeAndValue(e, typexpr, x.typ, nil)
+		return x.typ
+	case invalid:
+		// ignore - error reported before
+	case novalue:
+		check.errorf(x.pos(), "%s used as type", &x)
+	default:
+		check.errorf(x.pos(), "%s is not a type", &x)
+	}
+	return Typ[Invalid]
+}
+
+// typeList type-checks the list of type arguments and returns their
+// types, or nil if any of them is invalid.
+func (check *Checker) typeList(list []ast.Expr) []Type {
+	res := make([]Type, len(list))
+	for i, e := range list {
+		typ := check.typ(e)
+		if typ == Typ[Invalid] {
+			res = nil
+		}
+		check.validVarType(e, typ)
+		if res != nil {
+			res[i] = typ
+		}
+	}
+	return res
+}
+
+// validVarType reports an error if the type typ of the type expression
+// e is an interface which may only be used as a type constraint.
+// The check is delayed because typ may not be set up yet.
+func (check *Checker) validVarType(e ast.Expr, typ Type) {
+	switch typ.(type) {
+	case *Named, *Interface:
+		// may be a constraint interface
+	default:
+		return
+	}
+	check.delay(func() {
+		if t, _ := typ.Underlying().(*Interface); t != nil && !t.IsMethodSet() {
+			check.errorf(e.Pos(), "cannot use interface %s outside type constraints", typ)
+		}
+	})
+}
+
 // typeOrNil type-checks the type expression (or nil value) e
 // and returns the typ of e, or nil.
 // If e is neither a type nor nil, typOrNil returns Typ[Invalid].
 //
 func (check *Checker) typOrNil(e ast.Expr) Type {
 	var x operand
-	check.rawExpr(&x, e, nil)
+	check.rawExpr(&x, e, nil, false)
 	switch x.mode {
 	case invalid:
 		// ignore - error reported before
@@ -410,6 +603,7 @@ func (check *Checker) collectParams(scope *Scope, list *ast.FieldList, variadicO
 			}
 		}
 		typ := check.typ(ftype)
+		check.validVarType(ftype, typ)
 		// The parser ensures that f.Tag is nil and we don't
 		// care if a constructed AST contains a non-nil tag.
 		if len(field.Names) > 0 {
@@ -527,8 +721,17 @@ func (check *Checker) interfaceType(iface *Interface, ityp *ast.InterfaceType, d
 	//          those methods can be added to the list of all methods of this
 	//          interface.
 
+	var allUnions []*Union // type elements of embedded interfaces
 	for _, e := range embedded {
 		pos := e.Pos()
+		// A union of terms, a ~T term, or a single non-interface type
+		// is a type element restricting the type set of the interface.
+		if isUnionExpr(e) {
+			if u := check.union(e); u != nil {
+				iface.unions = append(iface.unions, u)
+			}
+			continue
+		}
 		typ := check.typExpr(e, nil, path)
 		// Determine underlying embedded (possibly incomplete) type
 		// by following its forward chain.
@@ -536,12 +739,23 @@ func (check *Checker) interfaceType(iface *Interface, ityp *ast.InterfaceType, d
 		under := underlying(named)
 		embed, _ := under.(*Interface)
 		if embed == nil {
-			if typ != Typ[Invalid] {
-				check.errorf(pos, "%s is not an interface", typ)
+			switch {
+			case typ == Typ[Invalid]:
+				// error reported before
+			case isTypeParam(typ):
+				check.errorf(pos, "cannot embed a type parameter")
+			case named == nil && IsInterface(typ):
+				check.errorf(pos, "%s is not a named interface", typ)
+			default:
+				iface.unions = append(iface.unions, &Union{[]*Term{{false, typ}}})
 			}
 			continue
 		}
 		iface.embeddeds = append(iface.embeddeds, named)
+		allUnions = append(allUnions, embed.allUnions...)
+		if embed.comparable {
+			iface.comparable = true
+		}
 		// collect embedded methods
 		if embed.allMethods == nil {
 			check.errorf(pos, "internal error: incomplete embedded interface %s (issue #18395)", named)
@@ -585,6 +799,10 @@ func (check *Checker) interfaceType(iface *Interface, ityp *ast.InterfaceType, d
 	// claim source order in the future. Revisit.
 	sort.Sort(byUniqueTypeName(iface.embeddeds))
 
+	if iface.unions != nil || allUnions != nil {
+		iface.allUnions = append(iface.unions[:len(iface.unions):len(iface.unions)], allUnions...)
+	}
+
 	if iface.allMethods == nil {
 		iface.allMethods = make([]*Func, 0) // mark interface as complete
 	} else {
@@ -653,6 +871,7 @@ func (check *Checker) structType(styp *Struct, e *ast.StructType, path []*TypeNa
 
 	for _, f := range list.List {
 		typ = check.typExpr(f.Type, nil, path)
+		check.validVarType(f.Type, typ)
 		tag = check.tag(f.Tag)
 		if len(f.Names) > 0 {
 			// named fields
@@ -714,6 +933,11 @@ func anonymousFieldIdent(e ast.Expr) *ast.Ident {
 		}
 	case *ast.SelectorExpr:
 		return e.Sel
+	case *ast.IndexExpr:
+		// T[A] is named T
+		return anonymousFieldIdent(e.X)
+	case *ast.IndexListExpr:
+		return anonymousFieldIdent(e.X)
 	}
 	return nil // invalid anonymous field
 }
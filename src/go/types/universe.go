@@ -77,6 +77,13 @@ func defPredeclaredTypes() {
 	typ := &Named{underlying: NewInterface([]*Func{err}, nil).Complete()}
 	sig.recv = NewVar(token.NoPos, nil, "", typ)
 	def(NewTypeName(token.NoPos, nil, "error", typ))
+
+	// any is an alias for interface{}
+	def(NewTypeName(token.NoPos, nil, "any", &emptyInterface))
+
+	// comparable is the constraint satisfied by all comparable types
+	comparable := &Named{underlying: &Interface{allMethods: markComplete, comparable: true}}
+	def(NewTypeName(token.NoPos, nil, "comparable", comparable))
 }
 
 var predeclaredConsts = [...]struct {
@@ -0,0 +1,99 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package heapdump reads the heap dumps written by runtime/debug.WriteHeapDump
+and works out the types of the objects in them.
+
+Format
+
+This is version 2 of the format. A heap dump starts with the header
+
+	go heap dump v2\n
+
+which names the version. Any incompatible change to the format changes the
+version, and this package rejects dumps with a version it does not know.
+
+The header is followed by a sequence of records. Each record starts with a
+tag and continues with the fields listed below for the tag. Unless noted
+otherwise, every field is an unsigned varint as written by
+encoding/binary.PutUvarint. A string field, and a memory field holding the
+contents of some memory, is a varint length followed by that many bytes. A
+bool field is a varint 0 or 1. Addresses are addresses in the dumped process.
+The last record has tag 0 and no fields.
+
+A fields list describes the pointers in some memory. It is a sequence of
+field kinds, each followed by the offset of the field in the memory, and ends
+with the kind 0 with no offset. The field kinds are
+
+	1 (ptr)    a pointer
+	2 (iface)  an interface with methods: a pointer to an itab, then a data word
+	3 (eface)  an empty interface: a pointer to a type, then a data word
+
+The records are
+
+	0  EOF         (none)
+	1  object      address, contents, fields
+	2  other root  description (string), address
+	3  type        address, size, size of the prefix holding pointers,
+	               kind, name (string), layout
+	4  goroutine   address of the G, sp of the top frame, goroutine ID,
+	               pc of the go statement that created it, status,
+	               whether it is a system goroutine (bool), 0 (bool),
+	               nanotime when it started waiting, wait reason (string),
+	               context pointer, address of its M, top defer, top panic
+	5  frame       sp, depth (0 for the top frame), sp of the child frame
+	               or 0, contents, function entry pc, pc, continuation pc,
+	               function name (string), fields
+	6  params      big-endian (bool), pointer size, heap start, heap end,
+	               GOARCH (string), GOEXPERIMENT (string), number of CPUs
+	7  finalizer   object, funcval, function pc, type of the function's
+	               argument, pointer type of the object
+	8  itab        address, type of the value it holds
+	9  thread      address of the M, ID, OS thread ID
+	10 memstats    the 24 fields of runtime.MemStats from Alloc to
+	               PauseTotalNs in order, then the 256 PauseNs, then NumGC
+	11 queued finalizer
+	               the same as a finalizer, for an object that is ready
+	               to be finalized
+	12 data        address, contents, fields of the data segment
+	13 bss         address, contents, fields of the bss segment
+	14 defer       address, G, sp, pc, funcval, function pc, next defer
+	15 panic       address, G, type of the argument, data word of the
+	               argument, 0, next panic
+	16 memprof     bucket address, allocation size, number of frames,
+	               then for each frame the function name (string), file
+	               (string) and line, then allocations, frees
+	17 alloc sample
+	               object address, bucket address
+
+The frames of a goroutine follow its goroutine record, starting from the top
+of the stack.
+
+A type is dumped once, and every type that the dump refers to is dumped,
+along with the types those refer to. Type records may come before or after
+the records that refer to them. The kind of a type is its reflect.Kind, plus
+32 if values of the type are stored directly in interfaces. The layout
+depends on the kind:
+
+	Array      element type, length
+	Chan       element type
+	Ptr        element type
+	Slice      element type
+	Map        key type, element type
+	Struct     number of fields, then for each field its name (string),
+	           offset and type
+	Interface  number of methods
+	others     (none)
+
+Object types
+
+The runtime does not record the type of each object, so Read infers the types
+from the interface values in memory: the type or itab word of an interface
+gives the type of the object its data word points to. From there the types of
+the fields of typed objects give the types of the objects they point to. An
+object that is reached only through untyped memory, such as an unsafe.Pointer,
+keeps a nil type.
+*/
+package heapdump
@@ -0,0 +1,330 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Dump is a heap dump.
+type Dump struct {
+	Params     Params
+	MemStats   *MemStats
+	Types      map[uint64]*Type // by address
+	Itabs      map[uint64]*Type // type of the value held, by itab address
+	Objects    []*Object        // sorted by address
+	Goroutines []*Goroutine
+	Threads    []*Thread
+	Data       *Segment
+	BSS        *Segment
+	Finalizers []*Finalizer
+	Defers     []*Defer
+	Panics     []*Panic
+	OtherRoots []*OtherRoot
+	AllocSites []*AllocSite
+}
+
+// Params describes the dumped process.
+type Params struct {
+	BigEndian  bool
+	PtrSize    uint64
+	HeapStart  uint64
+	HeapEnd    uint64
+	Arch       string
+	Experiment string
+	NCPU       uint64
+}
+
+// A Type is a Go type of the dumped process.
+type Type struct {
+	Addr        uint64
+	Name        string
+	Size        uint64
+	PtrData     uint64 // size of the prefix holding all pointers
+	Kind        reflect.Kind
+	DirectIface bool        // values are stored directly in interfaces
+	Elem        *Type       // Array, Chan, Map, Ptr and Slice
+	Key         *Type       // Map
+	Len         uint64      // Array
+	Fields      []TypeField // Struct
+	NumMethod   int         // Interface
+}
+
+func (t *Type) String() string {
+	if t == nil {
+		return "<unknown>"
+	}
+	return t.Name
+}
+
+// A TypeField is a field of a struct type.
+type TypeField struct {
+	Name   string
+	Offset uint64
+	Type   *Type
+}
+
+// A FieldKind is the kind of a pointer field in memory.
+type FieldKind uint8
+
+const (
+	FieldPtr   FieldKind = 1 // a pointer
+	FieldIface FieldKind = 2 // an interface with methods: itab and data words
+	FieldEface FieldKind = 3 // an empty interface: type and data words
+)
+
+// A Field is a pointer field in memory.
+type Field struct {
+	Kind   FieldKind
+	Offset uint64
+}
+
+// An Object is a heap object.
+type Object struct {
+	Addr   uint64
+	Data   []byte
+	Fields []Field
+	Type   *Type      // inferred, nil if unknown; the object may hold several values of the type
+	Alloc  *AllocSite // allocation site, if the allocation was sampled
+}
+
+// Size returns the size of o, which includes the rounding up to its size class.
+func (o *Object) Size() uint64 {
+	return uint64(len(o.Data))
+}
+
+// A Segment is the data or bss segment.
+type Segment struct {
+	Addr   uint64
+	Data   []byte
+	Fields []Field
+}
+
+// A Goroutine is a goroutine, with its stack.
+type Goroutine struct {
+	Addr       uint64 // of the G
+	SP         uint64 // of the top frame
+	ID         uint64
+	GoPC       uint64 // pc of the go statement that created the goroutine
+	Status     uint64
+	System     bool
+	WaitSince  int64 // nanotime when the goroutine started waiting
+	WaitReason string
+	Ctxt       uint64
+	M          uint64
+	Defer      uint64 // top defer record
+	Panic      uint64 // top panic record
+	Frames     []*Frame
+}
+
+// A Frame is a stack frame.
+type Frame struct {
+	SP      uint64
+	Depth   uint64 // 0 for the top frame
+	ChildSP uint64 // 0 for the top frame
+	Data    []byte
+	Entry   uint64
+	PC      uint64
+	ContPC  uint64
+	Func    string
+	Fields  []Field
+}
+
+// A Thread is an OS thread (M).
+type Thread struct {
+	Addr   uint64 // of the M
+	ID     uint64
+	ProcID uint64
+}
+
+// A Finalizer is a finalizer set on an object.
+type Finalizer struct {
+	Obj     uint64
+	Fn      uint64 // funcval
+	FnPC    uint64
+	ArgType *Type
+	ObjType *Type // pointer type of Obj
+	Queued  bool  // Obj is ready to be finalized
+}
+
+// A Defer is a deferred call of a goroutine.
+type Defer struct {
+	Addr uint64
+	G    uint64
+	SP   uint64
+	PC   uint64
+	Fn   uint64 // funcval
+	FnPC uint64
+	Link uint64 // next defer
+}
+
+// A Panic is a panic in progress in a goroutine.
+type Panic struct {
+	Addr    uint64
+	G       uint64
+	ArgType *Type
+	ArgData uint64 // data word of the argument
+	Link    uint64 // next panic
+}
+
+// An OtherRoot is a pointer to the heap held by the runtime.
+type OtherRoot struct {
+	Description string
+	Addr        uint64
+}
+
+// An AllocSite is a memory profile bucket: an allocation stack and size.
+type AllocSite struct {
+	Addr   uint64
+	Size   uint64
+	Stack  []AllocFrame
+	Allocs uint64
+	Frees  uint64
+}
+
+// An AllocFrame is a frame of an allocation stack.
+type AllocFrame struct {
+	Func string
+	File string
+	Line uint64
+}
+
+// MemStats is the runtime.MemStats of the dumped process.
+type MemStats struct {
+	Alloc        uint64
+	TotalAlloc   uint64
+	Sys          uint64
+	Lookups      uint64
+	Mallocs      uint64
+	Frees        uint64
+	HeapAlloc    uint64
+	HeapSys      uint64
+	HeapIdle     uint64
+	HeapInuse    uint64
+	HeapReleased uint64
+	HeapObjects  uint64
+	StackInuse   uint64
+	StackSys     uint64
+	MSpanInuse   uint64
+	MSpanSys     uint64
+	MCacheInuse  uint64
+	MCacheSys    uint64
+	BuckHashSys  uint64
+	GCSys        uint64
+	OtherSys     uint64
+	NextGC       uint64
+	LastGC       uint64
+	PauseTotalNs uint64
+	PauseNs      [256]uint64
+	NumGC        uint64
+}
+
+// FindObject returns the object that contains addr, or nil.
+func (d *Dump) FindObject(addr uint64) *Object {
+	i := sort.Search(len(d.Objects), func(i int) bool {
+		return d.Objects[i].Addr+d.Objects[i].Size() > addr
+	})
+	if i < len(d.Objects) && d.Objects[i].Addr <= addr {
+		return d.Objects[i]
+	}
+	return nil
+}
+
+// ReadPtr returns the pointer at offset off in data.
+func (d *Dump) ReadPtr(data []byte, off uint64) uint64 {
+	if off+d.Params.PtrSize > uint64(len(data)) {
+		return 0
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if d.Params.BigEndian {
+		order = binary.BigEndian
+	}
+	if d.Params.PtrSize == 4 {
+		return uint64(order.Uint32(data[off:]))
+	}
+	return order.Uint64(data[off:])
+}
+
+// A Ref is a pointer from some memory to an object.
+type Ref struct {
+	Offset uint64  // of the pointer in the memory
+	Addr   uint64  // the pointer
+	Obj    *Object // the object Addr points into
+}
+
+// Refs returns the pointers to objects in data, whose pointer fields are
+// given by fields. Pointers that do not point into an object are left out.
+// The data word of an interface is the only word that can point to an
+// object.
+func (d *Dump) Refs(data []byte, fields []Field) []Ref {
+	var refs []Ref
+	for _, f := range fields {
+		off := f.Offset
+		if f.Kind == FieldIface || f.Kind == FieldEface {
+			off += d.Params.PtrSize
+		}
+		p := d.ReadPtr(data, off)
+		if p == 0 {
+			continue
+		}
+		if obj := d.FindObject(p); obj != nil {
+			refs = append(refs, Ref{Offset: off, Addr: p, Obj: obj})
+		}
+	}
+	return refs
+}
+
+// A Root is a source of pointers into the heap that is not itself a
+// heap object: a segment, a stack frame, a finalizer, and so on.
+type Root struct {
+	Name string
+	Refs []Ref
+}
+
+// Roots returns the roots of the heap.
+func (d *Dump) Roots() []*Root {
+	var roots []*Root
+	add := func(name string, refs []Ref) {
+		if len(refs) > 0 {
+			roots = append(roots, &Root{Name: name, Refs: refs})
+		}
+	}
+	addPtr := func(name string, p uint64) {
+		if obj := d.FindObject(p); obj != nil {
+			add(name, []Ref{{Addr: p, Obj: obj}})
+		}
+	}
+	if d.Data != nil {
+		add("data", d.Refs(d.Data.Data, d.Data.Fields))
+	}
+	if d.BSS != nil {
+		add("bss", d.Refs(d.BSS.Data, d.BSS.Fields))
+	}
+	for _, g := range d.Goroutines {
+		for _, f := range g.Frames {
+			add(fmt.Sprintf("goroutine %d: %s", g.ID, f.Func), d.Refs(f.Data, f.Fields))
+		}
+		addPtr(fmt.Sprintf("goroutine %d: context", g.ID), g.Ctxt)
+	}
+	for _, f := range d.Finalizers {
+		addPtr(fmt.Sprintf("finalizer for %#x", f.Obj), f.Fn)
+		if f.Queued {
+			addPtr("queued finalizer", f.Obj)
+		}
+	}
+	for _, df := range d.Defers {
+		addPtr(fmt.Sprintf("defer record %#x", df.Addr), df.Fn)
+	}
+	for _, p := range d.Panics {
+		addPtr(fmt.Sprintf("panic record %#x", p.Addr), p.ArgData)
+	}
+	for _, r := range d.OtherRoots {
+		addPtr(r.Description, r.Addr)
+	}
+	return roots
+}
@@ -0,0 +1,148 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump_test
+
+import (
+	"bytes"
+	. "internal/heapdump"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+type node struct {
+	next  *node
+	items []*item
+	val   int
+}
+
+type item struct {
+	name string
+	n    int64
+}
+
+type labeler interface {
+	label() string
+}
+
+func (it *item) label() string { return it.name }
+
+var (
+	sinkEface interface{}
+	sinkIface labeler
+)
+
+func writeDump(t *testing.T) *Dump {
+	t.Helper()
+	if runtime.GOOS == "nacl" {
+		t.Skip("WriteHeapDump is not available on NaCl.")
+	}
+	f, err := ioutil.TempFile("", "heapdumptest")
+	if err != nil {
+		t.Fatalf("TempFile failed: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	debug.WriteHeapDump(f.Fd())
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Read(f)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return d
+}
+
+func TestRead(t *testing.T) {
+	it := &item{name: "item", n: 7}
+	n2 := &node{val: 2, items: []*item{it}}
+	n1 := &node{next: n2, val: 1}
+	sinkEface = n1
+	sinkIface = it
+	defer func() { sinkEface, sinkIface = nil, nil }()
+
+	d := writeDump(t)
+
+	if d.Params.PtrSize != uint64(unsafe.Sizeof(uintptr(0))) || d.Params.Arch != runtime.GOARCH {
+		t.Errorf("got params %+v", d.Params)
+	}
+	if d.MemStats == nil || d.MemStats.HeapAlloc == 0 {
+		t.Errorf("got memstats %+v", d.MemStats)
+	}
+	if len(d.Goroutines) == 0 || len(d.Goroutines[0].Frames) == 0 {
+		t.Errorf("no goroutines with stacks in the dump")
+	}
+
+	for _, c := range []struct {
+		p    unsafe.Pointer
+		name string
+	}{
+		{unsafe.Pointer(n1), "internal/heapdump_test.node"},
+		{unsafe.Pointer(n2), "internal/heapdump_test.node"},
+		{unsafe.Pointer(&n2.items[0]), "*heapdump_test.item"},
+		{unsafe.Pointer(it), "internal/heapdump_test.item"},
+	} {
+		obj := d.FindObject(uint64(uintptr(c.p)))
+		if obj == nil {
+			t.Errorf("object %p of type %s not in the dump", c.p, c.name)
+			continue
+		}
+		if obj.Type == nil || obj.Type.Name != c.name {
+			t.Errorf("object %p has type %v, want %s", c.p, obj.Type, c.name)
+		}
+	}
+
+	// The dumped memory of n1 points to n2.
+	obj := d.FindObject(uint64(uintptr(unsafe.Pointer(n1))))
+	if obj == nil {
+		t.Fatalf("object %p not in the dump", n1)
+	}
+	refs := d.Refs(obj.Data, obj.Fields)
+	if len(refs) == 0 || refs[0].Addr != uint64(uintptr(unsafe.Pointer(n2))) {
+		t.Errorf("refs of %p are %v, want %p first", n1, refs, n2)
+	}
+
+	typ := obj.Type
+	if typ.Kind != reflect.Struct || len(typ.Fields) != 3 || typ.Fields[1].Name != "items" || typ.Fields[1].Type.Kind != reflect.Slice {
+		t.Errorf("bad layout for %s: %+v", typ, typ.Fields)
+	}
+
+	roots := d.Roots()
+	found := false
+	for _, r := range roots {
+		for _, ref := range r.Refs {
+			if ref.Obj == obj {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no root points to %p", n1)
+	}
+	runtime.KeepAlive(n1)
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		err  string
+	}{
+		{"go1.7 heap dump\n", "unsupported header"},
+		{Header, "unexpected EOF"},
+		{Header + "\x63", "unknown record tag 99"},
+		{Header + "\x01\x10\x02ab\x07", "unknown field kind 7"},
+	} {
+		_, err := Read(bytes.NewReader([]byte(c.data)))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Read(%q) = %v, want error containing %q", c.data, err, c.err)
+		}
+	}
+}
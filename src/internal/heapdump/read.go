@@ -0,0 +1,372 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Header is the header of the heap dumps that this package reads.
+const Header = "go heap dump v2\n"
+
+// Record tags.
+const (
+	tagEOF             = 0
+	tagObject          = 1
+	tagOtherRoot       = 2
+	tagType            = 3
+	tagGoroutine       = 4
+	tagStackFrame      = 5
+	tagParams          = 6
+	tagFinalizer       = 7
+	tagItab            = 8
+	tagOSThread        = 9
+	tagMemStats        = 10
+	tagQueuedFinalizer = 11
+	tagData            = 12
+	tagBSS             = 13
+	tagDefer           = 14
+	tagPanic           = 15
+	tagMemProf         = 16
+	tagAllocSample     = 17
+)
+
+// kindDirectIface is added to the kind of a type whose values are
+// stored directly in interfaces.
+const kindDirectIface = 32
+
+// maxLen bounds the length of strings and memory in a dump, to avoid
+// huge allocations for corrupt input.
+const maxLen = 1 << 40
+
+// Read reads a heap dump and infers the types of its objects.
+func Read(r io.Reader) (*Dump, error) {
+	rd := &reader{
+		r: bufio.NewReader(r),
+		d: &Dump{
+			Types: make(map[uint64]*Type),
+			Itabs: make(map[uint64]*Type),
+		},
+	}
+	if err := rd.read(); err != nil {
+		return nil, err
+	}
+	d := rd.d
+	sort.Slice(d.Objects, func(i, j int) bool {
+		return d.Objects[i].Addr < d.Objects[j].Addr
+	})
+	for addr, site := range rd.samples {
+		if obj := d.FindObject(addr); obj != nil && obj.Addr == addr {
+			obj.Alloc = rd.sites[site]
+		}
+	}
+	d.inferTypes()
+	return d, nil
+}
+
+type reader struct {
+	r       *bufio.Reader
+	d       *Dump
+	err     error
+	g       *Goroutine            // goroutine the next frames belong to
+	sites   map[uint64]*AllocSite // by bucket address
+	samples map[uint64]uint64     // object address to bucket address
+}
+
+func (r *reader) read() error {
+	hdr := make([]byte, len(Header))
+	if _, err := io.ReadFull(r.r, hdr); err != nil {
+		return fmt.Errorf("heapdump: reading header: %v", err)
+	}
+	if string(hdr) != Header {
+		return fmt.Errorf("heapdump: unsupported header %q", hdr)
+	}
+	r.sites = make(map[uint64]*AllocSite)
+	r.samples = make(map[uint64]uint64)
+	d := r.d
+	for {
+		tag := r.uvarint()
+		if r.err != nil {
+			return r.error()
+		}
+		switch tag {
+		case tagEOF:
+			return nil
+		case tagObject:
+			d.Objects = append(d.Objects, &Object{
+				Addr:   r.uvarint(),
+				Data:   r.bytes(),
+				Fields: r.fields(),
+			})
+		case tagOtherRoot:
+			d.OtherRoots = append(d.OtherRoots, &OtherRoot{
+				Description: r.string(),
+				Addr:        r.uvarint(),
+			})
+		case tagType:
+			r.typ()
+		case tagGoroutine:
+			g := &Goroutine{
+				Addr:   r.uvarint(),
+				SP:     r.uvarint(),
+				ID:     r.uvarint(),
+				GoPC:   r.uvarint(),
+				Status: r.uvarint(),
+				System: r.bool(),
+			}
+			r.bool() // formerly whether the goroutine was in the background
+			g.WaitSince = int64(r.uvarint())
+			g.WaitReason = r.string()
+			g.Ctxt = r.uvarint()
+			g.M = r.uvarint()
+			g.Defer = r.uvarint()
+			g.Panic = r.uvarint()
+			d.Goroutines = append(d.Goroutines, g)
+			r.g = g
+		case tagStackFrame:
+			f := &Frame{
+				SP:      r.uvarint(),
+				Depth:   r.uvarint(),
+				ChildSP: r.uvarint(),
+				Data:    r.bytes(),
+				Entry:   r.uvarint(),
+				PC:      r.uvarint(),
+				ContPC:  r.uvarint(),
+				Func:    r.string(),
+				Fields:  r.fields(),
+			}
+			if r.g == nil {
+				return errors.New("heapdump: stack frame outside of a goroutine")
+			}
+			r.g.Frames = append(r.g.Frames, f)
+		case tagParams:
+			d.Params = Params{
+				BigEndian:  r.bool(),
+				PtrSize:    r.uvarint(),
+				HeapStart:  r.uvarint(),
+				HeapEnd:    r.uvarint(),
+				Arch:       r.string(),
+				Experiment: r.string(),
+				NCPU:       r.uvarint(),
+			}
+			if ps := d.Params.PtrSize; ps != 4 && ps != 8 {
+				return fmt.Errorf("heapdump: unsupported pointer size %d", ps)
+			}
+		case tagFinalizer, tagQueuedFinalizer:
+			d.Finalizers = append(d.Finalizers, &Finalizer{
+				Obj:     r.uvarint(),
+				Fn:      r.uvarint(),
+				FnPC:    r.uvarint(),
+				ArgType: r.typeRef(),
+				ObjType: r.typeRef(),
+				Queued:  tag == tagQueuedFinalizer,
+			})
+		case tagItab:
+			addr := r.uvarint()
+			d.Itabs[addr] = r.typeRef()
+		case tagOSThread:
+			d.Threads = append(d.Threads, &Thread{
+				Addr:   r.uvarint(),
+				ID:     r.uvarint(),
+				ProcID: r.uvarint(),
+			})
+		case tagMemStats:
+			r.memStats()
+		case tagData, tagBSS:
+			s := &Segment{
+				Addr:   r.uvarint(),
+				Data:   r.bytes(),
+				Fields: r.fields(),
+			}
+			if tag == tagData {
+				d.Data = s
+			} else {
+				d.BSS = s
+			}
+		case tagDefer:
+			d.Defers = append(d.Defers, &Defer{
+				Addr: r.uvarint(),
+				G:    r.uvarint(),
+				SP:   r.uvarint(),
+				PC:   r.uvarint(),
+				Fn:   r.uvarint(),
+				FnPC: r.uvarint(),
+				Link: r.uvarint(),
+			})
+		case tagPanic:
+			p := &Panic{
+				Addr:    r.uvarint(),
+				G:       r.uvarint(),
+				ArgType: r.typeRef(),
+				ArgData: r.uvarint(),
+			}
+			r.uvarint() // formerly the defer record of the panic
+			p.Link = r.uvarint()
+			d.Panics = append(d.Panics, p)
+		case tagMemProf:
+			site := &AllocSite{
+				Addr: r.uvarint(),
+				Size: r.uvarint(),
+			}
+			n := r.uvarint()
+			if n > maxLen {
+				return errors.New("heapdump: bad memory profile record")
+			}
+			for i := uint64(0); i < n && r.err == nil; i++ {
+				site.Stack = append(site.Stack, AllocFrame{
+					Func: r.string(),
+					File: r.string(),
+					Line: r.uvarint(),
+				})
+			}
+			site.Allocs = r.uvarint()
+			site.Frees = r.uvarint()
+			d.AllocSites = append(d.AllocSites, site)
+			r.sites[site.Addr] = site
+		case tagAllocSample:
+			addr := r.uvarint()
+			r.samples[addr] = r.uvarint()
+		default:
+			return fmt.Errorf("heapdump: unknown record tag %d", tag)
+		}
+	}
+}
+
+// error returns the error that stopped the reader, with a missing end
+// of the dump reported as such.
+func (r *reader) error() error {
+	if r.err == io.EOF {
+		r.err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("heapdump: %v", r.err)
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		r.err = err
+	}
+	return v
+}
+
+func (r *reader) bool() bool {
+	return r.uvarint() != 0
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if n > maxLen {
+		r.err = fmt.Errorf("length %d too large", n)
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		r.err = err
+	}
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func (r *reader) fields() []Field {
+	var fields []Field
+	for r.err == nil {
+		kind := FieldKind(r.uvarint())
+		if kind == 0 {
+			break
+		}
+		if kind > FieldEface {
+			r.err = fmt.Errorf("unknown field kind %d", kind)
+			break
+		}
+		fields = append(fields, Field{Kind: kind, Offset: r.uvarint()})
+	}
+	return fields
+}
+
+// typeRef reads the address of a type and returns the type, which may
+// not have been read yet.
+func (r *reader) typeRef() *Type {
+	addr := r.uvarint()
+	if addr == 0 {
+		return nil
+	}
+	t := r.d.Types[addr]
+	if t == nil {
+		t = &Type{Addr: addr}
+		r.d.Types[addr] = t
+	}
+	return t
+}
+
+func (r *reader) typ() {
+	t := r.typeRef()
+	if t == nil {
+		r.err = errors.New("type at address 0")
+		return
+	}
+	t.Size = r.uvarint()
+	t.PtrData = r.uvarint()
+	kind := r.uvarint()
+	t.Kind = reflect.Kind(kind &^ kindDirectIface)
+	t.DirectIface = kind&kindDirectIface != 0
+	t.Name = r.string()
+	switch t.Kind {
+	case reflect.Array:
+		t.Elem = r.typeRef()
+		t.Len = r.uvarint()
+	case reflect.Chan, reflect.Ptr, reflect.Slice:
+		t.Elem = r.typeRef()
+	case reflect.Map:
+		t.Key = r.typeRef()
+		t.Elem = r.typeRef()
+	case reflect.Struct:
+		n := r.uvarint()
+		if n > maxLen {
+			r.err = fmt.Errorf("type %s has %d fields", t.Name, n)
+			return
+		}
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			t.Fields = append(t.Fields, TypeField{
+				Name:   r.string(),
+				Offset: r.uvarint(),
+				Type:   r.typeRef(),
+			})
+		}
+	case reflect.Interface:
+		t.NumMethod = int(r.uvarint())
+	}
+}
+
+func (r *reader) memStats() {
+	m := new(MemStats)
+	for _, p := range []*uint64{
+		&m.Alloc, &m.TotalAlloc, &m.Sys, &m.Lookups, &m.Mallocs, &m.Frees,
+		&m.HeapAlloc, &m.HeapSys, &m.HeapIdle, &m.HeapInuse, &m.HeapReleased, &m.HeapObjects,
+		&m.StackInuse, &m.StackSys, &m.MSpanInuse, &m.MSpanSys, &m.MCacheInuse, &m.MCacheSys,
+		&m.BuckHashSys, &m.GCSys, &m.OtherSys, &m.NextGC, &m.LastGC, &m.PauseTotalNs,
+	} {
+		*p = r.uvarint()
+	}
+	for i := range m.PauseNs {
+		m.PauseNs[i] = r.uvarint()
+	}
+	m.NumGC = r.uvarint()
+	r.d.MemStats = m
+}
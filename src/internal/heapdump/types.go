@@ -0,0 +1,130 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package heapdump
+
+import "reflect"
+
+// inferTypes sets the types of the objects that can be reached from an
+// interface value through typed pointers. See the package documentation.
+func (d *Dump) inferTypes() {
+	var queue []*Object
+	assign := func(addr uint64, t *Type) {
+		if t == nil || t.Size == 0 {
+			return
+		}
+		// Only a pointer to the start of an object gives the type of
+		// the whole object; an interior pointer gives the type of a
+		// part of it.
+		obj := d.FindObject(addr)
+		if obj == nil || obj.Addr != addr || obj.Type != nil || obj.Size() < t.Size {
+			return
+		}
+		obj.Type = t
+		queue = append(queue, obj)
+	}
+
+	// The interface values in memory seed the types.
+	seed := func(data []byte, fields []Field) {
+		for _, f := range fields {
+			if f.Kind != FieldPtr {
+				d.walkInterface(data, f.Offset, f.Kind == FieldIface, assign)
+			}
+		}
+	}
+	for _, obj := range d.Objects {
+		seed(obj.Data, obj.Fields)
+	}
+	for _, s := range []*Segment{d.Data, d.BSS} {
+		if s != nil {
+			seed(s.Data, s.Fields)
+		}
+	}
+	for _, g := range d.Goroutines {
+		for _, f := range g.Frames {
+			seed(f.Data, f.Fields)
+		}
+	}
+	for _, f := range d.Finalizers {
+		if f.ObjType != nil && f.ObjType.Kind == reflect.Ptr {
+			assign(f.Obj, f.ObjType.Elem)
+		}
+	}
+	for _, p := range d.Panics {
+		d.assignInterface(p.ArgType, p.ArgData, assign)
+	}
+
+	// Then the typed objects give the types of the objects they
+	// point to. An object can hold several values of its type, for
+	// example the backing array of a slice.
+	for len(queue) > 0 {
+		obj := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		t := obj.Type
+		if t.PtrData == 0 {
+			continue
+		}
+		for off := uint64(0); off+t.Size <= obj.Size(); off += t.Size {
+			d.walk(obj.Data, off, t, assign)
+		}
+	}
+}
+
+// walk calls assign for each pointer in the value of type t at offset off
+// in data, with the type of what it points to.
+func (d *Dump) walk(data []byte, off uint64, t *Type, assign func(uint64, *Type)) {
+	if t == nil || t.PtrData == 0 || off+t.Size > uint64(len(data)) {
+		return
+	}
+	switch t.Kind {
+	case reflect.Ptr, reflect.Slice:
+		// A slice points to the start of an array of its elements.
+		if p := d.ReadPtr(data, off); p != 0 {
+			assign(p, t.Elem)
+		}
+	case reflect.Interface:
+		d.walkInterface(data, off, t.NumMethod > 0, assign)
+	case reflect.Array:
+		if t.Elem == nil || t.Elem.Size == 0 {
+			return
+		}
+		for i := uint64(0); i < t.Len; i++ {
+			d.walk(data, off+i*t.Elem.Size, t.Elem, assign)
+		}
+	case reflect.Struct:
+		for _, f := range t.Fields {
+			d.walk(data, off+f.Offset, f.Type, assign)
+		}
+	}
+}
+
+// walkInterface calls assign for the data word of the interface value at
+// offset off in data.
+func (d *Dump) walkInterface(data []byte, off uint64, iface bool, assign func(uint64, *Type)) {
+	w := d.ReadPtr(data, off)
+	var t *Type
+	if iface {
+		t = d.Itabs[w]
+	} else {
+		t = d.Types[w]
+	}
+	d.assignInterface(t, d.ReadPtr(data, off+d.Params.PtrSize), assign)
+}
+
+// assignInterface calls assign for the data word p of an interface value
+// holding type t.
+func (d *Dump) assignInterface(t *Type, p uint64, assign func(uint64, *Type)) {
+	if t == nil || p == 0 {
+		return
+	}
+	if !t.DirectIface {
+		// The data word points to a copy of the value.
+		assign(p, t)
+		return
+	}
+	// The data word is the value itself.
+	if t.Kind == reflect.Ptr {
+		assign(p, t.Elem)
+	}
+}
@@ -36,6 +36,7 @@ type Event struct {
 	Off   int       // offset in input file (for debugging and error reporting)
 	Type  byte      // one of Ev*
 	seq   int64     // sequence number
+	gen   int       // index of the trace generation the event belongs to
 	Ts    int64     // timestamp in nanoseconds
 	P     int       // P on which the event happened (can be one of TimerP, NetpollP, SyscallP)
 	G     uint64    // G on which the event happened
@@ -55,6 +56,8 @@ type Event struct {
 	// for blocking GoSysCall: the associated GoSysExit
 	// for GoSysExit: the next GoStart
 	// for GCMarkAssistStart: the associated GCMarkAssistDone
+	// for UserTaskCreate: the UserTaskEnd
+	// for UserRegion: if the start region, the corresponding UserRegion end event
 	Link *Event
 }
 
@@ -98,11 +101,11 @@ func Parse(r io.Reader, bin string) (ParseResult, error) {
 // parse parses, post-processes and verifies the trace. It returns the
 // trace version and the list of events.
 func parse(r io.Reader, bin string) (int, ParseResult, error) {
-	ver, rawEvents, strings, err := readTrace(r)
+	ver, gens, err := readTrace(r)
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
-	events, stacks, err := parseEvents(ver, rawEvents, strings)
+	events, stacks, err := mergeGenerations(ver, gens)
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
@@ -110,7 +113,7 @@ func parse(r io.Reader, bin string) (int, ParseResult, error) {
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
-	err = postProcessTrace(ver, events)
+	events, err = postProcessTrace(ver, events)
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
@@ -130,14 +133,25 @@ func parse(r io.Reader, bin string) (int, ParseResult, error) {
 
 // rawEvent is a helper type used during parsing.
 type rawEvent struct {
-	off  int
-	typ  byte
-	args []uint64
+	off   int
+	typ   byte
+	args  []uint64
+	sargs []string
+}
+
+// rawGeneration is the raw data of one trace generation.
+//
+// Since Go 1.11, a trace may be a sequence of generations. Each
+// generation starts with the trace header and has its own string and
+// stack tables, so that it can be parsed on its own.
+type rawGeneration struct {
+	events  []rawEvent
+	strings map[uint64]string
 }
 
 // readTrace does wire-format parsing and verification.
 // It does not care about specific event types and argument meaning.
-func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]string, err error) {
+func readTrace(r io.Reader) (ver int, gens []rawGeneration, err error) {
 	// Read and validate trace header.
 	var buf [16]byte
 	off, err := io.ReadFull(r, buf[:])
@@ -150,7 +164,7 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 		return
 	}
 	switch ver {
-	case 1005, 1007, 1008, 1009, 1010:
+	case 1005, 1007, 1008, 1009, 1010, 1011:
 		// Note: When adding a new version, add canned traces
 		// from the old version to the test suite using mkcanned.bash.
 		break
@@ -160,7 +174,8 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 	}
 
 	// Read events.
-	strings = make(map[uint64]string)
+	var events []rawEvent
+	strings := make(map[uint64]string)
 	for {
 		// Read event type and number of arguments (1 byte).
 		off0 := off
@@ -175,6 +190,30 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 			return
 		}
 		off += n
+		if buf[0] == 'g' && ver >= 1011 {
+			// The header of the next generation. No event starts
+			// with 'g': that would be an EvGoUnblockLocal without
+			// a stack argument.
+			n, err = io.ReadFull(r, buf[1:])
+			off += n
+			if err != nil {
+				err = fmt.Errorf("failed to read header at offset 0x%x: read %v, err %v", off0, n, err)
+				return
+			}
+			var ver1 int
+			ver1, err = parseHeader(buf[:])
+			if err != nil {
+				return
+			}
+			if ver1 != ver {
+				err = fmt.Errorf("generation at offset 0x%x has version %v, want %v", off0, ver1, ver)
+				return
+			}
+			gens = append(gens, rawGeneration{events, strings})
+			events = nil
+			strings = make(map[uint64]string)
+			continue
+		}
 		typ := buf[0] << 2 >> 2
 		narg := buf[0]>>6 + 1
 		inlineArgs := byte(4)
@@ -201,28 +240,16 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 				err = fmt.Errorf("string at offset %d has duplicate id %v", off, id)
 				return
 			}
-			var ln uint64
-			ln, off, err = readVal(r, off)
+			var s string
+			s, off, err = readStr(r, off)
 			if err != nil {
 				return
 			}
-			if ln == 0 {
+			if len(s) == 0 {
 				err = fmt.Errorf("string at offset %d has invalid length 0", off)
 				return
 			}
-			if ln > 1e6 {
-				err = fmt.Errorf("string at offset %d has too large length %v", off, ln)
-				return
-			}
-			buf := make([]byte, ln)
-			var n int
-			n, err = io.ReadFull(r, buf)
-			if err != nil {
-				err = fmt.Errorf("failed to read trace at offset %d: read %v, want %v, error %v", off, n, ln, err)
-				return
-			}
-			off += n
-			strings[id] = string(buf)
+			strings[id] = s
 			continue
 		}
 		ev := rawEvent{typ: typ, off: off0}
@@ -259,11 +286,40 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 				return
 			}
 		}
+		switch ev.typ {
+		case EvUserLog: // EvUserLog records are followed by a value string
+			var s string
+			s, off, err = readStr(r, off)
+			if err != nil {
+				return
+			}
+			ev.sargs = append(ev.sargs, s)
+		}
 		events = append(events, ev)
 	}
+	gens = append(gens, rawGeneration{events, strings})
 	return
 }
 
+// readStr reads a string of the form [length, string] from r,
+// where length is an unsigned base-128 value.
+func readStr(r io.Reader, off0 int) (s string, off int, err error) {
+	var sz uint64
+	sz, off, err = readVal(r, off0)
+	if err != nil {
+		return "", off, err
+	}
+	if sz > 1e6 {
+		return "", off, fmt.Errorf("string at offset %d has too large length %v", off0, sz)
+	}
+	buf := make([]byte, sz)
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return "", off, fmt.Errorf("failed to read trace at offset %d: read %v, want %v, error %v", off, n, sz, err)
+	}
+	return string(buf), off + n, nil
+}
+
 // parseHeader parses trace header of the form "go 1.7 trace\x00\x00\x00\x00"
 // and returns parsed version as 1007.
 func parseHeader(buf []byte) (int, error) {
@@ -288,10 +344,70 @@ func parseHeader(buf []byte) (int, error) {
 	return ver, nil
 }
 
+// mergeGenerations parses the generations of the trace and merges their
+// events and stacks. Stack IDs are renumbered to be unique across
+// generations, and time stamps are translated to nanoseconds since the
+// first event.
+func mergeGenerations(ver int, gens []rawGeneration) (events []*Event, stacks map[uint64][]*Frame, err error) {
+	stacks = make(map[uint64][]*Frame)
+	var base uint64 // added to the stack IDs of the generation
+	var start, lastMinTs int64
+	var lastFreq float64
+	for i, gen := range gens {
+		var evs []*Event
+		var stks map[uint64][]*Frame
+		var ticksPerSec int64
+		evs, stks, ticksPerSec, err = parseEvents(ver, gen.events, gen.strings)
+		if err != nil {
+			return
+		}
+		var maxID uint64
+		for id, stk := range stks {
+			if id > maxID {
+				maxID = id
+			}
+			stacks[id+base] = stk
+		}
+
+		// Translate cpu ticks to real time. Each generation has its own
+		// timer frequency, so the time of a generation is counted from
+		// the time of its first event, as measured by the previous one.
+		minTs := evs[0].Ts
+		if i > 0 {
+			start += int64(float64(minTs-lastMinTs) * lastFreq)
+		}
+		// Use floating point to avoid integer overflows.
+		freq := 1e9 / float64(ticksPerSec)
+		for _, ev := range evs {
+			ev.Ts = start + int64(float64(ev.Ts-minTs)*freq)
+			ev.gen = i
+			if ev.StkID > maxID {
+				maxID = ev.StkID
+			}
+			if ev.StkID != 0 {
+				ev.StkID += base
+			}
+			if ev.Type == EvGoCreate {
+				if ev.Args[1] > maxID {
+					maxID = ev.Args[1]
+				}
+				if ev.Args[1] != 0 {
+					ev.Args[1] += base
+				}
+			}
+		}
+		lastMinTs, lastFreq = minTs, freq
+		base += maxID
+		events = append(events, evs...)
+	}
+	return
+}
+
 // Parse events transforms raw events into events.
 // It does analyze and verify per-event-type arguments.
-func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (events []*Event, stacks map[uint64][]*Frame, err error) {
-	var ticksPerSec, lastSeq, lastTs int64
+// The time stamps of the events are in cpu ticks.
+func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (events []*Event, stacks map[uint64][]*Frame, ticksPerSec int64, err error) {
+	var lastSeq, lastTs int64
 	var lastG uint64
 	var lastP int
 	timerGoids := make(map[uint64]bool)
@@ -416,6 +532,15 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 				lastG = 0
 			case EvGoSysExit, EvGoWaiting, EvGoInSyscall:
 				e.G = e.Args[0]
+			case EvUserTaskCreate:
+				// e.Args 0: taskID, 1:parentID, 2:nameID
+				e.SArgs = []string{strings[e.Args[2]]}
+			case EvUserRegion:
+				// e.Args 0: taskID, 1: mode, 2:nameID
+				e.SArgs = []string{strings[e.Args[2]]}
+			case EvUserLog:
+				// e.Args 0: taskID, 1:keyID, 2: stackID
+				e.SArgs = []string{strings[e.Args[1]], raw.sargs[0]}
 			}
 			batches[lastP] = append(batches[lastP], e)
 		}
@@ -447,12 +572,7 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 		return
 	}
 
-	// Translate cpu ticks to real time.
-	minTs := events[0].Ts
-	// Use floating point to avoid integer overflows.
-	freq := 1e9 / float64(ticksPerSec)
 	for _, ev := range events {
-		ev.Ts = int64(float64(ev.Ts-minTs) * freq)
 		// Move timers and syscalls to separate fake Ps.
 		if timerGoids[ev.G] && ev.Type == EvGoUnblock {
 			ev.P = TimerP
@@ -528,7 +648,11 @@ var ErrTimeOrder = fmt.Errorf("time stamps out of order")
 // The resulting trace is guaranteed to be consistent
 // (for example, a P does not run two Gs at the same time, or a G is indeed
 // blocked before an unblock event).
-func postProcessTrace(ver int, events []*Event) error {
+//
+// Every generation of the trace starts with the state of all goroutines,
+// Ps and the GC. After the first generation, most of that state is known
+// from the previous generation, and the events restating it are dropped.
+func postProcessTrace(ver int, events []*Event) ([]*Event, error) {
 	const (
 		gDead = iota
 		gRunnable
@@ -551,6 +675,8 @@ func postProcessTrace(ver int, events []*Event) error {
 
 	gs := make(map[uint64]gdesc)
 	ps := make(map[int]pdesc)
+	tasks := make(map[uint64]*Event)           // task id to task creation events
+	activeRegions := make(map[uint64][]*Event) // goroutine id to stack of regions
 	gs[0] = gdesc{state: gRunning}
 	var evGC, evSTW *Event
 
@@ -568,34 +694,43 @@ func postProcessTrace(ver int, events []*Event) error {
 		return nil
 	}
 
+	newEvents := events[:0] // overwrite the original slice
 	for _, ev := range events {
 		g := gs[ev.G]
 		p := ps[ev.P]
 
 		switch ev.Type {
 		case EvProcStart:
+			if p.running && ev.gen > 0 {
+				// The P ran at the end of the previous generation.
+				continue
+			}
 			if p.running {
-				return fmt.Errorf("p %v is running before start (offset %v, time %v)", ev.P, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("p %v is running before start (offset %v, time %v)", ev.P, ev.Off, ev.Ts)
 			}
 			p.running = true
 		case EvProcStop:
 			if !p.running {
-				return fmt.Errorf("p %v is not running before stop (offset %v, time %v)", ev.P, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("p %v is not running before stop (offset %v, time %v)", ev.P, ev.Off, ev.Ts)
 			}
 			if p.g != 0 {
-				return fmt.Errorf("p %v is running a goroutine %v during stop (offset %v, time %v)", ev.P, p.g, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("p %v is running a goroutine %v during stop (offset %v, time %v)", ev.P, p.g, ev.Off, ev.Ts)
 			}
 			p.running = false
 		case EvGCStart:
+			if evGC != nil && ev.gen > 0 {
+				// The GC was running at the end of the previous generation.
+				continue
+			}
 			if evGC != nil {
-				return fmt.Errorf("previous GC is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
+				return nil, fmt.Errorf("previous GC is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
 			}
 			evGC = ev
 			// Attribute this to the global GC state.
 			ev.P = GCP
 		case EvGCDone:
 			if evGC == nil {
-				return fmt.Errorf("bogus GC end (offset %v, time %v)", ev.Off, ev.Ts)
+				return nil, fmt.Errorf("bogus GC end (offset %v, time %v)", ev.Off, ev.Ts)
 			}
 			evGC.Link = ev
 			evGC = nil
@@ -606,7 +741,7 @@ func postProcessTrace(ver int, events []*Event) error {
 				evp = &p.evSTW
 			}
 			if *evp != nil {
-				return fmt.Errorf("previous STW is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
+				return nil, fmt.Errorf("previous STW is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
 			}
 			*evp = ev
 		case EvGCSTWDone:
@@ -616,18 +751,18 @@ func postProcessTrace(ver int, events []*Event) error {
 				evp = &p.evSTW
 			}
 			if *evp == nil {
-				return fmt.Errorf("bogus STW end (offset %v, time %v)", ev.Off, ev.Ts)
+				return nil, fmt.Errorf("bogus STW end (offset %v, time %v)", ev.Off, ev.Ts)
 			}
 			(*evp).Link = ev
 			*evp = nil
 		case EvGCSweepStart:
 			if p.evSweep != nil {
-				return fmt.Errorf("previous sweeping is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
+				return nil, fmt.Errorf("previous sweeping is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
 			}
 			p.evSweep = ev
 		case EvGCMarkAssistStart:
 			if g.evMarkAssist != nil {
-				return fmt.Errorf("previous mark assist is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
+				return nil, fmt.Errorf("previous mark assist is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
 			}
 			g.evMarkAssist = ev
 		case EvGCMarkAssistDone:
@@ -639,36 +774,48 @@ func postProcessTrace(ver int, events []*Event) error {
 			}
 		case EvGCSweepDone:
 			if p.evSweep == nil {
-				return fmt.Errorf("bogus sweeping end (offset %v, time %v)", ev.Off, ev.Ts)
+				return nil, fmt.Errorf("bogus sweeping end (offset %v, time %v)", ev.Off, ev.Ts)
 			}
 			p.evSweep.Link = ev
 			p.evSweep = nil
 		case EvGoWaiting:
+			if g.state == gWaiting && ev.gen > 0 {
+				// The goroutine was blocked at the end of the previous generation.
+				continue
+			}
 			if g.state != gRunnable {
-				return fmt.Errorf("g %v is not runnable before EvGoWaiting (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("g %v is not runnable before EvGoWaiting (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
 			}
 			g.state = gWaiting
 			g.ev = ev
 		case EvGoInSyscall:
+			if g.state == gWaiting && ev.gen > 0 {
+				// The goroutine was in a syscall at the end of the previous generation.
+				continue
+			}
 			if g.state != gRunnable {
-				return fmt.Errorf("g %v is not runnable before EvGoInSyscall (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("g %v is not runnable before EvGoInSyscall (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
 			}
 			g.state = gWaiting
 			g.ev = ev
 		case EvGoCreate:
+			if g1, ok := gs[ev.Args[0]]; ok && g1.state != gDead && ev.gen > 0 {
+				// The goroutine existed at the end of the previous generation.
+				continue
+			}
 			if err := checkRunning(p, g, ev, true); err != nil {
-				return err
+				return nil, err
 			}
 			if _, ok := gs[ev.Args[0]]; ok {
-				return fmt.Errorf("g %v already exists (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
+				return nil, fmt.Errorf("g %v already exists (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
 			}
 			gs[ev.Args[0]] = gdesc{state: gRunnable, ev: ev, evCreate: ev}
 		case EvGoStart, EvGoStartLabel:
 			if g.state != gRunnable {
-				return fmt.Errorf("g %v is not runnable before start (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("g %v is not runnable before start (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
 			}
 			if p.g != 0 {
-				return fmt.Errorf("p %v is already running g %v while start g %v (offset %v, time %v)", ev.P, p.g, ev.G, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("p %v is already running g %v while start g %v (offset %v, time %v)", ev.P, p.g, ev.G, ev.Off, ev.Ts)
 			}
 			g.state = gRunning
 			g.evStart = ev
@@ -689,7 +836,7 @@ func postProcessTrace(ver int, events []*Event) error {
 			}
 		case EvGoEnd, EvGoStop:
 			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+				return nil, err
 			}
 			g.evStart.Link = ev
 			g.evStart = nil
@@ -697,7 +844,7 @@ func postProcessTrace(ver int, events []*Event) error {
 			p.g = 0
 		case EvGoSched, EvGoPreempt:
 			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+				return nil, err
 			}
 			g.state = gRunnable
 			g.evStart.Link = ev
@@ -706,14 +853,14 @@ func postProcessTrace(ver int, events []*Event) error {
 			g.ev = ev
 		case EvGoUnblock:
 			if g.state != gRunning {
-				return fmt.Errorf("g %v is not running while unpark (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("g %v is not running while unpark (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
 			}
 			if ev.P != TimerP && p.g != ev.G {
-				return fmt.Errorf("p %v is not running g %v while unpark (offset %v, time %v)", ev.P, ev.G, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("p %v is not running g %v while unpark (offset %v, time %v)", ev.P, ev.G, ev.Off, ev.Ts)
 			}
 			g1 := gs[ev.Args[0]]
 			if g1.state != gWaiting {
-				return fmt.Errorf("g %v is not waiting before unpark (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
+				return nil, fmt.Errorf("g %v is not waiting before unpark (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
 			}
 			if g1.ev != nil && g1.ev.Type == EvGoBlockNet && ev.P != TimerP {
 				ev.P = NetpollP
@@ -726,12 +873,12 @@ func postProcessTrace(ver int, events []*Event) error {
 			gs[ev.Args[0]] = g1
 		case EvGoSysCall:
 			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+				return nil, err
 			}
 			g.ev = ev
 		case EvGoSysBlock:
 			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+				return nil, err
 			}
 			g.state = gWaiting
 			g.evStart.Link = ev
@@ -739,7 +886,7 @@ func postProcessTrace(ver int, events []*Event) error {
 			p.g = 0
 		case EvGoSysExit:
 			if g.state != gWaiting {
-				return fmt.Errorf("g %v is not waiting during syscall exit (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+				return nil, fmt.Errorf("g %v is not waiting during syscall exit (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
 			}
 			if g.ev != nil && g.ev.Type == EvGoSysCall {
 				g.ev.Link = ev
@@ -749,23 +896,60 @@ func postProcessTrace(ver int, events []*Event) error {
 		case EvGoSleep, EvGoBlock, EvGoBlockSend, EvGoBlockRecv,
 			EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoBlockGC:
 			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+				return nil, err
 			}
 			g.state = gWaiting
 			g.ev = ev
 			g.evStart.Link = ev
 			g.evStart = nil
 			p.g = 0
+		case EvUserTaskCreate:
+			taskid := ev.Args[0]
+			if prevEv, ok := tasks[taskid]; ok {
+				return nil, fmt.Errorf("task id conflicts (id:%d), %q vs %q", taskid, ev, prevEv)
+			}
+			tasks[ev.Args[0]] = ev
+		case EvUserTaskEnd:
+			taskid := ev.Args[0]
+			if taskCreateEv, ok := tasks[taskid]; ok {
+				taskCreateEv.Link = ev
+				delete(tasks, taskid)
+			}
+		case EvUserRegion:
+			mode := ev.Args[1]
+			regions := activeRegions[ev.G]
+			if mode == 0 { // region start
+				activeRegions[ev.G] = append(regions, ev) // push
+			} else if mode == 1 { // region end
+				n := len(regions)
+				if n > 0 { // matching region start event is in the trace.
+					s := regions[n-1]
+					if s.Args[0] != ev.Args[0] || s.SArgs[0] != ev.SArgs[0] { // task id, region name mismatch
+						return nil, fmt.Errorf("misuse of region in goroutine %d: region end %q when the inner-most active region start event is %q", ev.G, ev, s)
+					}
+					// Link region start event with region end event
+					s.Link = ev
+
+					if n > 1 {
+						activeRegions[ev.G] = regions[:n-1]
+					} else {
+						delete(activeRegions, ev.G)
+					}
+				}
+			} else {
+				return nil, fmt.Errorf("invalid user region mode: %q", ev)
+			}
 		}
 
 		gs[ev.G] = g
 		ps[ev.P] = p
+		newEvents = append(newEvents, ev)
 	}
 
 	// TODO(dvyukov): restore stacks for EvGoStart events.
 	// TODO(dvyukov): test that all EvGoStart events has non-nil Link.
 
-	return nil
+	return newEvents, nil
 }
 
 // symbolize attaches func/file/line info to stack traces.
@@ -869,12 +1053,20 @@ func Print(events []*Event) {
 
 // PrintEvent dumps the event to stdout. For debugging.
 func PrintEvent(ev *Event) {
+	fmt.Printf("%s\n", ev)
+}
+
+func (ev *Event) String() string {
 	desc := EventDescriptions[ev.Type]
-	fmt.Printf("%v %v p=%v g=%v off=%v", ev.Ts, desc.Name, ev.P, ev.G, ev.Off)
+	w := new(bytes.Buffer)
+	fmt.Fprintf(w, "%v %v p=%v g=%v off=%v", ev.Ts, desc.Name, ev.P, ev.G, ev.Off)
 	for i, a := range desc.Args {
-		fmt.Printf(" %v=%v", a, ev.Args[i])
+		fmt.Fprintf(w, " %v=%v", a, ev.Args[i])
+	}
+	for _, s := range ev.SArgs {
+		fmt.Fprintf(w, " %q", s)
 	}
-	fmt.Printf("\n")
+	return w.String()
 }
 
 // argNum returns total number of args for the event accounting for timestamps,
@@ -967,7 +1159,11 @@ const (
 	EvGoBlockGC         = 42 // goroutine blocks on GC assist [timestamp, stack]
 	EvGCMarkAssistStart = 43 // GC mark assist start [timestamp, stack]
 	EvGCMarkAssistDone  = 44 // GC mark assist done [timestamp]
-	EvCount             = 45
+	EvUserTaskCreate    = 45 // trace.NewTask [timestamp, internal task id, internal parent id, stack, name string]
+	EvUserTaskEnd       = 46 // end of task [timestamp, internal task id, stack]
+	EvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
+	EvUserLog           = 48 // trace.Log [timestamp, internal id, key string id, stack, value string]
+	EvCount             = 49
 )
 
 var EventDescriptions = [EvCount]struct {
@@ -1021,4 +1217,8 @@ var EventDescriptions = [EvCount]struct {
 	EvGoBlockGC:         {"GoBlockGC", 1008, true, []string{}},
 	EvGCMarkAssistStart: {"GCMarkAssistStart", 1009, true, []string{}},
 	EvGCMarkAssistDone:  {"GCMarkAssistDone", 1009, false, []string{}},
+	EvUserTaskCreate:    {"UserTaskCreate", 1011, true, []string{"taskid", "pid", "typeid"}},
+	EvUserTaskEnd:       {"UserTaskEnd", 1011, true, []string{"taskid"}},
+	EvUserRegion:        {"UserRegion", 1011, true, []string{"taskid", "mode", "typeid"}},
+	EvUserLog:           {"UserLog", 1011, true, []string{"id", "keyid"}},
 }
@@ -100,3 +100,44 @@ func TestTimestampOverflow(t *testing.T) {
 		t.Fatalf("failed to parse: %v", err)
 	}
 }
+
+func TestParseGenerations(t *testing.T) {
+	// A goroutine blocks in the first generation and is unblocked in
+	// the second one, which starts by restating its state.
+	w := new(Writer)
+	w.Write([]byte("go 1.11 trace\x00\x00\x00"))
+	w.Emit(EvBatch, 0, 0)
+	w.Emit(EvFrequency, 1e9)
+	w.Emit(EvGoCreate, 1, 1, 0, 0)
+	w.Emit(EvGoStart, 1, 1, 1)
+	w.Emit(EvGoBlockRecv, 1, 0)
+	w.Write([]byte("go 1.11 trace\x00\x00\x00"))
+	w.Emit(EvBatch, 0, 10)
+	w.Emit(EvFrequency, 1e9)
+	w.Emit(EvGoCreate, 1, 1, 0, 0)
+	w.Emit(EvGoWaiting, 1, 1)
+	w.Emit(EvGoUnblock, 1, 1, 2, 0)
+	w.Emit(EvGoStart, 1, 1, 3)
+	w.Emit(EvGoEnd, 1)
+
+	res, err := Parse(w, "")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	want := []byte{EvGoCreate, EvGoStart, EvGoBlockRecv, EvGoUnblock, EvGoStart, EvGoEnd}
+	if len(res.Events) != len(want) {
+		Print(res.Events)
+		t.Fatalf("got %d events, want %d", len(res.Events), len(want))
+	}
+	for i, ev := range res.Events {
+		if ev.Type != want[i] {
+			t.Errorf("event %d is %v, want %v", i, EventDescriptions[ev.Type].Name, EventDescriptions[want[i]].Name)
+		}
+		if i > 0 && ev.Ts < res.Events[i-1].Ts {
+			t.Errorf("event %d at %v is before the previous event at %v", i, ev.Ts, res.Events[i-1].Ts)
+		}
+	}
+	if block := res.Events[2]; block.Link != res.Events[3] {
+		t.Errorf("GoBlockRecv is linked to %v, want the GoUnblock of the next generation", block.Link)
+	}
+}
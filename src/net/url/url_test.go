@@ -9,6 +9,7 @@ import (
 	encodingPkg "encoding"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -1615,6 +1616,18 @@ func TestURLErrorImplementsNetError(t *testing.T) {
 	}
 }
 
+func TestURLErrorUnwrap(t *testing.T) {
+	for i, tt := range netErrorTests {
+		inner := tt.err.(*Error).Err
+		if got := errors.Unwrap(tt.err); got != inner {
+			t.Errorf("%d: errors.Unwrap(%v) = %v, want %v", i+1, tt.err, got, inner)
+		}
+	}
+	if err := (&Error{"Get", "http://google.com/", io.EOF}); !errors.Is(err, io.EOF) {
+		t.Errorf("errors.Is(%v, io.EOF) = false, want true", err)
+	}
+}
+
 func TestURLHostname(t *testing.T) {
 	tests := []struct {
 		host string // URL.Host field
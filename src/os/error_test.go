@@ -5,6 +5,7 @@
 package os_test
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -30,6 +31,9 @@ func TestErrIsExist(t *testing.T) {
 		t.Fatal(s)
 		return
 	}
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatalf("errors.Is(%#v, os.ErrExist) = false, want true", err)
+	}
 }
 
 func testErrNotExist(name string) string {
@@ -41,6 +45,12 @@ func testErrNotExist(name string) string {
 	if s := checkErrorPredicate("os.IsNotExist", os.IsNotExist, err); s != "" {
 		return s
 	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Sprintf("errors.Is(%#v, os.ErrNotExist) = false, want true", err)
+	}
+	if wrapped := fmt.Errorf("open config: %w", err); !errors.Is(wrapped, os.ErrNotExist) {
+		return fmt.Sprintf("errors.Is(%#v, os.ErrNotExist) = false, want true", wrapped)
+	}
 
 	err = os.Chdir(name)
 	if err == nil {
@@ -110,6 +120,12 @@ func TestIsExist(t *testing.T) {
 		if isnot := os.IsNotExist(tt.err); isnot != tt.isnot {
 			t.Errorf("os.IsNotExist(%T %v) = %v, want %v", tt.err, tt.err, isnot, tt.isnot)
 		}
+		if is := errors.Is(tt.err, os.ErrExist); is != tt.is {
+			t.Errorf("errors.Is(%T %v, os.ErrExist) = %v, want %v", tt.err, tt.err, is, tt.is)
+		}
+		if isnot := errors.Is(tt.err, os.ErrNotExist); isnot != tt.isnot {
+			t.Errorf("errors.Is(%T %v, os.ErrNotExist) = %v, want %v", tt.err, tt.err, isnot, tt.isnot)
+		}
 	}
 }
 
@@ -129,6 +145,9 @@ func TestIsPermission(t *testing.T) {
 		if got := os.IsPermission(tt.err); got != tt.want {
 			t.Errorf("os.IsPermission(%#v) = %v; want %v", tt.err, got, tt.want)
 		}
+		if got := errors.Is(tt.err, os.ErrPermission); got != tt.want {
+			t.Errorf("errors.Is(%#v, os.ErrPermission) = %v; want %v", tt.err, got, tt.want)
+		}
 	}
 }
 
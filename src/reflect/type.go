@@ -2165,7 +2165,8 @@ const (
 )
 
 func bucketOf(ktyp, etyp *rtype) *rtype {
-	// See comment on hmap.overflow in ../runtime/hashmap.go.
+	// If both key and value have no pointers and are inline,
+	// the bucket has no pointers either. See bmap in ../runtime/hashmap.go.
 	var kind uint8
 	if ktyp.kind&kindNoPointers != 0 && etyp.kind&kindNoPointers != 0 &&
 		ktyp.size <= maxKeySize && etyp.size <= maxValSize {
@@ -2180,28 +2181,33 @@ func bucketOf(ktyp, etyp *rtype) *rtype {
 	}
 
 	// Prepare GC data if any.
-	// A bucket is at most bucketSize*(1+maxKeySize+maxValSize)+2*ptrSize bytes,
-	// or 2072 bytes, or 259 pointer-size words, or 33 bytes of pointer bitmap.
+	// A bucket is at most bucketSize*(1+maxKeySize+maxValSize) bytes,
+	// or 2056 bytes, or 257 pointer-size words, or 33 bytes of pointer bitmap.
 	// Note that since the key and value are known to be <= 128 bytes,
 	// they're guaranteed to have bitmaps instead of GC programs.
 	var gcdata *byte
 	var ptrdata uintptr
-	var overflowPad uintptr
 
-	// On NaCl, pad if needed to make overflow end at the proper struct alignment.
-	// On other systems, align > ptrSize is not possible.
-	if runtime.GOARCH == "amd64p32" && (ktyp.align > ptrSize || etyp.align > ptrSize) {
-		overflowPad = ptrSize
-	}
-	size := bucketSize*(1+ktyp.size+etyp.size) + overflowPad + ptrSize
+	// The bucket starts with a uint64 of control bytes, one per slot,
+	// followed by the keys and the values. Key and value sizes are
+	// multiples of their alignment, so they need no padding.
+	size := bucketSize * (1 + ktyp.size + etyp.size)
 	if size&uintptr(ktyp.align-1) != 0 || size&uintptr(etyp.align-1) != 0 {
 		panic("reflect: bad size computation in MapOf")
 	}
+	align := uint8(unsafe.Alignof(uint64(0)))
+	if ktyp.align > align {
+		align = ktyp.align
+	}
+	if etyp.align > align {
+		align = etyp.align
+	}
 
 	if kind != kindNoPointers {
-		nptr := (bucketSize*(1+ktyp.size+etyp.size) + ptrSize) / ptrSize
+		nptr := size / ptrSize
 		mask := make([]byte, (nptr+7)/8)
 		base := bucketSize / ptrSize
+		last := uintptr(0)
 
 		if ktyp.kind&kindNoPointers == 0 {
 			if ktyp.kind&kindGCProg != 0 {
@@ -2213,6 +2219,7 @@ func bucketOf(ktyp, etyp *rtype) *rtype {
 					for j := uintptr(0); j < bucketSize; j++ {
 						word := base + j*ktyp.size/ptrSize + i
 						mask[word/8] |= 1 << (word % 8)
+						last = word
 					}
 				}
 			}
@@ -2229,34 +2236,33 @@ func bucketOf(ktyp, etyp *rtype) *rtype {
 					for j := uintptr(0); j < bucketSize; j++ {
 						word := base + j*etyp.size/ptrSize + i
 						mask[word/8] |= 1 << (word % 8)
+						last = word
 					}
 				}
 			}
 		}
 		base += bucketSize * etyp.size / ptrSize
-		base += overflowPad / ptrSize
-
-		word := base
-		mask[word/8] |= 1 << (word % 8)
-		gcdata = &mask[0]
-		ptrdata = (word + 1) * ptrSize
 
-		// overflow word must be last
-		if ptrdata != size {
+		if base*ptrSize != size || last == 0 {
 			panic("reflect: bad layout computation in MapOf")
 		}
+		gcdata = &mask[0]
+		ptrdata = (last + 1) * ptrSize
+	}
+
+	// Like the compiler, pad a bucket that ends with zero-sized values,
+	// so that the address of the values cannot point past its end.
+	if etyp.size == 0 {
+		size += uintptr(align)
 	}
 
 	b := &rtype{
-		align:   ptrSize,
+		align:   align,
 		size:    size,
 		kind:    kind,
 		ptrdata: ptrdata,
 		gcdata:  gcdata,
 	}
-	if overflowPad > 0 {
-		b.align = 8
-	}
 	s := "bucket(" + ktyp.String() + "," + etyp.String() + ")"
 	b.str = resolveReflectName(newName(s, "", false))
 	return b
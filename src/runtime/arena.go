@@ -0,0 +1,288 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// User arenas.
+//
+// A user arena, exposed by package arena when the toolchain is built
+// with GOEXPERIMENT=arenas, allocates objects from chunks of heap memory
+// that are freed together, explicitly, instead of one by one by the
+// garbage collector.
+//
+// Each chunk is a large object span of the heap. The arena keeps its
+// chunks alive until it is freed, and afterwards any pointer into a
+// chunk keeps it alive, so the GC marks and scans a chunk as a whole.
+// The heap bitmap of a chunk is maintained by the arena rather than by
+// heapBitsSetType: the scan bits of every word are set when the chunk
+// is allocated, so that a scan of the chunk never stops early, and the
+// pointer bits of an object are set when the object is allocated. The
+// chunk is zeroed, so the words not allocated yet look like nil
+// pointers or scalars to the GC.
+//
+// Freeing an arena faults the pages of its chunks, so that any later
+// use of the memory crashes instead of silently reading or corrupting
+// another object, and makes the chunks noscan so that the GC does not
+// read them again. A GC worker may be scanning a chunk while the GC is
+// marking, so chunks freed during a GC cycle are quarantined and only
+// faulted once marking is done, in gcMarkTermination. A faulted chunk
+// stays in the heap until the sweeper finds it unmarked, that is until
+// no pointers into it remain, and its memory is then mapped back and
+// returned to the heap.
+//
+// Types whose pointer layout is described by a GC program, that is very
+// large arrays, are not allocated in arenas but in the heap.
+
+package runtime
+
+import (
+	"runtime/internal/atomic"
+	"runtime/internal/sys"
+	"unsafe"
+)
+
+// userArenaEnabled reports whether the toolchain was built with
+// GOEXPERIMENT=arenas.
+var userArenaEnabled = haveexperiment("arenas")
+
+// userArenaChunkBytes is the size of the chunks of user arenas.
+// Larger allocations get a chunk of their own.
+const userArenaChunkBytes = 64 << 10
+
+// Values of mspan.userArena.
+const (
+	userArenaChunkNone        = iota // not a user arena chunk
+	userArenaChunkLive               // chunk of a live arena
+	userArenaChunkQuarantined        // freed during a GC cycle, not faulted yet
+	userArenaChunkFaulted            // freed and faulted
+)
+
+// A userArena is the runtime side of an arena.Arena.
+// It is not safe for concurrent use.
+type userArena struct {
+	// chunks are the base addresses of the chunks of the arena.
+	// They keep the chunks alive until the arena is freed.
+	chunks []unsafe.Pointer
+
+	// [cur, end) is the free space of the current chunk.
+	cur, end uintptr
+
+	freed bool
+}
+
+// alloc allocates n zeroed values of type typ in the arena.
+func (a *userArena) alloc(typ *_type, n int) unsafe.Pointer {
+	if a.freed {
+		panic(plainError("arena: use of a freed arena"))
+	}
+	if n < 0 || uintptr(n) > maxSliceCap(typ.size) {
+		panic(plainError("arena: allocation size out of range"))
+	}
+	size := typ.size * uintptr(n)
+	if size == 0 {
+		return unsafe.Pointer(&zerobase)
+	}
+	if typ.kind&kindGCProg != 0 {
+		return newarray(typ, n)
+	}
+	p := round(a.cur, uintptr(typ.align))
+	if p+size > a.end {
+		a.refill(size)
+		p = a.cur
+	}
+	a.cur = p + size
+	if typ.kind&kindNoPointers == 0 {
+		userArenaHeapBitsSetType(p, typ, n)
+	}
+	return unsafe.Pointer(p)
+}
+
+// refill starts a new chunk with room for at least size bytes.
+func (a *userArena) refill(size uintptr) {
+	n := uintptr(userArenaChunkBytes)
+	if size > n {
+		n = round(size, _PageSize)
+	}
+	x, s := newUserArenaChunk(n)
+	a.chunks = append(a.chunks, x)
+	a.cur, a.end = s.base(), s.base()+s.elemsize
+}
+
+// free frees the chunks of the arena.
+func (a *userArena) free() {
+	for _, x := range a.chunks {
+		freeUserArenaChunk(spanOf(uintptr(x)))
+	}
+	a.chunks = nil
+	a.cur, a.end = 0, 0
+	a.freed = true
+}
+
+// newUserArenaChunk allocates a zeroed chunk of size bytes, a multiple
+// of the page size, the way mallocgc allocates a large object with
+// pointers, and sets the scan bits of all its words.
+func newUserArenaChunk(size uintptr) (unsafe.Pointer, *mspan) {
+	// Charge the allocation to the GC assist, like mallocgc.
+	if gcBlackenEnabled != 0 {
+		assistG := getg()
+		if assistG.m.curg != nil {
+			assistG = assistG.m.curg
+		}
+		assistG.gcAssistBytes -= int64(size)
+		if assistG.gcAssistBytes < 0 {
+			gcAssistAlloc(assistG)
+		}
+	}
+
+	mp := acquirem()
+	if mp.mallocing != 0 {
+		throw("malloc deadlock")
+	}
+	mp.mallocing = 1
+
+	var s *mspan
+	systemstack(func() {
+		s = largeAlloc(size, true, false)
+	})
+	s.freeindex = 1
+	s.allocCount = 1
+	s.userArena = userArenaChunkLive
+	x := unsafe.Pointer(s.base())
+
+	// The chunk is page-aligned, so its bitmap is whole bytes.
+	nb := s.elemsize / heapBitmapScale
+	bitp := heapBitsForAddr(s.base()).bitp
+	for i := uintptr(0); i < nb; i++ {
+		*subtractb(bitp, i) = bitScanAll
+	}
+	gomcache().local_scan += s.elemsize
+
+	publicationBarrier()
+
+	if gcphase != _GCoff {
+		gcmarknewobject(uintptr(x), s.elemsize, s.elemsize)
+	}
+
+	mp.mallocing = 0
+	releasem(mp)
+
+	if t := (gcTrigger{kind: gcTriggerHeap}); t.test() {
+		gcStart(gcBackgroundMode, t)
+	}
+	return x, s
+}
+
+// userArenaHeapBitsSetType sets the pointer bits of n values of type typ
+// at p in a user arena chunk, whose scan bits are already set. The GC
+// may be reading the bitmap of the chunk, and other objects may share
+// the bitmap bytes, so the bits are set atomically.
+func userArenaHeapBitsSetType(p uintptr, typ *_type, n int) {
+	nw := typ.ptrdata / sys.PtrSize
+	for i := 0; i < n; i++ {
+		h := heapBitsForAddr(p + uintptr(i)*typ.size)
+		for j := uintptr(0); j < nw; j++ {
+			if *addb(typ.gcdata, j/8)>>(j%8)&1 != 0 {
+				atomic.Or8(h.bitp, bitPointer<<h.shift)
+			}
+			h = h.next()
+		}
+	}
+}
+
+// freeUserArenaChunk faults the chunk s of a freed arena, or quarantines
+// it until the end of the mark phase if the GC is marking.
+func freeUserArenaChunk(s *mspan) {
+	// Disabling preemption keeps the GC from starting while the
+	// chunk is faulted.
+	mp := acquirem()
+	if gcphase == _GCoff {
+		s.setUserArenaChunkToFault()
+	} else {
+		lock(&mheap_.userArena.lock)
+		s.userArena = userArenaChunkQuarantined
+		s.userArenaNext = mheap_.userArena.quarantine
+		mheap_.userArena.quarantine = s
+		unlock(&mheap_.userArena.lock)
+	}
+	releasem(mp)
+}
+
+// setUserArenaChunkToFault makes the chunk s of a freed arena noscan,
+// since the GC must not read it anymore, and faults its memory.
+// The GC must not be marking.
+func (s *mspan) setUserArenaChunkToFault() {
+	s.spanclass = makeSpanClass(0, true)
+	s.userArena = userArenaChunkFaulted
+	sysFault(unsafe.Pointer(s.base()), s.npages*_PageSize)
+}
+
+// userArenaFaultQuarantined faults the chunks freed during the GC cycle
+// whose mark phase just ended. The world must be stopped.
+func userArenaFaultQuarantined() {
+	lock(&mheap_.userArena.lock)
+	for s := mheap_.userArena.quarantine; s != nil; {
+		next := s.userArenaNext
+		s.userArenaNext = nil
+		s.setUserArenaChunkToFault()
+		s = next
+	}
+	mheap_.userArena.quarantine = nil
+	unlock(&mheap_.userArena.lock)
+}
+
+// sweepUserArenaChunk prepares the unmarked chunk s for being returned
+// to the heap, mapping its memory back if it was faulted.
+func sweepUserArenaChunk(s *mspan) {
+	if s.userArena == userArenaChunkFaulted {
+		sysUnfault(unsafe.Pointer(s.base()), s.npages*_PageSize)
+	}
+	s.userArena = userArenaChunkNone
+}
+
+// userArenaFault throws if the fault address addr is in a freed arena.
+func userArenaFault(addr uintptr) {
+	if s := spanOf(addr); s != nil && s.state == mSpanInUse && s.userArena == userArenaChunkFaulted {
+		print("runtime: fault address ", hex(addr), " is in a freed arena\n")
+		throw("use of freed arena memory")
+	}
+}
+
+//go:linkname arena_runtime_newArena arena.runtime_newArena
+func arena_runtime_newArena() unsafe.Pointer {
+	if !userArenaEnabled {
+		panic(plainError("arena: the toolchain was not built with GOEXPERIMENT=arenas"))
+	}
+	return unsafe.Pointer(new(userArena))
+}
+
+//go:linkname arena_runtime_freeArena arena.runtime_freeArena
+func arena_runtime_freeArena(a unsafe.Pointer) {
+	(*userArena)(a).free()
+}
+
+//go:linkname arena_runtime_new arena.runtime_new
+func arena_runtime_new(a unsafe.Pointer, ptr interface{}) {
+	e := efaceOf(&ptr)
+	t := e._type
+	if t == nil || t.kind&kindMask != kindPtr || e.data == nil ||
+		(*ptrtype)(unsafe.Pointer(t)).elem.kind&kindMask != kindPtr {
+		panic(plainError("arena: New of a value that is not a non-nil pointer to a pointer"))
+	}
+	typ := (*ptrtype)(unsafe.Pointer((*ptrtype)(unsafe.Pointer(t)).elem)).elem
+	*(*unsafe.Pointer)(e.data) = (*userArena)(a).alloc(typ, 1)
+}
+
+//go:linkname arena_runtime_makeSlice arena.runtime_makeSlice
+func arena_runtime_makeSlice(a unsafe.Pointer, ptr interface{}, len, cap int) {
+	e := efaceOf(&ptr)
+	t := e._type
+	if t == nil || t.kind&kindMask != kindPtr || e.data == nil ||
+		(*ptrtype)(unsafe.Pointer(t)).elem.kind&kindMask != kindSlice {
+		panic(plainError("arena: MakeSlice of a value that is not a non-nil pointer to a slice"))
+	}
+	if len < 0 || len > cap {
+		panic(plainError("arena: MakeSlice: len out of range"))
+	}
+	typ := (*slicetype)(unsafe.Pointer((*ptrtype)(unsafe.Pointer(t)).elem)).elem
+	p := (*userArena)(a).alloc(typ, cap)
+	*(*slice)(e.data) = slice{p, len, cap}
+}
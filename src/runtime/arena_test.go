@@ -0,0 +1,183 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	. "runtime"
+	"runtime/debug"
+	"testing"
+)
+
+type arenaNode struct {
+	n    int
+	next *arenaNode
+	val  *int // in the heap
+	pad  [5]uintptr
+	s    []byte
+}
+
+var arenaSink *int
+
+// buildArenaList allocates a list of n nodes in a, whose values point
+// to the heap only from the arena.
+func buildArenaList(a *UserArena, n int) *arenaNode {
+	var head *arenaNode
+	for i := 0; i < n; i++ {
+		var x *arenaNode
+		a.New(&x)
+		x.n = i
+		x.val = new(int)
+		*x.val = i
+		a.MakeSlice(&x.s, 1, 8)
+		x.s[0] = byte(i)
+		x.next = head
+		head = x
+	}
+	return head
+}
+
+func checkArenaList(t *testing.T, head *arenaNode, n int) {
+	i := n - 1
+	for x := head; x != nil; x = x.next {
+		if x.n != i || *x.val != i || len(x.s) != 1 || cap(x.s) != 8 || x.s[0] != byte(i) {
+			t.Fatalf("node %d = {%d, %d, %v}", i, x.n, *x.val, x.s)
+		}
+		i--
+	}
+	if i != -1 {
+		t.Fatalf("list has %d nodes, want %d", n-1-i, n)
+	}
+}
+
+// reuseFreedHeap allocates garbage over any heap memory wrongly freed.
+func reuseFreedHeap() {
+	for i := 0; i < 100000; i++ {
+		p := new(int)
+		*p = -1
+		arenaSink = p
+	}
+}
+
+func TestUserArena(t *testing.T) {
+	a := NewUserArena()
+	defer a.Free()
+
+	const n = 10000
+	head := buildArenaList(a, n)
+	if a.Chunks() < 2 {
+		t.Errorf("got %d chunks for %d nodes, want several", a.Chunks(), n)
+	}
+
+	// A slice larger than a chunk gets a chunk of its own.
+	var big []*int
+	a.MakeSlice(&big, UserArenaChunkBytes, UserArenaChunkBytes)
+	big[len(big)-1] = new(int)
+	*big[len(big)-1] = 42
+
+	// A type with a GC program is allocated in the heap.
+	var huge *[1 << 15]*int
+	a.New(&huge)
+	huge[len(huge)-1] = new(int)
+	*huge[len(huge)-1] = 43
+
+	GC()
+	reuseFreedHeap()
+	GC()
+	checkArenaList(t, head, n)
+	if *big[len(big)-1] != 42 || *huge[len(huge)-1] != 43 {
+		t.Errorf("values pointed to by large allocations were freed")
+	}
+}
+
+func TestUserArenaUseAfterFree(t *testing.T) {
+	// Finish any GC cycle and keep others from starting, so that the
+	// arena is faulted when freed rather than quarantined.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	GC()
+
+	a := NewUserArena()
+	var x *arenaNode
+	a.New(&x)
+	x.n = 1
+	a.Free()
+
+	func() {
+		defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+		defer func() {
+			if recover() == nil {
+				t.Error("use of freed arena memory did not fault")
+			}
+		}()
+		arenaSink = &x.n
+		if *arenaSink == 1 {
+			t.Error("read freed arena memory")
+		}
+	}()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("allocation in freed arena did not panic")
+			}
+		}()
+		a.New(&x)
+	}()
+
+	// The freed chunk goes back to the heap once no pointers into it
+	// remain.
+	x, arenaSink = nil, nil
+	GC()
+	GC()
+	reuseFreedHeap()
+}
+
+func TestUserArenaFreeDuringGC(t *testing.T) {
+	n := 20
+	if testing.Short() {
+		n = 5
+	}
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			GC()
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		a := NewUserArena()
+		head := buildArenaList(a, 1000)
+		checkArenaList(t, head, 1000)
+		a.Free()
+	}
+}
+
+func TestUserArenaBadArgs(t *testing.T) {
+	a := NewUserArena()
+	defer a.Free()
+	var x int
+	var s []int
+	for _, f := range []func(){
+		func() { a.New(nil) },
+		func() { a.New(&x) },
+		func() { a.New((**int)(nil)) },
+		func() { a.MakeSlice(&x, 0, 0) },
+		func() { a.MakeSlice(&s, 2, 1) },
+		func() { a.MakeSlice(&s, -1, 1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic for bad arguments")
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import "unsafe"
+
+// CPU bandwidth limits from Linux control groups (cgroups).
+//
+// The runtime uses the CPU limit of the process's cgroup, if any, to
+// pick the default GOMAXPROCS (see defaultGOMAXPROCS). Both the v1
+// "cpu" controller (cpu.cfs_quota_us and cpu.cfs_period_us) and the
+// v2 unified hierarchy (cpu.max) are supported. The effective limit
+// of a cgroup is the smallest limit of the cgroup and its ancestors
+// that are visible in this mount namespace.
+//
+// cgroupInit locates the cgroup directory once, during schedinit.
+// cgroupCPULimit re-reads the limit files and is called by sysmon,
+// which runs without a P, so none of this code may allocate and all
+// state lives in fixed-size buffers.
+
+const (
+	cgroupNone = iota
+	cgroupV1
+	cgroupV2
+)
+
+const (
+	// cgroupPathMax is the longest cgroup directory the runtime
+	// will use. Processes in deeper cgroups don't get a cgroup
+	// CPU limit.
+	cgroupPathMax = 1024
+
+	// cgroupLineMax is the size of the line buffer used to read
+	// /proc/self/cgroup and /proc/self/mountinfo. Longer lines
+	// are skipped.
+	cgroupLineMax = 4096
+)
+
+var cgroup struct {
+	version int
+
+	// dir is the cgroup directory of this process: the mount
+	// point of the hierarchy followed by the cgroup path. The
+	// hierarchy above the mount point is not visible, so the walk
+	// up to the ancestors stops at mountLen.
+	dir      [cgroupPathMax]byte
+	dirLen   int
+	mountLen int
+
+	// rel is the cgroup path from /proc/self/cgroup.
+	rel    [cgroupPathMax]byte
+	relLen int
+
+	// Scratch space for file names, file contents and lines.
+	path [cgroupPathMax + 32]byte
+	data [64]byte
+	line [cgroupLineMax]byte
+}
+
+var (
+	procSelfCgroup    = []byte("/proc/self/cgroup\x00")
+	procSelfMountinfo = []byte("/proc/self/mountinfo\x00")
+)
+
+// cgroupInit finds the cgroup directory that holds the CPU limit
+// files of this process. If there is none, cgroup.version stays
+// cgroupNone.
+func cgroupInit() {
+	version := cgroupFindPath()
+	if version == cgroupNone {
+		return
+	}
+	rel := slicebytetostringtmp(cgroup.rel[:cgroup.relLen])
+	r := cgroupLineReader{fd: open(&procSelfMountinfo[0], 0 /* O_RDONLY */, 0)}
+	if r.fd < 0 {
+		return
+	}
+	for {
+		line, ok := r.next()
+		if !ok {
+			break
+		}
+		n, mountLen, ok := cgroupMountDir(line, rel, version, cgroup.dir[:])
+		if ok {
+			cgroup.dirLen = n
+			cgroup.mountLen = mountLen
+			cgroup.version = version
+			break
+		}
+	}
+	closefd(r.fd)
+}
+
+// cgroupFindPath reads /proc/self/cgroup, stores the path of the
+// cgroup that controls the CPU in cgroup.rel and returns its version.
+// The v1 cpu controller takes precedence over the v2 hierarchy, since
+// on hybrid systems the controller can only be attached to one of them.
+func cgroupFindPath() int {
+	r := cgroupLineReader{fd: open(&procSelfCgroup[0], 0 /* O_RDONLY */, 0)}
+	if r.fd < 0 {
+		return cgroupNone
+	}
+	version := cgroupNone
+	for {
+		line, ok := r.next()
+		if !ok {
+			break
+		}
+		// hierarchy-ID:controller-list:cgroup-path
+		i := index(line, ":")
+		if i < 0 {
+			continue
+		}
+		j := index(line[i+1:], ":")
+		if j < 0 {
+			continue
+		}
+		hier, controllers, path := line[:i], line[i+1:i+1+j], line[i+2+j:]
+		v := cgroupNone
+		if cgroupHasOption(controllers, "cpu") {
+			v = cgroupV1
+		} else if hier == "0" && controllers == "" && version == cgroupNone {
+			v = cgroupV2
+		}
+		if v == cgroupNone || len(path) > len(cgroup.rel) {
+			continue
+		}
+		cgroup.relLen = copy(cgroup.rel[:], path)
+		version = v
+		if v == cgroupV1 {
+			break
+		}
+	}
+	closefd(r.fd)
+	return version
+}
+
+// cgroupMountDir checks whether the /proc/self/mountinfo line
+// describes the mount of the given cgroup hierarchy version that
+// contains the cgroup path rel. If so, it writes the cgroup's
+// directory to dst and returns its length and the length of the mount
+// point prefix.
+func cgroupMountDir(line, rel string, version int, dst []byte) (n, mountLen int, ok bool) {
+	// 36 35 98:0 /root /mnt rw,noatime shared:1 - cgroup cgroup rw,cpu,cpuacct
+	//
+	// Field 3 is the root of the mount within the hierarchy and
+	// field 4 is the mount point. The optional fields end with
+	// "-", which is followed by the file system type, the mount
+	// source and the super block options.
+	root := cgroupField(line, 3)
+	mnt := cgroupField(line, 4)
+	sep := -1
+	for i := 6; ; i++ {
+		f := cgroupField(line, i)
+		if f == "" {
+			return 0, 0, false
+		}
+		if f == "-" {
+			sep = i
+			break
+		}
+	}
+	switch fstype := cgroupField(line, sep+1); version {
+	case cgroupV1:
+		if fstype != "cgroup" || !cgroupHasOption(cgroupField(line, sep+3), "cpu") {
+			return 0, 0, false
+		}
+	case cgroupV2:
+		if fstype != "cgroup2" {
+			return 0, 0, false
+		}
+	default:
+		return 0, 0, false
+	}
+
+	// The cgroup must be inside the mounted part of the hierarchy.
+	if root == "/" {
+		root = ""
+	}
+	if !hasprefix(rel, root) {
+		return 0, 0, false
+	}
+	suffix := rel[len(root):]
+	if suffix != "" && suffix[0] != '/' {
+		return 0, 0, false
+	}
+	for len(suffix) > 0 && suffix[len(suffix)-1] == '/' {
+		suffix = suffix[:len(suffix)-1]
+	}
+	if mnt == "" || len(mnt)+len(suffix) > len(dst) {
+		return 0, 0, false
+	}
+	mountLen = copy(dst, mnt)
+	n = mountLen + copy(dst[mountLen:], suffix)
+	return n, mountLen, true
+}
+
+// cgroupCPULimit returns the CPU limit of this process's cgroup, in
+// CPUs. It returns false if there is no limit.
+func cgroupCPULimit() (float64, bool) {
+	if cgroup.version == cgroupNone {
+		return 0, false
+	}
+	limit, found := 0.0, false
+	n := cgroup.dirLen
+	for {
+		if l, ok := cgroupReadLimit(cgroup.dir[:n]); ok && (!found || l < limit) {
+			limit, found = l, true
+		}
+		if n <= cgroup.mountLen {
+			break
+		}
+		// Move up to the parent cgroup.
+		for n > cgroup.mountLen && cgroup.dir[n-1] != '/' {
+			n--
+		}
+		if n > cgroup.mountLen {
+			n-- // drop the '/'
+		}
+	}
+	return limit, found
+}
+
+// cgroupReadLimit reads the CPU limit of the cgroup in directory dir.
+func cgroupReadLimit(dir []byte) (float64, bool) {
+	if cgroup.version == cgroupV2 {
+		b, ok := cgroupReadFile(dir, "/cpu.max")
+		if !ok {
+			return 0, false
+		}
+		return parseCPUMax(b)
+	}
+	quota, ok := cgroupReadFile(dir, "/cpu.cfs_quota_us")
+	if !ok {
+		return 0, false
+	}
+	q, ok := atoi(quota)
+	if !ok || q <= 0 {
+		// -1 means no limit.
+		return 0, false
+	}
+	period, ok := cgroupReadFile(dir, "/cpu.cfs_period_us")
+	if !ok {
+		return 0, false
+	}
+	p, ok := atoi(period)
+	if !ok || p <= 0 {
+		return 0, false
+	}
+	return float64(q) / float64(p), true
+}
+
+// parseCPUMax parses the contents of a cgroup v2 cpu.max file,
+// "$MAX $PERIOD", and returns the limit in CPUs. $MAX is "max" if
+// there is no limit.
+func parseCPUMax(s string) (float64, bool) {
+	i := index(s, " ")
+	if i < 0 {
+		return 0, false
+	}
+	q, ok := atoi(s[:i])
+	if !ok || q <= 0 {
+		return 0, false
+	}
+	p, ok := atoi(s[i+1:])
+	if !ok || p <= 0 {
+		return 0, false
+	}
+	return float64(q) / float64(p), true
+}
+
+// cgroupReadFile reads the file dir+name into cgroup.data and returns
+// its first line. The result is only valid until the next call.
+func cgroupReadFile(dir []byte, name string) (string, bool) {
+	if len(dir)+len(name)+1 > len(cgroup.path) {
+		return "", false
+	}
+	n := copy(cgroup.path[:], dir)
+	n += copy(cgroup.path[n:], name)
+	cgroup.path[n] = 0
+	fd := open(&cgroup.path[0], 0 /* O_RDONLY */, 0)
+	if fd < 0 {
+		return "", false
+	}
+	m := read(fd, unsafe.Pointer(&cgroup.data[0]), int32(len(cgroup.data)))
+	closefd(fd)
+	if m <= 0 {
+		return "", false
+	}
+	s := slicebytetostringtmp(cgroup.data[:m])
+	if i := index(s, "\n"); i >= 0 {
+		s = s[:i]
+	}
+	return s, true
+}
+
+// cgroupField returns the i'th space-separated field of line, or ""
+// if there are not enough fields.
+func cgroupField(line string, i int) string {
+	for ; i > 0; i-- {
+		j := index(line, " ")
+		if j < 0 {
+			return ""
+		}
+		line = line[j+1:]
+	}
+	if j := index(line, " "); j >= 0 {
+		line = line[:j]
+	}
+	return line
+}
+
+// cgroupHasOption reports whether the comma-separated list opts
+// contains opt.
+func cgroupHasOption(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		if i := index(opts, ","); i >= 0 {
+			o, opts = opts[:i], opts[i+1:]
+		} else {
+			o, opts = opts, ""
+		}
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// cgroupLineReader reads a file line by line using cgroup.line as
+// its buffer. Lines that don't fit in the buffer are skipped.
+type cgroupLineReader struct {
+	fd         int32
+	start, end int
+	eof        bool
+	skip       bool // discarding the rest of a long line
+}
+
+// next returns the next line of the file, without the newline. The
+// line is only valid until the next call.
+func (r *cgroupLineReader) next() (string, bool) {
+	buf := cgroup.line[:]
+	for {
+		if i := index(slicebytetostringtmp(buf[r.start:r.end]), "\n"); i >= 0 {
+			line := slicebytetostringtmp(buf[r.start : r.start+i])
+			r.start += i + 1
+			if r.skip {
+				r.skip = false
+				continue
+			}
+			return line, true
+		}
+		if r.eof {
+			if r.start < r.end && !r.skip {
+				line := slicebytetostringtmp(buf[r.start:r.end])
+				r.start = r.end
+				return line, true
+			}
+			return "", false
+		}
+		if r.start == 0 && r.end == len(buf) {
+			// The line doesn't fit.
+			r.skip = true
+			r.end = 0
+		}
+		r.end = copy(buf, buf[r.start:r.end])
+		r.start = 0
+		n := read(r.fd, unsafe.Pointer(&buf[r.end]), int32(len(buf)-r.end))
+		if n <= 0 {
+			r.eof = true
+		} else {
+			r.end += int(n)
+		}
+	}
+}
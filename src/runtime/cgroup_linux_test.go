@@ -0,0 +1,70 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	. "runtime"
+	"testing"
+)
+
+func TestParseCPUMax(t *testing.T) {
+	for _, test := range []struct {
+		in    string
+		limit float64
+		ok    bool
+	}{
+		{"max 100000", 0, false},
+		{"50000 100000", 0.5, true},
+		{"250000 100000", 2.5, true},
+		{"200000 50000", 4, true},
+		{"0 100000", 0, false},
+		{"100000 0", 0, false},
+		{"100000", 0, false},
+		{"", 0, false},
+		{"x 100000", 0, false},
+	} {
+		limit, ok := ParseCPUMax(test.in)
+		if limit != test.limit || ok != test.ok {
+			t.Errorf("ParseCPUMax(%q) = %v, %v; want %v, %v", test.in, limit, ok, test.limit, test.ok)
+		}
+	}
+}
+
+func TestCgroupMountDir(t *testing.T) {
+	const (
+		v1 = "34 25 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid shared:15 - cgroup cgroup rw,cpu,cpuacct"
+		v2 = "30 24 0:26 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:4 - cgroup2 cgroup2 rw,nsdelegate"
+		// A container whose cgroup namespace root is not the
+		// hierarchy root, with no optional fields.
+		v2ns = "1200 1190 0:26 /kubepods/pod1 /sys/fs/cgroup ro,nosuid - cgroup2 cgroup rw"
+		mem  = "35 25 0:30 / /sys/fs/cgroup/memory rw,nosuid shared:16 - cgroup cgroup rw,memory"
+		ext4 = "26 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw"
+	)
+	for _, test := range []struct {
+		line, rel  string
+		version    int
+		dir, mount string
+		ok         bool
+	}{
+		{v1, "/user.slice", CgroupV1, "/sys/fs/cgroup/cpu,cpuacct/user.slice", "/sys/fs/cgroup/cpu,cpuacct", true},
+		{v1, "/", CgroupV1, "/sys/fs/cgroup/cpu,cpuacct", "/sys/fs/cgroup/cpu,cpuacct", true},
+		{v1, "/a", CgroupV2, "", "", false},
+		{v2, "/system.slice/foo.service", CgroupV2, "/sys/fs/cgroup/system.slice/foo.service", "/sys/fs/cgroup", true},
+		{v2, "/a", CgroupV1, "", "", false},
+		{v2ns, "/kubepods/pod1/ctr", CgroupV2, "/sys/fs/cgroup/ctr", "/sys/fs/cgroup", true},
+		{v2ns, "/kubepods/pod1", CgroupV2, "/sys/fs/cgroup", "/sys/fs/cgroup", true},
+		{v2ns, "/kubepods/pod10", CgroupV2, "", "", false},
+		{v2ns, "/other", CgroupV2, "", "", false},
+		{mem, "/a", CgroupV1, "", "", false},
+		{ext4, "/a", CgroupV1, "", "", false},
+		{"garbage", "/a", CgroupV2, "", "", false},
+	} {
+		dir, mount, ok := CgroupMountDir(test.line, test.rel, test.version)
+		if dir != test.dir || mount != test.mount || ok != test.ok {
+			t.Errorf("CgroupMountDir(%q, %q, %d) = %q, %q, %v; want %q, %q, %v",
+				test.line, test.rel, test.version, dir, mount, ok, test.dir, test.mount, test.ok)
+		}
+	}
+}
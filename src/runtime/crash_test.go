@@ -259,6 +259,19 @@ func TestGoexitCrash(t *testing.T) {
 	}
 }
 
+func TestTracebackLabels(t *testing.T) {
+	t.Parallel()
+	want := `goroutine 1 [running, labels: {"request":"7", "tenant":"a"}]:`
+	output := runTestProg(t, "testprog", "TracebackLabels", "GODEBUG=tracebacklabels=1")
+	if !strings.Contains(output, want) {
+		t.Errorf("output:\n%s\n\nwant output containing: %s", output, want)
+	}
+	output = runTestProg(t, "testprog", "TracebackLabels")
+	if strings.Contains(output, "labels:") {
+		t.Errorf("labels printed without GODEBUG=tracebacklabels=1:\n%s", output)
+	}
+}
+
 func TestGoexitDefer(t *testing.T) {
 	c := make(chan struct{})
 	go func() {
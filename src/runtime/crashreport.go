@@ -0,0 +1,269 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// crashFD is the file descriptor set by runtime/debug.SetCrashOutput
+// to which the output of fatal errors is copied, or ^uintptr(0).
+var crashFD uintptr = ^uintptr(0)
+
+// crashStructured is set if a structured report of the goroutines
+// follows the fatal error output written to crashFD.
+var crashStructured uint32
+
+//go:linkname setCrashFD runtime/debug.setCrashFD
+func setCrashFD(fd uintptr, structured bool) uintptr {
+	var s uint32
+	if structured {
+		s = 1
+	}
+	atomic.Store(&crashStructured, s)
+	return atomic.Xchguintptr(&crashFD, fd)
+}
+
+// writeCrash copies b to crashFD if the program is crashing.
+//
+//go:nosplit
+func writeCrash(b []byte) {
+	fd := atomic.Loaduintptr(&crashFD)
+	if fd == ^uintptr(0) {
+		return
+	}
+	gp := getg()
+	if gp != nil && gp.m != nil && (gp.m.dying > 0 || gp.m.throwing > 0) ||
+		gp == nil && atomic.Load(&panicking) > 0 {
+		write(fd, unsafe.Pointer(&b[0]), int32(len(b)))
+	}
+}
+
+// crashReportHeader starts the structured crash report. Each line after
+// it is a JSON object describing a goroutine, and crashReportTrailer
+// ends the report.
+const (
+	crashReportHeader  = "go crash report v1\n"
+	crashReportTrailer = "end go crash report\n"
+)
+
+var didreport bool
+
+// writeCrashReport writes the structured crash report to crashFD, if
+// requested, for the crashing goroutine gp, whose stack starts at pc
+// and sp, and if all is set for the other goroutines too. It runs after
+// the text traceback has been printed and must not allocate.
+func writeCrashReport(gp *g, pc, sp uintptr, all bool) {
+	fd := atomic.Loaduintptr(&crashFD)
+	if fd == ^uintptr(0) || atomic.Load(&crashStructured) == 0 || didreport {
+		return
+	}
+	didreport = true
+	level, _, _ := gotraceback()
+
+	w := &crashReportWriter{fd: fd}
+	w.str(crashReportHeader)
+	w.goroutine(gp, pc, sp)
+	if all {
+		_g_ := getg()
+		lock(&allglock)
+		for _, gp1 := range allgs {
+			if gp1 == gp || readgstatus(gp1) == _Gdead || isSystemGoroutine(gp1) && level < 2 {
+				continue
+			}
+			if gp1.m != _g_.m && readgstatus(gp1)&^_Gscan == _Grunning {
+				// The stack is in use on another thread.
+				w.goroutine(gp1, 0, 0)
+			} else {
+				w.goroutine(gp1, ^uintptr(0), ^uintptr(0))
+			}
+		}
+		unlock(&allglock)
+	}
+	w.str(crashReportTrailer)
+	w.flush()
+}
+
+// crashReportWriter writes the structured crash report without
+// allocating.
+type crashReportWriter struct {
+	fd     uintptr
+	n      int
+	buf    [512]byte
+	frames int
+	pcbuf  [_TracebackMaxFrames]uintptr
+}
+
+func (w *crashReportWriter) flush() {
+	if w.n > 0 {
+		write(w.fd, unsafe.Pointer(&w.buf[0]), int32(w.n))
+		w.n = 0
+	}
+}
+
+func (w *crashReportWriter) byte(c byte) {
+	if w.n == len(w.buf) {
+		w.flush()
+	}
+	w.buf[w.n] = c
+	w.n++
+}
+
+func (w *crashReportWriter) str(s string) {
+	for i := 0; i < len(s); i++ {
+		w.byte(s[i])
+	}
+}
+
+func (w *crashReportWriter) int(v int64) {
+	var buf [20]byte
+	if v < 0 {
+		w.byte('-')
+		v = -v
+	}
+	u := uint64(v)
+	i := len(buf)
+	for {
+		i--
+		buf[i] = byte('0' + u%10)
+		u /= 10
+		if u == 0 {
+			break
+		}
+	}
+	for _, c := range buf[i:] {
+		w.byte(c)
+	}
+}
+
+// quote writes s as a JSON string.
+func (w *crashReportWriter) quote(s string) {
+	const hex = "0123456789abcdef"
+	w.byte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			w.byte('\\')
+			w.byte(c)
+		case c < 0x20 || c == 0x7f:
+			w.str(`\u00`)
+			w.byte(hex[c>>4])
+			w.byte(hex[c&0xf])
+		default:
+			w.byte(c)
+		}
+	}
+	w.byte('"')
+}
+
+// goroutine writes the line for gp. If pc and sp are 0, the stack of gp
+// is not available; if they are ^uintptr(0), the saved ones are used.
+func (w *crashReportWriter) goroutine(gp *g, pc, sp uintptr) {
+	status := readgstatus(gp) &^ _Gscan
+	w.str(`{"goroutine":`)
+	w.int(gp.goid)
+	w.str(`,"status":`)
+	if status < uint32(len(gStatusStrings)) && gStatusStrings[status] != "" {
+		w.quote(gStatusStrings[status])
+	} else {
+		w.quote("???")
+	}
+	if status == _Gwaiting && gp.waitreason != "" {
+		w.str(`,"waitreason":`)
+		w.quote(gp.waitreason)
+	}
+	if (status == _Gwaiting || status == _Gsyscall) && gp.waitsince != 0 {
+		w.str(`,"waitns":`)
+		w.int(nanotime() - gp.waitsince)
+	}
+	if gp.lockedm != 0 {
+		w.str(`,"lockedthread":true`)
+	}
+	w.str(`,"frames":[`)
+	if pc != ^uintptr(0) && !findfunc(pc).valid() {
+		// A signal arrived in non-Go code, which gentraceback
+		// cannot unwind.
+		pc, sp = 0, 0
+	}
+	if pc != 0 || sp != 0 {
+		w.frames = 0
+		w.stack(gp, pc, sp)
+	}
+	w.str("]")
+	if gp.gopc != 0 {
+		if f := findfunc(gp.gopc); f.valid() {
+			tracepc := gp.gopc
+			if tracepc > f.entry {
+				tracepc--
+			}
+			file, line := funcline(f, tracepc)
+			w.str(`,"createdby":{"func":`)
+			w.quote(funcname(f))
+			w.str(`,"file":`)
+			w.quote(file)
+			w.str(`,"line":`)
+			w.int(int64(line))
+			w.str("}")
+		}
+	}
+	w.str("}\n")
+}
+
+// stack writes the frames of gp, with the calls inlined into each
+// frame first, like the text traceback. Like the text traceback, it
+// is best effort: the stacks of a crashing program may not unwind
+// completely, so gentraceback collects the PCs without a callback,
+// which would throw.
+func (w *crashReportWriter) stack(gp *g, pc, sp uintptr) {
+	n := gentraceback(pc, sp, 0, gp, 0, &w.pcbuf[0], len(w.pcbuf), nil, nil, 0)
+	wasinjected := false
+	for _, pc := range w.pcbuf[:n] {
+		f := findfunc(pc)
+		if !f.valid() {
+			break
+		}
+		injected := wasinjected
+		// The runtime injects calls to sigpanic and asyncPreempt
+		// with the exact PC of the interrupted instruction as the
+		// return PC.
+		wasinjected = f.funcID == funcID_sigpanic || f.funcID == funcID_asyncPreempt
+		if !showframe(f, gp, w.frames == 0, false) {
+			continue
+		}
+		tracepc := pc // back up to CALL instruction for funcline.
+		if pc > f.entry && !injected {
+			tracepc--
+		}
+		file, line := funcline(f, tracepc)
+		if inldata := funcdata(f, _FUNCDATA_InlTree); inldata != nil {
+			inltree := (*[1 << 20]inlinedCall)(inldata)
+			ix := pcdatavalue(f, _PCDATA_InlTreeIndex, tracepc, nil)
+			for ix != -1 {
+				w.frame(funcnameFromNameoff(f, inltree[ix].func_), file, line, pc)
+				file = funcfile(f, inltree[ix].file)
+				line = inltree[ix].line
+				ix = inltree[ix].parent
+			}
+		}
+		w.frame(funcname(f), file, line, pc)
+	}
+}
+
+func (w *crashReportWriter) frame(name, file string, line int32, pc uintptr) {
+	if w.frames > 0 {
+		w.byte(',')
+	}
+	w.frames++
+	w.str(`{"func":`)
+	w.quote(name)
+	w.str(`,"file":`)
+	w.quote(file)
+	w.str(`,"line":`)
+	w.int(int64(line))
+	w.str(`,"pc":`)
+	w.int(int64(pc))
+	w.str("}")
+}
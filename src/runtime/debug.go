@@ -13,10 +13,20 @@ import (
 // simultaneously and returns the previous setting. If n < 1, it does not
 // change the current setting.
 // The number of logical CPUs on the local machine can be queried with NumCPU.
+//
+// By default, GOMAXPROCS is the number of CPUs. On Linux, if the process
+// is in a cgroup with a CPU bandwidth limit, the default is lowered to
+// the limit, rounded up, and the runtime updates it when the limit
+// changes. Setting the GOMAXPROCS environment variable or calling
+// GOMAXPROCS with n > 0 disables these updates.
+//
 // This call will go away when the scheduler improves.
 func GOMAXPROCS(n int) int {
 	lock(&sched.lock)
 	ret := int(gomaxprocs)
+	if n > 0 {
+		sched.customGOMAXPROCS = true
+	}
 	unlock(&sched.lock)
 	if n <= 0 || n == ret {
 		return ret
@@ -0,0 +1,79 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"os"
+	"runtime"
+)
+
+// CrashOptions controls what is written to the crash output set by
+// SetCrashOutput.
+type CrashOptions struct {
+	// Structured makes the runtime follow the text of a fatal error
+	// with a machine-parseable report of the goroutines. The report
+	// starts with the line
+	//
+	//	go crash report v1
+	//
+	// and ends with the line
+	//
+	//	end go crash report
+	//
+	// Each line in between is a JSON object describing a goroutine,
+	// the crashing one first, with these fields:
+	//
+	//	goroutine     the goroutine ID
+	//	status        "running", "runnable", "waiting", "syscall", ...
+	//	waitreason    why the goroutine is waiting, such as "chan receive"
+	//	waitns        approximately how long it has been waiting or in a
+	//	              system call, in nanoseconds, if known
+	//	lockedthread  true if the goroutine is locked to its thread
+	//	frames        the stack, innermost first, as objects with the
+	//	              fields func, file, line and pc
+	//	createdby     the go statement that created the goroutine, as an
+	//	              object with the fields func, file and line
+	//
+	// Fields that do not apply are omitted, and frames is empty if
+	// the stack is not available. Which goroutines and frames are
+	// reported follows the GOTRACEBACK setting, like the text.
+	Structured bool
+}
+
+// SetCrashOutput configures a single additional file where unhandled
+// panics and other fatal errors are printed, in addition to standard
+// error. There is only one additional file: calling SetCrashOutput
+// again overrides any earlier call. SetCrashOutput duplicates f's file
+// descriptor, so the caller may safely close f as soon as
+// SetCrashOutput returns. To disable this additional crash output,
+// call SetCrashOutput(nil, CrashOptions{}).
+//
+// A supervising process or sidecar can pass the write end of a pipe to
+// collect the crash reports of a program, whose standard error may be
+// truncated or lost.
+func SetCrashOutput(f *os.File, opts CrashOptions) error {
+	fd := ^uintptr(0)
+	if f != nil {
+		// The runtime writes to the file descriptor while
+		// crashing, with no way to check that it is still the
+		// caller's file, so use a private duplicate that the
+		// caller cannot close. It is close-on-exec, so that a
+		// child process that is the crash monitor does not
+		// inherit it and sees the end of the pipe when this
+		// process crashes.
+		fd2, err := dupCrashFD(f.Fd())
+		if err != nil {
+			return &os.PathError{Op: "SetCrashOutput", Path: f.Name(), Err: err}
+		}
+		runtime.KeepAlive(f)
+		fd = fd2
+	}
+	if prev := setCrashFD(fd, opts.Structured); prev != ^uintptr(0) {
+		// os.NewFile and Close are portable, unlike the
+		// parameter type of syscall.Close.
+		os.NewFile(prev, "").Close()
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import "syscall"
+
+func dupCrashFD(fd uintptr) (uintptr, error) {
+	fd2, err := syscall.Dup(int(fd), -1)
+	if err != nil {
+		return 0, err
+	}
+	return uintptr(fd2), nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"internal/testenv"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	. "runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	if path := os.Getenv("TEST_CRASH_OUTPUT"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			panic(err)
+		}
+		opts := CrashOptions{Structured: os.Getenv("TEST_CRASH_STRUCTURED") != ""}
+		if err := SetCrashOutput(f, opts); err != nil {
+			panic(err)
+		}
+		// The runtime has its own copy of the file descriptor.
+		f.Close()
+		// Leave a goroutine blocked in a channel receive.
+		c := make(chan int)
+		ready := make(chan bool)
+		go func() {
+			ready <- true
+			<-c
+		}()
+		<-ready
+		time.Sleep(10 * time.Millisecond)
+		if os.Getenv("TEST_CRASH_SIGNAL") != "" {
+			// Wait for the test to send the signal.
+			os.Stdout.WriteString("ready\n")
+			time.Sleep(time.Minute)
+			os.Exit(0)
+		}
+		panic("oops")
+	}
+}
+
+// crashSignals are the signals a test can send to crash a child
+// process, by name.
+var crashSignals = map[string]os.Signal{}
+
+func runCrash(t *testing.T, structured bool) (stderr, crash string) {
+	return runCrashSignal(t, structured, "")
+}
+
+// runCrashSignal runs the test program with the crash output set. If
+// sig is not empty, the program is crashed with that signal rather
+// than with a panic.
+func runCrashSignal(t *testing.T, structured bool, sig string) (stderr, crash string) {
+	testenv.MustHaveExec(t)
+	dir, err := ioutil.TempDir("", "crashoutput")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "crash")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), "GOTRACEBACK=all", "TEST_CRASH_OUTPUT="+path)
+	if structured {
+		cmd.Env = append(cmd.Env, "TEST_CRASH_STRUCTURED=1")
+	}
+	var buf bytes.Buffer
+	cmd.Stderr = &buf
+	if sig == "" {
+		if err := cmd.Run(); err == nil {
+			t.Fatalf("program did not crash; stderr:\n%s", buf.Bytes())
+		}
+	} else {
+		cmd.Env = append(cmd.Env, "TEST_CRASH_SIGNAL=1")
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		if line != "ready\n" {
+			cmd.Process.Kill()
+			cmd.Wait()
+			t.Fatalf("program not ready: %q, %v; stderr:\n%s", line, err, buf.Bytes())
+		}
+		if err := cmd.Process.Signal(crashSignals[sig]); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Wait(); err == nil {
+			t.Fatalf("program did not crash; stderr:\n%s", buf.Bytes())
+		}
+	}
+	out, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), string(out)
+}
+
+func TestSetCrashOutput(t *testing.T) {
+	stderr, crash := runCrash(t, false)
+	if !strings.HasPrefix(crash, "panic: oops") || !strings.Contains(crash, "\ngoroutine ") {
+		t.Errorf("crash output does not hold the panic and traceback:\n%s", crash)
+	}
+	if !strings.HasSuffix(stderr, crash) {
+		t.Errorf("crash output differs from standard error:\n%s\nstderr:\n%s", crash, stderr)
+	}
+}
+
+type crashFrame struct {
+	Func string
+	File string
+	Line int
+}
+
+type crashGoroutine struct {
+	Goroutine  int64
+	Status     string
+	WaitReason string
+	Frames     []crashFrame
+	CreatedBy  *crashFrame
+}
+
+// parseCrashReport checks that the crash output holds the text of
+// standard error followed by a structured report, and returns the
+// goroutines in the report.
+func parseCrashReport(t *testing.T, stderr, crash string) []crashGoroutine {
+	i := strings.Index(crash, "go crash report v1\n")
+	if i < 0 {
+		t.Fatalf("no structured report in crash output:\n%s", crash)
+	}
+	if !strings.HasSuffix(stderr, crash[:i]) {
+		t.Errorf("crash output before the report differs from standard error:\n%s\nstderr:\n%s", crash[:i], stderr)
+	}
+
+	var gs []crashGoroutine
+	s := bufio.NewScanner(strings.NewReader(crash[i:]))
+	s.Buffer(nil, 1<<20)
+	s.Scan() // header
+	ended := false
+	for s.Scan() {
+		if s.Text() == "end go crash report" {
+			ended = true
+			break
+		}
+		var g crashGoroutine
+		if err := json.Unmarshal(s.Bytes(), &g); err != nil {
+			t.Fatalf("bad report line %q: %v", s.Text(), err)
+		}
+		gs = append(gs, g)
+	}
+	if !ended {
+		t.Fatalf("report not ended:\n%s", crash[i:])
+	}
+	return gs
+}
+
+// blockedGoroutine reports whether gs holds the goroutine that the
+// test program leaves blocked in a channel receive.
+func blockedGoroutine(gs []crashGoroutine) bool {
+	for _, g := range gs {
+		if g.Status == "waiting" && g.WaitReason == "chan receive" && g.CreatedBy != nil && g.CreatedBy.Func == "runtime/debug_test.init.0" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSetCrashOutputStructured(t *testing.T) {
+	stderr, crash := runCrash(t, true)
+	gs := parseCrashReport(t, stderr, crash)
+	if len(gs) < 2 {
+		t.Fatalf("got %d goroutines in the report, want at least 2:\n%s", len(gs), crash)
+	}
+	g := gs[0]
+	found := false
+	for _, f := range g.Frames {
+		if f.Func == "runtime/debug_test.init.0" && strings.HasSuffix(f.File, "crash_test.go") {
+			found = true
+		}
+	}
+	if g.Status != "running" || !found {
+		t.Errorf("crashing goroutine reported as %+v", g)
+	}
+	if !blockedGoroutine(gs[1:]) {
+		t.Errorf("blocked goroutine not in the report:\n%s", crash)
+	}
+}
+
+func TestSetCrashOutputSignal(t *testing.T) {
+	for _, sig := range []string{"SIGQUIT", "SIGABRT"} {
+		t.Run(sig, func(t *testing.T) {
+			if crashSignals[sig] == nil {
+				t.Skipf("%s not supported on %s", sig, runtime.GOOS)
+			}
+			stderr, crash := runCrashSignal(t, true, sig)
+			if !strings.HasPrefix(crash, sig) {
+				t.Errorf("crash output does not start with the signal:\n%s", crash)
+			}
+			gs := parseCrashReport(t, stderr, crash)
+			if len(gs) == 0 {
+				t.Fatalf("no goroutines in the report:\n%s", crash)
+			}
+			if !blockedGoroutine(gs) {
+				t.Errorf("blocked goroutine not in the report:\n%s", crash)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package debug
+
+import "syscall"
+
+func dupCrashFD(fd uintptr) (uintptr, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	fd2, err := syscall.Dup(int(fd))
+	if err != nil {
+		return 0, err
+	}
+	syscall.CloseOnExec(fd2)
+	return uintptr(fd2), nil
+}
@@ -0,0 +1,14 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package debug_test
+
+import "syscall"
+
+func init() {
+	crashSignals["SIGQUIT"] = syscall.SIGQUIT
+	crashSignals["SIGABRT"] = syscall.SIGABRT
+}
@@ -0,0 +1,22 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import "syscall"
+
+func dupCrashFD(fd uintptr) (uintptr, error) {
+	p, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, err
+	}
+	var h syscall.Handle
+	// The duplicate is not inheritable, so child processes do not
+	// keep it open.
+	err = syscall.DuplicateHandle(p, syscall.Handle(fd), p, &h, 0, false, syscall.DUPLICATE_SAME_ACCESS)
+	if err != nil {
+		return 0, err
+	}
+	return uintptr(h), nil
+}
@@ -172,6 +172,38 @@ func TestSetGCPercent(t *testing.T) {
 	}
 }
 
+var memoryLimitSink []byte
+
+func TestSetMemoryLimit(t *testing.T) {
+	// Test that the limit is being set and returned correctly.
+	old := SetMemoryLimit(123 << 20)
+	defer SetMemoryLimit(old)
+	if got := SetMemoryLimit(-1); got != 123<<20 {
+		t.Errorf("SetMemoryLimit(123<<20); SetMemoryLimit(-1) = %d, want %d", got, 123<<20)
+	}
+
+	// Test that the limit is respected even with GC off.
+	defer SetGCPercent(SetGCPercent(-1))
+	defer func() { memoryLimitSink = nil }()
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	limit := int64(ms.Sys) + 64<<20
+	SetMemoryLimit(limit)
+	runtime.ReadMemStats(&ms)
+	if int64(ms.NextGC) >= limit {
+		t.Errorf("NextGC = %d MB, want < memory limit %d MB", ms.NextGC>>20, limit>>20)
+	}
+	ngc := ms.NumGC
+	for i := 0; i < 256; i++ {
+		memoryLimitSink = make([]byte, 1<<20)
+	}
+	runtime.ReadMemStats(&ms)
+	if ms.NumGC == ngc {
+		t.Errorf("allocated 256 MB with a %d MB memory limit but no GC ran", limit>>20)
+	}
+}
+
 func abs64(a int64) int64 {
 	if a < 0 {
 		return -a
@@ -0,0 +1,50 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"sort"
+	"time"
+)
+
+// SchedStats collect information about goroutine scheduling.
+//
+// The runtime samples one in every few transitions of each goroutine
+// between running, runnable and waiting, so the statistics are
+// estimates.
+type SchedStats struct {
+	// LatencyQuantiles, if non-empty, is filled with quantiles
+	// summarizing the distribution of scheduler latency: the time
+	// goroutines spend runnable, waiting in a run queue for a P,
+	// before they run. For example, if len(LatencyQuantiles) is 101,
+	// it is filled with the minimum, 1%, 2%, ..., 99% and maximum
+	// latencies. A long tail of this distribution means that
+	// goroutines are starved of CPU.
+	LatencyQuantiles []time.Duration
+
+	// Waits is the time goroutines spent waiting, by wait reason,
+	// longest total first. The time goroutines spend runnable after
+	// the wait is not included.
+	Waits []WaitStats
+}
+
+// WaitStats is the time goroutines spent waiting for one reason.
+type WaitStats struct {
+	Reason string        // as shown in goroutine tracebacks, such as "chan receive"
+	Count  int64         // number of waits
+	Total  time.Duration // total time spent waiting
+}
+
+// ReadSchedStats reads statistics about goroutine scheduling into
+// stats. The stats.Waits slice will be reused if large enough,
+// reallocated otherwise.
+func ReadSchedStats(stats *SchedStats) {
+	readSchedLatency(stats.LatencyQuantiles)
+	stats.Waits = stats.Waits[:0]
+	readWaitStats(&stats.Waits)
+	sort.Slice(stats.Waits, func(i, j int) bool {
+		return stats.Waits[i].Total > stats.Waits[j].Total
+	})
+}
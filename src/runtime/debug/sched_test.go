@@ -0,0 +1,60 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug_test
+
+import (
+	. "runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestReadSchedStats(t *testing.T) {
+	// Block a goroutine on a channel receive many times, so that
+	// some of its waits are sampled.
+	c := make(chan int)
+	done := make(chan bool)
+	go func() {
+		for range c {
+		}
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		time.Sleep(100 * time.Microsecond)
+		c <- i
+	}
+	close(c)
+	<-done
+
+	var stats SchedStats
+	stats.LatencyQuantiles = make([]time.Duration, 5)
+	ReadSchedStats(&stats)
+
+	q := stats.LatencyQuantiles
+	for i := range q {
+		if q[i] < 0 || i > 0 && q[i] < q[i-1] {
+			t.Errorf("stats.LatencyQuantiles = %v, want non-negative and non-decreasing", q)
+			break
+		}
+	}
+	if q[len(q)-1] == 0 {
+		t.Errorf("stats.LatencyQuantiles = %v, want a non-zero maximum", q)
+	}
+
+	var found bool
+	for i, w := range stats.Waits {
+		if i > 0 && w.Total > stats.Waits[i-1].Total {
+			t.Errorf("stats.Waits not sorted by total time: %v", stats.Waits)
+		}
+		if w.Reason == "chan receive" {
+			found = true
+			if w.Count <= 0 || w.Total <= 0 {
+				t.Errorf("got %+v for chan receive, want positive count and time", w)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no chan receive in stats.Waits: %v", stats.Waits)
+	}
+}
@@ -13,5 +13,9 @@ func readGCStats(*[]time.Duration)
 func freeOSMemory()
 func setMaxStack(int) int
 func setGCPercent(int32) int32
+func setMemoryLimit(int64) int64
 func setPanicOnFault(bool) bool
 func setMaxThreads(int) int
+func readSchedLatency([]time.Duration)
+func readWaitStats(*[]WaitStats)
+func setCrashFD(uintptr, bool) uintptr
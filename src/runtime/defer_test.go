@@ -0,0 +1,169 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// Make sure open-coded defer exit code is not lost, even with an early return
+// and conditional defers.
+func TestOpenDeferExit(t *testing.T) {
+	var list []int
+	f := func(a, b bool) {
+		if a {
+			defer func() { list = append(list, 1) }()
+		}
+		if b {
+			defer func() { list = append(list, 2) }()
+			if a {
+				return
+			}
+		}
+		list = append(list, 0)
+	}
+	f(true, true)
+	f(false, true)
+	f(true, false)
+	f(false, false)
+	want := []int{2, 1, 0, 2, 0, 1, 0}
+	if !reflect.DeepEqual(list, want) {
+		t.Fatalf("got %v, want %v", list, want)
+	}
+}
+
+type deferT struct{ s string }
+
+func (d deferT) val(out *[]string, n int)  { *out = append(*out, fmt.Sprint("val ", d.s, n)) }
+func (d *deferT) ptr(out *[]string, n int) { *out = append(*out, fmt.Sprint("ptr ", d.s, n)) }
+
+type deferI interface {
+	val(out *[]string, n int)
+}
+
+// Make sure the receiver and arguments of open-coded defers are evaluated
+// at the defer statement, for all kinds of calls.
+func TestOpenDeferArgs(t *testing.T) {
+	var out []string
+	func() {
+		x := 1
+		d := deferT{"a"}
+		var i deferI = deferT{"i"}
+		f := func(s string, a [4]int) { out = append(out, fmt.Sprint(s, a)) }
+		defer out2(&out, x)
+		defer d.val(&out, x)
+		defer d.ptr(&out, x)
+		defer i.val(&out, x)
+		defer f("closure", [4]int{x, x, x, x})
+		x = 2
+		d.s = "b"
+		i = deferT{"j"}
+		f = nil
+	}()
+	want := []string{"closure[1 1 1 1]", "val i1", "ptr b1", "val a1", "out2 1"}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+}
+
+func out2(out *[]string, n int) {
+	*out = append(*out, fmt.Sprint("out2 ", n))
+}
+
+// Make sure that a recover in an open-coded defer lets the remaining defers
+// in the frame run, and that results set by defers are seen by the caller.
+func TestOpenDeferRecover(t *testing.T) {
+	var list []int
+	f := func() (r int) {
+		defer func() { list = append(list, 3) }()
+		defer func() {
+			if recover() != nil {
+				r = 42
+			}
+		}()
+		defer func() { list = append(list, 1) }()
+		var p *int
+		return *p
+	}
+	if r := f(); r != 42 {
+		t.Errorf("got result %d, want 42", r)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(list, want) {
+		t.Errorf("got %v, want %v", list, want)
+	}
+}
+
+// Make sure that the remaining open-coded defers in a frame run when a
+// defer in that frame panics.
+func TestOpenDeferNestedPanic(t *testing.T) {
+	var list []int
+	func() {
+		defer func() {
+			if v := recover(); v != "second" {
+				t.Errorf("recovered %v, want second", v)
+			}
+		}()
+		defer func() { list = append(list, 2) }()
+		defer func() { panic("second") }()
+		defer func() { list = append(list, 1) }()
+		panic("first")
+	}()
+	if want := []int{1, 2}; !reflect.DeepEqual(list, want) {
+		t.Fatalf("got %v, want %v", list, want)
+	}
+}
+
+// Make sure Goexit runs open-coded defers in all frames.
+func TestOpenDeferGoexit(t *testing.T) {
+	c := make(chan []int)
+	go func() {
+		var list []int
+		defer func() { c <- list }()
+		defer func() { list = append(list, 2) }()
+		func() {
+			defer func() { list = append(list, 1) }()
+			runtime.Goexit()
+		}()
+		list = append(list, -1)
+	}()
+	if list, want := <-c, []int{1, 2}; !reflect.DeepEqual(list, want) {
+		t.Fatalf("got %v, want %v", list, want)
+	}
+}
+
+// Make sure pointers into the stack saved by open-coded defers are adjusted
+// when the stack moves while the defers run during a panic.
+func TestOpenDeferStackGrowth(t *testing.T) {
+	var x [16]int
+	for i := range x {
+		x[i] = i
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("did not panic")
+		}
+	}()
+	defer func(p *[16]int) {
+		growStackDefer(1000)
+		runtime.GC()
+		for i := range p {
+			if p[i] != i {
+				t.Fatalf("p[%d] = %d after stack growth", i, p[i])
+			}
+		}
+	}(&x)
+	panic("grow")
+}
+
+func growStackDefer(n int) int {
+	var buf [64]byte
+	if n == 0 {
+		return int(buf[0])
+	}
+	return growStackDefer(n-1) + int(buf[n%64])
+}
@@ -7,6 +7,9 @@ const (
 	_EINTR  = 0x4
 	_EFAULT = 0xe
 
+	_O_NONBLOCK = 0x4
+	_O_CLOEXEC  = 0x400000
+
 	_PROT_NONE  = 0x0
 	_PROT_READ  = 0x1
 	_PROT_WRITE = 0x2
@@ -108,6 +111,11 @@ type timespec struct {
 	_       [4]byte // EABI
 }
 
+//go:nosplit
+func (ts *timespec) setNsec(ns int64) {
+	ts.tv_sec = int64(timediv(ns, 1e9, &ts.tv_nsec))
+}
+
 func (ts *timespec) set_sec(x int32) {
 	ts.tv_sec = int64(x)
 }
@@ -8,7 +8,9 @@ const (
 	_EBADF       = 0x9
 	_EFAULT      = 0xe
 	_EAGAIN      = 0xb
+	_EBUSY       = 0x10
 	_ETIMEDOUT   = 0x91
+	_ETIME       = 0x3e
 	_EWOULDBLOCK = 0xb
 	_EINPROGRESS = 0x96
 
@@ -99,7 +101,9 @@ const (
 	_POLLHUP = 0x10
 	_POLLERR = 0x8
 
-	_PORT_SOURCE_FD = 0x4
+	_PORT_SOURCE_FD    = 0x4
+	_PORT_SOURCE_ALERT = 0x5
+	_PORT_ALERT_UPDATE = 0x2
 )
 
 type semt struct {
@@ -161,6 +165,12 @@ type timespec struct {
 	tv_nsec int64
 }
 
+//go:nosplit
+func (ts *timespec) setNsec(ns int64) {
+	ts.tv_sec = ns / 1e9
+	ts.tv_nsec = ns % 1e9
+}
+
 type timeval struct {
 	tv_sec  int64
 	tv_usec int64
@@ -116,8 +116,9 @@ const (
 	ITIMER_VIRTUAL = C.ITIMER_VIRTUAL
 	ITIMER_PROF    = C.ITIMER_PROF
 
-	O_RDONLY  = C.O_RDONLY
-	O_CLOEXEC = C.O_CLOEXEC
+	O_RDONLY   = C.O_RDONLY
+	O_CLOEXEC  = C.O_CLOEXEC
+	O_NONBLOCK = C.O_NONBLOCK
 
 	EPOLLIN       = C.POLLIN
 	EPOLLOUT      = C.POLLOUT
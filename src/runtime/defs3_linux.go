@@ -24,6 +24,7 @@ import "C"
 const (
 	O_RDONLY    = C.O_RDONLY
 	O_CLOEXEC   = C.O_CLOEXEC
+	O_NONBLOCK  = C.O_NONBLOCK
 	SA_RESTORER = 0 // unused
 )
 
@@ -121,6 +121,9 @@ const (
 	_EV_EOF       = 0x8000
 	_EVFILT_READ  = -0x1
 	_EVFILT_WRITE = -0x2
+	_EVFILT_USER  = -0xa
+
+	_NOTE_TRIGGER = 0x1000000
 )
 
 type machbody struct {
@@ -206,6 +209,11 @@ type timespec struct {
 	tv_nsec int32
 }
 
+//go:nosplit
+func (ts *timespec) setNsec(ns int64) {
+	ts.tv_sec = timediv(ns, 1e9, &ts.tv_nsec)
+}
+
 type fpcontrol struct {
 	pad_cgo_0 [2]byte
 }
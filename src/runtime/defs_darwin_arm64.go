@@ -121,6 +121,9 @@ const (
 	_EV_EOF       = 0x8000
 	_EVFILT_READ  = -0x1
 	_EVFILT_WRITE = -0x2
+	_EVFILT_USER  = -0xa
+
+	_NOTE_TRIGGER = 0x1000000
 )
 
 type machbody struct {
@@ -208,6 +211,12 @@ type timespec struct {
 	tv_nsec int64
 }
 
+//go:nosplit
+func (ts *timespec) setNsec(ns int64) {
+	ts.tv_sec = ns / 1e9
+	ts.tv_nsec = ns % 1e9
+}
+
 type exceptionstate64 struct {
 	far uint64 // virtual fault addr
 	esr uint32 // exception syndrome
@@ -85,6 +85,9 @@ const (
 	_EV_EOF       = 0x8000
 	_EVFILT_READ  = -0x1
 	_EVFILT_WRITE = -0x2
+	_EVFILT_USER  = -0x9
+
+	_NOTE_TRIGGER = 0x1000000
 )
 
 type rtprio struct {
@@ -174,6 +177,12 @@ type timespec struct {
 	tv_nsec int64
 }
 
+//go:nosplit
+func (ts *timespec) setNsec(ns int64) {
+	ts.tv_sec = ns / 1e9
+	ts.tv_nsec = ns % 1e9
+}
+
 func (ts *timespec) set_sec(x int64) {
 	ts.tv_sec = x
 }
@@ -98,6 +98,9 @@ const (
 	_EV_EOF       = 0x8000
 	_EVFILT_READ  = -0x1
 	_EVFILT_WRITE = -0x2
+	_EVFILT_USER  = -0xb
+
+	_NOTE_TRIGGER = 0x1000000
 )
 
 type rtprio struct {
@@ -189,6 +192,11 @@ type timespec struct {
 	tv_nsec int32
 }
 
+//go:nosplit
+func (ts *timespec) setNsec(ns int64) {
+	ts.tv_sec = timediv(ns, 1e9, &ts.tv_nsec)
+}
+
 func (ts *timespec) set_sec(x int64) {
 	ts.tv_sec = int32(x)
 }
@@ -16,6 +16,7 @@ const (
 	_MAP_FIXED   = 0x10
 
 	_MADV_DONTNEED   = 0x4
+	_MADV_FREE       = 0x8
 	_MADV_HUGEPAGE   = 0xe
 	_MADV_NOHUGEPAGE = 0xf
 
@@ -71,6 +72,7 @@ const (
 	_ITIMER_VIRTUAL = 0x1
 	_O_RDONLY       = 0
 	_O_CLOEXEC      = 0x80000
+	_O_NONBLOCK     = 0x800
 
 	_EPOLLIN       = 0x1
 	_EPOLLOUT      = 0x4
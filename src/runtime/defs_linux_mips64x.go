@@ -18,6 +18,7 @@ const (
 	_MAP_FIXED   = 0x10
 
 	_MADV_DONTNEED   = 0x4
+	_MADV_FREE       = 0x8
 	_MADV_HUGEPAGE   = 0xe
 	_MADV_NOHUGEPAGE = 0xf
 
@@ -147,6 +148,7 @@ type epollevent struct {
 const (
 	_O_RDONLY    = 0x0
 	_O_CLOEXEC   = 0x80000
+	_O_NONBLOCK  = 0x80
 	_SA_RESTORER = 0
 )
 
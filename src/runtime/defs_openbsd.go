@@ -29,6 +29,9 @@ const (
 	EINTR  = C.EINTR
 	EFAULT = C.EFAULT
 
+	O_NONBLOCK = C.O_NONBLOCK
+	O_CLOEXEC  = C.O_CLOEXEC
+
 	PROT_NONE  = C.PROT_NONE
 	PROT_READ  = C.PROT_READ
 	PROT_WRITE = C.PROT_WRITE
@@ -9,6 +9,9 @@ const (
 	_EINTR  = 0x4
 	_EFAULT = 0xe
 
+	_O_NONBLOCK = 0x4
+	_O_CLOEXEC  = 0x10000
+
 	_PROT_NONE  = 0x0
 	_PROT_READ  = 0x1
 	_PROT_WRITE = 0x2
@@ -143,6 +146,12 @@ type timespec struct {
 	tv_nsec int64
 }
 
+//go:nosplit
+func (ts *timespec) setNsec(ns int64) {
+	ts.tv_sec = ns / 1e9
+	ts.tv_nsec = ns % 1e9
+}
+
 func (ts *timespec) set_sec(x int64) {
 	ts.tv_sec = x
 }
@@ -8,3 +8,16 @@ package runtime
 
 var NewOSProc0 = newosproc0
 var Mincore = mincore
+
+var ParseCPUMax = parseCPUMax
+
+const (
+	CgroupV1 = cgroupV1
+	CgroupV2 = cgroupV2
+)
+
+func CgroupMountDir(line, rel string, version int) (dir, mount string, ok bool) {
+	var buf [cgroupPathMax]byte
+	n, mountLen, ok := cgroupMountDir(line, rel, version, buf[:])
+	return string(buf[:n]), string(buf[:mountLen]), ok
+}
@@ -34,6 +34,7 @@ var Fastlog2 = fastlog2
 
 var Atoi = atoi
 var Atoi32 = atoi32
+var ParseByteCount = parseByteCount
 
 type LFNode struct {
 	Next    uint64
@@ -387,6 +388,12 @@ func MapBucketsPointerIsNil(m map[int]int) bool {
 	return h.buckets == nil
 }
 
+// MapGrowing reports whether the map m is in the middle of a growth.
+func MapGrowing(m interface{}) bool {
+	h := (*hmap)(efaceOf(&m).data)
+	return h.growing()
+}
+
 func LockOSCounts() (external, internal uint32) {
 	g := getg()
 	if g.m.lockedExt+g.m.lockedInt == 0 {
@@ -413,3 +420,57 @@ func TracebackSystemstack(stk []uintptr, i int) int {
 	})
 	return n
 }
+
+// ReadMetricsSlow reads the given metrics along with MemStats while
+// the world is stopped, so that the two can be compared exactly.
+//
+// The samples must have been read once already, so that reading them
+// again doesn't allocate.
+func ReadMetricsSlow(memStats *MemStats, samplesp unsafe.Pointer, len, cap int) {
+	stopTheWorld("ReadMetricsSlow")
+
+	// Read memstats first. It's going to flush
+	// the mcaches which readMetrics relies on for
+	// exact allocation counts.
+	systemstack(func() {
+		readmemstats_m(memStats)
+	})
+
+	// Read metrics off the system stack.
+	//
+	// The only part of readMetrics that could allocate
+	// and skew the stats is initMetrics, and the caller
+	// has already made sure it ran.
+	readMetrics(samplesp, len, cap)
+
+	startTheWorld()
+}
+
+// UserArena is a user arena for testing, usable even if the toolchain
+// was not built with GOEXPERIMENT=arenas.
+type UserArena struct {
+	a *userArena
+}
+
+const UserArenaChunkBytes = userArenaChunkBytes
+
+func NewUserArena() *UserArena {
+	return &UserArena{new(userArena)}
+}
+
+func (a *UserArena) New(ptr interface{}) {
+	arena_runtime_new(unsafe.Pointer(a.a), ptr)
+}
+
+func (a *UserArena) MakeSlice(slicePtr interface{}, len, cap int) {
+	arena_runtime_makeSlice(unsafe.Pointer(a.a), slicePtr, len, cap)
+}
+
+func (a *UserArena) Free() {
+	a.a.free()
+}
+
+// Chunks returns the number of chunks of the arena.
+func (a *UserArena) Chunks() int {
+	return len(a.a.chunks)
+}
@@ -21,12 +21,31 @@ is GOGC=100. Setting GOGC=off disables the garbage collector entirely.
 The runtime/debug package's SetGCPercent function allows changing this
 percentage at run time. See https://golang.org/pkg/runtime/debug/#SetGCPercent.
 
+The GOMEMLIMIT variable sets a soft memory limit for the runtime. This memory limit
+includes the Go heap and all other memory managed by the runtime, and excludes
+external memory sources such as mappings of the binary itself, memory managed in
+other languages, and memory held by the operating system on behalf of the Go
+program. GOMEMLIMIT is a numeric value in bytes with an optional unit suffix.
+The supported suffixes include B, KiB, MiB, GiB, and TiB. These suffixes
+represent quantities of bytes as defined by the IEC 80000-13 standard. That is,
+they are based on powers of two: KiB means 2^10 bytes, MiB means 2^20 bytes,
+and so on. The default setting is no limit. GOMEMLIMIT=off also means no limit.
+The runtime/debug package's SetMemoryLimit function allows changing this limit
+at run time. See https://golang.org/pkg/runtime/debug/#SetMemoryLimit.
+
 The GODEBUG variable controls debugging variables within the runtime.
 It is a comma-separated list of name=val pairs setting these named variables:
 
 	allocfreetrace: setting allocfreetrace=1 causes every allocation to be
 	profiled and a stack trace printed on each object's allocation and free.
 
+	asyncpreemptoff: asyncpreemptoff=1 disables signal-based
+	asynchronous goroutine preemption. This makes some loops
+	non-preemptible for long periods, which may delay GC and
+	goroutine scheduling. This is useful for debugging GC issues
+	because it also disables the conservative stack scanning used
+	for asynchronously preempted goroutines.
+
 	cgocheck: setting cgocheck=0 disables all checks for packages
 	using cgo to incorrectly pass Go pointers to non-Go code.
 	Setting cgocheck=1 (the default) enables relatively cheap
@@ -34,6 +53,11 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	expensive checks that should not miss any errors, but will
 	cause your program to run slower.
 
+	cgroupgomaxprocs: setting cgroupgomaxprocs=0 makes the default
+	GOMAXPROCS the number of CPUs, ignoring the CPU limit of the
+	process's cgroup on Linux, and stops the runtime from updating
+	GOMAXPROCS when that limit changes.
+
 	efence: setting efence=1 causes the allocator to run in a mode
 	where each object is allocated on a unique page and addresses are
 	never recycled.
@@ -94,6 +118,20 @@ It is a comma-separated list of name=val pairs setting these named variables:
 		sys: #       MB mapped from the system
 		released: #  MB released to the system
 		consumed: #  MB allocated from the system
+	The background scavenger, which continuously returns memory in excess
+	of the heap goal to the system, emits a different summary each time it
+	reaches its goal:
+		scvg: # KB released, retained: # MB, goal: # MB
+	where retained is the heap memory still backed by the system and goal
+	is the retained memory the background scavenger aims for.
+
+	goroutineleak: setting goroutineleak=1 makes the garbage collector look for
+	leaked goroutines in its first cycle and then at most once every two minutes.
+	A goroutine has leaked when it is blocked on a channel or a sync object that
+	no goroutine that could run again can reach. Each leaked goroutine is
+	reported on standard error, with its stack and the go statement that created
+	it, the first time it is found. A cycle that looks for leaks stops the world
+	for its whole mark phase. See also the goroutineleak profile in runtime/pprof.
 
 	memprofilerate: setting memprofilerate=X will update the value of runtime.MemProfileRate.
 	When set to 0 memory profiling is disabled.  Refer to the description of
@@ -105,6 +143,20 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	This should only be used as a temporary workaround to diagnose buggy code.
 	The real fix is to not store integers in pointer-typed locations.
 
+	madvdontneed: setting madvdontneed=0 will use MADV_FREE
+	instead of MADV_DONTNEED on Linux when returning memory to the
+	kernel. This is more efficient, but means RSS numbers will
+	drop only when the OS is under memory pressure. MADV_FREE
+	requires Linux 4.5 or later.
+
+	mutexowner: setting mutexowner=1 makes sync.Mutex (and the writer side of
+	sync.RWMutex) record the goroutine that locks it and where. Unlocking a
+	Mutex from a goroutine other than the one that locked it crashes the program,
+	printing the stack of the Lock as well as that of the Unlock, and so does
+	unlocking a recently unlocked Mutex, printing the stack of the first Unlock.
+	Handing a locked Mutex to another goroutine to unlock is allowed by the sync
+	package, so this is only useful for programs that never do it.
+
 	sbrk: setting sbrk=1 replaces the memory allocator and garbage collector
 	with a trivial allocator that obtains memory from the operating system and
 	never reclaims any memory.
@@ -118,6 +170,10 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	schedtrace: setting schedtrace=X causes the scheduler to emit a single line to standard
 	error every X milliseconds, summarizing the scheduler state.
 
+	tracebacklabels: setting tracebacklabels=1 prints the profiler labels of
+	each goroutine (see runtime/pprof.Do) in its header in tracebacks, for
+	example "goroutine 7 [chan receive, labels: {"tenant":"a"}]:".
+
 The net and net/http packages also refer to debugging variables in GODEBUG.
 See the documentation for those packages for details.
 
@@ -125,7 +181,9 @@ The GOMAXPROCS variable limits the number of operating system threads that
 can execute user-level Go code simultaneously. There is no limit to the number of threads
 that can be blocked in system calls on behalf of Go code; those do not count against
 the GOMAXPROCS limit. This package's GOMAXPROCS function queries and changes
-the limit.
+the limit. If GOMAXPROCS is not set, the limit defaults to the number of CPUs,
+or on Linux to the CPU bandwidth limit of the process's cgroup, rounded up,
+if that is lower.
 
 The GOTRACEBACK variable controls the amount of output generated when a Go
 program fails due to an unrecovered panic or an unexpected runtime condition.
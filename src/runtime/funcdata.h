@@ -10,10 +10,12 @@
 
 #define PCDATA_StackMapIndex 0
 #define PCDATA_InlTreeIndex 1
+#define PCDATA_UnsafePoint 2
 
 #define FUNCDATA_ArgsPointerMaps 0 /* garbage collector blocks */
 #define FUNCDATA_LocalsPointerMaps 1
 #define FUNCDATA_InlTree 2
+#define FUNCDATA_OpenCodedDeferInfo 3 /* info for func with open-coded defers */
 
 // Pseudo-assembly statements.
 
@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"runtime"
 	"runtime/debug"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -27,6 +28,24 @@ func TestGcSys(t *testing.T) {
 	}
 }
 
+func TestGoroutineLeakGODEBUG(t *testing.T) {
+	if os.Getenv("GOGC") == "off" {
+		t.Skip("skipping test; GOGC=off in environment")
+	}
+	got := runTestProg(t, "testprog", "GoroutineLeak", "GODEBUG=goroutineleak=1")
+	for _, want := range []string{
+		"goroutine ",
+		" [chan receive (leaked)]:\n",
+		"main.leakRecv.func1(",
+		"created by main.leakRecv\n",
+		"OK\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
 func TestGcDeepNesting(t *testing.T) {
 	type T [2][2][2][2][2][2][2][2][2][2]*int
 	a := new(T)
@@ -6,55 +6,53 @@ package runtime
 
 // This file contains the implementation of Go's map type.
 //
-// A map is just a hash table. The data is arranged
-// into an array of buckets. Each bucket contains up to
-// 8 key/value pairs. The low-order bits of the hash are
-// used to select a bucket. Each bucket contains a few
-// high-order bits of each hash to distinguish the entries
-// within a single bucket.
+// A map is a hash table using open addressing. The data is
+// arranged into an array of 2^B buckets. Each bucket is a group
+// of 8 slots for key/value pairs, with one control byte per slot.
+// A control byte says whether its slot is empty, deleted (a
+// tombstone), or full. The control byte of a full slot also holds
+// the top 7 bits of the hash of its key.
 //
-// If more than 8 keys hash to a bucket, we chain on
-// extra buckets.
+// The low-order bits of the hash select the first bucket to look
+// in. If the key is not there and the bucket has no empty slot, the
+// lookup continues with the next bucket of a quadratic probe
+// sequence. The 8 control bytes of a bucket are loaded as a single
+// word and compared against the hash all at once (SIMD within a
+// register), so a lookup rarely compares more than one key.
 //
-// When the hashtable grows, we allocate a new array
-// of buckets twice as big. Buckets are incrementally
-// copied from the old bucket array to the new bucket array.
+// When the table gets too full, counting tombstones, a new bucket
+// array is allocated. The new array is twice as big, unless enough of
+// the used slots were tombstones for the live entries to fit
+// comfortably in an array of the same size. Entries are copied from
+// the old array to the new one incrementally, a few buckets at a time
+// on each write, so no single write has to rehash the whole table.
+// The old array is never written again. A bitmap records which of its
+// buckets have been copied ("evacuated"); the entries of a bucket that
+// has not been evacuated yet are still live.
 //
-// Map iterators walk through the array of buckets and
-// return the keys in walk order (bucket #, then overflow
-// chain order, then bucket index).  To maintain iteration
-// semantics, we never move keys within their bucket (if
-// we did, keys might be returned 0 or 2 times).  When
-// growing the table, iterators remain iterating through the
-// old table and must check the new table if the bucket
-// they are iterating through has been moved ("evacuated")
-// to the new table.
-
-// Picking loadFactor: too large and we have lots of overflow
-// buckets, too small and we waste a lot of space. I wrote
-// a simple program to check some stats for different loads:
-// (64-bit, 8 byte keys and values)
-//  loadFactor    %overflow  bytes/entry     hitprobe    missprobe
-//        4.00         2.13        20.77         3.00         4.00
-//        4.50         4.05        17.30         3.25         4.50
-//        5.00         6.85        14.77         3.50         5.00
-//        5.50        10.55        12.94         3.75         5.50
-//        6.00        15.27        11.67         4.00         6.00
-//        6.50        20.90        10.79         4.25         6.50
-//        7.00        27.14        10.15         4.50         7.00
-//        7.50        34.03         9.73         4.75         7.50
-//        8.00        41.10         9.40         5.00         8.00
+// While the table is growing, lookups look in the new array first,
+// and then in the old one. A write first evacuates the old buckets
+// that a lookup of its key would look at, so the key can only be in
+// the new array, and then evacuates a couple more buckets in order.
 //
-// %overflow   = percentage of buckets which have an overflow bucket
-// bytes/entry = overhead bytes used per key/value pair
-// hitprobe    = # of entries to check when looking up a present key
-// missprobe   = # of entries to check when looking up an absent key
-//
-// Keep in mind this data is for maximally loaded tables, i.e. just
-// before the table grows. Typical tables will be somewhat less loaded.
+// Map iterators walk through the bucket array the map had when the
+// iteration started, beginning at a random bucket and slot offset.
+// Keys never move within an array, so each key is returned at most
+// once. If the map has been rehashed since the iteration started,
+// the iterator keeps walking the old array, and looks every key up
+// in the current one to get its value and to skip deleted keys.
+// An iteration that starts while the map is growing walks the new
+// array, skipping the keys that the old array holds, and then the old
+// array.
+
+// Picking loadFactor: lookups for absent keys stop at the first
+// bucket with an empty slot, so the table must keep some empty
+// slots in most buckets. With at most 7/8 of the slots in use, few
+// lookups look at more than two buckets, while the overhead per
+// entry is lower than with the old chained buckets, which had a
+// maximum average load of 6.5 per 8 slots plus overflow buckets.
 
 import (
-	"runtime/internal/atomic"
 	"runtime/internal/sys"
 	"unsafe"
 )
@@ -64,10 +62,12 @@ const (
 	bucketCntBits = 3
 	bucketCnt     = 1 << bucketCntBits
 
-	// Maximum average load of a bucket that triggers growth is 6.5.
+	// Maximum fraction of the slots in use, counting tombstones,
+	// that triggers growth is 7/8. A table with a single bucket
+	// may be full.
 	// Represent as loadFactorNum/loadFactDen, to allow integer math.
-	loadFactorNum = 13
-	loadFactorDen = 2
+	loadFactorNum = 7
+	loadFactorDen = 8
 
 	// Maximum key or value size to keep inline (instead of mallocing per element).
 	// Must fit in a uint8.
@@ -76,79 +76,52 @@ const (
 	maxKeySize   = 128
 	maxValueSize = 128
 
-	// data offset should be the size of the bmap struct, but needs to be
-	// aligned correctly. For amd64p32 this means 64-bit alignment
-	// even though pointers are 32 bit.
-	dataOffset = unsafe.Offsetof(struct {
-		b bmap
-		v int64
-	}{}.v)
-
-	// Possible tophash values. We reserve a few possibilities for special marks.
-	// Each bucket (including its overflow buckets, if any) will have either all or none of its
-	// entries in the evacuated* states (except during the evacuate() method, which only happens
-	// during map writes and thus no one else can observe the map during that time).
-	empty          = 0 // cell is empty
-	evacuatedEmpty = 1 // cell is empty, bucket is evacuated.
-	evacuatedX     = 2 // key/value is valid.  Entry has been evacuated to first half of larger table.
-	evacuatedY     = 3 // same as above, but evacuated to second half of larger table.
-	minTopHash     = 4 // minimum tophash for a normal filled cell.
+	// data offset is the size of the control word. Keys and values
+	// are at most 8-byte aligned, so they need no padding.
+	dataOffset = unsafe.Sizeof(bmap{})
+
+	// Control byte values. Zeroed memory is a bucket of empty slots.
+	ctrlEmpty   = 0x00 // slot is empty
+	ctrlDeleted = 0x01 // slot is empty, but may be on the probe sequence of a key (tombstone)
+	ctrlFull    = 0x80 // set in the control byte of a full slot; the low 7 bits are hash bits
+	ctrlNaN     = 0xff // control byte of a key != key that was evacuated; see mapiternext
+
+	// Masks for matching the control bytes of a bucket in parallel.
+	ctrlLSB  = 0x0101010101010101 // low bit of each control byte
+	ctrlMSB  = 0x8080808080808080 // high bit of each control byte
+	ctrlLow7 = 0x7f7f7f7f7f7f7f7f // all but the high bit of each control byte
 
 	// flags
-	iterator     = 1 // there may be an iterator using buckets
-	oldIterator  = 2 // there may be an iterator using oldbuckets
 	hashWriting  = 4 // a goroutine is writing to the map
 	sameSizeGrow = 8 // the current map growth is to a new map of the same size
-
-	// sentinel bucket ID for iterator checks
-	noCheck = 1<<(8*sys.PtrSize) - 1
 )
 
 // A header for a Go map.
 type hmap struct {
 	// Note: the format of the Hmap is encoded in ../../cmd/internal/gc/reflect.go and
 	// ../reflect/type.go. Don't change this structure without also changing that code!
-	count     int // # live cells == size of map.  Must be first (used by len() builtin)
-	flags     uint8
-	B         uint8  // log_2 of # of buckets (can hold up to loadFactor * 2^B items)
-	noverflow uint16 // approximate number of overflow buckets; see incrnoverflow for details
-	hash0     uint32 // hash seed
+	count int // # live cells == size of map.  Must be first (used by len() builtin)
+	flags uint8
+	B     uint8  // log_2 of # of buckets (can hold up to loadFactor * 2^B * bucketCnt items)
+	hash0 uint32 // hash seed
 
 	buckets    unsafe.Pointer // array of 2^B Buckets. may be nil if count==0.
-	oldbuckets unsafe.Pointer // previous bucket array of half the size, non-nil only when growing
+	ndeleted   uintptr        // # of deleted slots (tombstones) in buckets
+	oldbuckets unsafe.Pointer // previous bucket array, non-nil only when growing
 	nevacuate  uintptr        // progress counter for evacuation (buckets less than this have been evacuated)
-
-	extra *mapextra // optional fields
-}
-
-// mapextra holds fields that are not present on all maps.
-type mapextra struct {
-	// If both key and value do not contain pointers and are inline, then we mark bucket
-	// type as containing no pointers. This avoids scanning such maps.
-	// However, bmap.overflow is a pointer. In order to keep overflow buckets
-	// alive, we store pointers to all overflow buckets in hmap.overflow and h.map.oldoverflow.
-	// overflow and oldoverflow are only used if key and value do not contain pointers.
-	// overflow contains overflow buckets for hmap.buckets.
-	// oldoverflow contains overflow buckets for hmap.oldbuckets.
-	// The indirection allows to store a pointer to the slice in hiter.
-	overflow    *[]*bmap
-	oldoverflow *[]*bmap
-
-	// nextOverflow holds a pointer to a free overflow bucket.
-	nextOverflow *bmap
+	evacuated  unsafe.Pointer // bitmap of the evacuated buckets of oldbuckets
 }
 
 // A bucket for a Go map.
 type bmap struct {
-	// tophash generally contains the top byte of the hash value
-	// for each key in this bucket. If tophash[0] < minTopHash,
-	// tophash[0] is a bucket evacuation state instead.
-	tophash [bucketCnt]uint8
+	// ctrl holds the control byte of slot i in bits 8*i through 8*i+7.
+	// It is only accessed as a whole word, so its layout does not
+	// depend on the byte order.
+	ctrl uint64
 	// Followed by bucketCnt keys and then bucketCnt values.
 	// NOTE: packing all the keys together and then all the values together makes the
 	// code a bit more complicated than alternating key/value/key/value/... but it allows
 	// us to eliminate padding which would be needed for, e.g., map[int64]int8.
-	// Followed by an overflow pointer.
 }
 
 // A hash iteration structure.
@@ -160,16 +133,13 @@ type hiter struct {
 	t           *maptype
 	h           *hmap
 	buckets     unsafe.Pointer // bucket ptr at hash_iter initialization time
-	bptr        *bmap          // current bucket
-	overflow    *[]*bmap       // keeps overflow buckets of hmap.buckets alive
-	oldoverflow *[]*bmap       // keeps overflow buckets of hmap.oldbuckets alive
+	oldbuckets  unsafe.Pointer // old bucket ptr if the map was growing at hash_iter initialization time, nil once buckets has been walked
 	startBucket uintptr        // bucket iteration started at
+	bucket      uintptr        // current bucket
 	offset      uint8          // intra-bucket offset to start from during iteration (should be big enough to hold bucketCnt-1)
-	wrapped     bool           // already wrapped around from end of bucket array to beginning
-	B           uint8
-	i           uint8
-	bucket      uintptr
-	checkBucket uintptr
+	B           uint8          // log_2 of # of buckets at hash_iter initialization time
+	oldB        uint8          // log_2 of # of buckets of oldbuckets
+	i           uint8          // # of slots of the current bucket already visited
 }
 
 // bucketShift returns 1<<b, optimized for code generation.
@@ -185,93 +155,146 @@ func bucketMask(b uint8) uintptr {
 	return bucketShift(b) - 1
 }
 
-// tophash calculates the tophash value for hash.
+// tophash calculates the control byte of a full slot holding a key
+// with the given hash. It uses the top 7 bits of the hash, which
+// are unlikely to have been used to select the bucket.
 func tophash(hash uintptr) uint8 {
-	top := uint8(hash >> (sys.PtrSize*8 - 8))
-	if top < minTopHash {
-		top += minTopHash
+	return uint8(hash>>(sys.PtrSize*8-7)) | ctrlFull
+}
+
+// maxLoad returns the maximum number of slots of a table with 1<<B
+// buckets that may be in use, counting tombstones.
+func maxLoad(B uint8) uintptr {
+	if B == 0 {
+		return bucketCnt
 	}
-	return top
+	return bucketShift(B) * (bucketCnt * loadFactorNum / loadFactorDen)
 }
 
-func evacuated(b *bmap) bool {
-	h := b.tophash[0]
-	return h > empty && h < minTopHash
+// ctrlAt returns the control byte of slot i.
+func (b *bmap) ctrlAt(i uintptr) uint8 {
+	return uint8(b.ctrl >> ((i & (bucketCnt - 1)) * 8))
 }
 
-func (b *bmap) overflow(t *maptype) *bmap {
-	return *(**bmap)(add(unsafe.Pointer(b), uintptr(t.bucketsize)-sys.PtrSize))
+// setCtrl sets the control byte of slot i to c.
+func (b *bmap) setCtrl(i uintptr, c uint8) {
+	s := (i & (bucketCnt - 1)) * 8
+	b.ctrl = b.ctrl&^(0xff<<s) | uint64(c)<<s
 }
 
-func (b *bmap) setoverflow(t *maptype, ovf *bmap) {
-	*(**bmap)(add(unsafe.Pointer(b), uintptr(t.bucketsize)-sys.PtrSize)) = ovf
+// A bitset is a set of slots of a bucket. Slot i is in the set
+// if the high bit of byte i is set.
+type bitset uint64
+
+// first returns the lowest slot in the set, which must not be empty.
+func (s bitset) first() uintptr {
+	return uintptr(sys.Ctz64(uint64(s))) >> 3
 }
 
-func (b *bmap) keys() unsafe.Pointer {
-	return add(unsafe.Pointer(b), dataOffset)
+// removeFirst returns the set without its lowest slot.
+func (s bitset) removeFirst() bitset {
+	return s & (s - 1)
 }
 
-// incrnoverflow increments h.noverflow.
-// noverflow counts the number of overflow buckets.
-// This is used to trigger same-size map growth.
-// See also tooManyOverflowBuckets.
-// To keep hmap small, noverflow is a uint16.
-// When there are few buckets, noverflow is an exact count.
-// When there are many buckets, noverflow is an approximate count.
-func (h *hmap) incrnoverflow() {
-	// We trigger same-size map growth if there are
-	// as many overflow buckets as buckets.
-	// We need to be able to count to 1<<h.B.
-	if h.B < 16 {
-		h.noverflow++
-		return
-	}
-	// Increment with probability 1/(1<<(h.B-15)).
-	// When we reach 1<<15 - 1, we will have approximately
-	// as many overflow buckets as buckets.
-	mask := uint32(1)<<(h.B-15) - 1
-	// Example: if h.B == 18, then mask == 7,
-	// and fastrand & 7 == 0 with probability 1/8.
-	if fastrand()&mask == 0 {
-		h.noverflow++
-	}
+// match returns the set of slots whose control byte is top.
+func (b *bmap) match(top uint8) bitset {
+	// Find the zero bytes of v. Unlike the usual "has zero byte"
+	// trick, this has no false positives: the addition cannot carry
+	// from one byte into the next.
+	v := b.ctrl ^ (ctrlLSB * uint64(top))
+	t := (v & ctrlLow7) + ctrlLow7
+	return bitset(^(t | v | ctrlLow7))
 }
 
-func (h *hmap) newoverflow(t *maptype, b *bmap) *bmap {
-	var ovf *bmap
-	if h.extra != nil && h.extra.nextOverflow != nil {
-		// We have preallocated overflow buckets available.
-		// See makeBucketArray for more details.
-		ovf = h.extra.nextOverflow
-		if ovf.overflow(t) == nil {
-			// We're not at the end of the preallocated overflow buckets. Bump the pointer.
-			h.extra.nextOverflow = (*bmap)(add(unsafe.Pointer(ovf), uintptr(t.bucketsize)))
-		} else {
-			// This is the last preallocated overflow bucket.
-			// Reset the overflow pointer on this bucket,
-			// which was set to a non-nil sentinel value.
-			ovf.setoverflow(t, nil)
-			h.extra.nextOverflow = nil
-		}
-	} else {
-		ovf = (*bmap)(newobject(t.bucket))
-	}
-	h.incrnoverflow()
-	if t.bucket.kind&kindNoPointers != 0 {
-		h.createOverflow()
-		*h.extra.overflow = append(*h.extra.overflow, ovf)
+// matchEmpty returns the set of empty slots.
+func (b *bmap) matchEmpty() bitset {
+	// Find the zero bytes of b.ctrl. See match.
+	v := b.ctrl
+	t := (v & ctrlLow7) + ctrlLow7
+	return bitset(^(t | v | ctrlLow7))
+}
+
+// matchFree returns the set of empty and deleted slots.
+func (b *bmap) matchFree() bitset {
+	return bitset(^b.ctrl & ctrlMSB)
+}
+
+// matchFull returns the set of full slots.
+func (b *bmap) matchFull() bitset {
+	return bitset(b.ctrl & ctrlMSB)
+}
+
+// A probeSeq is the sequence of buckets looked at for a hash. It
+// starts at bucket hash&mask and moves on by 1, 2, 3, ... buckets
+// (triangular numbers), which visits every bucket exactly once when
+// the number of buckets is a power of two.
+type probeSeq struct {
+	mask   uintptr
+	bucket uintptr // current bucket
+	n      uintptr // # of buckets visited before the current one
+}
+
+func makeProbeSeq(hash uintptr, B uint8) probeSeq {
+	mask := bucketMask(B)
+	return probeSeq{mask: mask, bucket: hash & mask}
+}
+
+func (p probeSeq) next() probeSeq {
+	p.n++
+	p.bucket = (p.bucket + p.n) & p.mask
+	return p
+}
+
+// last reports whether p is at the last bucket of the sequence.
+func (p probeSeq) last() bool {
+	return p.n == p.mask
+}
+
+// needGrow reports whether h must grow before the free slot i of b
+// can be used. Filling an empty slot uses up room in the table, but
+// filling a tombstone does not.
+func (h *hmap) needGrow(b *bmap, i uintptr) bool {
+	return b.ctrlAt(i) == ctrlEmpty && uintptr(h.count)+h.ndeleted >= maxLoad(h.B)
+}
+
+// fillSlot marks the free slot i of b as full, with control byte top.
+func (h *hmap) fillSlot(b *bmap, i uintptr, top uint8) {
+	if b.ctrlAt(i) == ctrlDeleted {
+		h.ndeleted--
 	}
-	b.setoverflow(t, ovf)
-	return ovf
+	b.setCtrl(i, top)
 }
 
-func (h *hmap) createOverflow() {
-	if h.extra == nil {
-		h.extra = new(mapextra)
+// clearSlot marks the full slot i of b as no longer in use.
+// A bucket that has ever been full since the last rehash never has an
+// empty slot again, so if b has an empty slot, no probe sequence has
+// gone past it and the slot can be made empty too. Otherwise it must
+// become a tombstone, so that lookups keep going past b.
+func (h *hmap) clearSlot(b *bmap, i uintptr) {
+	if h.B == 0 || b.matchEmpty() != 0 {
+		b.setCtrl(i, ctrlEmpty)
+		return
 	}
-	if h.extra.overflow == nil {
-		h.extra.overflow = new([]*bmap)
+	b.setCtrl(i, ctrlDeleted)
+	h.ndeleted++
+}
+
+// growing reports whether h is growing. The growth may be to the same size or bigger.
+func (h *hmap) growing() bool {
+	return h.oldbuckets != nil
+}
+
+// oldB returns log_2 of the number of buckets of h.oldbuckets.
+func (h *hmap) oldB() uint8 {
+	if h.flags&sameSizeGrow != 0 {
+		return h.B
 	}
+	return h.B - 1
+}
+
+// bucketEvacuated reports whether the old bucket has been evacuated.
+func (h *hmap) bucketEvacuated(bucket uintptr) bool {
+	return *(*uint8)(add(h.evacuated, bucket/8))&(1<<(bucket%8)) != 0
 }
 
 func makemap64(t *maptype, hint int64, h *hmap) *hmap {
@@ -296,9 +319,9 @@ func makemap_small() *hmap {
 // If h != nil, the map can be created directly in h.
 // If h.buckets != nil, bucket pointed to can be used as the first bucket.
 func makemap(t *maptype, hint int, h *hmap) *hmap {
-	// The size of hmap should be 48 bytes on 64 bit
-	// and 28 bytes on 32 bit platforms.
-	if sz := unsafe.Sizeof(hmap{}); sz != 8+5*sys.PtrSize {
+	// The size of hmap should be 56 bytes on 64 bit
+	// and 32 bytes on 32 bit platforms.
+	if sz := unsafe.Sizeof(hmap{}); sz != 8+6*sys.PtrSize {
 		println("runtime: sizeof(hmap) =", sz, ", t.hmap.size =", t.hmap.size)
 		throw("bad hmap size")
 	}
@@ -315,7 +338,7 @@ func makemap(t *maptype, hint int, h *hmap) *hmap {
 
 	// find size parameter which will hold the requested # of elements
 	B := uint8(0)
-	for overLoadFactor(hint, B) {
+	for uintptr(hint) > maxLoad(B) {
 		B++
 	}
 	h.B = B
@@ -324,12 +347,7 @@ func makemap(t *maptype, hint int, h *hmap) *hmap {
 	// if B == 0, the buckets field is allocated lazily later (in mapassign)
 	// If hint is large zeroing this memory could take a while.
 	if h.B != 0 {
-		var nextOverflow *bmap
-		h.buckets, nextOverflow = makeBucketArray(t, h.B)
-		if nextOverflow != nil {
-			h.extra = new(mapextra)
-			h.extra.nextOverflow = nextOverflow
-		}
+		h.buckets = newarray(t.bucket, int(bucketShift(h.B)))
 	}
 
 	return h
@@ -358,24 +376,11 @@ func mapaccess1(t *maptype, h *hmap, key unsafe.Pointer) unsafe.Pointer {
 	}
 	alg := t.key.alg
 	hash := alg.hash(key, uintptr(h.hash0))
-	m := bucketMask(h.B)
-	b := (*bmap)(add(h.buckets, (hash&m)*uintptr(t.bucketsize)))
-	if c := h.oldbuckets; c != nil {
-		if !h.sameSizeGrow() {
-			// There used to be half as many buckets; mask down one more power of two.
-			m >>= 1
-		}
-		oldb := (*bmap)(add(c, (hash&m)*uintptr(t.bucketsize)))
-		if !evacuated(oldb) {
-			b = oldb
-		}
-	}
 	top := tophash(hash)
-	for ; b != nil; b = b.overflow(t) {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] != top {
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
 			if t.indirectkey {
 				k = *((*unsafe.Pointer)(k))
@@ -388,6 +393,14 @@ func mapaccess1(t *maptype, h *hmap, key unsafe.Pointer) unsafe.Pointer {
 				return v
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		if _, v := mapaccessOld(t, h, hash, key); v != nil {
+			return v
+		}
 	}
 	return unsafe.Pointer(&zeroVal[0])
 }
@@ -410,24 +423,11 @@ func mapaccess2(t *maptype, h *hmap, key unsafe.Pointer) (unsafe.Pointer, bool)
 	}
 	alg := t.key.alg
 	hash := alg.hash(key, uintptr(h.hash0))
-	m := bucketMask(h.B)
-	b := (*bmap)(unsafe.Pointer(uintptr(h.buckets) + (hash&m)*uintptr(t.bucketsize)))
-	if c := h.oldbuckets; c != nil {
-		if !h.sameSizeGrow() {
-			// There used to be half as many buckets; mask down one more power of two.
-			m >>= 1
-		}
-		oldb := (*bmap)(unsafe.Pointer(uintptr(c) + (hash&m)*uintptr(t.bucketsize)))
-		if !evacuated(oldb) {
-			b = oldb
-		}
-	}
 	top := tophash(hash)
-	for ; b != nil; b = b.overflow(t) {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] != top {
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
 			if t.indirectkey {
 				k = *((*unsafe.Pointer)(k))
@@ -440,6 +440,14 @@ func mapaccess2(t *maptype, h *hmap, key unsafe.Pointer) (unsafe.Pointer, bool)
 				return v, true
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		if _, v := mapaccessOld(t, h, hash, key); v != nil {
+			return v, true
+		}
 	}
 	return unsafe.Pointer(&zeroVal[0]), false
 }
@@ -451,24 +459,11 @@ func mapaccessK(t *maptype, h *hmap, key unsafe.Pointer) (unsafe.Pointer, unsafe
 	}
 	alg := t.key.alg
 	hash := alg.hash(key, uintptr(h.hash0))
-	m := bucketMask(h.B)
-	b := (*bmap)(unsafe.Pointer(uintptr(h.buckets) + (hash&m)*uintptr(t.bucketsize)))
-	if c := h.oldbuckets; c != nil {
-		if !h.sameSizeGrow() {
-			// There used to be half as many buckets; mask down one more power of two.
-			m >>= 1
-		}
-		oldb := (*bmap)(unsafe.Pointer(uintptr(c) + (hash&m)*uintptr(t.bucketsize)))
-		if !evacuated(oldb) {
-			b = oldb
-		}
-	}
 	top := tophash(hash)
-	for ; b != nil; b = b.overflow(t) {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] != top {
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
 			if t.indirectkey {
 				k = *((*unsafe.Pointer)(k))
@@ -481,10 +476,35 @@ func mapaccessK(t *maptype, h *hmap, key unsafe.Pointer) (unsafe.Pointer, unsafe
 				return k, v
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		return mapaccessOld(t, h, hash, key)
 	}
 	return nil, nil
 }
 
+// mapaccessOld looks key up in the old bucket array of the growing
+// map h, after it was not found in the new one. It returns the key
+// and value, or nil if key is not in the map.
+func mapaccessOld(t *maptype, h *hmap, hash uintptr, key unsafe.Pointer) (unsafe.Pointer, unsafe.Pointer) {
+	bucket, b, i := findSlot(t, h.oldbuckets, h.oldB(), hash, key)
+	if b == nil || h.bucketEvacuated(bucket) {
+		return nil, nil
+	}
+	k := add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
+	if t.indirectkey {
+		k = *((*unsafe.Pointer)(k))
+	}
+	v := add(unsafe.Pointer(b), dataOffset+bucketCnt*uintptr(t.keysize)+i*uintptr(t.valuesize))
+	if t.indirectvalue {
+		v = *((*unsafe.Pointer)(v))
+	}
+	return k, v
+}
+
 func mapaccess1_fat(t *maptype, h *hmap, key, zero unsafe.Pointer) unsafe.Pointer {
 	v := mapaccess1(t, h, key)
 	if v == unsafe.Pointer(&zeroVal[0]) {
@@ -530,26 +550,19 @@ func mapassign(t *maptype, h *hmap, key unsafe.Pointer) unsafe.Pointer {
 	}
 
 again:
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(unsafe.Pointer(uintptr(h.buckets) + bucket*uintptr(t.bucketsize)))
 	top := tophash(hash)
 
-	var inserti *uint8
+	var insertb *bmap
+	var inserti uintptr
 	var insertk unsafe.Pointer
 	var val unsafe.Pointer
-	for {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] != top {
-				if b.tophash[i] == empty && inserti == nil {
-					inserti = &b.tophash[i]
-					insertk = add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
-					val = add(unsafe.Pointer(b), dataOffset+bucketCnt*uintptr(t.keysize)+i*uintptr(t.valuesize))
-				}
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
 			if t.indirectkey {
 				k = *((*unsafe.Pointer)(k))
@@ -564,30 +577,34 @@ again:
 			val = add(unsafe.Pointer(b), dataOffset+bucketCnt*uintptr(t.keysize)+i*uintptr(t.valuesize))
 			goto done
 		}
-		ovf := b.overflow(t)
-		if ovf == nil {
+		if insertb == nil {
+			if m := b.matchFree(); m != 0 {
+				insertb = b
+				inserti = m.first()
+			}
+		}
+		if b.matchEmpty() != 0 || p.last() {
 			break
 		}
-		b = ovf
 	}
 
 	// Did not find mapping for key. Allocate new cell & add entry.
 
-	// If we hit the max load factor or we have too many overflow buckets,
-	// and we're not already in the middle of growing, start growing.
-	if !h.growing() && (overLoadFactor(h.count+1, h.B) || tooManyOverflowBuckets(h.noverflow, h.B)) {
-		hashGrow(t, h)
+	// If there is no free slot, or we hit the max load factor and
+	// would fill an empty slot, grow the table.
+	if insertb == nil || h.needGrow(insertb, inserti) {
+		growOrFinish(t, h)
 		goto again // Growing the table invalidates everything, so try again
 	}
-
-	if inserti == nil {
-		// all current buckets are full, allocate a new one.
-		newb := h.newoverflow(t, b)
-		inserti = &newb.tophash[0]
-		insertk = add(unsafe.Pointer(newb), dataOffset)
-		val = add(insertk, bucketCnt*uintptr(t.keysize))
+	if top == ctrlNaN && !t.reflexivekey && !alg.equal(key, key) {
+		// ctrlNaN marks the NaNs copied by evacuate.
+		// This NaN is new, and can never be looked up anyway.
+		top--
 	}
 
+	insertk = add(unsafe.Pointer(insertb), dataOffset+inserti*uintptr(t.keysize))
+	val = add(unsafe.Pointer(insertb), dataOffset+bucketCnt*uintptr(t.keysize)+inserti*uintptr(t.valuesize))
+
 	// store new key/value at insert position
 	if t.indirectkey {
 		kmem := newobject(t.key)
@@ -599,7 +616,7 @@ again:
 		*(*unsafe.Pointer)(val) = vmem
 	}
 	typedmemmove(t.key, insertk, key)
-	*inserti = top
+	h.fillSlot(insertb, inserti, top)
 	h.count++
 
 done:
@@ -637,18 +654,15 @@ func mapdelete(t *maptype, h *hmap, key unsafe.Pointer) {
 	// in which case we have not actually done a write (delete).
 	h.flags |= hashWriting
 
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(add(h.buckets, bucket*uintptr(t.bucketsize)))
 	top := tophash(hash)
 search:
-	for ; b != nil; b = b.overflow(t) {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] != top {
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
 			k2 := k
 			if t.indirectkey {
@@ -672,10 +686,13 @@ search:
 					memclrHasPointers(v, t.elem.size)
 				}
 			}
-			b.tophash[i] = empty
+			h.clearSlot(b, i)
 			h.count--
 			break search
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
 	}
 
 	if h.flags&hashWriting == 0 {
@@ -698,23 +715,20 @@ func mapiterinit(t *maptype, h *hmap, it *hiter) {
 		return
 	}
 
-	if unsafe.Sizeof(hiter{})/sys.PtrSize != 12 {
+	if unsafe.Sizeof(hiter{})/sys.PtrSize != 9 {
 		throw("hash_iter size incorrect") // see ../../cmd/internal/gc/reflect.go
 	}
 	it.t = t
 	it.h = h
 
-	// grab snapshot of bucket state
+	// grab snapshot of bucket state.
+	// The bucket array is never written again once the map has
+	// been rehashed, so the snapshot stays valid.
 	it.B = h.B
 	it.buckets = h.buckets
-	if t.bucket.kind&kindNoPointers != 0 {
-		// Allocate the current slice and remember pointers to both current and old.
-		// This preserves all relevant overflow buckets alive even if
-		// the table grows and/or overflow buckets are added to the table
-		// while we are iterating.
-		h.createOverflow()
-		it.overflow = h.extra.overflow
-		it.oldoverflow = h.extra.oldoverflow
+	if h.growing() {
+		it.oldB = h.oldB()
+		it.oldbuckets = h.oldbuckets
 	}
 
 	// decide where to start
@@ -728,12 +742,6 @@ func mapiterinit(t *maptype, h *hmap, it *hiter) {
 	// iterator state
 	it.bucket = it.startBucket
 
-	// Remember we have an iterator.
-	// Can run concurrently with another mapiterinit().
-	if old := h.flags; old&(iterator|oldIterator) != iterator|oldIterator {
-		atomic.Or8(&h.flags, iterator|oldIterator)
-	}
-
 	mapiternext(it)
 }
 
@@ -748,83 +756,56 @@ func mapiternext(it *hiter) {
 	}
 	t := it.t
 	bucket := it.bucket
-	b := it.bptr
-	i := it.i
-	checkBucket := it.checkBucket
+	i := uintptr(it.i)
 	alg := t.key.alg
 
-next:
-	if b == nil {
-		if bucket == it.startBucket && it.wrapped {
-			// end of iteration
-			it.key = nil
-			it.value = nil
-			return
-		}
-		if h.growing() && it.B == h.B {
-			// Iterator was started in the middle of a grow, and the grow isn't done yet.
-			// If the bucket we're looking at hasn't been filled in yet (i.e. the old
-			// bucket hasn't been evacuated) then we need to iterate through the old
-			// bucket and only return the ones that will be migrated to this bucket.
-			oldbucket := bucket & it.h.oldbucketmask()
-			b = (*bmap)(add(h.oldbuckets, oldbucket*uintptr(t.bucketsize)))
-			if !evacuated(b) {
-				checkBucket = bucket
-			} else {
-				b = (*bmap)(add(it.buckets, bucket*uintptr(t.bucketsize)))
-				checkBucket = noCheck
+	for {
+		if i == bucketCnt {
+			bucket = (bucket + 1) & bucketMask(it.B)
+			if bucket == it.startBucket {
+				if it.oldbuckets == nil {
+					// end of iteration
+					it.key = nil
+					it.value = nil
+					return
+				}
+				// Done with the new array of a map that was
+				// growing when the iteration started. Walk
+				// the old one.
+				it.buckets = it.oldbuckets
+				it.B = it.oldB
+				it.oldbuckets = nil
+				it.startBucket &= bucketMask(it.B)
+				bucket = it.startBucket
 			}
-		} else {
-			b = (*bmap)(add(it.buckets, bucket*uintptr(t.bucketsize)))
-			checkBucket = noCheck
-		}
-		bucket++
-		if bucket == bucketShift(it.B) {
-			bucket = 0
-			it.wrapped = true
+			i = 0
 		}
-		i = 0
-	}
-	for ; i < bucketCnt; i++ {
-		offi := (i + it.offset) & (bucketCnt - 1)
-		if b.tophash[offi] == empty || b.tophash[offi] == evacuatedEmpty {
+		b := (*bmap)(add(it.buckets, bucket*uintptr(t.bucketsize)))
+		offi := (i + uintptr(it.offset)) & (bucketCnt - 1)
+		i++
+		if b.ctrlAt(offi)&ctrlFull == 0 {
 			continue
 		}
-		k := add(unsafe.Pointer(b), dataOffset+uintptr(offi)*uintptr(t.keysize))
+		k := add(unsafe.Pointer(b), dataOffset+offi*uintptr(t.keysize))
 		if t.indirectkey {
 			k = *((*unsafe.Pointer)(k))
 		}
-		v := add(unsafe.Pointer(b), dataOffset+bucketCnt*uintptr(t.keysize)+uintptr(offi)*uintptr(t.valuesize))
-		if checkBucket != noCheck && !h.sameSizeGrow() {
-			// Special case: iterator was started during a grow to a larger size
-			// and the grow is not done yet. We're working on a bucket whose
-			// oldbucket has not been evacuated yet. Or at least, it wasn't
-			// evacuated when we started the bucket. So we're iterating
-			// through the oldbucket, skipping any keys that will go
-			// to the other new bucket (each oldbucket expands to two
-			// buckets during a grow).
+		if it.oldbuckets != nil {
+			// The key is in the new array of a map that was
+			// growing when the iteration started. If it was
+			// evacuated from the old array, it is returned
+			// when walking the old array instead.
 			if t.reflexivekey || alg.equal(k, k) {
-				// If the item in the oldbucket is not destined for
-				// the current new bucket in the iteration, skip it.
 				hash := alg.hash(k, uintptr(h.hash0))
-				if hash&bucketMask(it.B) != checkBucket {
-					continue
-				}
-			} else {
-				// Hash isn't repeatable if k != k (NaNs).  We need a
-				// repeatable and randomish choice of which direction
-				// to send NaNs during evacuation. We'll use the low
-				// bit of tophash to decide which way NaNs go.
-				// NOTE: this case is why we need two evacuate tophash
-				// values, evacuatedX and evacuatedY, that differ in
-				// their low bit.
-				if checkBucket>>(it.B-1) != uintptr(b.tophash[offi]&1) {
+				if _, ob, _ := findSlot(t, it.oldbuckets, it.oldB, hash, k); ob != nil {
 					continue
 				}
+			} else if b.ctrlAt(offi) == ctrlNaN {
+				continue
 			}
 		}
-		if (b.tophash[offi] != evacuatedX && b.tophash[offi] != evacuatedY) ||
-			!(t.reflexivekey || alg.equal(k, k)) {
+		v := add(unsafe.Pointer(b), dataOffset+bucketCnt*uintptr(t.keysize)+offi*uintptr(t.valuesize))
+		if it.buckets == h.buckets || !(t.reflexivekey || alg.equal(k, k)) {
 			// This is the golden data, we can return it.
 			// OR
 			// key!=key, so the entry can't be deleted or updated, so we can just return it.
@@ -835,7 +816,7 @@ next:
 			}
 			it.value = v
 		} else {
-			// The hash table has grown since the iterator was started.
+			// The hash table has been rehashed since the iterator was started.
 			// The golden data for this key is now somewhere else.
 			// Check the current hash table for the data.
 			// This code handles the case where the key
@@ -850,297 +831,179 @@ next:
 			it.value = rv
 		}
 		it.bucket = bucket
-		if it.bptr != b { // avoid unnecessary write barrier; see issue 14921
-			it.bptr = b
-		}
-		it.i = i + 1
-		it.checkBucket = checkBucket
+		it.i = uint8(i)
 		return
 	}
-	b = b.overflow(t)
-	i = 0
-	goto next
-}
-
-func makeBucketArray(t *maptype, b uint8) (buckets unsafe.Pointer, nextOverflow *bmap) {
-	base := bucketShift(b)
-	nbuckets := base
-	// For small b, overflow buckets are unlikely.
-	// Avoid the overhead of the calculation.
-	if b >= 4 {
-		// Add on the estimated number of overflow buckets
-		// required to insert the median number of elements
-		// used with this value of b.
-		nbuckets += bucketShift(b - 4)
-		sz := t.bucket.size * nbuckets
-		up := roundupsize(sz)
-		if up != sz {
-			nbuckets = up / t.bucket.size
-		}
-	}
-	buckets = newarray(t.bucket, int(nbuckets))
-	if base != nbuckets {
-		// We preallocated some overflow buckets.
-		// To keep the overhead of tracking these overflow buckets to a minimum,
-		// we use the convention that if a preallocated overflow bucket's overflow
-		// pointer is nil, then there are more available by bumping the pointer.
-		// We need a safe non-nil pointer for the last overflow bucket; just use buckets.
-		nextOverflow = (*bmap)(add(buckets, base*uintptr(t.bucketsize)))
-		last := (*bmap)(add(buckets, (nbuckets-1)*uintptr(t.bucketsize)))
-		last.setoverflow(t, (*bmap)(buckets))
-	}
-	return buckets, nextOverflow
 }
 
+// hashGrow starts the growth of h into a new bucket array.
+// If the live entries take up at most 25/32 of the slots, the table
+// is full of tombstones, and the new array has the same size.
+// Otherwise it is twice as big.
+// The actual copying of the entries is done incrementally by
+// growWork() and evacuate().
 func hashGrow(t *maptype, h *hmap) {
-	// If we've hit the load factor, get bigger.
-	// Otherwise, there are too many overflow buckets,
-	// so keep the same number of buckets and "grow" laterally.
-	bigger := uint8(1)
-	if !overLoadFactor(h.count+1, h.B) {
-		bigger = 0
-		h.flags |= sameSizeGrow
+	newB := h.B
+	flags := h.flags &^ sameSizeGrow
+	if uintptr(h.count)*32 > bucketShift(h.B)*bucketCnt*25 {
+		newB++
+	} else {
+		flags |= sameSizeGrow
 	}
 	oldbuckets := h.buckets
-	newbuckets, nextOverflow := makeBucketArray(t, h.B+bigger)
+	newbuckets := newarray(t.bucket, int(bucketShift(newB)))
+	evacuated := mallocgc((bucketShift(h.B)+7)/8, nil, true)
 
-	flags := h.flags &^ (iterator | oldIterator)
-	if h.flags&iterator != 0 {
-		flags |= oldIterator
-	}
 	// commit the grow (atomic wrt gc)
-	h.B += bigger
+	h.B = newB
 	h.flags = flags
 	h.oldbuckets = oldbuckets
 	h.buckets = newbuckets
+	h.ndeleted = 0
 	h.nevacuate = 0
-	h.noverflow = 0
+	h.evacuated = evacuated
+}
 
-	if h.extra != nil && h.extra.overflow != nil {
-		// Promote current overflow buckets to the old generation.
-		if h.extra.oldoverflow != nil {
-			throw("oldoverflow is not nil")
-		}
-		h.extra.oldoverflow = h.extra.overflow
-		h.extra.overflow = nil
+// growOrFinish makes room in h when a write finds no room for its
+// key. It starts a growth, or finishes the growth in progress if there
+// is one, in which case the write will start the next growth when it
+// tries again. growWork evacuates old buckets fast enough for a growth
+// to be finished long before the new array fills up, so the latter
+// should not happen.
+func growOrFinish(t *maptype, h *hmap) {
+	if !h.growing() {
+		hashGrow(t, h)
+		return
 	}
-	if nextOverflow != nil {
-		if h.extra == nil {
-			h.extra = new(mapextra)
-		}
-		h.extra.nextOverflow = nextOverflow
+	for h.growing() {
+		evacuate(t, h, h.nevacuate)
 	}
-
-	// the actual copying of the hash table data is done incrementally
-	// by growWork() and evacuate().
-}
-
-// overLoadFactor reports whether count items placed in 1<<B buckets is over loadFactor.
-func overLoadFactor(count int, B uint8) bool {
-	return count > bucketCnt && uintptr(count) > loadFactorNum*(bucketShift(B)/loadFactorDen)
-}
-
-// tooManyOverflowBuckets reports whether noverflow buckets is too many for a map with 1<<B buckets.
-// Note that most of these overflow buckets must be in sparse use;
-// if use was dense, then we'd have already triggered regular map growth.
-func tooManyOverflowBuckets(noverflow uint16, B uint8) bool {
-	// If the threshold is too low, we do extraneous work.
-	// If the threshold is too high, maps that grow and shrink can hold on to lots of unused memory.
-	// "too many" means (approximately) as many overflow buckets as regular buckets.
-	// See incrnoverflow for more details.
-	if B > 15 {
-		B = 15
-	}
-	// The compiler doesn't see here that B < 16; mask B to generate shorter shift code.
-	return noverflow >= uint16(1)<<(B&15)
-}
-
-// growing reports whether h is growing. The growth may be to the same size or bigger.
-func (h *hmap) growing() bool {
-	return h.oldbuckets != nil
-}
-
-// sameSizeGrow reports whether the current growth is to a map of the same size.
-func (h *hmap) sameSizeGrow() bool {
-	return h.flags&sameSizeGrow != 0
 }
 
-// noldbuckets calculates the number of buckets prior to the current map growth.
-func (h *hmap) noldbuckets() uintptr {
-	oldB := h.B
-	if !h.sameSizeGrow() {
-		oldB--
+// growWork does some of the work of the growth in progress before a
+// write of the key with the given hash. It evacuates the old buckets
+// that a lookup of the key would look at, so that the write only has
+// to deal with the new array, and then two more buckets in order.
+// Two buckets per write are enough for a growth to the same size to
+// finish before the new array needs to grow again.
+func growWork(t *maptype, h *hmap, hash uintptr) {
+	oldbuckets := h.oldbuckets
+	for p := makeProbeSeq(hash, h.oldB()); h.growing(); p = p.next() {
+		b := (*bmap)(add(oldbuckets, p.bucket*uintptr(t.bucketsize)))
+		evacuate(t, h, p.bucket)
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
 	}
-	return bucketShift(oldB)
-}
-
-// oldbucketmask provides a mask that can be applied to calculate n % noldbuckets().
-func (h *hmap) oldbucketmask() uintptr {
-	return h.noldbuckets() - 1
-}
-
-func growWork(t *maptype, h *hmap, bucket uintptr) {
-	// make sure we evacuate the oldbucket corresponding
-	// to the bucket we're about to use
-	evacuate(t, h, bucket&h.oldbucketmask())
-
-	// evacuate one more oldbucket to make progress on growing
-	if h.growing() {
+	for n := 0; n < 2 && h.growing(); n++ {
 		evacuate(t, h, h.nevacuate)
 	}
 }
 
-func bucketEvacuated(t *maptype, h *hmap, bucket uintptr) bool {
-	b := (*bmap)(add(h.oldbuckets, bucket*uintptr(t.bucketsize)))
-	return evacuated(b)
-}
-
-// evacDst is an evacuation destination.
-type evacDst struct {
-	b *bmap          // current destination bucket
-	i int            // key/val index into b
-	k unsafe.Pointer // pointer to current key storage
-	v unsafe.Pointer // pointer to current value storage
-}
-
-func evacuate(t *maptype, h *hmap, oldbucket uintptr) {
-	b := (*bmap)(add(h.oldbuckets, oldbucket*uintptr(t.bucketsize)))
-	newbit := h.noldbuckets()
-	if !evacuated(b) {
-		// TODO: reuse overflow buckets instead of using new ones, if there
-		// is no iterator using the old buckets.  (If !oldIterator.)
-
-		// xy contains the x and y (low and high) evacuation destinations.
-		var xy [2]evacDst
-		x := &xy[0]
-		x.b = (*bmap)(add(h.buckets, oldbucket*uintptr(t.bucketsize)))
-		x.k = add(unsafe.Pointer(x.b), dataOffset)
-		x.v = add(x.k, bucketCnt*uintptr(t.keysize))
-
-		if !h.sameSizeGrow() {
-			// Only calculate y pointers if we're growing bigger.
-			// Otherwise GC can see bad pointers.
-			y := &xy[1]
-			y.b = (*bmap)(add(h.buckets, (oldbucket+newbit)*uintptr(t.bucketsize)))
-			y.k = add(unsafe.Pointer(y.b), dataOffset)
-			y.v = add(y.k, bucketCnt*uintptr(t.keysize))
-		}
-
-		for ; b != nil; b = b.overflow(t) {
-			k := add(unsafe.Pointer(b), dataOffset)
-			v := add(k, bucketCnt*uintptr(t.keysize))
-			for i := 0; i < bucketCnt; i, k, v = i+1, add(k, uintptr(t.keysize)), add(v, uintptr(t.valuesize)) {
-				top := b.tophash[i]
-				if top == empty {
-					b.tophash[i] = evacuatedEmpty
-					continue
-				}
-				if top < minTopHash {
-					throw("bad map state")
-				}
-				k2 := k
-				if t.indirectkey {
-					k2 = *((*unsafe.Pointer)(k2))
-				}
-				var useY uint8
-				if !h.sameSizeGrow() {
-					// Compute hash to make our evacuation decision (whether we need
-					// to send this key/value to bucket x or bucket y).
-					hash := t.key.alg.hash(k2, uintptr(h.hash0))
-					if h.flags&iterator != 0 && !t.reflexivekey && !t.key.alg.equal(k2, k2) {
-						// If key != key (NaNs), then the hash could be (and probably
-						// will be) entirely different from the old hash. Moreover,
-						// it isn't reproducible. Reproducibility is required in the
-						// presence of iterators, as our evacuation decision must
-						// match whatever decision the iterator made.
-						// Fortunately, we have the freedom to send these keys either
-						// way. Also, tophash is meaningless for these kinds of keys.
-						// We let the low bit of tophash drive the evacuation decision.
-						// We recompute a new random tophash for the next level so
-						// these keys will get evenly distributed across all buckets
-						// after multiple grows.
-						useY = top & 1
-						top = tophash(hash)
-					} else {
-						if hash&newbit != 0 {
-							useY = 1
-						}
-					}
-				}
-
-				if evacuatedX+1 != evacuatedY {
-					throw("bad evacuatedN")
-				}
-
-				b.tophash[i] = evacuatedX + useY // evacuatedX + 1 == evacuatedY
-				dst := &xy[useY]                 // evacuation destination
-
-				if dst.i == bucketCnt {
-					dst.b = h.newoverflow(t, dst.b)
-					dst.i = 0
-					dst.k = add(unsafe.Pointer(dst.b), dataOffset)
-					dst.v = add(dst.k, bucketCnt*uintptr(t.keysize))
-				}
-				dst.b.tophash[dst.i&(bucketCnt-1)] = top // mask dst.i as an optimization, to avoid a bounds check
-				if t.indirectkey {
-					*(*unsafe.Pointer)(dst.k) = k2 // copy pointer
-				} else {
-					typedmemmove(t.key, dst.k, k) // copy value
-				}
-				if t.indirectvalue {
-					*(*unsafe.Pointer)(dst.v) = *(*unsafe.Pointer)(v)
-				} else {
-					typedmemmove(t.elem, dst.v, v)
-				}
-				dst.i++
-				// These updates might push these pointers past the end of the
-				// key or value arrays.  That's ok, as we have the overflow pointer
-				// at the end of the bucket to protect against pointing past the
-				// end of the bucket.
-				dst.k = add(dst.k, uintptr(t.keysize))
-				dst.v = add(dst.v, uintptr(t.valuesize))
+// evacuate copies the entries of the old bucket to the new array,
+// unless that has been done already.
+func evacuate(t *maptype, h *hmap, bucket uintptr) {
+	if !h.bucketEvacuated(bucket) {
+		b := (*bmap)(add(h.oldbuckets, bucket*uintptr(t.bucketsize)))
+		for m := b.matchFull(); m != 0; m = m.removeFirst() {
+			i := m.first()
+			k := add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
+			v := add(unsafe.Pointer(b), dataOffset+bucketCnt*uintptr(t.keysize)+i*uintptr(t.valuesize))
+			k2 := k
+			if t.indirectkey {
+				k2 = *((*unsafe.Pointer)(k2))
+			}
+			// If key != key (NaNs), the hash is random, and the
+			// key may go to any bucket. That is fine, as such
+			// keys can never be looked up anyway. Mark the slot
+			// so that iterators can tell the copy from NaNs
+			// inserted into the new array.
+			hash := t.key.alg.hash(k2, uintptr(h.hash0))
+			top := tophash(hash)
+			if !t.reflexivekey && !t.key.alg.equal(k2, k2) {
+				top = ctrlNaN
+			}
+			dst, j := freeSlot(t, h.buckets, h.B, hash)
+			h.fillSlot(dst, j, top)
+			dk := add(unsafe.Pointer(dst), dataOffset+j*uintptr(t.keysize))
+			dv := add(unsafe.Pointer(dst), dataOffset+bucketCnt*uintptr(t.keysize)+j*uintptr(t.valuesize))
+			if t.indirectkey {
+				*(*unsafe.Pointer)(dk) = k2 // copy pointer
+			} else {
+				typedmemmove(t.key, dk, k) // copy value
+			}
+			if t.indirectvalue {
+				*(*unsafe.Pointer)(dv) = *(*unsafe.Pointer)(v)
+			} else {
+				typedmemmove(t.elem, dv, v)
 			}
 		}
-		// Unlink the overflow buckets & clear key/value to help GC.
-		if h.flags&oldIterator == 0 && t.bucket.kind&kindNoPointers == 0 {
-			b := add(h.oldbuckets, oldbucket*uintptr(t.bucketsize))
-			// Preserve b.tophash because the evacuation
-			// state is maintained there.
-			ptr := add(b, dataOffset)
-			n := uintptr(t.bucketsize) - dataOffset
-			memclrHasPointers(ptr, n)
-		}
+		*(*uint8)(add(h.evacuated, bucket/8)) |= 1 << (bucket % 8)
 	}
 
-	if oldbucket == h.nevacuate {
-		advanceEvacuationMark(h, t, newbit)
+	if bucket == h.nevacuate {
+		advanceEvacuationMark(h)
 	}
 }
 
-func advanceEvacuationMark(h *hmap, t *maptype, newbit uintptr) {
+func advanceEvacuationMark(h *hmap) {
 	h.nevacuate++
+	// Skip over the buckets that writes have evacuated out of order.
 	// Experiments suggest that 1024 is overkill by at least an order of magnitude.
 	// Put it in there as a safeguard anyway, to ensure O(1) behavior.
+	newbit := bucketShift(h.oldB())
 	stop := h.nevacuate + 1024
 	if stop > newbit {
 		stop = newbit
 	}
-	for h.nevacuate != stop && bucketEvacuated(t, h, h.nevacuate) {
+	for h.nevacuate != stop && h.bucketEvacuated(h.nevacuate) {
 		h.nevacuate++
 	}
 	if h.nevacuate == newbit { // newbit == # of oldbuckets
 		// Growing is all done. Free old main bucket array.
 		h.oldbuckets = nil
-		// Can discard old overflow buckets as well.
-		// If they are still referenced by an iterator,
-		// then the iterator holds a pointers to the slice.
-		if h.extra != nil {
-			h.extra.oldoverflow = nil
-		}
+		h.evacuated = nil
 		h.flags &^= sameSizeGrow
 	}
 }
 
+// findSlot returns the bucket and slot of the full slot holding key in
+// a bucket array with 1<<B buckets, or a nil *bmap if there is none.
+func findSlot(t *maptype, buckets unsafe.Pointer, B uint8, hash uintptr, key unsafe.Pointer) (uintptr, *bmap, uintptr) {
+	alg := t.key.alg
+	top := tophash(hash)
+	for p := makeProbeSeq(hash, B); ; p = p.next() {
+		b := (*bmap)(add(buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			k := add(unsafe.Pointer(b), dataOffset+i*uintptr(t.keysize))
+			if t.indirectkey {
+				k = *((*unsafe.Pointer)(k))
+			}
+			if alg.equal(key, k) {
+				return p.bucket, b, i
+			}
+		}
+		if b.matchEmpty() != 0 || p.last() {
+			return 0, nil, 0
+		}
+	}
+}
+
+// freeSlot returns the first free slot on the probe sequence for hash
+// in a bucket array with 1<<B buckets.
+func freeSlot(t *maptype, buckets unsafe.Pointer, B uint8, hash uintptr) (*bmap, uintptr) {
+	for p := makeProbeSeq(hash, B); ; p = p.next() {
+		b := (*bmap)(add(buckets, p.bucket*uintptr(t.bucketsize)))
+		if m := b.matchFree(); m != 0 {
+			return b, m.first()
+		}
+		if p.last() {
+			throw("bad map state")
+		}
+	}
+}
+
 func ismapkey(t *_type) bool {
 	return t.alg.hash != nil
 }
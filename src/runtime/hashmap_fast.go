@@ -20,31 +20,34 @@ func mapaccess1_fast32(t *maptype, h *hmap, key uint32) unsafe.Pointer {
 	if h.flags&hashWriting != 0 {
 		throw("concurrent map read and map write")
 	}
-	var b *bmap
 	if h.B == 0 {
 		// One-bucket table. No need to hash.
-		b = (*bmap)(h.buckets)
-	} else {
-		hash := t.key.alg.hash(noescape(unsafe.Pointer(&key)), uintptr(h.hash0))
-		m := bucketMask(h.B)
-		b = (*bmap)(add(h.buckets, (hash&m)*uintptr(t.bucketsize)))
-		if c := h.oldbuckets; c != nil {
-			if !h.sameSizeGrow() {
-				// There used to be half as many buckets; mask down one more power of two.
-				m >>= 1
-			}
-			oldb := (*bmap)(add(c, (hash&m)*uintptr(t.bucketsize)))
-			if !evacuated(oldb) {
-				b = oldb
+		b := (*bmap)(h.buckets)
+		for i, k := uintptr(0), add(unsafe.Pointer(b), dataOffset); i < bucketCnt; i, k = i+1, add(k, 4) {
+			if *(*uint32)(k) == key && b.ctrlAt(i)&ctrlFull != 0 {
+				return add(unsafe.Pointer(b), dataOffset+bucketCnt*4+i*uintptr(t.valuesize))
 			}
 		}
+		return unsafe.Pointer(&zeroVal[0])
 	}
-	for ; b != nil; b = b.overflow(t) {
-		for i, k := uintptr(0), b.keys(); i < bucketCnt; i, k = i+1, add(k, 4) {
-			if *(*uint32)(k) == key && b.tophash[i] != empty {
+	hash := t.key.alg.hash(noescape(unsafe.Pointer(&key)), uintptr(h.hash0))
+	top := tophash(hash)
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			if *(*uint32)(add(unsafe.Pointer(b), dataOffset+i*4)) == key {
 				return add(unsafe.Pointer(b), dataOffset+bucketCnt*4+i*uintptr(t.valuesize))
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		if _, v := mapaccessOld(t, h, hash, noescape(unsafe.Pointer(&key))); v != nil {
+			return v
+		}
 	}
 	return unsafe.Pointer(&zeroVal[0])
 }
@@ -60,31 +63,34 @@ func mapaccess2_fast32(t *maptype, h *hmap, key uint32) (unsafe.Pointer, bool) {
 	if h.flags&hashWriting != 0 {
 		throw("concurrent map read and map write")
 	}
-	var b *bmap
 	if h.B == 0 {
 		// One-bucket table. No need to hash.
-		b = (*bmap)(h.buckets)
-	} else {
-		hash := t.key.alg.hash(noescape(unsafe.Pointer(&key)), uintptr(h.hash0))
-		m := bucketMask(h.B)
-		b = (*bmap)(add(h.buckets, (hash&m)*uintptr(t.bucketsize)))
-		if c := h.oldbuckets; c != nil {
-			if !h.sameSizeGrow() {
-				// There used to be half as many buckets; mask down one more power of two.
-				m >>= 1
-			}
-			oldb := (*bmap)(add(c, (hash&m)*uintptr(t.bucketsize)))
-			if !evacuated(oldb) {
-				b = oldb
+		b := (*bmap)(h.buckets)
+		for i, k := uintptr(0), add(unsafe.Pointer(b), dataOffset); i < bucketCnt; i, k = i+1, add(k, 4) {
+			if *(*uint32)(k) == key && b.ctrlAt(i)&ctrlFull != 0 {
+				return add(unsafe.Pointer(b), dataOffset+bucketCnt*4+i*uintptr(t.valuesize)), true
 			}
 		}
+		return unsafe.Pointer(&zeroVal[0]), false
 	}
-	for ; b != nil; b = b.overflow(t) {
-		for i, k := uintptr(0), b.keys(); i < bucketCnt; i, k = i+1, add(k, 4) {
-			if *(*uint32)(k) == key && b.tophash[i] != empty {
+	hash := t.key.alg.hash(noescape(unsafe.Pointer(&key)), uintptr(h.hash0))
+	top := tophash(hash)
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			if *(*uint32)(add(unsafe.Pointer(b), dataOffset+i*4)) == key {
 				return add(unsafe.Pointer(b), dataOffset+bucketCnt*4+i*uintptr(t.valuesize)), true
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		if _, v := mapaccessOld(t, h, hash, noescape(unsafe.Pointer(&key))); v != nil {
+			return v, true
+		}
 	}
 	return unsafe.Pointer(&zeroVal[0]), false
 }
@@ -100,31 +106,34 @@ func mapaccess1_fast64(t *maptype, h *hmap, key uint64) unsafe.Pointer {
 	if h.flags&hashWriting != 0 {
 		throw("concurrent map read and map write")
 	}
-	var b *bmap
 	if h.B == 0 {
 		// One-bucket table. No need to hash.
-		b = (*bmap)(h.buckets)
-	} else {
-		hash := t.key.alg.hash(noescape(unsafe.Pointer(&key)), uintptr(h.hash0))
-		m := bucketMask(h.B)
-		b = (*bmap)(add(h.buckets, (hash&m)*uintptr(t.bucketsize)))
-		if c := h.oldbuckets; c != nil {
-			if !h.sameSizeGrow() {
-				// There used to be half as many buckets; mask down one more power of two.
-				m >>= 1
-			}
-			oldb := (*bmap)(add(c, (hash&m)*uintptr(t.bucketsize)))
-			if !evacuated(oldb) {
-				b = oldb
+		b := (*bmap)(h.buckets)
+		for i, k := uintptr(0), add(unsafe.Pointer(b), dataOffset); i < bucketCnt; i, k = i+1, add(k, 8) {
+			if *(*uint64)(k) == key && b.ctrlAt(i)&ctrlFull != 0 {
+				return add(unsafe.Pointer(b), dataOffset+bucketCnt*8+i*uintptr(t.valuesize))
 			}
 		}
+		return unsafe.Pointer(&zeroVal[0])
 	}
-	for ; b != nil; b = b.overflow(t) {
-		for i, k := uintptr(0), b.keys(); i < bucketCnt; i, k = i+1, add(k, 8) {
-			if *(*uint64)(k) == key && b.tophash[i] != empty {
+	hash := t.key.alg.hash(noescape(unsafe.Pointer(&key)), uintptr(h.hash0))
+	top := tophash(hash)
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			if *(*uint64)(add(unsafe.Pointer(b), dataOffset+i*8)) == key {
 				return add(unsafe.Pointer(b), dataOffset+bucketCnt*8+i*uintptr(t.valuesize))
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		if _, v := mapaccessOld(t, h, hash, noescape(unsafe.Pointer(&key))); v != nil {
+			return v
+		}
 	}
 	return unsafe.Pointer(&zeroVal[0])
 }
@@ -140,31 +149,34 @@ func mapaccess2_fast64(t *maptype, h *hmap, key uint64) (unsafe.Pointer, bool) {
 	if h.flags&hashWriting != 0 {
 		throw("concurrent map read and map write")
 	}
-	var b *bmap
 	if h.B == 0 {
 		// One-bucket table. No need to hash.
-		b = (*bmap)(h.buckets)
-	} else {
-		hash := t.key.alg.hash(noescape(unsafe.Pointer(&key)), uintptr(h.hash0))
-		m := bucketMask(h.B)
-		b = (*bmap)(add(h.buckets, (hash&m)*uintptr(t.bucketsize)))
-		if c := h.oldbuckets; c != nil {
-			if !h.sameSizeGrow() {
-				// There used to be half as many buckets; mask down one more power of two.
-				m >>= 1
-			}
-			oldb := (*bmap)(add(c, (hash&m)*uintptr(t.bucketsize)))
-			if !evacuated(oldb) {
-				b = oldb
+		b := (*bmap)(h.buckets)
+		for i, k := uintptr(0), add(unsafe.Pointer(b), dataOffset); i < bucketCnt; i, k = i+1, add(k, 8) {
+			if *(*uint64)(k) == key && b.ctrlAt(i)&ctrlFull != 0 {
+				return add(unsafe.Pointer(b), dataOffset+bucketCnt*8+i*uintptr(t.valuesize)), true
 			}
 		}
+		return unsafe.Pointer(&zeroVal[0]), false
 	}
-	for ; b != nil; b = b.overflow(t) {
-		for i, k := uintptr(0), b.keys(); i < bucketCnt; i, k = i+1, add(k, 8) {
-			if *(*uint64)(k) == key && b.tophash[i] != empty {
+	hash := t.key.alg.hash(noescape(unsafe.Pointer(&key)), uintptr(h.hash0))
+	top := tophash(hash)
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			if *(*uint64)(add(unsafe.Pointer(b), dataOffset+i*8)) == key {
 				return add(unsafe.Pointer(b), dataOffset+bucketCnt*8+i*uintptr(t.valuesize)), true
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		if _, v := mapaccessOld(t, h, hash, noescape(unsafe.Pointer(&key))); v != nil {
+			return v, true
+		}
 	}
 	return unsafe.Pointer(&zeroVal[0]), false
 }
@@ -186,9 +198,9 @@ func mapaccess1_faststr(t *maptype, h *hmap, ky string) unsafe.Pointer {
 		b := (*bmap)(h.buckets)
 		if key.len < 32 {
 			// short key, doing lots of comparisons is ok
-			for i, kptr := uintptr(0), b.keys(); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
+			for i, kptr := uintptr(0), add(unsafe.Pointer(b), dataOffset); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
 				k := (*stringStruct)(kptr)
-				if k.len != key.len || b.tophash[i] == empty {
+				if k.len != key.len || b.ctrlAt(i)&ctrlFull == 0 {
 					continue
 				}
 				if k.str == key.str || memequal(k.str, key.str, uintptr(key.len)) {
@@ -199,9 +211,9 @@ func mapaccess1_faststr(t *maptype, h *hmap, ky string) unsafe.Pointer {
 		}
 		// long key, try not to do more comparisons than necessary
 		keymaybe := uintptr(bucketCnt)
-		for i, kptr := uintptr(0), b.keys(); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
+		for i, kptr := uintptr(0), add(unsafe.Pointer(b), dataOffset); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
 			k := (*stringStruct)(kptr)
-			if k.len != key.len || b.tophash[i] == empty {
+			if k.len != key.len || b.ctrlAt(i)&ctrlFull == 0 {
 				continue
 			}
 			if k.str == key.str {
@@ -231,29 +243,27 @@ func mapaccess1_faststr(t *maptype, h *hmap, ky string) unsafe.Pointer {
 	}
 dohash:
 	hash := t.key.alg.hash(noescape(unsafe.Pointer(&ky)), uintptr(h.hash0))
-	m := bucketMask(h.B)
-	b := (*bmap)(add(h.buckets, (hash&m)*uintptr(t.bucketsize)))
-	if c := h.oldbuckets; c != nil {
-		if !h.sameSizeGrow() {
-			// There used to be half as many buckets; mask down one more power of two.
-			m >>= 1
-		}
-		oldb := (*bmap)(add(c, (hash&m)*uintptr(t.bucketsize)))
-		if !evacuated(oldb) {
-			b = oldb
-		}
-	}
 	top := tophash(hash)
-	for ; b != nil; b = b.overflow(t) {
-		for i, kptr := uintptr(0), b.keys(); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
-			k := (*stringStruct)(kptr)
-			if k.len != key.len || b.tophash[i] != top {
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			k := (*stringStruct)(add(unsafe.Pointer(b), dataOffset+i*2*sys.PtrSize))
+			if k.len != key.len {
 				continue
 			}
 			if k.str == key.str || memequal(k.str, key.str, uintptr(key.len)) {
 				return add(unsafe.Pointer(b), dataOffset+bucketCnt*2*sys.PtrSize+i*uintptr(t.valuesize))
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		if _, v := mapaccessOld(t, h, hash, noescape(unsafe.Pointer(&ky))); v != nil {
+			return v
+		}
 	}
 	return unsafe.Pointer(&zeroVal[0])
 }
@@ -275,9 +285,9 @@ func mapaccess2_faststr(t *maptype, h *hmap, ky string) (unsafe.Pointer, bool) {
 		b := (*bmap)(h.buckets)
 		if key.len < 32 {
 			// short key, doing lots of comparisons is ok
-			for i, kptr := uintptr(0), b.keys(); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
+			for i, kptr := uintptr(0), add(unsafe.Pointer(b), dataOffset); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
 				k := (*stringStruct)(kptr)
-				if k.len != key.len || b.tophash[i] == empty {
+				if k.len != key.len || b.ctrlAt(i)&ctrlFull == 0 {
 					continue
 				}
 				if k.str == key.str || memequal(k.str, key.str, uintptr(key.len)) {
@@ -288,9 +298,9 @@ func mapaccess2_faststr(t *maptype, h *hmap, ky string) (unsafe.Pointer, bool) {
 		}
 		// long key, try not to do more comparisons than necessary
 		keymaybe := uintptr(bucketCnt)
-		for i, kptr := uintptr(0), b.keys(); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
+		for i, kptr := uintptr(0), add(unsafe.Pointer(b), dataOffset); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
 			k := (*stringStruct)(kptr)
-			if k.len != key.len || b.tophash[i] == empty {
+			if k.len != key.len || b.ctrlAt(i)&ctrlFull == 0 {
 				continue
 			}
 			if k.str == key.str {
@@ -320,29 +330,27 @@ func mapaccess2_faststr(t *maptype, h *hmap, ky string) (unsafe.Pointer, bool) {
 	}
 dohash:
 	hash := t.key.alg.hash(noescape(unsafe.Pointer(&ky)), uintptr(h.hash0))
-	m := bucketMask(h.B)
-	b := (*bmap)(add(h.buckets, (hash&m)*uintptr(t.bucketsize)))
-	if c := h.oldbuckets; c != nil {
-		if !h.sameSizeGrow() {
-			// There used to be half as many buckets; mask down one more power of two.
-			m >>= 1
-		}
-		oldb := (*bmap)(add(c, (hash&m)*uintptr(t.bucketsize)))
-		if !evacuated(oldb) {
-			b = oldb
-		}
-	}
 	top := tophash(hash)
-	for ; b != nil; b = b.overflow(t) {
-		for i, kptr := uintptr(0), b.keys(); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
-			k := (*stringStruct)(kptr)
-			if k.len != key.len || b.tophash[i] != top {
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			k := (*stringStruct)(add(unsafe.Pointer(b), dataOffset+i*2*sys.PtrSize))
+			if k.len != key.len {
 				continue
 			}
 			if k.str == key.str || memequal(k.str, key.str, uintptr(key.len)) {
 				return add(unsafe.Pointer(b), dataOffset+bucketCnt*2*sys.PtrSize+i*uintptr(t.valuesize)), true
 			}
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
+	}
+	if h.growing() {
+		if _, v := mapaccessOld(t, h, hash, noescape(unsafe.Pointer(&ky))); v != nil {
+			return v, true
+		}
 	}
 	return unsafe.Pointer(&zeroVal[0]), false
 }
@@ -368,25 +376,19 @@ func mapassign_fast32(t *maptype, h *hmap, key uint32) unsafe.Pointer {
 	}
 
 again:
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork_fast32(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(unsafe.Pointer(uintptr(h.buckets) + bucket*uintptr(t.bucketsize)))
+	top := tophash(hash)
 
 	var insertb *bmap
 	var inserti uintptr
 	var insertk unsafe.Pointer
 
-	for {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] == empty {
-				if insertb == nil {
-					inserti = i
-					insertb = b
-				}
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := *((*uint32)(add(unsafe.Pointer(b), dataOffset+i*4)))
 			if k != key {
 				continue
@@ -395,28 +397,26 @@ again:
 			insertb = b
 			goto done
 		}
-		ovf := b.overflow(t)
-		if ovf == nil {
+		if insertb == nil {
+			if m := b.matchFree(); m != 0 {
+				insertb = b
+				inserti = m.first()
+			}
+		}
+		if b.matchEmpty() != 0 || p.last() {
 			break
 		}
-		b = ovf
 	}
 
 	// Did not find mapping for key. Allocate new cell & add entry.
 
-	// If we hit the max load factor or we have too many overflow buckets,
-	// and we're not already in the middle of growing, start growing.
-	if !h.growing() && (overLoadFactor(h.count+1, h.B) || tooManyOverflowBuckets(h.noverflow, h.B)) {
-		hashGrow(t, h)
+	// If there is no free slot, or we hit the max load factor and
+	// would fill an empty slot, grow the table.
+	if insertb == nil || h.needGrow(insertb, inserti) {
+		growOrFinish(t, h)
 		goto again // Growing the table invalidates everything, so try again
 	}
-
-	if insertb == nil {
-		// all current buckets are full, allocate a new one.
-		insertb = h.newoverflow(t, b)
-		inserti = 0 // not necessary, but avoids needlessly spilling inserti
-	}
-	insertb.tophash[inserti&(bucketCnt-1)] = tophash(hash) // mask inserti to avoid bounds checks
+	h.fillSlot(insertb, inserti, top)
 
 	insertk = add(unsafe.Pointer(insertb), dataOffset+inserti*4)
 	// store new key at insert position
@@ -454,25 +454,19 @@ func mapassign_fast32ptr(t *maptype, h *hmap, key unsafe.Pointer) unsafe.Pointer
 	}
 
 again:
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork_fast32(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(unsafe.Pointer(uintptr(h.buckets) + bucket*uintptr(t.bucketsize)))
+	top := tophash(hash)
 
 	var insertb *bmap
 	var inserti uintptr
 	var insertk unsafe.Pointer
 
-	for {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] == empty {
-				if insertb == nil {
-					inserti = i
-					insertb = b
-				}
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := *((*unsafe.Pointer)(add(unsafe.Pointer(b), dataOffset+i*4)))
 			if k != key {
 				continue
@@ -481,28 +475,26 @@ again:
 			insertb = b
 			goto done
 		}
-		ovf := b.overflow(t)
-		if ovf == nil {
+		if insertb == nil {
+			if m := b.matchFree(); m != 0 {
+				insertb = b
+				inserti = m.first()
+			}
+		}
+		if b.matchEmpty() != 0 || p.last() {
 			break
 		}
-		b = ovf
 	}
 
 	// Did not find mapping for key. Allocate new cell & add entry.
 
-	// If we hit the max load factor or we have too many overflow buckets,
-	// and we're not already in the middle of growing, start growing.
-	if !h.growing() && (overLoadFactor(h.count+1, h.B) || tooManyOverflowBuckets(h.noverflow, h.B)) {
-		hashGrow(t, h)
+	// If there is no free slot, or we hit the max load factor and
+	// would fill an empty slot, grow the table.
+	if insertb == nil || h.needGrow(insertb, inserti) {
+		growOrFinish(t, h)
 		goto again // Growing the table invalidates everything, so try again
 	}
-
-	if insertb == nil {
-		// all current buckets are full, allocate a new one.
-		insertb = h.newoverflow(t, b)
-		inserti = 0 // not necessary, but avoids needlessly spilling inserti
-	}
-	insertb.tophash[inserti&(bucketCnt-1)] = tophash(hash) // mask inserti to avoid bounds checks
+	h.fillSlot(insertb, inserti, top)
 
 	insertk = add(unsafe.Pointer(insertb), dataOffset+inserti*4)
 	// store new key at insert position
@@ -540,55 +532,47 @@ func mapassign_fast64(t *maptype, h *hmap, key uint64) unsafe.Pointer {
 	}
 
 again:
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork_fast64(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(unsafe.Pointer(uintptr(h.buckets) + bucket*uintptr(t.bucketsize)))
+	top := tophash(hash)
 
 	var insertb *bmap
 	var inserti uintptr
 	var insertk unsafe.Pointer
 
-	for {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] == empty {
-				if insertb == nil {
-					insertb = b
-					inserti = i
-				}
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := *((*uint64)(add(unsafe.Pointer(b), dataOffset+i*8)))
 			if k != key {
 				continue
 			}
-			insertb = b
 			inserti = i
+			insertb = b
 			goto done
 		}
-		ovf := b.overflow(t)
-		if ovf == nil {
+		if insertb == nil {
+			if m := b.matchFree(); m != 0 {
+				insertb = b
+				inserti = m.first()
+			}
+		}
+		if b.matchEmpty() != 0 || p.last() {
 			break
 		}
-		b = ovf
 	}
 
 	// Did not find mapping for key. Allocate new cell & add entry.
 
-	// If we hit the max load factor or we have too many overflow buckets,
-	// and we're not already in the middle of growing, start growing.
-	if !h.growing() && (overLoadFactor(h.count+1, h.B) || tooManyOverflowBuckets(h.noverflow, h.B)) {
-		hashGrow(t, h)
+	// If there is no free slot, or we hit the max load factor and
+	// would fill an empty slot, grow the table.
+	if insertb == nil || h.needGrow(insertb, inserti) {
+		growOrFinish(t, h)
 		goto again // Growing the table invalidates everything, so try again
 	}
-
-	if insertb == nil {
-		// all current buckets are full, allocate a new one.
-		insertb = h.newoverflow(t, b)
-		inserti = 0 // not necessary, but avoids needlessly spilling inserti
-	}
-	insertb.tophash[inserti&(bucketCnt-1)] = tophash(hash) // mask inserti to avoid bounds checks
+	h.fillSlot(insertb, inserti, top)
 
 	insertk = add(unsafe.Pointer(insertb), dataOffset+inserti*8)
 	// store new key at insert position
@@ -626,55 +610,47 @@ func mapassign_fast64ptr(t *maptype, h *hmap, key unsafe.Pointer) unsafe.Pointer
 	}
 
 again:
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork_fast64(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(unsafe.Pointer(uintptr(h.buckets) + bucket*uintptr(t.bucketsize)))
+	top := tophash(hash)
 
 	var insertb *bmap
 	var inserti uintptr
 	var insertk unsafe.Pointer
 
-	for {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] == empty {
-				if insertb == nil {
-					insertb = b
-					inserti = i
-				}
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := *((*unsafe.Pointer)(add(unsafe.Pointer(b), dataOffset+i*8)))
 			if k != key {
 				continue
 			}
-			insertb = b
 			inserti = i
+			insertb = b
 			goto done
 		}
-		ovf := b.overflow(t)
-		if ovf == nil {
+		if insertb == nil {
+			if m := b.matchFree(); m != 0 {
+				insertb = b
+				inserti = m.first()
+			}
+		}
+		if b.matchEmpty() != 0 || p.last() {
 			break
 		}
-		b = ovf
 	}
 
 	// Did not find mapping for key. Allocate new cell & add entry.
 
-	// If we hit the max load factor or we have too many overflow buckets,
-	// and we're not already in the middle of growing, start growing.
-	if !h.growing() && (overLoadFactor(h.count+1, h.B) || tooManyOverflowBuckets(h.noverflow, h.B)) {
-		hashGrow(t, h)
+	// If there is no free slot, or we hit the max load factor and
+	// would fill an empty slot, grow the table.
+	if insertb == nil || h.needGrow(insertb, inserti) {
+		growOrFinish(t, h)
 		goto again // Growing the table invalidates everything, so try again
 	}
-
-	if insertb == nil {
-		// all current buckets are full, allocate a new one.
-		insertb = h.newoverflow(t, b)
-		inserti = 0 // not necessary, but avoids needlessly spilling inserti
-	}
-	insertb.tophash[inserti&(bucketCnt-1)] = tophash(hash) // mask inserti to avoid bounds checks
+	h.fillSlot(insertb, inserti, top)
 
 	insertk = add(unsafe.Pointer(insertb), dataOffset+inserti*8)
 	// store new key at insert position
@@ -713,26 +689,19 @@ func mapassign_faststr(t *maptype, h *hmap, s string) unsafe.Pointer {
 	}
 
 again:
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork_faststr(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(unsafe.Pointer(uintptr(h.buckets) + bucket*uintptr(t.bucketsize)))
 	top := tophash(hash)
 
 	var insertb *bmap
 	var inserti uintptr
 	var insertk unsafe.Pointer
 
-	for {
-		for i := uintptr(0); i < bucketCnt; i++ {
-			if b.tophash[i] != top {
-				if b.tophash[i] == empty && insertb == nil {
-					insertb = b
-					inserti = i
-				}
-				continue
-			}
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
 			k := (*stringStruct)(add(unsafe.Pointer(b), dataOffset+i*2*sys.PtrSize))
 			if k.len != key.len {
 				continue
@@ -745,28 +714,26 @@ again:
 			insertb = b
 			goto done
 		}
-		ovf := b.overflow(t)
-		if ovf == nil {
+		if insertb == nil {
+			if m := b.matchFree(); m != 0 {
+				insertb = b
+				inserti = m.first()
+			}
+		}
+		if b.matchEmpty() != 0 || p.last() {
 			break
 		}
-		b = ovf
 	}
 
 	// Did not find mapping for key. Allocate new cell & add entry.
 
-	// If we hit the max load factor or we have too many overflow buckets,
-	// and we're not already in the middle of growing, start growing.
-	if !h.growing() && (overLoadFactor(h.count+1, h.B) || tooManyOverflowBuckets(h.noverflow, h.B)) {
-		hashGrow(t, h)
+	// If there is no free slot, or we hit the max load factor and
+	// would fill an empty slot, grow the table.
+	if insertb == nil || h.needGrow(insertb, inserti) {
+		growOrFinish(t, h)
 		goto again // Growing the table invalidates everything, so try again
 	}
-
-	if insertb == nil {
-		// all current buckets are full, allocate a new one.
-		insertb = h.newoverflow(t, b)
-		inserti = 0 // not necessary, but avoids needlessly spilling inserti
-	}
-	insertb.tophash[inserti&(bucketCnt-1)] = top // mask inserti to avoid bounds checks
+	h.fillSlot(insertb, inserti, top)
 
 	insertk = add(unsafe.Pointer(insertb), dataOffset+inserti*2*sys.PtrSize)
 	// store new key at insert position
@@ -799,15 +766,17 @@ func mapdelete_fast32(t *maptype, h *hmap, key uint32) {
 	// Set hashWriting after calling alg.hash for consistency with mapdelete
 	h.flags |= hashWriting
 
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork_fast32(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(add(h.buckets, bucket*uintptr(t.bucketsize)))
+	top := tophash(hash)
 search:
-	for ; b != nil; b = b.overflow(t) {
-		for i, k := uintptr(0), b.keys(); i < bucketCnt; i, k = i+1, add(k, 4) {
-			if key != *(*uint32)(k) || b.tophash[i] == empty {
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			k := add(unsafe.Pointer(b), dataOffset+i*4)
+			if key != *(*uint32)(k) {
 				continue
 			}
 			// Only clear key if there are pointers in it.
@@ -819,10 +788,13 @@ search:
 				v := add(unsafe.Pointer(b), dataOffset+bucketCnt*4+i*uintptr(t.valuesize))
 				memclrHasPointers(v, t.elem.size)
 			}
-			b.tophash[i] = empty
+			h.clearSlot(b, i)
 			h.count--
 			break search
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
 	}
 
 	if h.flags&hashWriting == 0 {
@@ -848,15 +820,17 @@ func mapdelete_fast64(t *maptype, h *hmap, key uint64) {
 	// Set hashWriting after calling alg.hash for consistency with mapdelete
 	h.flags |= hashWriting
 
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork_fast64(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(add(h.buckets, bucket*uintptr(t.bucketsize)))
+	top := tophash(hash)
 search:
-	for ; b != nil; b = b.overflow(t) {
-		for i, k := uintptr(0), b.keys(); i < bucketCnt; i, k = i+1, add(k, 8) {
-			if key != *(*uint64)(k) || b.tophash[i] == empty {
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			k := add(unsafe.Pointer(b), dataOffset+i*8)
+			if key != *(*uint64)(k) {
 				continue
 			}
 			// Only clear key if there are pointers in it.
@@ -868,10 +842,13 @@ search:
 				v := add(unsafe.Pointer(b), dataOffset+bucketCnt*8+i*uintptr(t.valuesize))
 				memclrHasPointers(v, t.elem.size)
 			}
-			b.tophash[i] = empty
+			h.clearSlot(b, i)
 			h.count--
 			break search
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
 	}
 
 	if h.flags&hashWriting == 0 {
@@ -898,17 +875,17 @@ func mapdelete_faststr(t *maptype, h *hmap, ky string) {
 	// Set hashWriting after calling alg.hash for consistency with mapdelete
 	h.flags |= hashWriting
 
-	bucket := hash & bucketMask(h.B)
 	if h.growing() {
-		growWork_faststr(t, h, bucket)
+		growWork(t, h, hash)
 	}
-	b := (*bmap)(add(h.buckets, bucket*uintptr(t.bucketsize)))
 	top := tophash(hash)
 search:
-	for ; b != nil; b = b.overflow(t) {
-		for i, kptr := uintptr(0), b.keys(); i < bucketCnt; i, kptr = i+1, add(kptr, 2*sys.PtrSize) {
-			k := (*stringStruct)(kptr)
-			if k.len != key.len || b.tophash[i] != top {
+	for p := makeProbeSeq(hash, h.B); ; p = p.next() {
+		b := (*bmap)(add(h.buckets, p.bucket*uintptr(t.bucketsize)))
+		for m := b.match(top); m != 0; m = m.removeFirst() {
+			i := m.first()
+			k := (*stringStruct)(add(unsafe.Pointer(b), dataOffset+i*2*sys.PtrSize))
+			if k.len != key.len {
 				continue
 			}
 			if k.str != key.str && !memequal(k.str, key.str, uintptr(key.len)) {
@@ -921,10 +898,13 @@ search:
 				v := add(unsafe.Pointer(b), dataOffset+bucketCnt*2*sys.PtrSize+i*uintptr(t.valuesize))
 				memclrHasPointers(v, t.elem.size)
 			}
-			b.tophash[i] = empty
+			h.clearSlot(b, i)
 			h.count--
 			break search
 		}
+		if b.matchEmpty() != 0 || p.last() {
+			break
+		}
 	}
 
 	if h.flags&hashWriting == 0 {
@@ -932,306 +912,3 @@ search:
 	}
 	h.flags &^= hashWriting
 }
-
-func growWork_fast32(t *maptype, h *hmap, bucket uintptr) {
-	// make sure we evacuate the oldbucket corresponding
-	// to the bucket we're about to use
-	evacuate_fast32(t, h, bucket&h.oldbucketmask())
-
-	// evacuate one more oldbucket to make progress on growing
-	if h.growing() {
-		evacuate_fast32(t, h, h.nevacuate)
-	}
-}
-
-func evacuate_fast32(t *maptype, h *hmap, oldbucket uintptr) {
-	b := (*bmap)(add(h.oldbuckets, oldbucket*uintptr(t.bucketsize)))
-	newbit := h.noldbuckets()
-	if !evacuated(b) {
-		// TODO: reuse overflow buckets instead of using new ones, if there
-		// is no iterator using the old buckets.  (If !oldIterator.)
-
-		// xy contains the x and y (low and high) evacuation destinations.
-		var xy [2]evacDst
-		x := &xy[0]
-		x.b = (*bmap)(add(h.buckets, oldbucket*uintptr(t.bucketsize)))
-		x.k = add(unsafe.Pointer(x.b), dataOffset)
-		x.v = add(x.k, bucketCnt*4)
-
-		if !h.sameSizeGrow() {
-			// Only calculate y pointers if we're growing bigger.
-			// Otherwise GC can see bad pointers.
-			y := &xy[1]
-			y.b = (*bmap)(add(h.buckets, (oldbucket+newbit)*uintptr(t.bucketsize)))
-			y.k = add(unsafe.Pointer(y.b), dataOffset)
-			y.v = add(y.k, bucketCnt*4)
-		}
-
-		for ; b != nil; b = b.overflow(t) {
-			k := add(unsafe.Pointer(b), dataOffset)
-			v := add(k, bucketCnt*4)
-			for i := 0; i < bucketCnt; i, k, v = i+1, add(k, 4), add(v, uintptr(t.valuesize)) {
-				top := b.tophash[i]
-				if top == empty {
-					b.tophash[i] = evacuatedEmpty
-					continue
-				}
-				if top < minTopHash {
-					throw("bad map state")
-				}
-				var useY uint8
-				if !h.sameSizeGrow() {
-					// Compute hash to make our evacuation decision (whether we need
-					// to send this key/value to bucket x or bucket y).
-					hash := t.key.alg.hash(k, uintptr(h.hash0))
-					if hash&newbit != 0 {
-						useY = 1
-					}
-				}
-
-				b.tophash[i] = evacuatedX + useY // evacuatedX + 1 == evacuatedY, enforced in makemap
-				dst := &xy[useY]                 // evacuation destination
-
-				if dst.i == bucketCnt {
-					dst.b = h.newoverflow(t, dst.b)
-					dst.i = 0
-					dst.k = add(unsafe.Pointer(dst.b), dataOffset)
-					dst.v = add(dst.k, bucketCnt*4)
-				}
-				dst.b.tophash[dst.i&(bucketCnt-1)] = top // mask dst.i as an optimization, to avoid a bounds check
-
-				// Copy key.
-				if sys.PtrSize == 4 && t.key.kind&kindNoPointers == 0 && writeBarrier.enabled {
-					writebarrierptr((*uintptr)(dst.k), *(*uintptr)(k))
-				} else {
-					*(*uint32)(dst.k) = *(*uint32)(k)
-				}
-
-				typedmemmove(t.elem, dst.v, v)
-				dst.i++
-				// These updates might push these pointers past the end of the
-				// key or value arrays.  That's ok, as we have the overflow pointer
-				// at the end of the bucket to protect against pointing past the
-				// end of the bucket.
-				dst.k = add(dst.k, 4)
-				dst.v = add(dst.v, uintptr(t.valuesize))
-			}
-		}
-		// Unlink the overflow buckets & clear key/value to help GC.
-		if h.flags&oldIterator == 0 && t.bucket.kind&kindNoPointers == 0 {
-			b := add(h.oldbuckets, oldbucket*uintptr(t.bucketsize))
-			// Preserve b.tophash because the evacuation
-			// state is maintained there.
-			ptr := add(b, dataOffset)
-			n := uintptr(t.bucketsize) - dataOffset
-			memclrHasPointers(ptr, n)
-		}
-	}
-
-	if oldbucket == h.nevacuate {
-		advanceEvacuationMark(h, t, newbit)
-	}
-}
-
-func growWork_fast64(t *maptype, h *hmap, bucket uintptr) {
-	// make sure we evacuate the oldbucket corresponding
-	// to the bucket we're about to use
-	evacuate_fast64(t, h, bucket&h.oldbucketmask())
-
-	// evacuate one more oldbucket to make progress on growing
-	if h.growing() {
-		evacuate_fast64(t, h, h.nevacuate)
-	}
-}
-
-func evacuate_fast64(t *maptype, h *hmap, oldbucket uintptr) {
-	b := (*bmap)(add(h.oldbuckets, oldbucket*uintptr(t.bucketsize)))
-	newbit := h.noldbuckets()
-	if !evacuated(b) {
-		// TODO: reuse overflow buckets instead of using new ones, if there
-		// is no iterator using the old buckets.  (If !oldIterator.)
-
-		// xy contains the x and y (low and high) evacuation destinations.
-		var xy [2]evacDst
-		x := &xy[0]
-		x.b = (*bmap)(add(h.buckets, oldbucket*uintptr(t.bucketsize)))
-		x.k = add(unsafe.Pointer(x.b), dataOffset)
-		x.v = add(x.k, bucketCnt*8)
-
-		if !h.sameSizeGrow() {
-			// Only calculate y pointers if we're growing bigger.
-			// Otherwise GC can see bad pointers.
-			y := &xy[1]
-			y.b = (*bmap)(add(h.buckets, (oldbucket+newbit)*uintptr(t.bucketsize)))
-			y.k = add(unsafe.Pointer(y.b), dataOffset)
-			y.v = add(y.k, bucketCnt*8)
-		}
-
-		for ; b != nil; b = b.overflow(t) {
-			k := add(unsafe.Pointer(b), dataOffset)
-			v := add(k, bucketCnt*8)
-			for i := 0; i < bucketCnt; i, k, v = i+1, add(k, 8), add(v, uintptr(t.valuesize)) {
-				top := b.tophash[i]
-				if top == empty {
-					b.tophash[i] = evacuatedEmpty
-					continue
-				}
-				if top < minTopHash {
-					throw("bad map state")
-				}
-				var useY uint8
-				if !h.sameSizeGrow() {
-					// Compute hash to make our evacuation decision (whether we need
-					// to send this key/value to bucket x or bucket y).
-					hash := t.key.alg.hash(k, uintptr(h.hash0))
-					if hash&newbit != 0 {
-						useY = 1
-					}
-				}
-
-				b.tophash[i] = evacuatedX + useY // evacuatedX + 1 == evacuatedY, enforced in makemap
-				dst := &xy[useY]                 // evacuation destination
-
-				if dst.i == bucketCnt {
-					dst.b = h.newoverflow(t, dst.b)
-					dst.i = 0
-					dst.k = add(unsafe.Pointer(dst.b), dataOffset)
-					dst.v = add(dst.k, bucketCnt*8)
-				}
-				dst.b.tophash[dst.i&(bucketCnt-1)] = top // mask dst.i as an optimization, to avoid a bounds check
-
-				// Copy key.
-				if t.key.kind&kindNoPointers == 0 && writeBarrier.enabled {
-					if sys.PtrSize == 8 {
-						writebarrierptr((*uintptr)(dst.k), *(*uintptr)(k))
-					} else {
-						// There are three ways to squeeze at least one 32 bit pointer into 64 bits.
-						// Give up and call typedmemmove.
-						typedmemmove(t.key, dst.k, k)
-					}
-				} else {
-					*(*uint64)(dst.k) = *(*uint64)(k)
-				}
-
-				typedmemmove(t.elem, dst.v, v)
-				dst.i++
-				// These updates might push these pointers past the end of the
-				// key or value arrays.  That's ok, as we have the overflow pointer
-				// at the end of the bucket to protect against pointing past the
-				// end of the bucket.
-				dst.k = add(dst.k, 8)
-				dst.v = add(dst.v, uintptr(t.valuesize))
-			}
-		}
-		// Unlink the overflow buckets & clear key/value to help GC.
-		if h.flags&oldIterator == 0 && t.bucket.kind&kindNoPointers == 0 {
-			b := add(h.oldbuckets, oldbucket*uintptr(t.bucketsize))
-			// Preserve b.tophash because the evacuation
-			// state is maintained there.
-			ptr := add(b, dataOffset)
-			n := uintptr(t.bucketsize) - dataOffset
-			memclrHasPointers(ptr, n)
-		}
-	}
-
-	if oldbucket == h.nevacuate {
-		advanceEvacuationMark(h, t, newbit)
-	}
-}
-
-func growWork_faststr(t *maptype, h *hmap, bucket uintptr) {
-	// make sure we evacuate the oldbucket corresponding
-	// to the bucket we're about to use
-	evacuate_faststr(t, h, bucket&h.oldbucketmask())
-
-	// evacuate one more oldbucket to make progress on growing
-	if h.growing() {
-		evacuate_faststr(t, h, h.nevacuate)
-	}
-}
-
-func evacuate_faststr(t *maptype, h *hmap, oldbucket uintptr) {
-	b := (*bmap)(add(h.oldbuckets, oldbucket*uintptr(t.bucketsize)))
-	newbit := h.noldbuckets()
-	if !evacuated(b) {
-		// TODO: reuse overflow buckets instead of using new ones, if there
-		// is no iterator using the old buckets.  (If !oldIterator.)
-
-		// xy contains the x and y (low and high) evacuation destinations.
-		var xy [2]evacDst
-		x := &xy[0]
-		x.b = (*bmap)(add(h.buckets, oldbucket*uintptr(t.bucketsize)))
-		x.k = add(unsafe.Pointer(x.b), dataOffset)
-		x.v = add(x.k, bucketCnt*2*sys.PtrSize)
-
-		if !h.sameSizeGrow() {
-			// Only calculate y pointers if we're growing bigger.
-			// Otherwise GC can see bad pointers.
-			y := &xy[1]
-			y.b = (*bmap)(add(h.buckets, (oldbucket+newbit)*uintptr(t.bucketsize)))
-			y.k = add(unsafe.Pointer(y.b), dataOffset)
-			y.v = add(y.k, bucketCnt*2*sys.PtrSize)
-		}
-
-		for ; b != nil; b = b.overflow(t) {
-			k := add(unsafe.Pointer(b), dataOffset)
-			v := add(k, bucketCnt*2*sys.PtrSize)
-			for i := 0; i < bucketCnt; i, k, v = i+1, add(k, 2*sys.PtrSize), add(v, uintptr(t.valuesize)) {
-				top := b.tophash[i]
-				if top == empty {
-					b.tophash[i] = evacuatedEmpty
-					continue
-				}
-				if top < minTopHash {
-					throw("bad map state")
-				}
-				var useY uint8
-				if !h.sameSizeGrow() {
-					// Compute hash to make our evacuation decision (whether we need
-					// to send this key/value to bucket x or bucket y).
-					hash := t.key.alg.hash(k, uintptr(h.hash0))
-					if hash&newbit != 0 {
-						useY = 1
-					}
-				}
-
-				b.tophash[i] = evacuatedX + useY // evacuatedX + 1 == evacuatedY, enforced in makemap
-				dst := &xy[useY]                 // evacuation destination
-
-				if dst.i == bucketCnt {
-					dst.b = h.newoverflow(t, dst.b)
-					dst.i = 0
-					dst.k = add(unsafe.Pointer(dst.b), dataOffset)
-					dst.v = add(dst.k, bucketCnt*2*sys.PtrSize)
-				}
-				dst.b.tophash[dst.i&(bucketCnt-1)] = top // mask dst.i as an optimization, to avoid a bounds check
-
-				// Copy key.
-				*(*string)(dst.k) = *(*string)(k)
-
-				typedmemmove(t.elem, dst.v, v)
-				dst.i++
-				// These updates might push these pointers past the end of the
-				// key or value arrays.  That's ok, as we have the overflow pointer
-				// at the end of the bucket to protect against pointing past the
-				// end of the bucket.
-				dst.k = add(dst.k, 2*sys.PtrSize)
-				dst.v = add(dst.v, uintptr(t.valuesize))
-			}
-		}
-		// Unlink the overflow buckets & clear key/value to help GC.
-		// Unlink the overflow buckets & clear key/value to help GC.
-		if h.flags&oldIterator == 0 && t.bucket.kind&kindNoPointers == 0 {
-			b := add(h.oldbuckets, oldbucket*uintptr(t.bucketsize))
-			// Preserve b.tophash because the evacuation
-			// state is maintained there.
-			ptr := add(b, dataOffset)
-			n := uintptr(t.bucketsize) - dataOffset
-			memclrHasPointers(ptr, n)
-		}
-	}
-
-	if oldbucket == h.nevacuate {
-		advanceEvacuationMark(h, t, newbit)
-	}
-}
@@ -6,8 +6,9 @@
 // objects in the heap plus additional info (roots, threads,
 // finalizers, etc.) to a file.
 
-// The format of the dumped file is described at
-// https://golang.org/s/go15heapdump.
+// The format of the dumped file is described in the documentation
+// of package internal/heapdump, which reads it. Any change to the
+// format must be made there too, along with a new header version.
 
 package runtime
 
@@ -92,22 +93,132 @@ func flush() {
 	nbuf = 0
 }
 
-// Cache of types that have been serialized already.
-// We use a type's hash field to pick a bucket.
-// Inside a bucket, we keep a list of types that
-// have been serialized so far, most recently used first.
-// Note: when a bucket overflows we may end up
-// serializing a type more than once. That's ok.
-const (
-	typeCacheBuckets = 256
-	typeCacheAssoc   = 4
+// Types that have been queued for dumping, and the queue itself.
+// A type is dumped at most once, and the types it refers to are
+// dumped along with it, so a reader can rebuild the type graph.
+var (
+	dumpedTypes addrSet
+	typeQueue   addrStack
 )
 
-type typeCacheBucket struct {
-	t [typeCacheAssoc]*_type
+// Itabs in the dump, used to find the interface values in memory.
+var dumpedItabs addrSet
+
+// addrSet is a set of non-zero addresses, used while the world is
+// stopped. Its memory comes from sysAlloc, so that the heap dump never
+// allocates from the heap it is dumping.
+type addrSet struct {
+	tab  []uintptr // open addressing, 0 marks an empty slot
+	used uintptr
+}
+
+// add adds p to the set and reports whether it was not already there.
+func (s *addrSet) add(p uintptr) bool {
+	if s.used >= uintptr(len(s.tab))/2 {
+		s.grow()
+	}
+	mask := uintptr(len(s.tab)) - 1
+	for i := addrHash(p) & mask; ; i = (i + 1) & mask {
+		switch s.tab[i] {
+		case p:
+			return false
+		case 0:
+			s.tab[i] = p
+			s.used++
+			return true
+		}
+	}
+}
+
+// has reports whether p is in the set.
+func (s *addrSet) has(p uintptr) bool {
+	if len(s.tab) == 0 || p == 0 {
+		return false
+	}
+	mask := uintptr(len(s.tab)) - 1
+	for i := addrHash(p) & mask; ; i = (i + 1) & mask {
+		switch s.tab[i] {
+		case p:
+			return true
+		case 0:
+			return false
+		}
+	}
+}
+
+func (s *addrSet) grow() {
+	old := s.tab
+	n := 2 * uintptr(len(old))
+	if n == 0 {
+		n = 1024
+	}
+	s.tab = dumpalloc(n)
+	s.used = 0
+	for _, p := range old {
+		if p != 0 {
+			s.add(p)
+		}
+	}
+	dumpfree(old)
+}
+
+func (s *addrSet) free() {
+	dumpfree(s.tab)
+	s.tab = nil
+	s.used = 0
+}
+
+func addrHash(p uintptr) uintptr {
+	return uintptr(uint64(p) * 0x9e3779b97f4a7c15 >> 32)
 }
 
-var typecache [typeCacheBuckets]typeCacheBucket
+// addrStack is a stack of addresses. Like addrSet, it never allocates
+// from the heap.
+type addrStack struct {
+	buf []uintptr
+	n   int
+}
+
+func (s *addrStack) push(p uintptr) {
+	if s.n == len(s.buf) {
+		n := 2 * uintptr(len(s.buf))
+		if n == 0 {
+			n = 1024
+		}
+		buf := dumpalloc(n)
+		copy(buf, s.buf)
+		dumpfree(s.buf)
+		s.buf = buf
+	}
+	s.buf[s.n] = p
+	s.n++
+}
+
+func (s *addrStack) pop() uintptr {
+	s.n--
+	return s.buf[s.n]
+}
+
+func (s *addrStack) free() {
+	dumpfree(s.buf)
+	s.buf = nil
+	s.n = 0
+}
+
+// dumpalloc returns zeroed memory for n addresses, outside the heap.
+func dumpalloc(n uintptr) []uintptr {
+	p := sysAlloc(n*sys.PtrSize, &memstats.other_sys)
+	if p == nil {
+		throw("heapdump: out of memory")
+	}
+	return (*[(1 << 30) / sys.PtrSize]uintptr)(p)[:n:n]
+}
+
+func dumpfree(buf []uintptr) {
+	if cap(buf) > 0 {
+		sysFree(unsafe.Pointer(&buf[:1][0]), uintptr(cap(buf))*sys.PtrSize, &memstats.other_sys)
+	}
+}
 
 // dump a uint64 in a varint format parseable by encoding/binary
 func dumpint(v uint64) {
@@ -149,41 +260,39 @@ func dumpstr(s string) {
 	dumpmemrange(sp.str, uintptr(sp.len))
 }
 
-// dump information for a type
+// dumptype queues t to be dumped, unless it already has been.
+// It does not write anything, so it can be called in the middle
+// of a record.
 func dumptype(t *_type) {
-	if t == nil {
+	if t == nil || !dumpedTypes.add(uintptr(unsafe.Pointer(t))) {
 		return
 	}
+	typeQueue.push(uintptr(unsafe.Pointer(t)))
+}
 
-	// If we've definitely serialized the type before,
-	// no need to do it again.
-	b := &typecache[t.hash&(typeCacheBuckets-1)]
-	if t == b.t[0] {
-		return
-	}
-	for i := 1; i < typeCacheAssoc; i++ {
-		if t == b.t[i] {
-			// Move-to-front
-			for j := i; j > 0; j-- {
-				b.t[j] = b.t[j-1]
-			}
-			b.t[0] = t
-			return
-		}
-	}
+// dumptyperef dumps the address of t and queues t to be dumped.
+func dumptyperef(t *_type) {
+	dumptype(t)
+	dumpint(uint64(uintptr(unsafe.Pointer(t))))
+}
 
-	// Might not have been dumped yet. Dump it and
-	// remember we did so.
-	for j := typeCacheAssoc - 1; j > 0; j-- {
-		b.t[j] = b.t[j-1]
+// dumptypes dumps the queued types, and the types they refer to.
+func dumptypes() {
+	for typeQueue.n > 0 {
+		dumptypeinfo((*_type)(unsafe.Pointer(typeQueue.pop())))
 	}
-	b.t[0] = t
+}
 
-	// dump the type
+// dump information for a type
+func dumptypeinfo(t *_type) {
 	dumpint(tagType)
 	dumpint(uint64(uintptr(unsafe.Pointer(t))))
 	dumpint(uint64(t.size))
-	if x := t.uncommon(); x == nil || t.nameOff(x.pkgpath).name() == "" {
+	dumpint(uint64(t.ptrdata))
+	dumpint(uint64(t.kind & (kindMask | kindDirectIface)))
+	// Named types are qualified by their package path, unnamed ones are
+	// spelled as in Go source.
+	if x := t.uncommon(); x == nil || t.tflag&tflagNamed == 0 || t.nameOff(x.pkgpath).name() == "" {
 		dumpstr(t.string())
 	} else {
 		pkgpathstr := t.nameOff(x.pkgpath).name()
@@ -195,7 +304,62 @@ func dumptype(t *_type) {
 		dwritebyte('.')
 		dwrite(name.str, uintptr(name.len))
 	}
-	dumpbool(t.kind&kindDirectIface == 0 || t.kind&kindNoPointers == 0)
+
+	// The layout of the type, with the types it refers to.
+	switch t.kind & kindMask {
+	case kindArray:
+		at := (*arraytype)(unsafe.Pointer(t))
+		dumptyperef(at.elem)
+		dumpint(uint64(at.len))
+	case kindChan:
+		dumptyperef((*chantype)(unsafe.Pointer(t)).elem)
+	case kindPtr:
+		dumptyperef((*ptrtype)(unsafe.Pointer(t)).elem)
+	case kindSlice:
+		dumptyperef((*slicetype)(unsafe.Pointer(t)).elem)
+	case kindMap:
+		mt := (*maptype)(unsafe.Pointer(t))
+		dumptyperef(mt.key)
+		dumptyperef(mt.elem)
+	case kindStruct:
+		st := (*structtype)(unsafe.Pointer(t))
+		dumpint(uint64(len(st.fields)))
+		for i := range st.fields {
+			f := &st.fields[i]
+			dumpstr(f.name.name())
+			dumpint(uint64(f.offset()))
+			dumptyperef(f.typ)
+		}
+	case kindInterface:
+		dumpint(uint64(len((*interfacetype)(unsafe.Pointer(t)).mhdr)))
+	}
+}
+
+// typeAt returns the type descriptor at p, or nil if p does not look
+// like the address of one. Pointers into the type data of a module
+// can also point at names and other data that are not types, so the
+// header is checked before it is trusted.
+func typeAt(p uintptr) *_type {
+	if p&(sys.PtrSize-1) != 0 {
+		return nil
+	}
+	for _, md := range activeModules() {
+		if p < md.types || p+unsafe.Sizeof(_type{}) > md.etypes {
+			continue
+		}
+		t := (*_type)(unsafe.Pointer(p))
+		if k := t.kind & kindMask; k < kindBool || k > kindUnsafePointer {
+			return nil
+		}
+		if t.size > _MaxMem || t.ptrdata > t.size || t.align == 0 || t.align&(t.align-1) != 0 {
+			return nil
+		}
+		if t.str < 0 || uintptr(t.str) >= md.etypes-md.types {
+			return nil
+		}
+		return t
+	}
+	return nil
 }
 
 // dump an object
@@ -203,7 +367,7 @@ func dumpobj(obj unsafe.Pointer, size uintptr, bv bitvector) {
 	dumpint(tagObject)
 	dumpint(uint64(uintptr(obj)))
 	dumpmemrange(obj, size)
-	dumpfields(bv)
+	dumpfieldsat(uintptr(obj), bv)
 }
 
 func dumpotherroot(description string, to unsafe.Pointer) {
@@ -217,8 +381,8 @@ func dumpfinalizer(obj unsafe.Pointer, fn *funcval, fint *_type, ot *ptrtype) {
 	dumpint(uint64(uintptr(obj)))
 	dumpint(uint64(uintptr(unsafe.Pointer(fn))))
 	dumpint(uint64(uintptr(unsafe.Pointer(fn.fn))))
-	dumpint(uint64(uintptr(unsafe.Pointer(fint))))
-	dumpint(uint64(uintptr(unsafe.Pointer(ot))))
+	dumptyperef(fint)
+	dumptyperef(&ot.typ)
 }
 
 type childInfo struct {
@@ -379,7 +543,7 @@ func dumpgoroutine(gp *g) {
 		dumpint(uint64(uintptr(unsafe.Pointer(p))))
 		dumpint(uint64(uintptr(unsafe.Pointer(gp))))
 		eface := efaceOf(&p.arg)
-		dumpint(uint64(uintptr(unsafe.Pointer(eface._type))))
+		dumptyperef(eface._type)
 		dumpint(uint64(uintptr(unsafe.Pointer(eface.data))))
 		dumpint(0) // was p->defer, no longer recorded
 		dumpint(uint64(uintptr(unsafe.Pointer(p.link))))
@@ -410,8 +574,8 @@ func finq_callback(fn *funcval, obj unsafe.Pointer, nret uintptr, fint *_type, o
 	dumpint(uint64(uintptr(obj)))
 	dumpint(uint64(uintptr(unsafe.Pointer(fn))))
 	dumpint(uint64(uintptr(unsafe.Pointer(fn.fn))))
-	dumpint(uint64(uintptr(unsafe.Pointer(fint))))
-	dumpint(uint64(uintptr(unsafe.Pointer(ot))))
+	dumptyperef(fint)
+	dumptyperef(&ot.typ)
 }
 
 func dumproots() {
@@ -420,13 +584,13 @@ func dumproots() {
 	dumpint(tagData)
 	dumpint(uint64(firstmoduledata.data))
 	dumpmemrange(unsafe.Pointer(firstmoduledata.data), firstmoduledata.edata-firstmoduledata.data)
-	dumpfields(firstmoduledata.gcdatamask)
+	dumpfieldsat(firstmoduledata.data, firstmoduledata.gcdatamask)
 
 	// bss segment
 	dumpint(tagBSS)
 	dumpint(uint64(firstmoduledata.bss))
 	dumpmemrange(unsafe.Pointer(firstmoduledata.bss), firstmoduledata.ebss-firstmoduledata.bss)
-	dumpfields(firstmoduledata.gcbssmask)
+	dumpfieldsat(firstmoduledata.bss, firstmoduledata.gcbssmask)
 
 	// MSpan.types
 	for _, s := range mheap_.allspans {
@@ -453,7 +617,7 @@ var freemark [_PageSize / 8]bool
 
 func dumpobjs() {
 	for _, s := range mheap_.allspans {
-		if s.state != _MSpanInUse {
+		if s.state != _MSpanInUse || s.userArena == userArenaChunkFaulted {
 			continue
 		}
 		p := s.base()
@@ -496,11 +660,10 @@ func dumpparams() {
 }
 
 func itab_callback(tab *itab) {
-	t := tab._type
-	dumptype(t)
+	dumpedItabs.add(uintptr(unsafe.Pointer(tab)))
 	dumpint(tagItab)
 	dumpint(uint64(uintptr(unsafe.Pointer(tab))))
-	dumpint(uint64(uintptr(unsafe.Pointer(t))))
+	dumptyperef(tab._type)
 }
 
 func dumpitabs() {
@@ -614,7 +777,7 @@ func dumpmemprof() {
 	}
 }
 
-var dumphdr = []byte("go1.7 heap dump\n")
+var dumphdr = []byte("go heap dump v2\n")
 
 func mdump() {
 	// make sure we're done sweeping
@@ -623,7 +786,6 @@ func mdump() {
 			s.ensureSwept()
 		}
 	}
-	memclrNoHeapPointers(unsafe.Pointer(&typecache), unsafe.Sizeof(typecache))
 	dwrite(unsafe.Pointer(&dumphdr[0]), uintptr(len(dumphdr)))
 	dumpparams()
 	dumpitabs()
@@ -633,6 +795,7 @@ func mdump() {
 	dumproots()
 	dumpmemstats()
 	dumpmemprof()
+	dumptypes()
 	dumpint(tagEOF)
 	flush()
 }
@@ -659,13 +822,42 @@ func writeheapdump_m(fd uintptr) {
 		sysFree(unsafe.Pointer(&tmpbuf[0]), uintptr(len(tmpbuf)), &memstats.other_sys)
 		tmpbuf = nil
 	}
+	dumpedTypes.free()
+	dumpedItabs.free()
+	typeQueue.free()
 
 	casgstatus(_g_.m.curg, _Gwaiting, _Grunning)
 }
 
-// dumpint() the kind & offset of each field in an object.
-func dumpfields(bv bitvector) {
-	dumpbv(&bv, 0)
+// dumpint() the kind & offset of each field in the memory at p,
+// whose pointers are described by bv. Two pointer words that hold an
+// interface value are dumped as a single iface or eface field.
+func dumpfieldsat(p uintptr, cbv bitvector) {
+	bv := gobv(cbv)
+	for i := uintptr(0); i < bv.n; i++ {
+		if ptrbit(&bv, i) == 0 {
+			continue
+		}
+		off := i * sys.PtrSize
+		if i+1 < bv.n && ptrbit(&bv, i+1) == 1 {
+			w := *(*uintptr)(unsafe.Pointer(p + off))
+			if dumpedItabs.has(w) {
+				dumpint(fieldKindIface)
+				dumpint(uint64(off))
+				i++
+				continue
+			}
+			if t := typeAt(w); t != nil {
+				dumptype(t)
+				dumpint(fieldKindEface)
+				dumpint(uint64(off))
+				i++
+				continue
+			}
+		}
+		dumpint(fieldKindPtr)
+		dumpint(uint64(off))
+	}
 	dumpint(fieldKindEol)
 }
 
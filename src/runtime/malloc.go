@@ -981,7 +981,7 @@ func mallocgc(size uintptr, typ *_type, needzero bool) unsafe.Pointer {
 			c.next_sample -= int32(size)
 		} else {
 			mp := acquirem()
-			profilealloc(mp, x, size)
+			profilealloc(mp, x, size, typ)
 			releasem(mp)
 		}
 	}
@@ -1043,6 +1043,11 @@ func reflect_unsafe_New(typ *_type) unsafe.Pointer {
 	return newobject(typ)
 }
 
+//go:linkname reflectlite_unsafe_New internal/reflectlite.unsafe_New
+func reflectlite_unsafe_New(typ *_type) unsafe.Pointer {
+	return newobject(typ)
+}
+
 // newarray allocates an array of n elements of type typ.
 func newarray(typ *_type, n int) unsafe.Pointer {
 	if n == 1 {
@@ -1059,9 +1064,9 @@ func reflect_unsafe_NewArray(typ *_type, n int) unsafe.Pointer {
 	return newarray(typ, n)
 }
 
-func profilealloc(mp *m, x unsafe.Pointer, size uintptr) {
+func profilealloc(mp *m, x unsafe.Pointer, size uintptr, typ *_type) {
 	mp.mcache.next_sample = nextSample()
-	mProf_Malloc(x, size)
+	mProf_Malloc(x, size, typ)
 }
 
 // nextSample returns the next sampling point for heap profiling. The goal is
@@ -44,14 +44,14 @@ func TestMemStats(t *testing.T) {
 			return fmt.Errorf("want %v", x)
 		}
 	}
-	// Of the uint fields, HeapReleased, HeapIdle can be 0.
+	// Of the uint fields, HeapReleased, HeapScavenged, HeapIdle can be 0.
 	// PauseTotalNs can be 0 if timer resolution is poor.
 	fields := map[string][]func(interface{}) error{
 		"Alloc": {nz, le(1e10)}, "TotalAlloc": {nz, le(1e11)}, "Sys": {nz, le(1e10)},
 		"Lookups": {nz, le(1e10)}, "Mallocs": {nz, le(1e10)}, "Frees": {nz, le(1e10)},
 		"HeapAlloc": {nz, le(1e10)}, "HeapSys": {nz, le(1e10)}, "HeapIdle": {le(1e10)},
-		"HeapInuse": {nz, le(1e10)}, "HeapReleased": {le(1e10)}, "HeapObjects": {nz, le(1e10)},
-		"StackInuse": {nz, le(1e10)}, "StackSys": {nz, le(1e10)},
+		"HeapInuse": {nz, le(1e10)}, "HeapReleased": {le(1e10)}, "HeapScavenged": {le(1e11)},
+		"HeapObjects": {nz, le(1e10)}, "StackInuse": {nz, le(1e10)}, "StackSys": {nz, le(1e10)},
 		"MSpanInuse": {nz, le(1e10)}, "MSpanSys": {nz, le(1e10)},
 		"MCacheInuse": {nz, le(1e10)}, "MCacheSys": {nz, le(1e10)},
 		"BuckHashSys": {nz, le(1e10)}, "GCSys": {nz, le(1e10)}, "OtherSys": {nz, le(1e10)},
@@ -152,6 +152,38 @@ func TestTinyAlloc(t *testing.T) {
 	}
 }
 
+var scavengeSink []byte
+
+func TestBackgroundScavenger(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	var before MemStats
+	ReadMemStats(&before)
+
+	// Grow the heap well past its steady-state goal, then drop
+	// the memory and let the background scavenger return it.
+	scavengeSink = make([]byte, 64<<20)
+	scavengeSink = nil
+	GC()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		var after MemStats
+		ReadMemStats(&after)
+		scavenged := after.HeapScavenged - before.HeapScavenged
+		if scavenged >= 32<<20 ||
+			scavenged > 0 && after.HeapSys-after.HeapReleased < 32<<20 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var after MemStats
+	ReadMemStats(&after)
+	t.Fatalf("background scavenger did not release memory: HeapSys=%d HeapReleased=%d HeapScavenged=%d (was %d)",
+		after.HeapSys, after.HeapReleased, after.HeapScavenged, before.HeapScavenged)
+}
+
 var mallocSink uintptr
 
 func BenchmarkMalloc8(b *testing.B) {
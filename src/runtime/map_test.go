@@ -610,8 +610,10 @@ var mapBucketTests = [...]struct {
 	{8, 1, 1},
 	{9, 2, 2},
 	{13, 2, 2},
-	{14, 4, 4},
-	{26, 4, 4},
+	{14, 2, 2},
+	{15, 4, 4},
+	{28, 4, 4},
+	{29, 8, 8},
 }
 
 func TestMapBuckets(t *testing.T) {
@@ -724,6 +726,105 @@ func TestMapBuckets(t *testing.T) {
 
 }
 
+// Test that deleting and inserting different keys, which leaves
+// tombstones behind, does not make a map of constant size grow.
+func TestMapDeleteChurn(t *testing.T) {
+	const n = 90
+	m := make(map[int]int)
+	for i := 0; i < n; i++ {
+		m[i] = i
+	}
+	buckets := runtime.MapBucketsCount(m)
+	for i := n; i < 100*n; i++ {
+		delete(m, i-n)
+		m[i] = i
+	}
+	if len(m) != n {
+		t.Fatalf("len(m) = %d, want %d", len(m), n)
+	}
+	for i := 99 * n; i < 100*n; i++ {
+		if m[i] != i {
+			t.Fatalf("m[%d] = %d, want %d", i, m[i], i)
+		}
+	}
+	if got := runtime.MapBucketsCount(m); got != buckets {
+		t.Errorf("map grew from %d to %d buckets", buckets, got)
+	}
+}
+
+// Test that a map grows incrementally, and that lookups, writes and
+// iterations are right while it is growing.
+func TestMapIncrementalGrowth(t *testing.T) {
+	m := make(map[int]int)
+	n := 0
+	for ; !runtime.MapGrowing(m); n++ {
+		m[n] = n
+	}
+	if n < 8 {
+		t.Fatalf("map started growing after %d entries", n)
+	}
+	// Start an iteration in the middle of the growth, and keep
+	// writing to the map while it runs.
+	seen := make(map[int]bool)
+	next := n
+	for k, v := range m {
+		if k != v {
+			t.Fatalf("m[%d] = %d", k, v)
+		}
+		if seen[k] {
+			t.Fatalf("key %d returned twice", k)
+		}
+		seen[k] = true
+		if k+1 < n && k%3 == 0 {
+			delete(m, k+1)
+		}
+		m[next] = next
+		next++
+	}
+	for k := 0; k < n; k++ {
+		deleted := k%3 == 1 && seen[k-1]
+		if !seen[k] && !deleted {
+			t.Errorf("key %d not returned", k)
+		}
+	}
+	if runtime.MapGrowing(m) {
+		t.Errorf("map still growing after %d writes", 2*(next-n))
+	}
+	for k := 0; k < next; k++ {
+		v, ok := m[k]
+		deleted := k < n && k%3 == 1 && seen[k-1]
+		if ok == deleted || ok && v != k {
+			t.Errorf("m[%d] = %d, %v", k, v, ok)
+		}
+	}
+}
+
+// Test that an iteration that starts while a map with NaN keys is
+// growing returns each entry once.
+func TestMapNanIncrementalGrowth(t *testing.T) {
+	m := make(map[float64]int)
+	nan := math.NaN()
+	n := 0
+	for ; !runtime.MapGrowing(m); n++ {
+		m[nan] = n
+	}
+	found := make(map[int]bool)
+	for _, v := range m {
+		if found[v] {
+			t.Fatalf("repeat of value %d", v)
+		}
+		found[v] = true
+		// Keep the growth going.
+		m[1.0] = -1
+		delete(m, 1.0)
+	}
+	for i := 0; i < n; i++ {
+		if !found[i] {
+			t.Errorf("missing value %d", i)
+		}
+	}
+}
+
 func benchmarkMapPop(b *testing.B, n int) {
 	m := map[int]int{}
 	for i := 0; i < b.N; i++ {
@@ -152,7 +152,16 @@ func sysUnused(v unsafe.Pointer, n uintptr) {
 		throw("unaligned sysUnused")
 	}
 
-	madvise(v, n, _MADV_DONTNEED)
+	// MADV_FREE (Linux 4.5+) lets the kernel reclaim the pages
+	// lazily, which is cheaper for both the scavenger and a later
+	// reuse of the memory, but the pages stay in the process's RSS
+	// until the kernel is under memory pressure. Use it only when
+	// asked to with GODEBUG=madvdontneed=0.
+	var advice int32 = _MADV_DONTNEED
+	if debug.madvdontneed == 0 {
+		advice = _MADV_FREE
+	}
+	madvise(v, n, advice)
 }
 
 func sysUsed(v unsafe.Pointer, n uintptr) {
@@ -190,6 +199,17 @@ func sysFault(v unsafe.Pointer, n uintptr) {
 	mmap(v, n, _PROT_NONE, _MAP_ANON|_MAP_PRIVATE|_MAP_FIXED, -1, 0)
 }
 
+// sysUnfault maps memory faulted by sysFault back in, zeroed.
+func sysUnfault(v unsafe.Pointer, n uintptr) {
+	p, err := mmap(v, n, _PROT_READ|_PROT_WRITE, _MAP_ANON|_MAP_PRIVATE|_MAP_FIXED, -1, 0)
+	if err == _ENOMEM {
+		throw("runtime: out of memory")
+	}
+	if p != v || err != 0 {
+		throw("runtime: cannot map faulted pages")
+	}
+}
+
 // sysReserve 预留一段内存(未分配),如果参数非空，说么调用者希望从这里开始预留，
 // 但是sysReserve 仍然可以选择另一个位置如果希望的位置不可用，
 // 有些os上某些情况 sysReserve 仅仅检查位置是否可用而并不真正的预留它，
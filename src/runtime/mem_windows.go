@@ -98,6 +98,10 @@ func sysFault(v unsafe.Pointer, n uintptr) {
 	sysUnused(v, n)
 }
 
+func sysUnfault(v unsafe.Pointer, n uintptr) {
+	sysUsed(v, n)
+}
+
 func sysReserve(v unsafe.Pointer, n uintptr, reserved *bool) unsafe.Pointer {
 	*reserved = true
 	// v is just a hint.
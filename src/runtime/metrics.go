@@ -0,0 +1,505 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Metrics implementation exported to runtime/metrics.
+//
+// Unlike ReadMemStats, reading metrics does not stop the world. Heap
+// statistics are read under the heap lock, and the counters that are
+// updated outside of it (allocation and free counts, histograms) are
+// maintained atomically.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+var (
+	// metrics is a map of runtime/metrics keys to
+	// data used by the runtime to sample each metric's
+	// value. metricsInit indicates it has been initialized.
+	//
+	// These fields are protected by metricsSema which should be
+	// locked/unlocked with metricsLock() / metricsUnlock().
+	metricsSema uint32 = 1
+	metricsInit bool
+	metrics     map[string]metricData
+
+	timeHistBuckets []float64
+
+	// agg is used by readMetrics, and is protected by metricsSema.
+	//
+	// Managed as a global variable because its pointer will be
+	// an argument to a dynamically-defined function, and we'd
+	// like to avoid it escaping to the heap.
+	agg statAggregate
+)
+
+// gcPauseDist is the distribution of stop-the-world pause
+// latencies of the garbage collector.
+var gcPauseDist timeHistogram
+
+// schedLatencyDist is the distribution of the time goroutines
+// spend runnable before they run. Only every gTrackingPeriod'th
+// transition of a goroutine is sampled.
+var schedLatencyDist timeHistogram
+
+type metricData struct {
+	// compute is a function that populates a metricValue
+	// given a populated statAggregate structure.
+	compute func(in *statAggregate, out *metricValue)
+}
+
+func metricsLock() {
+	// Acquire the metricsSema but with handoff. Operations are typically
+	// expensive enough that queueing up goroutines and handing off between
+	// them will be noticeably better-behaved.
+	semacquire(&metricsSema)
+}
+
+func metricsUnlock() {
+	semrelease(&metricsSema)
+}
+
+// initMetrics initializes the metrics map if it hasn't been yet.
+//
+// metricsSema must be held.
+func initMetrics() {
+	if metricsInit {
+		return
+	}
+	timeHistBuckets = timeHistogramMetricsBuckets()
+	metrics = map[string]metricData{
+		"/gc/cycles/automatic:gc-cycles": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.gcCyclesDone - in.sysStats.gcCyclesForced
+			},
+		},
+		"/gc/cycles/forced:gc-cycles": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.gcCyclesForced
+			},
+		},
+		"/gc/cycles/total:gc-cycles": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.gcCyclesDone
+			},
+		},
+		"/gc/gogc:percent": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.gogc
+			},
+		},
+		"/gc/gomemlimit:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.memoryLimit
+			},
+		},
+		"/gc/heap/allocs:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.totalAllocated
+			},
+		},
+		"/gc/heap/allocs:objects": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.totalAllocs
+			},
+		},
+		"/gc/heap/frees:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.totalFreed
+			},
+		},
+		"/gc/heap/frees:objects": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.totalFrees
+			},
+		},
+		"/gc/heap/goal:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.heapGoal
+			},
+		},
+		"/gc/heap/objects:objects": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.numObjects
+			},
+		},
+		"/gc/pauses:seconds": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				hist := out.float64HistOrInit(timeHistBuckets)
+				gcPauseDist.read(hist.counts)
+			},
+		},
+		"/memory/classes/heap/free:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.free
+			},
+		},
+		"/memory/classes/heap/objects:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.inObjects
+			},
+		},
+		"/memory/classes/heap/released:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.released
+			},
+		},
+		"/memory/classes/heap/stacks:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.inStacks
+			},
+		},
+		"/memory/classes/heap/unused:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.inHeap - in.heapStats.inObjects
+			},
+		},
+		"/memory/classes/metadata/mcache/free:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.mCacheSys - in.sysStats.mCacheInUse
+			},
+		},
+		"/memory/classes/metadata/mcache/inuse:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.mCacheInUse
+			},
+		},
+		"/memory/classes/metadata/mspan/free:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.mSpanSys - in.sysStats.mSpanInUse
+			},
+		},
+		"/memory/classes/metadata/mspan/inuse:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.mSpanInUse
+			},
+		},
+		"/memory/classes/metadata/other:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.gcMiscSys
+			},
+		},
+		"/memory/classes/os-stacks:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.stacksSys
+			},
+		},
+		"/memory/classes/other:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.otherSys
+			},
+		},
+		"/memory/classes/profiling/buckets:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.sysStats.buckHashSys
+			},
+		},
+		"/memory/classes/total:bytes": {
+			compute: func(in *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = in.heapStats.committed + in.heapStats.released +
+					in.sysStats.stacksSys + in.sysStats.mSpanSys +
+					in.sysStats.mCacheSys + in.sysStats.buckHashSys +
+					in.sysStats.gcMiscSys + in.sysStats.otherSys
+			},
+		},
+		"/sched/gomaxprocs:threads": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = uint64(gomaxprocs)
+			},
+		},
+		"/sched/goroutines:goroutines": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = uint64(gcount())
+			},
+		},
+		"/sched/latencies:seconds": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				hist := out.float64HistOrInit(timeHistBuckets)
+				schedLatencyDist.read(hist.counts)
+			},
+		},
+	}
+	metricsInit = true
+}
+
+// heapStatsAggregate represents memory stats obtained from the heap.
+type heapStatsAggregate struct {
+	// committed is heap memory backed by the OS: idle, in-use and
+	// stack spans.
+	committed uint64
+
+	// inHeap is the memory in in-use heap spans.
+	inHeap uint64
+
+	// inStacks is the memory in stack spans.
+	inStacks uint64
+
+	// free is idle heap memory that has not been released.
+	free uint64
+
+	// released is idle heap memory that has been returned to the
+	// OS.
+	released uint64
+
+	// inObjects is the memory occupied by objects, live or
+	// unswept.
+	inObjects uint64
+
+	// numObjects is the number of live or unswept heap objects.
+	numObjects uint64
+
+	// totalAllocated is the total bytes of heap objects allocated
+	// over the lifetime of the program.
+	totalAllocated uint64
+
+	// totalFreed is the total bytes of heap objects freed
+	// over the lifetime of the program.
+	totalFreed uint64
+
+	// totalAllocs is the number of heap objects allocated over
+	// the lifetime of the program.
+	totalAllocs uint64
+
+	// totalFrees is the number of heap objects freed over
+	// the lifetime of the program.
+	totalFrees uint64
+}
+
+// compute populates the heapStatsAggregate with values from the runtime.
+//
+// Small object allocations are counted when their span is cached by
+// an mcache, so the counts include the free slots of the spans
+// currently cached.
+func (a *heapStatsAggregate) compute() {
+	systemstack(func() {
+		lock(&mheap_.lock)
+		a.inHeap = memstats.heap_inuse
+		a.inStacks = memstats.stacks_inuse
+		a.free = memstats.heap_idle - memstats.heap_released
+		a.released = memstats.heap_released
+		unlock(&mheap_.lock)
+	})
+	a.committed = a.inHeap + a.inStacks + a.free
+
+	// Collect per-spanclass allocation stats.
+	for spc := range mheap_.central {
+		c := &mheap_.central[spc].mcentral
+		n := atomic.Load64(&c.nmalloc)
+		a.totalAllocs += n
+		a.totalAllocated += n * uint64(class_to_size[spanClass(spc).sizeclass()])
+	}
+	a.totalAllocs += atomic.Load64(&mheap_.nlargealloc)
+	a.totalAllocated += atomic.Load64(&mheap_.largealloc)
+
+	// Collect per-sizeclass free stats.
+	for i := 1; i < _NumSizeClasses; i++ {
+		n := atomic.Load64(&mheap_.nsmallfree[i])
+		a.totalFrees += n
+		a.totalFreed += n * uint64(class_to_size[i])
+	}
+	a.totalFrees += atomic.Load64(&mheap_.nlargefree)
+	a.totalFreed += atomic.Load64(&mheap_.largefree)
+
+	// The counters are read one at a time while the program keeps
+	// allocating and sweeping, so keep the derived values sane.
+	if a.totalFreed > a.totalAllocated {
+		a.totalFreed = a.totalAllocated
+	}
+	if a.totalFrees > a.totalAllocs {
+		a.totalFrees = a.totalAllocs
+	}
+	a.inObjects = a.totalAllocated - a.totalFreed
+	if a.inObjects > a.inHeap {
+		a.inObjects = a.inHeap
+	}
+	a.numObjects = a.totalAllocs - a.totalFrees
+}
+
+// sysStatsAggregate represents system memory stats obtained
+// from the runtime. This set of stats is grouped together because
+// they all depend on each other in some way to make sense of the
+// runtime's current heap memory use.
+type sysStatsAggregate struct {
+	stacksSys      uint64
+	mSpanSys       uint64
+	mSpanInUse     uint64
+	mCacheSys      uint64
+	mCacheInUse    uint64
+	buckHashSys    uint64
+	gcMiscSys      uint64
+	otherSys       uint64
+	heapGoal       uint64
+	gogc           uint64
+	memoryLimit    uint64
+	gcCyclesDone   uint64
+	gcCyclesForced uint64
+}
+
+// compute populates the sysStatsAggregate with values from the runtime.
+func (a *sysStatsAggregate) compute() {
+	a.stacksSys = atomic.Load64(&memstats.stacks_sys)
+	a.buckHashSys = atomic.Load64(&memstats.buckhash_sys)
+	a.gcMiscSys = atomic.Load64(&memstats.gc_sys)
+	a.otherSys = atomic.Load64(&memstats.other_sys)
+	a.heapGoal = atomic.Load64(&memstats.next_gc)
+	a.memoryLimit = uint64(atomic.Loadint64(&memoryLimit))
+	a.gcCyclesDone = uint64(atomic.Load(&memstats.numgc))
+	a.gcCyclesForced = uint64(atomic.Load(&memstats.numforcedgc))
+
+	systemstack(func() {
+		lock(&mheap_.lock)
+		a.mSpanSys = memstats.mspan_sys
+		a.mSpanInUse = uint64(mheap_.spanalloc.inuse)
+		a.mCacheSys = memstats.mcache_sys
+		a.mCacheInUse = uint64(mheap_.cachealloc.inuse)
+		if gcpercent >= 0 {
+			a.gogc = uint64(gcpercent)
+		} else {
+			// GOGC=off
+			a.gogc = maxInt64
+		}
+		unlock(&mheap_.lock)
+	})
+}
+
+// statAggregate is the main driver of the metrics implementation.
+//
+// It contains multiple aggregates of runtime statistics which
+// metrics are computed from.
+type statAggregate struct {
+	heapStats heapStatsAggregate
+	sysStats  sysStatsAggregate
+}
+
+// compute populates all the aggregates.
+func (a *statAggregate) compute() {
+	a.heapStats.compute()
+	a.sysStats.compute()
+}
+
+// metricKind is a runtime copy of runtime/metrics.ValueKind and
+// must be kept structurally identical to that type.
+type metricKind int
+
+const (
+	// These values must be kept identical to their corresponding Kind* values
+	// in the runtime/metrics package.
+	metricKindBad metricKind = iota
+	metricKindUint64
+	metricKindFloat64
+	metricKindFloat64Histogram
+)
+
+// metricSample is a runtime copy of runtime/metrics.Sample and
+// must be kept structurally identical to that type.
+type metricSample struct {
+	name  string
+	value metricValue
+}
+
+// metricValue is a runtime copy of runtime/metrics.Value and
+// must be kept structurally identical to that type.
+type metricValue struct {
+	kind    metricKind
+	scalar  uint64         // contains scalar values for scalar Kinds.
+	pointer unsafe.Pointer // contains non-scalar values.
+}
+
+// float64HistOrInit tries to pull out an existing float64Histogram
+// from the value, but if none exists, then it allocates one with
+// the given buckets.
+func (v *metricValue) float64HistOrInit(buckets []float64) *metricFloat64Histogram {
+	var hist *metricFloat64Histogram
+	if v.kind == metricKindFloat64Histogram && v.pointer != nil {
+		hist = (*metricFloat64Histogram)(v.pointer)
+	} else {
+		v.kind = metricKindFloat64Histogram
+		hist = new(metricFloat64Histogram)
+		v.pointer = unsafe.Pointer(hist)
+	}
+	hist.buckets = buckets
+	if len(hist.counts) != len(hist.buckets)-1 {
+		hist.counts = make([]uint64, len(buckets)-1)
+	}
+	return hist
+}
+
+// metricFloat64Histogram is a runtime copy of runtime/metrics.Float64Histogram
+// and must be kept structurally identical to that type.
+type metricFloat64Histogram struct {
+	counts  []uint64
+	buckets []float64
+}
+
+// read copies the counts of h into counts, which must have
+// timeHistTotalBuckets elements: the underflow bucket followed by
+// the regular buckets.
+func (h *timeHistogram) read(counts []uint64) {
+	counts[0] = atomic.Load64(&h.underflow)
+	for i := range h.counts {
+		counts[i+1] = atomic.Load64(&h.counts[i])
+	}
+}
+
+// readMetrics is the implementation of runtime/metrics.Read.
+//
+//go:linkname readMetrics runtime/metrics.runtime_readMetrics
+func readMetrics(samplesp unsafe.Pointer, len int, cap int) {
+	// Construct a slice from the args.
+	sl := slice{samplesp, len, cap}
+	samples := *(*[]metricSample)(unsafe.Pointer(&sl))
+
+	metricsLock()
+
+	// Ensure the map is initialized.
+	initMetrics()
+
+	// Read the runtime statistics the metrics are computed from.
+	agg = statAggregate{}
+	agg.compute()
+
+	// Iterate over each sample.
+	for i := range samples {
+		sample := &samples[i]
+		data, ok := metrics[sample.name]
+		if !ok {
+			sample.value.kind = metricKindBad
+			continue
+		}
+		// Compute the value based on the stats we have.
+		data.compute(&agg, &sample.value)
+	}
+
+	metricsUnlock()
+}
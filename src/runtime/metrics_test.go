@@ -0,0 +1,259 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func prepareAllMetricsSamples() (map[string]metrics.Description, []metrics.Sample) {
+	all := metrics.All()
+	samples := make([]metrics.Sample, len(all))
+	descs := make(map[string]metrics.Description)
+	for i := range all {
+		samples[i].Name = all[i].Name
+		descs[all[i].Name] = all[i]
+	}
+	return descs, samples
+}
+
+func TestReadMetrics(t *testing.T) {
+	// Generate some scheduler activity for the latency histogram.
+	c := make(chan int)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for range c {
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		c <- i
+	}
+	close(c)
+
+	// Tests whether readMetrics produces values aligning
+	// with ReadMemStats while the world is stopped.
+	var mstats runtime.MemStats
+	_, samples := prepareAllMetricsSamples()
+	metrics.Read(samples) // initialize the metrics and histograms
+	runtime.ReadMetricsSlow(&mstats, unsafe.Pointer(&samples[0]), len(samples), cap(samples))
+
+	checkUint64 := func(t *testing.T, m string, got, want uint64) {
+		t.Helper()
+		if got != want {
+			t.Errorf("metric %q: got %d, want %d", m, got, want)
+		}
+	}
+
+	// Check to make sure the values we read line up with other values we read.
+	for i := range samples {
+		name := samples[i].Name
+		switch name {
+		case "/gc/cycles/automatic:gc-cycles":
+			checkUint64(t, name, samples[i].Value.Uint64(), uint64(mstats.NumGC-mstats.NumForcedGC))
+		case "/gc/cycles/forced:gc-cycles":
+			checkUint64(t, name, samples[i].Value.Uint64(), uint64(mstats.NumForcedGC))
+		case "/gc/cycles/total:gc-cycles":
+			checkUint64(t, name, samples[i].Value.Uint64(), uint64(mstats.NumGC))
+		case "/gc/heap/allocs:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.TotalAlloc)
+		case "/gc/heap/allocs:objects":
+			// Mallocs also counts tiny allocations.
+			if got := samples[i].Value.Uint64(); got > mstats.Mallocs {
+				t.Errorf("metric %q: got %d, want at most %d", name, got, mstats.Mallocs)
+			}
+		case "/gc/heap/frees:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.TotalAlloc-mstats.HeapAlloc)
+		case "/gc/heap/frees:objects":
+			if got := samples[i].Value.Uint64(); got > mstats.Frees {
+				t.Errorf("metric %q: got %d, want at most %d", name, got, mstats.Frees)
+			}
+		case "/gc/heap/goal:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.NextGC)
+		case "/gc/pauses:seconds":
+			var total uint64
+			for _, n := range samples[i].Value.Float64Histogram().Counts {
+				total += n
+			}
+			// Each completed cycle has at least one pause.
+			if total < uint64(mstats.NumGC) {
+				t.Errorf("metric %q: got %d pauses, want at least %d", name, total, mstats.NumGC)
+			}
+		case "/memory/classes/heap/free:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.HeapIdle-mstats.HeapReleased)
+		case "/memory/classes/heap/objects:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.HeapAlloc)
+		case "/memory/classes/heap/released:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.HeapReleased)
+		case "/memory/classes/heap/stacks:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.StackInuse)
+		case "/memory/classes/heap/unused:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.HeapInuse-mstats.HeapAlloc)
+		case "/memory/classes/metadata/mcache/free:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.MCacheSys-mstats.MCacheInuse)
+		case "/memory/classes/metadata/mcache/inuse:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.MCacheInuse)
+		case "/memory/classes/metadata/mspan/free:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.MSpanSys-mstats.MSpanInuse)
+		case "/memory/classes/metadata/mspan/inuse:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.MSpanInuse)
+		case "/memory/classes/metadata/other:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.GCSys)
+		case "/memory/classes/os-stacks:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.StackSys-mstats.StackInuse)
+		case "/memory/classes/other:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.OtherSys)
+		case "/memory/classes/profiling/buckets:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.BuckHashSys)
+		case "/memory/classes/total:bytes":
+			checkUint64(t, name, samples[i].Value.Uint64(), mstats.Sys)
+		case "/sched/gomaxprocs:threads":
+			checkUint64(t, name, samples[i].Value.Uint64(), uint64(runtime.GOMAXPROCS(0)))
+		case "/sched/latencies:seconds":
+			var total uint64
+			for _, n := range samples[i].Value.Float64Histogram().Counts {
+				total += n
+			}
+			if total == 0 {
+				t.Errorf("metric %q: no samples", name)
+			}
+		}
+	}
+}
+
+func TestReadMetricsConsistency(t *testing.T) {
+	// Tests whether readMetrics produces consistent, sensible values.
+	// The values are read concurrently with the runtime doing other
+	// things (e.g. allocating) so what we read can't reasonably compared
+	// to runtime values.
+
+	// Run a few GC cycles to get some of the stats to be non-zero.
+	runtime.GC()
+	runtime.GC()
+	runtime.GC()
+
+	// Read all the supported metrics through the metrics package.
+	descs, samples := prepareAllMetricsSamples()
+	metrics.Read(samples)
+
+	// Check to make sure the values we read make sense.
+	var totalVirtual struct {
+		got, want uint64
+	}
+	var objects struct {
+		totalAllocs, totalFrees uint64
+		numObjects              uint64
+		allocsBytes, freesBytes uint64
+	}
+	var gc struct {
+		numGC  uint64
+		pauses uint64
+	}
+	for i := range samples {
+		kind := samples[i].Value.Kind()
+		if want := descs[samples[i].Name].Kind; kind != want {
+			t.Errorf("supported metric %q has unexpected kind: got %d, want %d", samples[i].Name, kind, want)
+			continue
+		}
+		if samples[i].Name != "/memory/classes/total:bytes" && strings.HasPrefix(samples[i].Name, "/memory/classes") {
+			v := samples[i].Value.Uint64()
+			totalVirtual.want += v
+
+			// None of these stats should ever get this big.
+			// If they do, there's probably overflow involved,
+			// usually due to bad accounting.
+			if int64(v) < 0 {
+				t.Errorf("%q has high/negative value: %d", samples[i].Name, v)
+			}
+		}
+		switch samples[i].Name {
+		case "/memory/classes/total:bytes":
+			totalVirtual.got = samples[i].Value.Uint64()
+		case "/gc/heap/allocs:objects":
+			objects.totalAllocs = samples[i].Value.Uint64()
+		case "/gc/heap/frees:objects":
+			objects.totalFrees = samples[i].Value.Uint64()
+		case "/gc/heap/objects:objects":
+			objects.numObjects = samples[i].Value.Uint64()
+		case "/gc/heap/allocs:bytes":
+			objects.allocsBytes = samples[i].Value.Uint64()
+		case "/gc/heap/frees:bytes":
+			objects.freesBytes = samples[i].Value.Uint64()
+		case "/gc/cycles/total:gc-cycles":
+			gc.numGC = samples[i].Value.Uint64()
+		case "/gc/pauses:seconds":
+			h := samples[i].Value.Float64Histogram()
+			if len(h.Buckets) != len(h.Counts)+1 {
+				t.Errorf("%q: got %d buckets for %d counts", samples[i].Name, len(h.Buckets), len(h.Counts))
+			}
+			for _, n := range h.Counts {
+				gc.pauses += n
+			}
+		}
+	}
+	if totalVirtual.got != totalVirtual.want {
+		t.Errorf(`"/memory/classes/total:bytes" does not match sum of /memory/classes/**: got %d, want %d`, totalVirtual.got, totalVirtual.want)
+	}
+	if objects.totalFrees > objects.totalAllocs {
+		t.Errorf("found more frees than allocs: %d > %d", objects.totalFrees, objects.totalAllocs)
+	}
+	if got, want := objects.numObjects, objects.totalAllocs-objects.totalFrees; got != want {
+		t.Errorf("number of objects does not match allocs - frees: got %d, want %d", got, want)
+	}
+	if objects.freesBytes > objects.allocsBytes {
+		t.Errorf("found more bytes freed than allocated: %d > %d", objects.freesBytes, objects.allocsBytes)
+	}
+	if gc.numGC < 3 {
+		t.Errorf("number of GC cycles is less than the number of forced GCs: %d < 3", gc.numGC)
+	}
+	// Each GC cycle has two stop-the-world pauses.
+	if gc.pauses < 2*gc.numGC {
+		t.Errorf("fewer pauses than expected: got %d, want at least %d", gc.pauses, 2*gc.numGC)
+	}
+}
+
+func TestReadMetricsUnknown(t *testing.T) {
+	samples := []metrics.Sample{{Name: "/not/a/metric:bytes"}}
+	metrics.Read(samples)
+	if k := samples[0].Value.Kind(); k != metrics.KindBad {
+		t.Errorf("unknown metric has kind %d, want KindBad", k)
+	}
+	metrics.Read(nil) // must not crash
+}
+
+func TestReadMetricsGOGC(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(100))
+
+	samples := []metrics.Sample{{Name: "/gc/gogc:percent"}}
+	for _, test := range []struct {
+		percent int
+		want    uint64
+	}{
+		{150, 150},
+		{0, 0},
+		{-1, math.MaxInt64}, // off
+	} {
+		debug.SetGCPercent(test.percent)
+		metrics.Read(samples)
+		if got := samples[0].Value.Uint64(); got != test.want {
+			t.Errorf("SetGCPercent(%d): /gc/gogc:percent = %d, want %d", test.percent, got, test.want)
+		}
+	}
+}
+
+func BenchmarkReadMetricsLatency(b *testing.B) {
+	_, samples := prepareAllMetricsSamples()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		metrics.Read(samples)
+	}
+}
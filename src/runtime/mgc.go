@@ -243,6 +243,33 @@ const defaultHeapMinimum = 4 << 20
 // Initialized from $GOGC.  GOGC=off means no GC.
 var gcpercent int32
 
+// memoryLimit is the soft limit on the total amount of memory mapped
+// by the runtime, in bytes. It is initialized from $GOMEMLIMIT and
+// can be changed with debug.SetMemoryLimit. maxInt64 means no limit.
+//
+// Writes are protected by mheap_.lock. Reads must be atomic.
+var memoryLimit int64 = maxInt64
+
+const maxInt64 = 1<<63 - 1
+
+const (
+	// memoryLimitHeadroomPercent is the fraction of the memory
+	// limit, in percent, that the heap goal leaves unused to
+	// absorb allocation while a GC cycle is running.
+	memoryLimitHeadroomPercent = 3
+
+	// memoryLimitScavengePercent is the fraction of the memory
+	// limit, in percent, above which the runtime eagerly returns
+	// idle heap memory to the OS.
+	memoryLimitScavengePercent = 95
+
+	// memoryLimitMaxGCCPUFraction is the fraction of CPU time GC
+	// may use before the memory limit is ignored for a cycle.
+	// Without this, a live heap close to or above the limit would
+	// make the program spend all of its time collecting garbage.
+	memoryLimitMaxGCCPUFraction = 0.5
+)
+
 // gc的初始化
 func gcinit() {
 	if unsafe.Sizeof(workbuf{}) != _WorkbufSize {
@@ -262,8 +289,9 @@ func gcinit() {
 	// 初始化heap_marked，让gc触发有个初始目标
 	memstats.heap_marked = uint64(float64(heapminimum) / (1 + memstats.triggerRatio))
 
-	// Set gcpercent from the environment. This will also compute
-	// and set the GC trigger and goal.
+	// Set the memory limit and gcpercent from the environment.
+	// This will also compute and set the GC trigger and goal.
+	memoryLimit = readGOMEMLIMIT()
 	_ = setGCPercent(readgogc())
 
 	work.startSema = 1
@@ -284,14 +312,33 @@ func readgogc() int32 {
 	return 100
 }
 
+// readGOMEMLIMIT reads the memory limit from $GOMEMLIMIT. The value is
+// a number of bytes with an optional unit suffix: B, KiB, MiB, GiB or
+// TiB. GOMEMLIMIT=off, or an unset variable, means no limit.
+func readGOMEMLIMIT() int64 {
+	p := gogetenv("GOMEMLIMIT")
+	if p == "" || p == "off" {
+		return maxInt64
+	}
+	n, ok := parseByteCount(p)
+	if !ok {
+		print("GOMEMLIMIT=", p, "\n")
+		throw("malformed GOMEMLIMIT; see `go doc runtime/debug.SetMemoryLimit`")
+	}
+	return n
+}
+
 // gcenable is called after the bulk of the runtime initialization,
 // just before we're about to start letting user code run.
-// It kicks off the background sweeper goroutine and enables GC.
+// It kicks off the background sweeper and scavenger goroutines and
+// enables GC.
 // 有main调用，启动后台清扫程序
 func gcenable() {
 	c := make(chan int, 1)
 	// 后台清扫go程
 	go bgsweep(c)
+	go bgscavenge(c)
+	<-c
 	<-c
 	memstats.enablegc = true // now that runtime is initialized, GC is okay
 }
@@ -332,6 +379,67 @@ func setGCPercent(in int32) (out int32) {
 	return out
 }
 
+//go:linkname setMemoryLimit runtime/debug.setMemoryLimit
+func setMemoryLimit(in int64) (out int64) {
+	lock(&mheap_.lock)
+	out = memoryLimit
+	if in >= 0 {
+		atomic.Store64((*uint64)(unsafe.Pointer(&memoryLimit)), uint64(in))
+		// Update pacing in response to the new limit.
+		gcSetTriggerRatio(memstats.triggerRatio)
+	}
+	unlock(&mheap_.lock)
+	return out
+}
+
+// memoryLimitNonHeap returns the memory mapped by the runtime that
+// does not hold heap objects: stacks and runtime metadata. Idle heap
+// memory is not counted, since the scavenger returns it to the OS as
+// total memory use approaches the limit.
+func memoryLimitNonHeap() uint64 {
+	return memstats.stacks_inuse + memstats.stacks_sys + memstats.mspan_sys +
+		memstats.mcache_sys + memstats.buckhash_sys + memstats.gc_sys + memstats.other_sys
+}
+
+// memoryLimitHeapGoal returns goal lowered, if necessary, so that
+// the heap plus the runtime's other memory stays under memoryLimit.
+//
+// To avoid a GC death spiral, the result never leaves less than a
+// small amount of heap growth over the last marked heap, and the limit
+// is ignored entirely while GC is using more than
+// memoryLimitMaxGCCPUFraction of the CPU.
+func memoryLimitHeapGoal(goal uint64) uint64 {
+	limit := atomic.Loadint64(&memoryLimit)
+	if limit == maxInt64 || gcController.memoryLimitOverrun {
+		return goal
+	}
+	nonHeap := memoryLimitNonHeap() + uint64(limit)/100*memoryLimitHeadroomPercent
+	limitGoal := uint64(0)
+	if uint64(limit) > nonHeap {
+		limitGoal = uint64(limit) - nonHeap
+	}
+	minGoal := memstats.heap_marked + memstats.heap_marked/16
+	if limitGoal < minGoal {
+		limitGoal = minGoal
+	}
+	if limitGoal < goal {
+		return limitGoal
+	}
+	return goal
+}
+
+// memoryLimitNeedsScavenge reports whether the runtime's mapped memory
+// is close enough to the memory limit that the background scavenger
+// should return idle heap memory to the OS right away.
+func memoryLimitNeedsScavenge() bool {
+	limit := atomic.Loadint64(&memoryLimit)
+	if limit == maxInt64 {
+		return false
+	}
+	mapped := heapRetained() + memoryLimitNonHeap()
+	return mapped > uint64(limit)/100*memoryLimitScavengePercent && memstats.heap_idle > memstats.heap_released
+}
+
 // Garbage collector phase.
 // Indicates to write barrier and synchronization task to perform.
 var gcphase uint32
@@ -507,6 +615,16 @@ type gcControllerState struct {
 	// If this is zero, no fractional workers are needed.
 	fractionalUtilizationGoal float64
 
+	// memoryLimitGoal is set if the memory limit lowered the heap
+	// goal of the current cycle below the GOGC-based goal.
+	memoryLimitGoal bool
+
+	// memoryLimitOverrun is set if GC used more than
+	// memoryLimitMaxGCCPUFraction of the CPU during the last
+	// cycle. While it is set, the memory limit does not lower the
+	// heap goal.
+	memoryLimitOverrun bool
+
 	_ [sys.CacheLineSize]byte
 }
 
@@ -526,7 +644,7 @@ func (c *gcControllerState) startCycle() {
 	// real heap_marked may not have a meaningful value (on the
 	// first cycle) or may be much smaller (resulting in a large
 	// error response).
-	if memstats.gc_trigger <= heapminimum {
+	if gcpercent >= 0 && memstats.gc_trigger <= heapminimum {
 		memstats.heap_marked = uint64(float64(memstats.gc_trigger) / (1 + memstats.triggerRatio))
 	}
 
@@ -536,6 +654,9 @@ func (c *gcControllerState) startCycle() {
 	if gcpercent < 0 {
 		memstats.next_gc = ^uint64(0)
 	}
+	goal := memoryLimitHeapGoal(memstats.next_gc)
+	c.memoryLimitGoal = goal < memstats.next_gc
+	memstats.next_gc = goal
 
 	// Ensure that the heap goal is at least a little larger than
 	// the current live heap size. This may not be the case if GC
@@ -700,6 +821,10 @@ func (c *gcControllerState) endCycle() float64 {
 	// difference between this estimate and the GOGC-based goal
 	// heap growth is the error.
 	goalGrowthRatio := float64(gcpercent) / 100
+	if c.memoryLimitGoal && memstats.heap_marked > 0 {
+		// The memory limit, not GOGC, set this cycle's goal.
+		goalGrowthRatio = float64(memstats.next_gc)/float64(memstats.heap_marked) - 1
+	}
 	actualGrowthRatio := float64(memstats.heap_live)/float64(memstats.heap_marked) - 1
 	assistDuration := nanotime() - c.markStartTime
 
@@ -927,7 +1052,6 @@ func gcSetTriggerRatio(triggerRatio float64) {
 			throw("gc_trigger underflow")
 		}
 	}
-	memstats.gc_trigger = trigger
 
 	// Compute the next GC goal, which is when the allocated heap
 	// has grown by GOGC/100 over the heap marked by the last
@@ -942,6 +1066,17 @@ func gcSetTriggerRatio(triggerRatio float64) {
 			goal = trigger
 		}
 	}
+
+	// Lower the goal if the memory limit requires it, and start
+	// the cycle early enough to finish before reaching it.
+	if limitGoal := memoryLimitHeapGoal(goal); limitGoal < goal {
+		goal = limitGoal
+		limitTrigger := memstats.heap_marked + (goal-memstats.heap_marked)/8*7
+		if trigger > limitTrigger {
+			trigger = limitTrigger
+		}
+	}
+	memstats.gc_trigger = trigger
 	memstats.next_gc = goal
 	if trace.enabled {
 		traceNextGC()
@@ -984,6 +1119,9 @@ func gcSetTriggerRatio(triggerRatio float64) {
 			atomic.Store64(&mheap_.pagesSweptBasis, pagesSwept)
 		}
 	}
+
+	// Update the background scavenger's goal.
+	gcPaceScavenger()
 }
 
 // gcGoalUtilization is the goal CPU utilization for
@@ -1109,6 +1247,15 @@ var work struct {
 	// mode is the concurrency mode of the current GC cycle.
 	mode gcMode
 
+	// leakCheck indicates the current GC cycle is looking for
+	// leaked goroutines. It is cleared once they have been found.
+	// See mgcleak.go.
+	leakCheck bool
+
+	// lastLeakCheck is the nanotime() of the start of the last
+	// leak detection cycle.
+	lastLeakCheck int64
+
 	// userForced indicates the current GC cycle was forced by an
 	// explicit user call.
 	userForced bool
@@ -1158,6 +1305,12 @@ var work struct {
 // program.
 // 用户层手动调用GC来触发gc
 func GC() {
+	gcCycle(gcBackgroundMode)
+}
+
+// gcCycle runs a garbage collection cycle in the given mode and blocks
+// the caller until the cycle, including its sweep, is complete.
+func gcCycle(mode gcMode) {
 	// We consider a cycle to be: sweep termination, mark, mark
 	// termination, and sweep. This function shouldn't return
 	// until a full cycle has been completed, from beginning to
@@ -1201,7 +1354,7 @@ func GC() {
 	// We're now in sweep N or later. Trigger GC cycle N+1, which
 	// will first finish sweep N if necessary and then enter sweep
 	// termination N+1.
-	gcStart(gcBackgroundMode, gcTrigger{kind: gcTriggerCycle, n: n + 1})
+	gcStart(mode, gcTrigger{kind: gcTriggerCycle, n: n + 1})
 
 	// Wait for mark termination N+1 to complete.
 	lock(&work.sweepWaiters.lock)
@@ -1255,6 +1408,7 @@ const (
 	gcBackgroundMode gcMode = iota // concurrent GC and sweep
 	gcForceMode                    // stop-the-world GC now, concurrent sweep
 	gcForceBlockMode               // stop-the-world GC now and STW sweep (forced by user)
+	gcLeakMode                     // stop-the-world GC with goroutine leak detection, concurrent sweep
 )
 
 // A gcTrigger is a predicate for starting a GC cycle. Specifically,
@@ -1460,6 +1614,14 @@ func gcStart(mode gcMode, trigger gcTrigger) {
 		}
 	}
 
+	// With GODEBUG=goroutineleak=1, look for leaked goroutines
+	// in the first cycle and then at most once every
+	// forcegcperiod.
+	if mode == gcBackgroundMode && debug.goroutineleak > 0 &&
+		(work.lastLeakCheck == 0 || nanotime()-work.lastLeakCheck >= forcegcperiod) {
+		mode = gcLeakMode
+	}
+
 	// Ok, we're doing it! Stop everybody else
 	// Golang中的sema，提供了休眠和唤醒Goroutine的功能，来实现同步机制。
 	semacquire(&worldsema)
@@ -1487,6 +1649,10 @@ func gcStart(mode gcMode, trigger gcTrigger) {
 	work.heap0 = atomic.Load64(&memstats.heap_live)
 	work.pauseNS = 0
 	work.mode = mode
+	if mode == gcLeakMode {
+		work.leakCheck = true
+		work.lastLeakCheck = nanotime()
+	}
 
 	// 记录开始时间
 	now := nanotime()
@@ -1569,6 +1735,7 @@ func gcStart(mode gcMode, trigger gcTrigger) {
 			now = startTheWorldWithSema(trace.enabled)
 		})
 		work.pauseNS += now - work.pauseStart
+		gcPauseDist.record(now - work.pauseStart)
 		work.tMark = now
 	} else { // 不是并发gc模式
 		if trace.enabled {
@@ -1782,6 +1949,8 @@ func gcMarkTermination(nextTriggerRatio float64) {
 
 		// marking is complete so we can turn the write barrier off
 		setGCPhase(_GCoff)
+		// The GC is done reading the freed user arena chunks.
+		userArenaFaultQuarantined()
 		// 唤醒后台清扫任务, 将在STW结束后开始运行
 		gcSweep(work.mode)
 
@@ -1818,15 +1987,13 @@ func gcMarkTermination(nextTriggerRatio float64) {
 		throw("gc done but gcphase != _GCoff")
 	}
 
-	// Update GC trigger and pacing for the next cycle.
-	// 更新下一次触发gc需要的heap大小(gc_trigger)
-	gcSetTriggerRatio(nextTriggerRatio)
-
 	// Update timing memstats
+	lastEnd := int64(atomic.Load64(&memstats.last_gc_nanotime))
 	now := nanotime()
 	sec, nsec, _ := time_now()
 	unixNow := sec*1e9 + int64(nsec)
 	work.pauseNS += now - work.pauseStart
+	gcPauseDist.record(now - work.pauseStart)
 	work.tEnd = now
 	atomic.Store64(&memstats.last_gc_unix, uint64(unixNow)) // must be Unix time to make sense to user
 	atomic.Store64(&memstats.last_gc_nanotime, uint64(now)) // monotonic time for us
@@ -1848,6 +2015,21 @@ func gcMarkTermination(nextTriggerRatio float64) {
 	totalCpu := sched.totaltime + (now-sched.procresizetime)*int64(gomaxprocs)
 	memstats.gc_cpu_fraction = float64(work.totaltime) / float64(totalCpu)
 
+	// Compute GC CPU utilization since the end of the last cycle
+	// and stop honoring the memory limit if GC is taking over.
+	if lastEnd == 0 {
+		lastEnd = runtimeInitTime
+	}
+	if d := (now - lastEnd) * int64(gomaxprocs); d > 0 {
+		gcController.memoryLimitOverrun = float64(cycleCpu)/float64(d) > memoryLimitMaxGCCPUFraction
+	}
+
+	// Update GC trigger and pacing for the next cycle. This must
+	// come after memoryLimitOverrun is updated, since the heap goal
+	// depends on it.
+	// 更新下一次触发gc需要的heap大小(gc_trigger)
+	gcSetTriggerRatio(nextTriggerRatio)
+
 	// Reset sweep state.
 	sweep.nbgsweep = 0
 	sweep.npausesweep = 0
@@ -1930,6 +2112,15 @@ func gcMarkTermination(nextTriggerRatio float64) {
 	releasem(mp)
 	mp = nil
 
+	// Let package unique drop the entries of the values this cycle
+	// reclaimed.
+	if ch := uniqueMapCleanup.ch; ch != nil {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+
 	// now that gc is done, kick off finalizer thread if needed
 	// 如果是并行GC, 让当前M继续运行(会回到gcBgMarkWorker然后休眠)
 	// 如果不是并行GC, 则让当前M开始调度
@@ -2233,6 +2424,10 @@ func gcMark(start_time int64) {
 	}
 	work.tstart = start_time
 
+	if work.leakCheck {
+		gcLeakHide(true)
+	}
+
 	// Queue root marking jobs.
 	gcMarkRootPrepare()
 
@@ -2278,6 +2473,14 @@ func gcMark(start_time int64) {
 	}
 	gcw.dispose()
 
+	if work.nproc > 1 {
+		notesleep(&work.alldone)
+	}
+
+	if work.leakCheck {
+		gcLeakFind()
+	}
+
 	if debug.gccheckmark > 0 {
 		// This is expensive when there's a large number of
 		// Gs, so only do it if checkmark is also enabled.
@@ -2287,10 +2490,6 @@ func gcMark(start_time int64) {
 		throw("work.full != 0")
 	}
 
-	if work.nproc > 1 {
-		notesleep(&work.alldone)
-	}
-
 	// Record that at least one root marking pass has completed.
 	work.markrootDone = true
 
@@ -2408,6 +2607,28 @@ func sync_runtime_registerPoolCleanup(f func()) {
 	poolcleanup = f
 }
 
+// uniqueMapCleanup holds the cleanup function registered by package
+// unique, which uniquemapcleanup runs after each GC cycle.
+var uniqueMapCleanup struct {
+	f  func()
+	ch chan struct{}
+}
+
+//go:linkname unique_runtime_registerUniqueMapCleanup unique.runtime_registerUniqueMapCleanup
+func unique_runtime_registerUniqueMapCleanup(f func()) {
+	uniqueMapCleanup.f = f
+	uniqueMapCleanup.ch = make(chan struct{}, 1)
+	go uniquemapcleanup()
+}
+
+// uniquemapcleanup runs the cleanup of package unique each time a GC
+// cycle ends.
+func uniquemapcleanup() {
+	for range uniqueMapCleanup.ch {
+		uniqueMapCleanup.f()
+	}
+}
+
 // 处理sync.Pool
 func clearpools() {
 	// clear sync.Pools
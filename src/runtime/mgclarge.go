@@ -251,6 +251,23 @@ func scavengetreap(treap *treapNode, now, limit uint64) uintptr {
 		scavengetreap(treap.right, now, limit)
 }
 
+// scavengeTreapBytes scavenges spans in the treap, largest first,
+// until at least nbytes have been released or every span has been
+// visited.
+func scavengeTreapBytes(treap *treapNode, nbytes uintptr) uintptr {
+	if treap == nil || nbytes == 0 {
+		return 0
+	}
+	released := scavengeTreapBytes(treap.right, nbytes)
+	if s := treap.spanKey; released < nbytes && s.npreleased != s.npages {
+		released += s.scavenge()
+	}
+	if released < nbytes {
+		released += scavengeTreapBytes(treap.left, nbytes-released)
+	}
+	return released
+}
+
 // rotateLeft rotates the tree rooted at node x.
 // turning (x a (y b c)) into (y (x a b) c).
 func (root *mTreap) rotateLeft(x *treapNode) {
@@ -0,0 +1,199 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Goroutine leak detection.
+//
+// A goroutine blocked on a channel or a sync object can only be woken
+// by another goroutine that can reach that channel or object. If
+// nothing that could ever run again can reach it, the goroutine is
+// blocked forever: it has leaked.
+//
+// The garbage collector already computes reachability, so leak
+// detection is done by a special GC cycle (gcLeakMode). The cycle
+// marks with the world stopped, and treats goroutines blocked on
+// channels and sync objects ("leak candidates") differently from
+// other goroutines:
+//
+// 1. Before marking, the pointers from the runtime's wait queues to
+// the channels and semaphores the candidates are blocked on are
+// hidden from the collector (see gcLeakHide). Otherwise every
+// blocking object would be reachable through allgs or semtable.
+//
+// 2. The stacks of the candidates are not scanned as roots (see
+// markroot). Everything else is marked as usual.
+//
+// 3. When marking is done, any candidate blocked on an object that
+// got marked may still be woken, so its stack is scanned and marking
+// continues. This is repeated until no more candidates become
+// reachable (see gcLeakFind).
+//
+// 4. The remaining candidates are leaked. They are flagged with
+// g.leaked, and their stacks and blocking objects are then marked
+// like everything else, so a leak detection cycle never frees memory
+// a normal cycle would not.
+//
+// Detection is conservative: a goroutine that is reported can never be
+// woken, but some leaks are missed. For example, small pointer-free
+// objects such as a lone sync.Mutex may share a tiny allocator block,
+// and so a mark bit, with objects that are still reachable.
+//
+// Leak detection runs when the goroutineleak profile is collected
+// (see runtime/pprof), and, with GODEBUG=goroutineleak=1, as part of
+// at most one GC cycle every forcegcperiod.
+
+package runtime
+
+import "unsafe"
+
+// leakCandidate reports whether gp is blocked on a channel or a sync
+// object, and so may be leaked. The world must be stopped.
+func leakCandidate(gp *g) bool {
+	if readgstatus(gp) != _Gwaiting || isSystemGoroutine(gp) {
+		return false
+	}
+	switch gp.waitreason {
+	case "chan send", "chan receive", "select",
+		"chan send (nil chan)", "chan receive (nil chan)", "select (no cases)":
+		return true
+	case "semacquire":
+		return gp.waitobj != 0
+	}
+	return false
+}
+
+// leakMarked reports whether the object containing p has been marked.
+// Pointers outside the heap are into globals or stacks and are
+// treated as reachable.
+func leakMarked(p uintptr) bool {
+	base, _, s, objIndex := heapBitsForObject(p, 0, 0)
+	if base == 0 {
+		return true
+	}
+	return s.markBitsForIndex(objIndex).isMarked()
+}
+
+// leakReachable reports whether anything gp is blocked on has been
+// marked, in which case gp may still be woken. A goroutine blocked on
+// a sync object may also wait for a channel to be closed (see
+// semacquire1).
+func leakReachable(gp *g) bool {
+	if gp.waitobj != 0 && leakMarked(gp.waitobj) {
+		return true
+	}
+	for sg := gp.waiting; sg != nil; sg = sg.waitlink {
+		if sg.hidden != 0 && leakMarked(sg.hidden) {
+			return true
+		}
+	}
+	return false
+}
+
+// gcLeakHide hides from the collector the pointers from the sudogs of
+// leak candidates to the channels they are blocked on, and from the
+// semaphore wait queues to the semaphores, saving them in
+// sudog.hidden. If hide is false, it shades and restores them instead.
+//
+// The world must be stopped. The pointers are written without write
+// barriers, which would otherwise shade the objects being hidden.
+//
+//go:nowritebarrierrec
+func gcLeakHide(hide bool) {
+	for _, gp := range allgs {
+		if !leakCandidate(gp) {
+			continue
+		}
+		for sg := gp.waiting; sg != nil; sg = sg.waitlink {
+			leakHidePtr((*uintptr)(unsafe.Pointer(&sg.c)), sg, hide)
+		}
+	}
+	for i := range semtable {
+		leakHideSema(semtable[i].root.treap, hide)
+	}
+}
+
+//go:nowritebarrierrec
+func leakHideSema(t *sudog, hide bool) {
+	if t == nil {
+		return
+	}
+	for s := t; s != nil; s = s.waitlink {
+		leakHidePtr((*uintptr)(unsafe.Pointer(&s.elem)), s, hide)
+	}
+	leakHideSema(t.prev, hide)
+	leakHideSema(t.next, hide)
+}
+
+//go:nowritebarrierrec
+func leakHidePtr(slot *uintptr, sg *sudog, hide bool) {
+	if hide {
+		sg.hidden = *slot
+		*slot = 0
+		return
+	}
+	if sg.hidden != 0 {
+		shade(sg.hidden)
+	}
+	*slot = sg.hidden
+	sg.hidden = 0
+}
+
+// gcLeakFind finishes marking for a leak detection cycle. It scans the
+// stacks of leak candidates that turn out to be reachable, flags the
+// rest as leaked, and then marks them too.
+//
+// It runs with the world stopped, after the root jobs have run and
+// all mark work has been drained.
+func gcLeakFind() {
+	gcw := &getg().m.p.ptr().gcw
+	stacks := allgs[:work.nStackRoots]
+	for {
+		found := false
+		for _, gp := range stacks {
+			if !gp.gcscandone && leakCandidate(gp) && leakReachable(gp) {
+				scang(gp, gcw)
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+		gcDrain(gcw, gcDrainNoBlock)
+	}
+
+	for _, gp := range stacks {
+		if gp.gcscandone || !leakCandidate(gp) {
+			gp.leaked = false
+			continue
+		}
+		if !gp.leaked {
+			gp.leaked = true
+			if debug.goroutineleak > 0 {
+				// Print the traceback at the level set by
+				// GOTRACEBACK, not the one mark termination
+				// uses.
+				mp := getg().m
+				level := mp.traceback
+				mp.traceback = 0
+				printlock()
+				print("\n")
+				goroutineheader(gp)
+				traceback(^uintptr(0), ^uintptr(0), 0, gp)
+				printunlock()
+				mp.traceback = level
+			}
+		}
+		scang(gp, gcw)
+	}
+	gcLeakHide(false)
+	gcDrain(gcw, gcDrainNoBlock)
+	gcw.dispose()
+	work.leakCheck = false
+}
+
+// detectGoroutineLeaks runs a full leak detection GC cycle. When it
+// returns, every goroutine found to be blocked forever has g.leaked
+// set.
+func detectGoroutineLeaks() {
+	gcCycle(gcLeakMode)
+}
@@ -264,6 +264,14 @@ func markroot(gcw *gcWork, i uint32) {
 			gp.waitsince = work.tstart
 		}
 
+		// In a leak detection cycle, goroutines blocked on
+		// channels and sync objects are scanned only once
+		// what they are blocked on is found to be reachable.
+		// See gcLeakFind.
+		if work.leakCheck && leakCandidate(gp) {
+			return
+		}
+
 		// scang must be done on the system stack in case
 		// we're trying to scan our own stack.
 		// scang必须在系统栈上运行
@@ -407,22 +415,26 @@ func markrootSpans(gcw *gcWork, shard int) {
 		lock(&s.speciallock)
 
 		for sp := s.specials; sp != nil; sp = sp.next {
-			if sp.kind != _KindSpecialFinalizer {
-				continue
+			switch sp.kind {
+			case _KindSpecialFinalizer:
+				// don't mark finalized object, but scan it so we
+				// retain everything it points to.
+				spf := (*specialfinalizer)(unsafe.Pointer(sp))
+				// A finalizer can be set for an inner byte of an object, find object beginning.
+				p := s.base() + uintptr(spf.special.offset)/s.elemsize*s.elemsize
+
+				// Mark everything that can be reached from
+				// the object (but *not* the object itself or
+				// we'll never collect it).
+				scanobject(p, gcw)
+
+				// The special itself is a root.
+				scanblock(uintptr(unsafe.Pointer(&spf.fn)), sys.PtrSize, &oneptrmask[0], gcw)
+			case _KindSpecialWeakHandle:
+				// The handle, but not the object, is a root.
+				spw := (*specialWeakHandle)(unsafe.Pointer(sp))
+				scanblock(uintptr(unsafe.Pointer(&spw.handle)), sys.PtrSize, &oneptrmask[0], gcw)
 			}
-			// don't mark finalized object, but scan it so we
-			// retain everything it points to.
-			spf := (*specialfinalizer)(unsafe.Pointer(sp))
-			// A finalizer can be set for an inner byte of an object, find object beginning.
-			p := s.base() + uintptr(spf.special.offset)/s.elemsize*s.elemsize
-
-			// Mark everything that can be reached from
-			// the object (but *not* the object itself or
-			// we'll never collect it).
-			scanobject(p, gcw)
-
-			// The special itself is a root.
-			scanblock(uintptr(unsafe.Pointer(&spf.fn)), sys.PtrSize, &oneptrmask[0], gcw)
 		}
 
 		unlock(&s.speciallock)
@@ -814,11 +826,16 @@ func scanstack(gp *g, gcw *gcWork) {
 
 	// Scan the stack.
 	var cache pcvalueCache
+	// conservative is set for the frame below an asyncPreempt frame,
+	// which was stopped at an arbitrary instruction.
+	conservative := false
 	scanframe := func(frame *stkframe, unused unsafe.Pointer) bool {
+		isAsyncPreempt := frame.fn.valid() && frame.fn.funcID == funcID_asyncPreempt
 		// scanframeworker会根据代码地址(pc)获取函数信息
 		// 然后找到函数信息中的stackmap.bytedata, 它保存了函数的栈上哪些地方有指针
 		// 再调用scanblock来扫描函数的栈空间, 同时函数的参数也会这样扫描
-		scanframeworker(frame, &cache, gcw)
+		scanframeworker(frame, &cache, gcw, isAsyncPreempt || conservative)
+		conservative = isAsyncPreempt
 		return true
 	}
 	// 枚举所有调用帧, 分别调用scanframe函数
@@ -831,7 +848,12 @@ func scanstack(gp *g, gcw *gcWork) {
 // Scan a stack frame: local variables and function arguments/results.
 //go:nowritebarrier
 // 扫描一个栈帧： 本地变量和函数的参数及返回结果
-func scanframeworker(frame *stkframe, cache *pcvalueCache, gcw *gcWork) {
+//
+// If conservative is true, the frame has no precise pointer
+// information, either because it is an asyncPreempt frame holding
+// spilled registers or because it was asynchronously preempted, and
+// it is scanned conservatively.
+func scanframeworker(frame *stkframe, cache *pcvalueCache, gcw *gcWork, conservative bool) {
 
 	f := frame.fn
 	targetpc := frame.continpc
@@ -842,6 +864,25 @@ func scanframeworker(frame *stkframe, cache *pcvalueCache, gcw *gcWork) {
 	if _DebugGC > 1 {
 		print("scanframe ", funcname(f), "\n")
 	}
+
+	if conservative {
+		// Scan the whole frame conservatively, including
+		// spill slots and the saved frame pointer.
+		if frame.varp != 0 {
+			size := frame.varp - frame.sp
+			if size > 0 {
+				scanConservative(frame.sp, size, gcw)
+			}
+		}
+
+		// Scan arguments to this frame.
+		if frame.arglen != 0 {
+			// TODO: We could pass the entry argument map
+			// to narrow this down further.
+			scanConservative(frame.argp, frame.arglen, gcw)
+		}
+		return
+	}
 	if targetpc != f.entry {
 		targetpc--
 	}
@@ -0,0 +1,192 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Scavenging free pages.
+//
+// This file implements scavenging (the release of physical pages backing mapped
+// memory) of free and unused pages in the heap as a way to deal with page-level
+// fragmentation and reduce the RSS of Go applications.
+//
+// Scavenging in Go happens on two fronts: there's the background
+// (asynchronous) scavenger and the periodic scavenger run by sysmon.
+//
+// The background scavenger runs on a goroutine and paces itself to
+// keep the heap's retained memory (heap_sys - heap_released) close
+// to a goal derived from the heap goal: retainExtraPercent percent
+// above next_gc. If a memory limit is set, the goal is also capped so
+// that the runtime's total mapped memory stays under the limit. The
+// scavenger releases whole free spans, largest first, in small steps
+// and sleeps between steps so that it uses about scavengePercent
+// percent of a single CPU.
+//
+// The periodic scavenger in sysmon releases spans that have been
+// unused for 5 minutes, regardless of the goal, and is retained as a
+// backstop for when the heap goal is very large (for example, with
+// GOGC=off).
+
+package runtime
+
+import "runtime/internal/atomic"
+
+const (
+	// The background scavenger is paced according to these parameters.
+	//
+	// scavengePercent represents the portion of mutator time we're willing
+	// to spend on scavenging in percent.
+	scavengePercent = 1 // 1%
+
+	// retainExtraPercent represents the amount of memory over the heap goal
+	// that the scavenger should keep as a buffer space for the allocator.
+	//
+	// The purpose of maintaining this overhead is to have a greater pool of
+	// unscavenged memory available for allocation (since using scavenged memory
+	// incurs an additional cost), to account for heap fragmentation and
+	// the ever-changing layout of the heap.
+	retainExtraPercent = 10
+
+	// scavengeStepBytes is the amount of memory the background
+	// scavenger tries to release in each step. It always releases
+	// whole spans, so a step may release more.
+	scavengeStepBytes = 64 << 10
+)
+
+// State of the background scavenger.
+var scavenge struct {
+	lock   mutex
+	g      *g
+	parked bool
+
+	// goal is the retained heap size, in bytes, that the
+	// background scavenger aims for. ^uint64(0) means no goal.
+	// Protected by mheap_.lock.
+	goal uint64
+
+	// sysmonWake is non-zero if the background scavenger should
+	// be woken up by sysmon. Accessed atomically.
+	sysmonWake uint32
+}
+
+// heapRetained returns an estimate of the current heap RSS.
+//
+// mheap_.lock must be held or the world must be stopped.
+func heapRetained() uint64 {
+	return memstats.heap_sys - memstats.heap_released
+}
+
+// gcPaceScavenger updates the scavenger's pacing, particularly
+// its rate and RSS goal.
+//
+// The RSS goal is based on the current heap goal with a small overhead
+// to accommodate non-determinism in the allocator, and is further
+// lowered by the memory limit, if any.
+//
+// mheap_.lock must be held or the world must be stopped.
+func gcPaceScavenger() {
+	goal := ^uint64(0)
+	if memstats.next_gc != ^uint64(0) {
+		goal = memstats.next_gc + memstats.next_gc/100*retainExtraPercent
+	}
+	if limit := atomic.Loadint64(&memoryLimit); limit != maxInt64 {
+		// Keep the heap's share of mapped memory under the
+		// threshold at which the memory limit scavenges.
+		limitGoal := uint64(0)
+		threshold := uint64(limit) / 100 * memoryLimitScavengePercent
+		if nonHeap := memoryLimitNonHeap(); threshold > nonHeap {
+			limitGoal = threshold - nonHeap
+		}
+		if limitGoal < goal {
+			goal = limitGoal
+		}
+	}
+	// Round up to the physical page size, since that's the
+	// granularity at which memory is released.
+	if goal != ^uint64(0) {
+		goal = (goal + uint64(physPageSize) - 1) &^ (uint64(physPageSize) - 1)
+	}
+	scavenge.goal = goal
+	if goal < heapRetained() {
+		// There's work to do. sysmon will wake the scavenger.
+		atomic.Store(&scavenge.sysmonWake, 1)
+	}
+}
+
+// wakeScavenger unparks the background scavenger if necessary.
+// It is called by sysmon, which runs without a P, so the scavenger
+// is put on the global run queue like forcegc.g.
+func wakeScavenger() {
+	lock(&scavenge.lock)
+	if scavenge.parked {
+		scavenge.parked = false
+		atomic.Store(&scavenge.sysmonWake, 0)
+		scavenge.g.schedlink = 0
+		injectglist(scavenge.g)
+	}
+	unlock(&scavenge.lock)
+}
+
+// bgscavenge is the background scavenger goroutine. It releases
+// free heap memory to the OS until the heap's retained memory reaches
+// scavenge.goal, then parks until sysmon wakes it up again.
+func bgscavenge(c chan int) {
+	scavenge.g = getg()
+
+	lock(&scavenge.lock)
+	scavenge.parked = true
+	c <- 1
+	goparkunlock(&scavenge.lock, "GC scavenge wait", traceEvGoBlock, 1)
+
+	var released uint64 // bytes released since the scavenger was woken
+	for {
+		var step uintptr
+		var retained, goal uint64
+		start := nanotime()
+		systemstack(func() {
+			// Disallow malloc or panic while holding the
+			// heap lock, like mheap.scavenge.
+			gp := getg()
+			gp.m.mallocing++
+			lock(&mheap_.lock)
+			retained, goal = heapRetained(), scavenge.goal
+			if retained > goal {
+				n := retained - goal
+				if n > scavengeStepBytes {
+					n = scavengeStepBytes
+				}
+				step = mheap_.scavengeLocked(uintptr(n))
+				memstats.heap_scavenged += uint64(step)
+				retained -= uint64(step)
+			}
+			unlock(&mheap_.lock)
+			gp.m.mallocing--
+		})
+
+		if step == 0 {
+			// Reached the goal or ran out of free memory
+			// to release.
+			if debug.gctrace > 0 && released > 0 {
+				print("scvg: ", released>>10, " KB released, retained: ", retained>>20, " MB, goal: ")
+				if goal == ^uint64(0) {
+					print("none\n")
+				} else {
+					print(goal>>20, " MB\n")
+				}
+			}
+			released = 0
+			lock(&scavenge.lock)
+			scavenge.parked = true
+			goparkunlock(&scavenge.lock, "GC scavenge wait", traceEvGoBlock, 1)
+			continue
+		}
+		released += uint64(step)
+
+		// Sleep long enough that the work above takes up
+		// scavengePercent of this goroutine's time.
+		crit := nanotime() - start
+		if crit < 1000 {
+			// Don't trust tiny measurements.
+			crit = 1000
+		}
+		timeSleep(crit * (100/scavengePercent - 1))
+	}
+}
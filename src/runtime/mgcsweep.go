@@ -208,7 +208,6 @@ func (s *mspan) sweep(preserve bool) bool {
 	size := s.elemsize
 	res := false
 
-	c := _g_.m.mcache
 	freeToHeap := false
 
 	// The allocBits indicate which unmarked objects don't need to be
@@ -247,12 +246,14 @@ func (s *mspan) sweep(preserve bool) bool {
 					break
 				}
 			}
-			// Pass 2: queue all finalizers _or_ handle profile record.
+			// Pass 2: queue all finalizers and clear all weak
+			// handles _or_ handle profile record. Weak handles are
+			// cleared even if a finalizer keeps the object alive.
 			for special != nil && uintptr(special.offset) < endOffset {
 				// Find the exact byte for which the special was setup
 				// (as opposed to object beginning).
 				p := s.base() + uintptr(special.offset)
-				if special.kind == _KindSpecialFinalizer || !hasFin {
+				if special.kind == _KindSpecialFinalizer || special.kind == _KindSpecialWeakHandle || !hasFin {
 					// Splice out special record.
 					y := special
 					special = special.next
@@ -341,7 +342,7 @@ func (s *mspan) sweep(preserve bool) bool {
 	}
 
 	if nfreed > 0 && spc.sizeclass() != 0 {
-		c.local_nsmallfree[spc.sizeclass()] += uintptr(nfreed)
+		atomic.Xadd64(&mheap_.nsmallfree[spc.sizeclass()], int64(nfreed))
 		res = mheap_.central[spc].mcentral.freeSpan(s, preserve, wasempty)
 		// MCentral_FreeSpan updates sweepgen
 	} else if freeToHeap {
@@ -361,14 +362,17 @@ func (s *mspan) sweep(preserve bool) bool {
 		// have mysterious crashes due to confused memory reuse.
 		// It should be possible to switch back to SysFree if we also
 		// implement and then call some kind of MHeap_DeleteSpan.
+		if s.userArena != userArenaChunkNone {
+			sweepUserArenaChunk(s)
+		}
 		if debug.efence > 0 {
 			s.limit = 0 // prevent mlookup from finding this span
 			sysFault(unsafe.Pointer(s.base()), size)
 		} else {
 			mheap_.freeSpan(s, 1)
 		}
-		c.local_nlargefree++
-		c.local_largefree += size
+		atomic.Xadd64(&mheap_.nlargefree, 1)
+		atomic.Xadd64(&mheap_.largefree, int64(size))
 		res = true
 	}
 	if !res {
@@ -110,7 +110,8 @@ type mheap struct {
 	// TODO(austin): pagesInUse should be a uintptr, but the 386
 	// compiler can't 8-byte align fields.
 
-	// Malloc stats.
+	// Malloc stats. These are updated atomically so that
+	// runtime/metrics can read them without stopping the world.
 	largealloc  uint64                  // bytes allocated for large objects
 	nlargealloc uint64                  // number of large object allocations
 	largefree   uint64                  // bytes freed for large objects (>maxsmallsize)
@@ -164,12 +165,20 @@ type mheap struct {
 		pad      [sys.CacheLineSize - unsafe.Sizeof(mcentral{})%sys.CacheLineSize]byte
 	}
 
-	spanalloc             fixalloc // allocator for span*
-	cachealloc            fixalloc // allocator for mcache*
-	treapalloc            fixalloc // allocator for treapNodes* used by large objects
-	specialfinalizeralloc fixalloc // allocator for specialfinalizer*
-	specialprofilealloc   fixalloc // allocator for specialprofile*
-	speciallock           mutex    // lock for special record allocators.
+	spanalloc              fixalloc // allocator for span*
+	cachealloc             fixalloc // allocator for mcache*
+	treapalloc             fixalloc // allocator for treapNodes* used by large objects
+	specialfinalizeralloc  fixalloc // allocator for specialfinalizer*
+	specialprofilealloc    fixalloc // allocator for specialprofile*
+	specialweakhandlealloc fixalloc // allocator for specialWeakHandle*
+	speciallock            mutex    // lock for special record allocators.
+
+	// userArena holds the user arena chunks freed during a GC cycle,
+	// which are faulted once marking is done.
+	userArena struct {
+		lock       mutex
+		quarantine *mspan
+	}
 
 	unused *specialfinalizer // never set, just here to force the specialfinalizer type into DWARF
 }
@@ -326,6 +335,7 @@ type mspan struct {
 	incache     bool       // being used by an mcache
 	state       mSpanState // mspaninuse etc
 	needzero    uint8      // needs to be zeroed before allocation
+	userArena   uint8      // user arena chunk state, see arena.go
 	divShift    uint8      // for divide by elemsize - divMagic.shift
 	divShift2   uint8      // for divide by elemsize - divMagic.shift2
 	elemsize    uintptr    // computed from sizeclass or from npages
@@ -334,6 +344,8 @@ type mspan struct {
 	limit       uintptr    // end of data in span
 	speciallock mutex      // guards specials list
 	specials    *special   // linked list of special records sorted by offset.
+
+	userArenaNext *mspan // next in mheap_.userArena.quarantine
 }
 
 func (s *mspan) base() uintptr {
@@ -529,6 +541,7 @@ func (h *mheap) init(spansStart, spansBytes uintptr) {
 	h.cachealloc.init(unsafe.Sizeof(mcache{}), nil, nil, &memstats.mcache_sys)
 	h.specialfinalizeralloc.init(unsafe.Sizeof(specialfinalizer{}), nil, nil, &memstats.other_sys)
 	h.specialprofilealloc.init(unsafe.Sizeof(specialprofile{}), nil, nil, &memstats.other_sys)
+	h.specialweakhandlealloc.init(unsafe.Sizeof(specialWeakHandle{}), nil, nil, &memstats.other_sys)
 
 	// Don't zero mspan allocations. Background sweeping can
 	// inspect a span concurrently with allocating it, so it's
@@ -756,8 +769,8 @@ func (h *mheap) alloc_m(npage uintptr, spanclass spanClass, large bool) *mspan {
 		h.pagesInUse += uint64(npage)
 		if large {
 			memstats.heap_objects++
-			mheap_.largealloc += uint64(s.elemsize)
-			mheap_.nlargealloc++
+			atomic.Xadd64(&mheap_.largealloc, int64(s.elemsize))
+			atomic.Xadd64(&mheap_.nlargealloc, 1)
 			atomic.Xadd64(&memstats.heap_live, int64(npage<<_PageShift))
 			// Swept spans are at the end of lists.
 			if s.npages < uintptr(len(h.busy)) {
@@ -1165,37 +1178,44 @@ func (h *mheap) busyList(npages uintptr) *mSpanList {
 	return &h.busylarge
 }
 
+// scavenge releases the pages of free span s that have not been
+// released yet to the OS and returns the number of bytes released.
+// The caller must hold the heap lock.
+func (s *mspan) scavenge() uintptr {
+	start := s.base()
+	end := start + s.npages<<_PageShift
+	if physPageSize > _PageSize {
+		// We can only release pages in
+		// physPageSize blocks, so round start
+		// and end in. (Otherwise, madvise
+		// will round them *out* and release
+		// more memory than we want.)
+		start = (start + physPageSize - 1) &^ (physPageSize - 1)
+		end &^= physPageSize - 1
+		if end <= start {
+			// start and end don't span a
+			// whole physical page.
+			return 0
+		}
+	}
+	len := end - start
+
+	released := len - (s.npreleased << _PageShift)
+	if physPageSize > _PageSize && released == 0 {
+		return 0
+	}
+	memstats.heap_released += uint64(released)
+	s.npreleased = len >> _PageShift
+	sysUnused(unsafe.Pointer(start), len)
+	return released
+}
+
 func scavengeTreapNode(t *treapNode, now, limit uint64) uintptr {
 	s := t.spanKey
-	var sumreleased uintptr
 	if (now-uint64(s.unusedsince)) > limit && s.npreleased != s.npages {
-		start := s.base()
-		end := start + s.npages<<_PageShift
-		if physPageSize > _PageSize {
-			// We can only release pages in
-			// physPageSize blocks, so round start
-			// and end in. (Otherwise, madvise
-			// will round them *out* and release
-			// more memory than we want.)
-			start = (start + physPageSize - 1) &^ (physPageSize - 1)
-			end &^= physPageSize - 1
-			if end <= start {
-				// start and end don't span a
-				// whole physical page.
-				return sumreleased
-			}
-		}
-		len := end - start
-		released := len - (s.npreleased << _PageShift)
-		if physPageSize > _PageSize && released == 0 {
-			return sumreleased
-		}
-		memstats.heap_released += uint64(released)
-		sumreleased += released
-		s.npreleased = len >> _PageShift
-		sysUnused(unsafe.Pointer(start), len)
+		return s.scavenge()
 	}
-	return sumreleased
+	return 0
 }
 
 func scavengelist(list *mSpanList, now, limit uint64) uintptr {
@@ -1208,34 +1228,25 @@ func scavengelist(list *mSpanList, now, limit uint64) uintptr {
 		if (now-uint64(s.unusedsince)) <= limit || s.npreleased == s.npages {
 			continue
 		}
-		start := s.base()
-		end := start + s.npages<<_PageShift
-		if physPageSize > _PageSize {
-			// We can only release pages in
-			// physPageSize blocks, so round start
-			// and end in. (Otherwise, madvise
-			// will round them *out* and release
-			// more memory than we want.)
-			start = (start + physPageSize - 1) &^ (physPageSize - 1)
-			end &^= physPageSize - 1
-			if end <= start {
-				// start and end don't span a
-				// whole physical page.
-				continue
-			}
-		}
-		len := end - start
+		sumreleased += s.scavenge()
+	}
+	return sumreleased
+}
 
-		released := len - (s.npreleased << _PageShift)
-		if physPageSize > _PageSize && released == 0 {
-			continue
+// scavengeLocked releases at least nbytes of free heap memory to the
+// OS, if that much is available, and returns the number of bytes
+// released. It releases whole spans, preferring large ones. The
+// caller must hold the heap lock.
+func (h *mheap) scavengeLocked(nbytes uintptr) uintptr {
+	released := scavengeTreapBytes(h.freelarge.treap, nbytes)
+	for i := len(h.free) - 1; i >= 0 && released < nbytes; i-- {
+		for s := h.free[i].first; s != nil && released < nbytes; s = s.next {
+			if s.npreleased != s.npages {
+				released += s.scavenge()
+			}
 		}
-		memstats.heap_released += uint64(released)
-		sumreleased += released
-		s.npreleased = len >> _PageShift
-		sysUnused(unsafe.Pointer(start), len)
 	}
-	return sumreleased
+	return released
 }
 
 func (h *mheap) scavenge(k int32, now, limit uint64) {
@@ -1386,8 +1397,9 @@ func (list *mSpanList) takeAll(other *mSpanList) {
 }
 
 const (
-	_KindSpecialFinalizer = 1
-	_KindSpecialProfile   = 2
+	_KindSpecialFinalizer  = 1
+	_KindSpecialProfile    = 2
+	_KindSpecialWeakHandle = 3
 	// Note: The finalizer special must be first because if we're freeing
 	// an object, a finalizer special will cause the freeing operation
 	// to abort, and we want to keep the other special records around
@@ -1576,6 +1588,165 @@ func setprofilebucket(p unsafe.Pointer, b *bucket) {
 	}
 }
 
+// The described object has weak pointers.
+//
+// The weak pointers to an object share a handle: a heap-allocated word
+// holding the address of the object as a uintptr, which the GC does
+// not follow. The sweeper clears the handle when it finds the object
+// unmarked, before any finalizer of the object is queued, so a weak
+// pointer never observes a resurrected object. Converting a weak
+// pointer back to a strong one sweeps the span of the object first, so
+// that a dead object is never returned, and shades the object if the
+// GC is marking, like the deletion write barrier.
+//
+// specialWeakHandle is allocated from non-GC'd memory, so any heap
+// pointers must be specially handled.
+//
+//go:notinheap
+type specialWeakHandle struct {
+	special special
+	handle  *uintptr // heap pointer, kept live by markrootSpans
+}
+
+//go:linkname weak_runtime_registerWeakPointer weak.runtime_registerWeakPointer
+func weak_runtime_registerWeakPointer(ptr interface{}) (unsafe.Pointer, uintptr) {
+	e := efaceOf(&ptr)
+	if t := e._type; t == nil || t.kind&kindMask != kindPtr && t.kind&kindMask != kindUnsafePointer {
+		panic(plainError("weak.Make: argument is not a pointer"))
+	}
+	if e.data == nil {
+		return nil, 0
+	}
+	// The handle is that of the object, and the weak pointer records
+	// the offset of an interior pointer.
+	p := e.data
+	var off uintptr
+	if s := mheap_.lookupMaybe(p); s != nil {
+		base := s.base() + s.objIndex(uintptr(p))*s.elemsize
+		off = uintptr(p) - base
+		p = unsafe.Pointer(base)
+	}
+	return unsafe.Pointer(getOrAddWeakHandle(p)), off
+}
+
+//go:linkname weak_runtime_makeStrongFromWeak weak.runtime_makeStrongFromWeak
+func weak_runtime_makeStrongFromWeak(u unsafe.Pointer) unsafe.Pointer {
+	handle := (*uintptr)(u)
+
+	// Prevent preemption, so that a GC cycle can't start or finish
+	// marking while the pointer is being made strong.
+	mp := acquirem()
+	p := atomic.Loaduintptr(handle)
+	if p == 0 {
+		releasem(mp)
+		return nil
+	}
+	// The object may be dead and its span not swept yet, so sweep it
+	// before trusting the handle. If the span was already swept and
+	// reused, this sweeps an unrelated span, which is harmless, and
+	// the handle is already clear.
+	if s := mheap_.lookupMaybe(unsafe.Pointer(p)); s != nil {
+		s.ensureSwept()
+	}
+	ptr := unsafe.Pointer(atomic.Loaduintptr(handle))
+
+	// This may create the only pointer to an object the GC has not
+	// marked yet, from a stack it has already scanned, so shade the
+	// object as the deletion write barrier would.
+	if gcphase != _GCoff && ptr != nil {
+		shade(uintptr(ptr))
+	}
+	releasem(mp)
+	KeepAlive(ptr)
+	return ptr
+}
+
+// getOrAddWeakHandle returns the weak handle of the object at p,
+// adding one if the object has none.
+func getOrAddWeakHandle(p unsafe.Pointer) *uintptr {
+	if mheap_.lookupMaybe(p) == nil {
+		// p does not point into the heap, so it is never freed.
+		handle := new(uintptr)
+		*handle = uintptr(p)
+		return handle
+	}
+	// First try to retrieve without allocating.
+	if handle := getWeakHandle(p); handle != nil {
+		return handle
+	}
+
+	lock(&mheap_.speciallock)
+	s := (*specialWeakHandle)(mheap_.specialweakhandlealloc.alloc())
+	unlock(&mheap_.speciallock)
+	handle := new(uintptr)
+	*handle = uintptr(p)
+	s.special.kind = _KindSpecialWeakHandle
+	s.handle = handle
+	if addspecial(p, &s.special) {
+		// This is responsible for maintaining the same
+		// GC-related invariants as markrootSpans in any
+		// situation where it's possible that markrootSpans
+		// has already run but mark termination hasn't yet.
+		if gcphase != _GCoff {
+			mp := acquirem()
+			gcw := &mp.p.ptr().gcw
+			// Mark the handle, since the special isn't part
+			// of the GC'd heap.
+			scanblock(uintptr(unsafe.Pointer(&s.handle)), sys.PtrSize, &oneptrmask[0], gcw)
+			if gcBlackenPromptly {
+				gcw.dispose()
+			}
+			releasem(mp)
+		}
+		// Keep p alive until the handle is set up, so that a
+		// handle never starts out pointing to a dead object.
+		KeepAlive(p)
+		return handle
+	}
+
+	// Another handle was added concurrently. Use that one, which
+	// still exists because p is kept alive.
+	lock(&mheap_.speciallock)
+	mheap_.specialweakhandlealloc.free(unsafe.Pointer(s))
+	unlock(&mheap_.speciallock)
+	handle = getWeakHandle(p)
+	if handle == nil {
+		throw("getOrAddWeakHandle: handle vanished")
+	}
+	KeepAlive(p)
+	return handle
+}
+
+// getWeakHandle returns the weak handle of the object at p in the
+// heap, or nil if it has none.
+func getWeakHandle(p unsafe.Pointer) *uintptr {
+	span := mheap_.lookupMaybe(p)
+	if span == nil {
+		throw("getWeakHandle on invalid pointer")
+	}
+
+	// Ensure that the span is swept.
+	// Sweeping accesses the specials list w/o locks, so we have
+	// to synchronize with it. And it's just much safer.
+	mp := acquirem()
+	span.ensureSwept()
+
+	offset := uintptr(p) - span.base()
+
+	var handle *uintptr
+	lock(&span.speciallock)
+	for s := span.specials; s != nil && uintptr(s.offset) <= offset; s = s.next {
+		if uintptr(s.offset) == offset && s.kind == _KindSpecialWeakHandle {
+			handle = (*specialWeakHandle)(unsafe.Pointer(s)).handle
+			break
+		}
+	}
+	unlock(&span.speciallock)
+	releasem(mp)
+	KeepAlive(p)
+	return handle
+}
+
 // Do whatever cleanup needs to be done to deallocate s. It has
 // already been unlinked from the MSpan specials list.
 func freespecial(s *special, p unsafe.Pointer, size uintptr) {
@@ -1592,6 +1763,12 @@ func freespecial(s *special, p unsafe.Pointer, size uintptr) {
 		lock(&mheap_.speciallock)
 		mheap_.specialprofilealloc.free(unsafe.Pointer(sp))
 		unlock(&mheap_.speciallock)
+	case _KindSpecialWeakHandle:
+		sw := (*specialWeakHandle)(unsafe.Pointer(s))
+		atomic.Storeuintptr(sw.handle, 0)
+		lock(&mheap_.speciallock)
+		mheap_.specialweakhandlealloc.free(unsafe.Pointer(sw))
+		unlock(&mheap_.speciallock)
 	default:
 		throw("bad special kind")
 		panic("not reached")
@@ -52,6 +52,12 @@ type bucket struct {
 	hash    uintptr
 	size    uintptr
 	nstk    uintptr
+
+	// atyp is the type of the objects allocated in a memProfile
+	// bucket, or nil if unknown. Types live in read-only data or,
+	// for types made by reflect, are kept alive by reflect's
+	// caches, so the GC need not see this pointer.
+	atyp *_type
 }
 
 // A memRecord is the bucket data for a bucket of type memProfile,
@@ -201,8 +207,9 @@ func (b *bucket) bp() *blockRecord {
 	return (*blockRecord)(data)
 }
 
-// Return the bucket for stk[0:nstk], allocating new bucket if needed.
-func stkbucket(typ bucketType, size uintptr, stk []uintptr, alloc bool) *bucket {
+// Return the bucket for stk[0:nstk] and the allocated type atyp,
+// allocating new bucket if needed.
+func stkbucket(typ bucketType, size uintptr, atyp *_type, stk []uintptr, alloc bool) *bucket {
 	if buckhash == nil {
 		buckhash = (*[buckHashSize]*bucket)(sysAlloc(unsafe.Sizeof(*buckhash), &memstats.buckhash_sys))
 		if buckhash == nil {
@@ -221,13 +228,17 @@ func stkbucket(typ bucketType, size uintptr, stk []uintptr, alloc bool) *bucket
 	h += size
 	h += h << 10
 	h ^= h >> 6
+	// hash in type
+	h += uintptr(unsafe.Pointer(atyp))
+	h += h << 10
+	h ^= h >> 6
 	// finalize
 	h += h << 3
 	h ^= h >> 11
 
 	i := int(h % buckHashSize)
 	for b := buckhash[i]; b != nil; b = b.next {
-		if b.typ == typ && b.hash == h && b.size == size && eqslice(b.stk(), stk) {
+		if b.typ == typ && b.hash == h && b.size == size && b.atyp == atyp && eqslice(b.stk(), stk) {
 			return b
 		}
 	}
@@ -241,6 +252,7 @@ func stkbucket(typ bucketType, size uintptr, stk []uintptr, alloc bool) *bucket
 	copy(b.stk(), stk)
 	b.hash = h
 	b.size = size
+	b.atyp = atyp
 	b.next = buckhash[i]
 	buckhash[i] = b
 	if typ == memProfile {
@@ -336,12 +348,13 @@ func mProf_PostSweep() {
 	unlock(&proflock)
 }
 
-// Called by malloc to record a profiled block.
-func mProf_Malloc(p unsafe.Pointer, size uintptr) {
+// Called by malloc to record a profiled block of type typ, which may
+// be nil. Frees are counted in the same bucket, and so by type too.
+func mProf_Malloc(p unsafe.Pointer, size uintptr, typ *_type) {
 	var stk [maxStack]uintptr
 	nstk := callers(4, stk[:])
 	lock(&proflock)
-	b := stkbucket(memProfile, size, stk[:nstk], true)
+	b := stkbucket(memProfile, size, typ, stk[:nstk], true)
 	c := mProf.cycle
 	mp := b.mp()
 	mpc := &mp.future[(c+2)%uint32(len(mp.future))]
@@ -421,7 +434,7 @@ func saveblockevent(cycles int64, skip int, which bucketType) {
 		nstk = gcallers(gp.m.curg, skip, stk[:])
 	}
 	lock(&proflock)
-	b := stkbucket(which, 0, stk[:nstk], true)
+	b := stkbucket(which, 0, nil, stk[:nstk], true)
 	b.bp().count++
 	b.bp().cycles += cycles
 	unlock(&proflock)
@@ -498,6 +511,13 @@ type MemProfileRecord struct {
 	AllocBytes, FreeBytes     int64       // number of bytes allocated, freed
 	AllocObjects, FreeObjects int64       // number of objects allocated, freed
 	Stack0                    [32]uintptr // stack trace for this record; ends at first 0 entry
+
+	// Type is the name of the type of the allocated objects, or of
+	// their elements for slices, as printed by the %T verb of package
+	// fmt. It is "" for memory without a Go type, such as the bytes
+	// of a string. The allocations of a call sequence are recorded
+	// separately for each type.
+	Type string
 }
 
 // InUseBytes returns the number of bytes in use (AllocBytes - FreeBytes).
@@ -595,6 +615,10 @@ func record(r *MemProfileRecord, b *bucket) {
 	r.FreeBytes = int64(mp.active.free_bytes)
 	r.AllocObjects = int64(mp.active.allocs)
 	r.FreeObjects = int64(mp.active.frees)
+	r.Type = ""
+	if b.atyp != nil {
+		r.Type = b.atyp.string()
+	}
 	if raceenabled {
 		racewriterangepc(unsafe.Pointer(&r.Stack0[0]), unsafe.Sizeof(r.Stack0), getcallerpc(), funcPC(MemProfile))
 	}
@@ -718,6 +742,24 @@ func ThreadCreateProfile(p []StackRecord) (n int, ok bool) {
 // Most clients should use the runtime/pprof package instead
 // of calling GoroutineProfile directly.
 func GoroutineProfile(p []StackRecord) (n int, ok bool) {
+	return goroutineProfileWithLabels(p, nil)
+}
+
+// pprof_goroutineProfileWithLabels is like GoroutineProfile, but also
+// returns the profiler labels of each goroutine in labels.
+//
+//go:linkname pprof_goroutineProfileWithLabels runtime/pprof.runtime_goroutineProfileWithLabels
+func pprof_goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
+	return goroutineProfileWithLabels(p, labels)
+}
+
+// goroutineProfileWithLabels implements GoroutineProfile. If labels is
+// not nil, it must be as long as p, and labels[i] is set to the profiler
+// labels of the goroutine recorded in p[i].
+func goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
+	if labels != nil && len(labels) != len(p) {
+		labels = nil
+	}
 	gp := getg()
 
 	isOK := func(gp1 *g) bool {
@@ -737,7 +779,7 @@ func GoroutineProfile(p []StackRecord) (n int, ok bool) {
 
 	if n <= len(p) {
 		ok = true
-		r := p
+		r, lbl := p, labels
 
 		// Save current goroutine.
 		sp := getcallersp(unsafe.Pointer(&p))
@@ -746,6 +788,10 @@ func GoroutineProfile(p []StackRecord) (n int, ok bool) {
 			saveg(pc, sp, gp, &r[0])
 		})
 		r = r[1:]
+		if labels != nil {
+			lbl[0] = gp.labels
+			lbl = lbl[1:]
+		}
 
 		// Save other goroutines.
 		for _, gp1 := range allgs {
@@ -757,6 +803,70 @@ func GoroutineProfile(p []StackRecord) (n int, ok bool) {
 				}
 				saveg(^uintptr(0), ^uintptr(0), gp1, &r[0])
 				r = r[1:]
+				if labels != nil {
+					lbl[0] = gp1.labels
+					lbl = lbl[1:]
+				}
+			}
+		}
+	}
+
+	startTheWorld()
+
+	return n, ok
+}
+
+//go:linkname pprof_detectGoroutineLeaks runtime/pprof.runtime_detectGoroutineLeaks
+func pprof_detectGoroutineLeaks() {
+	detectGoroutineLeaks()
+}
+
+// pprof_goroutineLeakProfile is like GoroutineProfile, but only
+// returns the goroutines found to be leaked by the last leak
+// detection cycle. The last PC of each record is that of the go
+// statement that created the goroutine. If labels is not nil, it must be
+// as long as p, and labels[i] is set to the profiler labels of the
+// goroutine recorded in p[i].
+//
+//go:linkname pprof_goroutineLeakProfile runtime/pprof.runtime_goroutineLeakProfile
+func pprof_goroutineLeakProfile(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
+	if labels != nil && len(labels) != len(p) {
+		labels = nil
+	}
+	isLeaked := func(gp *g) bool {
+		return gp.leaked && readgstatus(gp) == _Gwaiting
+	}
+
+	stopTheWorld("profile")
+
+	for _, gp := range allgs {
+		if isLeaked(gp) {
+			n++
+		}
+	}
+
+	if n <= len(p) {
+		ok = true
+		r, lbl := p, labels
+		for _, gp := range allgs {
+			if !isLeaked(gp) {
+				continue
+			}
+			if len(r) == 0 {
+				// Should be impossible, but better to return a
+				// truncated profile than to crash the entire process.
+				break
+			}
+			stk := r[0].Stack0[:]
+			depth := gentraceback(^uintptr(0), ^uintptr(0), 0, gp, 0, &stk[0], len(stk)-1, nil, nil, 0)
+			stk[depth] = gp.gopc
+			if depth+1 < len(stk) {
+				stk[depth+1] = 0
+			}
+			r = r[1:]
+			if labels != nil {
+				lbl[0] = gp.labels
+				lbl = lbl[1:]
 			}
 		}
 	}
@@ -788,20 +898,7 @@ func Stack(buf []byte, all bool) int {
 		sp := getcallersp(unsafe.Pointer(&buf))
 		pc := getcallerpc()
 		systemstack(func() {
-			g0 := getg()
-			// Force traceback=1 to override GOTRACEBACK setting,
-			// so that Stack's results are consistent.
-			// GOTRACEBACK is only about crash dumps.
-			g0.m.traceback = 1
-			g0.writebuf = buf[0:0:len(buf)]
-			goroutineheader(gp)
-			traceback(pc, sp, 0, gp)
-			if all {
-				tracebackothers(gp)
-			}
-			g0.m.traceback = 0
-			n = len(g0.writebuf)
-			g0.writebuf = nil
+			n = writeStacks(buf, gp, pc, sp, all, false)
 		})
 	}
 
@@ -811,6 +908,51 @@ func Stack(buf []byte, all bool) int {
 	return n
 }
 
+// pprof_goroutineStacks is like Stack(buf, true), but the goroutine
+// headers include the profiler labels of each goroutine.
+//
+//go:linkname pprof_goroutineStacks runtime/pprof.runtime_goroutineStacks
+func pprof_goroutineStacks(buf []byte) int {
+	stopTheWorld("stack trace")
+
+	n := 0
+	if len(buf) > 0 {
+		gp := getg()
+		sp := getcallersp(unsafe.Pointer(&buf))
+		pc := getcallerpc()
+		systemstack(func() {
+			n = writeStacks(buf, gp, pc, sp, true, true)
+		})
+	}
+
+	startTheWorld()
+	return n
+}
+
+// writeStacks formats the stack trace of gp, starting at pc and sp, into
+// buf, followed by the traces of all other goroutines if all is set. If
+// labels is set, the goroutine headers include the profiler labels.
+// It returns the number of bytes written. It must run on the system stack.
+func writeStacks(buf []byte, gp *g, pc, sp uintptr, all, labels bool) int {
+	g0 := getg()
+	// Force traceback=1 to override GOTRACEBACK setting,
+	// so that Stack's results are consistent.
+	// GOTRACEBACK is only about crash dumps.
+	g0.m.traceback = 1
+	g0.m.tracebacklabels = labels
+	g0.writebuf = buf[0:0:len(buf)]
+	goroutineheader(gp)
+	traceback(pc, sp, 0, gp)
+	if all {
+		tracebackothers(gp)
+	}
+	g0.m.traceback = 0
+	g0.m.tracebacklabels = false
+	n := len(g0.writebuf)
+	g0.writebuf = nil
+	return n
+}
+
 // Tracing of alloc/free/gc.
 
 var tracelock mutex
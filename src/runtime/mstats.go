@@ -52,6 +52,8 @@ type mstats struct {
 	heap_inuse uint64 // bytes in _MSpanInUse spans
 	// 当前已归还操作系统系统的内存
 	heap_released uint64 // bytes released to the os
+	// 后台scavenger累计归还操作系统的内存
+	heap_scavenged uint64 // cumulative bytes released by the background scavenger
 	// 正在使用的对象数量，不包含闲置链表
 	heap_objects uint64 // total number of allocated objects
 
@@ -290,8 +292,20 @@ type MemStats struct {
 	//
 	// This counts heap memory from idle spans that was returned
 	// to the OS and has not yet been reacquired for the heap.
+	// Memory is released both by the background scavenger, which
+	// keeps the retained heap close to the heap goal, and by the
+	// periodic scavenger, which releases spans that have been idle
+	// for several minutes.
 	HeapReleased uint64
 
+	// HeapScavenged is cumulative bytes of heap memory returned to
+	// the OS by the background scavenger.
+	//
+	// Unlike HeapReleased, it does not decrease when released
+	// memory is reacquired for the heap, so the difference between
+	// two readings is how much the scavenger released in between.
+	HeapScavenged uint64
+
 	// HeapObjects is the number of allocated heap objects.
 	//
 	// Like HeapAlloc, this increases as objects are allocated and
@@ -650,21 +664,12 @@ func flushallmcaches() {
 //go:nosplit
 func purgecachedstats(c *mcache) {
 	// Protected by either heap or GC lock.
-	h := &mheap_
 	memstats.heap_scan += uint64(c.local_scan)
 	c.local_scan = 0
 	memstats.tinyallocs += uint64(c.local_tinyallocs)
 	c.local_tinyallocs = 0
 	memstats.nlookup += uint64(c.local_nlookup)
 	c.local_nlookup = 0
-	h.largefree += uint64(c.local_largefree)
-	c.local_largefree = 0
-	h.nlargefree += uint64(c.local_nlargefree)
-	c.local_nlargefree = 0
-	for i := 0; i < len(c.local_nsmallfree); i++ {
-		h.nsmallfree[i] += uint64(c.local_nsmallfree[i])
-		c.local_nsmallfree[i] = 0
-	}
 }
 
 // Atomically increases a given *system* memory stat. We are counting on this
@@ -16,6 +16,11 @@ import (
 // func netpollinit()			// to initialize the poller
 // func netpollopen(fd uintptr, pd *pollDesc) int32	// to arm edge-triggered notifications
 // and associate fd with pd.
+// func netpoll(delay int64) *g	// to poll the network, blocking for up to delay
+// nanoseconds if delay > 0, indefinitely if delay < 0.
+// func netpollBreak()		// to wake up a netpoll blocked in another thread
+// func netpollIsPollDescriptor(fd uintptr) bool	// to report whether fd is used
+// by the poller itself.
 // An implementation must call the following function to denote that the pd is ready.
 // func netpollready(gpp **g, pd *pollDesc, mode int32)
 
@@ -90,7 +95,9 @@ type pollCache struct {
 }
 
 var (
-	netpollInited  uint32
+	netpollInitLock mutex
+	netpollInited   uint32
+
 	pollcache      pollCache
 	netpollWaiters uint32
 )
@@ -98,20 +105,33 @@ var (
 //go:linkname poll_runtime_pollServerInit internal/poll.runtime_pollServerInit
 // poll 服务初始化，只会调用一次
 func poll_runtime_pollServerInit() {
-	netpollinit()
-	atomic.Store(&netpollInited, 1)
+	netpollGenericInit()
+}
+
+// netpollGenericInit initializes the poller the first time it is
+// called. Besides package internal/poll, the timer code uses it,
+// since the scheduler waits for timers in netpoll.
+func netpollGenericInit() {
+	if atomic.Load(&netpollInited) == 0 {
+		lock(&netpollInitLock)
+		if netpollInited == 0 {
+			netpollinit()
+			atomic.Store(&netpollInited, 1)
+		}
+		unlock(&netpollInitLock)
+	}
 }
 
 func netpollinited() bool {
 	return atomic.Load(&netpollInited) != 0
 }
 
-//go:linkname poll_runtime_pollServerDescriptor internal/poll.runtime_pollServerDescriptor
+//go:linkname poll_runtime_isPollServerDescriptor internal/poll.runtime_isPollServerDescriptor
 
-// poll_runtime_pollServerDescriptor returns the descriptor being used,
-// or ^uintptr(0) if the system does not use a poll descriptor.
-func poll_runtime_pollServerDescriptor() uintptr {
-	return netpolldescriptor()
+// poll_runtime_isPollServerDescriptor reports whether fd is a
+// descriptor being used by netpoll.
+func poll_runtime_isPollServerDescriptor(fd uintptr) bool {
+	return netpollIsPollDescriptor(fd)
 }
 
 //go:linkname poll_runtime_pollOpen internal/poll.runtime_pollOpen
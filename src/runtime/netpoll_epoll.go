@@ -12,7 +12,10 @@
 
 package runtime
 
-import "unsafe"
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
 
 func epollcreate(size int32) int32
 func epollcreate1(flags int32) int32
@@ -23,28 +26,49 @@ func epollctl(epfd, op, fd int32, ev *epollevent) int32
 //go:noescape
 func epollwait(epfd int32, ev *epollevent, nev, timeout int32) int32
 func closeonexec(fd int32)
+func pipe2(flags int32) (r, w int32, errno int32)
 
 var (
 	epfd int32 = -1 // epoll descriptor
+
+	netpollBreakRd, netpollBreakWr uintptr // for netpollBreak
+
+	netpollWakeSig uint32 // used to avoid duplicate calls of netpollBreak
 )
 
 // 网络轮询器的初始化，创建epoll的实例和设置它的文件描述符 epfd
 func netpollinit() {
 	epfd = epollcreate1(_EPOLL_CLOEXEC)
-	if epfd >= 0 {
-		return
-	}
-	epfd = epollcreate(1024)
-	if epfd >= 0 {
+	if epfd < 0 {
+		epfd = epollcreate(1024)
+		if epfd < 0 {
+			println("runtime: epollcreate failed with", -epfd)
+			throw("runtime: netpollinit failed")
+		}
 		closeonexec(epfd)
-		return
 	}
-	println("runtime: epollcreate failed with", -epfd)
-	throw("runtime: netpollinit failed")
+	r, w, errno := pipe2(_O_NONBLOCK | _O_CLOEXEC)
+	if errno != 0 {
+		println("runtime: pipe failed with", -errno)
+		throw("runtime: pipe failed")
+	}
+	ev := epollevent{
+		events: _EPOLLIN,
+	}
+	*(**uintptr)(unsafe.Pointer(&ev.data)) = &netpollBreakRd
+	errno = epollctl(epfd, _EPOLL_CTL_ADD, r, &ev)
+	if errno != 0 {
+		println("runtime: epollctl failed with", -errno)
+		throw("runtime: epollctl failed")
+	}
+	netpollBreakRd = uintptr(r)
+	netpollBreakWr = uintptr(w)
 }
 
-func netpolldescriptor() uintptr {
-	return uintptr(epfd)
+// netpollIsPollDescriptor reports whether fd is a descriptor being
+// used by the poller itself.
+func netpollIsPollDescriptor(fd uintptr) bool {
+	return fd == uintptr(epfd) || fd == netpollBreakRd || fd == netpollBreakWr
 }
 
 // EPOLLIN - 当关联的文件可以执行 read ()操作时。
@@ -75,6 +99,18 @@ func netpollarm(pd *pollDesc, mode int) {
 	throw("runtime: unused")
 }
 
+// netpollBreak interrupts an epollwait.
+func netpollBreak() {
+	if !atomic.Cas(&netpollWakeSig, 0, 1) {
+		return
+	}
+	// The pipe is nonblocking. If the write fails because the pipe
+	// is full, there are already unread wakeups pending, which is
+	// all that netpollBreak needs.
+	var b byte
+	write(netpollBreakWr, unsafe.Pointer(&b), 1)
+}
+
 // polls for ready network connections
 // returns list of goroutines that become runnable
 // 返回可变成可运行的G列表
@@ -83,14 +119,26 @@ func netpollarm(pd *pollDesc, mode int) {
 // 	一个goroutine因为net io读取阻塞了，此时goroutine会进入Gwaiting状态
 // 	当有一个数据发送给这个goroutine io fd时，如果M执行了netpoll函数，就会获取到这个G
 //  这就是golang实现底层用非阻塞io来实现用户层阻塞io的一部分。
-func netpoll(block bool) *g {
+func netpoll(delay int64) *g {
 	if epfd == -1 {
 		return nil
 	}
-	waitms := int32(-1)
-	// 如果是非阻塞的调用，设置waitms = 0
-	if !block {
+	// delay < 0: block indefinitely
+	// delay == 0: does not block, just polls
+	// delay > 0: block for up to that many nanoseconds
+	var waitms int32
+	if delay < 0 {
+		waitms = -1
+	} else if delay == 0 {
 		waitms = 0
+	} else if delay < 1e6 {
+		waitms = 1
+	} else if delay < 1e15 {
+		waitms = int32(delay / 1e6)
+	} else {
+		// An arbitrary cap on how long to wait for a timer.
+		// 1e9 ms == ~11.5 days.
+		waitms = 1e9
 	}
 	var events [128]epollevent
 retry:
@@ -101,6 +149,11 @@ retry:
 			println("runtime: epollwait on fd", epfd, "failed with", -n)
 			throw("runtime: netpoll failed")
 		}
+		// If a timed sleep was interrupted, just return to
+		// recalculate how long we should sleep now.
+		if waitms > 0 {
+			return nil
+		}
 		goto retry
 	}
 	var gp guintptr
@@ -109,6 +162,23 @@ retry:
 		if ev.events == 0 {
 			continue
 		}
+
+		if *(**uintptr)(unsafe.Pointer(&ev.data)) == &netpollBreakRd {
+			if ev.events != _EPOLLIN {
+				println("runtime: netpoll: break fd ready for", ev.events)
+				throw("runtime: netpoll: break fd ready for something unexpected")
+			}
+			if delay != 0 {
+				// netpollBreak could be picked up by a
+				// nonblocking poll. Only read the byte
+				// if blocking.
+				var tmp [16]byte
+				read(int32(netpollBreakRd), noescape(unsafe.Pointer(&tmp[0])), int32(len(tmp)))
+				atomic.Store(&netpollWakeSig, 0)
+			}
+			continue
+		}
+
 		var mode int32
 		if ev.events&(_EPOLLIN|_EPOLLRDHUP|_EPOLLHUP|_EPOLLERR) != 0 {
 			mode += 'r'
@@ -123,8 +193,5 @@ retry:
 			netpollready(&gp, pd, mode)
 		}
 	}
-	if block && gp == 0 {
-		goto retry
-	}
 	return gp.ptr()
 }
@@ -8,7 +8,10 @@ package runtime
 
 // Integrated network poller (kqueue-based implementation).
 
-import "unsafe"
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
 
 func kqueue() int32
 
@@ -18,6 +21,8 @@ func closeonexec(fd int32)
 
 var (
 	kq int32 = -1
+
+	netpollWakeSig uint32 // used to avoid duplicate calls of netpollBreak
 )
 
 func netpollinit() {
@@ -27,10 +32,13 @@ func netpollinit() {
 		throw("runtime: netpollinit failed")
 	}
 	closeonexec(kq)
+	addWakeupEvent(kq)
 }
 
-func netpolldescriptor() uintptr {
-	return uintptr(kq)
+// netpollIsPollDescriptor reports whether fd is a descriptor being
+// used by the poller itself.
+func netpollIsPollDescriptor(fd uintptr) bool {
+	return fd == uintptr(kq) || isWakeupFd(fd)
 }
 
 func netpollopen(fd uintptr, pd *pollDesc) int32 {
@@ -63,15 +71,37 @@ func netpollarm(pd *pollDesc, mode int) {
 	throw("runtime: unused")
 }
 
+// netpollBreak interrupts a kevent.
+func netpollBreak() {
+	// Failing to cas indicates there is an in-flight wakeup, so we're done here.
+	if !atomic.Cas(&netpollWakeSig, 0, 1) {
+		return
+	}
+
+	wakeNetpoll(kq)
+}
+
 // Polls for ready network connections.
 // Returns list of goroutines that become runnable.
-func netpoll(block bool) *g {
+// delay < 0: blocks indefinitely
+// delay == 0: does not block, just polls
+// delay > 0: block for up to that many nanoseconds
+func netpoll(delay int64) *g {
 	if kq == -1 {
 		return nil
 	}
 	var tp *timespec
 	var ts timespec
-	if !block {
+	if delay < 0 {
+		tp = nil
+	} else if delay == 0 {
+		tp = &ts
+	} else {
+		ts.setNsec(delay)
+		if ts.tv_sec > 1e6 {
+			// Darwin returns EINVAL if the sleep time is too long.
+			ts.tv_sec = 1e6
+		}
 		tp = &ts
 	}
 	var events [64]keventt
@@ -82,11 +112,27 @@ retry:
 			println("runtime: kevent on fd", kq, "failed with", -n)
 			throw("runtime: netpoll failed")
 		}
+		// If a timed sleep was interrupted, just return to
+		// recalculate how long we should sleep now.
+		if delay > 0 {
+			return nil
+		}
 		goto retry
 	}
 	var gp guintptr
 	for i := 0; i < int(n); i++ {
 		ev := &events[i]
+
+		if isWakeup(ev) {
+			if delay != 0 {
+				// netpollBreak could be picked up by a nonblocking poll.
+				// Only call drainWakeupEvent and reset the netpollWakeSig if blocking.
+				drainWakeupEvent(kq)
+				atomic.Store(&netpollWakeSig, 0)
+			}
+			continue
+		}
+
 		var mode int32
 		switch ev.filter {
 		case _EVFILT_READ:
@@ -111,8 +157,5 @@ retry:
 			netpollready(&gp, (*pollDesc)(unsafe.Pointer(ev.udata)), mode)
 		}
 	}
-	if block && gp == 0 {
-		goto retry
-	}
 	return gp.ptr()
 }
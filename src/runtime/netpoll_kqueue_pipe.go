@@ -0,0 +1,64 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build netbsd openbsd
+
+package runtime
+
+import "unsafe"
+
+// TODO: Use EVFILT_USER once it is available on all the systems
+// listed above.
+
+func pipe2(flags int32) (r, w int32, errno int32)
+
+var netpollBreakRd, netpollBreakWr uintptr // for netpollBreak
+
+func addWakeupEvent(kq int32) {
+	r, w, errno := pipe2(_O_NONBLOCK | _O_CLOEXEC)
+	if errno != 0 {
+		println("runtime: pipe failed with", errno)
+		throw("runtime: pipe failed")
+	}
+	ev := keventt{
+		filter: _EVFILT_READ,
+		flags:  _EV_ADD,
+	}
+	*(*uintptr)(unsafe.Pointer(&ev.ident)) = uintptr(r)
+	n := kevent(kq, &ev, 1, nil, 0, nil)
+	if n < 0 {
+		println("runtime: kevent failed with", -n)
+		throw("runtime: kevent failed")
+	}
+	netpollBreakRd = uintptr(r)
+	netpollBreakWr = uintptr(w)
+}
+
+func wakeNetpoll(_ int32) {
+	// The pipe is nonblocking. If the write fails because the pipe
+	// is full, there are already unread wakeups pending, which is
+	// all that netpollBreak needs.
+	var b byte
+	write(netpollBreakWr, unsafe.Pointer(&b), 1)
+}
+
+func isWakeup(ev *keventt) bool {
+	if uintptr(ev.ident) == netpollBreakRd {
+		if ev.filter == _EVFILT_READ {
+			return true
+		}
+		println("runtime: netpoll: break fd ready for", ev.filter)
+		throw("runtime: netpoll: break fd ready for something unexpected")
+	}
+	return false
+}
+
+func drainWakeupEvent(_ int32) {
+	var buf [16]byte
+	read(int32(netpollBreakRd), noescape(unsafe.Pointer(&buf[0])), int32(len(buf)))
+}
+
+func isWakeupFd(fd uintptr) bool {
+	return fd == netpollBreakRd || fd == netpollBreakWr
+}
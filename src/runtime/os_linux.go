@@ -5,6 +5,7 @@
 package runtime
 
 import (
+	"runtime/internal/atomic"
 	"runtime/internal/sys"
 	"unsafe"
 )
@@ -394,6 +395,8 @@ func sigprocmask(how int32, new, old *sigset) {
 func getrlimit(kind int32, limit unsafe.Pointer) int32
 func raise(sig uint32)
 func raiseproc(sig uint32)
+func getpid() int
+func tgkill(tgid, tid, sig int)
 
 //go:noescape
 func sched_getaffinity(pid, len uintptr, buf *byte) int32
@@ -452,3 +455,21 @@ func setSignalstackSP(s *stackt, sp uintptr) {
 
 func (c *sigctxt) fixsigcode(sig uint32) {
 }
+
+// preemptMSupported reports whether preemptM can asynchronously
+// preempt goroutines on this platform. The signal path is shared by
+// all Linux ports, but only amd64 implements asyncPreempt so far.
+const preemptMSupported = sys.GoarchAmd64 == 1
+
+// preemptM sends a preemption request to mp. This request may be
+// handled asynchronously and may be coalesced with other requests to
+// the M. When the request is received, if the running G or P are
+// marked for preemption and the goroutine is at an asynchronous
+// safe-point, it will preempt the goroutine. The signal handler
+// clears mp.signalPending once it has handled the request, so at most
+// one preemption signal is in flight per M.
+func preemptM(mp *m) {
+	if atomic.Cas(&mp.signalPending, 0, 1) {
+		tgkill(getpid(), int(mp.procid), sigPreempt)
+	}
+}
@@ -12,6 +12,9 @@ type mOS struct {
 	waitsemalock  int32
 }
 
+// sigPreempt is unused on nacl, which has no way to signal a thread.
+const sigPreempt = 0
+
 func nacl_exception_stack(p uintptr, size int32) int32
 func nacl_exception_handler(fn uintptr, arg unsafe.Pointer) int32
 func nacl_sem_create(flag int32) int32
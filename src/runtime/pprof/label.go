@@ -6,6 +6,9 @@ package pprof
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 )
 
 type label struct {
@@ -24,31 +27,67 @@ type labelContextKey struct{}
 func labelValue(ctx context.Context) labelMap {
 	labels, _ := ctx.Value(labelContextKey{}).(*labelMap)
 	if labels == nil {
-		return labelMap(nil)
+		return labelMap{}
 	}
 	return *labels
 }
 
 // labelMap is the representation of the label set held in the context type.
-// This is an initial implementation, but it will be replaced with something
-// that admits incremental immutable modification more efficiently.
-type labelMap map[string]string
+// Its list is sorted by key and has no duplicate keys, so that the runtime
+// can print the labels of a goroutine in tracebacks without the help of this
+// package. The runtime mirrors this layout in runtime/proflabel.go.
+type labelMap struct {
+	LabelSet
+}
+
+// String returns the labels in l as {"key":"value", ...}.
+func (l *labelMap) String() string {
+	if l == nil {
+		return "{}"
+	}
+	keyVals := make([]string, 0, len(l.list))
+	for _, lbl := range l.list {
+		keyVals = append(keyVals, fmt.Sprintf("%q:%q", lbl.key, lbl.value))
+	}
+	return "{" + strings.Join(keyVals, ", ") + "}"
+}
 
 // WithLabels returns a new context.Context with the given labels added.
 // A label overwrites a prior label with the same key.
 func WithLabels(ctx context.Context, labels LabelSet) context.Context {
-	childLabels := make(labelMap)
 	parentLabels := labelValue(ctx)
-	// TODO(matloob): replace the map implementation with something
-	// more efficient so creating a child context WithLabels doesn't need
-	// to clone the map.
-	for k, v := range parentLabels {
-		childLabels[k] = v
+	return context.WithValue(ctx, labelContextKey{}, &labelMap{mergeLabelSets(parentLabels.LabelSet, labels)})
+}
+
+// mergeLabelSets returns the sorted union of left and right, which must be
+// sorted and free of duplicates. A label in right overwrites a label in
+// left with the same key.
+func mergeLabelSets(left, right LabelSet) LabelSet {
+	if len(left.list) == 0 {
+		return right
+	} else if len(right.list) == 0 {
+		return left
 	}
-	for _, label := range labels.list {
-		childLabels[label.key] = label.value
+
+	list := make([]label, 0, len(left.list)+len(right.list))
+	l, r := 0, 0
+	for l < len(left.list) && r < len(right.list) {
+		switch {
+		case left.list[l].key < right.list[r].key:
+			list = append(list, left.list[l])
+			l++
+		case left.list[l].key > right.list[r].key:
+			list = append(list, right.list[r])
+			r++
+		default:
+			list = append(list, right.list[r])
+			l++
+			r++
+		}
 	}
-	return context.WithValue(ctx, labelContextKey{}, &childLabels)
+	list = append(list, left.list[l:]...)
+	list = append(list, right.list[r:]...)
+	return LabelSet{list: list}
 }
 
 // Labels takes an even number of strings representing key-value pairs
@@ -58,27 +97,44 @@ func Labels(args ...string) LabelSet {
 	if len(args)%2 != 0 {
 		panic("uneven number of arguments to pprof.Labels")
 	}
-	labels := LabelSet{}
+	list := make([]label, 0, len(args)/2)
 	for i := 0; i+1 < len(args); i += 2 {
-		labels.list = append(labels.list, label{key: args[i], value: args[i+1]})
+		list = append(list, label{key: args[i], value: args[i+1]})
+	}
+	// Keep the list sorted by key, and keep only the last of the
+	// labels with the same key.
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].key < list[j].key
+	})
+	deduped := list[:0]
+	for _, lbl := range list {
+		if n := len(deduped); n > 0 && deduped[n-1].key == lbl.key {
+			deduped[n-1] = lbl
+			continue
+		}
+		deduped = append(deduped, lbl)
 	}
-	return labels
+	return LabelSet{list: deduped}
 }
 
 // Label returns the value of the label with the given key on ctx, and a boolean indicating
 // whether that label exists.
 func Label(ctx context.Context, key string) (string, bool) {
 	ctxLabels := labelValue(ctx)
-	v, ok := ctxLabels[key]
-	return v, ok
+	for _, lbl := range ctxLabels.list {
+		if lbl.key == key {
+			return lbl.value, true
+		}
+	}
+	return "", false
 }
 
 // ForLabels invokes f with each label set on the context.
 // The function f should return true to continue iteration or false to stop iteration early.
 func ForLabels(ctx context.Context, f func(key, value string) bool) {
 	ctxLabels := labelValue(ctx)
-	for k, v := range ctxLabels {
-		if !f(k, v) {
+	for _, lbl := range ctxLabels.list {
+		if !f(lbl.key, lbl.value) {
 			break
 		}
 	}
@@ -80,3 +80,27 @@ func TestContextLabels(t *testing.T) {
 		t.Errorf("(sorted) labels on context: got %v, want %v", gotLabels, wantLabels)
 	}
 }
+
+func TestLabelMapStringer(t *testing.T) {
+	for _, tbl := range []struct {
+		m        labelMap
+		expected string
+	}{
+		{
+			m: labelMap{
+				// empty map
+			},
+			expected: "{}",
+		}, {
+			m:        labelMap{Labels("foo", "bar")},
+			expected: `{"foo":"bar"}`,
+		}, {
+			m:        labelMap{Labels("foo", "bar", "key1", "value1", "key2", "value2", "key1", "value3")},
+			expected: `{"foo":"bar", "key1":"value3", "key2":"value2"}`,
+		},
+	} {
+		if got := tbl.m.String(); tbl.expected != got {
+			t.Errorf("%#v: expected %q, got %q", tbl.m, tbl.expected, got)
+		}
+	}
+}
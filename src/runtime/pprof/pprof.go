@@ -77,6 +77,7 @@ import (
 	"fmt"
 	"io"
 	"runtime"
+	"runtime/debug"
 	"sort"
 	"strings"
 	"sync"
@@ -98,11 +99,12 @@ import (
 //
 // Each Profile has a unique name. A few profiles are predefined:
 //
-//	goroutine    - stack traces of all current goroutines
-//	heap         - a sampling of all heap allocations
-//	threadcreate - stack traces that led to the creation of new OS threads
-//	block        - stack traces that led to blocking on synchronization primitives
-//	mutex        - stack traces of holders of contended mutexes
+//	goroutine     - stack traces of all current goroutines
+//	goroutineleak - stack traces of goroutines blocked forever
+//	heap          - a sampling of all heap allocations
+//	threadcreate  - stack traces that led to the creation of new OS threads
+//	block         - stack traces that led to blocking on synchronization primitives
+//	mutex         - stack traces of holders of contended mutexes
 //
 // These predefined profiles maintain themselves and panic on an explicit
 // Add or Remove method call.
@@ -113,6 +115,26 @@ import (
 // If there has been no garbage collection at all, the heap profile reports
 // all known allocations. This exception helps mainly in programs running
 // without garbage collection enabled, usually for debugging purposes.
+// Each sample of the heap profile carries the type of the allocated
+// objects, when known, in a "type" label, so that 'go tool pprof -tags'
+// breaks the heap down by Go type as well as by call site.
+//
+// The goroutineleak profile runs a garbage collection that finds the
+// goroutines blocked on channels or sync objects that no goroutine that
+// could run again can reach, and so will never be woken. It may miss some
+// leaks, but never reports a goroutine that can still be woken. Each stack
+// ends with the go statement that created the goroutine. The collection
+// stops the world for its whole mark phase.
+//
+// The goroutine profile with debug=1 ends with a summary of the scheduler
+// latency, the time goroutines spend runnable before they run, and of the
+// time goroutines spent waiting by wait reason. A long latency tail means
+// that goroutines are starved of CPU. See runtime/debug.ReadSchedStats.
+//
+// The goroutine and goroutineleak profiles carry the profiler labels
+// (see Do) that each goroutine had when the profile was taken. Goroutines
+// with the same stack but different labels are counted separately.
+// With debug=2, the labels are printed in each goroutine header.
 //
 // The CPU profile is not available as a Profile. It has a special API,
 // the StartCPUProfile and StopCPUProfile functions, because it streams
@@ -138,6 +160,12 @@ var goroutineProfile = &Profile{
 	write: writeGoroutine,
 }
 
+var goroutineLeakProfile = &Profile{
+	name:  "goroutineleak",
+	count: countGoroutineLeak,
+	write: writeGoroutineLeak,
+}
+
 var threadcreateProfile = &Profile{
 	name:  "threadcreate",
 	count: countThreadCreate,
@@ -168,11 +196,12 @@ func lockProfiles() {
 	if profiles.m == nil {
 		// Initial built-in profiles.
 		profiles.m = map[string]*Profile{
-			"goroutine":    goroutineProfile,
-			"threadcreate": threadcreateProfile,
-			"heap":         heapProfile,
-			"block":        blockProfile,
-			"mutex":        mutexProfile,
+			"goroutine":     goroutineProfile,
+			"goroutineleak": goroutineLeakProfile,
+			"threadcreate":  threadcreateProfile,
+			"heap":          heapProfile,
+			"block":         blockProfile,
+			"mutex":         mutexProfile,
 		}
 	}
 }
@@ -337,14 +366,17 @@ type stackProfile [][]uintptr
 
 func (x stackProfile) Len() int              { return len(x) }
 func (x stackProfile) Stack(i int) []uintptr { return x[i] }
+func (x stackProfile) Label(i int) *labelMap { return nil }
 
 // A countProfile is a set of stack traces to be printed as counts
 // grouped by stack trace. There are multiple implementations:
 // all that matters is that we can find out how many traces there are
-// and obtain each trace in turn.
+// and obtain each trace in turn, along with the profiler labels of
+// the goroutine it was taken from, if any.
 type countProfile interface {
 	Len() int
 	Stack(i int) []uintptr
+	Label(i int) *labelMap
 }
 
 // printCountCycleProfile outputs block profile records (for block or mutex profiles)
@@ -389,12 +421,16 @@ func printCountCycleProfile(w io.Writer, countName, cycleName string, scaler fun
 func printCountProfile(w io.Writer, debug int, name string, p countProfile) error {
 	// Build count of each stack.
 	var buf bytes.Buffer
-	key := func(stk []uintptr) string {
+	key := func(stk []uintptr, lbls *labelMap) string {
 		buf.Reset()
 		fmt.Fprintf(&buf, "@")
 		for _, pc := range stk {
 			fmt.Fprintf(&buf, " %#x", pc)
 		}
+		if lbls != nil && len(lbls.list) > 0 {
+			buf.WriteString("\n# labels: ")
+			buf.WriteString(lbls.String())
+		}
 		return buf.String()
 	}
 	count := map[string]int{}
@@ -402,7 +438,7 @@ func printCountProfile(w io.Writer, debug int, name string, p countProfile) erro
 	var keys []string
 	n := p.Len()
 	for i := 0; i < n; i++ {
-		k := key(p.Stack(i))
+		k := key(p.Stack(i), p.Label(i))
 		if count[k] == 0 {
 			index[k] = i
 			keys = append(keys, k)
@@ -433,8 +469,9 @@ func printCountProfile(w io.Writer, debug int, name string, p countProfile) erro
 	var locs []uint64
 	for _, k := range keys {
 		values[0] = int64(count[k])
+		idx := index[k]
 		locs = locs[:0]
-		for _, addr := range p.Stack(index[k]) {
+		for _, addr := range p.Stack(idx) {
 			// For count profiles, all stack addresses are
 			// return PCs, which is what locForPC expects.
 			l := b.locForPC(addr)
@@ -443,7 +480,15 @@ func printCountProfile(w io.Writer, debug int, name string, p countProfile) erro
 			}
 			locs = append(locs, l)
 		}
-		b.pbSample(values, locs, nil)
+		var labels func()
+		if lbls := p.Label(idx); lbls != nil {
+			labels = func() {
+				for _, lbl := range lbls.list {
+					b.pbLabel(tagSample_Label, lbl.key, lbl.value, 0)
+				}
+			}
+		}
+		b.pbSample(values, locs, labels)
 	}
 	b.build()
 	return nil
@@ -577,6 +622,9 @@ func writeHeap(w io.Writer, debug int) error {
 			fmt.Fprintf(w, " %#x", pc)
 		}
 		fmt.Fprintf(w, "\n")
+		if r.Type != "" {
+			fmt.Fprintf(w, "#\ttype %s\n", r.Type)
+		}
 		printStackRecord(w, r.Stack(), false)
 	}
 
@@ -596,6 +644,7 @@ func writeHeap(w io.Writer, debug int) error {
 	fmt.Fprintf(w, "# HeapIdle = %d\n", s.HeapIdle)
 	fmt.Fprintf(w, "# HeapInuse = %d\n", s.HeapInuse)
 	fmt.Fprintf(w, "# HeapReleased = %d\n", s.HeapReleased)
+	fmt.Fprintf(w, "# HeapScavenged = %d\n", s.HeapScavenged)
 	fmt.Fprintf(w, "# HeapObjects = %d\n", s.HeapObjects)
 
 	fmt.Fprintf(w, "# Stack = %d / %d\n", s.StackInuse, s.StackSys)
@@ -626,7 +675,11 @@ func countThreadCreate() int {
 
 // writeThreadCreate writes the current runtime ThreadCreateProfile to w.
 func writeThreadCreate(w io.Writer, debug int) error {
-	return writeRuntimeProfile(w, debug, "threadcreate", runtime.ThreadCreateProfile)
+	// Threads are not created on behalf of a labeled goroutine in any
+	// useful sense, so the threadcreate profile has no labels.
+	return writeRuntimeProfile(w, debug, "threadcreate", func(p []runtime.StackRecord, _ []unsafe.Pointer) (n int, ok bool) {
+		return runtime.ThreadCreateProfile(p)
+	})
 }
 
 // countGoroutine returns the number of goroutines.
@@ -639,16 +692,62 @@ func writeGoroutine(w io.Writer, debug int) error {
 	if debug >= 2 {
 		return writeGoroutineStacks(w)
 	}
-	return writeRuntimeProfile(w, debug, "goroutine", runtime.GoroutineProfile)
+	if err := writeRuntimeProfile(w, debug, "goroutine", runtime_goroutineProfileWithLabels); err != nil || debug == 0 {
+		return err
+	}
+	return writeSchedStats(w)
+}
+
+// writeSchedStats writes the scheduler latency and the time spent
+// waiting by wait reason, as comments that pprof ignores.
+func writeSchedStats(w io.Writer) error {
+	var s debug.SchedStats
+	s.LatencyQuantiles = make([]time.Duration, 101)
+	debug.ReadSchedStats(&s)
+	q := s.LatencyQuantiles
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "\n# runtime/debug.SchedStats\n")
+	fmt.Fprintf(b, "# Latency p50 = %v, p90 = %v, p99 = %v, max = %v\n", q[50], q[90], q[99], q[100])
+	for _, wt := range s.Waits {
+		fmt.Fprintf(b, "# Wait %q = %v in %d waits\n", wt.Reason, wt.Total, wt.Count)
+	}
+	return b.Flush()
+}
+
+// runtime_goroutineProfileWithLabels is defined in runtime/mprof.go.
+func runtime_goroutineProfileWithLabels(p []runtime.StackRecord, labels []unsafe.Pointer) (n int, ok bool)
+
+// countGoroutineLeak returns the number of goroutines found to be
+// leaked by the last leak detection cycle.
+func countGoroutineLeak() int {
+	n, _ := runtime_goroutineLeakProfile(nil, nil)
+	return n
+}
+
+// writeGoroutineLeak looks for leaked goroutines and writes their
+// stacks to w.
+func writeGoroutineLeak(w io.Writer, debug int) error {
+	runtime_detectGoroutineLeaks()
+	return writeRuntimeProfile(w, debug, "goroutineleak", runtime_goroutineLeakProfile)
 }
 
+// runtime_detectGoroutineLeaks is defined in runtime/mprof.go.
+func runtime_detectGoroutineLeaks()
+
+// runtime_goroutineLeakProfile is defined in runtime/mprof.go.
+func runtime_goroutineLeakProfile(p []runtime.StackRecord, labels []unsafe.Pointer) (n int, ok bool)
+
+// runtime_goroutineStacks is defined in runtime/mprof.go.
+func runtime_goroutineStacks(buf []byte) int
+
 func writeGoroutineStacks(w io.Writer) error {
 	// We don't know how big the buffer needs to be to collect
 	// all the goroutines. Start with 1 MB and try a few times, doubling each time.
 	// Give up and use a truncated trace if 64 MB is not enough.
 	buf := make([]byte, 1<<20)
 	for i := 0; ; i++ {
-		n := runtime.Stack(buf, true)
+		n := runtime_goroutineStacks(buf)
 		if n < len(buf) {
 			buf = buf[:n]
 			break
@@ -663,7 +762,7 @@ func writeGoroutineStacks(w io.Writer) error {
 	return err
 }
 
-func writeRuntimeProfile(w io.Writer, debug int, name string, fetch func([]runtime.StackRecord) (int, bool)) error {
+func writeRuntimeProfile(w io.Writer, debug int, name string, fetch func([]runtime.StackRecord, []unsafe.Pointer) (int, bool)) error {
 	// Find out how many records there are (fetch(nil)),
 	// allocate that many records, and get the data.
 	// There's a race—more records might be added between
@@ -671,13 +770,15 @@ func writeRuntimeProfile(w io.Writer, debug int, name string, fetch func([]runti
 	// and also try again if we're very unlucky.
 	// The loop should only execute one iteration in the common case.
 	var p []runtime.StackRecord
-	n, ok := fetch(nil)
+	var labels []unsafe.Pointer
+	n, ok := fetch(nil, nil)
 	for {
 		// Allocate room for a slightly bigger profile,
 		// in case a few more entries have been added
 		// since the call to ThreadProfile.
 		p = make([]runtime.StackRecord, n+10)
-		n, ok = fetch(p)
+		labels = make([]unsafe.Pointer, n+10)
+		n, ok = fetch(p, labels)
 		if ok {
 			p = p[0:n]
 			break
@@ -685,13 +786,19 @@ func writeRuntimeProfile(w io.Writer, debug int, name string, fetch func([]runti
 		// Profile grew; try again.
 	}
 
-	return printCountProfile(w, debug, name, runtimeProfile(p))
+	return printCountProfile(w, debug, name, &runtimeProfile{p, labels})
 }
 
-type runtimeProfile []runtime.StackRecord
+// runtimeProfile is a countProfile of runtime stack records. labels
+// holds the *labelMap of the goroutine each record was taken from.
+type runtimeProfile struct {
+	stk    []runtime.StackRecord
+	labels []unsafe.Pointer
+}
 
-func (p runtimeProfile) Len() int              { return len(p) }
-func (p runtimeProfile) Stack(i int) []uintptr { return p[i].Stack() }
+func (p *runtimeProfile) Len() int              { return len(p.stk) }
+func (p *runtimeProfile) Stack(i int) []uintptr { return p.stk[i].Stack() }
+func (p *runtimeProfile) Label(i int) *labelMap { return (*labelMap)(p.labels[i]) }
 
 var cpu struct {
 	sync.Mutex
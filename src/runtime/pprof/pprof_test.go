@@ -771,6 +771,9 @@ func TestGoroutineCounts(t *testing.T) {
 	if !containsInOrder(prof, "\n50 @ ", "\n40 @", "\n10 @", "\n1 @") {
 		t.Errorf("expected sorted goroutine counts:\n%s", prof)
 	}
+	if !strings.Contains(prof, "\n# runtime/debug.SchedStats\n# Latency p50 = ") {
+		t.Errorf("expected scheduler statistics at the end of the profile:\n%s", prof)
+	}
 
 	// Check proto profile
 	w.Reset()
@@ -792,6 +795,71 @@ func TestGoroutineCounts(t *testing.T) {
 	time.Sleep(10 * time.Millisecond) // let goroutines exit
 }
 
+func TestGoroutineProfileLabels(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	// Goroutines inherit the labels of the goroutine that created them.
+	c := make(chan int)
+	Do(context.Background(), Labels("tenant", "a"), func(context.Context) {
+		for i := 0; i < 10; i++ {
+			go func4(c)
+		}
+	})
+	for i := 0; i < 5; i++ {
+		go func4(c)
+	}
+	for i := 0; i < 5; i++ {
+		runtime.Gosched()
+	}
+
+	var w bytes.Buffer
+	goroutineProf := Lookup("goroutine")
+
+	// Check debug profile. Goroutines with different labels are
+	// counted separately.
+	goroutineProf.WriteTo(&w, 1)
+	prof := w.String()
+	labeled := regexp.MustCompile(`(?m)^10 @ .*\n# labels: \{"tenant":"a"\}\n(#.*\n)*#\t0x[0-9a-f]+\truntime/pprof\.func4\+`)
+	if !labeled.MatchString(prof) {
+		t.Errorf("expected 10 labeled goroutines in func4:\n%s", prof)
+	}
+	unlabeled := regexp.MustCompile(`(?m)^5 @ .*\n#\t0x[0-9a-f]+\truntime/pprof\.func4\+`)
+	if !unlabeled.MatchString(prof) {
+		t.Errorf("expected 5 unlabeled goroutines in func4:\n%s", prof)
+	}
+
+	// Check proto profile.
+	w.Reset()
+	goroutineProf.WriteTo(&w, 0)
+	p, err := profile.Parse(&w)
+	if err != nil {
+		t.Fatalf("error parsing protobuf profile: %v", err)
+	}
+	if err := p.CheckValid(); err != nil {
+		t.Errorf("protobuf profile is invalid: %v", err)
+	}
+	found := false
+	for _, s := range p.Sample {
+		if v := s.Label["tenant"]; len(v) == 1 && v[0] == "a" && s.Value[0] == 10 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no sample with 10 goroutines labeled tenant=a:\n%v", p)
+	}
+
+	// Check the stacks printed with debug=2.
+	w.Reset()
+	goroutineProf.WriteTo(&w, 2)
+	if n := strings.Count(w.String(), `[chan receive, labels: {"tenant":"a"}]:`); n != 10 {
+		t.Errorf("found %d labeled goroutine headers, want 10:\n%s", n, w.String())
+	}
+
+	close(c)
+
+	time.Sleep(10 * time.Millisecond) // let goroutines exit
+}
+
 func containsInOrder(s string, all ...string) bool {
 	for _, t := range all {
 		i := strings.Index(s, t)
@@ -823,6 +891,76 @@ func containsCounts(prof *profile.Profile, counts []int64) bool {
 	return true
 }
 
+func leakedRecv(c chan int) { <-c }
+
+func leakedSend(c chan int) { c <- 1 }
+
+func leakedWait(wg *sync.WaitGroup) { wg.Wait() }
+
+func blockedRecv(c chan int) { <-c }
+
+func blockedLockContext(ctx context.Context, mu *sync.Mutex) { mu.LockContext(ctx) }
+
+func TestGoroutineLeakProfile(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	// Goroutines blocked on channels and sync objects that
+	// nothing else can reach have leaked.
+	for i := 0; i < 10; i++ {
+		go leakedRecv(make(chan int))
+		go leakedSend(make(chan int))
+		wg := new(sync.WaitGroup)
+		wg.Add(1)
+		go leakedWait(wg)
+	}
+	// A goroutine blocked on a channel the test can still
+	// send on has not.
+	c := make(chan int)
+	go blockedRecv(c)
+	// Nor has a goroutine waiting for a Mutex nothing else can
+	// reach, with a Context the test can still cancel.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mu := new(sync.Mutex)
+	mu.Lock()
+	go blockedLockContext(ctx, mu)
+	for i := 0; i < 5; i++ {
+		runtime.Gosched()
+	}
+
+	var w bytes.Buffer
+	leakProf := Lookup("goroutineleak")
+	leakProf.WriteTo(&w, 1)
+	prof := w.String()
+	for _, want := range []string{"leakedRecv", "leakedSend", "leakedWait"} {
+		re := regexp.MustCompile(`(?m)^10 @ .*\n(#.*\n)*#\t0x[0-9a-f]+\truntime/pprof\.` + want + `\+`)
+		if !re.MatchString(prof) {
+			t.Errorf("expected 10 goroutines leaked in %s:\n%s", want, prof)
+		}
+	}
+	if strings.Contains(prof, "blockedRecv") {
+		t.Errorf("goroutine blocked on a reachable channel reported as leaked:\n%s", prof)
+	}
+	if strings.Contains(prof, "blockedLockContext") {
+		t.Errorf("goroutine blocked with a reachable Context reported as leaked:\n%s", prof)
+	}
+	if n := leakProf.Count(); n < 30 {
+		t.Errorf("leak profile count = %d, want at least 30", n)
+	}
+
+	w.Reset()
+	leakProf.WriteTo(&w, 0)
+	p, err := profile.Parse(&w)
+	if err != nil {
+		t.Fatalf("error parsing protobuf profile: %v", err)
+	}
+	if err := p.CheckValid(); err != nil {
+		t.Errorf("protobuf profile is invalid: %v", err)
+	}
+
+	c <- 1
+}
+
 // Issue 18836.
 func TestEmptyCallStack(t *testing.T) {
 	t.Parallel()
@@ -60,6 +60,9 @@ func writeHeapProto(w io.Writer, p []runtime.MemProfileRecord, rate int64) error
 			if blockSize != 0 {
 				b.pbLabel(tagSample_Label, "bytes", "", blockSize)
 			}
+			if r.Type != "" {
+				b.pbLabel(tagSample_Label, "type", r.Type, 0)
+			}
 		})
 	}
 	b.build()
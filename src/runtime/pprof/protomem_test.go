@@ -22,7 +22,7 @@ func TestConvertMemProfile(t *testing.T) {
 	a1, a2 := uintptr(addr1)+1, uintptr(addr2)+1
 	rate := int64(512 * 1024)
 	rec := []runtime.MemProfileRecord{
-		{AllocBytes: 4096, FreeBytes: 1024, AllocObjects: 4, FreeObjects: 1, Stack0: [32]uintptr{a1, a2}},
+		{AllocBytes: 4096, FreeBytes: 1024, AllocObjects: 4, FreeObjects: 1, Stack0: [32]uintptr{a1, a2}, Type: "main.T"},
 		{AllocBytes: 512 * 1024, FreeBytes: 0, AllocObjects: 1, FreeObjects: 0, Stack0: [32]uintptr{a2 + 1, a2 + 2}},
 		{AllocBytes: 512 * 1024, FreeBytes: 512 * 1024, AllocObjects: 1, FreeObjects: 1, Stack0: [32]uintptr{a1 + 1, a1 + 2, a2 + 3}},
 	}
@@ -50,6 +50,7 @@ func TestConvertMemProfile(t *testing.T) {
 				{ID: 1, Mapping: map1, Address: addr1},
 				{ID: 2, Mapping: map2, Address: addr2},
 			},
+			Label:    map[string][]string{"type": {"main.T"}},
 			NumLabel: map[string][]int64{"bytes": {1024}},
 		},
 		{
@@ -88,9 +88,11 @@ func TestDo(t *testing.T) {
 }
 
 func getProfLabel() map[string]string {
-	l := (*labelMap)(runtime_getProfLabel())
-	if l == nil {
-		return map[string]string{}
+	m := map[string]string{}
+	if l := (*labelMap)(runtime_getProfLabel()); l != nil {
+		for _, lbl := range l.list {
+			m[lbl.key] = lbl.value
+		}
 	}
-	return *l
+	return m
 }
@@ -0,0 +1,235 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Goroutine preemption
+//
+// A goroutine can be preempted at any safe point. Currently, there
+// are a few categories of safe points:
+//
+// 1. A blocked safe point occurs for the duration that a goroutine is
+//    descheduled, blocked on synchronization, or in a system call.
+//
+// 2. Synchronous safe points occur when a running goroutine checks
+//    for a preemption request.
+//
+// 3. Asynchronous safe points occur at any instruction in user code
+//    where the goroutine can be safely paused and a conservative
+//    stack and register scan can find stack roots. The runtime can
+//    stop a goroutine at an async safe point using a signal.
+//
+// At both blocked and synchronous safe points, a goroutine's CPU
+// state is minimal and the garbage collector has complete information
+// about its entire stack. This makes it possible to deschedule a
+// goroutine with minimal space, and to precisely scan a goroutine's
+// stack.
+//
+// Synchronous safe points are implemented by overloading the stack
+// bound check in function prologues. To preempt a goroutine at the
+// next synchronous safe point, the runtime poisons the goroutine's
+// stack bound to a value that will cause the next stack bound check
+// to fail and enter the stack growth implementation, which will
+// detect that it was actually a preemption and redirect to preemption
+// handling. A goroutine spinning in a loop without function calls
+// never reaches such a check.
+//
+// Preemption at asynchronous safe points is implemented by sending
+// the thread running the goroutine a signal (sigPreempt) and
+// inspecting the interrupted state in the signal handler to
+// determine if the goroutine was at an asynchronous safe point. Since
+// the signal delivery itself is asynchronous, the handler also checks
+// if the running goroutine still wants to be preempted. If all
+// conditions are satisfied, it adjusts the signal context to make it
+// look like the signaled thread just called asyncPreempt and resumes
+// the thread. asyncPreempt spills all registers and enters the
+// scheduler.
+//
+// The compiler marks the instructions at which the goroutine must not
+// be stopped in the PCDATA_UnsafePoint table: write barrier sequences,
+// sequences that hold a pointer only in a uintptr, and everything in
+// nosplit functions and the runtime itself. The frame of the
+// interrupted function does not have a precise stack map at an
+// arbitrary instruction, so the garbage collector scans it and the
+// spilled registers conservatively, and the stack of such a goroutine
+// is never moved while it is stopped.
+//
+// (An alternative would be to preempt in the signal handler itself.
+// This would let the OS save and restore the register state and the
+// runtime would only need to know how to extract potentially
+// pointer-containing registers from the signal context. However, this
+// would consume an M for every preempted G, and the scheduler itself
+// is not designed to run from a signal handler, as it tends to
+// allocate memory and start threads in the preemption path.)
+
+package runtime
+
+import (
+	"runtime/internal/sys"
+	"unsafe"
+)
+
+// asyncPreempt saves all user registers and calls asyncPreempt2.
+//
+// When stack scanning encounters an asyncPreempt frame, it scans that
+// frame and its parent frame conservatively.
+//
+// asyncPreempt is implemented in assembly.
+func asyncPreempt()
+
+// asyncPreempt2 is called by asyncPreempt with all user registers
+// saved on the stack. It is nosplit because the stack space it needs
+// was reserved by isAsyncSafePoint and the stack must not be moved
+// while the interrupted frame only has conservative pointer
+// information.
+//
+//go:nosplit
+func asyncPreempt2() {
+	gp := getg()
+	gp.asyncSafePoint = true
+	mcall(preempt_m)
+	gp.asyncSafePoint = false
+}
+
+// asyncPreemptStack is the bytes of stack space required to inject an
+// asyncPreempt call.
+var asyncPreemptStack = ^uintptr(0)
+
+func init() {
+	f := findfunc(funcPC(asyncPreempt))
+	total := funcMaxSPDelta(f)
+	f = findfunc(funcPC(asyncPreempt2))
+	total += funcMaxSPDelta(f)
+	// Add some overhead for return PCs, etc.
+	asyncPreemptStack = uintptr(total) + 8*sys.PtrSize
+	if asyncPreemptStack > _StackLimit {
+		// We need more than the nosplit limit. This isn't
+		// unsafe, but it may limit asynchronous preemption.
+		print("runtime: asyncPreemptStack=", asyncPreemptStack, "\n")
+		throw("async stack too large")
+	}
+}
+
+// asyncPreemptUnsupported is where asyncPreempt leads on architectures
+// that do not implement asynchronous preemption yet. preemptMSupported
+// is false there, so the runtime never injects a call to asyncPreempt.
+func asyncPreemptUnsupported() {
+	throw("asyncPreempt not implemented on " + GOARCH)
+}
+
+// wantAsyncPreempt returns whether an asynchronous preemption is
+// queued for gp.
+func wantAsyncPreempt(gp *g) bool {
+	return gp.preempt && readgstatus(gp)&^_Gscan == _Grunning
+}
+
+// canPreemptM reports whether mp is in a state that is safe to preempt.
+//
+// It is nosplit because it has nosplit callers.
+//
+//go:nosplit
+func canPreemptM(mp *m) bool {
+	return mp.locks == 0 && mp.mallocing == 0 && mp.preemptoff == "" && mp.p.ptr().status == _Prunning
+}
+
+// isAsyncSafePoint reports whether gp at instruction PC is an
+// asynchronous safe point. This indicates that:
+//
+// 1. It's safe to suspend gp and conservatively scan its stack and
+// registers. There are no potentially hidden pointer values and it's
+// not in the middle of an atomic sequence like a write barrier.
+//
+// 2. gp has enough stack space to inject the asyncPreempt call.
+//
+// 3. It's generally safe to interact with the runtime, even if we're
+// in a signal handler stopped here. For example, there are no runtime
+// locks held, so acquiring a runtime lock won't self-deadlock.
+func isAsyncSafePoint(gp *g, pc, sp uintptr) bool {
+	mp := gp.m
+
+	// Only user Gs can have safe points. We check this first
+	// because it's extremely common that we'll catch mp in the
+	// scheduler processing this G preemption.
+	if mp.curg != gp {
+		return false
+	}
+
+	// Check M state.
+	if mp.p == 0 || !canPreemptM(mp) {
+		return false
+	}
+
+	// Check stack space.
+	if sp < gp.stack.lo || sp-gp.stack.lo < asyncPreemptStack {
+		return false
+	}
+
+	// Check if PC is an unsafe point.
+	f := findfunc(pc)
+	if !f.valid() {
+		// Not Go code.
+		return false
+	}
+	if pcdatavalue(f, _PCDATA_UnsafePoint, pc, nil) != _PCDATA_UnsafePointSafe {
+		// Unsafe point marked by compiler. This includes
+		// atomic sequences (e.g., write barrier) and nosplit
+		// functions.
+		return false
+	}
+	if funcdata(f, _FUNCDATA_LocalsPointerMaps) == nil {
+		// This is assembly code. Don't assume it's
+		// well-formed.
+		return false
+	}
+	name := funcname(f)
+	if inldata := funcdata(f, _FUNCDATA_InlTree); inldata != nil {
+		inltree := (*[1 << 20]inlinedCall)(inldata)
+		ix := pcdatavalue(f, _PCDATA_InlTreeIndex, pc, nil)
+		if ix >= 0 {
+			name = funcnameFromNameoff(f, inltree[ix].func_)
+		}
+	}
+	if hasprefix(name, "runtime.") ||
+		hasprefix(name, "runtime/internal/") ||
+		hasprefix(name, "reflect.") {
+		// For now we never async preempt the runtime or
+		// anything closely tied to the runtime. Known issues
+		// include: various points in the scheduler ("don't
+		// preempt between here and here"), much of the defer
+		// implementation (untyped info on stack), bulk write
+		// barriers (write barrier check),
+		// reflect.{makeFuncStub,methodValueCall}.
+		return false
+	}
+
+	return true
+}
+
+// scanConservative scans block [b, b+n) conservatively, treating any
+// pointer-like value in the block as a pointer.
+//
+// This is used for the frame of a goroutine that was asynchronously
+// preempted, which has no precise stack map at the point where it
+// stopped, and for the registers asyncPreempt spilled.
+//
+//go:nowritebarrier
+func scanConservative(b, n uintptr, gcw *gcWork) {
+	for i := uintptr(0); i < n; i += sys.PtrSize {
+		val := *(*uintptr)(unsafe.Pointer(b + i))
+
+		// Check if val points into the heap.
+		span := spanOf(val)
+		if span == nil || span.state != _MSpanInUse || val < span.base() || val >= span.limit {
+			continue
+		}
+
+		// Check if val points to an allocated object.
+		idx := span.objIndex(val)
+		if span.isFree(idx) {
+			continue
+		}
+
+		// val points to an allocated object. Mark it.
+		obj := span.base() + idx*span.elemsize
+		greyobject(obj, b, i, heapBitsForAddr(obj), span, gcw, idx)
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !amd64
+
+#include "textflag.h"
+
+// asyncPreempt is not implemented on this architecture yet, and the
+// runtime never injects a call to it here.
+TEXT ·asyncPreempt(SB),NOSPLIT|NOFRAME,$0-0
+	JMP	·asyncPreemptUnsupported(SB)
@@ -91,6 +91,7 @@ func gwrite(b []byte) {
 	gp := getg()
 	if gp == nil || gp.writebuf == nil {
 		writeErr(b)
+		writeCrash(b)
 		return
 	}
 
@@ -322,6 +322,7 @@ func os_beforeExit() {
 // 用来执行强制gc
 func init() {
 	go forcegchelper()
+	go gomaxprocshelper()
 }
 
 // 用来执行强制gc
@@ -343,6 +344,83 @@ func forcegchelper() {
 	}
 }
 
+// defaultGOMAXPROCS returns the default GOMAXPROCS: the number of
+// CPUs, lowered to the CPU limit of the process's cgroup, if any.
+// Fractional limits are rounded up, and the result is at least 2
+// (given as many CPUs), since a limit isn't a cap on parallelism and
+// a single P would serialize the GC with the program.
+func defaultGOMAXPROCS() int32 {
+	procs := ncpu
+	if debug.cgroupgomaxprocs == 0 {
+		return procs
+	}
+	if limit, ok := cgroupCPULimit(); ok {
+		n := int32(limit)
+		if float64(n) < limit {
+			n++
+		}
+		if n < 2 {
+			n = 2
+		}
+		if n < procs {
+			procs = n
+		}
+	}
+	return procs
+}
+
+// gomaxprocshelper changes GOMAXPROCS to follow the default when
+// sysmon notices that the cgroup CPU limit changed.
+func gomaxprocshelper() {
+	maxprocs.g = getg()
+	for {
+		lock(&maxprocs.lock)
+		if maxprocs.idle != 0 {
+			throw("gomaxprocshelper: phase error")
+		}
+		atomic.Store(&maxprocs.idle, 1)
+		goparkunlock(&maxprocs.lock, "GOMAXPROCS updater (idle)", traceEvGoBlock, 1)
+		// this goroutine is explicitly resumed by sysmon
+		stopTheWorld("GOMAXPROCS updater")
+		// Don't override a GOMAXPROCS call made while this
+		// goroutine was waking up.
+		lock(&sched.lock)
+		custom := sched.customGOMAXPROCS
+		unlock(&sched.lock)
+		if !custom {
+			// newprocs will be processed by startTheWorld
+			newprocs = maxprocs.procs
+		}
+		startTheWorld()
+	}
+}
+
+// sysmonUpdateGOMAXPROCS re-reads the cgroup CPU limit and, if the
+// default GOMAXPROCS changed, wakes gomaxprocshelper to apply it.
+func sysmonUpdateGOMAXPROCS() {
+	if atomic.Load(&maxprocs.idle) == 0 {
+		// Not started yet, or still applying the last change.
+		return
+	}
+	lock(&sched.lock)
+	custom := sched.customGOMAXPROCS
+	cur := gomaxprocs
+	unlock(&sched.lock)
+	if custom {
+		return
+	}
+	procs := defaultGOMAXPROCS()
+	if procs == cur {
+		return
+	}
+	lock(&maxprocs.lock)
+	maxprocs.procs = procs
+	maxprocs.idle = 0
+	maxprocs.g.schedlink = 0
+	injectglist(maxprocs.g)
+	unlock(&maxprocs.lock)
+}
+
 //go:nosplit
 
 // Gosched yields the processor, allowing other goroutines to run. It does not
@@ -656,9 +734,11 @@ func schedinit() {
 	sched.lastpoll = uint64(nanotime())
 	// 确认P的个数
 	// 默认等于cpu个数，可以通过GOMAXPROCS环境变量更改
-	procs := ncpu
+	cgroupInit()
+	procs := defaultGOMAXPROCS()
 	if n, ok := atoi32(gogetenv("GOMAXPROCS")); ok && n > 0 {
 		procs = n
+		sched.customGOMAXPROCS = true
 	}
 	// 调整P的个数，这里是新分配procs个P
 	// 这个函数很重要，所有的P都是从这里分配的，以后也不用担心没有P了
@@ -986,8 +1066,40 @@ func casgstatus(gp *g, oldval, newval uint32) {
 	if newval == _Grunning {
 		gp.gcscanvalid = false
 	}
+
+	// Sample the time this goroutine spends runnable for the
+	// scheduler latency metric, and the time it spends waiting
+	// for the wait reason statistics.
+	if oldval == _Grunning {
+		// Track every gTrackingPeriod time a goroutine transitions out of running.
+		if gp.trackingSeq%gTrackingPeriod == 0 {
+			gp.tracking = true
+		}
+		gp.trackingSeq++
+	}
+	if gp.tracking {
+		if oldval == _Gwaiting && gp.waitStamp != 0 {
+			recordWait(gp.waitreason, nanotime()-gp.waitStamp)
+			gp.waitStamp = 0
+		}
+		if newval == _Gwaiting {
+			gp.waitStamp = nanotime()
+		} else if newval == _Grunnable {
+			gp.runnableStamp = nanotime()
+		} else if newval == _Grunning {
+			if gp.runnableStamp != 0 {
+				schedLatencyDist.record(nanotime() - gp.runnableStamp)
+			}
+			gp.tracking = false
+			gp.runnableStamp = 0
+		}
+	}
 }
 
+// gTrackingPeriod is the number of transitions out of _Grunning
+// between latency tracking runs of a goroutine.
+const gTrackingPeriod = 8
+
 // casgstatus(gp, oldstatus, Gcopystack), assuming oldstatus is Gwaiting or Grunnable.
 // Returns old status. Cannot call casgstatus directly, because we are racing with an
 // async wakeup that might come in from netpoll. If we see Gwaiting from the readgstatus,
@@ -1094,6 +1206,12 @@ loop:
 					// 设置可抢占
 					gp.preempt = true
 					gp.stackguard0 = stackPreempt
+					// Goroutines in tight loops never
+					// reach a stack check, so also send
+					// a preemption signal.
+					if preemptMSupported && debug.asyncpreemptoff == 0 {
+						preemptM(gp.m)
+					}
 				}
 				casfrom_Gscanstatus(gp, _Gscanrunning, _Grunning)
 			}
@@ -1280,7 +1398,7 @@ func startTheWorldWithSema(emitTraceEvent bool) int64 {
 
 	_g_.m.locks++ // disable preemption because it can be holding p in a local var
 	if netpollinited() {
-		gp := netpoll(false) // non-blocking
+		gp := netpoll(0) // non-blocking
 		injectglist(gp)
 	}
 	add := needaddgcproc()
@@ -2454,6 +2572,11 @@ func gcstopm() {
 func execute(gp *g, inheritTime bool) {
 	_g_ := getg()
 
+	// Assign gp.m before entering _Grunning so running Gs have an M.
+	// 当前的M的G改为gp
+	_g_.m.curg = gp
+	// gp的M改为当前的M
+	gp.m = _g_.m
 	// 更改gp的状态为_Grunning
 	casgstatus(gp, _Grunnable, _Grunning)
 	// 置等待时间为0
@@ -2465,10 +2588,6 @@ func execute(gp *g, inheritTime bool) {
 	if !inheritTime {
 		_g_.m.p.ptr().schedtick++
 	}
-	// 当前的M的G改为gp
-	_g_.m.curg = gp
-	// gp的M改为当前的M
-	gp.m = _g_.m
 
 	// Check whether the profiler needs to be turned on or off.
 	hz := sched.profilehz
@@ -2515,6 +2634,10 @@ top:
 	if _p_.runSafePointFn != 0 {
 		runSafePointFn()
 	}
+
+	now, pollUntil, _ := checkTimers(_p_, 0)
+	ranTimer := false
+
 	// fing是执行finalizer的goroutine
 	if fingwait && fingwake {
 		if gp := wakefing(); gp != nil {
@@ -2551,7 +2674,7 @@ top:
 	// anyway.
 	// 从网络IO轮询器中找到就绪的G，把这个G变为可运行的G
 	if netpollinited() && atomic.Load(&netpollWaiters) > 0 && atomic.Load64(&sched.lastpoll) != 0 {
-		if gp := netpoll(false); gp != nil { // non-blocking
+		if gp := netpoll(0); gp != nil { // non-blocking
 			// netpoll returns list of goroutines linked by schedlink.
 			// 如果找到的可运行的网络IO的G列表，则把相关的G插入全局队列
 			injectglist(gp.schedlink.ptr())
@@ -2567,13 +2690,6 @@ top:
 
 	// Steal work from other P's.
 	procs := uint32(gomaxprocs)
-	// 如果其他P都是空闲的，就不从其他P哪里偷取G了
-	if atomic.Load(&sched.npidle) == procs-1 {
-		// Either GOMAXPROCS=1 or everybody, except for us, is idle already.
-		// New work can appear from returning syscall/cgocall, network or timers.
-		// Neither of that submits to local run queues, so no point in stealing.
-		goto stop
-	}
 	// If number of spinning M's >= number of busy P's, block.
 	// This is necessary to prevent excessive CPU consumption
 	// when GOMAXPROCS>>1 but the program parallelism is low.
@@ -2595,12 +2711,49 @@ top:
 				goto top
 			}
 			stealRunNextG := i > 2 // first look for ready queues with more than 1 g
+			p2 := allp[enum.position()]
+			if _p_ == p2 {
+				continue
+			}
 			// 从allp[enum.position()]偷去一半的G，并返回其中的一个
-			if gp := runqsteal(_p_, allp[enum.position()], stealRunNextG); gp != nil {
+			if gp := runqsteal(_p_, p2, stealRunNextG); gp != nil {
 				return gp, false
 			}
+
+			// Consider running the timers of p2.
+			// This call to checkTimers is the only place where
+			// we hold a lock on a different P's timers.
+			// Lock contention can be a problem here, so avoid
+			// grabbing the lock if p2 is running and not marked
+			// for preemption. If p2 is running and not being
+			// preempted we assume it will handle its own timers.
+			if i > 2 && shouldStealTimers(p2) {
+				tnow, w, ran := checkTimers(p2, now)
+				now = tnow
+				if w != 0 && (pollUntil == 0 || w < pollUntil) {
+					pollUntil = w
+				}
+				if ran {
+					// Running the timers may have
+					// made an arbitrary number of G's
+					// ready and added them to this P's
+					// local run queue. That invalidates
+					// the assumption of runqsteal
+					// that it always has room to add
+					// stolen G's. So check now if there
+					// is a local G to run.
+					if gp, inheritTime := runqget(_p_); gp != nil {
+						return gp, inheritTime
+					}
+					ranTimer = true
+				}
+			}
 		}
 	}
+	if ranTimer {
+		// Running a timer may have made some goroutine ready.
+		goto top
+	}
 
 stop:
 
@@ -2621,6 +2774,12 @@ stop:
 		return gp, false
 	}
 
+	delta := int64(-1)
+	if pollUntil != 0 {
+		// checkTimers ensures that pollUntil > now.
+		delta = pollUntil - now
+	}
+
 	// Before we drop our P, make a snapshot of the allp slice,
 	// which can change underfoot once we no longer block
 	// safe-points. We don't need to snapshot the contents because
@@ -2721,23 +2880,58 @@ stop:
 		}
 	}
 
+	// Check for timers that were added or became due on other Ps
+	// while we were transitioning from spinning to non-spinning.
+	// We can't use checkTimers here because running timers
+	// requires a P.
+	for _, _p_ := range allpSnapshot {
+		w := int64(atomic.Load64(&_p_.timer0When))
+		if w != 0 && (pollUntil == 0 || w < pollUntil) {
+			pollUntil = w
+		}
+	}
+	if pollUntil != 0 {
+		if now == 0 {
+			now = nanotime()
+		}
+		delta = pollUntil - now
+		if delta < 0 {
+			delta = 0
+		}
+	}
+
 	// poll network
-	// 再次检查netpoll
-	if netpollinited() && atomic.Load(&netpollWaiters) > 0 && atomic.Xchg64(&sched.lastpoll, 0) != 0 {
+	// 再次检查netpoll，如果有定时器，则最多阻塞到最早的定时器到期
+	if netpollinited() && (atomic.Load(&netpollWaiters) > 0 || pollUntil != 0) && atomic.Xchg64(&sched.lastpoll, 0) != 0 {
+		atomic.Store64(&sched.pollUntil, uint64(pollUntil))
 		if _g_.m.p != 0 {
 			throw("findrunnable: netpoll with p")
 		}
 		if _g_.m.spinning {
 			throw("findrunnable: netpoll with spinning")
 		}
-		gp := netpoll(true) // block until new work is available
+		if faketime != 0 {
+			// When using fake time, just poll.
+			delta = 0
+		}
+		gp := netpoll(delta) // block until new work is available
+		atomic.Store64(&sched.pollUntil, 0)
 		atomic.Store64(&sched.lastpoll, uint64(nanotime()))
-		if gp != nil {
-			lock(&sched.lock)
-			_p_ = pidleget()
-			unlock(&sched.lock)
-			if _p_ != nil {
-				acquirep(_p_)
+		if faketime != 0 && gp == nil {
+			// Using fake time and nothing is ready; stop M.
+			// When all M's stop, checkdead will jump the fake clock
+			// forward to the next timer.
+			stopm()
+			goto top
+		}
+		lock(&sched.lock)
+		_p_ = pidleget()
+		unlock(&sched.lock)
+		if _p_ == nil {
+			injectglist(gp)
+		} else {
+			acquirep(_p_)
+			if gp != nil {
 				injectglist(gp.schedlink.ptr())
 				casgstatus(gp, _Gwaiting, _Grunnable)
 				if trace.enabled {
@@ -2745,7 +2939,21 @@ stop:
 				}
 				return gp, false
 			}
-			injectglist(gp)
+			// The poll timed out or was interrupted, which
+			// means that a timer may be due or a new timer
+			// was added. Look again.
+			if wasSpinning {
+				_g_.m.spinning = true
+				atomic.Xadd(&sched.nmspinning, 1)
+			}
+			goto top
+		}
+	} else if pollUntil != 0 && netpollinited() {
+		// Another thread is blocked in netpoll. Make sure it wakes
+		// up in time for the earliest timer.
+		pollerPollUntil := int64(atomic.Load64(&sched.pollUntil))
+		if pollerPollUntil == 0 || pollerPollUntil > pollUntil {
+			netpollBreak()
 		}
 	}
 	// 实在找不到G，那就休眠吧
@@ -2771,7 +2979,7 @@ func pollWork() bool {
 	}
 	// 如果有网络io的G，返回true
 	if netpollinited() && atomic.Load(&netpollWaiters) > 0 && sched.lastpoll != 0 {
-		if gp := netpoll(false); gp != nil {
+		if gp := netpoll(0); gp != nil {
 			injectglist(gp)
 			return true
 		}
@@ -2873,6 +3081,19 @@ top:
 		runSafePointFn()
 	}
 
+	pp := _g_.m.p.ptr()
+
+	// Sanity check: if we are spinning, the run queue should be empty.
+	// Check this before calling checkTimers, as that might call
+	// goready to put a ready goroutine on the local run queue.
+	if _g_.m.spinning && (pp.runnext != 0 || pp.runqhead != pp.runqtail) {
+		throw("schedule: spinning with local work")
+	}
+
+	// Run any timers on this P that are due. This may make
+	// goroutines runnable.
+	checkTimers(pp, 0)
+
 	var gp *g
 	var inheritTime bool
 
@@ -2911,9 +3132,6 @@ top:
 	if gp == nil {
 		// 从p的本地队列中获取
 		gp, inheritTime = runqget(_g_.m.p.ptr())
-		if gp != nil && _g_.m.spinning {
-			throw("schedule: spinning with local work")
-		}
 	}
 	if gp == nil {
 		// 想尽办法找到可运行的G，找不到就不用返回了
@@ -3033,7 +3251,7 @@ func gosched_m(gp *g) {
 // goschedguarded is a forbidden-states-avoided version of gosched_m
 func goschedguarded_m(gp *g) {
 
-	if gp.m.locks != 0 || gp.m.mallocing != 0 || gp.m.preemptoff != "" || gp.m.p.ptr().status != _Prunning {
+	if !canPreemptM(gp.m) {
 		gogo(&gp.sched) // never return
 	}
 
@@ -3088,6 +3306,7 @@ func goexit0(gp *g) {
 	gp._panic = nil // non-nil for Goexit during panic. points at stack-allocated data.
 	gp.writebuf = nil
 	gp.waitreason = ""
+	gp.leaked = false
 	gp.param = nil
 	gp.labels = nil
 	gp.timer = nil
@@ -4487,6 +4706,10 @@ func procresize(nprocs int32) *p {
 			globrunqputhead(p.runnext.ptr())
 			p.runnext = 0
 		}
+		// Move p's timers to allp[0], which always survives.
+		if len(p.timers) > 0 {
+			moveTimers(allp[0], p)
+		}
 		// if there's a background worker, make it runnable and put
 		// it on the global queue so it can clean itself up
 		if gp := p.gcBgMarkWorker.ptr(); gp != nil {
@@ -4519,6 +4742,20 @@ func procresize(nprocs int32) *p {
 		gfpurge(p)
 		traceProcFree(p)
 		if raceenabled {
+			if p.timerRaceCtx != 0 {
+				// The race detector code uses a callback to fetch
+				// the proc context, so arrange for that callback
+				// to see the right thing.
+				// This only works because the world is stopped.
+				mp := getg().m
+				phold := mp.p.ptr()
+				mp.p.set(p)
+
+				racectxend(p.timerRaceCtx)
+				p.timerRaceCtx = 0
+
+				mp.p.set(phold)
+			}
 			raceprocdestroy(p.racectx)
 			p.racectx = 0
 		}
@@ -4714,23 +4951,34 @@ func checkdead() {
 	}
 
 	// Maybe jump time forward for playground.
-	gp := timejump()
-	if gp != nil {
-		casgstatus(gp, _Gwaiting, _Grunnable)
-		globrunqput(gp)
-		_p_ := pidleget()
-		if _p_ == nil {
-			throw("checkdead: no p for timer")
+	if faketime != 0 {
+		when, _p_ := timeSleepUntil()
+		if _p_ != nil {
+			faketime = when
+			for pp := &sched.pidle; *pp != 0; pp = &(*pp).ptr().link {
+				if (*pp).ptr() == _p_ {
+					*pp = _p_.link
+					atomic.Xadd(&sched.npidle, -1)
+					break
+				}
+			}
+			mp := mget()
+			if mp == nil {
+				// There should always be a free M since
+				// nothing is running.
+				throw("checkdead: no m for timer")
+			}
+			mp.nextp.set(_p_)
+			notewakeup(&mp.park)
+			return
 		}
-		mp := mget()
-		if mp == nil {
-			// There should always be a free M since
-			// nothing is running.
-			throw("checkdead: no m for timer")
+	}
+
+	// There are no goroutines running, so we can look at the P's.
+	for _, _p_ := range allp {
+		if len(_p_.timers) > 0 {
+			return
 		}
-		mp.nextp.set(_p_)
-		notewakeup(&mp.park)
-		return
 	}
 
 	getg().m.throwing = -1 // do not dump full stacks
@@ -4773,6 +5021,8 @@ func sysmon() {
 	}
 
 	lastscavenge := nanotime()
+	lastlimitscavenge := lastscavenge
+	lastmaxprocs := lastscavenge
 	nscavenge := 0
 
 	lasttrace := int64(0)
@@ -4800,14 +5050,19 @@ func sysmon() {
 				if scavengelimit < forcegcperiod {
 					maxsleep = scavengelimit / 2
 				}
+				now := nanotime()
+				next, _ := timeSleepUntil()
 				shouldRelax := true
 				if osRelaxMinNS > 0 {
-					next := timeSleepUntil()
-					now := nanotime()
 					if next-now < osRelaxMinNS {
 						shouldRelax = false
 					}
 				}
+				// Don't sleep past the next timer: with no timer
+				// goroutine, nothing else would notice it is due.
+				if next > now && next-now < maxsleep {
+					maxsleep = next - now
+				}
 				if shouldRelax {
 					osRelax(true)
 				}
@@ -4834,7 +5089,7 @@ func sysmon() {
 		// 并且如果获取到了可运行的G，那么插入全局列表。
 		if netpollinited() && lastpoll != 0 && lastpoll+10*1000*1000 < now {
 			atomic.Cas64(&sched.lastpoll, uint64(lastpoll), uint64(now))
-			gp := netpoll(false) // non-blocking - returns list of goroutines
+			gp := netpoll(0) // non-blocking - returns list of goroutines
 			if gp != nil {
 				// Need to decrement number of idle locked M's
 				// (pretending that one more is running) before injectglist.
@@ -4848,6 +5103,12 @@ func sysmon() {
 				incidlelocked(1)
 			}
 		}
+		if next, _ := timeSleepUntil(); next < now {
+			// There are timers that should have already run,
+			// perhaps because there is an unpreemptible P.
+			// Try to start an M to run them.
+			startm(nil, false)
+		}
 		// retake P's blocked in syscalls
 		// and preempt long running G's
 		// 抢夺阻塞时间长的syscall的G
@@ -4873,6 +5134,21 @@ func sysmon() {
 			mheap_.scavenge(int32(nscavenge), uint64(now), uint64(scavengelimit))
 			lastscavenge = now
 			nscavenge++
+		} else if lastlimitscavenge+memoryLimitScavengePeriod < now && memoryLimitNeedsScavenge() {
+			// Memory use is close to the memory limit.
+			// Have the background scavenger return idle
+			// heap memory to the OS.
+			atomic.Store(&scavenge.sysmonWake, 1)
+			lastlimitscavenge = now
+		}
+		// wake the background scavenger if the pacer asked for it
+		if atomic.Load(&scavenge.sysmonWake) != 0 {
+			wakeScavenger()
+		}
+		// follow changes to the cgroup CPU limit
+		if lastmaxprocs+maxprocsCheckPeriod < now {
+			sysmonUpdateGOMAXPROCS()
+			lastmaxprocs = now
 		}
 		if debug.schedtrace > 0 && lasttrace+int64(debug.schedtrace)*1000000 <= now {
 			lasttrace = now
@@ -4889,6 +5165,14 @@ type sysmontick struct {
 	syscallwhen int64
 }
 
+// maxprocsCheckPeriod is the time in nanoseconds between two checks
+// of the cgroup CPU limit by sysmon.
+const maxprocsCheckPeriod = 1000 * 1000 * 1000 // 1s
+
+// memoryLimitScavengePeriod is the minimum time in nanoseconds
+// between two scavenges triggered by the memory limit.
+const memoryLimitScavengePeriod = 10 * 1000 * 1000 // 10ms
+
 // forcePreemptNS is the time slice given to a G before it is
 // preempted.
 const forcePreemptNS = 10 * 1000 * 1000 // 10ms
@@ -5023,6 +5307,12 @@ func preemptone(_p_ *p) bool {
 	// gorotuine 中的每个调用都会通过将当前堆栈指针与 gp->stackguard0 进行比较来检查堆栈溢出。
 	// 将 gp->stackguard0 设置为 stackPreempt 会将抢占折叠为正常的堆栈溢出检查。
 	gp.stackguard0 = stackPreempt
+
+	// Request an async preemption of this P.
+	if preemptMSupported && debug.asyncpreemptoff == 0 {
+		preemptM(mp)
+	}
+
 	return true
 }
 
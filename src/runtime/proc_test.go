@@ -336,6 +336,31 @@ func TestPreemptionGC(t *testing.T) {
 	atomic.StoreUint32(&stop, 1)
 }
 
+func TestAsyncPreempt(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("no asynchronous preemption on " + runtime.GOOS + "/" + runtime.GOARCH)
+	}
+	// Test that a goroutine spinning in a loop without calls
+	// can be preempted by a pending GC.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+	var stop uint32
+	var started uint32
+	go func() {
+		atomic.StoreUint32(&started, 1)
+		// The atomic load is an intrinsic, so the loop
+		// contains no calls and no stack checks.
+		for atomic.LoadUint32(&stop) == 0 {
+		}
+	}()
+	for atomic.LoadUint32(&started) == 0 {
+		runtime.Gosched()
+	}
+	for i := 0; i < 4; i++ {
+		runtime.GC()
+	}
+	atomic.StoreUint32(&stop, 1)
+}
+
 func TestGCFairness(t *testing.T) {
 	output := runTestProg(t, "testprog", "GCFairness")
 	want := "OK\n"
@@ -38,3 +38,32 @@ func runtime_setProfLabel(labels unsafe.Pointer) {
 func runtime_getProfLabel() unsafe.Pointer {
 	return getg().labels
 }
+
+// profLabel and profLabelSet mirror the label and labelMap types of
+// runtime/pprof. gp.labels points to a profLabelSet, whose list is
+// sorted by key.
+type profLabel struct {
+	key   string
+	value string
+}
+
+type profLabelSet struct {
+	list []profLabel
+}
+
+// printProfLabels prints the profiler labels of gp in a goroutine header.
+// It prints nothing if gp has no labels.
+func printProfLabels(gp *g) {
+	ls := (*profLabelSet)(gp.labels)
+	if ls == nil || len(ls.list) == 0 {
+		return
+	}
+	print(", labels: {")
+	for i, l := range ls.list {
+		if i > 0 {
+			print(", ")
+		}
+		print("\"", l.key, "\":\"", l.value, "\"")
+	}
+	print("}")
+}
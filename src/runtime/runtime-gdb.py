@@ -116,34 +116,34 @@ class MapTypePrinter:
 	def children(self):
 		B = self.val['B']
 		buckets = self.val['buckets']
-		oldbuckets = self.val['oldbuckets']
-		flags = self.val['flags']
-		inttype = self.val['hash0'].type
+		if not buckets:
+			return
 		cnt = 0
 		for bucket in xrange(2 ** int(B)):
-			bp = buckets + bucket
-			if oldbuckets:
-				oldbucket = bucket & (2 ** (B - 1) - 1)
-				oldbp = oldbuckets + oldbucket
-				oldb = oldbp.dereference()
-				if (oldb['overflow'].cast(inttype) & 1) == 0:  # old bucket not evacuated yet
-					if bucket >= 2 ** (B - 1):
-						continue    # already did old bucket
-					bp = oldbp
-			while bp:
-				b = bp.dereference()
-				for i in xrange(8):
-					if b['tophash'][i] != 0:
-						k = b['keys'][i]
-						v = b['values'][i]
-						if flags & 1:
-							k = k.dereference()
-						if flags & 2:
-							v = v.dereference()
-						yield str(cnt), k
-						yield str(cnt + 1), v
-						cnt += 2
-				bp = b['overflow']
+			b = (buckets + bucket).dereference()
+			ctrl = int(b['ctrl'])
+			for i in xrange(8):
+				if (ctrl >> (8 * i)) & 0x80:  # slot is full
+					yield str(cnt), b['keys'][i]
+					yield str(cnt + 1), b['values'][i]
+					cnt += 2
+		oldbuckets = self.val['oldbuckets']
+		if not oldbuckets:
+			return
+		oldB = int(B)
+		if not self.val['flags'] & 8:  # not sameSizeGrow
+			oldB -= 1
+		evacuated = self.val['evacuated'].cast(gdb.lookup_type('uint8').pointer())
+		for bucket in xrange(2 ** oldB):
+			if int(evacuated[bucket // 8]) & (1 << (bucket % 8)):
+				continue  # entries are in buckets
+			b = (oldbuckets + bucket).dereference()
+			ctrl = int(b['ctrl'])
+			for i in xrange(8):
+				if (ctrl >> (8 * i)) & 0x80:  # slot is full
+					yield str(cnt), b['keys'][i]
+					yield str(cnt + 1), b['values'][i]
+					cnt += 2
 
 
 class ChanTypePrinter:
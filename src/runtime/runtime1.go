@@ -204,6 +204,9 @@ func check() {
 	if unsafe.Sizeof(y1) != 2 {
 		throw("bad unsafe.Sizeof y1")
 	}
+	if unsafe.Offsetof(p{}.timer0When)%8 != 0 {
+		throw("bad offsetof p.timer0When")
+	}
 
 	if timediv(12345*1000000000+54321, 1000000000, &e) != 12345 || e != 54321 {
 		throw("bad timediv")
@@ -315,7 +318,9 @@ type dbgVar struct {
 // already have an initial value.
 var debug struct {
 	allocfreetrace   int32
+	asyncpreemptoff  int32
 	cgocheck         int32
+	cgroupgomaxprocs int32
 	efence           int32
 	gccheckmark      int32
 	gcpacertrace     int32
@@ -323,22 +328,28 @@ var debug struct {
 	gcrescanstacks   int32
 	gcstoptheworld   int32
 	gctrace          int32
+	goroutineleak    int32
 	invalidptr       int32
+	madvdontneed     int32 // for Linux
+	mutexowner       int32
 	// add GODEBUG=sbrk=1 to bypass memory allocator (and GC)
 	// To reduce lock contention in this mode, makes persistent allocation state per-P,
 	// which means at most 64 kB overhead x $GOMAXPROCS, which should be
 	// completely tolerable.
 	// 添加GODEBUG = sbrk = 1以绕过内存分配器（和GC）为了减少此模式下的锁争用，使per-P持久分配状态，
 	// 这意味着最多64 kB开销x $ GOMAXPROCS，这应该是完全可以容忍的。
-	sbrk        int32
-	scavenge    int32
-	scheddetail int32
-	schedtrace  int32
+	sbrk            int32
+	scavenge        int32
+	scheddetail     int32
+	schedtrace      int32
+	tracebacklabels int32
 }
 
 var dbgvars = []dbgVar{
 	{"allocfreetrace", &debug.allocfreetrace},
+	{"asyncpreemptoff", &debug.asyncpreemptoff},
 	{"cgocheck", &debug.cgocheck},
+	{"cgroupgomaxprocs", &debug.cgroupgomaxprocs},
 	{"efence", &debug.efence},
 	{"gccheckmark", &debug.gccheckmark},
 	{"gcpacertrace", &debug.gcpacertrace},
@@ -346,17 +357,23 @@ var dbgvars = []dbgVar{
 	{"gcrescanstacks", &debug.gcrescanstacks},
 	{"gcstoptheworld", &debug.gcstoptheworld},
 	{"gctrace", &debug.gctrace},
+	{"goroutineleak", &debug.goroutineleak},
 	{"invalidptr", &debug.invalidptr},
+	{"madvdontneed", &debug.madvdontneed},
+	{"mutexowner", &debug.mutexowner},
 	{"sbrk", &debug.sbrk},
 	{"scavenge", &debug.scavenge},
 	{"scheddetail", &debug.scheddetail},
 	{"schedtrace", &debug.schedtrace},
+	{"tracebacklabels", &debug.tracebacklabels},
 }
 
 func parsedebugvars() {
 	// defaults
 	debug.cgocheck = 1
+	debug.cgroupgomaxprocs = 1
 	debug.invalidptr = 1
+	debug.madvdontneed = 1
 
 	for p := gogetenv("GODEBUG"); p != ""; {
 		field := ""
@@ -502,6 +519,18 @@ func reflect_resolveTypeOff(rtype unsafe.Pointer, off int32) unsafe.Pointer {
 	return unsafe.Pointer((*_type)(rtype).typeOff(typeOff(off)))
 }
 
+// reflectlite_resolveNameOff resolves a name offset from a base pointer.
+//go:linkname reflectlite_resolveNameOff internal/reflectlite.resolveNameOff
+func reflectlite_resolveNameOff(ptrInModule unsafe.Pointer, off int32) unsafe.Pointer {
+	return unsafe.Pointer(resolveNameOff(ptrInModule, nameOff(off)).bytes)
+}
+
+// reflectlite_resolveTypeOff resolves an *rtype offset from a base type.
+//go:linkname reflectlite_resolveTypeOff internal/reflectlite.resolveTypeOff
+func reflectlite_resolveTypeOff(rtype unsafe.Pointer, off int32) unsafe.Pointer {
+	return unsafe.Pointer((*_type)(rtype).typeOff(typeOff(off)))
+}
+
 // reflect_resolveTextOff resolves an function pointer offset from a base type.
 //go:linkname reflect_resolveTextOff reflect.resolveTextOff
 func reflect_resolveTextOff(rtype unsafe.Pointer, off int32) unsafe.Pointer {
@@ -333,6 +333,10 @@ type sudog struct {
 	waitlink    *sudog // g.waiting list or semaRoot
 	waittail    *sudog // semaRoot
 	c           *hchan // channel
+
+	// hidden holds c or elem while a goroutine leak detection
+	// cycle hides them from the garbage collector.
+	hidden uintptr
 }
 
 type libcall struct {
@@ -393,12 +397,22 @@ type g struct {
 	waitsince  int64  // approx time when the g become blocked
 	waitreason string // if status==Gwaiting
 	schedlink  guintptr
+
+	// Per-G tracking for the scheduler latency metric. See
+	// casgstatus.
+	tracking      bool  // whether we're tracking this G's runnable latency
+	trackingSeq   uint8 // used to decide whether to track this G
+	runnableStamp int64 // nanotime() of when the G last became runnable, only used when tracking
+	waitStamp     int64 // nanotime() of when the G last started waiting, only used when tracking
+
 	// 标记是否可抢占
 	preempt        bool     // preemption signal, duplicates stackguard0 = stackpreempt
 	paniconfault   bool     // panic (instead of crash) on unexpected fault address
 	preemptscan    bool     // preempted g does scan for gc
+	asyncSafePoint bool     // set if g is stopped at an asynchronous safe point
 	gcscandone     bool     // g has scanned stack; protected by _Gscan bit in status
 	gcscanvalid    bool     // false at start of gc cycle, true if G has not run since last scan; TODO: remove?
+	leaked         bool     // g was found blocked forever by the last leak detection cycle
 	throwsplit     bool     // must not split stack
 	raceignore     int8     // ignore race detection events
 	sysblocktraced bool     // StartTrace has emitted EvGoInSyscall about this goroutine
@@ -418,6 +432,7 @@ type g struct {
 	startpc    uintptr // pc of goroutine function
 	racectx    uintptr
 	waiting    *sudog         // sudog structures this g is waiting on (that have a valid elem ptr); in lock order
+	waitobj    uintptr        // semaphore or notify list g is parked on, for goroutine leak detection
 	cgoCtxt    []uintptr      // cgo traceback context
 	labels     unsafe.Pointer // profiler labels
 	timer      *timer         // cached timer for time.Sleep
@@ -476,11 +491,12 @@ type m struct {
 	newSigstack bool // minit on C thread called sigaltstack
 	printlock   int8
 	// m在执行cgo吗
-	incgo      bool   // m is executing a cgo call
-	freeWait   uint32 // if == 0, safe to free g0 and delete m (atomic)
-	fastrand   [2]uint32
-	needextram bool
-	traceback  uint8
+	incgo           bool   // m is executing a cgo call
+	freeWait        uint32 // if == 0, safe to free g0 and delete m (atomic)
+	fastrand        [2]uint32
+	needextram      bool
+	traceback       uint8
+	tracebacklabels bool // print profiler labels in goroutine headers, see goroutineheader
 	// 当前cgo调用的数目
 	ncgocall uint64 // number of cgo calls in total
 	// cgo调用的总数
@@ -511,6 +527,7 @@ type m struct {
 	waittraceskip int
 	startingtrace bool
 	syscalltick   uint32
+	signalPending uint32  // whether a preemption signal is pending (atomic)
 	thread        uintptr // thread handle
 	freelink      *m      // on sched.freem
 
@@ -607,15 +624,34 @@ type p struct {
 	// TODO: Consider caching this in the running G.
 	wbBuf wbBuf
 
+	// The when field of the first entry on the timer heap.
+	// This is updated using atomic functions.
+	// This is 0 if the timer heap is empty.
+	// Must be 8-byte aligned on 32-bit systems; checked in check.
+	timer0When uint64
+
 	runSafePointFn uint32 // if 1, run sched.safePointFn at next safe point
 
+	// Lock for timers. We normally access the timers while running
+	// on this P, but the scheduler can also do it from a different P.
+	timersLock mutex
+
+	// Actions to take at some time. This is used to implement the
+	// standard library's time package.
+	// Must hold timersLock to access.
+	timers []*timer
+
+	// Race context used while executing timer functions.
+	timerRaceCtx uintptr
+
 	pad [sys.CacheLineSize]byte
 }
 
 type schedt struct {
 	// accessed atomically. keep at top to ensure alignment on 32-bit systems.
-	goidgen  uint64
-	lastpoll uint64
+	goidgen   uint64
+	lastpoll  uint64 // time of last network poll, 0 if currently polling
+	pollUntil uint64 // time to which current poll is sleeping
 
 	lock mutex
 
@@ -686,6 +722,12 @@ type schedt struct {
 
 	procresizetime int64 // nanotime() of last change to gomaxprocs
 	totaltime      int64 // ∫gomaxprocs dt up to procresizetime
+
+	// customGOMAXPROCS is set if GOMAXPROCS was set by the
+	// environment or by a call to GOMAXPROCS, in which case the
+	// runtime no longer updates it to follow the cgroup CPU limit.
+	// Protected by lock.
+	customGOMAXPROCS bool
 }
 
 // Values for the flags field of a sigTabT.
@@ -712,11 +754,12 @@ type _func struct {
 	args   int32  // in/out args size
 	funcID funcID // set for certain special runtime functions
 
-	pcsp      int32
-	pcfile    int32
-	pcln      int32
-	npcdata   int32
-	nfuncdata int32
+	pcsp        int32
+	pcfile      int32
+	pcln        int32
+	deferreturn uint32 // offset of a deferreturn block from entry, if any.
+	npcdata     int32
+	nfuncdata   int32
 }
 
 // layout of Itab known to compilers
@@ -744,6 +787,16 @@ type forcegcstate struct {
 	idle uint32
 }
 
+// updatemaxprocsstate is the state of the goroutine that applies
+// GOMAXPROCS changes requested by sysmon. sysmon cannot stop the
+// world itself.
+type updatemaxprocsstate struct {
+	lock  mutex
+	g     *g
+	idle  uint32
+	procs int32 // new GOMAXPROCS
+}
+
 // startup_random_data holds random bytes initialized at startup. These come from
 // the ELF AT_RANDOM auxiliary vector (vdso_linux_amd64.go or os_linux_386.go).
 var startupRandomData []byte
@@ -774,11 +827,27 @@ func extendRandom(r []byte, n int) {
 type _defer struct {
 	siz     int32
 	started bool
-	sp      uintptr // sp at time of defer
-	pc      uintptr
-	fn      *funcval
-	_panic  *_panic // panic that is running defer
-	link    *_defer
+	// openDefer indicates that this _defer is for a frame with open-coded
+	// defers. We have only one defer record for the entire frame (which may
+	// currently have 0, 1, or more defers active).
+	openDefer bool
+	sp        uintptr // sp at time of defer
+	pc        uintptr
+	fn        *funcval
+	_panic    *_panic // panic that is running defer
+	link      *_defer
+
+	// If openDefer is true, the fields below record values about the stack
+	// frame and associated function that has the open-coded defer(s). sp
+	// above will be the sp for the frame, and pc will be address of the
+	// deferreturn call in the function.
+	fd   unsafe.Pointer // funcdata for the function associated with the frame
+	varp uintptr        // value of varp for the stack frame
+	// framepc is the current pc associated with the stack frame. Together,
+	// with sp above (which is the sp associated with the stack frame),
+	// framepc/sp can be used as pc/sp pair to continue a stack trace via
+	// gentraceback().
+	framepc uintptr
 }
 
 // panics
@@ -821,6 +890,7 @@ var (
 	gomaxprocs int32
 	ncpu       int32
 	forcegc    forcegcstate
+	maxprocs   updatemaxprocsstate
 	sched      schedt
 	newprocs   int32
 
@@ -0,0 +1,100 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// waitReasonStats accumulates the time goroutines spend waiting, by
+// wait reason, for the transitions sampled by the scheduler latency
+// tracking in casgstatus.
+//
+// Wait reasons are string constants, so a reason is identified by the
+// address of its bytes. An entry is claimed by a CAS of that address
+// into str and is never freed. The length is published after the
+// claim, so readers skip entries whose length is still 0. Waits for
+// reasons that do not fit in the table are dropped.
+var waitReasonStats [64]waitReasonStat
+
+type waitReasonStat struct {
+	str   uintptr // address of the reason's bytes, 0 if unused
+	len   uintptr // length of the reason, 0 until published
+	count uint64  // sampled waits
+	ns    uint64  // total nanoseconds of the sampled waits
+}
+
+// recordWait adds a sampled wait of d nanoseconds for reason to
+// waitReasonStats.
+//
+// Disallow preemptions and stack growths because this function
+// is called from casgstatus.
+//go:nosplit
+func recordWait(reason string, d int64) {
+	if len(reason) == 0 || d < 0 {
+		return
+	}
+	p := uintptr((*stringStruct)(unsafe.Pointer(&reason)).str)
+	i := (p ^ p>>6) % uintptr(len(waitReasonStats))
+	for n := 0; n < len(waitReasonStats); n++ {
+		s := &waitReasonStats[i]
+		str := atomic.Loaduintptr(&s.str)
+		if str == 0 {
+			if atomic.Casuintptr(&s.str, 0, p) {
+				atomic.Storeuintptr(&s.len, uintptr(len(reason)))
+				str = p
+			} else {
+				str = atomic.Loaduintptr(&s.str)
+			}
+		}
+		if str == p {
+			atomic.Xadd64(&s.count, 1)
+			atomic.Xadd64(&s.ns, d)
+			return
+		}
+		i = (i + 1) % uintptr(len(waitReasonStats))
+	}
+}
+
+// waitStatsRecord is a wait reason and the estimated number and total
+// time of the waits for it. It must match runtime/debug.WaitStats.
+type waitStatsRecord struct {
+	reason string
+	count  int64
+	total  int64
+}
+
+// readWaitStats appends the wait statistics to *p. Only one in
+// gTrackingPeriod transitions is sampled, so the counts and times are
+// scaled up accordingly.
+//
+//go:linkname readWaitStats runtime/debug.readWaitStats
+func readWaitStats(p *[]waitStatsRecord) {
+	for i := range waitReasonStats {
+		s := &waitReasonStats[i]
+		n := atomic.Loaduintptr(&s.len)
+		if n == 0 {
+			continue
+		}
+		var reason string
+		str := (*stringStruct)(unsafe.Pointer(&reason))
+		str.str = unsafe.Pointer(atomic.Loaduintptr(&s.str))
+		str.len = int(n)
+		*p = append(*p, waitStatsRecord{
+			reason: reason,
+			count:  int64(atomic.Load64(&s.count)) * gTrackingPeriod,
+			total:  int64(atomic.Load64(&s.ns)) * gTrackingPeriod,
+		})
+	}
+}
+
+// readSchedLatency fills q with evenly spaced quantiles of the
+// scheduler latency distribution, in nanoseconds.
+//
+//go:linkname readSchedLatency runtime/debug.readSchedLatency
+func readSchedLatency(q []int64) {
+	schedLatencyDist.quantiles(q)
+}
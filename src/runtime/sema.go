@@ -53,22 +53,32 @@ var semtable [semTabSize]struct {
 
 //go:linkname sync_runtime_Semacquire sync.runtime_Semacquire
 func sync_runtime_Semacquire(addr *uint32) {
-	semacquire1(addr, false, semaBlockProfile)
+	semacquire1(addr, false, semaBlockProfile, 0, nil)
+}
+
+//go:linkname sync_runtime_SemacquireDone sync.runtime_SemacquireDone
+func sync_runtime_SemacquireDone(addr *uint32, done *hchan) bool {
+	return semacquire1(addr, false, semaBlockProfile, 0, done)
 }
 
 //go:linkname poll_runtime_Semacquire internal/poll.runtime_Semacquire
 func poll_runtime_Semacquire(addr *uint32) {
-	semacquire1(addr, false, semaBlockProfile)
+	semacquire1(addr, false, semaBlockProfile, 0, nil)
 }
 
 //go:linkname sync_runtime_Semrelease sync.runtime_Semrelease
-func sync_runtime_Semrelease(addr *uint32, handoff bool) {
-	semrelease1(addr, handoff)
+func sync_runtime_Semrelease(addr *uint32, handoff bool, skipframes int) {
+	semrelease1(addr, handoff, skipframes)
 }
 
 //go:linkname sync_runtime_SemacquireMutex sync.runtime_SemacquireMutex
-func sync_runtime_SemacquireMutex(addr *uint32, lifo bool) {
-	semacquire1(addr, lifo, semaBlockProfile|semaMutexProfile)
+func sync_runtime_SemacquireMutex(addr *uint32, lifo bool, skipframes int) {
+	semacquire1(addr, lifo, semaBlockProfile|semaMutexProfile, skipframes, nil)
+}
+
+//go:linkname sync_runtime_SemacquireMutexDone sync.runtime_SemacquireMutexDone
+func sync_runtime_SemacquireMutexDone(addr *uint32, lifo bool, skipframes int, done *hchan) bool {
+	return semacquire1(addr, lifo, semaBlockProfile|semaMutexProfile, skipframes, done)
 }
 
 //go:linkname poll_runtime_Semrelease internal/poll.runtime_Semrelease
@@ -97,10 +107,13 @@ const (
 // 并把他设为等待状态。
 // http://ga0.github.io/golang/2015/10/11/golang-sync.html
 func semacquire(addr *uint32) {
-	semacquire1(addr, false, 0)
+	semacquire1(addr, false, 0, 0, nil)
 }
 
-func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags) {
+// semacquire1 waits until *addr > 0 and then decrements it.
+// If done is not nil, semacquire1 also gives up waiting once the
+// channel done is closed, and reports whether it decremented *addr.
+func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags, skipframes int, done *hchan) bool {
 	gp := getg()
 	if gp != gp.m.curg {
 		throw("semacquire not on the G stack")
@@ -108,7 +121,7 @@ func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags) {
 
 	// Easy case.
 	if cansemacquire(addr) {
-		return
+		return true
 	}
 
 	// Harder case:
@@ -123,6 +136,22 @@ func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags) {
 	s.releasetime = 0
 	s.acquiretime = 0
 	s.ticket = 0
+	var cs *sudog
+	if done != nil {
+		// Wait on done as well, as a select would: semrelease and
+		// closechan race to win gp.selectDone, and only the winner
+		// wakes us.
+		s.isSelect = true
+		cs = acquireSudog()
+		cs.g = gp
+		cs.isSelect = true
+		cs.elem = nil
+		cs.releasetime = 0
+		cs.c = done
+		cs.waitlink = nil
+		gp.waiting = cs
+	}
+	acquired := true
 	if profile&semaBlockProfile != 0 && blockprofilerate > 0 {
 		t0 = cputicks()
 		s.releasetime = -1
@@ -146,24 +175,86 @@ func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags) {
 		// Any semrelease after the cansemacquire knows we're waiting
 		// (we set nwait above), so go to sleep.
 		root.queue(addr, s, lifo)
-		goparkunlock(&root.lock, "semacquire", traceEvGoBlockSync, 4)
+		gp.waitobj = uintptr(unsafe.Pointer(addr))
+		if cs == nil {
+			goparkunlock(&root.lock, "semacquire", traceEvGoBlockSync, 4+skipframes)
+		} else if !semaparkdone(gp, root, addr, s, cs, skipframes) {
+			gp.waitobj = 0
+			acquired = false
+			break
+		}
+		gp.waitobj = 0
 		if s.ticket != 0 || cansemacquire(addr) {
 			break
 		}
 	}
+	if cs != nil {
+		gp.waiting = nil
+		cs.g = nil
+		cs.isSelect = false
+		cs.c = nil
+		s.isSelect = false
+		releaseSudog(cs)
+	}
 	if s.releasetime > 0 {
-		blockevent(s.releasetime-t0, 3)
+		blockevent(s.releasetime-t0, 3+skipframes)
 	}
 	releaseSudog(s)
+	return acquired
+}
+
+// semaparkdone parks gp, which has just queued s on root for addr
+// with root.lock held, until either semrelease wakes it or the channel
+// cs.c is closed. It enqueues cs on the channel to be woken by its
+// closing. semaparkdone releases root.lock and reports whether gp was
+// woken by semrelease. Both sudogs are dequeued on return.
+func semaparkdone(gp *g, root *semaRoot, addr *uint32, s, cs *sudog, skipframes int) bool {
+	c := cs.c
+	lock(&c.lock)
+	if c.closed != 0 {
+		if raceenabled {
+			raceacquire(unsafe.Pointer(c))
+		}
+	} else {
+		gp.param = nil
+		c.recvq.enqueue(cs)
+		gopark(semaparkcommit, unsafe.Pointer(root), "semacquire", traceEvGoBlockSync, 5+skipframes)
+		lock(&root.lock)
+		lock(&c.lock)
+	}
+
+	// Whoever woke us, if anyone, has dequeued its sudog.
+	// Dequeue the other one before resetting selectDone,
+	// so that nothing else can try to win it.
+	if root.remove(addr, s) {
+		atomic.Xadd(&root.nwait, -1)
+	}
+	c.recvq.dequeueSudoG(cs)
+	gp.selectDone = 0
+	unlock(&c.lock)
+	unlock(&root.lock)
+
+	woken := gp.param == unsafe.Pointer(s)
+	gp.param = nil
+	return woken
+}
+
+// semaparkcommit releases the locks held by semaparkdone once gp is
+// parked. Like selparkcommit, it must not access gp's stack.
+func semaparkcommit(gp *g, root unsafe.Pointer) bool {
+	c := gp.waiting.c
+	unlock(&(*semaRoot)(root).lock)
+	unlock(&c.lock)
+	return true
 }
 
 // semrelease函数首先让信号量加一，然后检查是否有正在等待的Goroutine：
 // 如果没有，直接返回；如果有，调用goready函数唤醒一个Goroutine。
 func semrelease(addr *uint32) {
-	semrelease1(addr, false)
+	semrelease1(addr, false, 0)
 }
 
-func semrelease1(addr *uint32, handoff bool) {
+func semrelease1(addr *uint32, handoff bool, skipframes int) {
 	root := semroot(addr)
 	atomic.Xadd(addr, 1)
 
@@ -182,15 +273,30 @@ func semrelease1(addr *uint32, handoff bool) {
 		unlock(&root.lock)
 		return
 	}
-	s, t0 := root.dequeue(addr)
-	if s != nil {
+	var s *sudog
+	var t0 int64
+	for {
+		s, t0 = root.dequeue(addr)
+		if s == nil {
+			break
+		}
 		atomic.Xadd(&root.nwait, -1)
+		// A waiter that is also waiting for a channel to be
+		// closed must win the race to be woken by us. If it
+		// loses, it is already on its way out; wake the next one.
+		if !s.isSelect {
+			break
+		}
+		if atomic.Cas(&s.g.selectDone, 0, 1) {
+			s.g.param = unsafe.Pointer(s)
+			break
+		}
 	}
 	unlock(&root.lock)
 	if s != nil { // May be slow, so unlock first
 		acquiretime := s.acquiretime
 		if acquiretime != 0 {
-			mutexevent(t0-acquiretime, 3)
+			mutexevent(t0-acquiretime, 3+skipframes)
 		}
 		if s.ticket != 0 {
 			throw("corrupted semaphore ticket")
@@ -198,7 +304,7 @@ func semrelease1(addr *uint32, handoff bool) {
 		if handoff && cansemacquire(addr) {
 			s.ticket = 1
 		}
-		readyWithTime(s, 5)
+		readyWithTime(s, 5+skipframes)
 	}
 }
 
@@ -322,7 +428,52 @@ func (root *semaRoot) dequeue(addr *uint32) (found *sudog, now int64) {
 	return nil, 0
 
 Found:
-	now = int64(0)
+	return s, root.unlink(ps, s)
+}
+
+// remove removes s, which was queued waiting on addr, from root.
+// It reports whether s was still queued.
+func (root *semaRoot) remove(addr *uint32, s *sudog) bool {
+	ps := &root.treap
+	for t := *ps; t != nil; t = *ps {
+		if t.elem == unsafe.Pointer(addr) {
+			if t == s {
+				root.unlink(ps, s)
+				return true
+			}
+			// Look for s in t's wait list.
+			for p := t; p.waitlink != nil; p = p.waitlink {
+				if p.waitlink != s {
+					continue
+				}
+				p.waitlink = s.waitlink
+				if t.waittail == s {
+					if p == t {
+						t.waittail = nil
+					} else {
+						t.waittail = p
+					}
+				}
+				s.waitlink = nil
+				s.elem = nil
+				s.ticket = 0
+				return true
+			}
+			return false
+		}
+		if uintptr(unsafe.Pointer(addr)) < uintptr(t.elem) {
+			ps = &t.prev
+		} else {
+			ps = &t.next
+		}
+	}
+	return false
+}
+
+// unlink removes s, found at *ps in the tree of unique addrs, from root.
+// If the sudog was being profiled, unlink returns the current time.
+// Otherwise it returns 0.
+func (root *semaRoot) unlink(ps **sudog, s *sudog) (now int64) {
 	if s.acquiretime != 0 {
 		now = cputicks()
 	}
@@ -372,7 +523,7 @@ Found:
 	s.next = nil
 	s.prev = nil
 	s.ticket = 0
-	return s, now
+	return now
 }
 
 // rotateLeft rotates the tree rooted at node x.
@@ -514,7 +665,9 @@ func notifyListWait(l *notifyList, t uint32) {
 		l.tail.next = s
 	}
 	l.tail = s
+	s.g.waitobj = uintptr(unsafe.Pointer(l))
 	goparkunlock(&l.lock, "semacquire", traceEvGoBlockCond, 3)
+	s.g.waitobj = 0
 	if t0 != 0 {
 		blockevent(s.releasetime-t0, 2)
 	}
@@ -619,3 +772,155 @@ func notifyListCheck(sz uintptr) {
 func sync_nanotime() int64 {
 	return nanotime()
 }
+
+// Owner tracking for sync.Mutex, enabled by GODEBUG=mutexowner=1.
+// Each locked Mutex has a record of the goroutine that locked it and
+// where; when it is unlocked, the record keeps the goroutine that
+// unlocked it and where instead, until it is recycled. Records are
+// hashed by Mutex address like semaRoots, with the most recently
+// unlocked ones kept per hash entry.
+
+const (
+	mutexOwnerDepth   = 32 // maximum stack depth recorded
+	mutexUnlockedKeep = 8  // unlocked records kept per mutexOwners entry
+)
+
+//go:notinheap
+type mutexOwner struct {
+	next *mutexOwner
+	addr uintptr
+	goid int64
+	nstk int
+	stk  [mutexOwnerDepth]uintptr
+}
+
+var mutexOwners [semTabSize]struct {
+	lock      mutex
+	locked    *mutexOwner // records of locked mutexes
+	unlocked  *mutexOwner // records of unlocked mutexes, most recent first
+	nunlocked int
+	free      *mutexOwner
+}
+
+//go:linkname sync_runtime_mutexOwnerEnabled sync.runtime_mutexOwnerEnabled
+func sync_runtime_mutexOwnerEnabled() bool {
+	return debug.mutexowner > 0
+}
+
+// sync_runtime_mutexAcquired records that the calling goroutine
+// locked the Mutex at addr.
+//go:linkname sync_runtime_mutexAcquired sync.runtime_mutexAcquired
+func sync_runtime_mutexAcquired(addr unsafe.Pointer) {
+	var stk [mutexOwnerDepth]uintptr
+	nstk := callers(1, stk[:])
+
+	tab := &mutexOwners[(uintptr(addr)>>3)%semTabSize]
+	lock(&tab.lock)
+	o := mutexOwnerRemove(&tab.locked, uintptr(addr))
+	if o == nil {
+		o = mutexOwnerRemove(&tab.unlocked, uintptr(addr))
+		if o != nil {
+			tab.nunlocked--
+		}
+	}
+	if o == nil {
+		if o = tab.free; o != nil {
+			tab.free = o.next
+		} else {
+			o = (*mutexOwner)(persistentalloc(unsafe.Sizeof(mutexOwner{}), 0, &memstats.other_sys))
+		}
+		o.addr = uintptr(addr)
+	}
+	o.goid = getg().goid
+	o.nstk = nstk
+	o.stk = stk
+	o.next = tab.locked
+	tab.locked = o
+	unlock(&tab.lock)
+}
+
+// sync_runtime_mutexReleased records that the calling goroutine is
+// about to unlock the Mutex at addr. It crashes the program if the
+// Mutex was locked by another goroutine and checkOwner is set, or if
+// the Mutex was already unlocked.
+//go:linkname sync_runtime_mutexReleased sync.runtime_mutexReleased
+func sync_runtime_mutexReleased(addr unsafe.Pointer, checkOwner bool) {
+	var stk [mutexOwnerDepth]uintptr
+	nstk := callers(1, stk[:])
+	gp := getg()
+
+	tab := &mutexOwners[(uintptr(addr)>>3)%semTabSize]
+	lock(&tab.lock)
+	o := mutexOwnerRemove(&tab.locked, uintptr(addr))
+	if o == nil {
+		// Either the Mutex was locked before tracking began,
+		// or it is being unlocked twice.
+		for p := tab.unlocked; p != nil; p = p.next {
+			if p.addr == uintptr(addr) {
+				prev := *p
+				unlock(&tab.lock)
+				print("sync: Mutex ", addr, " already unlocked by goroutine ", prev.goid, " at:\n")
+				printMutexOwnerStack(prev.stk[:prev.nstk])
+				print("\n")
+				throw("sync: unlock of unlocked mutex")
+			}
+		}
+		unlock(&tab.lock)
+		return
+	}
+	if checkOwner && o.goid != gp.goid {
+		prev := *o
+		unlock(&tab.lock)
+		print("sync: Mutex ", addr, " locked by goroutine ", prev.goid, " unlocked by goroutine ", gp.goid, "\n")
+		print("locked at:\n")
+		printMutexOwnerStack(prev.stk[:prev.nstk])
+		print("\n")
+		throw("sync: unlock of mutex locked by another goroutine")
+	}
+	o.goid = gp.goid
+	o.nstk = nstk
+	o.stk = stk
+	o.next = tab.unlocked
+	tab.unlocked = o
+	if tab.nunlocked++; tab.nunlocked > mutexUnlockedKeep {
+		// Recycle the least recently unlocked record.
+		p := tab.unlocked
+		for p.next.next != nil {
+			p = p.next
+		}
+		p.next.next = tab.free
+		tab.free = p.next
+		p.next = nil
+		tab.nunlocked--
+	}
+	unlock(&tab.lock)
+}
+
+// mutexOwnerRemove removes the record for addr from the list *l
+// and returns it, or nil if there is none.
+func mutexOwnerRemove(l **mutexOwner, addr uintptr) *mutexOwner {
+	for p := l; *p != nil; p = &(*p).next {
+		if o := *p; o.addr == addr {
+			*p = o.next
+			o.next = nil
+			return o
+		}
+	}
+	return nil
+}
+
+// printMutexOwnerStack prints a stack recorded by callers
+// in the format of a traceback.
+func printMutexOwnerStack(stk []uintptr) {
+	for _, pc := range stk {
+		f := findfunc(pc)
+		if !f.valid() {
+			print("unknown pc ", hex(pc), "\n")
+			continue
+		}
+		// pc is a return address; report the call.
+		file, line := funcline(f, pc-1)
+		print(funcname(f), "(...)\n")
+		print("\t", file, ":", line, "\n")
+	}
+}
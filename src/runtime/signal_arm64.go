@@ -88,3 +88,14 @@ func (c *sigctxt) preparePanic(sig uint32, gp *g) {
 	c.set_r28(uint64(uintptr(unsafe.Pointer(gp))))
 	c.set_pc(uint64(funcPC(sigpanic)))
 }
+
+func (c *sigctxt) pushCall(targetPC uintptr) {
+	// Make it look like the signaled instruction called target.
+	// Push the current LR to the stack the same way preparePanic
+	// does, so the traceback can unwind it.
+	sp := c.sp() - sys.SpAlign // needs only sizeof uint64, but must align the stack
+	c.set_sp(sp)
+	*(*uint64)(unsafe.Pointer(uintptr(sp))) = c.lr()
+	c.set_lr(c.pc())
+	c.set_pc(uint64(targetPC))
+}
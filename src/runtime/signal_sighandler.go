@@ -7,6 +7,7 @@
 package runtime
 
 import (
+	"runtime/internal/atomic"
 	"unsafe"
 )
 
@@ -34,6 +35,13 @@ func sighandler(sig uint32, info *siginfo, ctxt unsafe.Pointer, gp *g) {
 		return
 	}
 
+	if sig == sigPreempt && preemptMSupported && debug.asyncpreemptoff == 0 {
+		// Might be a preemption signal. The signal may also
+		// have been sent by something else, so we keep going
+		// and let it be handled like any other signal below.
+		doSigPreempt(gp, c)
+	}
+
 	flags := int32(_SigThrow)
 	if sig < uint32(len(sigtable)) {
 		flags = sigtable[sig].flags
@@ -112,6 +120,9 @@ func sighandler(sig uint32, info *siginfo, ctxt unsafe.Pointer, gp *g) {
 			print("\n")
 		}
 		dumpregs(c)
+		// Like the text above, the report covers the other
+		// goroutines; it is only written by the first m.
+		writeCrashReport(gp, c.sigpc(), c.sigsp(), true)
 	}
 
 	if docrash {
@@ -136,3 +147,18 @@ func sighandler(sig uint32, info *siginfo, ctxt unsafe.Pointer, gp *g) {
 
 	exit(2)
 }
+
+// doSigPreempt handles a preemption signal on gp.
+//
+//go:nowritebarrierrec
+func doSigPreempt(gp *g, ctxt *sigctxt) {
+	// Check if this G wants to be preempted and is safe to
+	// preempt.
+	if wantAsyncPreempt(gp) && isAsyncSafePoint(gp, ctxt.sigpc(), ctxt.sigsp()) {
+		// Inject a call to asyncPreempt.
+		ctxt.pushCall(funcPC(asyncPreempt))
+	}
+
+	// Acknowledge the preemption.
+	atomic.Store(&gp.m.signalPending, 0)
+}
@@ -806,6 +806,7 @@ func adjustdefers(gp *g, adjinfo *adjustinfo) {
 		adjustpointer(adjinfo, unsafe.Pointer(&d.fn))
 		adjustpointer(adjinfo, unsafe.Pointer(&d.sp))
 		adjustpointer(adjinfo, unsafe.Pointer(&d._panic))
+		adjustpointer(adjinfo, unsafe.Pointer(&d.varp))
 	}
 }
 
@@ -1091,7 +1092,7 @@ func newstack() {
 	// 因为GC可能会改变Gwaiting到Gscanwaiting，然后这个goroutine必须等待GC完成才能继续。
 	// 如果GC在某种程度上依赖于此goroutine（例如，它需要由goroutine保持锁定），那么小的抢占就会变成真正的死锁。
 	if preempt {
-		if thisg.m.locks != 0 || thisg.m.mallocing != 0 || thisg.m.preemptoff != "" || thisg.m.p.ptr().status != _Prunning {
+		if !canPreemptM(thisg.m) {
 			// Let the goroutine keep running for now.
 			// gp->preempt is set, so it will be preempted next time.
 			gp.stackguard0 = gp.stack.lo + _StackGuard
@@ -1125,38 +1126,7 @@ func newstack() {
 		if thisg.m.p == 0 && thisg.m.locks == 0 {
 			throw("runtime: g is running but p is not")
 		}
-		// Synchronize with scang.
-		casgstatus(gp, _Grunning, _Gwaiting)
-		if gp.preemptscan {
-			for !castogscanstatus(gp, _Gwaiting, _Gscanwaiting) {
-				// Likely to be racing with the GC as
-				// it sees a _Gwaiting and does the
-				// stack scan. If so, gcworkdone will
-				// be set and gcphasework will simply
-				// return.
-			}
-			if !gp.gcscandone {
-				// gcw is safe because we're on the
-				// system stack.
-				gcw := &gp.m.p.ptr().gcw
-				scanstack(gp, gcw)
-				if gcBlackenPromptly {
-					gcw.dispose()
-				}
-				gp.gcscandone = true
-			}
-			gp.preemptscan = false
-			gp.preempt = false
-			casfrom_Gscanstatus(gp, _Gscanwaiting, _Gwaiting)
-			// This clears gcscanvalid.
-			casgstatus(gp, _Gwaiting, _Grunning)
-			gp.stackguard0 = gp.stack.lo + _StackGuard
-			gogo(&gp.sched) // never return
-		}
-
-		// Act like goroutine called runtime.Gosched.
-		casgstatus(gp, _Gwaiting, _Grunning)
-		gopreempt_m(gp) // never return
+		preempt_m(gp) // never return
 	}
 
 	// Allocate a bigger segment and move the stack.
@@ -1190,6 +1160,48 @@ func newstack() {
 	gogo(&gp.sched)
 }
 
+// preempt_m preempts gp, which was running and has just entered the
+// runtime, either from the stack bound check in a function prologue
+// or from an asynchronous preemption. If the GC is trying to scan
+// gp's stack, preempt_m scans it and resumes gp. Otherwise, it acts as
+// if gp called runtime.Gosched.
+//
+// preempt_m runs on g0.
+func preempt_m(gp *g) {
+	// Synchronize with scang.
+	casgstatus(gp, _Grunning, _Gwaiting)
+	if gp.preemptscan {
+		for !castogscanstatus(gp, _Gwaiting, _Gscanwaiting) {
+			// Likely to be racing with the GC as
+			// it sees a _Gwaiting and does the
+			// stack scan. If so, gcworkdone will
+			// be set and gcphasework will simply
+			// return.
+		}
+		if !gp.gcscandone {
+			// gcw is safe because we're on the
+			// system stack.
+			gcw := &gp.m.p.ptr().gcw
+			scanstack(gp, gcw)
+			if gcBlackenPromptly {
+				gcw.dispose()
+			}
+			gp.gcscandone = true
+		}
+		gp.preemptscan = false
+		gp.preempt = false
+		casfrom_Gscanstatus(gp, _Gscanwaiting, _Gwaiting)
+		// This clears gcscanvalid.
+		casgstatus(gp, _Gwaiting, _Grunning)
+		gp.stackguard0 = gp.stack.lo + _StackGuard
+		gogo(&gp.sched) // never return
+	}
+
+	// Act like goroutine called runtime.Gosched.
+	casgstatus(gp, _Gwaiting, _Grunning)
+	gopreempt_m(gp) // never return
+}
+
 //go:nosplit
 func nilfunc() {
 	*(*uint8)(nil) = 0
@@ -1247,6 +1259,12 @@ func shrinkstack(gp *g) {
 		// stack (see gcBgMarkWorker for explanation).
 		return
 	}
+	if gp.asyncSafePoint {
+		// We don't have precise pointer maps for the
+		// innermost frames of an asynchronously preempted
+		// goroutine, so we can't copy its stack.
+		return
+	}
 
 	// 收缩栈，变为原来的一半，当然这里的一半不能比最小栈还小
 	oldsize := gp.stack.hi - gp.stack.lo
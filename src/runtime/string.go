@@ -390,6 +390,41 @@ func atoi32(s string) (int32, bool) {
 	return 0, false
 }
 
+// parseByteCount parses a non-negative byte count with an optional
+// binary unit suffix, as accepted by GOMEMLIMIT.
+func parseByteCount(s string) (int64, bool) {
+	shift := uint(0)
+	for _, u := range [...]struct {
+		suffix string
+		shift  uint
+	}{{"TiB", 40}, {"GiB", 30}, {"MiB", 20}, {"KiB", 10}, {"B", 0}} {
+		if len(s) > len(u.suffix) && s[len(s)-len(u.suffix):] == u.suffix {
+			s = s[:len(s)-len(u.suffix)]
+			shift = u.shift
+			break
+		}
+	}
+	if len(s) == 0 {
+		return 0, false
+	}
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		if n > (maxInt64-uint64(c-'0'))/10 {
+			// Overflow.
+			return 0, false
+		}
+		n = n*10 + uint64(c-'0')
+	}
+	if n > maxInt64>>shift {
+		return 0, false
+	}
+	return int64(n << shift), true
+}
+
 //go:nosplit
 func findnull(s *byte) int {
 	if s == nil {
@@ -388,3 +388,37 @@ func TestAtoi32(t *testing.T) {
 		}
 	}
 }
+
+type parseByteCountTest struct {
+	in  string
+	out int64
+	ok  bool
+}
+
+var parseByteCountTests = []parseByteCountTest{
+	{"", 0, false},
+	{"B", 0, false},
+	{"0", 0, true},
+	{"0B", 0, true},
+	{"1024", 1024, true},
+	{"1KiB", 1 << 10, true},
+	{"512MiB", 512 << 20, true},
+	{"4GiB", 4 << 30, true},
+	{"2TiB", 2 << 40, true},
+	{"-1", 0, false},
+	{"1.5GiB", 0, false},
+	{"1KB", 0, false},
+	{"9223372036854775807", 1<<63 - 1, true},
+	{"9223372036854775808", 0, false},
+	{"8388608TiB", 0, false},
+}
+
+func TestParseByteCount(t *testing.T) {
+	for _, test := range parseByteCountTests {
+		out, ok := runtime.ParseByteCount(test.in)
+		if test.out != out || test.ok != ok {
+			t.Errorf("parseByteCount(%q) = (%v, %v) want (%v, %v)",
+				test.in, out, ok, test.out, test.ok)
+		}
+	}
+}
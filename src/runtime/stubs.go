@@ -119,7 +119,7 @@ func reflect_memmove(to, from unsafe.Pointer, n uintptr) {
 }
 
 // exported value for testing
-var hashLoad = float32(loadFactorNum) / float32(loadFactorDen)
+var hashLoad = float32(bucketCnt*loadFactorNum) / float32(loadFactorDen)
 
 //go:nosplit
 func fastrand() uint32 {
@@ -10,3 +10,21 @@ package runtime
 func sbrk0() uintptr {
 	return 0
 }
+
+// Only Linux has cgroups.
+func cgroupInit() {}
+
+func cgroupCPULimit() (float64, bool) {
+	return 0, false
+}
+
+// preemptMSupported is false on platforms that do not implement
+// asynchronous preemption yet.
+const preemptMSupported = false
+
+func preemptM(mp *m) {
+	// Not currently supported.
+	//
+	// TODO: Use a dedicated preemption signal on the other
+	// Unix systems and SuspendThread on Windows.
+}
@@ -133,7 +133,8 @@ again:
 		}
 		se.pcExpander.init(ncallers[0], se.wasPanic)
 		ncallers = ncallers[1:]
-		se.wasPanic = se.pcExpander.funcInfo.valid() && se.pcExpander.funcInfo.funcID == funcID_sigpanic
+		se.wasPanic = se.pcExpander.funcInfo.valid() &&
+			(se.pcExpander.funcInfo.funcID == funcID_sigpanic || se.pcExpander.funcInfo.funcID == funcID_asyncPreempt)
 		if se.skip > 0 {
 			for ; se.skip > 0; se.skip-- {
 				se.pcExpander.next()
@@ -341,12 +342,20 @@ func (f *Func) funcInfo() funcInfo {
 //
 // See funcdata.h and ../cmd/internal/obj/funcdata.go.
 const (
-	_PCDATA_StackMapIndex       = 0
-	_PCDATA_InlTreeIndex        = 1
-	_FUNCDATA_ArgsPointerMaps   = 0
-	_FUNCDATA_LocalsPointerMaps = 1
-	_FUNCDATA_InlTree           = 2
-	_ArgsSizeUnknown            = -0x80000000
+	_PCDATA_StackMapIndex        = 0
+	_PCDATA_InlTreeIndex         = 1
+	_PCDATA_UnsafePoint          = 2
+	_FUNCDATA_ArgsPointerMaps    = 0
+	_FUNCDATA_LocalsPointerMaps  = 1
+	_FUNCDATA_InlTree            = 2
+	_FUNCDATA_OpenCodedDeferInfo = 3
+	_ArgsSizeUnknown             = -0x80000000
+)
+
+// PCDATA_UnsafePoint values.
+const (
+	_PCDATA_UnsafePointSafe   = -1 // Safe for async preemption
+	_PCDATA_UnsafePointUnsafe = -2 // Unsafe for async preemption
 )
 
 // A FuncID identifies particular functions that need to be treated
@@ -368,14 +377,17 @@ const (
 	funcID_sigpanic
 	funcID_runfinq
 	funcID_bgsweep
+	funcID_bgscavenge
 	funcID_forcegchelper
-	funcID_timerproc
+	funcID_gomaxprocshelper
+	funcID_uniquemapcleanup
 	funcID_gcBgMarkWorker
 	funcID_systemstack_switch
 	funcID_systemstack
 	funcID_cgocallback_gofunc
 	funcID_gogo
 	funcID_externalthreadhandler
+	funcID_asyncPreempt
 )
 
 // moduledata records information about the layout of the executable
@@ -846,6 +858,25 @@ func funcspdelta(f funcInfo, targetpc uintptr, cache *pcvalueCache) int32 {
 	return x
 }
 
+// funcMaxSPDelta returns the maximum spdelta at any point in f.
+func funcMaxSPDelta(f funcInfo) int32 {
+	datap := f.datap
+	p := datap.pclntable[f.pcsp:]
+	pc := f.entry
+	val := int32(-1)
+	max := int32(0)
+	for {
+		var ok bool
+		p, ok = step(p, &pc, &val, pc == f.entry)
+		if !ok {
+			return max
+		}
+		if val > max {
+			max = val
+		}
+	}
+}
+
 func pcdatavalue(f funcInfo, table int32, targetpc uintptr, cache *pcvalueCache) int32 {
 	if table < 0 || table >= f.npcdata {
 		return -1
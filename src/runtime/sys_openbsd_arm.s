@@ -376,6 +376,15 @@ TEXT runtime·closeonexec(SB),NOSPLIT,$0
 	SWI	$0
 	RET
 
+// func pipe2(flags int32) (r, w int32, errno int32)
+TEXT runtime·pipe2(SB),NOSPLIT,$0-16
+	MOVW	$r+4(FP), R0		// arg 1 - fildes
+	MOVW	flags+0(FP), R1		// arg 2 - flags
+	MOVW	$101, R12		// sys_pipe2
+	SWI	$0
+	MOVW	R0, errno+12(FP)
+	RET
+
 TEXT ·publicationBarrier(SB),NOSPLIT,$-4-0
 	B	runtime·armPublicationBarrier(SB)
 
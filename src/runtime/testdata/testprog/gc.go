@@ -17,6 +17,7 @@ func init() {
 	register("GCFairness", GCFairness)
 	register("GCFairness2", GCFairness2)
 	register("GCSys", GCSys)
+	register("GoroutineLeak", GoroutineLeak)
 }
 
 func GCSys() {
@@ -121,3 +122,18 @@ func GCFairness2() {
 	}
 	fmt.Println("OK")
 }
+
+func GoroutineLeak() {
+	leakRecv(make(chan int))
+	time.Sleep(10 * time.Millisecond)
+	// With GODEBUG=goroutineleak=1, the first collection looks
+	// for leaked goroutines.
+	runtime.GC()
+	fmt.Println("OK")
+}
+
+func leakRecv(c chan int) {
+	go func() {
+		<-c
+	}()
+}
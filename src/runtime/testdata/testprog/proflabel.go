@@ -0,0 +1,21 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+func init() {
+	register("TracebackLabels", TracebackLabels)
+}
+
+func TracebackLabels() {
+	// Not pprof.Do, which restores the labels before the panic is printed.
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("tenant", "a", "request", "7"))
+	pprof.SetGoroutineLabels(ctx)
+	panic("labeled")
+}
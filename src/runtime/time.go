@@ -7,6 +7,7 @@
 package runtime
 
 import (
+	"runtime/internal/atomic"
 	"runtime/internal/sys"
 	"unsafe"
 )
@@ -16,11 +17,16 @@ import (
 // For GOOS=nacl, package syscall knows the layout of this structure.
 // If this struct changes, adjust ../syscall/net_nacl.go:/runtimeTimer.
 type timer struct {
-	tb *timersBucket // the bucket the timer lives in
-	i  int           // heap index
+	// If this timer is on a heap, which P's heap it is on.
+	// puintptr rather than *p to match uintptr in the versions
+	// of this struct defined in other packages.
+	// Set under the P's timersLock, but may be read without it
+	// using atomic operations.
+	pp puintptr
+	i  int // heap index, valid only if pp != 0
 
 	// Timer wakes up at when, and then at when+period, ... (period > 0 only)
-	// each time calling f(arg, now) in the timer goroutine, so f must be
+	// each time calling f(arg, now) in the scheduler, so f must be
 	// a well-behaved function and not block.
 	when   int64
 	period int64
@@ -29,47 +35,22 @@ type timer struct {
 	seq    uintptr
 }
 
-// timersLen is the length of timers array.
+// Timers are kept in a heap on each P, protected by the P's
+// timersLock. A timer is added to the heap of the P that starts it and
+// stays there until it runs or is deleted, so there is no lock shared
+// by all the timers in the program. The timers are run by the
+// scheduler: schedule and findrunnable call checkTimers, which runs
+// any timers that are due, and an idle M that has nothing else to do
+// waits in netpoll until the earliest timer is due. There are no
+// goroutines dedicated to running timers.
 //
-// Ideally, this would be set to GOMAXPROCS, but that would require
-// dynamic reallocation
-//
-// The current value is a compromise between memory usage and performance
-// that should cover the majority of GOMAXPROCS values used in the wild.
-const timersLen = 64
-
-// timers contains "per-P" timer heaps.
-//
-// Timers are queued into timersBucket associated with the current P,
-// so each P may work with its own timers independently of other P instances.
-//
-// Each timersBucket may be associated with multiple P
-// if GOMAXPROCS > timersLen.
-var timers [timersLen]struct {
-	timersBucket
-
-	// The padding should eliminate false sharing
-	// between timersBucket values.
-	pad [sys.CacheLineSize - unsafe.Sizeof(timersBucket{})%sys.CacheLineSize]byte
-}
+// When a P is destroyed by procresize, its timers are moved to
+// another P. A timer's pp field only changes while the timer's heap
+// is locked, so code that wants to delete a timer reads pp, locks
+// that P's timers, and then checks that pp is still the same.
 
-func (t *timer) assignBucket() *timersBucket {
-	id := uint8(getg().m.p.ptr().id) % timersLen
-	t.tb = &timers[id].timersBucket
-	return t.tb
-}
-
-//go:notinheap
-type timersBucket struct {
-	lock         mutex
-	gp           *g
-	created      bool
-	sleeping     bool
-	rescheduling bool
-	sleepUntil   int64
-	waitnote     note
-	t            []*timer
-}
+// maxWhen is the maximum value for timer's when field.
+const maxWhen = 1<<63 - 1
 
 // nacl fake time support - time in nanoseconds since 1970
 var faketime int64
@@ -96,10 +77,16 @@ func timeSleep(ns int64) {
 	t.when = nanotime() + ns
 	t.f = goroutineReady
 	t.arg = gp
-	tb := t.assignBucket()
-	lock(&tb.lock)
-	tb.addtimerLocked(t)
-	goparkunlock(&tb.lock, "sleep", traceEvGoSleep, 2)
+	gopark(addtimerForSleep, unsafe.Pointer(t), "sleep", traceEvGoSleep, 1)
+}
+
+// addtimerForSleep is called after the goroutine is parked for timeSleep.
+// We can't call addtimer in timeSleep itself because if this is a short
+// sleep and there are many goroutines then the P can wind up running the
+// timer function, goroutineReady, before the goroutine has been parked.
+func addtimerForSleep(gp *g, t unsafe.Pointer) bool {
+	addtimer((*timer)(t))
+	return true
 }
 
 // startTimer adds t to the timer heap.
@@ -125,219 +112,321 @@ func goroutineReady(arg interface{}, seq uintptr) {
 	goready(arg.(*g), 0)
 }
 
-func addtimer(t *timer) {
-	tb := t.assignBucket()
-	lock(&tb.lock)
-	tb.addtimerLocked(t)
-	unlock(&tb.lock)
+// timerP returns the P whose heap t is on, or nil if t is not on a heap.
+func (t *timer) timerP() *p {
+	return (*p)(unsafe.Pointer(atomic.Loaduintptr((*uintptr)(unsafe.Pointer(&t.pp)))))
+}
+
+// setTimerP records that t is on pp's heap. pp may be nil.
+// The caller must hold the timers lock of the old and new P.
+func (t *timer) setTimerP(pp *p) {
+	atomic.Storeuintptr((*uintptr)(unsafe.Pointer(&t.pp)), uintptr(unsafe.Pointer(pp)))
 }
 
-// Add a timer to the heap and start or kick timerproc if the new timer is
-// earlier than any of the others.
-// Timers are locked.
-// 增加一个定时器到堆中并开始或者删掉timeproc如果新的定时器早于任何定时器的话
-func (tb *timersBucket) addtimerLocked(t *timer) {
-	// when must never be negative; otherwise timerproc will overflow
+// addtimer adds a timer to the current P.
+// This should only be called with a timer that is not on any heap.
+func addtimer(t *timer) {
+	// when must never be negative; otherwise runtimer will overflow
 	// during its delta calculation and never expire other runtime timers.
-	// when 必须不能是负值，否则的话 timeproc将会溢出
+	// when 必须不能是负值，否则的话 runtimer 将会溢出
 	if t.when < 0 {
-		t.when = 1<<63 - 1
-	}
-	t.i = len(tb.t)
-	tb.t = append(tb.t, t)
-	siftupTimer(tb.t, t.i)
-	if t.i == 0 {
-		// siftup moved to top: new earliest deadline.
-		if tb.sleeping {
-			tb.sleeping = false
-			// 唤醒睡眠中的goroutine
-			notewakeup(&tb.waitnote)
-		}
-		if tb.rescheduling {
-			tb.rescheduling = false
-			goready(tb.gp, 0)
-		}
+		t.when = maxWhen
+	} else if t.when == 0 {
+		// A zero timer0When means the heap is empty, so a timer
+		// that is already due must still have a positive when.
+		t.when = 1
 	}
-	// 只运行一次timeproc()
-	if !tb.created {
-		tb.created = true
-		go timerproc(tb)
+	if t.timerP() != nil {
+		throw("addtimer called with initialized timer")
 	}
+
+	when := t.when
+
+	// Disable preemption while using pp to avoid changing another P's heap.
+	mp := acquirem()
+
+	pp := getg().m.p.ptr()
+	lock(&pp.timersLock)
+	doaddtimer(pp, t)
+	unlock(&pp.timersLock)
+
+	wakeNetPoller(when)
+
+	releasem(mp)
 }
 
-// Delete timer t from the heap.
-// Do not need to update the timerproc: if it wakes up early, no big deal.
-func deltimer(t *timer) bool {
-	if t.tb == nil {
-		// t.tb can be nil if the user created a timer
-		// directly, without invoking startTimer e.g
-		//    time.Ticker{C: c}
-		// In this case, return early without any deletion.
-		// See Issue 21874.
-		return false
+// doaddtimer adds t to pp's heap.
+// The caller must have locked the timers for pp.
+func doaddtimer(pp *p, t *timer) {
+	// Timers rely on the network poller, so make sure the poller
+	// has started.
+	if !netpollinited() {
+		netpollGenericInit()
 	}
 
-	tb := t.tb
+	t.setTimerP(pp)
+	i := len(pp.timers)
+	t.i = i
+	pp.timers = append(pp.timers, t)
+	siftupTimer(pp.timers, i)
+	if t == pp.timers[0] {
+		atomic.Store64(&pp.timer0When, uint64(t.when))
+	}
+}
 
-	lock(&tb.lock)
-	// t may not be registered anymore and may have
-	// a bogus i (typically 0, if generated by Go).
-	// Verify it before proceeding.
-	i := t.i
-	last := len(tb.t) - 1
-	if i < 0 || i > last || tb.t[i] != t {
-		unlock(&tb.lock)
-		return false
+// deltimer deletes the timer t. It may be on some other P, so we can't
+// assume that the current P's timers are the right ones to lock.
+// Reports whether the timer was removed before it was run.
+func deltimer(t *timer) bool {
+	for {
+		pp := t.timerP()
+		if pp == nil {
+			// The timer has already run, or was never started.
+			// t.pp can be zero if the user created a timer
+			// directly, without invoking startTimer e.g
+			//    time.Ticker{C: c}
+			// See Issue 21874.
+			return false
+		}
+		lock(&pp.timersLock)
+		if t.timerP() == pp {
+			if i := t.i; i >= len(pp.timers) || pp.timers[i] != t {
+				throw("deltimer: timer not at its heap index")
+			}
+			dodeltimer(pp, t.i)
+			unlock(&pp.timersLock)
+			return true
+		}
+		// The timer ran or moved to another P while we were
+		// acquiring the lock. Look again.
+		unlock(&pp.timersLock)
 	}
+}
+
+// dodeltimer removes timer i from pp's heap.
+// The caller must have locked the timers for pp.
+func dodeltimer(pp *p, i int) {
+	if t := pp.timers[i]; t.timerP() != pp {
+		throw("dodeltimer: wrong P")
+	}
+	pp.timers[i].setTimerP(nil)
+	last := len(pp.timers) - 1
 	if i != last {
-		tb.t[i] = tb.t[last]
-		tb.t[i].i = i
+		pp.timers[i] = pp.timers[last]
+		pp.timers[i].i = i
 	}
-	tb.t[last] = nil
-	tb.t = tb.t[:last]
+	pp.timers[last] = nil
+	pp.timers = pp.timers[:last]
 	if i != last {
-		siftupTimer(tb.t, i)
-		siftdownTimer(tb.t, i)
+		// Moving to i may have moved the last timer to a new parent,
+		// so sift up to preserve the heap guarantee.
+		siftupTimer(pp.timers, i)
+		siftdownTimer(pp.timers, i)
 	}
-	unlock(&tb.lock)
-	return true
+	updateTimer0When(pp)
 }
 
-// Timerproc runs the time-driven events.
-// It sleeps until the next event in the tb heap.
-// If addtimer inserts a new earlier event, it wakes timerproc early.
-// Timeproc 运行时间驱动的事件
-// 它会一直睡眠直到下一个事件进入了定时器的堆中
-// 如果 addtimer 插入了一个更早的事件， addtimerLocked 将会更早地唤醒timeproc
-func timerproc(tb *timersBucket) {
-	// 设置定时器goroutine
-	tb.gp = getg()
-	for {
-		lock(&tb.lock)
-		tb.sleeping = false
-		now := nanotime()
-		delta := int64(-1)
-		// 从堆的[0]开始迭代定时器
-		for {
-			// 已经没有其他的定时器了，退出迭代循环
-			if len(tb.t) == 0 {
-				delta = -1
-				break
-			}
-			t := tb.t[0]
-			delta = t.when - now
-			if delta > 0 {
-				break
-			}
-			// t.period 意味着它是一个ticker，所以改变 when 并移到堆的下方，经过t.period再执行
-			if t.period > 0 {
-				// leave in heap but adjust next time to fire
-				t.when += t.period * (1 + -delta/t.period)
-				siftdownTimer(tb.t, 0)
-			} else {
-				// remove from heap
-				last := len(tb.t) - 1
-				if last > 0 {
-					tb.t[0] = tb.t[last]
-					tb.t[0].i = 0
-				}
-				tb.t[last] = nil
-				tb.t = tb.t[:last]
-				if last > 0 {
-					siftdownTimer(tb.t, 0)
-				}
-				t.i = -1 // mark as removed
-			}
-			f := t.f
-			arg := t.arg
-			seq := t.seq
-			unlock(&tb.lock)
-			if raceenabled {
-				raceacquire(unsafe.Pointer(t))
-			}
-			// 无锁调用f，一般来说就是sendTime，发送一个事件
-			f(arg, seq)
-			lock(&tb.lock)
-		}
-		// 如果 delta < 0 - 定时器为空，设成 “rescheduling” 并暂停定时器goroutine。它将会一直睡眠直到在 addtimerLocked 中调用 "goready"
-		if delta < 0 || faketime > 0 {
-			// No timers left - put goroutine to sleep.
-			tb.rescheduling = true
-			goparkunlock(&tb.lock, "timer goroutine (idle)", traceEvGoBlock, 1)
-			continue
-		}
-		// At least one timer pending. Sleep until then.
-		// 至少还有一个定时器未结束，睡眠直到结束
-		// 如果在堆中还有一些定时器，那么会一直睡眠直到最快的时间到了
-		tb.sleeping = true
-		tb.sleepUntil = now + delta
-		noteclear(&tb.waitnote)
-		unlock(&tb.lock)
-		notetsleepg(&tb.waitnote, delta)
+// updateTimer0When sets pp.timer0When from the top of the heap.
+// The caller must have locked the timers for pp.
+func updateTimer0When(pp *p) {
+	if len(pp.timers) == 0 {
+		atomic.Store64(&pp.timer0When, 0)
+	} else {
+		atomic.Store64(&pp.timer0When, uint64(pp.timers[0].when))
 	}
 }
 
-func timejump() *g {
-	if faketime == 0 {
-		return nil
+// moveTimers moves all the timers on pp's heap to the current P's heap.
+// This is called when pp is being destroyed, while the world is stopped.
+func moveTimers(plocal, pp *p) {
+	lock(&plocal.timersLock)
+	lock(&pp.timersLock)
+	for _, t := range pp.timers {
+		t.setTimerP(nil)
+		doaddtimer(plocal, t)
 	}
+	for i := range pp.timers {
+		pp.timers[i] = nil
+	}
+	pp.timers = pp.timers[:0]
+	atomic.Store64(&pp.timer0When, 0)
+	unlock(&pp.timersLock)
+	unlock(&plocal.timersLock)
+}
 
-	for i := range timers {
-		lock(&timers[i].lock)
+// runtimer examines the first timer in timers. If it is ready based on now,
+// it runs the timer and removes or updates it.
+// Returns 0 if it ran a timer, -1 if there are no more timers, or the time
+// when the first timer should run.
+// The caller must have locked the timers for pp.
+// If a timer is run, this will temporarily unlock the timers.
+func runtimer(pp *p, now int64) int64 {
+	if len(pp.timers) == 0 {
+		return -1
+	}
+	t := pp.timers[0]
+	if t.timerP() != pp {
+		throw("runtimer: bad p")
 	}
-	gp := timejumpLocked()
-	for i := range timers {
-		unlock(&timers[i].lock)
+	if t.when > now {
+		// Not ready to run.
+		return t.when
 	}
 
-	return gp
-}
+	// t.period 意味着它是一个ticker，所以改变 when 并移到堆的下方，经过t.period再执行
+	if t.period > 0 {
+		// Leave in heap but adjust next time to fire.
+		delta := t.when - now
+		t.when += t.period * (1 + -delta/t.period)
+		siftdownTimer(pp.timers, 0)
+		updateTimer0When(pp)
+	} else {
+		// Remove from heap.
+		dodeltimer(pp, 0)
+	}
 
-func timejumpLocked() *g {
-	// Determine a timer bucket with minimum when.
-	var minT *timer
-	for i := range timers {
-		tb := &timers[i]
-		if !tb.created || len(tb.t) == 0 {
-			continue
+	f := t.f
+	arg := t.arg
+	seq := t.seq
+
+	unlock(&pp.timersLock)
+
+	if raceenabled {
+		// Timers run on the system stack, which has no race
+		// context, so give each P a context for running timers
+		// and temporarily use it for g0.
+		gp := getg()
+		ppcur := gp.m.p.ptr()
+		if ppcur.timerRaceCtx == 0 {
+			ppcur.timerRaceCtx = racegostart(funcPC(runtimer) + sys.PCQuantum)
 		}
-		t := tb.t[0]
-		if minT == nil || t.when < minT.when {
-			minT = t
+		raceacquirectx(ppcur.timerRaceCtx, unsafe.Pointer(t))
+		if gp.racectx != 0 {
+			throw("runtimer: unexpected racectx")
 		}
+		gp.racectx = ppcur.timerRaceCtx
 	}
-	if minT == nil || minT.when <= faketime {
-		return nil
+
+	// 无锁调用f，一般来说就是sendTime，发送一个事件
+	f(arg, seq)
+
+	if raceenabled {
+		getg().racectx = 0
 	}
 
-	faketime = minT.when
-	tb := minT.tb
-	if !tb.rescheduling {
-		return nil
+	lock(&pp.timersLock)
+	return 0
+}
+
+// checkTimers runs any timers for the P that are ready.
+// If now is not 0 it is the current time.
+// It returns the current time or 0 if it is not known,
+// and the time when the next timer should run or 0 if there is no next timer,
+// and reports whether it ran any timers.
+// If the time when the next timer should run is not 0,
+// it is always larger than the returned time.
+// We pass now in and out to avoid extra calls of nanotime.
+//
+// The caller always has a P (though not necessarily pp), so write
+// barriers are allowed even when called from a nowritebarrierrec
+// function such as schedule.
+//
+//go:yeswritebarrierrec
+func checkTimers(pp *p, now int64) (rnow, pollUntil int64, ran bool) {
+	// If the first timer on the heap is not yet ready to run,
+	// then there is nothing to do.
+	next := int64(atomic.Load64(&pp.timer0When))
+	if next == 0 {
+		// No timers to run.
+		return now, 0, false
+	}
+	if now == 0 {
+		now = nanotime()
+	}
+	if now < next {
+		return now, next, false
 	}
-	tb.rescheduling = false
-	return tb.gp
+
+	lock(&pp.timersLock)
+	for {
+		tw := runtimer(pp, now)
+		if tw != 0 {
+			if tw > 0 {
+				pollUntil = tw
+			}
+			break
+		}
+		ran = true
+	}
+	unlock(&pp.timersLock)
+
+	return now, pollUntil, ran
+}
+
+// shouldStealTimers reports whether we should try stealing the timers from p2.
+// We don't steal timers from a running P that is not marked for preemption,
+// on the assumption that it will run its own timers. This reduces
+// contention on the timers lock.
+func shouldStealTimers(p2 *p) bool {
+	if p2.status != _Prunning {
+		return true
+	}
+	mp := p2.m.ptr()
+	if mp == nil || mp.locks > 0 {
+		return false
+	}
+	gp := mp.curg
+	if gp == nil || gp.atomicstatus != _Grunning || !gp.preempt {
+		return false
+	}
+	return true
 }
 
-func timeSleepUntil() int64 {
-	next := int64(1<<63 - 1)
+// wakeNetPoller wakes up the thread sleeping in the network poller if it
+// isn't going to wake up before the when argument; or it wakes an idle P
+// to service timers and the network poller if there isn't one already.
+func wakeNetPoller(when int64) {
+	if atomic.Load64(&sched.lastpoll) == 0 {
+		// In findrunnable we ensure that when polling the pollUntil
+		// field is either zero or the time to which the current
+		// poll is expected to run. This can have a spurious wakeup
+		// but should never miss a wakeup.
+		pollerPollUntil := int64(atomic.Load64(&sched.pollUntil))
+		if pollerPollUntil == 0 || pollerPollUntil > when {
+			netpollBreak()
+		}
+	} else {
+		// There are no threads in the network poller, try to get
+		// one there so it can handle new timers.
+		wakep()
+	}
+}
 
-	// Determine minimum sleepUntil across all the timer buckets.
-	//
-	// The function can not return a precise answer,
-	// as another timer may pop in as soon as timers have been unlocked.
-	// So lock the timers one by one instead of all at once.
-	for i := range timers {
-		tb := &timers[i]
+// timeSleepUntil returns the time when the next timer should fire,
+// and the P that holds the timer heap that that timer is on.
+// This is only called by sysmon and checkdead.
+func timeSleepUntil() (int64, *p) {
+	next := int64(maxWhen)
+	var pret *p
+
+	// Prevent allp slice changes. This is like retake.
+	lock(&allpLock)
+	for _, pp := range allp {
+		if pp == nil {
+			// This can happen if procresize has grown
+			// allp but not yet created new Ps.
+			continue
+		}
 
-		lock(&tb.lock)
-		if tb.sleeping && tb.sleepUntil < next {
-			next = tb.sleepUntil
+		w := int64(atomic.Load64(&pp.timer0When))
+		if w != 0 && w < next {
+			next = w
+			pret = pp
 		}
-		unlock(&tb.lock)
 	}
+	unlock(&allpLock)
 
-	return next
+	return next, pret
 }
 
 // Heap maintenance algorithms.
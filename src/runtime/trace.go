@@ -7,7 +7,8 @@
 // creation/blocking/unblocking, syscall enter/exit/block, GC-related events,
 // changes of heap size, processor start/stop, etc and writes them to a buffer
 // in a compact form. A precise nanosecond-precision timestamp and a stack
-// trace is captured for most events.
+// trace is captured for most events. The trace is a sequence of
+// generations that can each be parsed on their own, see traceAdvance.
 // See https://golang.org/s/go15trace for more info.
 
 package runtime
@@ -54,7 +55,7 @@ const (
 	traceEvGoInSyscall       = 32 // denotes that goroutine is in syscall when tracing starts [timestamp, goroutine id]
 	traceEvHeapAlloc         = 33 // memstats.heap_live change [timestamp, heap_alloc]
 	traceEvNextGC            = 34 // memstats.next_gc change [timestamp, next_gc]
-	traceEvTimerGoroutine    = 35 // denotes timer goroutine [timer goroutine id]
+	traceEvTimerGoroutine    = 35 // not currently used; previously denoted timer goroutine [timer goroutine id]
 	traceEvFutileWakeup      = 36 // denotes that the previous wakeup of this goroutine was futile [timestamp]
 	traceEvString            = 37 // string dictionary entry [ID, length, string]
 	traceEvGoStartLocal      = 38 // goroutine starts running on the same P as the last event [timestamp, goroutine id]
@@ -64,7 +65,14 @@ const (
 	traceEvGoBlockGC         = 42 // goroutine blocks on GC assist [timestamp, stack]
 	traceEvGCMarkAssistStart = 43 // GC mark assist start [timestamp, stack]
 	traceEvGCMarkAssistDone  = 44 // GC mark assist done [timestamp]
-	traceEvCount             = 45
+	traceEvUserTaskCreate    = 45 // trace.NewTask [timestamp, internal task id, internal parent task id, stack, name string]
+	traceEvUserTaskEnd       = 46 // end of a task [timestamp, internal task id, stack]
+	traceEvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
+	traceEvUserLog           = 48 // trace.Log [timestamp, internal task id, key string id, stack, value string]
+	traceEvCount             = 49
+	// Byte is used but only 6 bits are available for event type.
+	// The remaining 2 bits are used to specify the number of arguments.
+	// That means, the max event type value is 63.
 )
 
 const (
@@ -99,33 +107,39 @@ const (
 
 // trace is global tracing context.
 var trace struct {
-	lock          mutex       // protects the following members
-	lockOwner     *g          // to avoid deadlocks during recursive lock locks
-	enabled       bool        // when set runtime traces events
-	shutdown      bool        // set when we are waiting for trace reader to finish after setting enabled to false
-	headerWritten bool        // whether ReadTrace has emitted trace header
-	footerWritten bool        // whether ReadTrace has emitted trace footer
-	shutdownSema  uint32      // used to wait for ReadTrace completion
-	seqStart      uint64      // sequence number when tracing was started
-	ticksStart    int64       // cputicks when tracing was started
-	ticksEnd      int64       // cputicks when tracing was stopped
-	timeStart     int64       // nanotime when tracing was started
-	timeEnd       int64       // nanotime when tracing was stopped
-	seqGC         uint64      // GC start/done sequencer
-	reading       traceBufPtr // buffer currently handed off to user
-	empty         traceBufPtr // stack of empty buffers
-	fullHead      traceBufPtr // queue of full buffers
-	fullTail      traceBufPtr
-	reader        guintptr        // goroutine that called ReadTrace, or nil
-	stackTab      traceStackTable // maps stack traces to unique ids
+	lock          mutex          // protects the following members
+	lockOwner     *g             // to avoid deadlocks during recursive lock locks
+	enabled       bool           // when set runtime traces events
+	shutdown      bool           // set when we are waiting for trace reader to finish after setting enabled to false
+	gen           uintptr        // current generation, see traceAdvance
+	readGen       uintptr        // generation ReadTrace is returning data for
+	headerWritten bool           // whether ReadTrace has emitted the header of readGen
+	footerWritten bool           // whether ReadTrace has emitted the footer of readGen
+	shutdownSema  uint32         // used to wait for ReadTrace completion
+	advanceSema   uint32         // used by traceAdvance to wait for ReadTrace to finish the previous generation
+	advanceWait   bool           // set when traceAdvance is waiting on advanceSema
+	seqStart      uint64         // sequence number when tracing was started
+	ticksStart    [2]int64       // cputicks when a generation was started, indexed by gen%2
+	ticksEnd      [2]int64       // cputicks when a generation was ended
+	timeStart     [2]int64       // nanotime when a generation was started
+	timeEnd       [2]int64       // nanotime when a generation was ended
+	seqGC         uint64         // GC start/done sequencer
+	reading       traceBufPtr    // buffer currently handed off to user
+	empty         traceBufPtr    // stack of empty buffers
+	fullHead      [2]traceBufPtr // queues of full buffers, indexed by gen%2
+	fullTail      [2]traceBufPtr
+	reader        guintptr           // goroutine that called ReadTrace, or nil
+	stackTab      [2]traceStackTable // maps stack traces to unique ids, indexed by gen%2
 
 	// Dictionary for traceEvString.
 	//
-	// Currently this is used only at trace setup and for
-	// func/file:line info after tracing session, so we assume
-	// single-threaded access.
-	strings   map[string]uint64
-	stringSeq uint64
+	// TODO: central lock to access the map is not ideal.
+	//   option: pre-assign ids to all user annotation region names and tags
+	//   option: per-P cache
+	//   option: sync.Map like data structure
+	stringsLock mutex
+	strings     [2]map[string]uint64 // indexed by gen%2
+	stringSeq   [2]uint64
 
 	// markWorkerLabels maps gcMarkWorkerMode to string ID.
 	markWorkerLabels [len(gcMarkWorkerModeStrings)]uint64
@@ -134,6 +148,9 @@ var trace struct {
 	buf     traceBufPtr // global trace buffer, used when running without a p
 }
 
+// Holding traceAdvanceSema serializes traceAdvance calls.
+var traceAdvanceSema uint32 = 1
+
 // traceBufHeader is per-P tracing buffer.
 type traceBufHeader struct {
 	link      traceBufPtr             // in trace.empty/full
@@ -188,6 +205,23 @@ func StartTrace() error {
 		return errorString("tracing is already enabled")
 	}
 
+	trace.gen++
+	trace.readGen = trace.gen
+	trace.headerWritten = false
+	trace.footerWritten = false
+	traceGenStart()
+
+	unlock(&trace.bufLock)
+
+	startTheWorld()
+	return nil
+}
+
+// traceGenStart starts the generation trace.gen: it emits the state of
+// all goroutines and of the GC, resets the string table and enables
+// tracing. It is called with the world stopped and trace.bufLock held,
+// by StartTrace and traceAdvance.
+func traceGenStart() {
 	// Can't set trace.enabled yet. While the world is stopped, exitsyscall could
 	// already emit a delayed event (see exitTicks in exitsyscall) if we set trace.enabled here.
 	// That would lead to an inconsistent trace:
@@ -201,7 +235,7 @@ func StartTrace() error {
 	// Obtain current stack ID to use in all traceEvGoCreate events below.
 	mp := acquirem()
 	stkBuf := make([]uintptr, traceStackSize)
-	stackID := traceStackID(mp, stkBuf, 2)
+	stackID := traceStackID(mp, stkBuf, 3)
 	releasem(mp)
 
 	for _, gp := range allgs {
@@ -210,7 +244,7 @@ func StartTrace() error {
 			gp.traceseq = 0
 			gp.tracelastp = getg().m.p
 			// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
-			id := trace.stackTab.put([]uintptr{gp.startpc + sys.PCQuantum})
+			id := trace.stackTab[trace.gen%2].put([]uintptr{gp.startpc + sys.PCQuantum})
 			traceEvent(traceEvGoCreate, -1, uint64(gp.goid), uint64(id), stackID)
 		}
 		if status == _Gwaiting {
@@ -227,54 +261,45 @@ func StartTrace() error {
 	}
 	traceProcStart()
 	traceGoStart()
+
+	// The world is stopped and we hold worldsema, so if the GC is
+	// marking, its GCStart has been emitted (if tracing was on) and
+	// its GCDone has not. Let the trace know about the GC in progress.
+	trace.seqGC = 0
+	if gcphase == _GCmark {
+		traceEvent(traceEvGCStart, 0, trace.seqGC)
+		trace.seqGC++
+	}
+
 	// Note: ticksStart needs to be set after we emit traceEvGoInSyscall events.
 	// If we do it the other way around, it is possible that exitsyscall will
 	// query sysexitticks after ticksStart but before traceEvGoInSyscall timestamp.
 	// It will lead to a false conclusion that cputicks is broken.
-	trace.ticksStart = cputicks()
-	trace.timeStart = nanotime()
-	trace.headerWritten = false
-	trace.footerWritten = false
+	trace.ticksStart[trace.gen%2] = cputicks()
+	trace.timeStart[trace.gen%2] = nanotime()
 
 	// string to id mapping
 	//  0 : reserved for an empty string
 	//  remaining: other strings registered by traceString
-	trace.stringSeq = 0
-	trace.strings = make(map[string]uint64)
+	trace.stringSeq[trace.gen%2] = 0
+	trace.strings[trace.gen%2] = make(map[string]uint64)
 
-	trace.seqGC = 0
 	_g_.m.startingtrace = false
 	trace.enabled = true
 
 	// Register runtime goroutine labels.
 	_, pid, bufp := traceAcquireBuffer()
 	for i, label := range gcMarkWorkerModeStrings[:] {
-		trace.markWorkerLabels[i], bufp = traceString(bufp, pid, label)
+		trace.markWorkerLabels[i], bufp = traceString(bufp, pid, trace.gen, label)
 	}
 	traceReleaseBuffer(pid)
-
-	unlock(&trace.bufLock)
-
-	startTheWorld()
-	return nil
 }
 
-// StopTrace stops tracing, if it was previously enabled.
-// StopTrace only returns after all the reads for the trace have completed.
-func StopTrace() {
-	// Stop the world so that we can collect the trace buffers from all p's below,
-	// and also to avoid races with traceEvent.
-	stopTheWorld("stop tracing")
-
-	// See the comment in StartTrace.
-	lock(&trace.bufLock)
-
-	if !trace.enabled {
-		unlock(&trace.bufLock)
-		startTheWorld()
-		return
-	}
-
+// traceGenEnd ends the generation trace.gen: it queues the trace buffers
+// of all p's and records the end time of the generation. It is called
+// with the world stopped and trace.bufLock held, by StopTrace and
+// traceAdvance.
+func traceGenEnd() {
 	traceGoSched()
 
 	// Loop over all allocated Ps because dead Ps may still have
@@ -282,7 +307,7 @@ func StopTrace() {
 	for _, p := range allp[:cap(allp)] {
 		buf := p.tracebuf
 		if buf != 0 {
-			traceFullQueue(buf)
+			traceFullQueue(trace.gen, buf)
 			p.tracebuf = 0
 		}
 	}
@@ -290,19 +315,39 @@ func StopTrace() {
 		buf := trace.buf
 		trace.buf = 0
 		if buf.ptr().pos != 0 {
-			traceFullQueue(buf)
+			traceFullQueue(trace.gen, buf)
 		}
 	}
 
+	i := trace.gen % 2
 	for {
-		trace.ticksEnd = cputicks()
-		trace.timeEnd = nanotime()
+		trace.ticksEnd[i] = cputicks()
+		trace.timeEnd[i] = nanotime()
 		// Windows time can tick only every 15ms, wait for at least one tick.
-		if trace.timeEnd != trace.timeStart {
+		if trace.timeEnd[i] != trace.timeStart[i] {
 			break
 		}
 		osyield()
 	}
+}
+
+// StopTrace stops tracing, if it was previously enabled.
+// StopTrace only returns after all the reads for the trace have completed.
+func StopTrace() {
+	// Stop the world so that we can collect the trace buffers from all p's below,
+	// and also to avoid races with traceEvent.
+	stopTheWorld("stop tracing")
+
+	// See the comment in StartTrace.
+	lock(&trace.bufLock)
+
+	if !trace.enabled {
+		unlock(&trace.bufLock)
+		startTheWorld()
+		return
+	}
+
+	traceGenEnd()
 
 	trace.enabled = false
 	trace.shutdown = true
@@ -327,8 +372,10 @@ func StopTrace() {
 	if trace.buf != 0 {
 		throw("trace: non-empty global trace buffer")
 	}
-	if trace.fullHead != 0 || trace.fullTail != 0 {
-		throw("trace: non-empty full trace buffer")
+	for i := range trace.fullHead {
+		if trace.fullHead[i] != 0 || trace.fullTail[i] != 0 {
+			throw("trace: non-empty full trace buffer")
+		}
 	}
 	if trace.reading != 0 || trace.reader != 0 {
 		throw("trace: reading after shutdown")
@@ -338,17 +385,75 @@ func StopTrace() {
 		trace.empty = buf.ptr().link
 		sysFree(unsafe.Pointer(buf), unsafe.Sizeof(*buf.ptr()), &memstats.other_sys)
 	}
-	trace.strings = nil
+	trace.strings = [2]map[string]uint64{}
 	trace.shutdown = false
 	unlock(&trace.lock)
 }
 
+// traceAdvance ends the current trace generation and starts a new one,
+// returning the number of the new generation, or 0 if tracing is not
+// enabled.
+//
+// The trace is a sequence of generations. Each generation is a
+// complete trace on its own: it starts with a header and the state of
+// all goroutines, and ends with the timer frequency and the stacks and
+// strings its events refer to. Generation N+1 uses the stack table,
+// string table and buffer queue generation N-1 used, so traceAdvance
+// first waits for ReadTrace to return all of generation N-1.
+//
+// Unless traceAdvance is called, a trace has a single generation.
+// runtime/trace's flight recorder calls it periodically, so it can
+// drop old generations.
+func traceAdvance() uintptr {
+	semacquire(&traceAdvanceSema)
+
+	lock(&trace.lock)
+	for trace.enabled && trace.readGen != trace.gen {
+		trace.advanceWait = true
+		unlock(&trace.lock)
+		semacquire(&trace.advanceSema)
+		lock(&trace.lock)
+	}
+	unlock(&trace.lock)
+
+	stopTheWorld("trace advance")
+
+	// See the comment in StartTrace.
+	lock(&trace.bufLock)
+
+	if !trace.enabled {
+		unlock(&trace.bufLock)
+		startTheWorld()
+		semrelease(&traceAdvanceSema)
+		return 0
+	}
+
+	traceGenEnd()
+	trace.enabled = false
+	trace.gen++
+	traceGenStart()
+	gen := trace.gen
+
+	unlock(&trace.bufLock)
+
+	startTheWorld()
+	semrelease(&traceAdvanceSema)
+	return gen
+}
+
 // ReadTrace returns the next chunk of binary tracing data, blocking until data
 // is available. If tracing is turned off and all the data accumulated while it
 // was on has been returned, ReadTrace returns nil. The caller must copy the
 // returned data before calling ReadTrace again.
 // ReadTrace must be called from one goroutine at a time.
 func ReadTrace() []byte {
+	data, _ := readTrace()
+	return data
+}
+
+// readTrace is ReadTrace, but it also returns the generation the
+// data belongs to.
+func readTrace() ([]byte, uintptr) {
 	// This function may need to lock trace.lock recursively
 	// (goparkunlock -> traceGoPark -> traceEvent -> traceFlush).
 	// To allow this we use trace.lockOwner.
@@ -365,7 +470,7 @@ func ReadTrace() []byte {
 		trace.lockOwner = nil
 		unlock(&trace.lock)
 		println("runtime: ReadTrace called from multiple goroutines simultaneously")
-		return nil
+		return nil, 0
 	}
 	// Recycle the old buffer.
 	if buf := trace.reading; buf != 0 {
@@ -376,45 +481,58 @@ func ReadTrace() []byte {
 	// Write trace header.
 	if !trace.headerWritten {
 		trace.headerWritten = true
+		gen := trace.readGen
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return []byte("go 1.10 trace\x00\x00\x00")
+		return []byte("go 1.11 trace\x00\x00\x00"), gen
 	}
 	// Wait for new data.
-	if trace.fullHead == 0 && !trace.shutdown {
+	if !traceReaderAvailable() {
 		trace.reader.set(getg())
 		goparkunlock(&trace.lock, "trace reader (blocked)", traceEvGoBlock, 2)
 		lock(&trace.lock)
 	}
+	gen := trace.readGen
 	// Write a buffer.
-	if trace.fullHead != 0 {
-		buf := traceFullDequeue()
+	if trace.fullHead[gen%2] != 0 {
+		buf := traceFullDequeue(gen)
 		trace.reading = buf
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return buf.ptr().arr[:buf.ptr().pos]
+		return buf.ptr().arr[:buf.ptr().pos], gen
 	}
 	// Write footer with timer frequency.
-	if !trace.footerWritten {
-		trace.footerWritten = true
-		// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
-		freq := float64(trace.ticksEnd-trace.ticksStart) * 1e9 / float64(trace.timeEnd-trace.timeStart) / traceTickDiv
-		trace.lockOwner = nil
-		unlock(&trace.lock)
-		var data []byte
-		data = append(data, traceEvFrequency|0<<traceArgCountShift)
-		data = traceAppend(data, uint64(freq))
-		for i := range timers {
-			tb := &timers[i]
-			if tb.gp != nil {
-				data = append(data, traceEvTimerGoroutine|0<<traceArgCountShift)
-				data = traceAppend(data, uint64(tb.gp.goid))
+	if gen != trace.gen || trace.shutdown {
+		if !trace.footerWritten {
+			trace.footerWritten = true
+			// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
+			i := gen % 2
+			freq := float64(trace.ticksEnd[i]-trace.ticksStart[i]) * 1e9 / float64(trace.timeEnd[i]-trace.timeStart[i]) / traceTickDiv
+			trace.lockOwner = nil
+			unlock(&trace.lock)
+			var data []byte
+			data = append(data, traceEvFrequency|0<<traceArgCountShift)
+			data = traceAppend(data, uint64(freq))
+			// This will emit a bunch of full buffers, we will pick them up
+			// on the next iteration.
+			trace.stackTab[i].dump(gen)
+			return data, gen
+		}
+		// The generation is complete, move on to the next one.
+		if gen != trace.gen {
+			trace.readGen++
+			trace.headerWritten = true
+			trace.footerWritten = false
+			gen = trace.readGen
+			wake := trace.advanceWait
+			trace.advanceWait = false
+			trace.lockOwner = nil
+			unlock(&trace.lock)
+			if wake {
+				semrelease(&trace.advanceSema)
 			}
+			return []byte("go 1.11 trace\x00\x00\x00"), gen
 		}
-		// This will emit a bunch of full buffers, we will pick them up
-		// on the next iteration.
-		trace.stackTab.dump()
-		return data
 	}
 	// Done.
 	if trace.shutdown {
@@ -428,22 +546,29 @@ func ReadTrace() []byte {
 		}
 		// trace.enabled is already reset, so can call traceable functions.
 		semrelease(&trace.shutdownSema)
-		return nil
+		return nil, 0
 	}
 	// Also bad, but see the comment above.
 	trace.lockOwner = nil
 	unlock(&trace.lock)
 	println("runtime: spurious wakeup of trace reader")
-	return nil
+	return nil, 0
+}
+
+// traceReaderAvailable reports whether ReadTrace has something to
+// return: a full buffer, or the end of the generation it is reading.
+// trace.lock must be held or the result is advisory.
+func traceReaderAvailable() bool {
+	return trace.fullHead[trace.readGen%2] != 0 || trace.readGen != trace.gen || trace.shutdown
 }
 
 // traceReader returns the trace reader that should be woken up, if any.
 func traceReader() *g {
-	if trace.reader == 0 || (trace.fullHead == 0 && !trace.shutdown) {
+	if trace.reader == 0 || !traceReaderAvailable() {
 		return nil
 	}
 	lock(&trace.lock)
-	if trace.reader == 0 || (trace.fullHead == 0 && !trace.shutdown) {
+	if trace.reader == 0 || !traceReaderAvailable() {
 		unlock(&trace.lock)
 		return nil
 	}
@@ -461,30 +586,32 @@ func traceProcFree(pp *p) {
 		return
 	}
 	lock(&trace.lock)
-	traceFullQueue(buf)
+	traceFullQueue(trace.gen, buf)
 	unlock(&trace.lock)
 }
 
-// traceFullQueue queues buf into queue of full buffers.
-func traceFullQueue(buf traceBufPtr) {
+// traceFullQueue queues buf into the queue of full buffers of generation gen.
+func traceFullQueue(gen uintptr, buf traceBufPtr) {
+	i := gen % 2
 	buf.ptr().link = 0
-	if trace.fullHead == 0 {
-		trace.fullHead = buf
+	if trace.fullHead[i] == 0 {
+		trace.fullHead[i] = buf
 	} else {
-		trace.fullTail.ptr().link = buf
+		trace.fullTail[i].ptr().link = buf
 	}
-	trace.fullTail = buf
+	trace.fullTail[i] = buf
 }
 
-// traceFullDequeue dequeues from queue of full buffers.
-func traceFullDequeue() traceBufPtr {
-	buf := trace.fullHead
+// traceFullDequeue dequeues from the queue of full buffers of generation gen.
+func traceFullDequeue(gen uintptr) traceBufPtr {
+	i := gen % 2
+	buf := trace.fullHead[i]
 	if buf == 0 {
 		return 0
 	}
-	trace.fullHead = buf.ptr().link
-	if trace.fullHead == 0 {
-		trace.fullTail = 0
+	trace.fullHead[i] = buf.ptr().link
+	if trace.fullHead[i] == 0 {
+		trace.fullTail[i] = 0
 	}
 	buf.ptr().link = 0
 	return buf
@@ -510,10 +637,25 @@ func traceEvent(ev byte, skip int, args ...uint64) {
 		traceReleaseBuffer(pid)
 		return
 	}
+	if skip > 0 {
+		if getg() == mp.curg {
+			skip++ // +1 because stack is captured in traceEventLocked.
+		}
+	}
+	traceEventLocked(0, mp, pid, bufp, ev, skip, args...)
+	traceReleaseBuffer(pid)
+}
+
+// traceEventLocked writes a single event to the buffer bufp, which
+// the caller has acquired with traceAcquireBuffer. extraBytes is the
+// number of bytes the caller is going to write to the buffer right
+// after the event; they are reserved along with the event itself.
+func traceEventLocked(extraBytes int, mp *m, pid int32, bufp *traceBufPtr, ev byte, skip int, args ...uint64) {
 	buf := (*bufp).ptr()
-	const maxSize = 2 + 5*traceBytesPerNumber // event type, length, sequence, timestamp, stack id and two add params
+	// TODO: test on non-zero extraBytes param.
+	maxSize := 2 + 5*traceBytesPerNumber + extraBytes // event type, length, sequence, timestamp, stack id and two add params
 	if buf == nil || len(buf.arr)-buf.pos < maxSize {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+		buf = traceFlush(traceBufPtrOf(buf), pid, trace.gen).ptr()
 		(*bufp).set(buf)
 	}
 
@@ -554,7 +696,6 @@ func traceEvent(ev byte, skip int, args ...uint64) {
 		// Fill in actual length.
 		*lenp = byte(evSize - 2)
 	}
-	traceReleaseBuffer(pid)
 }
 
 func traceStackID(mp *m, buf []uintptr, skip int) uint64 {
@@ -573,7 +714,7 @@ func traceStackID(mp *m, buf []uintptr, skip int) uint64 {
 	if nstk > 0 && gp.goid == 1 {
 		nstk-- // skip runtime.main
 	}
-	id := trace.stackTab.put(buf[:nstk])
+	id := trace.stackTab[trace.gen%2].put(buf[:nstk])
 	return uint64(id)
 }
 
@@ -595,15 +736,16 @@ func traceReleaseBuffer(pid int32) {
 	releasem(getg().m)
 }
 
-// traceFlush puts buf onto stack of full buffers and returns an empty buffer.
-func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
+// traceFlush puts buf onto the queue of full buffers of generation gen
+// and returns an empty buffer.
+func traceFlush(buf traceBufPtr, pid int32, gen uintptr) traceBufPtr {
 	owner := trace.lockOwner
 	dolock := owner == nil || owner != getg().m.curg
 	if dolock {
 		lock(&trace.lock)
 	}
 	if buf != 0 {
-		traceFullQueue(buf)
+		traceFullQueue(gen, buf)
 	}
 	if trace.empty != 0 {
 		buf = trace.empty
@@ -631,18 +773,38 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	return buf
 }
 
-// traceString adds a string to the trace.strings and returns the id.
-func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr) {
+// traceString adds a string to the string table of generation gen and
+// returns the id.
+func traceString(bufp *traceBufPtr, pid int32, gen uintptr, s string) (uint64, *traceBufPtr) {
 	if s == "" {
 		return 0, bufp
 	}
-	if id, ok := trace.strings[s]; ok {
+
+	lock(&trace.stringsLock)
+	if raceenabled {
+		// raceacquire is necessary because the map access
+		// below is race annotated.
+		raceacquire(unsafe.Pointer(&trace.stringsLock))
+	}
+
+	strings := trace.strings[gen%2]
+	if id, ok := strings[s]; ok {
+		if raceenabled {
+			racerelease(unsafe.Pointer(&trace.stringsLock))
+		}
+		unlock(&trace.stringsLock)
+
 		return id, bufp
 	}
 
-	trace.stringSeq++
-	id := trace.stringSeq
-	trace.strings[s] = id
+	trace.stringSeq[gen%2]++
+	id := trace.stringSeq[gen%2]
+	strings[s] = id
+
+	if raceenabled {
+		racerelease(unsafe.Pointer(&trace.stringsLock))
+	}
+	unlock(&trace.stringsLock)
 
 	// memory allocation in above may trigger tracing and
 	// cause *bufp changes. Following code now works with *bufp,
@@ -652,7 +814,7 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 	buf := (*bufp).ptr()
 	size := 1 + 2*traceBytesPerNumber + len(s)
 	if buf == nil || len(buf.arr)-buf.pos < size {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+		buf = traceFlush(traceBufPtrOf(buf), pid, gen).ptr()
 		(*bufp).set(buf)
 	}
 	buf.byte(traceEvString)
@@ -787,11 +949,11 @@ func allFrames(pcs []uintptr) []Frame {
 	}
 }
 
-// dump writes all previously cached stacks to trace buffers,
-// releases all memory and resets state.
-func (tab *traceStackTable) dump() {
+// dump writes all previously cached stacks to trace buffers of
+// generation gen, releases all memory and resets state.
+func (tab *traceStackTable) dump(gen uintptr) {
 	var tmp [(2 + 4*traceStackSize) * traceBytesPerNumber]byte
-	bufp := traceFlush(0, 0)
+	bufp := traceFlush(0, 0, gen)
 	for _, stk := range tab.tab {
 		stk := stk.ptr()
 		for ; stk != nil; stk = stk.link.ptr() {
@@ -801,7 +963,7 @@ func (tab *traceStackTable) dump() {
 			tmpbuf = traceAppend(tmpbuf, uint64(len(frames)))
 			for _, f := range frames {
 				var frame traceFrame
-				frame, bufp = traceFrameForPC(bufp, 0, f)
+				frame, bufp = traceFrameForPC(bufp, 0, gen, f)
 				tmpbuf = traceAppend(tmpbuf, uint64(f.PC))
 				tmpbuf = traceAppend(tmpbuf, uint64(frame.funcID))
 				tmpbuf = traceAppend(tmpbuf, uint64(frame.fileID))
@@ -810,7 +972,7 @@ func (tab *traceStackTable) dump() {
 			// Now copy to the buffer.
 			size := 1 + traceBytesPerNumber + len(tmpbuf)
 			if buf := bufp.ptr(); len(buf.arr)-buf.pos < size {
-				bufp = traceFlush(bufp, 0)
+				bufp = traceFlush(bufp, 0, gen)
 			}
 			buf := bufp.ptr()
 			buf.byte(traceEvStack | 3<<traceArgCountShift)
@@ -820,7 +982,7 @@ func (tab *traceStackTable) dump() {
 	}
 
 	lock(&trace.lock)
-	traceFullQueue(bufp)
+	traceFullQueue(gen, bufp)
 	unlock(&trace.lock)
 
 	tab.mem.drop()
@@ -835,7 +997,7 @@ type traceFrame struct {
 
 // traceFrameForPC records the frame information.
 // It may allocate memory.
-func traceFrameForPC(buf traceBufPtr, pid int32, f Frame) (traceFrame, traceBufPtr) {
+func traceFrameForPC(buf traceBufPtr, pid int32, gen uintptr, f Frame) (traceFrame, traceBufPtr) {
 	bufp := &buf
 	var frame traceFrame
 
@@ -844,13 +1006,13 @@ func traceFrameForPC(buf traceBufPtr, pid int32, f Frame) (traceFrame, traceBufP
 	if len(fn) > maxLen {
 		fn = fn[len(fn)-maxLen:]
 	}
-	frame.funcID, bufp = traceString(bufp, pid, fn)
+	frame.funcID, bufp = traceString(bufp, pid, gen, fn)
 	frame.line = uint64(f.Line)
 	file := f.File
 	if len(file) > maxLen {
 		file = file[len(file)-maxLen:]
 	}
-	frame.fileID, bufp = traceString(bufp, pid, file)
+	frame.fileID, bufp = traceString(bufp, pid, gen, file)
 	return frame, (*bufp)
 }
 
@@ -998,7 +1160,7 @@ func traceGoCreate(newg *g, pc uintptr) {
 	newg.traceseq = 0
 	newg.tracelastp = getg().m.p
 	// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
-	id := trace.stackTab.put([]uintptr{pc + sys.PCQuantum})
+	id := trace.stackTab[trace.gen%2].put([]uintptr{pc + sys.PCQuantum})
 	traceEvent(traceEvGoCreate, 2, uint64(newg.goid), uint64(id))
 }
 
@@ -1055,12 +1217,12 @@ func traceGoSysCall() {
 }
 
 func traceGoSysExit(ts int64) {
-	if ts != 0 && ts < trace.ticksStart {
+	if ts != 0 && ts < trace.ticksStart[trace.gen%2] {
 		// There is a race between the code that initializes sysexitticks
 		// (in exitsyscall, which runs without a P, and therefore is not
 		// stopped with the rest of the world) and the code that initializes
-		// a new trace. The recorded sysexitticks must therefore be treated
-		// as "best effort". If they are valid for this trace, then great,
+		// a new trace generation. The recorded sysexitticks must therefore be treated
+		// as "best effort". If they are valid for this generation, then great,
 		// use them for greater accuracy. But if they're not valid for this
 		// trace, assume that the trace was started after the actual syscall
 		// exit (but before we actually managed to start the goroutine,
@@ -1096,3 +1258,89 @@ func traceNextGC() {
 		traceEvent(traceEvNextGC, -1, memstats.next_gc)
 	}
 }
+
+// To access runtime functions from runtime/trace.
+// See runtime/trace/annotation.go and runtime/trace/flightrecorder.go
+
+//go:linkname trace_readTrace runtime/trace.readTrace
+func trace_readTrace() ([]byte, uint64) {
+	data, gen := readTrace()
+	return data, uint64(gen)
+}
+
+//go:linkname trace_advance runtime/trace.advance
+func trace_advance() uint64 {
+	return uint64(traceAdvance())
+}
+
+//go:linkname trace_userTaskCreate runtime/trace.userTaskCreate
+func trace_userTaskCreate(id, parentID uint64, taskType string) {
+	if !trace.enabled {
+		return
+	}
+
+	// Same as in traceEvent.
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+
+	typeStringID, bufp := traceString(bufp, pid, trace.gen, taskType)
+	traceEventLocked(0, mp, pid, bufp, traceEvUserTaskCreate, 3, id, parentID, typeStringID)
+	traceReleaseBuffer(pid)
+}
+
+//go:linkname trace_userTaskEnd runtime/trace.userTaskEnd
+func trace_userTaskEnd(id uint64) {
+	traceEvent(traceEvUserTaskEnd, 2, id)
+}
+
+//go:linkname trace_userRegion runtime/trace.userRegion
+func trace_userRegion(id, mode uint64, name string) {
+	if !trace.enabled {
+		return
+	}
+
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+
+	nameStringID, bufp := traceString(bufp, pid, trace.gen, name)
+	traceEventLocked(0, mp, pid, bufp, traceEvUserRegion, 3, id, mode, nameStringID)
+	traceReleaseBuffer(pid)
+}
+
+//go:linkname trace_userLog runtime/trace.userLog
+func trace_userLog(id uint64, category, message string) {
+	if !trace.enabled {
+		return
+	}
+
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+
+	categoryID, bufp := traceString(bufp, pid, trace.gen, category)
+
+	extraSpace := traceBytesPerNumber + len(message) // extraSpace for the value string
+	traceEventLocked(extraSpace, mp, pid, bufp, traceEvUserLog, 3, id, categoryID)
+	// traceEventLocked reserved extra space for val and len(val)
+	// in buf, so buf now has room for the following.
+	buf := (*bufp).ptr()
+
+	// double-check the message and its length can fit.
+	// Otherwise, truncate the message.
+	slen := len(message)
+	if room := len(buf.arr) - buf.pos; room < slen+traceBytesPerNumber {
+		slen = room - traceBytesPerNumber
+	}
+	buf.varint(uint64(slen))
+	buf.pos += copy(buf.arr[buf.pos:], message[:slen])
+
+	traceReleaseBuffer(pid)
+}
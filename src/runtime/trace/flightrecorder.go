@@ -0,0 +1,294 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FlightRecorder keeps the most recent part of the execution trace in
+// memory, so that it can be written out after something interesting
+// has happened, for example when a request turns out to be slow.
+//
+// The runtime splits the trace into generations that can each be
+// parsed on their own, and starts a new generation about every second.
+// The flight recorder keeps the generations that cover the last
+// MinAge of execution, as long as they fit in MaxBytes.
+//
+// Only one of the flight recorder and Start can trace the program at
+// a time.
+type FlightRecorder struct {
+	cfg FlightRecorderConfig
+
+	mu      sync.Mutex
+	cond    sync.Cond      // signaled when a generation is complete
+	gens    []*flightGen   // complete generations, oldest first
+	size    uint64         // size of gens in bytes
+	cur     *flightGen     // generation being read
+	enabled bool           // between Start and Stop
+	writing bool           // a WriteTo is in progress
+	done    bool           // the trace reader has returned
+	stop    chan struct{}  // closed by Stop to end the advancer
+	advance chan struct{}  // asks the advancer for a new generation
+	wg      sync.WaitGroup // reader and advancer
+}
+
+// FlightRecorderConfig configures a FlightRecorder.
+type FlightRecorderConfig struct {
+	// MinAge is the lower bound on the age of the events kept in
+	// the recorder. Generations are dropped only once the newer
+	// ones cover at least MinAge.
+	//
+	// If zero, a default of 10 seconds is used.
+	MinAge time.Duration
+
+	// MaxBytes is the upper bound on the size of the trace kept in
+	// the recorder. MaxBytes takes precedence over MinAge, but the
+	// most recent complete generation is always kept.
+	//
+	// If zero, a default of 10 MiB is used.
+	MaxBytes uint64
+}
+
+// flightGen is a trace generation held by the flight recorder.
+type flightGen struct {
+	gen    uint64
+	start  time.Time
+	chunks [][]byte
+	size   uint64
+}
+
+const (
+	// flightPeriod is how often the flight recorder starts a new
+	// trace generation, unless MinAge is shorter.
+	flightPeriod = time.Second
+	// The flight recorder also starts a new generation once the
+	// current one is a 1/flightSizeDiv fraction of MaxBytes.
+	flightSizeDiv = 4
+)
+
+// NewFlightRecorder returns a new flight recorder with the given
+// configuration. The recorder must be started with Start.
+func NewFlightRecorder(cfg FlightRecorderConfig) *FlightRecorder {
+	if cfg.MinAge <= 0 {
+		cfg.MinAge = 10 * time.Second
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = 10 << 20
+	}
+	r := &FlightRecorder{cfg: cfg}
+	r.cond.L = &r.mu
+	return r
+}
+
+// Start starts recording the execution trace into the flight recorder.
+// Start returns an error if the recorder or tracing is already enabled.
+func (r *FlightRecorder) Start() error {
+	tracing.Lock()
+	defer tracing.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enabled {
+		return errors.New("flight recorder already enabled")
+	}
+	if err := runtime.StartTrace(); err != nil {
+		return err
+	}
+	r.gens, r.size, r.cur = nil, 0, nil
+	r.enabled, r.done = true, false
+	r.stop = make(chan struct{})
+	r.advance = make(chan struct{}, 1)
+	r.wg.Add(2)
+	go r.read()
+	go r.advancer()
+	tracing.recorder = r
+	atomic.StoreInt32(&tracing.enabled, 1)
+	return nil
+}
+
+// Stop stops the flight recorder and drops the trace it holds. It
+// waits for a concurrent WriteTo to finish.
+func (r *FlightRecorder) Stop() {
+	tracing.Lock()
+	defer tracing.Unlock()
+
+	r.mu.Lock()
+	if !r.enabled {
+		r.mu.Unlock()
+		return
+	}
+	r.enabled = false
+	close(r.stop)
+	r.mu.Unlock()
+
+	atomic.StoreInt32(&tracing.enabled, 0)
+	tracing.recorder = nil
+	runtime.StopTrace()
+	r.wg.Wait()
+
+	r.mu.Lock()
+	for r.writing {
+		r.cond.Wait()
+	}
+	r.gens, r.size, r.cur = nil, 0, nil
+	r.mu.Unlock()
+}
+
+// Enabled reports whether the flight recorder is recording.
+func (r *FlightRecorder) Enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enabled
+}
+
+// WriteTo ends the current trace generation and writes the trace held
+// by the flight recorder to w. The result can be parsed like any other
+// execution trace, for example with `go tool trace`.
+//
+// Only one WriteTo may run at a time. WriteTo returns an error if the
+// flight recorder is not enabled, if another WriteTo is in progress,
+// or if writing to w fails.
+func (r *FlightRecorder) WriteTo(w io.Writer) (n int64, err error) {
+	r.mu.Lock()
+	if !r.enabled {
+		r.mu.Unlock()
+		return 0, errors.New("flight recorder not enabled")
+	}
+	if r.writing {
+		r.mu.Unlock()
+		return 0, errors.New("concurrent call to WriteTo")
+	}
+	r.writing = true
+	r.mu.Unlock()
+
+	// Complete the current generation, so that the snapshot includes
+	// everything up to now, and wait for the reader to collect it.
+	gen := advance()
+	r.mu.Lock()
+	for gen != 0 && !r.done && (r.cur == nil || r.cur.gen < gen) {
+		r.cond.Wait()
+	}
+	gens := append([]*flightGen(nil), r.gens...)
+	r.mu.Unlock()
+
+	for _, g := range gens {
+		for _, c := range g.chunks {
+			var m int
+			m, err = w.Write(c)
+			n += int64(m)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	r.mu.Lock()
+	r.writing = false
+	r.cond.Broadcast()
+	r.mu.Unlock()
+	return n, err
+}
+
+// read collects the trace data from the runtime, one generation at a
+// time.
+func (r *FlightRecorder) read() {
+	defer r.wg.Done()
+	for {
+		data, gen := readTrace()
+		r.mu.Lock()
+		if data == nil {
+			r.finish()
+			r.done = true
+			r.cond.Broadcast()
+			r.mu.Unlock()
+			return
+		}
+		if r.cur != nil && r.cur.gen != gen {
+			r.finish()
+			r.cond.Broadcast()
+		}
+		if r.cur == nil {
+			r.cur = &flightGen{gen: gen, start: time.Now()}
+		}
+		// The runtime reuses the buffer once we call readTrace again.
+		r.cur.chunks = append(r.cur.chunks, append([]byte(nil), data...))
+		r.cur.size += uint64(len(data))
+		full := r.cur.size >= r.cfg.MaxBytes/flightSizeDiv
+		r.mu.Unlock()
+
+		if full {
+			select {
+			case r.advance <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// finish adds the current generation to the complete ones and drops
+// the generations that are no longer needed. r.mu must be held.
+func (r *FlightRecorder) finish() {
+	if r.cur == nil {
+		return
+	}
+	r.gens = append(r.gens, r.cur)
+	r.size += r.cur.size
+	r.cur = nil
+
+	// Drop the oldest generation while the remaining ones are too
+	// large, or still cover MinAge without it. The data of a
+	// generation is at most as old as its start.
+	now := time.Now()
+	for len(r.gens) > 1 {
+		if r.size <= r.cfg.MaxBytes && now.Sub(r.gens[1].start) < r.cfg.MinAge {
+			break
+		}
+		r.size -= r.gens[0].size
+		r.gens[0] = nil
+		r.gens = r.gens[1:]
+	}
+}
+
+// advancer starts a new trace generation every flightPeriod, or
+// sooner when the reader asks for it.
+func (r *FlightRecorder) advancer() {
+	defer r.wg.Done()
+	period := flightPeriod
+	if r.cfg.MinAge < period {
+		period = r.cfg.MinAge
+	}
+	t := time.NewTicker(period)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-t.C:
+		case <-r.advance:
+		}
+		advance()
+	}
+}
+
+//
+// Function bodies are defined in runtime/trace.go
+//
+
+// readTrace is runtime.ReadTrace, but it also returns the trace
+// generation the data belongs to.
+func readTrace() ([]byte, uint64)
+
+// advance ends the current trace generation and starts a new one. It
+// returns the new generation, or 0 if tracing is not enabled.
+func advance() uint64
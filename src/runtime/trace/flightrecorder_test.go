@@ -0,0 +1,149 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"internal/trace"
+	"io/ioutil"
+	. "runtime/trace"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: 50 * time.Millisecond})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer fr.Stop()
+
+	if err := fr.Start(); err == nil {
+		t.Errorf("Start of a started flight recorder succeeded")
+	}
+	if err := Start(ioutil.Discard); err == nil {
+		Stop()
+		t.Errorf("Start while flight recording succeeded")
+	}
+	if !fr.Enabled() || !IsEnabled() {
+		t.Errorf("flight recorder is not enabled after Start")
+	}
+
+	// A goroutine that stays blocked across generations.
+	block := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		<-block
+		done <- true
+	}()
+
+	ctx := context.Background()
+	Log(ctx, "flight", "old")
+	var buf bytes.Buffer
+	if _, err := fr.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if logs := flightLogs(t, buf.Bytes()); !logs["old"] {
+		t.Errorf("first snapshot has logs %v, want old", logs)
+	}
+
+	// Keep the program busy over many generations.
+	var wg sync.WaitGroup
+	stop := make(chan bool)
+	ping := make(chan int)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case ping <- i:
+			case <-stop:
+				close(ping)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range ping {
+		}
+	}()
+	time.Sleep(500 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	close(block)
+	<-done
+	Log(ctx, "flight", "new")
+	buf.Reset()
+	if _, err := fr.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if logs := flightLogs(t, buf.Bytes()); !logs["new"] || logs["old"] {
+		t.Errorf("second snapshot has logs %v, want new but not old", logs)
+	}
+
+	fr.Stop()
+	if fr.Enabled() || IsEnabled() {
+		t.Errorf("flight recorder is enabled after Stop")
+	}
+	if _, err := fr.WriteTo(&buf); err == nil {
+		t.Errorf("WriteTo of a stopped flight recorder succeeded")
+	}
+
+	// Regular tracing works again.
+	if err := Start(ioutil.Discard); err != nil {
+		t.Fatalf("failed to start tracing after the flight recorder: %v", err)
+	}
+	Stop()
+}
+
+func TestFlightRecorderMaxBytes(t *testing.T) {
+	const maxBytes = 256 << 10
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: time.Hour, MaxBytes: maxBytes})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer fr.Stop()
+
+	ctx := context.Background()
+	deadline := time.Now().Add(time.Second)
+	var buf bytes.Buffer
+	for i := 0; time.Now().Before(deadline); i++ {
+		Log(ctx, "flight", "some rather long message to fill the trace buffers quickly")
+		if i%1000 == 0 {
+			buf.Reset()
+			if _, err := fr.WriteTo(&buf); err != nil {
+				t.Fatalf("WriteTo failed: %v", err)
+			}
+		}
+	}
+	if buf.Len() > 2*maxBytes {
+		t.Errorf("snapshot has %d bytes, want at most about %d", buf.Len(), maxBytes)
+	}
+	flightLogs(t, buf.Bytes())
+}
+
+// flightLogs parses a flight recorder snapshot and returns the values
+// of its "flight" logs.
+func flightLogs(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+	res, err := trace.Parse(bytes.NewReader(data), "")
+	if err == trace.ErrTimeOrder {
+		t.Skipf("skipping trace: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("failed to parse snapshot: %v", err)
+	}
+	logs := make(map[string]bool)
+	for _, ev := range res.Events {
+		if ev.Type == trace.EvUserLog && ev.SArgs[0] == "flight" {
+			logs[ev.SArgs[1]] = true
+		}
+	}
+	return logs
+}
@@ -139,6 +139,7 @@ func TestTraceSymbolize(t *testing.T) {
 	}
 	want := []eventDesc{
 		{trace.EvGCStart, []frame{
+			{"runtime.gcCycle", 0},
 			{"runtime.GC", 0},
 			{"runtime/trace_test.TestTraceSymbolize", 107},
 			{"testing.tRunner", 0},
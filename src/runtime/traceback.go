@@ -143,6 +143,7 @@ func gentraceback(pc0, sp0, lr0 uintptr, gp *g, skip int, pcbuf *uintptr, max in
 		frame.lr = lr0
 	}
 	waspanic := false
+	wasinjected := false
 	cgoCtxt := gp.cgoCtxt
 	printing := pcbuf == nil && callback == nil
 	_defer := gp._defer
@@ -323,7 +324,7 @@ func gentraceback(pc0, sp0, lr0 uintptr, gp *g, skip int, pcbuf *uintptr, max in
 			} else {
 				// backup to CALL instruction to read inlining info (same logic as below)
 				tracepc := frame.pc
-				if (n > 0 || flags&_TraceTrap == 0) && frame.pc > f.entry && !waspanic {
+				if (n > 0 || flags&_TraceTrap == 0) && frame.pc > f.entry && !wasinjected {
 					tracepc--
 				}
 				inldata := funcdata(f, _FUNCDATA_InlTree)
@@ -377,7 +378,7 @@ func gentraceback(pc0, sp0, lr0 uintptr, gp *g, skip int, pcbuf *uintptr, max in
 				//		/home/rsc/go/src/runtime/x.go:23 +0xf
 				//
 				tracepc := frame.pc // back up to CALL instruction for funcline.
-				if (n > 0 || flags&_TraceTrap == 0) && frame.pc > f.entry && !waspanic {
+				if (n > 0 || flags&_TraceTrap == 0) && frame.pc > f.entry && !wasinjected {
 					tracepc--
 				}
 				file, line := funcline(f, tracepc)
@@ -439,6 +440,10 @@ func gentraceback(pc0, sp0, lr0 uintptr, gp *g, skip int, pcbuf *uintptr, max in
 		}
 
 		waspanic = f.funcID == funcID_sigpanic
+		// The runtime injects calls to sigpanic and asyncPreempt
+		// with the exact PC of the interrupted instruction as the
+		// return PC.
+		wasinjected = waspanic || f.funcID == funcID_asyncPreempt
 
 		// Do not unwind past the bottom of the stack.
 		if !flr.valid() {
@@ -454,8 +459,8 @@ func gentraceback(pc0, sp0, lr0 uintptr, gp *g, skip int, pcbuf *uintptr, max in
 		frame.argmap = nil
 
 		// On link register architectures, sighandler saves the LR on stack
-		// before faking a call to sigpanic.
-		if usesLR && waspanic {
+		// before faking a call to sigpanic or asyncPreempt.
+		if usesLR && wasinjected {
 			x := *(*uintptr)(unsafe.Pointer(frame.sp))
 			frame.sp += sys.MinFrameSize
 			if GOARCH == "arm64" {
@@ -800,12 +805,18 @@ func goroutineheader(gp *g) {
 	if isScan {
 		print(" (scan)")
 	}
+	if gp.leaked {
+		print(" (leaked)")
+	}
 	if waitfor >= 1 {
 		print(", ", waitfor, " minutes")
 	}
 	if gp.lockedm != 0 {
 		print(", locked to thread")
 	}
+	if debug.tracebacklabels > 0 || getg().m.tracebacklabels {
+		printProfLabels(gp)
+	}
 	print("]:\n")
 }
 
@@ -915,8 +926,10 @@ func isSystemGoroutine(gp *g) bool {
 	}
 	return f.funcID == funcID_runfinq && !fingRunning ||
 		f.funcID == funcID_bgsweep ||
+		f.funcID == funcID_bgscavenge ||
 		f.funcID == funcID_forcegchelper ||
-		f.funcID == funcID_timerproc ||
+		f.funcID == funcID_gomaxprocshelper ||
+		f.funcID == funcID_uniquemapcleanup ||
 		f.funcID == funcID_gcBgMarkWorker
 }
 
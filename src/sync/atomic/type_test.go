@@ -0,0 +1,259 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package atomic_test
+
+import (
+	"runtime"
+	. "sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+func TestBool(t *testing.T) {
+	var x struct {
+		before uint32
+		b      Bool
+		after  uint32
+	}
+	x.before = magic32
+	x.after = magic32
+	if x.b.Load() {
+		t.Fatalf("zero Bool is true")
+	}
+	x.b.Store(true)
+	if !x.b.Load() {
+		t.Fatalf("Store(true): Load() = false")
+	}
+	if old := x.b.Swap(false); !old || x.b.Load() {
+		t.Fatalf("Swap(false) = %v, Load() = %v; want true, false", old, x.b.Load())
+	}
+	if x.b.CompareAndSwap(true, true) {
+		t.Fatalf("CompareAndSwap(true, true) succeeded on false")
+	}
+	if !x.b.CompareAndSwap(false, true) || !x.b.Load() {
+		t.Fatalf("CompareAndSwap(false, true) failed on false")
+	}
+	if x.before != magic32 || x.after != magic32 {
+		t.Fatalf("wrong magic: %#x _ %#x != %#x _ %#x", x.before, x.after, magic32, magic32)
+	}
+}
+
+func TestInt32Methods(t *testing.T) {
+	var x struct {
+		before int32
+		i      Int32
+		after  int32
+	}
+	x.before = magic32
+	x.after = magic32
+	var j int32
+	for delta := int32(1); delta+delta > delta; delta += delta {
+		k := x.i.Add(delta)
+		j += delta
+		if x.i.Load() != j || k != j {
+			t.Fatalf("delta=%d i=%d j=%d k=%d", delta, x.i.Load(), j, k)
+		}
+	}
+	if old := x.i.Swap(magic32); old != j || x.i.Load() != magic32 {
+		t.Fatalf("Swap(%#x) = %#x, Load() = %#x", magic32, old, x.i.Load())
+	}
+	if x.i.CompareAndSwap(0, 1) || !x.i.CompareAndSwap(magic32, 1) || x.i.Load() != 1 {
+		t.Fatalf("CompareAndSwap: i=%#x", x.i.Load())
+	}
+	x.i.Store(-1)
+	if x.i.Load() != -1 {
+		t.Fatalf("Store(-1): Load() = %d", x.i.Load())
+	}
+	if x.before != magic32 || x.after != magic32 {
+		t.Fatalf("wrong magic: %#x _ %#x != %#x _ %#x", x.before, x.after, magic32, magic32)
+	}
+}
+
+func TestUint32Methods(t *testing.T) {
+	var x struct {
+		before uint32
+		i      Uint32
+		after  uint32
+	}
+	x.before = magic32
+	x.after = magic32
+	var j uint32
+	for delta := uint32(1); delta+delta > delta; delta += delta {
+		k := x.i.Add(delta)
+		j += delta
+		if x.i.Load() != j || k != j {
+			t.Fatalf("delta=%d i=%d j=%d k=%d", delta, x.i.Load(), j, k)
+		}
+	}
+	if old := x.i.Swap(magic32); old != j || x.i.Load() != magic32 {
+		t.Fatalf("Swap(%#x) = %#x, Load() = %#x", magic32, old, x.i.Load())
+	}
+	if x.i.CompareAndSwap(0, 1) || !x.i.CompareAndSwap(magic32, 1) || x.i.Load() != 1 {
+		t.Fatalf("CompareAndSwap: i=%#x", x.i.Load())
+	}
+	if x.before != magic32 || x.after != magic32 {
+		t.Fatalf("wrong magic: %#x _ %#x != %#x _ %#x", x.before, x.after, magic32, magic32)
+	}
+}
+
+func TestInt64Methods(t *testing.T) {
+	var x struct {
+		before int64
+		i      Int64
+		after  int64
+	}
+	x.before = magic64
+	x.after = magic64
+	var j int64
+	for delta := int64(1); delta+delta > delta; delta += delta {
+		k := x.i.Add(delta)
+		j += delta
+		if x.i.Load() != j || k != j {
+			t.Fatalf("delta=%d i=%d j=%d k=%d", delta, x.i.Load(), j, k)
+		}
+	}
+	if old := x.i.Swap(magic64); old != j || x.i.Load() != magic64 {
+		t.Fatalf("Swap(%#x) = %#x, Load() = %#x", int64(magic64), old, x.i.Load())
+	}
+	if x.i.CompareAndSwap(0, 1) || !x.i.CompareAndSwap(magic64, 1) || x.i.Load() != 1 {
+		t.Fatalf("CompareAndSwap: i=%#x", x.i.Load())
+	}
+	x.i.Store(-1)
+	if x.i.Load() != -1 {
+		t.Fatalf("Store(-1): Load() = %d", x.i.Load())
+	}
+	if x.before != magic64 || x.after != magic64 {
+		t.Fatalf("wrong magic: %#x _ %#x != %#x _ %#x", x.before, x.after, uint64(magic64), uint64(magic64))
+	}
+}
+
+func TestUint64Methods(t *testing.T) {
+	var x struct {
+		before uint64
+		i      Uint64
+		after  uint64
+	}
+	x.before = magic64
+	x.after = magic64
+	var j uint64
+	for delta := uint64(1); delta+delta > delta; delta += delta {
+		k := x.i.Add(delta)
+		j += delta
+		if x.i.Load() != j || k != j {
+			t.Fatalf("delta=%d i=%d j=%d k=%d", delta, x.i.Load(), j, k)
+		}
+	}
+	if old := x.i.Swap(magic64); old != j || x.i.Load() != magic64 {
+		t.Fatalf("Swap(%#x) = %#x, Load() = %#x", uint64(magic64), old, x.i.Load())
+	}
+	if x.i.CompareAndSwap(0, 1) || !x.i.CompareAndSwap(magic64, 1) || x.i.Load() != 1 {
+		t.Fatalf("CompareAndSwap: i=%#x", x.i.Load())
+	}
+	if x.before != magic64 || x.after != magic64 {
+		t.Fatalf("wrong magic: %#x _ %#x != %#x _ %#x", x.before, x.after, uint64(magic64), uint64(magic64))
+	}
+}
+
+func TestUintptrMethods(t *testing.T) {
+	var x Uintptr
+	var j uintptr
+	for delta := uintptr(1); delta+delta > delta; delta += delta {
+		k := x.Add(delta)
+		j += delta
+		if x.Load() != j || k != j {
+			t.Fatalf("delta=%d i=%d j=%d k=%d", delta, x.Load(), j, k)
+		}
+	}
+	if old := x.Swap(magic32); old != j || x.Load() != magic32 {
+		t.Fatalf("Swap(%#x) = %#x, Load() = %#x", magic32, old, x.Load())
+	}
+	if x.CompareAndSwap(0, 1) || !x.CompareAndSwap(magic32, 1) || x.Load() != 1 {
+		t.Fatalf("CompareAndSwap: i=%#x", x.Load())
+	}
+}
+
+func TestPointerMethods(t *testing.T) {
+	type T struct{ n int }
+	a, b := &T{1}, &T{2}
+	var x Pointer[T]
+	if p := x.Load(); p != nil {
+		t.Fatalf("zero Pointer: Load() = %v", p)
+	}
+	x.Store(a)
+	if p := x.Load(); p != a {
+		t.Fatalf("Store(a): Load() = %v", p)
+	}
+	if old := x.Swap(b); old != a || x.Load() != b {
+		t.Fatalf("Swap(b) = %v, Load() = %v", old, x.Load())
+	}
+	if x.CompareAndSwap(a, nil) {
+		t.Fatalf("CompareAndSwap(a, nil) succeeded on b")
+	}
+	if !x.CompareAndSwap(b, nil) || x.Load() != nil {
+		t.Fatalf("CompareAndSwap(b, nil) failed on b")
+	}
+}
+
+var globalAligned struct {
+	b byte
+	i Int64
+	u Uint64
+}
+
+// Test that Int64 and Uint64 are 64-bit aligned wherever they are
+// allocated, even on 32-bit systems.
+func TestAligned64(t *testing.T) {
+	type S struct {
+		b byte
+		i Int64
+		u Uint64
+	}
+	if off := unsafe.Offsetof(S{}.i); off != 8 {
+		t.Errorf("offset of Int64 field = %d, want 8", off)
+	}
+	if off := unsafe.Offsetof(S{}.u); off != 16 {
+		t.Errorf("offset of Uint64 field = %d, want 16", off)
+	}
+
+	var local S
+	heap := new(S)
+	ptrs := []*S{&local, heap, &S{}, (*S)(unsafe.Pointer(&globalAligned))}
+	for i, p := range ptrs {
+		if addr := uintptr(unsafe.Pointer(&p.i)); addr%8 != 0 {
+			t.Errorf("#%d: Int64 at %#x is not 64-bit aligned", i, addr)
+		}
+		// Would panic with misaligned addresses on 32-bit systems.
+		p.i.Add(1)
+		p.u.Add(1)
+	}
+	runtime.KeepAlive(ptrs)
+}
+
+func TestHammerInt64Methods(t *testing.T) {
+	const (
+		n = 4
+		m = 10000
+	)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(n))
+	var x struct {
+		b byte
+		i Int64
+	}
+	done := make(chan bool)
+	for i := 0; i < n; i++ {
+		go func() {
+			for j := 0; j < m; j++ {
+				x.i.Add(1)
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-done
+	}
+	if v := x.i.Load(); v != n*m {
+		t.Fatalf("Int64 = %d, want %d", v, n*m)
+	}
+}
@@ -34,6 +34,22 @@ type Locker interface {
 	Unlock()
 }
 
+// A Context carries a cancelation signal for the LockContext methods.
+// It is implemented by context.Context, which this package cannot
+// refer to because package context depends on it.
+//
+// Done returns a channel that is closed when the wait should be
+// abandoned, or nil if it never should be; nothing may be sent on it.
+// Err returns a non-nil error once Done is closed.
+type Context interface {
+	Done() <-chan struct{}
+	Err() error
+}
+
+// mutexDebug reports whether GODEBUG=mutexowner=1 is set, in which
+// case the runtime records which goroutine holds each Mutex.
+var mutexDebug = runtime_mutexOwnerEnabled()
+
 const (
 	mutexLocked = 1 << iota // mutex is locked
 	mutexWoken
@@ -89,9 +105,89 @@ func (m *Mutex) Lock() {
 		if race.Enabled {
 			race.Acquire(unsafe.Pointer(m))
 		}
+		if mutexDebug {
+			runtime_mutexAcquired(unsafe.Pointer(m))
+		}
 		return
 	}
+	// Slow path (outlined so that the fast path can be inlined)
+	m.lockSlow(nil)
+	if race.Enabled {
+		race.Acquire(unsafe.Pointer(m))
+	}
+	if mutexDebug {
+		runtime_mutexAcquired(unsafe.Pointer(m))
+	}
+}
+
+// TryLock tries to lock m and reports whether it succeeded.
+//
+// Note that while correct uses of TryLock do exist, they are rare,
+// and use of TryLock is often a sign of a deeper problem
+// in a particular use of mutexes.
+func (m *Mutex) TryLock() bool {
+	old := m.state
+	if old&(mutexLocked|mutexStarving) != 0 {
+		return false
+	}
+
+	// There may be a goroutine waiting for the mutex, but we are
+	// running now and can try to grab the mutex before that
+	// goroutine wakes up.
+	if !atomic.CompareAndSwapInt32(&m.state, old, old|mutexLocked) {
+		return false
+	}
+
+	if race.Enabled {
+		race.Acquire(unsafe.Pointer(m))
+	}
+	if mutexDebug {
+		runtime_mutexAcquired(unsafe.Pointer(m))
+	}
+	return true
+}
+
+// LockContext locks m like Lock, but if the lock is in use it only
+// waits until ctx is done. It returns nil if it locked m, and ctx.Err()
+// otherwise. If ctx is done before LockContext is called, it still
+// locks m if that does not require waiting.
+//
+// While a goroutine waits in LockContext, no helper goroutine is
+// involved and no timers are set, so abandoning the wait is cheap.
+func (m *Mutex) LockContext(ctx Context) error {
+	if m.TryLock() {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !m.lockDone(ctx.Done()) {
+		return ctx.Err()
+	}
+	return nil
+}
 
+// lockDone locks m like Lock, but gives up waiting once done is closed.
+// It reports whether it locked m.
+func (m *Mutex) lockDone(done <-chan struct{}) bool {
+	if m.TryLock() {
+		return true
+	}
+	if !m.lockSlow(done) {
+		return false
+	}
+	if race.Enabled {
+		race.Acquire(unsafe.Pointer(m))
+	}
+	if mutexDebug {
+		runtime_mutexAcquired(unsafe.Pointer(m))
+	}
+	return true
+}
+
+// lockSlow is the contended part of Lock. If done is not nil, lockSlow
+// gives up waiting once it is closed, and reports whether it locked m.
+func (m *Mutex) lockSlow(done <-chan struct{}) bool {
 	var waitStartTime int64
 	starving := false
 	awoke := false
@@ -145,7 +241,11 @@ func (m *Mutex) Lock() {
 			if waitStartTime == 0 {
 				waitStartTime = runtime_nanotime()
 			}
-			runtime_SemacquireMutex(&m.sema, queueLifo)
+			if done == nil {
+				runtime_SemacquireMutex(&m.sema, queueLifo, 1)
+			} else if !runtime_SemacquireMutexDone(&m.sema, queueLifo, 1, done) && m.withdraw() {
+				return false
+			}
 			starving = starving || runtime_nanotime()-waitStartTime > starvationThresholdNs
 			old = m.state
 			if old&mutexStarving != 0 {
@@ -174,9 +274,31 @@ func (m *Mutex) Lock() {
 			old = m.state
 		}
 	}
+	return true
+}
 
-	if race.Enabled {
-		race.Acquire(unsafe.Pointer(m))
+// withdraw removes a waiter that gave up waiting from the count of
+// waiters and reports whether it did. The waiter might have been
+// counted out already by an Unlock that went on to wake it through
+// m.sema, after it stopped waiting there. In that case withdraw
+// instead takes the wakeup meant for it, and reports false: the
+// waiter must go on as if woken.
+func (m *Mutex) withdraw() bool {
+	old := m.state
+	for {
+		waiters := old >> mutexWaiterShift
+		// In normal mode Unlock counts out the waiter it wakes.
+		// In starvation mode the woken waiter counts itself out,
+		// so an Unlock handed the mutex to us if we are the only
+		// waiter left and the mutex is not locked.
+		if waiters == 0 || waiters == 1 && old&(mutexLocked|mutexStarving) == mutexStarving {
+			runtime_SemacquireMutex(&m.sema, true, 2)
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&m.state, old, old-1<<mutexWaiterShift) {
+			return true
+		}
+		old = m.state
 	}
 }
 
@@ -185,15 +307,35 @@ func (m *Mutex) Lock() {
 //
 // A locked Mutex is not associated with a particular goroutine.
 // It is allowed for one goroutine to lock a Mutex and then
-// arrange for another goroutine to unlock it.
+// arrange for another goroutine to unlock it, unless the program
+// runs with GODEBUG=mutexowner=1 (see package runtime).
 func (m *Mutex) Unlock() {
 	if race.Enabled {
 		_ = m.state
 		race.Release(unsafe.Pointer(m))
 	}
+	if mutexDebug {
+		runtime_mutexReleased(unsafe.Pointer(m), true)
+	}
 
 	// Fast path: drop lock bit.
 	new := atomic.AddInt32(&m.state, -mutexLocked)
+	if new != 0 {
+		// Outlined slow path to allow inlining the fast path.
+		// To hide unlockSlow during tracing we skip one extra frame when tracing GoUnblock.
+		m.unlockSlow(new)
+	}
+}
+
+// unlock is Unlock without the race and owner annotations.
+func (m *Mutex) unlock() {
+	new := atomic.AddInt32(&m.state, -mutexLocked)
+	if new != 0 {
+		m.unlockSlow(new)
+	}
+}
+
+func (m *Mutex) unlockSlow(new int32) {
 	if (new+mutexLocked)&mutexLocked == 0 {
 		throw("sync: unlock of unlocked mutex")
 	}
@@ -212,7 +354,7 @@ func (m *Mutex) Unlock() {
 			// Grab the right to wake someone.
 			new = (old - 1<<mutexWaiterShift) | mutexWoken
 			if atomic.CompareAndSwapInt32(&m.state, old, new) {
-				runtime_Semrelease(&m.sema, false)
+				runtime_Semrelease(&m.sema, false, 1)
 				return
 			}
 			old = m.state
@@ -222,6 +364,6 @@ func (m *Mutex) Unlock() {
 		// Note: mutexLocked is not set, the waiter will set it after wakeup.
 		// But mutex is still considered locked if mutexStarving is set,
 		// so new coming goroutines won't acquire it.
-		runtime_Semrelease(&m.sema, true)
+		runtime_Semrelease(&m.sema, true, 1)
 	}
 }
@@ -7,6 +7,7 @@
 package sync_test
 
 import (
+	"context"
 	"fmt"
 	"internal/testenv"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"runtime"
 	"strings"
 	. "sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -21,7 +23,7 @@ import (
 func HammerSemaphore(s *uint32, loops int, cdone chan bool) {
 	for i := 0; i < loops; i++ {
 		Runtime_Semacquire(s)
-		Runtime_Semrelease(s, false)
+		Runtime_Semrelease(s, false, 0)
 	}
 	cdone <- true
 }
@@ -151,8 +153,66 @@ var misuseTests = []struct {
 	},
 }
 
+var ownerMisuseTests = []struct {
+	name string
+	f    func()
+	want []string
+}{
+	{
+		"Mutex.UnlockOtherGoroutine",
+		func() {
+			var mu Mutex
+			lockMutexForOwnerTest(&mu)
+			done := make(chan bool)
+			go func() {
+				mu.Unlock()
+				done <- true
+			}()
+			<-done
+		},
+		[]string{"locked by another goroutine", "locked at:\nsync.(*Mutex).Lock", "sync_test.lockMutexForOwnerTest"},
+	},
+	{
+		"Mutex.UnlockUnlocked",
+		func() {
+			var mu Mutex
+			mu.Lock()
+			unlockMutexForOwnerTest(&mu)
+			mu.Unlock()
+		},
+		[]string{"already unlocked by goroutine", "sync.(*Mutex).Unlock", "sync_test.unlockMutexForOwnerTest", "unlock of unlocked mutex"},
+	},
+	{
+		"RWMutex.UnlockOtherGoroutine",
+		func() {
+			var mu RWMutex
+			mu.Lock()
+			done := make(chan bool)
+			go func() {
+				mu.Unlock()
+				done <- true
+			}()
+			<-done
+		},
+		[]string{"locked by another goroutine", "sync.(*RWMutex).Lock"},
+	},
+}
+
+//go:noinline
+func lockMutexForOwnerTest(mu *Mutex) { mu.Lock() }
+
+//go:noinline
+func unlockMutexForOwnerTest(mu *Mutex) { mu.Unlock() }
+
 func init() {
 	if len(os.Args) == 3 && os.Args[1] == "TESTMISUSE" {
+		for _, test := range ownerMisuseTests {
+			if test.name == os.Args[2] {
+				test.f()
+				fmt.Printf("test completed\n")
+				os.Exit(0)
+			}
+		}
 		for _, test := range misuseTests {
 			if test.name == os.Args[2] {
 				func() {
@@ -178,6 +238,141 @@ func TestMutexMisuse(t *testing.T) {
 	}
 }
 
+func TestMutexOwner(t *testing.T) {
+	testenv.MustHaveExec(t)
+	for _, test := range ownerMisuseTests {
+		cmd := exec.Command(os.Args[0], "TESTMISUSE", test.name)
+		cmd.Env = append(os.Environ(), "GODEBUG=mutexowner=1")
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Errorf("%s: did not fail:\n%s", test.name, out)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("%s: output does not contain %q:\n%s", test.name, want, out)
+			}
+		}
+	}
+
+	// Without GODEBUG, unlocking from another goroutine is fine.
+	out, err := exec.Command(os.Args[0], "TESTMISUSE", "Mutex.UnlockOtherGoroutine").CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "test completed") {
+		t.Errorf("Mutex.UnlockOtherGoroutine without mutexowner: %v\n%s", err, out)
+	}
+}
+
+func TestMutexTryLock(t *testing.T) {
+	var mu Mutex
+	if !mu.TryLock() {
+		t.Fatal("TryLock failed with mutex unlocked")
+	}
+	if mu.TryLock() {
+		t.Fatal("TryLock succeeded with mutex locked")
+	}
+	mu.Unlock()
+	if !mu.TryLock() {
+		t.Fatal("TryLock failed with mutex unlocked")
+	}
+	mu.Unlock()
+}
+
+func TestMutexLockContext(t *testing.T) {
+	var mu Mutex
+	if err := mu.LockContext(context.Background()); err != nil {
+		t.Fatalf("LockContext with mutex unlocked: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := mu.LockContext(ctx); err != context.Canceled {
+		t.Fatalf("LockContext with canceled context = %v, want %v", err, context.Canceled)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mu.LockContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("LockContext with timeout = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// A waiter that gave up must not keep the mutex from other waiters.
+	locked := make(chan bool)
+	go func() {
+		mu.Lock()
+		locked <- true
+	}()
+	time.Sleep(time.Millisecond)
+	mu.Unlock()
+	<-locked
+	mu.Unlock()
+
+	// A waiting LockContext gets the mutex once it is unlocked.
+	mu.Lock()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	errc := make(chan error)
+	go func() {
+		errc <- mu.LockContext(ctx)
+	}()
+	time.Sleep(time.Millisecond)
+	mu.Unlock()
+	if err := <-errc; err != nil {
+		t.Fatalf("LockContext: %v", err)
+	}
+	if mu.TryLock() {
+		t.Fatal("TryLock succeeded after LockContext")
+	}
+	mu.Unlock()
+}
+
+// Test that LockContext waiters giving up at random times in all
+// stages of waiting keep the mutex consistent, both in normal and
+// starvation mode.
+func TestMutexLockContextHammer(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	n := 1000
+	if testing.Short() {
+		n = 100
+	}
+	var mu Mutex
+	var held int32
+	done := make(chan bool)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			for j := 0; j < n; j++ {
+				if i%2 == 0 {
+					mu.Lock()
+				} else {
+					timeout := time.Duration(j%5) * 50 * time.Microsecond
+					ctx, cancel := context.WithTimeout(context.Background(), timeout)
+					err := mu.LockContext(ctx)
+					cancel()
+					if err != nil {
+						continue
+					}
+				}
+				if atomic.AddInt32(&held, 1) != 1 {
+					panic("mutual exclusion violated")
+				}
+				if j%10 == 0 {
+					// Hold the mutex long enough to make
+					// waiters switch it to starvation mode.
+					time.Sleep(2 * time.Millisecond)
+				}
+				atomic.AddInt32(&held, -1)
+				mu.Unlock()
+			}
+			done <- true
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+	if !mu.TryLock() {
+		t.Fatal("TryLock failed after all goroutines unlocked")
+	}
+	mu.Unlock()
+}
+
 func TestMutexFairness(t *testing.T) {
 	var mu Mutex
 	stop := make(chan bool)
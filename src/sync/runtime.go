@@ -13,16 +13,28 @@ import "unsafe"
 // library and should not be used directly.
 func runtime_Semacquire(s *uint32)
 
+// SemacquireDone is like Semacquire, but gives up waiting once done is
+// closed. It reports whether it decremented *s.
+func runtime_SemacquireDone(s *uint32, done <-chan struct{}) bool
+
 // SemacquireMutex is like Semacquire, but for profiling contended Mutexes.
 // If lifo is true, queue waiter at the head of wait queue.
-func runtime_SemacquireMutex(s *uint32, lifo bool)
+// skipframes is the number of frames to omit during tracing, counting from
+// runtime_SemacquireMutex's caller.
+func runtime_SemacquireMutex(s *uint32, lifo bool, skipframes int)
+
+// SemacquireMutexDone is like SemacquireMutex, but gives up waiting
+// once done is closed. It reports whether it decremented *s.
+func runtime_SemacquireMutexDone(s *uint32, lifo bool, skipframes int, done <-chan struct{}) bool
 
 // Semrelease atomically increments *s and notifies a waiting goroutine
 // if one is blocked in Semacquire.
 // It is intended as a simple wakeup primitive for use by the synchronization
 // library and should not be used directly.
 // If handoff is true, pass count directly to the first waiter.
-func runtime_Semrelease(s *uint32, handoff bool)
+// skipframes is the number of frames to omit during tracing, counting from
+// runtime_Semrelease's caller.
+func runtime_Semrelease(s *uint32, handoff bool, skipframes int)
 
 // Approximation of notifyList in runtime/sema.go. Size and alignment must
 // agree.
@@ -61,3 +73,9 @@ func runtime_canSpin(i int) bool
 func runtime_doSpin()
 
 func runtime_nanotime() int64
+
+// Mutex owner tracking, enabled by GODEBUG=mutexowner=1.
+// See runtime/sema.go for documentation.
+func runtime_mutexOwnerEnabled() bool
+func runtime_mutexAcquired(m unsafe.Pointer)
+func runtime_mutexReleased(m unsafe.Pointer, checkOwner bool)
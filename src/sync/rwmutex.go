@@ -55,6 +55,69 @@ func (rw *RWMutex) RLock() {
 	}
 }
 
+// TryRLock tries to lock rw for reading and reports whether it succeeded.
+//
+// Note that while correct uses of TryRLock do exist, they are rare,
+// and use of TryRLock is often a sign of a deeper problem
+// in a particular use of mutexes.
+func (rw *RWMutex) TryRLock() bool {
+	if race.Enabled {
+		_ = rw.w.state
+		race.Disable()
+	}
+	for {
+		c := atomic.LoadInt32(&rw.readerCount)
+		if c < 0 {
+			if race.Enabled {
+				race.Enable()
+			}
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&rw.readerCount, c, c+1) {
+			if race.Enabled {
+				race.Enable()
+				race.Acquire(unsafe.Pointer(&rw.readerSem))
+			}
+			return true
+		}
+	}
+}
+
+// RLockContext locks rw for reading like RLock, but if a writer holds
+// or is waiting for the lock it only waits until ctx is done. It returns
+// nil if it locked rw, and ctx.Err() otherwise.
+func (rw *RWMutex) RLockContext(ctx Context) error {
+	if rw.TryRLock() {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := ctx.Done()
+	if race.Enabled {
+		_ = rw.w.state
+		race.Disable()
+	}
+	// Wait for the writer by queuing behind it on w, which it holds
+	// until it unlocks rw. While we hold w no writer is pending,
+	// so we can register as a reader without waiting.
+	locked := rw.w.lockDone(done)
+	if locked {
+		atomic.AddInt32(&rw.readerCount, 1)
+		rw.w.Unlock()
+	}
+	if race.Enabled {
+		race.Enable()
+	}
+	if !locked {
+		return ctx.Err()
+	}
+	if race.Enabled {
+		race.Acquire(unsafe.Pointer(&rw.readerSem))
+	}
+	return nil
+}
+
 // RUnlock undoes a single RLock call;
 // it does not affect other simultaneous readers.
 // It is a run-time error if rw is not locked for reading
@@ -71,9 +134,14 @@ func (rw *RWMutex) RUnlock() {
 			throw("sync: RUnlock of unlocked RWMutex")
 		}
 		// A writer is pending.
-		if atomic.AddInt32(&rw.readerWait, -1) == 0 {
+		switch atomic.AddInt32(&rw.readerWait, -1) {
+		case 0:
 			// The last reader unblocks the writer.
-			runtime_Semrelease(&rw.writerSem, false)
+			runtime_Semrelease(&rw.writerSem, false, 0)
+		case rwmutexMaxReaders:
+			// The writer gave up waiting for us (see LockContext),
+			// so the last reader unlocks rw on its behalf.
+			rw.unlockAbandoned()
 		}
 	}
 	if race.Enabled {
@@ -104,6 +172,111 @@ func (rw *RWMutex) Lock() {
 	}
 }
 
+// TryLock tries to lock rw for writing and reports whether it succeeded.
+//
+// Note that while correct uses of TryLock do exist, they are rare,
+// and use of TryLock is often a sign of a deeper problem
+// in a particular use of mutexes.
+func (rw *RWMutex) TryLock() bool {
+	if race.Enabled {
+		_ = rw.w.state
+		race.Disable()
+	}
+	if !rw.w.TryLock() {
+		if race.Enabled {
+			race.Enable()
+		}
+		return false
+	}
+	if !atomic.CompareAndSwapInt32(&rw.readerCount, 0, -rwmutexMaxReaders) {
+		rw.w.Unlock()
+		if race.Enabled {
+			race.Enable()
+		}
+		return false
+	}
+	if race.Enabled {
+		race.Enable()
+		race.Acquire(unsafe.Pointer(&rw.readerSem))
+		race.Acquire(unsafe.Pointer(&rw.writerSem))
+	}
+	return true
+}
+
+// LockContext locks rw for writing like Lock, but if the lock is in use
+// it only waits until ctx is done. It returns nil if it locked rw, and
+// ctx.Err() otherwise.
+//
+// If LockContext gives up while waiting for readers to unlock rw, new
+// readers keep waiting until those readers have unlocked it, as they
+// would have had LockContext succeeded.
+func (rw *RWMutex) LockContext(ctx Context) error {
+	if rw.TryLock() {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := ctx.Done()
+	if race.Enabled {
+		_ = rw.w.state
+		race.Disable()
+	}
+	// First, resolve competition with other writers.
+	if !rw.w.lockDone(done) {
+		if race.Enabled {
+			race.Enable()
+		}
+		return ctx.Err()
+	}
+	// Announce to readers there is a pending writer.
+	r := atomic.AddInt32(&rw.readerCount, -rwmutexMaxReaders) + rwmutexMaxReaders
+	// Wait for active readers.
+	if r != 0 && atomic.AddInt32(&rw.readerWait, r) != 0 &&
+		!runtime_SemacquireDone(&rw.writerSem, done) && rw.abandon() {
+		if race.Enabled {
+			race.Enable()
+		}
+		return ctx.Err()
+	}
+	if race.Enabled {
+		race.Enable()
+		race.Acquire(unsafe.Pointer(&rw.readerSem))
+		race.Acquire(unsafe.Pointer(&rw.writerSem))
+	}
+	return nil
+}
+
+// abandon gives up the pending write lock of a writer that stopped
+// waiting for the active readers, and reports whether it did. Adding
+// rwmutexMaxReaders to readerWait tells the last departing reader to
+// unlock rw instead of waking the writer. If the last reader has
+// already left, the writer owns rw, and abandon takes the wakeup meant
+// for it and reports false.
+func (rw *RWMutex) abandon() bool {
+	if atomic.AddInt32(&rw.readerWait, rwmutexMaxReaders) != rwmutexMaxReaders {
+		return true
+	}
+	atomic.AddInt32(&rw.readerWait, -rwmutexMaxReaders)
+	runtime_Semacquire(&rw.writerSem)
+	return false
+}
+
+// unlockAbandoned unlocks rw for a writer that abandoned it.
+// It is called by the last reader the writer was waiting for.
+func (rw *RWMutex) unlockAbandoned() {
+	atomic.AddInt32(&rw.readerWait, -rwmutexMaxReaders)
+	r := atomic.AddInt32(&rw.readerCount, rwmutexMaxReaders)
+	for i := 0; i < int(r); i++ {
+		runtime_Semrelease(&rw.readerSem, false, 0)
+	}
+	// The writer locked w, so skip the owner check.
+	if mutexDebug {
+		runtime_mutexReleased(unsafe.Pointer(&rw.w), false)
+	}
+	rw.w.unlock()
+}
+
 // Unlock unlocks rw for writing. It is a run-time error if rw is
 // not locked for writing on entry to Unlock.
 //
@@ -126,7 +299,7 @@ func (rw *RWMutex) Unlock() {
 	}
 	// Unblock blocked readers, if any.
 	for i := 0; i < int(r); i++ {
-		runtime_Semrelease(&rw.readerSem, false)
+		runtime_Semrelease(&rw.readerSem, false, 0)
 	}
 	// Allow other writers to proceed.
 	rw.w.Unlock()
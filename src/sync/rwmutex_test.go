@@ -7,11 +7,13 @@
 package sync_test
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	. "sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // There is a modified copy of this file in runtime/rwmutex_test.go.
@@ -123,6 +125,177 @@ func TestRWMutex(t *testing.T) {
 	HammerRWMutex(10, 5, n)
 }
 
+func TestRWMutexTryLock(t *testing.T) {
+	var mu RWMutex
+	if !mu.TryLock() {
+		t.Fatal("TryLock failed with mutex unlocked")
+	}
+	if mu.TryLock() {
+		t.Fatal("TryLock succeeded with mutex locked")
+	}
+	if mu.TryRLock() {
+		t.Fatal("TryRLock succeeded with mutex locked")
+	}
+	mu.Unlock()
+
+	if !mu.TryRLock() {
+		t.Fatal("TryRLock failed with mutex unlocked")
+	}
+	if !mu.TryRLock() {
+		t.Fatal("TryRLock failed with mutex read-locked")
+	}
+	if mu.TryLock() {
+		t.Fatal("TryLock succeeded with mutex read-locked")
+	}
+	mu.RUnlock()
+	mu.RUnlock()
+
+	if !mu.TryLock() {
+		t.Fatal("TryLock failed with mutex unlocked")
+	}
+	mu.Unlock()
+}
+
+func TestRWMutexLockContext(t *testing.T) {
+	var mu RWMutex
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mu.Lock()
+	if err := mu.RLockContext(ctx); err != context.Canceled {
+		t.Fatalf("RLockContext with mutex locked = %v, want %v", err, context.Canceled)
+	}
+	if err := mu.LockContext(ctx); err != context.Canceled {
+		t.Fatalf("LockContext with mutex locked = %v, want %v", err, context.Canceled)
+	}
+	mu.Unlock()
+
+	// A writer that gives up waiting for a reader leaves the mutex
+	// to the reader; it is unlocked when the reader unlocks it.
+	mu.RLock()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mu.LockContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("LockContext with mutex read-locked = %v, want %v", err, context.DeadlineExceeded)
+	}
+	mu.RUnlock()
+	if !mu.TryLock() {
+		t.Fatal("TryLock failed after abandoned LockContext")
+	}
+	mu.Unlock()
+
+	// Readers blocked behind the abandoned writer get in.
+	mu.RLock()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	errc := make(chan error)
+	go func() {
+		errc <- mu.LockContext(ctx)
+	}()
+	rlocked := make(chan bool)
+	go func() {
+		time.Sleep(time.Millisecond)
+		mu.RLock()
+		rlocked <- true
+	}()
+	if err := <-errc; err != context.DeadlineExceeded {
+		t.Fatalf("LockContext with mutex read-locked = %v, want %v", err, context.DeadlineExceeded)
+	}
+	mu.RUnlock()
+	<-rlocked
+	mu.RUnlock()
+
+	// Waiting LockContext and RLockContext calls get the mutex.
+	mu.Lock()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		errc <- mu.RLockContext(ctx)
+	}()
+	time.Sleep(time.Millisecond)
+	mu.Unlock()
+	if err := <-errc; err != nil {
+		t.Fatalf("RLockContext: %v", err)
+	}
+	go func() {
+		errc <- mu.LockContext(ctx)
+	}()
+	time.Sleep(time.Millisecond)
+	mu.RUnlock()
+	if err := <-errc; err != nil {
+		t.Fatalf("LockContext: %v", err)
+	}
+	if mu.TryRLock() {
+		t.Fatal("TryRLock succeeded after LockContext")
+	}
+	mu.Unlock()
+}
+
+func contextReader(rwm *RWMutex, num_iterations int, activity *int32, cdone chan bool) {
+	for i := 0; i < num_iterations; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i%5)*20*time.Microsecond)
+		err := rwm.RLockContext(ctx)
+		cancel()
+		if err != nil {
+			continue
+		}
+		n := atomic.AddInt32(activity, 1)
+		if n < 1 || n >= 10000 {
+			panic(fmt.Sprintf("wlock(%d)\n", n))
+		}
+		for i := 0; i < 100; i++ {
+		}
+		atomic.AddInt32(activity, -1)
+		rwm.RUnlock()
+	}
+	cdone <- true
+}
+
+func contextWriter(rwm *RWMutex, num_iterations int, activity *int32, cdone chan bool) {
+	for i := 0; i < num_iterations; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i%5)*20*time.Microsecond)
+		err := rwm.LockContext(ctx)
+		cancel()
+		if err != nil {
+			continue
+		}
+		n := atomic.AddInt32(activity, 10000)
+		if n != 10000 {
+			panic(fmt.Sprintf("wlock(%d)\n", n))
+		}
+		for i := 0; i < 100; i++ {
+		}
+		atomic.AddInt32(activity, -10000)
+		rwm.Unlock()
+	}
+	cdone <- true
+}
+
+func TestRWMutexLockContextHammer(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	n := 1000
+	if testing.Short() {
+		n = 100
+	}
+	var activity int32
+	var rwm RWMutex
+	cdone := make(chan bool)
+	go writer(&rwm, n, &activity, cdone)
+	go contextWriter(&rwm, n, &activity, cdone)
+	go contextWriter(&rwm, n, &activity, cdone)
+	for i := 0; i < 3; i++ {
+		go reader(&rwm, n, &activity, cdone)
+		go contextReader(&rwm, n, &activity, cdone)
+	}
+	for i := 0; i < 9; i++ {
+		<-cdone
+	}
+	if !rwm.TryLock() {
+		t.Fatal("TryLock failed after all goroutines unlocked")
+	}
+	rwm.Unlock()
+}
+
 func TestRLocker(t *testing.T) {
 	var wl RWMutex
 	var rl Locker
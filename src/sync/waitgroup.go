@@ -97,7 +97,7 @@ func (wg *WaitGroup) Add(delta int) {
 	*statep = 0
 	for ; w != 0; w-- {
 		// 目的是作为一个简单的wakeup原语，以供同步使用。true为唤醒排在等待队列的第一个goroutine
-		runtime_Semrelease(&wg.sema, false)
+		runtime_Semrelease(&wg.sema, false, 0)
 	}
 }
 
@@ -20,7 +20,7 @@ import (
 // Really for use by package time, but we cannot import time here.
 
 type runtimeTimer struct {
-	tb uintptr
+	pp uintptr
 	i  int
 
 	when   int64
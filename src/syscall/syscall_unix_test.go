@@ -7,6 +7,7 @@
 package syscall_test
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"internal/testenv"
@@ -60,6 +61,31 @@ func _() {
 	)
 }
 
+func TestErrnoIs(t *testing.T) {
+	tests := []struct {
+		err    syscall.Errno
+		target error
+		want   bool
+	}{
+		{syscall.EACCES, os.ErrPermission, true},
+		{syscall.EPERM, os.ErrPermission, true},
+		{syscall.EEXIST, os.ErrExist, true},
+		{syscall.ENOTEMPTY, os.ErrExist, true},
+		{syscall.ENOENT, os.ErrNotExist, true},
+		{syscall.ENOENT, os.ErrExist, false},
+		{syscall.EINVAL, os.ErrInvalid, false},
+	}
+	for _, tt := range tests {
+		if got := errors.Is(tt.err, tt.target); got != tt.want {
+			t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+		}
+		wrapped := &os.PathError{Op: "open", Path: "x", Err: tt.err}
+		if got := errors.Is(wrapped, tt.target); got != tt.want {
+			t.Errorf("errors.Is(%v, %v) = %v, want %v", wrapped, tt.target, got, tt.want)
+		}
+	}
+}
+
 // TestFcntlFlock tests whether the file locking structure matches
 // the calling convention of each kernel.
 // On some Linux systems, glibc uses another set of values for the
@@ -14,7 +14,7 @@ func runtimeNano() int64
 // Interface to timers implemented in package runtime.
 // Must be in sync with ../runtime/time.go:/^type timer
 type runtimeTimer struct {
-	tb uintptr
+	pp uintptr
 	i  int
 
 	when   int64
@@ -474,3 +474,32 @@ func TestZeroTimerStopPanics(t *testing.T) {
 	var tr Timer
 	tr.Stop()
 }
+
+// Test that timers keep firing when the P they were started on goes away.
+func TestTimersGOMAXPROCSChange(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	const n = 100
+	var wg sync.WaitGroup
+	wg.Add(2 * n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			AfterFunc(Duration(i%10)*Millisecond, wg.Done)
+			Sleep(Duration(i%10) * Millisecond)
+			wg.Done()
+		}(i)
+	}
+	runtime.GOMAXPROCS(1)
+	runtime.GOMAXPROCS(4)
+	runtime.GOMAXPROCS(1)
+
+	done := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-After(10 * Second):
+		t.Fatal("timers did not fire after GOMAXPROCS change")
+	}
+}
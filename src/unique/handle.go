@@ -0,0 +1,94 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package unique canonicalizes, or interns, comparable values.
+//
+// Make returns a Handle for a value. The handles of equal values are
+// equal, and comparing handles is as cheap as comparing pointers,
+// whatever the values. Interning many equal values, such as strings
+// read from a file, also keeps a single copy of them in memory.
+//
+// Interned values are not kept alive by the package: a value is
+// dropped once no Handle for it is reachable anymore, after which Make
+// creates a new Handle for an equal value.
+package unique
+
+import (
+	"sync"
+	"unsafe"
+	"weak"
+)
+
+// A Handle is a unique identifier for a comparable value. Two Handles
+// compare equal exactly if the values used to create them compare
+// equal.
+type Handle struct {
+	value *interface{}
+}
+
+// Value returns a shallow copy of the value that produced the Handle.
+func (h Handle) Value() interface{} {
+	return *h.value
+}
+
+var (
+	mu sync.Mutex
+
+	// m maps the interned values to weak pointers to the boxes the
+	// Handles point to. It is created by the first call to Make.
+	m map[interface{}]weak.Pointer
+)
+
+// Make returns the Handle for value, which must be comparable.
+//
+// A string value is copied, so that the interned string does not keep
+// alive the memory it was sliced from. Other values are stored as
+// given, so the strings they contain are not copied.
+//
+// Values that are not equal to themselves, because they contain a NaN,
+// are not interned: each call to Make with them returns a new Handle.
+func Make(value interface{}) Handle {
+	mu.Lock()
+	defer mu.Unlock()
+	if m == nil {
+		m = make(map[interface{}]weak.Pointer)
+		runtime_registerUniqueMapCleanup(cleanup)
+	}
+	if wp, ok := m[value]; ok {
+		if p := wp.Value().(*interface{}); p != nil {
+			return Handle{p}
+		}
+	}
+	if s, ok := value.(string); ok {
+		value = clone(s)
+	}
+	p := new(interface{})
+	*p = value
+	if value == value {
+		m[value] = weak.Make(p)
+	}
+	return Handle{p}
+}
+
+// clone returns a copy of s.
+func clone(s string) string {
+	b := make([]byte, len(s))
+	copy(b, s)
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// cleanup deletes the entries of the values that were reclaimed.
+// The runtime calls it after each garbage collection.
+func cleanup() {
+	mu.Lock()
+	for value, wp := range m {
+		if wp.Value().(*interface{}) == nil {
+			delete(m, value)
+		}
+	}
+	mu.Unlock()
+}
+
+// Implemented in runtime.
+func runtime_registerUniqueMapCleanup(cleanup func())
@@ -0,0 +1,116 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unique
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type point struct {
+	x, y int
+	name string
+}
+
+func TestHandle(t *testing.T) {
+	s := strings.Repeat("x", 10)
+	h := Make(s)
+	if h2 := Make(strings.Repeat("x", 10)); h2 != h {
+		t.Errorf("handles of equal strings differ")
+	}
+	if h2 := Make(strings.Repeat("x", 11)); h2 == h {
+		t.Errorf("handles of different strings are equal")
+	}
+	if v := h.Value().(string); v != s {
+		t.Errorf("Value = %q, want %q", v, s)
+	}
+
+	p := Make(point{1, 2, "a"})
+	if p2 := Make(point{1, 2, "a"}); p2 != p {
+		t.Errorf("handles of equal structs differ")
+	}
+	if p2 := Make(point{1, 3, "a"}); p2 == p {
+		t.Errorf("handles of different structs are equal")
+	}
+	if v := p.Value().(point); v != (point{1, 2, "a"}) {
+		t.Errorf("Value = %v, want {1 2 a}", v)
+	}
+
+	if Make(int32(1)) == Make(int64(1)) {
+		t.Errorf("handles of values of different types are equal")
+	}
+	nan := math.NaN()
+	if Make(nan) == Make(nan) {
+		t.Errorf("handles of NaN are equal")
+	}
+}
+
+func TestHandleClone(t *testing.T) {
+	big := strings.Repeat("y", 1<<10)
+	h := Make(big[:5])
+	if s := h.Value().(string); s != "yyyyy" {
+		t.Errorf("Value = %q, want %q", s, "yyyyy")
+	}
+	runtime.KeepAlive(h)
+}
+
+func TestHandleUnhashable(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Make of an incomparable value did not panic")
+		}
+	}()
+	Make([]int{1})
+}
+
+// entries returns the number of values in the map.
+func entries() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(m)
+}
+
+func TestHandleReclaimed(t *testing.T) {
+	const n = 100
+	var keep []Handle
+	for i := 0; i < n; i++ {
+		keep = append(keep, Make(fmt.Sprint("kept ", i)))
+		Make(fmt.Sprint("dropped ", i))
+	}
+
+	// The runtime runs the cleanup after a GC, asynchronously.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		runtime.GC()
+		mu.Lock()
+		dropped := 0
+		for v := range m {
+			if s, ok := v.(string); ok && strings.HasPrefix(s, "dropped ") {
+				dropped++
+			}
+		}
+		mu.Unlock()
+		if dropped == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d dropped values are still in the map", dropped)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for i, h := range keep {
+		if h2 := Make(fmt.Sprint("kept ", i)); h2 != h {
+			t.Fatalf("handle of kept value %d changed", i)
+		}
+	}
+	if entries() < n {
+		t.Errorf("got %d values in the map, want at least %d", entries(), n)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package weak provides weak pointers, which refer to a value without
+// keeping it alive.
+//
+// A weak pointer lets a program, for example a cache, hold a value for
+// as long as the rest of the program uses it, and learn when the
+// garbage collector reclaimed it. Unlike a finalizer, a weak pointer
+// does not resurrect the value, delay its collection or prevent the
+// collection of cycles.
+package weak
+
+import "unsafe"
+
+// A Pointer is a weak pointer to a value of type T, made from a strong
+// pointer of type *T by Make.
+//
+// Two Pointers compare equal if they were made from the same pointer,
+// even after the value was reclaimed, so Pointers can be used as map
+// keys to identify values.
+//
+// Once the value is no longer reachable from strong pointers, the
+// garbage collector may reclaim it, and Value then returns a nil *T.
+// This happens before any finalizer of the value runs, so a value
+// resurrected by its finalizer is not reachable from weak pointers.
+//
+// The garbage collector tracks values, not pointers: a weak pointer to
+// a field of a struct keeps working as long as any part of the struct
+// is reachable, and the values packed by the allocator into a single
+// block, such as small values without pointers, are reclaimed together.
+// Pointers to values that are not allocated in the heap, such as global
+// variables, never become nil.
+type Pointer struct {
+	u   unsafe.Pointer // the handle shared by the weak pointers to the value
+	off uintptr        // offset of the pointer in the value
+	typ unsafe.Pointer // the type *T
+}
+
+// ifaceWords is the representation of an interface{}.
+type ifaceWords struct {
+	typ  unsafe.Pointer
+	data unsafe.Pointer
+}
+
+// Make returns a weak pointer made from ptr, which must be a pointer,
+// of type *T for some T, or unsafe.Pointer. A nil ptr of type *T makes
+// a weak pointer whose Value is always a nil *T.
+func Make(ptr interface{}) Pointer {
+	u, off := runtime_registerWeakPointer(ptr)
+	return Pointer{u: u, off: off, typ: (*ifaceWords)(unsafe.Pointer(&ptr)).typ}
+}
+
+// Value returns the pointer p was made from, as an interface value
+// holding a *T, or a nil *T if the value it points to was reclaimed.
+// It returns nil for the zero Pointer.
+//
+// The result is a strong pointer: it keeps the value alive like any
+// other pointer.
+func (p Pointer) Value() interface{} {
+	if p.typ == nil {
+		return nil
+	}
+	var v interface{}
+	w := (*ifaceWords)(unsafe.Pointer(&v))
+	w.typ = p.typ
+	if p.u != nil {
+		if base := runtime_makeStrongFromWeak(p.u); base != nil {
+			w.data = unsafe.Pointer(uintptr(base) + p.off)
+		}
+	}
+	return v
+}
+
+// Implemented in runtime.
+func runtime_registerWeakPointer(ptr interface{}) (unsafe.Pointer, uintptr)
+func runtime_makeStrongFromWeak(u unsafe.Pointer) unsafe.Pointer
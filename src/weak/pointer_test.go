@@ -0,0 +1,141 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package weak_test
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+	"weak"
+)
+
+type T struct {
+	// Pointers and enough size to keep the allocator from packing
+	// the value with others.
+	a *T
+	b [4]int
+}
+
+var global T
+
+func TestPointer(t *testing.T) {
+	p := new(T)
+	wp := weak.Make(p)
+	if v := wp.Value().(*T); v != p {
+		t.Fatalf("Value = %p, want %p", v, p)
+	}
+	if wq := weak.Make(p); wq != wp {
+		t.Errorf("weak pointers made from the same pointer differ")
+	}
+	if wq := weak.Make(new(T)); wq == wp {
+		t.Errorf("weak pointers made from different pointers are equal")
+	}
+	wb := weak.Make(&p.b[2])
+	if v := wb.Value().(*int); v != &p.b[2] {
+		t.Errorf("Value of an interior pointer = %p, want %p", v, &p.b[2])
+	}
+	runtime.GC()
+	if v := wp.Value().(*T); v != p {
+		t.Fatalf("Value after GC = %p, want %p", v, p)
+	}
+	runtime.KeepAlive(p)
+
+	p = nil
+	runtime.GC()
+	if v := wp.Value().(*T); v != nil {
+		t.Errorf("Value of reclaimed value = %p, want nil", v)
+	}
+	if v := wb.Value().(*int); v != nil {
+		t.Errorf("Value of interior pointer to reclaimed value = %p, want nil", v)
+	}
+}
+
+func TestPointerNil(t *testing.T) {
+	if v := (weak.Pointer{}).Value(); v != nil {
+		t.Errorf("Value of zero Pointer = %v, want nil", v)
+	}
+	if v := weak.Make((*T)(nil)).Value().(*T); v != nil {
+		t.Errorf("Value of nil weak pointer = %p, want nil", v)
+	}
+	wp := weak.Make(&global)
+	runtime.GC()
+	if v := wp.Value().(*T); v != &global {
+		t.Errorf("Value of pointer to global = %p, want %p", v, &global)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Make of a non-pointer did not panic")
+		}
+	}()
+	weak.Make(global)
+}
+
+func TestPointerFinalizer(t *testing.T) {
+	p := new(T)
+	wp := weak.Make(p)
+	done := make(chan bool)
+	runtime.SetFinalizer(p, func(p *T) {
+		if v := wp.Value().(*T); v != nil {
+			t.Errorf("Value in finalizer = %p, want nil", v)
+		}
+		close(done)
+	})
+	p = nil
+	runtime.GC()
+	if v := wp.Value().(*T); v != nil {
+		t.Errorf("Value of finalized value = %p, want nil", v)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("finalizer did not run")
+	}
+}
+
+func TestPointerConcurrentGC(t *testing.T) {
+	n := 1000
+	if testing.Short() {
+		n = 100
+	}
+	stop := make(chan bool)
+	gcDone := make(chan bool)
+	go func() {
+		defer close(gcDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var live []*T
+			var wps []weak.Pointer
+			for i := 0; i < n; i++ {
+				p := &T{b: [4]int{i}}
+				live = append(live, p)
+				wps = append(wps, weak.Make(p))
+				// And a weak pointer to garbage.
+				weak.Make(&T{})
+			}
+			for i, p := range live {
+				if v := wps[i].Value().(*T); v != p {
+					t.Errorf("Value of live value %d = %p, want %p", i, v, p)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-gcDone
+}
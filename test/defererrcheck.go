@@ -0,0 +1,79 @@
+// errorcheck -0 -l -d=defer
+
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Check that open-coded defers are used in the expected situations.
+
+package main
+
+import "fmt"
+
+var glob = 3
+
+func f1() {
+	for i := 0; i < 10; i++ {
+		fmt.Println("loop")
+	}
+	defer func() { // ERROR "open-coded defer"
+		fmt.Println("defer")
+	}()
+}
+
+func f2() {
+	for {
+		defer func() { // ERROR "heap-allocated defer"
+			fmt.Println("defer1")
+		}()
+		if glob > 2 {
+			break
+		}
+	}
+	defer func() { // ERROR "heap-allocated defer"
+		fmt.Println("defer2")
+	}()
+}
+
+func f3() {
+	defer fmt.Println(1) // ERROR "heap-allocated defer"
+	defer fmt.Println(2) // ERROR "heap-allocated defer"
+	defer fmt.Println(3) // ERROR "heap-allocated defer"
+	defer fmt.Println(4) // ERROR "heap-allocated defer"
+	defer fmt.Println(5) // ERROR "heap-allocated defer"
+	defer fmt.Println(6) // ERROR "heap-allocated defer"
+	defer fmt.Println(7) // ERROR "heap-allocated defer"
+	defer fmt.Println(8) // ERROR "heap-allocated defer"
+	defer fmt.Println(9) // ERROR "heap-allocated defer"
+}
+
+func f4(x int) {
+	defer fmt.Println(1) // ERROR "heap-allocated defer"
+	defer fmt.Println(2) // ERROR "heap-allocated defer"
+	switch x {
+	case 0:
+		return
+	case 1:
+		return
+	case 2:
+		return
+	case 3:
+		return
+	case 4:
+		return
+	case 5:
+		return
+	case 6:
+		return
+	case 7:
+		return
+	}
+}
+
+func f5(x int) {
+	defer fmt.Println(1) // ERROR "open-coded defer"
+	if x > 0 {
+		defer fmt.Println(2) // ERROR "open-coded defer"
+		return
+	}
+}
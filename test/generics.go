@@ -0,0 +1,137 @@
+// run
+
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Test generic functions and types.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+func Sum[T Number](list []T) T {
+	var s T
+	for _, x := range list {
+		s += x
+	}
+	return s
+}
+
+func Map[T, U any](list []T, f func(T) U) []U {
+	var res []U
+	for _, x := range list {
+		res = append(res, f(x))
+	}
+	return res
+}
+
+func Index[T comparable](list []T, x T) int {
+	for i, y := range list {
+		if y == x {
+			return i
+		}
+	}
+	return -1
+}
+
+type Stringer interface {
+	String() string
+}
+
+type MyInt int
+
+func (x MyInt) String() string { return "MyInt(" + strconv.Itoa(int(x)) + ")" }
+
+func Join[T Stringer](list []T) string {
+	s := ""
+	for _, x := range list {
+		s += x.String()
+	}
+	return s
+}
+
+type List[T any] struct {
+	next *List[T]
+	val  T
+}
+
+func (l *List[T]) Push(v T) *List[T] { return &List[T]{l, v} }
+
+func (l *List[T]) Slice() []T {
+	var s []T
+	for ; l != nil; l = l.next {
+		s = append(s, l.val)
+	}
+	return s
+}
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+func Pairs[K comparable, V any](m map[K]V, keys []K) []Pair[K, V] {
+	var res []Pair[K, V]
+	for _, k := range keys {
+		res = append(res, Pair[K, V]{k, m[k]})
+	}
+	return res
+}
+
+var ints *List[int]
+
+func main() {
+	if got := Sum([]int{1, 2, 3}); got != 6 {
+		panic(fmt.Sprintf("Sum(ints) = %d", got))
+	}
+	if got := Sum([]float64{0.5, 1}); got != 1.5 {
+		panic(fmt.Sprintf("Sum(floats) = %v", got))
+	}
+	if got := Sum([]MyInt{1, 2}); got != 3 {
+		panic(fmt.Sprintf("Sum(MyInts) = %v", got))
+	}
+	if got := fmt.Sprint(Map([]int{1, 2}, strconv.Itoa)); got != "[1 2]" {
+		panic(fmt.Sprintf("Map = %s", got))
+	}
+	if got := Map[int, string]([]int{3}, strconv.Itoa); got[0] != "3" {
+		panic(fmt.Sprintf("Map[int, string] = %v", got))
+	}
+	if got := Index([]string{"a", "b"}, "b"); got != 1 {
+		panic(fmt.Sprintf("Index = %d", got))
+	}
+	if got := Join([]MyInt{1, 2}); got != "MyInt(1)MyInt(2)" {
+		panic(fmt.Sprintf("Join = %s", got))
+	}
+
+	l := ints.Push(1).Push(2)
+	if got := fmt.Sprint(l.Slice()); got != "[2 1]" {
+		panic(fmt.Sprintf("List[int] = %s", got))
+	}
+	var s *List[string]
+	if got := fmt.Sprint(s.Push("a").Slice()); got != "[a]" {
+		panic(fmt.Sprintf("List[string] = %s", got))
+	}
+
+	p := Pairs(map[string]int{"x": 1, "y": 2}, []string{"y", "x"})
+	if got := fmt.Sprint(p); got != "[{y 2} {x 1}]" {
+		panic(fmt.Sprintf("Pairs = %s", got))
+	}
+
+	// Instances of the same generic type with the same type
+	// arguments are identical.
+	var x interface{} = Pair[string, int]{"a", 1}
+	if _, ok := x.(Pair[string, int]); !ok {
+		panic("Pair[string, int] is not Pair[string, int]")
+	}
+	if _, ok := x.(Pair[string, int64]); ok {
+		panic("Pair[string, int] is Pair[string, int64]")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+type node[T any] struct {
+	v    T
+	next *node[T]
+}
+
+type List[T any] struct {
+	head *node[T]
+	n    int
+}
+
+func (l *List[T]) Push(v T) {
+	l.head = &node[T]{v, l.head}
+	l.n++
+}
+
+func (l *List[T]) PushFunc(v T) {
+	push := func() {
+		l.head = &node[T]{v: v, next: l.head}
+	}
+	push()
+	l.n++
+}
+
+func (l *List[T]) Len() int { return l.n }
+
+func (l *List[T]) Slice() []T {
+	var s []T
+	for p := l.head; p != nil; p = p.next {
+		s = append(s, p.v)
+	}
+	return s
+}
+
+func New[T any](vs ...T) *List[T] {
+	l := &List[T]{}
+	for _, v := range vs {
+		l.Push(v)
+	}
+	return l
+}
@@ -0,0 +1,24 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"./a"
+)
+
+func main() {
+	var l a.List[string]
+	l.Push("x")
+	l.PushFunc("y")
+	if got := fmt.Sprint(l.Len(), l.Slice()); got != "2 [y x]" {
+		panic("got " + got)
+	}
+
+	if got := fmt.Sprint(a.New(1, 2, 3).Slice()); got != "[3 2 1]" {
+		panic("got " + got)
+	}
+}